@@ -0,0 +1,226 @@
+// This package reads the allocation bitmap and describes the free space on the
+// volume.
+
+package exfat
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+
+	"github.com/dsoprea/go-logging"
+)
+
+// AllocationBitmap records which clusters of the cluster heap are in use
+// (Section 7.1.5).
+type AllocationBitmap struct {
+	data         []byte
+	clusterCount uint32
+	clusterSize  uint32
+}
+
+// ReadAllocationBitmap finds the allocation bitmap that corresponds to the
+// active FAT and reads it.
+func (er *ExfatReader) ReadAllocationBitmap() (ab *AllocationBitmap, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	en := NewExfatNavigator(er, er.FirstClusterOfRootDirectory())
+
+	index, _, _, err := en.IndexDirectoryEntries()
+	log.PanicIf(err)
+
+	bsh := er.ActiveBootSectorHeader()
+
+	// The lowest bit of the BitmapFlags field is the BitmapIdentifier, which
+	// is set for the bitmap that goes with the second FAT (Section 7.1.2.1).
+	useSecond := bsh.VolumeFlags.UseSecondFat()
+
+	var abde *ExfatAllocationBitmapDirectoryEntry
+	for _, ide := range index["AllocationBitmap"] {
+		if ide.EntrySet != nil && ide.EntrySet.IsInUse() == false {
+			continue
+		}
+
+		current := ide.PrimaryEntry.(*ExfatAllocationBitmapDirectoryEntry)
+
+		isSecond := current.BitmapFlags&1 == 1
+		if isSecond == useSecond {
+			abde = current
+			break
+		}
+	}
+
+	if abde == nil {
+		log.Panicf("allocation-bitmap not found: USE-SECOND=[%v]", useSecond)
+	}
+
+	// Each bit describes one cluster, starting with cluster (2)
+	// (Section 7.1.5).
+	byteCount := (uint64(bsh.ClusterCount) + 7) / 8
+
+	if abde.DataLength < byteCount {
+		log.Panicf("allocation-bitmap too small for the cluster-count: (%d) < (%d)", abde.DataLength, byteCount)
+	}
+
+	b := bytes.NewBuffer(make([]byte, 0, byteCount))
+
+	_, _, err = er.WriteFromClusterChain(abde.FirstCluster, byteCount, true, b)
+	log.PanicIf(err)
+
+	ab = &AllocationBitmap{
+		data:         b.Bytes(),
+		clusterCount: bsh.ClusterCount,
+		clusterSize:  bsh.ClusterSize(),
+	}
+
+	return ab, nil
+}
+
+// ClusterCount returns the number of clusters that the bitmap describes.
+func (ab *AllocationBitmap) ClusterCount() uint32 {
+	return ab.clusterCount
+}
+
+// IsAllocated indicates whether the given cluster is in use.
+func (ab *AllocationBitmap) IsAllocated(clusterNumber uint32) bool {
+	if clusterNumber < 2 || uint64(clusterNumber) > uint64(ab.clusterCount)+1 {
+		log.Panicf("cluster not in the cluster heap: (%d)", clusterNumber)
+	}
+
+	i := clusterNumber - 2
+
+	return ab.data[i/8]&(1<<(i%8)) != 0
+}
+
+// FreeClusterCount returns the number of clusters that are not in use.
+func (ab *AllocationBitmap) FreeClusterCount() (count uint32) {
+	cb := func(fe FreeExtent) (doContinue bool, err error) {
+		count += fe.ClusterCount
+		return true, nil
+	}
+
+	err := ab.EnumerateFreeExtents(cb)
+	log.PanicIf(err)
+
+	return count
+}
+
+// FreeExtent is a run of adjacent clusters that are all free.
+type FreeExtent struct {
+	FirstCluster uint32
+	ClusterCount uint32
+}
+
+// String returns a string description.
+func (fe FreeExtent) String() string {
+	return fmt.Sprintf("FreeExtent<FIRST-CLUSTER=(%d) CLUSTER-COUNT=(%d)>", fe.FirstCluster, fe.ClusterCount)
+}
+
+// FreeExtentVisitorFunc is a visitor callback as all free extents are visited.
+type FreeExtentVisitorFunc func(fe FreeExtent) (doContinue bool, err error)
+
+// EnumerateFreeExtents calls the given callback for every run of free clusters,
+// in cluster order.
+func (ab *AllocationBitmap) EnumerateFreeExtents(cb FreeExtentVisitorFunc) (err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	var current *FreeExtent
+
+	for i := uint32(0); i < ab.clusterCount; {
+		// Skip over fully-allocated bytes all at once.
+		if i%8 == 0 && ab.data[i/8] == 0xff && current == nil {
+			i += 8
+			continue
+		}
+
+		isAllocated := ab.data[i/8]&(1<<(i%8)) != 0
+
+		if isAllocated == false {
+			if current == nil {
+				current = &FreeExtent{
+					FirstCluster: i + 2,
+				}
+			}
+
+			current.ClusterCount++
+		} else if current != nil {
+			doContinue, err := cb(*current)
+			log.PanicIf(err)
+
+			if doContinue == false {
+				return nil
+			}
+
+			current = nil
+		}
+
+		i++
+	}
+
+	if current != nil {
+		_, err := cb(*current)
+		log.PanicIf(err)
+	}
+
+	return nil
+}
+
+// LargestFreeExtents returns up to `n` of the largest free extents, largest
+// first (and lower clusters first for extents of the same size). If `n` is
+// zero or less, all extents are returned. Placing new data in the largest
+// extents keeps it contiguous, which allows it to be written without a FAT
+// chain (see NoFatChain).
+func (ab *AllocationBitmap) LargestFreeExtents(n int) (extents []FreeExtent, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	extents = make([]FreeExtent, 0)
+
+	cb := func(fe FreeExtent) (doContinue bool, err error) {
+		extents = append(extents, fe)
+		return true, nil
+	}
+
+	err = ab.EnumerateFreeExtents(cb)
+	log.PanicIf(err)
+
+	sort.SliceStable(extents, func(i, j int) bool {
+		return extents[i].ClusterCount > extents[j].ClusterCount
+	})
+
+	if n > 0 && len(extents) > n {
+		extents = extents[:n]
+	}
+
+	return extents, nil
+}
+
+// LargestContiguousFileSize returns the size, in bytes, of the largest file
+// that could currently be stored in one contiguous series of clusters.
+func (ab *AllocationBitmap) LargestContiguousFileSize() (size uint64, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	extents, err := ab.LargestFreeExtents(1)
+	log.PanicIf(err)
+
+	if len(extents) == 0 {
+		return 0, nil
+	}
+
+	return uint64(extents[0].ClusterCount) * uint64(ab.clusterSize), nil
+}
@@ -0,0 +1,145 @@
+package exfat
+
+import (
+	"io/ioutil"
+	"reflect"
+	"testing"
+
+	"github.com/dsoprea/go-logging"
+)
+
+func getTestAllocationBitmap() (tree *Tree, ab *AllocationBitmap, closer func()) {
+	tree, closer = getTestTree()
+
+	ab, err := tree.er.ReadAllocationBitmap()
+	log.PanicIf(err)
+
+	return tree, ab, closer
+}
+
+func TestExfatReader_ReadAllocationBitmap(t *testing.T) {
+	tree, ab, closer := getTestAllocationBitmap()
+
+	defer closer()
+
+	if ab.ClusterCount() != 239 {
+		t.Fatalf("Cluster-count not correct: (%d)", ab.ClusterCount())
+	}
+
+	// The bitmap, up-case table, and root directory come first.
+	if ab.IsAllocated(tree.er.FirstClusterOfRootDirectory()) != true {
+		t.Fatalf("Expected root-directory to be allocated.")
+	}
+
+	// Every cluster of every file that is in use must be allocated.
+
+	cb := func(pathParts []string, node *TreeNode) (err error) {
+		sede := node.StreamDirectoryEntry()
+		if sede == nil || node.IsInUse() == false || sede.ValidDataLength == 0 {
+			return nil
+		}
+
+		useFat := sede.GeneralSecondaryFlags.NoFatChain() == false
+
+		visitedClusters, _, err := tree.er.WriteFromClusterChain(sede.FirstCluster, sede.ValidDataLength, useFat, ioutil.Discard)
+		log.PanicIf(err)
+
+		for _, clusterNumber := range visitedClusters {
+			if ab.IsAllocated(clusterNumber) != true {
+				t.Fatalf("Cluster (%d) of [%s] not allocated.", clusterNumber, node.Name())
+			}
+		}
+
+		return nil
+	}
+
+	err := tree.Visit(cb)
+	log.PanicIf(err)
+}
+
+func TestAllocationBitmap_EnumerateFreeExtents(t *testing.T) {
+	_, ab, closer := getTestAllocationBitmap()
+
+	defer closer()
+
+	extents := make([]FreeExtent, 0)
+
+	cb := func(fe FreeExtent) (doContinue bool, err error) {
+		extents = append(extents, fe)
+		return true, nil
+	}
+
+	err := ab.EnumerateFreeExtents(cb)
+	log.PanicIf(err)
+
+	expected := []FreeExtent{
+		{FirstCluster: 85, ClusterCount: 11},
+		{FirstCluster: 98, ClusterCount: 1},
+		{FirstCluster: 104, ClusterCount: 137},
+	}
+
+	if reflect.DeepEqual(extents, expected) != true {
+		t.Fatalf("Extents not correct: %v", extents)
+	}
+
+	for _, fe := range extents {
+		for i := uint32(0); i < fe.ClusterCount; i++ {
+			if ab.IsAllocated(fe.FirstCluster+i) != false {
+				t.Fatalf("Cluster (%d) in %s is allocated.", fe.FirstCluster+i, fe)
+			}
+		}
+
+		if ab.IsAllocated(fe.FirstCluster-1) != true {
+			t.Fatalf("Extent not maximal: %s", fe)
+		}
+	}
+
+	if ab.FreeClusterCount() != 149 {
+		t.Fatalf("Free cluster-count not correct: (%d)", ab.FreeClusterCount())
+	}
+}
+
+func TestAllocationBitmap_EnumerateFreeExtents__Stop(t *testing.T) {
+	_, ab, closer := getTestAllocationBitmap()
+
+	defer closer()
+
+	count := 0
+
+	cb := func(fe FreeExtent) (doContinue bool, err error) {
+		count++
+		return false, nil
+	}
+
+	err := ab.EnumerateFreeExtents(cb)
+	log.PanicIf(err)
+
+	if count != 1 {
+		t.Fatalf("Enumeration did not stop: (%d)", count)
+	}
+}
+
+func TestAllocationBitmap_LargestFreeExtents(t *testing.T) {
+	_, ab, closer := getTestAllocationBitmap()
+
+	defer closer()
+
+	extents, err := ab.LargestFreeExtents(2)
+	log.PanicIf(err)
+
+	expected := []FreeExtent{
+		{FirstCluster: 104, ClusterCount: 137},
+		{FirstCluster: 85, ClusterCount: 11},
+	}
+
+	if reflect.DeepEqual(extents, expected) != true {
+		t.Fatalf("Extents not correct: %v", extents)
+	}
+
+	size, err := ab.LargestContiguousFileSize()
+	log.PanicIf(err)
+
+	if size != 137*4096 {
+		t.Fatalf("Largest contiguous size not correct: (%d)", size)
+	}
+}
@@ -0,0 +1,203 @@
+// This package defines the integration point for image containers.
+
+package exfat
+
+import (
+	"io"
+	"os"
+
+	"github.com/dsoprea/go-logging"
+)
+
+// Backend is random-access storage that holds an image. It is the integration
+// point for forensic and virtual-disk containers (e.g. EWF/E01, AFF4, or VHD):
+// wrap the container's library in a type that returns the decompressed image
+// data from ReadAt() and its total size from Size(), and pass it to
+// NewExfatReaderFromBackend(). For example, for an EWF library with a handle
+// type:
+//
+//	type ewfBackend struct {
+//		handle *ewf.Handle
+//	}
+//
+//	func (eb ewfBackend) ReadAt(p []byte, offset int64) (int, error) {
+//		return eb.handle.ReadAt(p, offset)
+//	}
+//
+//	func (eb ewfBackend) Size() int64 {
+//		return eb.handle.MediaSize()
+//	}
+//
+//	func (eb ewfBackend) Close() error {
+//		return eb.handle.Close()
+//	}
+//
+// ReadAt() must be safe for concurrent use and follow the io.ReaderAt rules
+// (returning io.EOF at the end of the image). Plain and split raw images are
+// supported in-tree by OpenRawBackend() and OpenSplitRawBackend().
+type Backend interface {
+	io.ReaderAt
+	io.Closer
+
+	// Size returns the size of the image in bytes.
+	Size() int64
+}
+
+// rawBackend is an ordinary image file.
+type rawBackend struct {
+	*os.File
+
+	size int64
+}
+
+// Size returns the size of the image in bytes.
+func (rb rawBackend) Size() int64 {
+	return rb.size
+}
+
+// OpenRawBackend opens an ordinary (raw, or "dd") image file as a Backend.
+func OpenRawBackend(filepath string) (backend Backend, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	f, err := os.Open(filepath)
+	log.PanicIf(err)
+
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		log.Panic(err)
+	}
+
+	backend = rawBackend{
+		File: f,
+		size: fi.Size(),
+	}
+
+	return backend, nil
+}
+
+// OpenSplitRawBackend opens a raw image that was split into several segment
+// files, given in order, as a Backend. See NewSegmentedReader().
+func OpenSplitRawBackend(paths ...string) (backend Backend, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	sr, err := NewSegmentedReader(paths...)
+	log.PanicIf(err)
+
+	return sr, nil
+}
+
+// BackendReader adapts a Backend to the io.ReadSeeker (and io.ReaderAt) that
+// NewExfatReader() takes. If the backend is writable, so is the reader (it
+// is then also an io.ReadWriteSeeker).
+type BackendReader struct {
+	backend Backend
+
+	// position is the offset for Read() and Seek().
+	position int64
+}
+
+// NewBackendReader returns a new BackendReader for the given backend.
+func NewBackendReader(backend Backend) *BackendReader {
+	return &BackendReader{
+		backend: backend,
+	}
+}
+
+// Backend returns the underlying backend.
+func (br *BackendReader) Backend() Backend {
+	return br.backend
+}
+
+// ReadAt reads from the given offset.
+func (br *BackendReader) ReadAt(data []byte, offset int64) (n int, err error) {
+	return br.backend.ReadAt(data, offset)
+}
+
+// Read reads from the current position.
+func (br *BackendReader) Read(data []byte) (n int, err error) {
+	if br.position >= br.backend.Size() {
+		return 0, io.EOF
+	}
+
+	n, err = br.backend.ReadAt(data, br.position)
+	br.position += int64(n)
+
+	if err == io.EOF && n > 0 {
+		err = nil
+	}
+
+	return n, err
+}
+
+// Seek moves the current position.
+func (br *BackendReader) Seek(offset int64, whence int) (position int64, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	switch whence {
+	case os.SEEK_SET:
+		position = offset
+	case os.SEEK_CUR:
+		position = br.position + offset
+	case os.SEEK_END:
+		position = br.backend.Size() + offset
+	default:
+		log.Panicf("whence not valid: (%d)", whence)
+	}
+
+	if position < 0 {
+		log.Panicf("position can not be negative: (%d)", position)
+	}
+
+	br.position = position
+
+	return position, nil
+}
+
+// WriteAt writes at the given offset if the backend implements io.WriterAt
+// (e.g. one from OpenWritableImageBackend()).
+func (br *BackendReader) WriteAt(data []byte, offset int64) (n int, err error) {
+	wa, ok := br.backend.(io.WriterAt)
+	if ok == false {
+		return 0, log.Errorf("backend is not writable: [%T]", br.backend)
+	}
+
+	return wa.WriteAt(data, offset)
+}
+
+// Write writes at the current position. See WriteAt().
+func (br *BackendReader) Write(data []byte) (n int, err error) {
+	n, err = br.WriteAt(data, br.position)
+	br.position += int64(n)
+
+	return n, err
+}
+
+// Close closes the backend.
+func (br *BackendReader) Close() error {
+	return br.backend.Close()
+}
+
+// NewExfatReaderFromBackend returns a new ExfatReader that reads the image from
+// the given backend. The reader takes ownership of the backend, which is
+// closed by the reader's Close().
+func NewExfatReaderFromBackend(backend Backend) *ExfatReader {
+	br := NewBackendReader(backend)
+
+	er := NewExfatReader(br)
+	er.closer = br
+
+	return er
+}
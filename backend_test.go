@@ -0,0 +1,116 @@
+package exfat
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/dsoprea/go-logging"
+)
+
+// testBackend is an in-memory backend, as a container adapter would provide.
+type testBackend struct {
+	*bytes.Reader
+
+	isClosed bool
+}
+
+func (tb *testBackend) Close() error {
+	tb.isClosed = true
+	return nil
+}
+
+func TestNewExfatReaderFromBackend(t *testing.T) {
+	image, _ := getTestImage()
+
+	backend := &testBackend{
+		Reader: bytes.NewReader(image),
+	}
+
+	er := NewExfatReaderFromBackend(backend)
+
+	err := er.Parse()
+	log.PanicIf(err)
+
+	data, err := er.ReadFile("79c6d31a-cca1-11e9-8325-9746d045e868")
+	log.PanicIf(err)
+
+	if len(data) != 29 {
+		t.Fatalf("File not read correctly: (%d)", len(data))
+	}
+
+	err = er.Close()
+	log.PanicIf(err)
+
+	if backend.isClosed != true {
+		t.Fatalf("Expected the backend to be closed.")
+	}
+}
+
+func TestOpenRawBackend(t *testing.T) {
+	filepath := path.Join(assetPath, "test.exfat")
+
+	backend, err := OpenRawBackend(filepath)
+	log.PanicIf(err)
+
+	defer backend.Close()
+
+	fi, err := os.Stat(filepath)
+	log.PanicIf(err)
+
+	if backend.Size() != fi.Size() {
+		t.Fatalf("Size not correct: (%d)", backend.Size())
+	}
+
+	er := NewExfatReader(NewBackendReader(backend))
+
+	err = er.Parse()
+	log.PanicIf(err)
+
+	if er.ActiveBootSectorHeader().ClusterCount != 239 {
+		t.Fatalf("Boot-sector not read correctly.")
+	}
+}
+
+func TestOpenSplitRawBackend(t *testing.T) {
+	paths, image, closer := getTestSegmentedImage(4096, 100000)
+	defer closer()
+
+	backend, err := OpenSplitRawBackend(paths...)
+	log.PanicIf(err)
+
+	er := NewExfatReaderFromBackend(backend)
+
+	defer er.Close()
+
+	err = er.Parse()
+	log.PanicIf(err)
+
+	if backend.Size() != int64(len(image)) {
+		t.Fatalf("Size not correct: (%d)", backend.Size())
+	}
+}
+
+func TestBackendReader_ReadAndSeek(t *testing.T) {
+	image := []byte("0123456789")
+
+	br := NewBackendReader(&testBackend{Reader: bytes.NewReader(image)})
+
+	_, err := br.Seek(-4, os.SEEK_END)
+	log.PanicIf(err)
+
+	data, err := ioutil.ReadAll(br)
+	log.PanicIf(err)
+
+	if string(data) != "6789" {
+		t.Fatalf("Data not correct: [%s]", data)
+	}
+
+	n, err := br.Read(make([]byte, 1))
+	if n != 0 || err != io.EOF {
+		t.Fatalf("Expected EOF: (%d) [%v]", n, err)
+	}
+}
@@ -0,0 +1,183 @@
+// This package reports the clusters that the FAT marks as bad and the files
+// that use them.
+
+package exfat
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/dsoprea/go-logging"
+)
+
+// BadClusters returns the clusters whose entries in the active FAT are marked
+// as bad (FFFFFFF7h), in order. These are regions of the media that a
+// formatter or a repair tool found to be unreliable.
+func (er *ExfatReader) BadClusters() (clusters []uint32, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	fat, err := er.ActiveFat()
+	log.PanicIf(err)
+
+	clusters = make([]uint32, 0)
+
+	lastClusterNumber := fat.EntryCount() + 1
+	for clusterNumber := uint32(2); clusterNumber <= lastClusterNumber; clusterNumber++ {
+		mc, err := fat.Entry(clusterNumber)
+		log.PanicIf(err)
+
+		if mc.IsBad() == true {
+			clusters = append(clusters, clusterNumber)
+		}
+	}
+
+	return clusters, nil
+}
+
+// BadClusterFile is a file or directory whose data uses one or more bad
+// clusters.
+type BadClusterFile struct {
+	// Path is the path of the file, with backslashes.
+	Path string `json:"path" yaml:"path"`
+
+	// Clusters are the bad clusters in the file's chain, in chain order.
+	Clusters []uint32 `json:"clusters" yaml:"clusters"`
+}
+
+// String returns a descriptive string.
+func (bcf BadClusterFile) String() string {
+	return fmt.Sprintf("BadClusterFile<PATH=[%s] CLUSTERS=%v>", bcf.Path, bcf.Clusters)
+}
+
+// collectBadClusters returns the clusters of the given chain that are in
+// `bad`. Like clusterReachability.markChain(), it stops quietly at the first
+// cluster that isn't in the heap since the chains of damaged volumes are being
+// followed.
+func (er *ExfatReader) collectBadClusters(firstCluster uint32, length uint64, useFat bool, bad map[uint32]struct{}) (found []uint32, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	bsh := er.ActiveBootSectorHeader()
+	clusterSize := uint64(bsh.ClusterSize())
+	lastClusterNumber := bsh.ClusterCount + 1
+
+	maximumCount := (length + clusterSize - 1) / clusterSize
+
+	clusterNumber := firstCluster
+	for i := uint64(0); i < maximumCount; i++ {
+		if clusterNumber < 2 || clusterNumber > lastClusterNumber {
+			break
+		}
+
+		if _, isBad := bad[clusterNumber]; isBad == true {
+			found = append(found, clusterNumber)
+		}
+
+		nextClusterNumber, isLast, err := er.nextClusterNumber(clusterNumber, useFat)
+		log.PanicIf(err)
+
+		if isLast == true {
+			break
+		}
+
+		clusterNumber = nextClusterNumber
+	}
+
+	return found, nil
+}
+
+// FindBadClusterFiles returns the files and directories that are in use and
+// whose data (or vendor allocations) include a cluster that the FAT marks as
+// bad, in Walk() order. Every directory is loaded. Nothing is walked if there
+// are no bad clusters.
+func FindBadClusterFiles(tree *Tree) (files []BadClusterFile, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	er := tree.er
+
+	badClusters, err := er.BadClusters()
+	log.PanicIf(err)
+
+	files = make([]BadClusterFile, 0)
+
+	if len(badClusters) == 0 {
+		return files, nil
+	}
+
+	bad := make(map[uint32]struct{}, len(badClusters))
+	for _, clusterNumber := range badClusters {
+		bad[clusterNumber] = struct{}{}
+	}
+
+	cb := func(pathParts []string, node *TreeNode) (err error) {
+		defer func() {
+			if errRaw := recover(); errRaw != nil {
+				err = log.Wrap(errRaw.(error))
+			}
+		}()
+
+		if len(pathParts) == 0 {
+			return nil
+		} else if node.IsInUse() == false {
+			if node.IsDirectory() == true {
+				return filepath.SkipDir
+			}
+
+			return nil
+		}
+
+		clusters := make([]uint32, 0)
+
+		sede := node.StreamDirectoryEntry()
+		if sede.DataLength > 0 {
+			useFat := sede.GeneralSecondaryFlags.NoFatChain() == false
+
+			found, err := er.collectBadClusters(sede.FirstCluster, sede.DataLength, useFat, bad)
+			log.PanicIf(err)
+
+			clusters = append(clusters, found...)
+		}
+
+		for _, de := range node.IndexedDirectoryEntry().SecondaryEntries {
+			vade, ok := de.(*ExfatVendorAllocationDirectoryEntry)
+			if ok == false || vade.DataLength == 0 {
+				continue
+			}
+
+			useFat := vade.GeneralSecondaryFlags.NoFatChain() == false
+
+			found, err := er.collectBadClusters(vade.FirstCluster, vade.DataLength, useFat, bad)
+			log.PanicIf(err)
+
+			clusters = append(clusters, found...)
+		}
+
+		if len(clusters) > 0 {
+			bcf := BadClusterFile{
+				Path:     strings.Join(pathParts, `\`),
+				Clusters: clusters,
+			}
+
+			files = append(files, bcf)
+		}
+
+		return nil
+	}
+
+	err = tree.Walk(cb)
+	log.PanicIf(err)
+
+	return files, nil
+}
@@ -0,0 +1,124 @@
+package exfat
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/dsoprea/go-logging"
+)
+
+// getTestBadClusterImage returns the test image with the second cluster of
+// the JPG and the last cluster of the heap marked as bad.
+func getTestBadClusterImage() (image []byte, lastClusterNumber uint32) {
+	image, er := getTestImage()
+
+	bsh := er.ActiveBootSectorHeader()
+
+	fatOffset := int64(bsh.FatOffset) * int64(bsh.SectorSize())
+	lastClusterNumber = bsh.ClusterCount + 1
+
+	defaultEncoding.PutUint32(image[fatOffset+8*4:], 0xfffffff7)
+	defaultEncoding.PutUint32(image[fatOffset+int64(lastClusterNumber)*4:], 0xfffffff7)
+
+	return image, lastClusterNumber
+}
+
+func TestExfatReader_BadClusters__None(t *testing.T) {
+	f, er := getTestFileAndParser()
+
+	defer f.Close()
+
+	err := er.Parse()
+	log.PanicIf(err)
+
+	clusters, err := er.BadClusters()
+	log.PanicIf(err)
+
+	if len(clusters) != 0 {
+		t.Fatalf("Expected no bad clusters: %v", clusters)
+	}
+}
+
+func TestExfatReader_BadClusters(t *testing.T) {
+	image, lastClusterNumber := getTestBadClusterImage()
+
+	er := NewExfatReader(bytes.NewReader(image))
+
+	err := er.Parse()
+	log.PanicIf(err)
+
+	clusters, err := er.BadClusters()
+	log.PanicIf(err)
+
+	expected := []uint32{8, lastClusterNumber}
+	if reflect.DeepEqual(clusters, expected) != true {
+		t.Fatalf("Bad clusters not correct: %v", clusters)
+	}
+}
+
+func TestFindBadClusterFiles(t *testing.T) {
+	image, _ := getTestBadClusterImage()
+
+	er := NewExfatReader(bytes.NewReader(image))
+
+	err := er.Parse()
+	log.PanicIf(err)
+
+	tree := NewTree(er)
+
+	files, err := FindBadClusterFiles(tree)
+	log.PanicIf(err)
+
+	if len(files) != 1 {
+		t.Fatalf("Expected one affected file: %v", files)
+	}
+
+	bcf := files[0]
+
+	if bcf.Path != "2-delahaye-type-165-cabriolet-dsc_8025.jpg" {
+		t.Fatalf("Path not correct: [%s]", bcf.Path)
+	} else if reflect.DeepEqual(bcf.Clusters, []uint32{8}) != true {
+		t.Fatalf("Clusters not correct: %v", bcf.Clusters)
+	}
+}
+
+func TestFindBadClusterFiles__None(t *testing.T) {
+	tree, closer := getTestTree()
+
+	defer closer()
+
+	files, err := FindBadClusterFiles(tree)
+	log.PanicIf(err)
+
+	if len(files) != 0 {
+		t.Fatalf("Expected no affected files: %v", files)
+	}
+}
+
+func TestExfatReader_CheckCompliance__BadClusters(t *testing.T) {
+	image, lastClusterNumber := getTestBadClusterImage()
+
+	report := getTestComplianceReport(image)
+
+	if reflect.DeepEqual(report.BadClusters, []uint32{8, lastClusterNumber}) != true {
+		t.Fatalf("Bad clusters not correct: %v", report.BadClusters)
+	} else if len(report.BadClusterFiles) != 1 {
+		t.Fatalf("Expected one affected file: %v", report.BadClusterFiles)
+	}
+
+	// Bad clusters are allowed, so the grade doesn't change.
+	if report.Grade() != ComplianceGradeDeviations {
+		t.Fatalf("Grade not correct: [%s]", report.Grade())
+	}
+
+	b := new(bytes.Buffer)
+	report.DumpTo(b)
+
+	if strings.Contains(b.String(), "Bad Clusters: (2)") != true {
+		t.Fatalf("Dump does not include the bad clusters:\n%s", b.String())
+	} else if strings.Contains(b.String(), "2-delahaye-type-165-cabriolet-dsc_8025.jpg: [8]") != true {
+		t.Fatalf("Dump does not include the affected file:\n%s", b.String())
+	}
+}
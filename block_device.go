@@ -0,0 +1,243 @@
+// This package supports reading from devices that only allow aligned reads.
+
+package exfat
+
+import (
+	"io"
+	"os"
+	"sync"
+	"unsafe"
+
+	"github.com/dsoprea/go-logging"
+)
+
+const (
+	// defaultAlignedReaderBufferSize is how much an AlignedReader reads from
+	// the device at a time. Directory and FAT reads are small and tend to be
+	// near each other, so reading ahead saves most of the round-trips.
+	defaultAlignedReaderBufferSize = 64 * 1024
+)
+
+// BlockDevice is a raw device that can only be read in whole, aligned blocks
+// (e.g. a disk opened with O_DIRECT, or a Windows volume handle). Every read
+// starts at a multiple of BlockSize(), is a multiple of BlockSize() long, and
+// is into memory that is aligned to BlockSize().
+type BlockDevice interface {
+	// BlockSize returns the required alignment. It must be a power of two.
+	BlockSize() int
+
+	// Size returns the size of the device in bytes.
+	Size() int64
+
+	// ReadAt reads whole blocks at the given offset.
+	ReadAt(data []byte, offset int64) (n int, err error)
+}
+
+// readerAtBlockDevice adapts an io.ReaderAt that has alignment constraints.
+type readerAtBlockDevice struct {
+	ra        io.ReaderAt
+	size      int64
+	blockSize int
+}
+
+// NewReaderAtBlockDevice returns a BlockDevice for the given reader (e.g. an
+// *os.File that was opened with O_DIRECT), which is `size` bytes long and
+// must be read in blocks of `blockSize`.
+func NewReaderAtBlockDevice(ra io.ReaderAt, size int64, blockSize int) BlockDevice {
+	return &readerAtBlockDevice{
+		ra:        ra,
+		size:      size,
+		blockSize: blockSize,
+	}
+}
+
+// BlockSize returns the required alignment.
+func (rabd *readerAtBlockDevice) BlockSize() int {
+	return rabd.blockSize
+}
+
+// Size returns the size of the device in bytes.
+func (rabd *readerAtBlockDevice) Size() int64 {
+	return rabd.size
+}
+
+// ReadAt reads whole blocks at the given offset.
+func (rabd *readerAtBlockDevice) ReadAt(data []byte, offset int64) (n int, err error) {
+	return rabd.ra.ReadAt(data, offset)
+}
+
+// alignedBuffer returns a buffer of the given size whose memory starts at a
+// multiple of `alignment`, which must be a power of two.
+func alignedBuffer(size, alignment int) []byte {
+	buffer := make([]byte, size+alignment)
+
+	shift := int(uintptr(unsafe.Pointer(&buffer[0])) & uintptr(alignment-1))
+	if shift == 0 {
+		return buffer[:size]
+	}
+
+	return buffer[alignment-shift : alignment-shift+size]
+}
+
+// AlignedReader reads a BlockDevice as an ordinary io.ReadSeeker and
+// io.ReaderAt, so that it can be passed to NewExfatReader(). Reads of any size
+// and at any offset are turned into aligned reads of whole blocks, and the
+// most recent read is kept so that neighboring reads don't go back to the
+// device. It is safe for concurrent use by ReadAt(), though device reads are
+// serialized.
+type AlignedReader struct {
+	bd        BlockDevice
+	blockSize int
+
+	locker sync.Mutex
+
+	// buffer is aligned and holds `bufferLength` bytes from `bufferOffset`.
+	buffer       []byte
+	bufferOffset int64
+	bufferLength int
+
+	// position is the offset for Read() and Seek().
+	position int64
+}
+
+// NewAlignedReader returns a new AlignedReader for the given device.
+func NewAlignedReader(bd BlockDevice) (ar *AlignedReader, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	blockSize := bd.BlockSize()
+	if blockSize <= 0 || blockSize&(blockSize-1) != 0 {
+		log.Panicf("block-size must be a power of two: (%d)", blockSize)
+	}
+
+	bufferSize := defaultAlignedReaderBufferSize
+	if bufferSize < blockSize {
+		bufferSize = blockSize
+	}
+
+	ar = &AlignedReader{
+		bd:        bd,
+		blockSize: blockSize,
+		buffer:    alignedBuffer(bufferSize, blockSize),
+	}
+
+	return ar, nil
+}
+
+// fill reads the aligned chunk that contains the given offset into the buffer.
+// The lock must be held.
+func (ar *AlignedReader) fill(offset int64) (err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	blockSize := int64(ar.blockSize)
+
+	chunkOffset := offset - offset%blockSize
+	chunkLength := int64(len(ar.buffer))
+
+	// Don't read past the last (possibly partial) block.
+	size := ar.bd.Size()
+	lastBlockEnd := (size + blockSize - 1) / blockSize * blockSize
+
+	if chunkOffset+chunkLength > lastBlockEnd {
+		chunkLength = lastBlockEnd - chunkOffset
+	}
+
+	n, err := ar.bd.ReadAt(ar.buffer[:chunkLength], chunkOffset)
+	if err == io.EOF && n > 0 {
+		err = nil
+	}
+
+	log.PanicIf(err)
+
+	// Anything past the end of the device is not data.
+	if chunkOffset+int64(n) > size {
+		n = int(size - chunkOffset)
+	}
+
+	ar.bufferOffset = chunkOffset
+	ar.bufferLength = n
+
+	return nil
+}
+
+// ReadAt reads from the given offset, which needn't be aligned.
+func (ar *AlignedReader) ReadAt(data []byte, offset int64) (n int, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	ar.locker.Lock()
+	defer ar.locker.Unlock()
+
+	size := ar.bd.Size()
+
+	for n < len(data) {
+		current := offset + int64(n)
+		if current >= size {
+			return n, io.EOF
+		}
+
+		if current < ar.bufferOffset || current >= ar.bufferOffset+int64(ar.bufferLength) {
+			err := ar.fill(current)
+			log.PanicIf(err)
+
+			if ar.bufferLength == 0 {
+				return n, io.EOF
+			}
+		}
+
+		copied := copy(data[n:], ar.buffer[current-ar.bufferOffset:ar.bufferLength])
+		n += copied
+	}
+
+	return n, nil
+}
+
+// Read reads from the current position.
+func (ar *AlignedReader) Read(data []byte) (n int, err error) {
+	n, err = ar.ReadAt(data, ar.position)
+	ar.position += int64(n)
+
+	if err == io.EOF && n > 0 {
+		err = nil
+	}
+
+	return n, err
+}
+
+// Seek moves the current position.
+func (ar *AlignedReader) Seek(offset int64, whence int) (position int64, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	switch whence {
+	case os.SEEK_SET:
+		position = offset
+	case os.SEEK_CUR:
+		position = ar.position + offset
+	case os.SEEK_END:
+		position = ar.bd.Size() + offset
+	default:
+		log.Panicf("whence not valid: (%d)", whence)
+	}
+
+	if position < 0 {
+		log.Panicf("position can not be negative: (%d)", position)
+	}
+
+	ar.position = position
+
+	return position, nil
+}
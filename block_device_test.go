@@ -0,0 +1,121 @@
+package exfat
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"fmt"
+	"io"
+	"testing"
+	"unsafe"
+
+	"github.com/dsoprea/go-logging"
+)
+
+// strictBlockDevice fails any read that isn't aligned the way that O_DIRECT
+// requires.
+type strictBlockDevice struct {
+	BlockDevice
+
+	readCount int
+}
+
+func (sbd *strictBlockDevice) ReadAt(data []byte, offset int64) (n int, err error) {
+	blockSize := sbd.BlockSize()
+
+	if offset%int64(blockSize) != 0 {
+		return 0, fmt.Errorf("offset not aligned: (%d)", offset)
+	} else if len(data)%blockSize != 0 {
+		return 0, fmt.Errorf("length not aligned: (%d)", len(data))
+	} else if uintptr(unsafe.Pointer(&data[0]))%uintptr(blockSize) != 0 {
+		return 0, fmt.Errorf("buffer not aligned")
+	}
+
+	sbd.readCount++
+
+	return sbd.BlockDevice.ReadAt(data, offset)
+}
+
+func getTestAlignedReader(blockSize int) (ar *AlignedReader, sbd *strictBlockDevice, image []byte) {
+	image, _ = getTestImage()
+
+	sbd = &strictBlockDevice{
+		BlockDevice: NewReaderAtBlockDevice(bytes.NewReader(image), int64(len(image)), blockSize),
+	}
+
+	ar, err := NewAlignedReader(sbd)
+	log.PanicIf(err)
+
+	return ar, sbd, image
+}
+
+func TestAlignedReader_ReadAt(t *testing.T) {
+	ar, sbd, image := getTestAlignedReader(4096)
+
+	for _, offset := range []int64{0, 1, 4095, 4097, 100000} {
+		data := make([]byte, 10000)
+
+		n, err := ar.ReadAt(data, offset)
+		log.PanicIf(err)
+
+		if n != len(data) {
+			t.Fatalf("Read not complete: (%d)", n)
+		} else if bytes.Equal(data, image[offset:offset+int64(len(data))]) != true {
+			t.Fatalf("Data not correct at offset (%d).", offset)
+		}
+	}
+
+	// Reads near the last one don't go back to the device.
+
+	readCount := sbd.readCount
+
+	_, err := ar.ReadAt(make([]byte, 10), 100100)
+	log.PanicIf(err)
+
+	if sbd.readCount != readCount {
+		t.Fatalf("Expected buffered read.")
+	}
+
+	// Reading past the end is short.
+
+	data := make([]byte, 100)
+
+	n, err := ar.ReadAt(data, int64(len(image))-10)
+	if err != io.EOF || n != 10 {
+		t.Fatalf("Expected short read at end: (%d) %v", n, err)
+	}
+}
+
+func TestAlignedReader__ExfatReader(t *testing.T) {
+	ar, _, _ := getTestAlignedReader(512)
+
+	er := NewExfatReader(ar)
+
+	err := er.Parse()
+	log.PanicIf(err)
+
+	tree := NewTree(er)
+
+	files, _, err := tree.List()
+	log.PanicIf(err)
+
+	if len(files) != 13 {
+		t.Fatalf("File count not correct: (%d)", len(files))
+	}
+
+	data, err := er.ReadFile("2-delahaye-type-165-cabriolet-dsc_8025.jpg")
+	log.PanicIf(err)
+
+	digest := fmt.Sprintf("%x", sha1.Sum(data))
+	if digest != "a2219fa800ae2325003d8d4f5122b37f12f1e18e" {
+		t.Fatalf("Hash not correct: [%s]", digest)
+	}
+}
+
+func TestNewAlignedReader__InvalidBlockSize(t *testing.T) {
+	bd := NewReaderAtBlockDevice(bytes.NewReader(nil), 0, 1000)
+
+	_, err := NewAlignedReader(bd)
+	if err == nil {
+		t.Fatalf("Expected error for block-size that isn't a power of two.")
+	}
+}
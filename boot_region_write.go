@@ -0,0 +1,125 @@
+// This package supports rewriting the boot regions.
+
+package exfat
+
+import (
+	"github.com/dsoprea/go-logging"
+	"github.com/go-restruct/restruct"
+)
+
+// MarshalBinary returns the raw boot-sector (the first 512 bytes of the
+// first sector).
+func (bsh BootSectorHeader) MarshalBinary() (raw []byte, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	raw, err = restruct.Pack(defaultEncoding, &bsh)
+	log.PanicIf(err)
+
+	if len(raw) != bootSectorHeaderSize {
+		log.Panicf("packed boot-sector is the wrong size: (%d)", len(raw))
+	}
+
+	return raw, nil
+}
+
+// checkGeometryUnchanged makes sure that none of the fields that describe
+// where things are have changed. Those can't be changed without moving the
+// things that they describe.
+func checkGeometryUnchanged(current, updated BootSectorHeader) (err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	if updated.VolumeLength != current.VolumeLength {
+		log.Panicf("volume-length can not be changed: (%d) -> (%d)", current.VolumeLength, updated.VolumeLength)
+	} else if updated.FatOffset != current.FatOffset || updated.FatLength != current.FatLength || updated.NumberOfFats != current.NumberOfFats {
+		log.Panicf("FAT region can not be changed")
+	} else if updated.ClusterHeapOffset != current.ClusterHeapOffset || updated.ClusterCount != current.ClusterCount {
+		log.Panicf("cluster heap can not be changed")
+	} else if updated.FirstClusterOfRootDirectory != current.FirstClusterOfRootDirectory {
+		log.Panicf("root directory can not be moved: (%d) -> (%d)", current.FirstClusterOfRootDirectory, updated.FirstClusterOfRootDirectory)
+	} else if updated.BytesPerSectorShift != current.BytesPerSectorShift || updated.SectorsPerClusterShift != current.SectorsPerClusterShift {
+		log.Panicf("sector and cluster sizes can not be changed")
+	}
+
+	return nil
+}
+
+// fillBootChecksum fills the last sector of the given boot region with the
+// checksum of the others (Section 3.4).
+func fillBootChecksum(region []byte, sectorSize int) {
+	checksum := bootChecksum(region[:11*sectorSize])
+
+	checksumSector := region[11*sectorSize : 12*sectorSize]
+	for i := 0; i < sectorSize; i += 4 {
+		defaultEncoding.PutUint32(checksumSector[i:i+4], checksum)
+	}
+}
+
+// WriteBootRegion writes the given boot-sector to both the main and backup
+// boot regions and recalculates their checksums (Section 3.4). The rest of the
+// main boot region (extended boot-sectors, OEM parameters, and the reserved
+// sector) is copied to the backup, so both regions are identical afterward.
+// This is meant for fixing the serial number, PercentInUse, the volume flags,
+// and the like; the fields that describe the layout of the volume must not be
+// changed. The reader must have been created with an image that is also
+// writable and must be parsed. This must not be called concurrently with
+// anything else on the reader.
+func (er *ExfatReader) WriteBootRegion(bsh BootSectorHeader) (err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	if er.bootRegion.isEmpty() == true {
+		log.Panicf("boot-sectors not loaded yet")
+	}
+
+	err = validateBootSectorHeader(bsh)
+	log.PanicIf(err)
+
+	err = checkGeometryUnchanged(er.bootRegion.bsh, bsh)
+	log.PanicIf(err)
+
+	raw, err := bsh.MarshalBinary()
+	log.PanicIf(err)
+
+	sectorSize := int(er.SectorSize())
+	regionSize := bootRegionSectorCount * sectorSize
+
+	region := make([]byte, regionSize)
+
+	// This is written back, so it must never be zero-filled (see
+	// ReadRetryPolicy).
+	err = er.readAtWithRetries(region, 0)
+	log.PanicIf(err)
+
+	// Anything in the boot-sector past the first 512 bytes is kept.
+	copy(region, raw)
+
+	fillBootChecksum(region, sectorSize)
+
+	// Write the main region last so that, if the write is interrupted, the
+	// old main region is still intact.
+
+	err = er.writeAt(region, int64(regionSize))
+	log.PanicIf(err)
+
+	err = er.writeAt(region, 0)
+	log.PanicIf(err)
+
+	// Both regions now have the same content as the selected one.
+
+	er.bootRegion.bsh = bsh
+	er.mainBootRegion = er.bootRegion
+	er.backupBootRegion = er.bootRegion
+
+	return nil
+}
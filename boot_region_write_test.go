@@ -0,0 +1,161 @@
+package exfat
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/dsoprea/go-logging"
+)
+
+func TestBootSectorHeader_MarshalBinary(t *testing.T) {
+	image, er := getTestImage()
+
+	raw, err := er.ActiveBootSectorHeader().MarshalBinary()
+	log.PanicIf(err)
+
+	if bytes.Equal(raw, image[:bootSectorHeaderSize]) != true {
+		t.Fatalf("Packed boot-sector does not match the image.")
+	}
+}
+
+func TestExfatReader_WriteBootRegion(t *testing.T) {
+	f, closer := getTestWritableImage()
+
+	defer closer()
+
+	er := NewExfatReader(f)
+
+	err := er.Parse()
+	log.PanicIf(err)
+
+	bsh := er.ActiveBootSectorHeader()
+
+	bsh.VolumeSerialNumber = 0x11223344
+	bsh.PercentInUse = 99
+
+	err = er.WriteBootRegion(bsh)
+	log.PanicIf(err)
+
+	if er.ActiveBootSectorHeader().VolumeSerialNumber != 0x11223344 {
+		t.Fatalf("Expected serial number to be updated in memory.")
+	} else if er.MainBootSectorHeader() != er.ActiveBootSectorHeader() {
+		t.Fatalf("Expected main boot-sector to be updated in memory.")
+	} else if er.BackupBootSectorHeader() != er.ActiveBootSectorHeader() {
+		t.Fatalf("Expected backup boot-sector to be updated in memory.")
+	}
+
+	// Reparse and make sure that both regions are still consistent.
+
+	updated, err := ioutil.ReadFile(f.Name())
+	log.PanicIf(err)
+
+	er = NewExfatReader(bytes.NewReader(updated))
+
+	err = er.Parse()
+	log.PanicIf(err)
+
+	bsh = er.ActiveBootSectorHeader()
+
+	if bsh.VolumeSerialNumber != 0x11223344 {
+		t.Fatalf("Serial number not written: (0x%08x)", bsh.VolumeSerialNumber)
+	} else if bsh.PercentInUse != 99 {
+		t.Fatalf("PercentInUse not written: (%d)", bsh.PercentInUse)
+	}
+
+	report := getTestComplianceReport(updated)
+
+	for _, finding := range report.Findings {
+		if finding.Section == "3.4" || finding.Section == "3.1" {
+			t.Fatalf("Boot regions not consistent: %s", finding)
+		}
+	}
+
+	// The backup boot-sector has the same serial number.
+
+	sectorSize := int(bsh.SectorSize())
+	backupOffset := bootRegionSectorCount * sectorSize
+
+	backup, err := ioutil.ReadFile(f.Name())
+	log.PanicIf(err)
+
+	if defaultEncoding.Uint32(backup[backupOffset+100:]) != 0x11223344 {
+		t.Fatalf("Backup boot-sector not updated.")
+	}
+}
+
+func TestExfatReader_WriteBootRegion__GeometryChanged(t *testing.T) {
+	f, closer := getTestWritableImage()
+
+	defer closer()
+
+	er := NewExfatReader(f)
+
+	err := er.Parse()
+	log.PanicIf(err)
+
+	bsh := er.ActiveBootSectorHeader()
+	bsh.ClusterCount++
+
+	err = er.WriteBootRegion(bsh)
+	if err == nil {
+		t.Fatalf("Expected error for a changed cluster-count.")
+	}
+
+	original, _ := getTestImage()
+
+	_, err = f.Seek(0, os.SEEK_SET)
+	log.PanicIf(err)
+
+	current, err := ioutil.ReadAll(f)
+	log.PanicIf(err)
+
+	if bytes.Equal(original, current) != true {
+		t.Fatalf("Image should not have been changed.")
+	}
+}
+
+// testFlakyWriter is a testFlakyReader that also counts writes.
+type testFlakyWriter struct {
+	*testFlakyReader
+
+	writes int
+}
+
+func (tfw *testFlakyWriter) WriteAt(p []byte, offset int64) (n int, err error) {
+	tfw.writes++
+	return len(p), nil
+}
+
+func TestExfatReader_WriteBootRegion__ReadFails(t *testing.T) {
+	tfr, _ := getTestFlakyReader(0)
+
+	tfw := &testFlakyWriter{
+		testFlakyReader: tfr,
+	}
+
+	// Fail the OEM-parameters sector of the main region.
+	tfr.badOffset = 9 * 512
+
+	er := NewExfatReader(tfw)
+
+	rrp := ReadRetryPolicy{
+		ZeroFill: true,
+	}
+
+	er.SetReadRetryPolicy(rrp)
+
+	err := er.Parse()
+	log.PanicIf(err)
+
+	tfr.failuresLeft = -1
+
+	err = er.WriteBootRegion(er.ActiveBootSectorHeader())
+	if err == nil || errors.Is(err, errTestReadFailed) != true {
+		t.Fatalf("Expected the read error: [%v]", err)
+	} else if tfw.writes != 0 {
+		t.Fatalf("Nothing should have been written: (%d)", tfw.writes)
+	}
+}
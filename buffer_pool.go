@@ -0,0 +1,42 @@
+// This package manages the pools of buffers that are reused for reads.
+
+package exfat
+
+import (
+	"sync"
+)
+
+// clusterBuffers returns the pool of cluster-sized buffers, creating it if
+// necessary. The pools store pointers so that returning a buffer doesn't
+// allocate.
+func (er *ExfatReader) clusterBuffers() *sync.Pool {
+	er.clusterBufferPoolOnce.Do(func() {
+		clusterSize := er.bootRegion.bsh.ClusterSize()
+
+		er.clusterBufferPool = &sync.Pool{
+			New: func() interface{} {
+				data := make([]byte, clusterSize)
+				return &data
+			},
+		}
+	})
+
+	return er.clusterBufferPool
+}
+
+// sectorBuffers returns the pool of sector-sized buffers, creating it if
+// necessary.
+func (er *ExfatReader) sectorBuffers() *sync.Pool {
+	er.sectorBufferPoolOnce.Do(func() {
+		sectorSize := er.SectorSize()
+
+		er.sectorBufferPool = &sync.Pool{
+			New: func() interface{} {
+				data := make([]byte, sectorSize)
+				return &data
+			},
+		}
+	})
+
+	return er.sectorBufferPool
+}
@@ -0,0 +1,296 @@
+// This package supports reading a cluster chain as a stream.
+
+package exfat
+
+import (
+	"errors"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/dsoprea/go-logging"
+)
+
+const (
+	// chainCheckpointInterval is how many clusters apart the clusters that
+	// ChainReader remembers are.
+	chainCheckpointInterval = 64
+)
+
+var (
+	// ErrNegativeOffset is returned when a seek or read would move before the
+	// start of the data.
+	ErrNegativeOffset = errors.New("negative offset")
+)
+
+// ChainReader reads the data of a cluster chain as an io.ReadSeeker and
+// io.ReaderAt. Seeking only follows the FAT (and not at all for contiguous
+// allocations); no data is read until the next Read(). Use
+// io.NewSectionReader() or io.LimitReader() to read a byte-range (e.g. for
+// HTTP range requests). ReadAt() is safe to call concurrently with itself
+// and with Read() and Seek(), but Read() and Seek() aren't safe to call
+// concurrently with each other since they share the position.
+type ChainReader struct {
+	er *ExfatReader
+
+	firstCluster uint32
+	length       int64
+	useFat       bool
+	clusterSize  int64
+
+	position int64
+
+	// locker guards the cached positions in the chain below, which both
+	// Read() and ReadAt() use and update.
+	locker sync.Mutex
+
+	// currentIndex is the position of currentCluster in the chain: the last
+	// cluster that was read. These are cached so that sequential reads don't
+	// have to walk the chain again.
+	currentIndex   int64
+	currentCluster uint32
+
+	// checkpoints has every chainCheckpointInterval'th cluster of the part of
+	// the chain that's been walked, so that random reads only have to walk
+	// from the closest one rather than from the start.
+	checkpoints []uint32
+}
+
+// NewChainReader returns a reader for the first `length` bytes of the chain
+// that starts at `firstCluster`. `useFat` should be false if the chain is
+// contiguous (NoFatChain).
+func (er *ExfatReader) NewChainReader(firstCluster uint32, length uint64, useFat bool) *ChainReader {
+	return &ChainReader{
+		er:             er,
+		firstCluster:   firstCluster,
+		length:         int64(length),
+		useFat:         useFat,
+		clusterSize:    int64(er.ActiveBootSectorHeader().ClusterSize()),
+		currentCluster: firstCluster,
+		checkpoints:    []uint32{firstCluster},
+	}
+}
+
+// NewReader returns a ChainReader for the file's valid data. The node must
+// not be a directory.
+func (tn *TreeNode) NewReader() (cr *ChainReader, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	if tn.tree == nil {
+		log.Panicf("node does not belong to a tree: [%s]", tn.name)
+	} else if tn.isDirectory == true {
+		log.Panicf("node is a directory: [%s]", tn.name)
+	}
+
+	useFat := tn.sede.GeneralSecondaryFlags.NoFatChain() == false
+
+	cr = tn.tree.er.NewChainReader(tn.sede.FirstCluster, tn.Size(), useFat)
+
+	return cr, nil
+}
+
+// Size returns the length of the data.
+func (cr *ChainReader) Size() int64 {
+	return cr.length
+}
+
+// clusterAt returns the cluster with the given index in the chain, walking
+// it from the closest checkpoint or the cached position, whichever is closer.
+// It must be called with the lock held.
+func (cr *ChainReader) clusterAt(index int64) (clusterNumber uint32, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	if cr.useFat == false {
+		return cr.firstCluster + uint32(index), nil
+	}
+
+	checkpoint := index / chainCheckpointInterval
+	if checkpoint >= int64(len(cr.checkpoints)) {
+		checkpoint = int64(len(cr.checkpoints)) - 1
+	}
+
+	currentIndex := checkpoint * chainCheckpointInterval
+	clusterNumber = cr.checkpoints[checkpoint]
+
+	if cr.currentIndex <= index && cr.currentIndex > currentIndex {
+		currentIndex = cr.currentIndex
+		clusterNumber = cr.currentCluster
+	}
+
+	for currentIndex < index {
+		clusterNumber, err = cr.nextCluster(clusterNumber, currentIndex)
+		log.PanicIf(err)
+
+		currentIndex++
+		cr.addCheckpoint(currentIndex, clusterNumber)
+	}
+
+	return clusterNumber, nil
+}
+
+// nextCluster returns the cluster that follows the one with the given index in
+// the chain.
+func (cr *ChainReader) nextCluster(clusterNumber uint32, index int64) (nextClusterNumber uint32, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	if cr.useFat == false {
+		return clusterNumber + 1, nil
+	}
+
+	nextClusterNumber, isLast, err := cr.er.nextClusterNumber(clusterNumber, true)
+	log.PanicIf(err)
+
+	if isLast == true {
+		log.Panic(cr.er.newFatCorruptionError(clusterNumber, log.Errorf("cluster chain ended early: INDEX=(%d)", index)))
+	}
+
+	return nextClusterNumber, nil
+}
+
+// addCheckpoint records the cluster if its index falls on a checkpoint that
+// hasn't been recorded yet. It must be called with the lock held.
+func (cr *ChainReader) addCheckpoint(index int64, clusterNumber uint32) {
+	if index%chainCheckpointInterval == 0 && index/chainCheckpointInterval == int64(len(cr.checkpoints)) {
+		cr.checkpoints = append(cr.checkpoints, clusterNumber)
+	}
+}
+
+// readAt reads as much of `p` as possible from the given offset, walking the
+// chain from the cluster that the offset falls in to the cluster where the
+// read ends. The cached position is left on the last cluster that was read.
+func (cr *ChainReader) readAt(p []byte, offset int64) (n int, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	if offset >= cr.length {
+		return 0, io.EOF
+	} else if cr.firstCluster < 2 {
+		log.Panicf("cluster can not be less than (2): (%d)", cr.firstCluster)
+	}
+
+	cr.locker.Lock()
+	defer cr.locker.Unlock()
+
+	index := offset / cr.clusterSize
+	clusterOffset := offset % cr.clusterSize
+
+	clusterNumber, err := cr.clusterAt(index)
+	log.PanicIf(err)
+
+	bsh := cr.er.ActiveBootSectorHeader()
+
+	for {
+		if clusterNumber < 2 || clusterNumber-2 >= bsh.ClusterCount {
+			ce := newCorruptionError("cluster chain", log.Errorf("cluster not in the cluster heap: (%d)", clusterNumber))
+			ce.ClusterNumber = cr.firstCluster
+
+			log.Panic(ce)
+		}
+
+		count := cr.clusterSize - clusterOffset
+		if remaining := cr.length - offset; remaining < count {
+			count = remaining
+		}
+
+		if int64(len(p)-n) < count {
+			count = int64(len(p) - n)
+		}
+
+		imageOffset := int64(bsh.ClusterHeapOffset)*int64(bsh.SectorSize()) + int64(clusterNumber-2)*cr.clusterSize + clusterOffset
+
+		err = cr.er.readAt(p[n:n+int(count)], imageOffset)
+		log.PanicIf(err)
+
+		n += int(count)
+		offset += count
+
+		if n == len(p) || offset >= cr.length {
+			break
+		}
+
+		clusterNumber, err = cr.nextCluster(clusterNumber, index)
+		log.PanicIf(err)
+
+		index++
+		clusterOffset = 0
+
+		if cr.useFat == true {
+			cr.addCheckpoint(index, clusterNumber)
+		}
+	}
+
+	cr.currentIndex = index
+	cr.currentCluster = clusterNumber
+
+	return n, nil
+}
+
+// Read reads from the current position. It returns io.EOF at the end of the
+// data.
+func (cr *ChainReader) Read(p []byte) (n int, err error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	n, err = cr.readAt(p, cr.position)
+	cr.position += int64(n)
+
+	return n, err
+}
+
+// ReadAt reads from the given offset without changing the current position.
+// It returns io.EOF if the data ends before `p` is full.
+func (cr *ChainReader) ReadAt(p []byte, offset int64) (n int, err error) {
+	if offset < 0 {
+		return 0, ErrNegativeOffset
+	} else if len(p) == 0 {
+		return 0, nil
+	}
+
+	n, err = cr.readAt(p, offset)
+	if err != nil {
+		return n, err
+	} else if n < len(p) {
+		return n, io.EOF
+	}
+
+	return n, nil
+}
+
+// Seek sets the position of the next Read(). Seeking past the end is allowed;
+// the next Read() will return io.EOF.
+func (cr *ChainReader) Seek(offset int64, whence int) (position int64, err error) {
+	switch whence {
+	case os.SEEK_SET:
+		position = offset
+	case os.SEEK_CUR:
+		position = cr.position + offset
+	case os.SEEK_END:
+		position = cr.length + offset
+	default:
+		return cr.position, log.Errorf("whence not valid: (%d)", whence)
+	}
+
+	if position < 0 {
+		return cr.position, ErrNegativeOffset
+	}
+
+	cr.position = position
+
+	return position, nil
+}
@@ -0,0 +1,256 @@
+package exfat
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/dsoprea/go-logging"
+)
+
+func TestChainReader(t *testing.T) {
+	_, node, data, closer := getTestSparseNode()
+
+	defer closer()
+
+	cr, err := node.NewReader()
+	log.PanicIf(err)
+
+	if cr.Size() != int64(len(data)) {
+		t.Fatalf("Size not correct: (%d)", cr.Size())
+	}
+
+	recovered, err := ioutil.ReadAll(cr)
+	log.PanicIf(err)
+
+	if bytes.Equal(recovered, data) != true {
+		t.Fatalf("Data not correct.")
+	}
+
+	// Seek into the middle of a cluster and read across the next boundary.
+
+	_, err = cr.Seek(100000, os.SEEK_SET)
+	log.PanicIf(err)
+
+	recovered, err = ioutil.ReadAll(io.LimitReader(cr, 5000))
+	log.PanicIf(err)
+
+	if bytes.Equal(recovered, data[100000:105000]) != true {
+		t.Fatalf("Data after seek not correct.")
+	}
+
+	position, err := cr.Seek(-10, os.SEEK_END)
+	log.PanicIf(err)
+
+	if position != int64(len(data))-10 {
+		t.Fatalf("Position not correct: (%d)", position)
+	}
+
+	recovered, err = ioutil.ReadAll(cr)
+	log.PanicIf(err)
+
+	if bytes.Equal(recovered, data[len(data)-10:]) != true {
+		t.Fatalf("Tail not correct.")
+	}
+
+	_, err = cr.Seek(-1, os.SEEK_SET)
+	if err != ErrNegativeOffset {
+		t.Fatalf("Expected error for negative offset: [%v]", err)
+	}
+}
+
+func TestChainReader_ReadAt(t *testing.T) {
+	_, node, data, closer := getTestSparseNode()
+
+	defer closer()
+
+	cr, err := node.NewReader()
+	log.PanicIf(err)
+
+	sr := io.NewSectionReader(cr, 4000, 10000)
+
+	recovered, err := ioutil.ReadAll(sr)
+	log.PanicIf(err)
+
+	if bytes.Equal(recovered, data[4000:14000]) != true {
+		t.Fatalf("Section not correct.")
+	}
+
+	b := make([]byte, 100)
+
+	n, err := cr.ReadAt(b, int64(len(data))-50)
+	if err != io.EOF || n != 50 {
+		t.Fatalf("Expected a short read at the end: (%d) [%v]", n, err)
+	} else if bytes.Equal(b[:50], data[len(data)-50:]) != true {
+		t.Fatalf("Short read not correct.")
+	}
+}
+
+func TestChainReader__Fat(t *testing.T) {
+	srcTree, srcCloser := getTestTree()
+
+	defer srcCloser()
+
+	// The writer always builds FAT chains, so the copy can be read either
+	// way.
+
+	tree, closer := getTestFilteredCopy(srcTree)
+
+	defer closer()
+
+	node, err := tree.LookupPath("2-delahaye-type-165-cabriolet-dsc_8025.jpg")
+	log.PanicIf(err)
+
+	b := new(bytes.Buffer)
+
+	err = node.WriteData(b, false)
+	log.PanicIf(err)
+
+	data := b.Bytes()
+
+	cr := tree.er.NewChainReader(node.StreamDirectoryEntry().FirstCluster, node.Size(), true)
+
+	// Backwards and forwards, so that the chain has to be walked again from
+	// the start.
+
+	for _, offset := range []int64{300000, 5000, 200000} {
+		_, err := cr.Seek(offset, os.SEEK_SET)
+		log.PanicIf(err)
+
+		recovered := make([]byte, 10000)
+
+		_, err = io.ReadFull(cr, recovered)
+		log.PanicIf(err)
+
+		if bytes.Equal(recovered, data[offset:offset+10000]) != true {
+			t.Fatalf("Data at (%d) not correct.", offset)
+		}
+	}
+}
+
+func TestChainReader_ReadAt__Fat(t *testing.T) {
+	srcTree, srcCloser := getTestTree()
+
+	defer srcCloser()
+
+	tree, closer := getTestFilteredCopy(srcTree)
+
+	defer closer()
+
+	node, err := tree.LookupPath("2-delahaye-type-165-cabriolet-dsc_8025.jpg")
+	log.PanicIf(err)
+
+	b := new(bytes.Buffer)
+
+	err = node.WriteData(b, false)
+	log.PanicIf(err)
+
+	data := b.Bytes()
+
+	cr := tree.er.NewChainReader(node.StreamDirectoryEntry().FirstCluster, node.Size(), true)
+
+	// A single read across the whole chain.
+
+	recovered := make([]byte, len(data))
+
+	_, err = cr.ReadAt(recovered, 0)
+	log.PanicIf(err)
+
+	if bytes.Equal(recovered, data) != true {
+		t.Fatalf("Data not correct.")
+	}
+
+	clusterCount := (int64(len(data)) + cr.clusterSize - 1) / cr.clusterSize
+
+	if cr.currentIndex != clusterCount-1 {
+		t.Fatalf("Cached position not updated: (%d) != (%d)", cr.currentIndex, clusterCount-1)
+	} else if int64(len(cr.checkpoints)) != (clusterCount-1)/chainCheckpointInterval+1 {
+		t.Fatalf("Checkpoints not correct: (%d)", len(cr.checkpoints))
+	}
+
+	// Every checkpoint should agree with the chain.
+
+	for i, clusterNumber := range cr.checkpoints {
+		fresh := tree.er.NewChainReader(node.StreamDirectoryEntry().FirstCluster, node.Size(), true)
+
+		expected, err := fresh.clusterAt(int64(i) * chainCheckpointInterval)
+		log.PanicIf(err)
+
+		if clusterNumber != expected {
+			t.Fatalf("Checkpoint (%d) not correct: (%d) != (%d)", i, clusterNumber, expected)
+		}
+	}
+}
+
+func TestChainReader__Concurrent(t *testing.T) {
+	srcTree, srcCloser := getTestTree()
+
+	defer srcCloser()
+
+	tree, closer := getTestFilteredCopy(srcTree)
+
+	defer closer()
+
+	node, err := tree.LookupPath("2-delahaye-type-165-cabriolet-dsc_8025.jpg")
+	log.PanicIf(err)
+
+	b := new(bytes.Buffer)
+
+	err = node.WriteData(b, false)
+	log.PanicIf(err)
+
+	data := b.Bytes()
+
+	cr := tree.er.NewChainReader(node.StreamDirectoryEntry().FirstCluster, node.Size(), true)
+
+	var wg sync.WaitGroup
+
+	errs := make(chan error, 5)
+
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+
+			recovered := make([]byte, 3000)
+
+			for j := 0; j < 50; j++ {
+				offset := int64((j*7919 + i*104729) % (len(data) - len(recovered)))
+
+				_, err := cr.ReadAt(recovered, offset)
+				if err != nil {
+					errs <- err
+					return
+				} else if bytes.Equal(recovered, data[offset:offset+int64(len(recovered))]) != true {
+					errs <- fmt.Errorf("data at (%d) not correct", offset)
+					return
+				}
+			}
+		}(i)
+	}
+
+	wg.Add(1)
+
+	go func() {
+		defer wg.Done()
+
+		recovered, err := ioutil.ReadAll(cr)
+		if err != nil {
+			errs <- err
+		} else if bytes.Equal(recovered, data) != true {
+			errs <- fmt.Errorf("sequential data not correct")
+		}
+	}()
+
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Fatalf("Concurrent read failed: [%v]", err)
+	}
+}
@@ -0,0 +1,170 @@
+// This package extracts what it can from cluster chains that are damaged.
+
+package exfat
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/dsoprea/go-logging"
+)
+
+// ChainGap is a range of data that couldn't be extracted because its cluster
+// chain is damaged and that was written as zeros.
+type ChainGap struct {
+	// Offset is the offset of the gap in the data (not in the image).
+	Offset uint64
+
+	// Length is the size of the gap.
+	Length uint64
+
+	// ClusterNumber is the last cluster that could be read, or zero if not
+	// even the first one could.
+	ClusterNumber uint32
+
+	// Err describes the damage.
+	Err error
+}
+
+// String returns a descriptive string.
+func (cg ChainGap) String() string {
+	return fmt.Sprintf("ChainGap<OFFSET=(%d) LENGTH=(%d) CLUSTER=(%d) ERROR=[%v]>", cg.Offset, cg.Length, cg.ClusterNumber, cg.Err)
+}
+
+// SalvageFromClusterChain is the same as WriteFromClusterChain() except that,
+// if the chain ends early or leads out of the cluster heap (e.g. to a free or
+// bad cluster), zeros are written for the rest of the data rather than
+// failing, and the gap is returned. Since there's no way to know where the
+// rest of a broken chain is, there is at most one gap and it always runs to
+// the end. This allows a partially-recoverable file to still be extracted.
+// Failures to read the image are still returned as errors (see
+// SetReadRetryPolicy()).
+func (er *ExfatReader) SalvageFromClusterChain(firstClusterNumber uint32, dataSize uint64, useFat bool, w io.Writer) (gaps []ChainGap, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	bsh := er.ActiveBootSectorHeader()
+
+	clusterSize := uint64(bsh.ClusterSize())
+	clusterHeapOffset := int64(bsh.ClusterHeapOffset) * int64(bsh.SectorSize())
+	lastClusterNumber := bsh.ClusterCount + 1
+
+	gaps = make([]ChainGap, 0)
+
+	bufferPointer := er.clusterBuffers().Get().(*[]byte)
+	defer er.clusterBuffers().Put(bufferPointer)
+
+	buffer := *bufferPointer
+
+	written := uint64(0)
+	clusterNumber := firstClusterNumber
+	previousClusterNumber := uint32(0)
+
+	for written < dataSize {
+		if clusterNumber < 2 || clusterNumber > lastClusterNumber {
+			gap := ChainGap{
+				Offset:        written,
+				Length:        dataSize - written,
+				ClusterNumber: previousClusterNumber,
+				Err:           log.Errorf("cluster not in the cluster heap: (%d)", clusterNumber),
+			}
+
+			gaps = append(gaps, gap)
+
+			break
+		}
+
+		count := clusterSize
+		if remaining := dataSize - written; remaining < count {
+			count = remaining
+		}
+
+		offset := clusterHeapOffset + int64(clusterNumber-2)*int64(clusterSize)
+
+		err := er.readAt(buffer[:count], offset)
+		log.PanicIf(err)
+
+		_, err = w.Write(buffer[:count])
+		log.PanicIf(err)
+
+		written += count
+
+		if written >= dataSize {
+			break
+		}
+
+		nextClusterNumber, isLast, err := er.nextClusterNumber(clusterNumber, useFat)
+		log.PanicIf(err)
+
+		if isLast == true {
+			gap := ChainGap{
+				Offset:        written,
+				Length:        dataSize - written,
+				ClusterNumber: clusterNumber,
+				Err:           log.Errorf("cluster chain ended early"),
+			}
+
+			gaps = append(gaps, gap)
+
+			break
+		}
+
+		previousClusterNumber = clusterNumber
+		clusterNumber = nextClusterNumber
+	}
+
+	if len(gaps) > 0 {
+		er.debug(EventChainZeroFill, "first_cluster", firstClusterNumber, "offset", gaps[0].Offset, "length", gaps[0].Length, "error", gaps[0].Err)
+
+		zeros := make([]byte, clusterSize)
+
+		for remaining := gaps[0].Length; remaining > 0; {
+			count := clusterSize
+			if remaining < count {
+				count = remaining
+			}
+
+			_, err := w.Write(zeros[:count])
+			log.PanicIf(err)
+
+			remaining -= count
+		}
+	}
+
+	return gaps, nil
+}
+
+// SalvageData is the same as WriteData() but writes zeros for whatever can't
+// be reached because the chain is damaged. See SalvageFromClusterChain().
+func (tn *TreeNode) SalvageData(w io.Writer, includeSlack bool) (gaps []ChainGap, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	if tn.tree == nil {
+		log.Panicf("node does not belong to a tree: [%s]", tn.name)
+	} else if tn.isDirectory == true {
+		log.Panicf("node is a directory: [%s]", tn.name)
+	}
+
+	dataSize := tn.Size()
+	if includeSlack == true && tn.AllocatedSize() > dataSize {
+		dataSize = tn.AllocatedSize()
+	}
+
+	if dataSize == 0 {
+		return make([]ChainGap, 0), nil
+	}
+
+	useFat := tn.sede.GeneralSecondaryFlags.NoFatChain() == false
+
+	gaps, err = tn.tree.er.SalvageFromClusterChain(tn.sede.FirstCluster, dataSize, useFat, w)
+	log.PanicIf(err)
+
+	return gaps, nil
+}
@@ -0,0 +1,161 @@
+package exfat
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+
+	"github.com/dsoprea/go-logging"
+)
+
+// getTestSalvageTree returns a tree for the test image after the FAT entry of
+// the JPG's third cluster (9) has been set to the given value.
+func getTestSalvageTree(nextEntry uint32) (tree *Tree, node *TreeNode) {
+	image, er := getTestImage()
+
+	bsh := er.ActiveBootSectorHeader()
+
+	fatOffset := int64(bsh.FatOffset) * int64(bsh.SectorSize())
+	defaultEncoding.PutUint32(image[fatOffset+9*4:], nextEntry)
+
+	er = NewExfatReader(bytes.NewReader(image))
+
+	err := er.Parse()
+	log.PanicIf(err)
+
+	tree = NewTree(er)
+
+	err = tree.Load()
+	log.PanicIf(err)
+
+	node, err = tree.LookupPath("2-delahaye-type-165-cabriolet-dsc_8025.jpg")
+	log.PanicIf(err)
+
+	return tree, node
+}
+
+func getTestSalvageOriginal() []byte {
+	tree, closer := getTestTree()
+
+	defer closer()
+
+	node, err := tree.LookupPath("2-delahaye-type-165-cabriolet-dsc_8025.jpg")
+	log.PanicIf(err)
+
+	b := new(bytes.Buffer)
+
+	err = node.WriteData(b, false)
+	log.PanicIf(err)
+
+	return b.Bytes()
+}
+
+func TestTreeNode_SalvageData__Undamaged(t *testing.T) {
+	original := getTestSalvageOriginal()
+
+	tree, closer := getTestTree()
+
+	defer closer()
+
+	node, err := tree.LookupPath("2-delahaye-type-165-cabriolet-dsc_8025.jpg")
+	log.PanicIf(err)
+
+	b := new(bytes.Buffer)
+
+	gaps, err := node.SalvageData(b, false)
+	log.PanicIf(err)
+
+	if len(gaps) != 0 {
+		t.Fatalf("Expected no gaps: %v", gaps)
+	} else if bytes.Equal(b.Bytes(), original) != true {
+		t.Fatalf("Data not correct.")
+	}
+}
+
+func TestTreeNode_SalvageData__ChainEndsEarly(t *testing.T) {
+	original := getTestSalvageOriginal()
+
+	tree, node := getTestSalvageTree(0xffffffff)
+
+	tl := new(testLogger)
+	tree.er.SetLogger(tl)
+
+	// Make sure that the normal extraction fails.
+
+	err := node.WriteData(ioutil.Discard, false)
+	if err == nil {
+		t.Fatalf("Expected the truncated chain to fail normally.")
+	}
+
+	b := new(bytes.Buffer)
+
+	gaps, err := node.SalvageData(b, false)
+	log.PanicIf(err)
+
+	readSize := uint64(3 * 4096)
+
+	if len(gaps) != 1 {
+		t.Fatalf("Expected one gap: %v", gaps)
+	}
+
+	gap := gaps[0]
+
+	if gap.Offset != readSize || gap.Length != uint64(len(original))-readSize || gap.ClusterNumber != 9 {
+		t.Fatalf("Gap not correct: %s", gap)
+	}
+
+	recovered := b.Bytes()
+
+	if len(recovered) != len(original) {
+		t.Fatalf("Size not correct: (%d)", len(recovered))
+	} else if bytes.Equal(recovered[:readSize], original[:readSize]) != true {
+		t.Fatalf("Recovered data not correct.")
+	} else if bytes.Equal(recovered[readSize:], make([]byte, len(original)-int(readSize))) != true {
+		t.Fatalf("Gap not zero-filled.")
+	} else if len(tl.find(EventChainZeroFill)) != 1 {
+		t.Fatalf("Expected one zero-fill event: %v", tl.events)
+	}
+}
+
+func TestTreeNode_SalvageData__OutOfBounds(t *testing.T) {
+	original := getTestSalvageOriginal()
+
+	_, node := getTestSalvageTree(0x00ffffff)
+
+	b := new(bytes.Buffer)
+
+	gaps, err := node.SalvageData(b, false)
+	log.PanicIf(err)
+
+	readSize := uint64(3 * 4096)
+
+	if len(gaps) != 1 {
+		t.Fatalf("Expected one gap: %v", gaps)
+	} else if gaps[0].Offset != readSize || gaps[0].ClusterNumber != 9 {
+		t.Fatalf("Gap not correct: %s", gaps[0])
+	} else if uint64(b.Len()) != uint64(len(original)) {
+		t.Fatalf("Size not correct: (%d)", b.Len())
+	}
+}
+
+func TestExfatReader_SalvageFromClusterChain__FirstClusterInvalid(t *testing.T) {
+	f, er := getTestFileAndParser()
+
+	defer f.Close()
+
+	err := er.Parse()
+	log.PanicIf(err)
+
+	b := new(bytes.Buffer)
+
+	gaps, err := er.SalvageFromClusterChain(0, 100, true, b)
+	log.PanicIf(err)
+
+	if len(gaps) != 1 {
+		t.Fatalf("Expected one gap: %v", gaps)
+	} else if gaps[0].Offset != 0 || gaps[0].Length != 100 || gaps[0].ClusterNumber != 0 {
+		t.Fatalf("Gap not correct: %s", gaps[0])
+	} else if bytes.Equal(b.Bytes(), make([]byte, 100)) != true {
+		t.Fatalf("Data not zero-filled.")
+	}
+}
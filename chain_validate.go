@@ -0,0 +1,95 @@
+// This package checks cluster chains before their data is read.
+
+package exfat
+
+import (
+	"errors"
+
+	"github.com/dsoprea/go-logging"
+)
+
+var (
+	// ErrClusterLoop is matched (with errors.Is) by the error returned when a
+	// cluster chain leads back to a cluster that it already passed through.
+	ErrClusterLoop = errors.New("cluster chain loops")
+
+	// ErrClusterOutOfRange is matched (with errors.Is) by the error returned
+	// when a cluster chain leads to a cluster that isn't in the cluster heap
+	// (including free and bad clusters).
+	ErrClusterOutOfRange = errors.New("cluster not in the cluster heap")
+
+	// ErrChainTooShort is matched (with errors.Is) by the error returned when
+	// a cluster chain ends before it covers the length of its data.
+	ErrChainTooShort = errors.New("cluster chain shorter than its data")
+)
+
+// ValidateChain walks the cluster chain that starts at `firstCluster`, as far
+// as is needed to cover `length` bytes, without reading any data. It returns
+// a CorruptionError that also matches ErrClusterLoop, ErrClusterOutOfRange,
+// or ErrChainTooShort if the chain can't be followed that far, so that
+// problems are found before an extraction has written anything. The chain may
+// continue past `length` (e.g. when only the valid data is being read); that
+// part isn't checked. If `useFat` is false, only the bounds of the contiguous
+// run are checked.
+func (er *ExfatReader) ValidateChain(firstCluster uint32, length uint64, useFat bool) (err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	if length == 0 {
+		return nil
+	}
+
+	bsh := er.ActiveBootSectorHeader()
+
+	clusterSize := uint64(bsh.ClusterSize())
+	lastClusterNumber := uint64(bsh.ClusterCount) + 1
+
+	clusterCount := (length + clusterSize - 1) / clusterSize
+
+	if firstCluster < 2 || uint64(firstCluster) > lastClusterNumber {
+		ce := newCorruptionError("cluster chain", ErrClusterOutOfRange)
+		ce.ClusterNumber = firstCluster
+
+		log.Panic(ce)
+	}
+
+	if useFat == false {
+		if uint64(firstCluster)+clusterCount-1 > lastClusterNumber {
+			ce := newCorruptionError("cluster chain", ErrClusterOutOfRange)
+			ce.ClusterNumber = firstCluster
+
+			log.Panic(ce)
+		}
+
+		return nil
+	}
+
+	visited := make(map[uint32]struct{})
+
+	clusterNumber := firstCluster
+	for i := uint64(1); ; i++ {
+		visited[clusterNumber] = struct{}{}
+
+		if i >= clusterCount {
+			break
+		}
+
+		nextClusterNumber, isLast, err := er.nextClusterNumber(clusterNumber, true)
+		log.PanicIf(err)
+
+		if isLast == true {
+			log.Panic(er.newFatCorruptionError(clusterNumber, ErrChainTooShort))
+		} else if nextClusterNumber < 2 || uint64(nextClusterNumber) > lastClusterNumber {
+			log.Panic(er.newFatCorruptionError(clusterNumber, ErrClusterOutOfRange))
+		} else if _, found := visited[nextClusterNumber]; found == true {
+			log.Panic(er.newFatCorruptionError(clusterNumber, ErrClusterLoop))
+		}
+
+		clusterNumber = nextClusterNumber
+	}
+
+	return nil
+}
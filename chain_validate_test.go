@@ -0,0 +1,121 @@
+package exfat
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/dsoprea/go-logging"
+)
+
+func TestExfatReader_ValidateChain(t *testing.T) {
+	tree, closer := getTestTree()
+
+	defer closer()
+
+	node, err := tree.LookupPath("2-delahaye-type-165-cabriolet-dsc_8025.jpg")
+	log.PanicIf(err)
+
+	sede := node.StreamDirectoryEntry()
+
+	err = tree.er.ValidateChain(sede.FirstCluster, sede.DataLength, true)
+	log.PanicIf(err)
+
+	// Nothing is checked for empty data.
+
+	err = tree.er.ValidateChain(0, 0, true)
+	log.PanicIf(err)
+}
+
+func TestExfatReader_ValidateChain__Damaged(t *testing.T) {
+	cases := []struct {
+		nextEntry uint32
+		expected  error
+	}{
+		{0xffffffff, ErrChainTooShort},
+		{0, ErrClusterOutOfRange},
+		{0xfffffff7, ErrClusterOutOfRange},
+		{7, ErrClusterLoop},
+	}
+
+	for _, c := range cases {
+		tree, node := getTestSalvageTree(c.nextEntry)
+
+		sede := node.StreamDirectoryEntry()
+
+		err := tree.er.ValidateChain(sede.FirstCluster, sede.DataLength, true)
+		if err == nil {
+			t.Fatalf("Expected error for entry (0x%08x).", c.nextEntry)
+		} else if errors.Is(err, c.expected) != true {
+			t.Fatalf("Error not correct for entry (0x%08x): [%v]", c.nextEntry, err)
+		} else if errors.Is(err, ErrCorrupt) != true {
+			t.Fatalf("Expected a corruption error: [%v]", err)
+		}
+
+		var ce *CorruptionError
+		if errors.As(err, &ce) != true {
+			t.Fatalf("Expected a CorruptionError: [%v]", err)
+		} else if ce.ClusterNumber != 9 {
+			t.Fatalf("Cluster not correct: (%d)", ce.ClusterNumber)
+		}
+
+		// The part of the chain before the damage is fine.
+
+		err = tree.er.ValidateChain(sede.FirstCluster, 3*4096, true)
+		log.PanicIf(err)
+	}
+}
+
+func TestExfatReader_ValidateChain__OutOfRange(t *testing.T) {
+	f, er := getTestFileAndParser()
+
+	defer f.Close()
+
+	err := er.Parse()
+	log.PanicIf(err)
+
+	bsh := er.ActiveBootSectorHeader()
+	clusterSize := uint64(bsh.ClusterSize())
+	lastClusterNumber := bsh.ClusterCount + 1
+
+	err = er.ValidateChain(1, 100, true)
+	if errors.Is(err, ErrClusterOutOfRange) != true {
+		t.Fatalf("Expected the first cluster to be out of range: [%v]", err)
+	}
+
+	err = er.ValidateChain(lastClusterNumber+1, 100, false)
+	if errors.Is(err, ErrClusterOutOfRange) != true {
+		t.Fatalf("Expected the first cluster to be out of range: [%v]", err)
+	}
+
+	// A contiguous run that ends on the last cluster is fine, but not one that
+	// goes past it.
+
+	err = er.ValidateChain(lastClusterNumber-1, 2*clusterSize, false)
+	log.PanicIf(err)
+
+	err = er.ValidateChain(lastClusterNumber-1, 2*clusterSize+1, false)
+	if errors.Is(err, ErrClusterOutOfRange) != true {
+		t.Fatalf("Expected the run to be out of range: [%v]", err)
+	}
+}
+
+func TestExfatReader_EnumerateClusters__Loop(t *testing.T) {
+	tree, _ := getTestSalvageTree(7)
+
+	er := tree.er
+
+	visitedCount := 0
+	cb := func(ec *ExfatCluster) (doContinue bool, err error) {
+		visitedCount++
+		return true, nil
+	}
+
+	err := er.EnumerateClusters(7, cb, true)
+	if errors.Is(err, ErrClusterLoop) != true {
+		t.Fatalf("Expected loop error: [%v]", err)
+	} else if errors.Is(err, ErrCorrupt) != true {
+		t.Fatalf("Expected a corruption error: [%v]", err)
+	} else if visitedCount != int(er.ActiveBootSectorHeader().ClusterCount) {
+		t.Fatalf("Visited count not correct: (%d)", visitedCount)
+	}
+}
@@ -0,0 +1,58 @@
+// This package supports visiting every cluster in the cluster heap.
+
+package exfat
+
+import (
+	"github.com/dsoprea/go-logging"
+)
+
+// ClusterScanVisitorFunc receives each cluster in the cluster heap along with
+// whether the allocation bitmap marks it as in use. The data is only valid
+// until the callback returns (the buffer is reused); copy it if it needs to be
+// kept. It must not be modified (it may be read-only memory).
+type ClusterScanVisitorFunc func(clusterNumber uint32, allocated bool, data []byte) (err error)
+
+// EnumerateAllClusters passes every cluster in the cluster heap, in order, to
+// the given callback, along with its allocation state from the active
+// allocation bitmap. This is for tools that scan the whole volume (e.g.
+// carvers and virus scanners) regardless of the directory structure. If the
+// callback returns an error, the scan stops and that error is returned.
+func (er *ExfatReader) EnumerateAllClusters(cb ClusterScanVisitorFunc) (err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	ab, err := er.ReadAllocationBitmap()
+	log.PanicIf(err)
+
+	bsh := er.ActiveBootSectorHeader()
+
+	clusterSize := int64(bsh.ClusterSize())
+	clusterHeapOffset := int64(bsh.ClusterHeapOffset) * int64(bsh.SectorSize())
+
+	buffer := make([]byte, clusterSize)
+
+	for i := uint32(0); i < bsh.ClusterCount; i++ {
+		clusterNumber := i + 2
+		offset := clusterHeapOffset + int64(i)*clusterSize
+
+		data, err := er.mappedRange(offset, int(clusterSize))
+		log.PanicIf(err)
+
+		if data == nil {
+			data = buffer
+
+			err := er.readAt(data, offset)
+			log.PanicIf(err)
+		}
+
+		err = cb(clusterNumber, ab.IsAllocated(clusterNumber), data)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
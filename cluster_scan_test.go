@@ -0,0 +1,90 @@
+package exfat
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/dsoprea/go-logging"
+)
+
+func TestExfatReader_EnumerateAllClusters(t *testing.T) {
+	tree, ab, closer := getTestAllocationBitmap()
+
+	defer closer()
+
+	er := tree.er
+
+	_, node, data, closer2 := getTestSparseNode()
+
+	defer closer2()
+
+	firstCluster := node.StreamDirectoryEntry().FirstCluster
+	clusterSize := int(er.ActiveBootSectorHeader().ClusterSize())
+
+	count := uint32(0)
+	allocatedCount := uint32(0)
+	nextClusterNumber := uint32(2)
+
+	cb := func(clusterNumber uint32, allocated bool, clusterData []byte) (err error) {
+		if clusterNumber != nextClusterNumber {
+			t.Fatalf("Clusters not visited in order: (%d) != (%d)", clusterNumber, nextClusterNumber)
+		} else if len(clusterData) != clusterSize {
+			t.Fatalf("Cluster data not the right size: (%d)", len(clusterData))
+		} else if allocated != ab.IsAllocated(clusterNumber) {
+			t.Fatalf("Allocation not correct for (%d).", clusterNumber)
+		}
+
+		// The image is contiguous.
+		if clusterNumber == firstCluster+1 {
+			if bytes.Equal(clusterData, data[clusterSize:clusterSize*2]) != true {
+				t.Fatalf("Cluster data not correct.")
+			}
+		}
+
+		if allocated == true {
+			allocatedCount++
+		}
+
+		count++
+		nextClusterNumber++
+
+		return nil
+	}
+
+	err := er.EnumerateAllClusters(cb)
+	log.PanicIf(err)
+
+	if count != ab.ClusterCount() {
+		t.Fatalf("Cluster count not correct: (%d)", count)
+	} else if allocatedCount != ab.ClusterCount()-ab.FreeClusterCount() {
+		t.Fatalf("Allocated count not correct: (%d)", allocatedCount)
+	}
+}
+
+func TestExfatReader_EnumerateAllClusters__Stop(t *testing.T) {
+	tree, closer := getTestTree()
+
+	defer closer()
+
+	errStop := errors.New("stop")
+
+	count := 0
+
+	cb := func(clusterNumber uint32, allocated bool, data []byte) (err error) {
+		count++
+
+		if count == 3 {
+			return errStop
+		}
+
+		return nil
+	}
+
+	err := tree.er.EnumerateAllClusters(cb)
+	if err != errStop {
+		t.Fatalf("Expected the callback's error: [%v]", err)
+	} else if count != 3 {
+		t.Fatalf("Scan did not stop: (%d)", count)
+	}
+}
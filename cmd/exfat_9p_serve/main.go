@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+
+	"github.com/dsoprea/go-logging"
+	"github.com/jessevdk/go-flags"
+
+	"github.com/dsoprea/go-exfat"
+)
+
+type rootParameters struct {
+	FilesystemFilepath string `short:"f" long:"filesystem-filepath" description:"File-path of exFAT filesystem" required:"true"`
+	ListenAddress      string `short:"l" long:"listen" description:"Address to listen on" default:"127.0.0.1:5640"`
+}
+
+var (
+	rootArguments = new(rootParameters)
+)
+
+func main() {
+	defer func() {
+		if state := recover(); state != nil {
+			err := log.Wrap(state.(error))
+			log.PrintError(err)
+			os.Exit(-1)
+		}
+	}()
+
+	p := flags.NewParser(rootArguments, flags.Default)
+
+	_, err := p.Parse()
+	if err != nil {
+		os.Exit(1)
+	}
+
+	backend, err := exfat.OpenImageBackend(rootArguments.FilesystemFilepath)
+	log.PanicIf(err)
+
+	er := exfat.NewExfatReaderFromBackend(backend)
+
+	defer er.Close()
+
+	err = er.Parse()
+	log.PanicIf(err)
+
+	tree := exfat.NewTree(er)
+
+	nps := exfat.NewNinePServer(tree)
+
+	listener, err := net.Listen("tcp", rootArguments.ListenAddress)
+	log.PanicIf(err)
+
+	defer listener.Close()
+
+	fmt.Printf("Serving [%s] read-only over 9P2000.L on [%s].\n", rootArguments.FilesystemFilepath, listener.Addr())
+
+	err = nps.Serve(listener)
+	log.PanicIf(err)
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/dsoprea/go-logging"
+	"github.com/jessevdk/go-flags"
+
+	"github.com/dsoprea/go-exfat"
+)
+
+type rootParameters struct {
+	FilesystemFilepath string `short:"f" long:"filesystem-filepath" description:"File-path of exFAT filesystem" required:"true"`
+	IncludeSlack       bool   `long:"include-slack" description:"Also print the allocated space past the end of the valid data, as it exists on the volume"`
+
+	Positional struct {
+		VolumePaths []string `positional-arg-name:"path" description:"File-paths to print, in order (forward or backward slashes; '*', '?', character classes, and '**' are expanded)" required:"1"`
+	} `positional-args:"yes"`
+}
+
+var (
+	rootArguments = new(rootParameters)
+)
+
+// globCharacters are the characters that make an argument a pattern rather
+// than a literal path.
+const globCharacters = "*?["
+
+func main() {
+	defer func() {
+		if state := recover(); state != nil {
+			err := log.Wrap(state.(error))
+			log.PrintError(err)
+			os.Exit(-1)
+		}
+	}()
+
+	p := flags.NewParser(rootArguments, flags.Default)
+
+	_, err := p.Parse()
+	if err != nil {
+		os.Exit(1)
+	}
+
+	backend, err := exfat.OpenImageBackend(rootArguments.FilesystemFilepath)
+	log.PanicIf(err)
+
+	er := exfat.NewExfatReaderFromBackend(backend)
+
+	defer er.Close()
+
+	err = er.Parse()
+	log.PanicIf(err)
+
+	tree := exfat.NewTree(er)
+
+	err = tree.Load()
+	log.PanicIf(err)
+
+	// Resolve everything before writing anything so that a bad argument
+	// doesn't leave partial output in the pipe.
+
+	nodes := make([]*exfat.TreeNode, 0)
+
+	for _, volumePath := range rootArguments.Positional.VolumePaths {
+		if strings.ContainsAny(volumePath, globCharacters) == false {
+			node, err := tree.LookupPath(volumePath)
+			log.PanicIf(err)
+
+			if node == nil {
+				fmt.Fprintf(os.Stderr, "File not found: [%s]\n", volumePath)
+				os.Exit(2)
+			} else if node.IsDirectory() == true {
+				fmt.Fprintf(os.Stderr, "Path is a directory: [%s]\n", volumePath)
+				os.Exit(2)
+			}
+
+			nodes = append(nodes, node)
+
+			continue
+		}
+
+		matches, matchedNodes, err := tree.Glob(volumePath)
+		log.PanicIf(err)
+
+		// Like a shell, expand each pattern in sorted order.
+		sort.Strings(matches)
+
+		matchCount := 0
+		for _, matchedPath := range matches {
+			node := matchedNodes[matchedPath]
+
+			if node.IsDirectory() == true || node.IsInUse() == false {
+				continue
+			}
+
+			nodes = append(nodes, node)
+			matchCount++
+		}
+
+		if matchCount == 0 {
+			fmt.Fprintf(os.Stderr, "No files match: [%s]\n", volumePath)
+			os.Exit(2)
+		}
+	}
+
+	for _, node := range nodes {
+		err := node.WriteData(os.Stdout, rootArguments.IncludeSlack)
+		log.PanicIf(err)
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"encoding/json"
+
+	"github.com/dsoprea/go-logging"
+	"github.com/jessevdk/go-flags"
+
+	"github.com/dsoprea/go-exfat"
+)
+
+type rootParameters struct {
+	Filepath string `short:"f" long:"filepath" description:"File-path of exFAT filesystem" required:"true"`
+	Json     bool   `short:"j" long:"json" description:"Print the report as JSON"`
+}
+
+var (
+	rootArguments = new(rootParameters)
+)
+
+func main() {
+	defer func() {
+		if state := recover(); state != nil {
+			err := log.Wrap(state.(error))
+			log.PrintError(err)
+			os.Exit(-1)
+		}
+	}()
+
+	p := flags.NewParser(rootArguments, flags.Default)
+
+	_, err := p.Parse()
+	if err != nil {
+		os.Exit(1)
+	}
+
+	backend, err := exfat.OpenImageBackend(rootArguments.Filepath)
+	log.PanicIf(err)
+
+	er := exfat.NewExfatReaderFromBackend(backend)
+
+	defer er.Close()
+
+	err = er.Parse()
+	log.PanicIf(err)
+
+	report, err := er.CheckCompliance()
+	log.PanicIf(err)
+
+	if rootArguments.Json == true {
+		encoded, err := json.MarshalIndent(report, "", "  ")
+		log.PanicIf(err)
+
+		fmt.Println(string(encoded))
+	} else {
+		report.Dump()
+	}
+
+	// Let scripts tell a volume that other implementations may refuse apart
+	// from one that is merely unusual.
+	if report.Grade() == exfat.ComplianceGradeNonCompliant {
+		os.Exit(2)
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"os"
+
+	"github.com/dsoprea/go-logging"
+	"github.com/jessevdk/go-flags"
+
+	"github.com/dsoprea/go-exfat"
+)
+
+type rootParameters struct {
+	OldFilepath string `short:"a" long:"old-filepath" description:"File-path of the older exFAT filesystem" required:"true"`
+	NewFilepath string `short:"b" long:"new-filepath" description:"File-path of the newer exFAT filesystem" required:"true"`
+	Hash        bool   `long:"hash" description:"Also compare the data of files"`
+	HashName    string `long:"hash-algorithm" description:"Hash algorithm to compare with (crc32, md5, sha1, sha256, sha512)" default:"sha256"`
+}
+
+var (
+	rootArguments = new(rootParameters)
+)
+
+// openTree opens and parses the image at the given path.
+func openTree(filepath string) (tree *exfat.Tree, er *exfat.ExfatReader) {
+	backend, err := exfat.OpenImageBackend(filepath)
+	log.PanicIf(err)
+
+	er = exfat.NewExfatReaderFromBackend(backend)
+
+	err = er.Parse()
+	log.PanicIf(err)
+
+	tree = exfat.NewTree(er)
+
+	return tree, er
+}
+
+func main() {
+	defer func() {
+		if state := recover(); state != nil {
+			err := log.Wrap(state.(error))
+			log.PrintError(err)
+			os.Exit(-1)
+		}
+	}()
+
+	p := flags.NewParser(rootArguments, flags.Default)
+
+	_, err := p.Parse()
+	if err != nil {
+		os.Exit(1)
+	}
+
+	oldTree, oldEr := openTree(rootArguments.OldFilepath)
+
+	defer oldEr.Close()
+
+	newTree, newEr := openTree(rootArguments.NewFilepath)
+
+	defer newEr.Close()
+
+	hashName := ""
+	if rootArguments.Hash == true {
+		hashName = rootArguments.HashName
+	}
+
+	td, err := exfat.DiffTrees(oldTree, newTree, hashName)
+	log.PanicIf(err)
+
+	td.Dump()
+
+	if td.IsIdentical() == false {
+		os.Exit(3)
+	}
+}
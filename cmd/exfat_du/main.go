@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/dsoprea/go-logging"
+	"github.com/dustin/go-humanize"
+	"github.com/jessevdk/go-flags"
+
+	"github.com/dsoprea/go-exfat"
+)
+
+type rootParameters struct {
+	Filepath      string `short:"f" long:"filepath" description:"File-path of exFAT filesystem" required:"true"`
+	Summarize     bool   `short:"s" long:"summarize" description:"Only print the total for the given path"`
+	MaxDepth      int    `short:"d" long:"max-depth" description:"Only print directories this far below the given path (-1 for no limit)" default:"-1"`
+	All           bool   `short:"a" long:"all" description:"Also print files"`
+	Allocated     bool   `long:"allocated" description:"Print the allocated size (DataLength) rather than the size of the valid data"`
+	Counts        bool   `short:"c" long:"counts" description:"Also print the number of files and directories under each directory"`
+	HumanReadable bool   `short:"H" long:"human-readable" description:"Print sizes in KiB, MiB, etc.."`
+
+	Positional struct {
+		VolumePath string `positional-arg-name:"path" description:"Directory to report on (forward or backward slashes; defaults to the root)"`
+	} `positional-args:"yes"`
+}
+
+var (
+	rootArguments = new(rootParameters)
+)
+
+func formatSize(size uint64) string {
+	if rootArguments.HumanReadable == true {
+		return humanize.IBytes(size)
+	}
+
+	return fmt.Sprintf("%d", size)
+}
+
+// printNode prints one line for the node.
+func printNode(node *exfat.TreeNode) {
+	tt, err := node.Totals()
+	log.PanicIf(err)
+
+	size := tt.Size
+	if rootArguments.Allocated == true {
+		size = tt.AllocatedSize
+	}
+
+	nodePath := node.Path()
+	if nodePath == "" {
+		nodePath = exfat.VolumePathSeparator
+	}
+
+	if rootArguments.Counts == true && node.IsDirectory() == true {
+		fmt.Printf("%s\t%d\t%d\t%s\n", formatSize(size), tt.FileCount, tt.DirectoryCount, nodePath)
+	} else {
+		fmt.Printf("%s\t%s\n", formatSize(size), nodePath)
+	}
+}
+
+// report prints everything under the node before the node itself, as `du`
+// does.
+func report(node *exfat.TreeNode, depth int) {
+	isPrinted := rootArguments.MaxDepth < 0 || depth <= rootArguments.MaxDepth
+
+	if node.IsDirectory() == true {
+		for _, childFolderName := range node.ChildFolders() {
+			childNode := node.GetChild(childFolderName)
+			if childNode.IsInUse() == false {
+				continue
+			}
+
+			report(childNode, depth+1)
+		}
+
+		if rootArguments.All == true && isPrinted == true {
+			for _, childFilename := range node.ChildFiles() {
+				childNode := node.GetChild(childFilename)
+				if childNode.IsInUse() == false {
+					continue
+				}
+
+				if rootArguments.MaxDepth < 0 || depth+1 <= rootArguments.MaxDepth {
+					printNode(childNode)
+				}
+			}
+		}
+	}
+
+	if isPrinted == true {
+		printNode(node)
+	}
+}
+
+func main() {
+	defer func() {
+		if state := recover(); state != nil {
+			err := log.Wrap(state.(error))
+			log.PrintError(err)
+			os.Exit(-1)
+		}
+	}()
+
+	p := flags.NewParser(rootArguments, flags.Default)
+
+	_, err := p.Parse()
+	if err != nil {
+		os.Exit(1)
+	}
+
+	backend, err := exfat.OpenImageBackend(rootArguments.Filepath)
+	log.PanicIf(err)
+
+	er := exfat.NewExfatReaderFromBackend(backend)
+
+	defer er.Close()
+
+	err = er.Parse()
+	log.PanicIf(err)
+
+	tree := exfat.NewTree(er)
+
+	// Everything is going to be visited, anyway.
+	err = tree.LoadAll()
+	log.PanicIf(err)
+
+	node, err := tree.LookupPath(rootArguments.Positional.VolumePath)
+	log.PanicIf(err)
+
+	if node == nil {
+		fmt.Fprintf(os.Stderr, "Path not found: [%s]\n", rootArguments.Positional.VolumePath)
+		os.Exit(2)
+	}
+
+	if rootArguments.Summarize == true {
+		printNode(node)
+		return
+	}
+
+	report(node, 0)
+}
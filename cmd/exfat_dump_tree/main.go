@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"encoding/json"
+
+	"github.com/dsoprea/go-logging"
+	"github.com/jessevdk/go-flags"
+
+	"github.com/dsoprea/go-exfat"
+)
+
+type rootParameters struct {
+	Filepath string `short:"f" long:"filepath" description:"File-path of exFAT filesystem" required:"true"`
+	Format   string `long:"format" description:"Output format ('ascii' is in the style of tree(1))" choice:"text" choice:"ascii" choice:"json" default:"text"`
+}
+
+var (
+	rootArguments = new(rootParameters)
+)
+
+func main() {
+	defer func() {
+		if state := recover(); state != nil {
+			err := log.Wrap(state.(error))
+			log.PrintError(err)
+			os.Exit(-1)
+		}
+	}()
+
+	p := flags.NewParser(rootArguments, flags.Default)
+
+	_, err := p.Parse()
+	if err != nil {
+		os.Exit(1)
+	}
+
+	backend, err := exfat.OpenImageBackend(rootArguments.Filepath)
+	log.PanicIf(err)
+
+	er := exfat.NewExfatReaderFromBackend(backend)
+
+	defer er.Close()
+
+	// Most directories don't need the FAT, so only parse it if one does.
+	er.SetDeferFatParsing(true)
+
+	err = er.Parse()
+	log.PanicIf(err)
+
+	tree := exfat.NewTree(er)
+
+	root, err := exfat.BuildTreeListing(tree)
+	log.PanicIf(err)
+
+	switch rootArguments.Format {
+	case "json":
+		encoded, err := json.MarshalIndent(root, "", "  ")
+		log.PanicIf(err)
+
+		fmt.Println(string(encoded))
+	case "ascii":
+		err := root.WriteAscii(os.Stdout)
+		log.PanicIf(err)
+	default:
+		err := root.WriteText(os.Stdout)
+		log.PanicIf(err)
+	}
+}
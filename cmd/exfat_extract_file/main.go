@@ -2,7 +2,13 @@ package main
 
 import (
 	"fmt"
+	"hash"
+	"io"
 	"os"
+	"strings"
+	"time"
+
+	"encoding/hex"
 
 	"github.com/dsoprea/go-logging"
 	"github.com/jessevdk/go-flags"
@@ -12,9 +18,21 @@ import (
 
 type rootParameters struct {
 	FilesystemFilepath string `short:"f" long:"filesystem-filepath" description:"File-path of exFAT filesystem" required:"true"`
-	ExtractFilepath    string `short:"e" long:"extract-filepath" description:"File-path to extract (use forward slashes)" required:"true"`
+	ExtractFilepath    string `short:"e" long:"extract-filepath" description:"File-path to extract (forward or backward slashes; required unless --first-cluster is given)"`
+	FirstCluster       uint32 `long:"first-cluster" description:"Extract the data starting at this cluster rather than a file from the tree, for when the directory is damaged but the location of the data is known (requires --length)"`
+	Length             uint64 `long:"length" description:"Number of bytes to extract with --first-cluster"`
+	NoFat              bool   `long:"no-fat" description:"With --first-cluster, the data is contiguous rather than following the FAT"`
 	OutputFilepath     string `short:"o" long:"output-filepath" description:"File-path to write to ('-' for STDOUT)" required:"true"`
 	PrintDataInfo      bool   `short:"d" long:"detail" description:"Whether to print additional cluster and sector info (only if not extracting to STDOUT)"`
+	Verify             bool   `long:"verify" description:"Re-read the extracted file and compare it against the image (only if not extracting to STDOUT)"`
+	HashName           string `long:"hash" description:"Print the digest of the extracted data using the given algorithm (crc32, md5, sha1, sha256, sha512; only if not extracting to STDOUT)"`
+	ReadAhead          int    `long:"read-ahead" description:"Number of clusters to read ahead in the background (for high-latency storage)" default:"0"`
+	IncludeSlack       bool   `long:"include-slack" description:"Also extract the allocated space past the end of the valid data, as it exists on the volume"`
+	Sparse             bool   `long:"sparse" description:"Extract the whole allocation but leave holes for the space past the end of the valid data and for unallocated clusters rather than writing zeros (only if not extracting to STDOUT)"`
+	ReadRetries        int    `long:"read-retries" description:"Number of times to retry a failed read, waiting longer each time (for failing media)" default:"0"`
+	ZeroFillUnreadable bool   `long:"zero-fill-unreadable" description:"Write zeros for sectors that still can't be read after the retries, rather than failing, and print the ranges that were affected to STDERR"`
+	ZeroFillDamaged    bool   `long:"zero-fill-damaged-chain" description:"If the cluster chain ends early or leads out of the cluster heap, write zeros for the rest of the data rather than failing, and print the gap to STDERR (can not be combined with --sparse, --verify, or --detail)"`
+	Preserve           bool   `long:"preserve" description:"Set the modified- and accessed-times of the extracted file to those on the volume, and make it read-only if it is read-only on the volume (only if not extracting to STDOUT)"`
 }
 
 var (
@@ -37,34 +55,110 @@ func main() {
 		os.Exit(1)
 	}
 
-	f, err := os.Open(rootArguments.FilesystemFilepath)
+	if rootArguments.ExtractFilepath == "" && rootArguments.FirstCluster == 0 {
+		fmt.Printf("Either --extract-filepath or --first-cluster is required.\n")
+		os.Exit(1)
+	} else if rootArguments.ExtractFilepath != "" && rootArguments.FirstCluster != 0 {
+		fmt.Printf("--extract-filepath and --first-cluster can not be combined.\n")
+		os.Exit(1)
+	} else if rootArguments.FirstCluster != 0 {
+		if rootArguments.Length == 0 {
+			fmt.Printf("--first-cluster requires --length.\n")
+			os.Exit(1)
+		} else if rootArguments.Sparse == true || rootArguments.IncludeSlack == true || rootArguments.Preserve == true {
+			fmt.Printf("--first-cluster can not be combined with --sparse, --include-slack, or --preserve.\n")
+			os.Exit(1)
+		}
+	} else if rootArguments.Length != 0 || rootArguments.NoFat == true {
+		fmt.Printf("--length and --no-fat only apply to --first-cluster.\n")
+		os.Exit(1)
+	}
+
+	backend, err := exfat.OpenImageBackend(rootArguments.FilesystemFilepath)
 	log.PanicIf(err)
 
-	defer f.Close()
+	er := exfat.NewExfatReaderFromBackend(backend)
+
+	defer er.Close()
+
+	// This also covers the FAT and the directories.
+	rrp := exfat.ReadRetryPolicy{
+		Retries:  rootArguments.ReadRetries,
+		Backoff:  100 * time.Millisecond,
+		ZeroFill: rootArguments.ZeroFillUnreadable,
+	}
 
-	er := exfat.NewExfatReader(f)
+	er.SetReadRetryPolicy(rrp)
 
 	err = er.Parse()
 	log.PanicIf(err)
 
-	tree := exfat.NewTree(er)
+	er.SetReadAheadClusterCount(rootArguments.ReadAhead)
 
-	err = tree.Load()
-	log.PanicIf(err)
+	// This stays nil when extracting by first-cluster, which bypasses the tree.
+	var node *exfat.TreeNode
 
-	// We use the List() call because it provides a simple lookup for the
-	// complete path strings, which a) simplifies the process for us, and
-	// b) eliminates any unnecessary interpretation/construction of the path-
-	// names on our end so that we can avoid issues with problematic preexisting
-	// slashes in the filepath that prevents us from finding the file-path that
-	// the user provides.
-	_, nodes, err := tree.List()
-	log.PanicIf(err)
+	if rootArguments.ExtractFilepath != "" {
+		tree := exfat.NewTree(er)
+
+		err = tree.Load()
+		log.PanicIf(err)
+
+		// Both forward- and backward-slashes are accepted.
+		node, err = tree.LookupPath(rootArguments.ExtractFilepath)
+		log.PanicIf(err)
+
+		if node == nil {
+			fmt.Printf("File not found.\n")
+			os.Exit(2)
+		}
+	}
 
-	node, found := nodes[rootArguments.ExtractFilepath]
-	if found != true {
-		fmt.Printf("File not found.\n")
-		os.Exit(2)
+	if rootArguments.Sparse == true {
+		if rootArguments.OutputFilepath == "-" {
+			fmt.Printf("Sparse extraction can not write to STDOUT.\n")
+			os.Exit(1)
+		} else if rootArguments.IncludeSlack == true || rootArguments.HashName != "" || rootArguments.Verify == true || rootArguments.PrintDataInfo == true {
+			fmt.Printf("Sparse extraction can not be combined with --include-slack, --hash, --verify, or --detail.\n")
+			os.Exit(1)
+		}
+	}
+
+	if rootArguments.ZeroFillDamaged == true && (rootArguments.Sparse == true || rootArguments.Verify == true || rootArguments.PrintDataInfo == true) {
+		fmt.Printf("--zero-fill-damaged-chain can not be combined with --sparse, --verify, or --detail.\n")
+		os.Exit(1)
+	}
+
+	if rootArguments.Preserve == true && rootArguments.OutputFilepath == "-" {
+		fmt.Printf("Metadata can not be preserved when extracting to STDOUT.\n")
+		os.Exit(1)
+	}
+
+	var firstCluster uint32
+	var dataSize uint64
+	var useFat bool
+
+	if node == nil {
+		firstCluster = rootArguments.FirstCluster
+		dataSize = rootArguments.Length
+		useFat = rootArguments.NoFat == false
+	} else {
+		sde := node.StreamDirectoryEntry()
+
+		firstCluster = sde.FirstCluster
+		useFat = sde.GeneralSecondaryFlags.NoFatChain() == false
+
+		dataSize = node.Size()
+		if rootArguments.IncludeSlack == true && node.AllocatedSize() > dataSize {
+			dataSize = node.AllocatedSize()
+		}
+	}
+
+	// Check the chain before anything is written so that a damaged file
+	// doesn't leave a partial copy behind.
+	if rootArguments.Sparse == false && rootArguments.ZeroFillDamaged == false {
+		err := er.ValidateChain(firstCluster, dataSize, useFat)
+		log.PanicIf(err)
 	}
 
 	var g *os.File
@@ -82,17 +176,89 @@ func main() {
 		}()
 	}
 
-	sde := node.StreamDirectoryEntry()
+	if rootArguments.Sparse == true {
+		ab, err := er.ReadAllocationBitmap()
+		log.PanicIf(err)
+
+		written, skipped, err := node.WriteSparseData(g, ab)
+		log.PanicIf(err)
+
+		fmt.Printf("(%d) bytes written.\n", written)
+		fmt.Printf("(%d) bytes left as holes.\n", skipped)
+		fmt.Printf("\n")
+
+		if rootArguments.Preserve == true {
+			err := node.ApplyMetadata(rootArguments.OutputFilepath)
+			log.PanicIf(err)
+		}
+
+		return
+	}
+
+	var w io.Writer = g
+	var h hash.Hash
 
-	useFat := sde.GeneralSecondaryFlags.NoFatChain() == false
+	if rootArguments.HashName != "" {
+		newHash, err := exfat.LookupHash(rootArguments.HashName)
+		log.PanicIf(err)
 
-	clusters, sectors, err := er.WriteFromClusterChain(sde.FirstCluster, sde.ValidDataLength, useFat, g)
-	log.PanicIf(err)
+		h = newHash()
+		w = io.MultiWriter(g, h)
+	}
+
+	var clusters, sectors []uint32
+
+	if rootArguments.ZeroFillDamaged == true {
+		gaps, err := er.SalvageFromClusterChain(firstCluster, dataSize, useFat, w)
+		log.PanicIf(err)
+
+		for _, gap := range gaps {
+			fmt.Fprintf(os.Stderr, "Damaged chain (zero-filled): offset (%d) length (%d) after cluster (%d): %v\n", gap.Offset, gap.Length, gap.ClusterNumber, gap.Err)
+		}
+	} else {
+		clusters, sectors, err = er.WriteFromClusterChain(firstCluster, dataSize, useFat, w)
+		log.PanicIf(err)
+	}
+
+	for _, ur := range er.UnreadableRanges() {
+		fmt.Fprintf(os.Stderr, "Unreadable (zero-filled): offset (%d) length (%d): %v\n", ur.Offset, ur.Length, ur.Err)
+	}
 
 	if rootArguments.OutputFilepath != "-" {
-		fmt.Printf("(%d) bytes written.\n", sde.ValidDataLength)
+		fmt.Printf("(%d) bytes written.\n", dataSize)
+
+		if rootArguments.IncludeSlack == true {
+			fmt.Printf("(%d) of them are slack.\n", node.SlackBytes())
+		}
+
 		fmt.Printf("\n")
 
+		if h != nil {
+			fmt.Printf("%s: %s\n", strings.ToLower(rootArguments.HashName), hex.EncodeToString(h.Sum(nil)))
+			fmt.Printf("\n")
+		}
+
+		if rootArguments.Verify == true {
+			err := g.Sync()
+			log.PanicIf(err)
+
+			h, err := os.Open(rootArguments.OutputFilepath)
+			log.PanicIf(err)
+
+			isMatched, mismatchOffset, err := er.VerifyFromClusterChain(firstCluster, dataSize, useFat, h)
+			h.Close()
+
+			log.PanicIf(err)
+
+			if isMatched != true {
+				fmt.Printf("Verification failed: extracted data differs at offset (%d).\n", mismatchOffset)
+				os.Exit(3)
+			}
+
+			fmt.Printf("Verified.\n")
+			fmt.Printf("\n")
+		}
+
 		if rootArguments.PrintDataInfo == true {
 
 			fmt.Printf("Clusters:")
@@ -113,5 +279,12 @@ func main() {
 
 			fmt.Printf("\n")
 		}
+
+		// This is done last so that a read-only file has already been
+		// written and verified.
+		if rootArguments.Preserve == true {
+			err := node.ApplyMetadata(rootArguments.OutputFilepath)
+			log.PanicIf(err)
+		}
 	}
 }
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/dsoprea/go-logging"
+	"github.com/jessevdk/go-flags"
+
+	"github.com/dsoprea/go-exfat"
+)
+
+type rootParameters struct {
+	FilesystemFilepath string `short:"f" long:"filesystem-filepath" description:"File-path of a valid exFAT filesystem to mutate" required:"true"`
+	CorpusPath         string `short:"o" long:"corpus-path" description:"Directory to write the corpus to" required:"true"`
+	Count              int    `short:"n" long:"count" description:"Number of mutated images to write" default:"100"`
+	Seed               int64  `short:"s" long:"seed" description:"Random seed" default:"1"`
+}
+
+var (
+	rootArguments = new(rootParameters)
+)
+
+func main() {
+	defer func() {
+		if state := recover(); state != nil {
+			err := log.Wrap(state.(error))
+			log.PrintError(err)
+			os.Exit(-1)
+		}
+	}()
+
+	p := flags.NewParser(rootArguments, flags.Default)
+
+	_, err := p.Parse()
+	if err != nil {
+		os.Exit(1)
+	}
+
+	image, err := ioutil.ReadFile(rootArguments.FilesystemFilepath)
+	log.PanicIf(err)
+
+	im, err := exfat.NewImageMutator(image, rootArguments.Seed)
+	log.PanicIf(err)
+
+	err = im.WriteCorpus(rootArguments.CorpusPath, rootArguments.Count)
+	log.PanicIf(err)
+
+	fmt.Printf("(%d) images written.\n", rootArguments.Count+1)
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/dsoprea/go-logging"
+	"github.com/jessevdk/go-flags"
+
+	"github.com/dsoprea/go-exfat"
+)
+
+type rootParameters struct {
+	FilesystemFilepath string   `short:"f" long:"filesystem-filepath" description:"File-path of exFAT filesystem" required:"true"`
+	VolumePath         string   `short:"p" long:"path" description:"Directory to hash (forward or backward slashes; defaults to the whole volume)"`
+	HashNames          []string `short:"a" long:"algorithm" description:"Hash algorithm to use; may be given more than once (crc32, md5, sha1, sha256, sha512; defaults to md5, sha1, and sha256, or to sha256 with --manifest)"`
+	Manifest           bool     `long:"manifest" description:"Print one '<digest>  <path>' line per file, which can be checked with 'sha256sum -c' (or the tool for the given algorithm) from the directory that the files were extracted to. Paths are relative to --path"`
+}
+
+var (
+	rootArguments = new(rootParameters)
+)
+
+func main() {
+	defer func() {
+		if state := recover(); state != nil {
+			err := log.Wrap(state.(error))
+			log.PrintError(err)
+			os.Exit(-1)
+		}
+	}()
+
+	p := flags.NewParser(rootArguments, flags.Default)
+
+	_, err := p.Parse()
+	if err != nil {
+		os.Exit(1)
+	}
+
+	hashNames := rootArguments.HashNames
+
+	if rootArguments.Manifest == true {
+		if len(hashNames) == 0 {
+			hashNames = []string{exfat.DefaultHashName}
+		} else if len(hashNames) > 1 {
+			fmt.Printf("Only one algorithm can be used with --manifest.\n")
+			os.Exit(1)
+		}
+	} else if len(hashNames) == 0 {
+		hashNames = []string{"md5", "sha1", "sha256"}
+	}
+
+	for _, hashName := range hashNames {
+		if _, err := exfat.LookupHash(hashName); err != nil {
+			fmt.Printf("Hash algorithm not supported: [%s]\n", hashName)
+			os.Exit(1)
+		}
+	}
+
+	backend, err := exfat.OpenImageBackend(rootArguments.FilesystemFilepath)
+	log.PanicIf(err)
+
+	er := exfat.NewExfatReaderFromBackend(backend)
+
+	defer er.Close()
+
+	err = er.Parse()
+	log.PanicIf(err)
+
+	tree := exfat.NewTree(er)
+
+	cb := func(fd exfat.FileDigests) (err error) {
+		if rootArguments.Manifest == true {
+			fmt.Printf("%s\n", fd.ManifestLine(hashNames[0]))
+			return nil
+		}
+
+		fmt.Printf("%s\n", exfat.JoinVolumePath(fd.PathParts))
+
+		for _, hashName := range hashNames {
+			fmt.Printf("  %s: %s\n", strings.ToLower(hashName), fd.Digests[strings.ToLower(hashName)])
+		}
+
+		fmt.Printf("\n")
+
+		return nil
+	}
+
+	err = exfat.HashTree(tree, rootArguments.VolumePath, hashNames, cb)
+	log.PanicIf(err)
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"encoding/json"
+
+	"github.com/dsoprea/go-logging"
+	"github.com/jessevdk/go-flags"
+
+	"github.com/dsoprea/go-exfat"
+)
+
+type rootParameters struct {
+	Filepath string `short:"f" long:"filepath" description:"File-path of exFAT filesystem" required:"true"`
+	Format   string `long:"format" description:"Output format" choice:"text" choice:"json" default:"text"`
+}
+
+var (
+	rootArguments = new(rootParameters)
+)
+
+func main() {
+	defer func() {
+		if state := recover(); state != nil {
+			err := log.Wrap(state.(error))
+			log.PrintError(err)
+			os.Exit(-1)
+		}
+	}()
+
+	p := flags.NewParser(rootArguments, flags.Default)
+
+	_, err := p.Parse()
+	if err != nil {
+		os.Exit(1)
+	}
+
+	backend, err := exfat.OpenImageBackend(rootArguments.Filepath)
+	log.PanicIf(err)
+
+	er := exfat.NewExfatReaderFromBackend(backend)
+
+	defer er.Close()
+
+	err = er.Parse()
+	log.PanicIf(err)
+
+	vr, err := er.VolumeReport()
+	log.PanicIf(err)
+
+	if rootArguments.Format == "json" {
+		encoded, err := json.MarshalIndent(vr, "", "  ")
+		log.PanicIf(err)
+
+		fmt.Println(string(encoded))
+	} else {
+		vr.Dump()
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/dsoprea/go-logging"
+	"github.com/jessevdk/go-flags"
+
+	"github.com/dsoprea/go-exfat"
+)
+
+type rootParameters struct {
+	Filepath string `short:"f" long:"filepath" description:"File-path of exFAT filesystem" required:"true"`
+	Label    string `short:"s" long:"set" description:"New volume label"`
+	Clear    bool   `long:"clear" description:"Remove the volume label"`
+}
+
+var (
+	rootArguments = new(rootParameters)
+)
+
+func main() {
+	defer func() {
+		if state := recover(); state != nil {
+			err := log.Wrap(state.(error))
+			log.PrintError(err)
+			os.Exit(-1)
+		}
+	}()
+
+	p := flags.NewParser(rootArguments, flags.Default)
+
+	_, err := p.Parse()
+	if err != nil {
+		os.Exit(1)
+	}
+
+	if rootArguments.Label != "" && rootArguments.Clear == true {
+		fmt.Printf("Only one of --set and --clear may be given.\n")
+		os.Exit(1)
+	}
+
+	if rootArguments.Label == "" && rootArguments.Clear == false {
+		backend, err := exfat.OpenImageBackend(rootArguments.Filepath)
+		log.PanicIf(err)
+
+		er := exfat.NewExfatReaderFromBackend(backend)
+
+		defer er.Close()
+
+		// Only the root directory is needed.
+		er.SetDeferFatParsing(true)
+
+		err = er.Parse()
+		log.PanicIf(err)
+
+		vi, err := er.VolumeInfo()
+		log.PanicIf(err)
+
+		fmt.Println(vi.Label)
+
+		return
+	}
+
+	backend, err := exfat.OpenWritableImageBackend(rootArguments.Filepath)
+	log.PanicIf(err)
+
+	br := exfat.NewBackendReader(backend)
+
+	defer br.Close()
+
+	err = exfat.SetVolumeLabel(br, rootArguments.Label)
+	log.PanicIf(err)
+}
@@ -3,6 +3,8 @@ package main
 import (
 	"fmt"
 	"os"
+	"sort"
+	"strings"
 
 	"path/filepath"
 
@@ -15,8 +17,17 @@ import (
 
 type rootParameters struct {
 	Filepath       string `short:"f" long:"filepath" description:"File-path of exFAT filesystem" required:"true"`
-	FilenameFilter string `short:"p" long:"pattern" description:"Filename filter"`
+	FilenameFilter string `short:"p" long:"pattern" description:"Filename filter. If it has a forward- or backward-slash, it is matched against the whole path (where '**' matches any number of directories)"`
+	MaxDepth       int    `long:"maxdepth" description:"Only list entries at most this many levels deep (1 is the root directory; zero for no limit)" default:"0"`
+	DirsOnly       bool   `long:"dirs-only" description:"Only list directories"`
+	FilesOnly      bool   `long:"files-only" description:"Only list files"`
 	ShowDetail     bool   `short:"d" long:"detail" description:"Show additional entry detail"`
+	KeepGoing      bool   `short:"k" long:"keep-going" description:"List what can be read of a damaged volume and report the directories that couldn't be"`
+	ShowAttributes bool   `short:"a" long:"attributes" description:"Show the attributes (an 'RHSDA' string for read-only, hidden, system, directory, and archive)"`
+	ShowTimes      bool   `short:"t" long:"times" description:"Show the creation and last-access times as well as the modified-time"`
+	ShowCluster    bool   `long:"first-cluster" description:"Show the first cluster"`
+	ShowAllocated  bool   `long:"allocated" description:"Show the allocated size as well as the valid size"`
+	SortBy         string `short:"s" long:"sort" description:"Order of the listing (defaults to the traversal order)" choice:"name" choice:"size" choice:"mtime"`
 }
 
 var (
@@ -39,28 +50,72 @@ func main() {
 		os.Exit(1)
 	}
 
-	f, err := os.Open(rootArguments.Filepath)
+	if rootArguments.DirsOnly == true && rootArguments.FilesOnly == true {
+		fmt.Printf("--dirs-only and --files-only can not be combined.\n")
+		os.Exit(1)
+	}
+
+	isPathPattern := strings.ContainsAny(rootArguments.FilenameFilter, `/\`)
+
+	backend, err := exfat.OpenImageBackend(rootArguments.Filepath)
 	log.PanicIf(err)
 
-	defer f.Close()
+	er := exfat.NewExfatReaderFromBackend(backend)
+
+	defer er.Close()
 
-	er := exfat.NewExfatReader(f)
+	// Most directories don't need the FAT, so only parse it if one does.
+	er.SetDeferFatParsing(true)
 
 	err = er.Parse()
 	log.PanicIf(err)
 
 	tree := exfat.NewTree(er)
 
+	if rootArguments.KeepGoing == true {
+		tree.SetErrorPolicy(exfat.TreeErrorPolicyRecord)
+	}
+
 	err = tree.Load()
 	log.PanicIf(err)
 
 	files, nodes, err := tree.List()
 	log.PanicIf(err)
 
+	switch rootArguments.SortBy {
+	case "name":
+		sort.Strings(files)
+	case "size":
+		sort.SliceStable(files, func(i, j int) bool {
+			return nodes[files[i]].Size() < nodes[files[j]].Size()
+		})
+	case "mtime":
+		sort.SliceStable(files, func(i, j int) bool {
+			return nodes[files[i]].FileDirectoryEntry().LastModifiedTimestamp().Before(nodes[files[j]].FileDirectoryEntry().LastModifiedTimestamp())
+		})
+	}
+
 	for _, currentFilepath := range files {
 		node := nodes[currentFilepath]
 
-		if rootArguments.FilenameFilter != "" {
+		if rootArguments.DirsOnly == true && node.IsDirectory() == false {
+			continue
+		} else if rootArguments.FilesOnly == true && node.IsDirectory() == true {
+			continue
+		}
+
+		if rootArguments.MaxDepth > 0 && len(exfat.SplitVolumePath(currentFilepath)) > rootArguments.MaxDepth {
+			continue
+		}
+
+		if isPathPattern == true {
+			isMatched, err := exfat.MatchVolumePath(rootArguments.FilenameFilter, currentFilepath)
+			log.PanicIf(err)
+
+			if isMatched != true {
+				continue
+			}
+		} else if rootArguments.FilenameFilter != "" {
 			// Since the filepaths are separated by Windows-standard backward-
 			// slashes, they won't necessarily split correctly on all platforms.
 			// Therefore, we'll just use the name from the node.
@@ -101,7 +156,46 @@ func main() {
 
 			fmt.Printf("\n")
 		} else {
-			fmt.Printf("%15s %30s %s\n", humanize.Comma(int64(sde.ValidDataLength)), fde.LastModifiedTimestamp(), currentFilepath)
+			fields := make([]string, 0)
+
+			if rootArguments.ShowAttributes == true {
+				fields = append(fields, fde.FileAttributes.Flags())
+			}
+
+			fields = append(fields, fmt.Sprintf("%15s", humanize.Comma(int64(sde.ValidDataLength))))
+
+			if rootArguments.ShowAllocated == true {
+				fields = append(fields, fmt.Sprintf("%15s", humanize.Comma(int64(sde.DataLength))))
+			}
+
+			fields = append(fields, fmt.Sprintf("%30s", fde.LastModifiedTimestamp()))
+
+			if rootArguments.ShowTimes == true {
+				fields = append(fields, fmt.Sprintf("%30s", fde.CreateTimestamp()))
+				fields = append(fields, fmt.Sprintf("%30s", fde.LastAccessedTimestamp()))
+			}
+
+			if rootArguments.ShowCluster == true {
+				fields = append(fields, fmt.Sprintf("%10d", sde.FirstCluster))
+			}
+
+			fields = append(fields, currentFilepath)
+
+			fmt.Println(strings.Join(fields, " "))
+		}
+	}
+
+	// The root is not included in the list.
+	rootNode, err := tree.Lookup(nil)
+	log.PanicIf(err)
+
+	if loadErr := rootNode.LoadError(); loadErr != nil {
+		fmt.Fprintf(os.Stderr, "Could not read root directory: %v\n", loadErr)
+	}
+
+	for _, currentFilepath := range files {
+		if loadErr := nodes[currentFilepath].LoadError(); loadErr != nil {
+			fmt.Fprintf(os.Stderr, "Could not read directory [%s]: %v\n", currentFilepath, loadErr)
 		}
 	}
 }
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/dsoprea/go-logging"
+	"github.com/dustin/go-humanize"
+	"github.com/jessevdk/go-flags"
+
+	"github.com/dsoprea/go-exfat"
+)
+
+const (
+	timestampLayout = "2006-01-02 15:04:05"
+)
+
+type rootParameters struct {
+	Filepath      string   `short:"f" long:"filepath" description:"File-path of exFAT filesystem" required:"true"`
+	Long          bool     `short:"l" long:"long" description:"Print the mode, size, and modified-time of each entry (the same as '-c mode -c size -c modified')"`
+	Columns       []string `short:"c" long:"column" description:"Column to print before the name; may be given more than once" choice:"mode" choice:"attributes" choice:"size" choice:"allocated" choice:"first-cluster" choice:"created" choice:"modified" choice:"accessed"`
+	HumanReadable bool     `short:"H" long:"human-readable" description:"Print sizes in KiB, MiB, etc.."`
+	All           bool     `short:"a" long:"all" description:"Also print deleted entries (marked with a '*')"`
+	Sort          string   `long:"sort" description:"Order of the entries (directories are always first)" choice:"name" choice:"size" choice:"modified" default:"name"`
+
+	Positional struct {
+		DirectoryPath string `positional-arg-name:"path" description:"Directory to list (forward or backward slashes; defaults to the root)"`
+	} `positional-args:"yes"`
+}
+
+var (
+	rootArguments = new(rootParameters)
+)
+
+func formatSize(size uint64) string {
+	if rootArguments.HumanReadable == true {
+		return humanize.IBytes(size)
+	}
+
+	return humanize.Comma(int64(size))
+}
+
+// formatColumn returns the value of the given column for the given node.
+func formatColumn(column string, node *exfat.TreeNode) string {
+	fde := node.FileDirectoryEntry()
+	sde := node.StreamDirectoryEntry()
+
+	switch column {
+	case "mode":
+		return fmt.Sprintf("%-10s", node.Stat().Mode())
+	case "attributes":
+		return fde.FileAttributes.Flags()
+	case "size":
+		return fmt.Sprintf("%15s", formatSize(node.Size()))
+	case "allocated":
+		return fmt.Sprintf("%15s", formatSize(node.AllocatedSize()))
+	case "first-cluster":
+		return fmt.Sprintf("%10d", sde.FirstCluster)
+	case "created":
+		return fde.CreateTimestamp().Format(timestampLayout)
+	case "modified":
+		return fde.LastModifiedTimestamp().Format(timestampLayout)
+	case "accessed":
+		return fde.LastAccessedTimestamp().Format(timestampLayout)
+	}
+
+	log.Panicf("column not valid: [%s]", column)
+	return ""
+}
+
+func main() {
+	defer func() {
+		if state := recover(); state != nil {
+			err := log.Wrap(state.(error))
+			log.PrintError(err)
+			os.Exit(-1)
+		}
+	}()
+
+	p := flags.NewParser(rootArguments, flags.Default)
+
+	_, err := p.Parse()
+	if err != nil {
+		os.Exit(1)
+	}
+
+	columns := rootArguments.Columns
+	if rootArguments.Long == true && len(columns) == 0 {
+		columns = []string{"mode", "size", "modified"}
+	}
+
+	backend, err := exfat.OpenImageBackend(rootArguments.Filepath)
+	log.PanicIf(err)
+
+	er := exfat.NewExfatReaderFromBackend(backend)
+
+	defer er.Close()
+
+	// Most directories don't need the FAT, so only parse it if one does.
+	er.SetDeferFatParsing(true)
+
+	err = er.Parse()
+	log.PanicIf(err)
+
+	tree := exfat.NewTree(er)
+
+	// Only the directories along the path are read.
+	node, err := tree.LookupPath(rootArguments.Positional.DirectoryPath)
+	log.PanicIf(err)
+
+	if node == nil {
+		fmt.Fprintf(os.Stderr, "Directory not found: [%s]\n", rootArguments.Positional.DirectoryPath)
+		os.Exit(2)
+	} else if node.IsDirectory() == false {
+		fmt.Fprintf(os.Stderr, "Not a directory: [%s]\n", rootArguments.Positional.DirectoryPath)
+		os.Exit(2)
+	}
+
+	order := exfat.ChildOrderName
+	if rootArguments.Sort == "size" {
+		order = exfat.ChildOrderSize
+	} else if rootArguments.Sort == "modified" {
+		order = exfat.ChildOrderModified
+	}
+
+	// Directories are listed first.
+	for _, childNode := range node.ChildrenBy(order) {
+		name := childNode.Name()
+
+		deletedMarker := ""
+		if childNode.IsInUse() == false {
+			if rootArguments.All == false {
+				continue
+			}
+
+			deletedMarker = "*"
+		}
+
+		fields := make([]string, 0, len(columns)+1)
+		for _, column := range columns {
+			fields = append(fields, formatColumn(column, childNode))
+		}
+
+		if childNode.IsDirectory() == true {
+			name += `\`
+		}
+
+		fields = append(fields, deletedMarker+name)
+
+		fmt.Println(strings.Join(fields, "  "))
+	}
+}
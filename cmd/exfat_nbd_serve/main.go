@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+
+	"github.com/dsoprea/go-logging"
+	"github.com/jessevdk/go-flags"
+
+	"github.com/dsoprea/go-exfat"
+)
+
+type rootParameters struct {
+	FilesystemFilepath string `short:"f" long:"filesystem-filepath" description:"File-path of exFAT filesystem" required:"true"`
+	VolumePath         string `short:"p" long:"path" description:"File to export (forward or backward slashes)" required:"true"`
+	ListenAddress      string `short:"l" long:"listen" description:"Address to listen on" default:"127.0.0.1:10809"`
+}
+
+var (
+	rootArguments = new(rootParameters)
+)
+
+func main() {
+	defer func() {
+		if state := recover(); state != nil {
+			err := log.Wrap(state.(error))
+			log.PrintError(err)
+			os.Exit(-1)
+		}
+	}()
+
+	p := flags.NewParser(rootArguments, flags.Default)
+
+	_, err := p.Parse()
+	if err != nil {
+		os.Exit(1)
+	}
+
+	backend, err := exfat.OpenImageBackend(rootArguments.FilesystemFilepath)
+	log.PanicIf(err)
+
+	er := exfat.NewExfatReaderFromBackend(backend)
+
+	defer er.Close()
+
+	err = er.Parse()
+	log.PanicIf(err)
+
+	tree := exfat.NewTree(er)
+
+	node, err := tree.LookupPath(rootArguments.VolumePath)
+	log.PanicIf(err)
+
+	if node == nil {
+		fmt.Fprintf(os.Stderr, "File not found: [%s]\n", rootArguments.VolumePath)
+		os.Exit(2)
+	} else if node.IsDirectory() == true {
+		fmt.Fprintf(os.Stderr, "Path is a directory: [%s]\n", rootArguments.VolumePath)
+		os.Exit(2)
+	}
+
+	ns, err := node.NewNbdServer()
+	log.PanicIf(err)
+
+	listener, err := net.Listen("tcp", rootArguments.ListenAddress)
+	log.PanicIf(err)
+
+	defer listener.Close()
+
+	fmt.Printf("Exporting [%s] (%d bytes) read-only on [%s] as [%s].\n", rootArguments.VolumePath, node.Size(), listener.Addr(), node.Name())
+
+	err = ns.Serve(listener)
+	log.PanicIf(err)
+}
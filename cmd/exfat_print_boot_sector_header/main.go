@@ -1,16 +1,25 @@
 package main
 
 import (
+	"fmt"
+	"io/ioutil"
 	"os"
 
+	"encoding/json"
+	"path/filepath"
+
 	"github.com/dsoprea/go-logging"
 	"github.com/jessevdk/go-flags"
+	"gopkg.in/yaml.v2"
 
 	"github.com/dsoprea/go-exfat"
 )
 
 type rootParameters struct {
 	Filepath string `short:"f" long:"filepath" description:"File-path of exFAT filesystem" required:"true"`
+	Format   string `long:"format" description:"Output format" choice:"text" choice:"json" choice:"yaml" default:"text"`
+
+	ExtendedBootCodePath string `long:"extended-boot-code-path" description:"Also write the boot-code of each of the eight extended boot-sectors to a file in this directory (extended-boot-code-N.bin)"`
 }
 
 var (
@@ -33,15 +42,60 @@ func main() {
 		os.Exit(1)
 	}
 
-	f, err := os.Open(rootArguments.Filepath)
+	backend, err := exfat.OpenImageBackend(rootArguments.Filepath)
 	log.PanicIf(err)
 
-	defer f.Close()
+	er := exfat.NewExfatReaderFromBackend(backend)
 
-	er := exfat.NewExfatReader(f)
+	defer er.Close()
+
+	// Only the boot-sector is needed.
+	er.SetDeferFatParsing(true)
 
 	err = er.Parse()
 	log.PanicIf(err)
 
-	er.ActiveBootSectorHeader().Dump()
+	bsh := er.ActiveBootSectorHeader()
+	oemParameters := er.OemParameters()
+
+	// The OEM parameters are added alongside the BSH fields so that the
+	// existing keys don't move.
+	info := struct {
+		exfat.BootSectorHeaderInfo `yaml:",inline"`
+		OemParameters              []exfat.OemParameterInfo `json:"oem_parameters" yaml:"oem_parameters"`
+	}{
+		BootSectorHeaderInfo: bsh.Info(),
+		OemParameters:        oemParameters.Info(),
+	}
+
+	switch rootArguments.Format {
+	case "json":
+		encoded, err := json.MarshalIndent(info, "", "  ")
+		log.PanicIf(err)
+
+		fmt.Println(string(encoded))
+	case "yaml":
+		encoded, err := yaml.Marshal(info)
+		log.PanicIf(err)
+
+		fmt.Print(string(encoded))
+	default:
+		bsh.Dump()
+		oemParameters.Dump()
+	}
+
+	if rootArguments.ExtendedBootCodePath != "" {
+		err := os.MkdirAll(rootArguments.ExtendedBootCodePath, 0755)
+		log.PanicIf(err)
+
+		// The extended boot-sectors are numbered from one, after the main
+		// boot-sector.
+		for i, extendedBootCode := range er.ExtendedBootCode() {
+			filename := fmt.Sprintf("extended-boot-code-%d.bin", i+1)
+			outputFilepath := filepath.Join(rootArguments.ExtendedBootCodePath, filename)
+
+			err := ioutil.WriteFile(outputFilepath, extendedBootCode, 0644)
+			log.PanicIf(err)
+		}
+	}
 }
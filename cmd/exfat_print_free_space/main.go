@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/dsoprea/go-logging"
+	"github.com/dustin/go-humanize"
+	"github.com/jessevdk/go-flags"
+
+	"github.com/dsoprea/go-exfat"
+)
+
+type rootParameters struct {
+	Filepath    string `short:"f" long:"filepath" description:"File-path of exFAT filesystem" required:"true"`
+	ExtentCount int    `short:"n" long:"extent-count" description:"Number of the largest free extents to list (zero for all)" default:"10"`
+}
+
+var (
+	rootArguments = new(rootParameters)
+)
+
+func main() {
+	defer func() {
+		if state := recover(); state != nil {
+			err := log.Wrap(state.(error))
+			log.PrintError(err)
+			os.Exit(-1)
+		}
+	}()
+
+	p := flags.NewParser(rootArguments, flags.Default)
+
+	_, err := p.Parse()
+	if err != nil {
+		os.Exit(1)
+	}
+
+	backend, err := exfat.OpenImageBackend(rootArguments.Filepath)
+	log.PanicIf(err)
+
+	er := exfat.NewExfatReaderFromBackend(backend)
+
+	defer er.Close()
+
+	err = er.Parse()
+	log.PanicIf(err)
+
+	ab, err := er.ReadAllocationBitmap()
+	log.PanicIf(err)
+
+	clusterSize := uint64(er.ActiveBootSectorHeader().ClusterSize())
+	freeClusterCount := ab.FreeClusterCount()
+
+	largestFileSize, err := ab.LargestContiguousFileSize()
+	log.PanicIf(err)
+
+	fmt.Printf("Free clusters: %s of %s (%s)\n", humanize.Comma(int64(freeClusterCount)), humanize.Comma(int64(ab.ClusterCount())), humanize.IBytes(uint64(freeClusterCount)*clusterSize))
+	fmt.Printf("Largest contiguous file: %s\n", humanize.IBytes(largestFileSize))
+	fmt.Printf("\n")
+
+	extents, err := ab.LargestFreeExtents(rootArguments.ExtentCount)
+	log.PanicIf(err)
+
+	fmt.Printf("%15s %15s %15s\n", "First Cluster", "Clusters", "Size")
+
+	for _, fe := range extents {
+		fmt.Printf("%15d %15s %15s\n", fe.FirstCluster, humanize.Comma(int64(fe.ClusterCount)), humanize.IBytes(uint64(fe.ClusterCount)*clusterSize))
+	}
+}
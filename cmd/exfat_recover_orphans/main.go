@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/dsoprea/go-logging"
+	"github.com/dustin/go-humanize"
+	"github.com/jessevdk/go-flags"
+
+	"github.com/dsoprea/go-exfat"
+)
+
+type rootParameters struct {
+	FilesystemFilepath string `short:"f" long:"filesystem-filepath" description:"File-path of exFAT filesystem" required:"true"`
+	OutputPath         string `short:"o" long:"output-path" description:"Directory to write one file per run of orphaned clusters to (if not given, the runs are only listed)"`
+}
+
+var (
+	rootArguments = new(rootParameters)
+)
+
+func main() {
+	defer func() {
+		if state := recover(); state != nil {
+			err := log.Wrap(state.(error))
+			log.PrintError(err)
+			os.Exit(-1)
+		}
+	}()
+
+	p := flags.NewParser(rootArguments, flags.Default)
+
+	_, err := p.Parse()
+	if err != nil {
+		os.Exit(1)
+	}
+
+	backend, err := exfat.OpenImageBackend(rootArguments.FilesystemFilepath)
+	log.PanicIf(err)
+
+	er := exfat.NewExfatReaderFromBackend(backend)
+
+	defer er.Close()
+
+	err = er.Parse()
+	log.PanicIf(err)
+
+	tree := exfat.NewTree(er)
+
+	runs, err := exfat.FindOrphanClusters(tree)
+	log.PanicIf(err)
+
+	clusterSize := uint64(er.ActiveBootSectorHeader().ClusterSize())
+
+	totalClusterCount := uint64(0)
+	for _, run := range runs {
+		fmt.Printf("Cluster (%d): (%d) clusters (%s)\n", run.FirstCluster, run.ClusterCount, humanize.IBytes(uint64(run.ClusterCount)*clusterSize))
+		totalClusterCount += uint64(run.ClusterCount)
+	}
+
+	if len(runs) > 0 {
+		fmt.Printf("\n")
+	}
+
+	fmt.Printf("Orphaned runs: (%d)\n", len(runs))
+	fmt.Printf("Orphaned clusters: (%d) (%s)\n", totalClusterCount, humanize.IBytes(totalClusterCount*clusterSize))
+	fmt.Printf("\n")
+
+	if rootArguments.OutputPath == "" || len(runs) == 0 {
+		return
+	}
+
+	filepaths, err := exfat.ExportOrphanRuns(er, runs, rootArguments.OutputPath)
+	log.PanicIf(err)
+
+	for _, filepath := range filepaths {
+		fmt.Printf("Wrote: %s\n", filepath)
+	}
+
+	fmt.Printf("\n")
+}
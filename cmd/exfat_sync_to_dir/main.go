@@ -0,0 +1,74 @@
+package main
+
+import (
+	"os"
+
+	"github.com/dsoprea/go-logging"
+	"github.com/jessevdk/go-flags"
+
+	"github.com/dsoprea/go-exfat"
+)
+
+type rootParameters struct {
+	FilesystemFilepath string `short:"f" long:"filesystem-filepath" description:"File-path of exFAT filesystem" required:"true"`
+	SourcePath         string `short:"s" long:"source-path" description:"Directory on the volume to sync (forward or backward slashes; defaults to the whole volume)"`
+	OutputPath         string `short:"o" long:"output-path" description:"Local directory to sync to" required:"true"`
+	CompareHash        bool   `long:"hash" description:"Compare existing files by hash rather than by size and modified-time"`
+	HashName           string `long:"hash-algorithm" description:"Hash algorithm to compare with (crc32, md5, sha1, sha256, sha512)" default:"sha256"`
+	DeleteRemoved      bool   `long:"delete" description:"Delete local files and directories that are not on the volume"`
+	CaseInsensitive    bool   `long:"case-insensitive" description:"Avoid local names that only differ by case"`
+	ReadAhead          int    `long:"read-ahead" description:"Number of clusters to read ahead in the background (for high-latency storage)" default:"0"`
+}
+
+var (
+	rootArguments = new(rootParameters)
+)
+
+func main() {
+	defer func() {
+		if state := recover(); state != nil {
+			err := log.Wrap(state.(error))
+			log.PrintError(err)
+			os.Exit(-1)
+		}
+	}()
+
+	p := flags.NewParser(rootArguments, flags.Default)
+
+	_, err := p.Parse()
+	if err != nil {
+		os.Exit(1)
+	}
+
+	backend, err := exfat.OpenImageBackend(rootArguments.FilesystemFilepath)
+	log.PanicIf(err)
+
+	er := exfat.NewExfatReaderFromBackend(backend)
+
+	defer er.Close()
+
+	err = er.Parse()
+	log.PanicIf(err)
+
+	er.SetReadAheadClusterCount(rootArguments.ReadAhead)
+
+	tree := exfat.NewTree(er)
+
+	err = tree.Load()
+	log.PanicIf(err)
+
+	opts := exfat.SyncOptions{
+		DeleteRemoved:   rootArguments.DeleteRemoved,
+		CaseInsensitive: rootArguments.CaseInsensitive,
+		HashName:        rootArguments.HashName,
+	}
+
+	if rootArguments.CompareHash == true {
+		opts.CompareMode = exfat.SyncCompareHash
+	}
+
+	summary, err := exfat.SyncToDir(tree, rootArguments.SourcePath, rootArguments.OutputPath, opts)
+	log.PanicIf(err)
+
+	summary.Dump()
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"os"
+
+	"github.com/dsoprea/go-logging"
+	"github.com/jessevdk/go-flags"
+
+	"github.com/dsoprea/go-exfat"
+)
+
+type rootParameters struct {
+	FilesystemFilepath string `short:"f" long:"filesystem-filepath" description:"File-path of exFAT filesystem" required:"true"`
+	SourcePath         string `short:"s" long:"source-path" description:"Directory on the volume to compare (forward or backward slashes; defaults to the whole volume)"`
+	LocalPath          string `short:"l" long:"local-path" description:"Local directory to compare against" required:"true"`
+	Hash               bool   `long:"hash" description:"Also compare the data of files that have the same size"`
+	HashName           string `long:"hash-algorithm" description:"Hash algorithm to compare with (crc32, md5, sha1, sha256, sha512)" default:"sha256"`
+	CaseInsensitive    bool   `long:"case-insensitive" description:"The local directory was synced with --case-insensitive"`
+}
+
+var (
+	rootArguments = new(rootParameters)
+)
+
+func main() {
+	defer func() {
+		if state := recover(); state != nil {
+			err := log.Wrap(state.(error))
+			log.PrintError(err)
+			os.Exit(-1)
+		}
+	}()
+
+	p := flags.NewParser(rootArguments, flags.Default)
+
+	_, err := p.Parse()
+	if err != nil {
+		os.Exit(1)
+	}
+
+	backend, err := exfat.OpenImageBackend(rootArguments.FilesystemFilepath)
+	log.PanicIf(err)
+
+	er := exfat.NewExfatReaderFromBackend(backend)
+
+	defer er.Close()
+
+	err = er.Parse()
+	log.PanicIf(err)
+
+	tree := exfat.NewTree(er)
+
+	opts := exfat.LocalDiffOptions{
+		VolumePath:      rootArguments.SourcePath,
+		CaseInsensitive: rootArguments.CaseInsensitive,
+	}
+
+	if rootArguments.Hash == true {
+		opts.HashName = rootArguments.HashName
+	}
+
+	report, err := exfat.DiffWithLocalOptions(tree, rootArguments.LocalPath, opts)
+	log.PanicIf(err)
+
+	report.Dump()
+
+	if report.IsIdentical() == false {
+		os.Exit(3)
+	}
+}
@@ -0,0 +1,521 @@
+// This package checks a volume against the requirements and recommendations of
+// the specification that are not needed to read it, which helps explain why
+// another implementation might refuse to mount it.
+
+package exfat
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"encoding/json"
+
+	"github.com/dsoprea/go-logging"
+)
+
+const (
+	// bootRegionSectorCount is the number of sectors in each of the main and
+	// backup boot regions (Section 3.1).
+	bootRegionSectorCount = 12
+
+	// maximumClusterCount is the largest ClusterCount allowed by Section
+	// 3.1.9 (2^32 - 11).
+	maximumClusterCount = 0xfffffff5
+)
+
+// ComplianceLevel is how strongly the specification states a requirement.
+type ComplianceLevel int
+
+const (
+	// ComplianceLevelShall is a requirement ("shall"). Other implementations
+	// may refuse to mount a volume that does not meet it.
+	ComplianceLevelShall ComplianceLevel = iota
+
+	// ComplianceLevelShould is a recommendation ("should").
+	ComplianceLevelShould
+)
+
+// String returns the keyword that the specification uses for the level.
+func (cl ComplianceLevel) String() string {
+	if cl == ComplianceLevelShall {
+		return "SHALL"
+	}
+
+	return "SHOULD"
+}
+
+// MarshalText encodes the level as its keyword.
+func (cl ComplianceLevel) MarshalText() ([]byte, error) {
+	return []byte(cl.String()), nil
+}
+
+// ComplianceGrade summarizes a compliance report.
+type ComplianceGrade int
+
+const (
+	// ComplianceGradeCompliant means that every check passed.
+	ComplianceGradeCompliant ComplianceGrade = iota
+
+	// ComplianceGradeDeviations means that only recommendations were not
+	// followed.
+	ComplianceGradeDeviations
+
+	// ComplianceGradeNonCompliant means that at least one requirement was not
+	// met.
+	ComplianceGradeNonCompliant
+)
+
+// String returns a description of the grade.
+func (cg ComplianceGrade) String() string {
+	switch cg {
+	case ComplianceGradeCompliant:
+		return "compliant"
+	case ComplianceGradeDeviations:
+		return "compliant with deviations from recommendations"
+	}
+
+	return "non-compliant"
+}
+
+// ComplianceFinding is one check that did not pass.
+type ComplianceFinding struct {
+	// Section is the section of the specification that the check comes from.
+	Section string `json:"section"`
+
+	Level       ComplianceLevel `json:"level"`
+	Description string          `json:"description"`
+}
+
+// String returns a string description.
+func (cf ComplianceFinding) String() string {
+	return fmt.Sprintf("ComplianceFinding<SECTION=[%s] LEVEL=[%s] DESCRIPTION=[%s]>", cf.Section, cf.Level, cf.Description)
+}
+
+// ComplianceReport is the result of checking a volume.
+type ComplianceReport struct {
+	// CheckCount is the number of checks that were performed.
+	CheckCount int `json:"check_count"`
+
+	// Findings are the checks that did not pass, in the order that they were
+	// performed.
+	Findings []ComplianceFinding `json:"findings"`
+
+	// BadClusters are the clusters that the FAT marks as bad. These are
+	// allowed by the specification, so they are not findings.
+	BadClusters []uint32 `json:"bad_clusters,omitempty"`
+
+	// BadClusterFiles are the files and directories that use any of the bad
+	// clusters.
+	BadClusterFiles []BadClusterFile `json:"bad_cluster_files,omitempty"`
+}
+
+// Grade summarizes the findings.
+func (cr *ComplianceReport) Grade() ComplianceGrade {
+	grade := ComplianceGradeCompliant
+
+	for _, cf := range cr.Findings {
+		if cf.Level == ComplianceLevelShall {
+			return ComplianceGradeNonCompliant
+		}
+
+		grade = ComplianceGradeDeviations
+	}
+
+	return grade
+}
+
+// MarshalJSON encodes the report along with its grade.
+func (cr *ComplianceReport) MarshalJSON() ([]byte, error) {
+	type reportFields ComplianceReport
+
+	encodable := struct {
+		*reportFields
+		Grade string `json:"grade"`
+	}{
+		reportFields: (*reportFields)(cr),
+		Grade:        cr.Grade().String(),
+	}
+
+	return json.Marshal(encodable)
+}
+
+// check records a finding if the condition is false.
+func (cr *ComplianceReport) check(condition bool, section string, level ComplianceLevel, format string, args ...interface{}) {
+	cr.CheckCount++
+
+	if condition == true {
+		return
+	}
+
+	cf := ComplianceFinding{
+		Section:     section,
+		Level:       level,
+		Description: fmt.Sprintf(format, args...),
+	}
+
+	cr.Findings = append(cr.Findings, cf)
+}
+
+// Dump prints the findings and the grade.
+func (cr *ComplianceReport) Dump() {
+	cr.DumpTo(os.Stdout)
+}
+
+// DumpTo is the same as Dump() but writes to the given writer.
+func (cr *ComplianceReport) DumpTo(w io.Writer) {
+	fmt.Fprintf(w, "Compliance Report\n")
+	fmt.Fprintf(w, "=================\n")
+	fmt.Fprintf(w, "\n")
+
+	for _, cf := range cr.Findings {
+		fmt.Fprintf(w, "%-6s %-8s %s\n", cf.Level, cf.Section, cf.Description)
+	}
+
+	if len(cr.Findings) > 0 {
+		fmt.Fprintf(w, "\n")
+	}
+
+	fmt.Fprintf(w, "Checks: (%d) Findings: (%d)\n", cr.CheckCount, len(cr.Findings))
+	fmt.Fprintf(w, "Grade: %s\n", cr.Grade())
+
+	if len(cr.BadClusters) == 0 {
+		return
+	}
+
+	fmt.Fprintf(w, "\n")
+	fmt.Fprintf(w, "Bad Clusters: (%d)\n", len(cr.BadClusters))
+
+	for _, bcf := range cr.BadClusterFiles {
+		fmt.Fprintf(w, "  %s: %v\n", bcf.Path, bcf.Clusters)
+	}
+}
+
+// bootChecksum calculates the checksum of a boot region's first eleven
+// sectors (Section 3.4.1). The VolumeFlags and PercentInUse fields are
+// skipped.
+func bootChecksum(data []byte) uint32 {
+	checksum := uint32(0)
+
+	for i, c := range data {
+		if i == 106 || i == 107 || i == 112 {
+			continue
+		}
+
+		if checksum&1 > 0 {
+			checksum = 0x80000000 + (checksum >> 1) + uint32(c)
+		} else {
+			checksum = (checksum >> 1) + uint32(c)
+		}
+	}
+
+	return checksum
+}
+
+// upcaseTableChecksum calculates the checksum of an up-case table (Section
+// 7.2.2).
+func upcaseTableChecksum(data []byte) uint32 {
+	checksum := uint32(0)
+
+	for _, c := range data {
+		if checksum&1 > 0 {
+			checksum = 0x80000000 + (checksum >> 1) + uint32(c)
+		} else {
+			checksum = (checksum >> 1) + uint32(c)
+		}
+	}
+
+	return checksum
+}
+
+// isFilledWith indicates whether every byte is the given value.
+func isFilledWith(data []byte, value byte) bool {
+	for _, c := range data {
+		if c != value {
+			return false
+		}
+	}
+
+	return true
+}
+
+// CheckCompliance checks the volume against the requirements and
+// recommendations of the specification that this package can verify: the
+// boot regions and their checksums, the geometry, the critical entries of the
+// root directory, and the entry-sets of every file. The bad clusters, and the
+// files that use them, are also reported. Parse() must be called
+// first. Problems that would keep the volume from being parsed at all are
+// returned as errors by Parse() instead.
+func (er *ExfatReader) CheckCompliance() (report *ComplianceReport, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	report = new(ComplianceReport)
+
+	err = er.checkBootRegionCompliance(report)
+	log.PanicIf(err)
+
+	er.checkGeometryCompliance(report)
+
+	err = er.checkRootDirectoryCompliance(report)
+	log.PanicIf(err)
+
+	err = er.checkFileCompliance(report)
+	log.PanicIf(err)
+
+	err = er.checkBadClusters(report)
+	log.PanicIf(err)
+
+	return report, nil
+}
+
+func (er *ExfatReader) checkBootRegionCompliance(report *ComplianceReport) (err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	sectorSize := int(er.SectorSize())
+	regionSize := bootRegionSectorCount * sectorSize
+
+	regions := make([]byte, regionSize*2)
+
+	err = er.readAt(regions, 0)
+	log.PanicIf(err)
+
+	mainRegion := regions[:regionSize]
+	backupRegion := regions[regionSize:]
+
+	names := []string{"main", "backup"}
+	for i, region := range [][]byte{mainRegion, backupRegion} {
+		name := names[i]
+
+		// Section 3.4: the last sector is the checksum of the others,
+		// repeated.
+		checksum := bootChecksum(region[:11*sectorSize])
+
+		checksumSector := region[11*sectorSize:]
+		isChecksumValid := true
+
+		for j := 0; j < sectorSize; j += 4 {
+			if defaultEncoding.Uint32(checksumSector[j:j+4]) != checksum {
+				isChecksumValid = false
+				break
+			}
+		}
+
+		report.check(isChecksumValid, "3.4", ComplianceLevelShall, "%s boot checksum does not match (0x%08x)", name, checksum)
+
+		// Section 3.2.2: each extended boot-sector ends with its signature.
+		for j := 1; j <= mainExtendedBootSectorCount; j++ {
+			sector := region[j*sectorSize : (j+1)*sectorSize]
+			signature := defaultEncoding.Uint32(sector[sectorSize-4:])
+
+			report.check(signature == requiredExtendedBootSignature, "3.2.2", ComplianceLevelShall, "%s extended boot-sector (%d) signature not correct: (0x%08x)", name, j, signature)
+		}
+	}
+
+	// Section 3.1: the backup is a copy of the main region, other than the
+	// fields that are excluded from the checksum.
+
+	mainComparable := make([]byte, 11*sectorSize)
+	copy(mainComparable, mainRegion)
+
+	backupComparable := make([]byte, 11*sectorSize)
+	copy(backupComparable, backupRegion)
+
+	for _, i := range []int{106, 107, 112} {
+		mainComparable[i] = 0
+		backupComparable[i] = 0
+	}
+
+	report.check(bytes.Equal(mainComparable, backupComparable) == true, "3.1", ComplianceLevelShould, "backup boot region does not match the main boot region")
+
+	// Section 3.1.19: only if the volume isn't bootable, but that's the norm
+	// for removable media.
+
+	bsh := er.bootRegion.bsh
+
+	report.check(isFilledWith(bsh.BootCode[:], 0xf4) == true, "3.1.19", ComplianceLevelShould, "boot-code is not filled with F4h (fine if the volume is meant to be bootable)")
+
+	for j := 1; j <= mainExtendedBootSectorCount; j++ {
+		extendedBootCode := mainRegion[j*sectorSize : (j+1)*sectorSize-4]
+
+		report.check(isFilledWith(extendedBootCode, 0) == true, "3.2.1", ComplianceLevelShould, "extended boot-code (%d) is not filled with 00h (fine if the volume is meant to be bootable)", j)
+	}
+
+	report.check(isFilledWith(bsh.Reserved[:], 0) == true, "3.1", ComplianceLevelShould, "reserved field of the boot-sector is not zeroed")
+
+	return nil
+}
+
+func (er *ExfatReader) checkGeometryCompliance(report *ComplianceReport) {
+	bsh := er.bootRegion.bsh
+	sectorSize := uint64(bsh.SectorSize())
+
+	// Section 3.1.5: at least 1M.
+	minimumVolumeLength := (1 << 20) / sectorSize
+	report.check(bsh.VolumeLength >= minimumVolumeLength, "3.1.5", ComplianceLevelShall, "volume-length is less than 1M: (%d) < (%d)", bsh.VolumeLength, minimumVolumeLength)
+
+	// Section 3.1.7: the FATs fit between the FAT offset and the cluster heap.
+	maximumFatLength := (uint64(bsh.ClusterHeapOffset) - uint64(bsh.FatOffset)) / uint64(bsh.NumberOfFats)
+	report.check(uint64(bsh.FatLength) <= maximumFatLength, "3.1.7", ComplianceLevelShall, "FAT length is larger than the room for it: (%d) > (%d)", bsh.FatLength, maximumFatLength)
+
+	// Section 3.1.9: the cluster-count is exactly what fits in the volume.
+
+	expectedClusterCount := uint64(0)
+	if bsh.VolumeLength > uint64(bsh.ClusterHeapOffset) {
+		expectedClusterCount = (bsh.VolumeLength - uint64(bsh.ClusterHeapOffset)) >> bsh.SectorsPerClusterShift
+	}
+
+	if expectedClusterCount > maximumClusterCount {
+		expectedClusterCount = maximumClusterCount
+	}
+
+	report.check(uint64(bsh.ClusterCount) == expectedClusterCount, "3.1.9", ComplianceLevelShall, "cluster-count is not what the volume-length allows: (%d) != (%d)", bsh.ClusterCount, expectedClusterCount)
+
+	// Section 3.1.10.
+	report.check(bsh.FirstClusterOfRootDirectory >= 2 && uint64(bsh.FirstClusterOfRootDirectory) <= uint64(bsh.ClusterCount)+1, "3.1.10", ComplianceLevelShall, "first cluster of the root directory is not in the cluster heap: (%d)", bsh.FirstClusterOfRootDirectory)
+
+	// Section 3.1.12: revision 1.00 through 1.99.
+	report.check(bsh.FileSystemRevision[1] == 1 && bsh.FileSystemRevision[0] <= 99, "3.1.12", ComplianceLevelShall, "filesystem revision not supported: (%d.%02d)", bsh.FileSystemRevision[1], bsh.FileSystemRevision[0])
+
+	// Section 3.1.13.
+	report.check(bsh.VolumeFlags.ClearToZero() == false, "3.1.13.4", ComplianceLevelShall, "clear-to-zero volume flag is set")
+	report.check(bsh.VolumeFlags&0xfff0 == 0, "3.1.13", ComplianceLevelShall, "reserved volume flags are set: (0x%04x)", uint16(bsh.VolumeFlags))
+	report.check(bsh.VolumeFlags.IsDirty() == false, "3.1.13.2", ComplianceLevelShould, "volume is marked dirty (it was not cleanly unmounted)")
+	report.check(bsh.VolumeFlags.HasHadMediaFailures() == false, "3.1.13.3", ComplianceLevelShould, "volume is marked as having had media failures")
+
+	// Section 3.1.17.
+	report.check(bsh.DriveSelect == 0x80, "3.1.17", ComplianceLevelShould, "drive-select is not 80h: (0x%02x)", bsh.DriveSelect)
+
+	// Section 3.1.18.
+	report.check(bsh.PercentInUse <= 100 || bsh.PercentInUse == 0xff, "3.1.18", ComplianceLevelShall, "percent-in-use not valid: (%d)", bsh.PercentInUse)
+}
+
+func (er *ExfatReader) checkRootDirectoryCompliance(report *ComplianceReport) (err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	bsh := er.bootRegion.bsh
+
+	en := NewExfatNavigator(er, er.FirstClusterOfRootDirectory())
+
+	index, _, _, err := en.IndexDirectoryEntries()
+	log.PanicIf(err)
+
+	// Section 7.1.
+	bitmapCount := len(index["AllocationBitmap"])
+	report.check(bitmapCount == int(bsh.NumberOfFats), "7.1", ComplianceLevelShall, "there is not one allocation-bitmap per FAT: (%d) != (%d)", bitmapCount, bsh.NumberOfFats)
+
+	if bitmapCount > 0 {
+		ab, err := er.ReadAllocationBitmap()
+		log.PanicIf(err)
+
+		if bsh.PercentInUse != 0xff {
+			usedClusterCount := uint64(ab.ClusterCount() - ab.FreeClusterCount())
+			percentInUse := usedClusterCount * 100 / uint64(ab.ClusterCount())
+
+			// Allow for the stored value to have been rounded either way.
+			difference := int(percentInUse) - int(bsh.PercentInUse)
+
+			report.check(difference >= -1 && difference <= 1, "3.1.18", ComplianceLevelShould, "percent-in-use does not match the allocation-bitmap: (%d) != (%d)", bsh.PercentInUse, percentInUse)
+		}
+	}
+
+	// Section 7.2.
+	upcaseTableCount := len(index["UpcaseTable"])
+	report.check(upcaseTableCount == 1, "7.2", ComplianceLevelShall, "there is not exactly one up-case table: (%d)", upcaseTableCount)
+
+	if upcaseTableCount == 1 {
+		utde, data, err := er.readUpcaseTableData()
+		log.PanicIf(err)
+
+		checksum := upcaseTableChecksum(data)
+		report.check(checksum == utde.TableChecksum, "7.2.2", ComplianceLevelShall, "up-case table checksum does not match: (0x%08x) != (0x%08x)", checksum, utde.TableChecksum)
+	}
+
+	// Section 7.3.
+	volumeLabelCount := len(index["VolumeLabel"])
+	report.check(volumeLabelCount <= 1, "7.3", ComplianceLevelShall, "there is more than one volume label: (%d)", volumeLabelCount)
+
+	return nil
+}
+
+// invalidFilenameCharacters are the characters that Section 7.7.3 does not
+// allow in filenames, other than the control characters.
+const invalidFilenameCharacters = "\"*/:<>?\\|"
+
+func (er *ExfatReader) checkFileCompliance(report *ComplianceReport) (err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	tree := NewTree(er)
+
+	err = tree.Load()
+	log.PanicIf(err)
+
+	cb := func(pathParts []string, node *TreeNode) (err error) {
+		if len(pathParts) == 0 || node.IsInUse() == false {
+			return nil
+		}
+
+		nodePath := strings.Join(pathParts, `\`)
+
+		es := node.IndexedDirectoryEntry().EntrySet
+		if es != nil {
+			report.check(es.IsChecksumValid() == true, "6.3.3", ComplianceLevelShall, "entry-set checksum does not match: [%s]", nodePath)
+		}
+
+		isNameValid := strings.ContainsAny(node.Name(), invalidFilenameCharacters) == false
+		for _, r := range node.Name() {
+			if r < 0x20 {
+				isNameValid = false
+			}
+		}
+
+		report.check(isNameValid == true, "7.7.3", ComplianceLevelShall, "filename has characters that are not allowed: [%s]", nodePath)
+
+		sede := node.StreamDirectoryEntry()
+		report.check(sede.ValidDataLength <= sede.DataLength, "7.6.4", ComplianceLevelShall, "valid data-length exceeds the data-length: [%s] (%d) > (%d)", nodePath, sede.ValidDataLength, sede.DataLength)
+
+		return nil
+	}
+
+	err = tree.Visit(cb)
+	log.PanicIf(err)
+
+	return nil
+}
+
+// checkBadClusters annotates the report with the bad clusters and the files
+// that they affect.
+func (er *ExfatReader) checkBadClusters(report *ComplianceReport) (err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	report.BadClusters, err = er.BadClusters()
+	log.PanicIf(err)
+
+	tree := NewTree(er)
+
+	report.BadClusterFiles, err = FindBadClusterFiles(tree)
+	log.PanicIf(err)
+
+	return nil
+}
@@ -0,0 +1,139 @@
+package exfat
+
+import (
+	"bytes"
+	"testing"
+
+	"encoding/json"
+
+	"github.com/dsoprea/go-logging"
+)
+
+func getTestComplianceReport(image []byte) *ComplianceReport {
+	er := NewExfatReader(bytes.NewReader(image))
+
+	err := er.Parse()
+	log.PanicIf(err)
+
+	report, err := er.CheckCompliance()
+	log.PanicIf(err)
+
+	return report
+}
+
+func TestExfatReader_CheckCompliance(t *testing.T) {
+	image, _ := getTestImage()
+
+	report := getTestComplianceReport(image)
+
+	if report.CheckCount == 0 {
+		t.Fatalf("Expected checks to be performed.")
+	} else if len(report.Findings) != 1 {
+		t.Fatalf("Expected exactly one finding: %v", report.Findings)
+	}
+
+	// The test image was formatted with boot-code.
+	if report.Findings[0].Section != "3.1.19" {
+		t.Fatalf("Finding not correct: %s", report.Findings[0])
+	} else if report.Grade() != ComplianceGradeDeviations {
+		t.Fatalf("Grade not correct: [%s]", report.Grade())
+	}
+}
+
+func TestExfatReader_CheckCompliance__BootRegionChanged(t *testing.T) {
+	image, _ := getTestImage()
+
+	// DriveSelect, in the main boot-sector only.
+	image[111] = 0
+
+	report := getTestComplianceReport(image)
+
+	sections := make([]string, len(report.Findings))
+	for i, cf := range report.Findings {
+		sections[i] = cf.Section
+	}
+
+	expected := []string{"3.4", "3.1", "3.1.19", "3.1.17"}
+
+	if len(sections) != len(expected) {
+		t.Fatalf("Findings not correct: %v", report.Findings)
+	}
+
+	for i, section := range expected {
+		if sections[i] != section {
+			t.Fatalf("Finding (%d) not correct: %s", i, report.Findings[i])
+		}
+	}
+
+	if report.Findings[0].Level != ComplianceLevelShall {
+		t.Fatalf("Checksum finding should be a requirement.")
+	} else if report.Grade() != ComplianceGradeNonCompliant {
+		t.Fatalf("Grade not correct: [%s]", report.Grade())
+	}
+}
+
+func TestComplianceReport_Grade(t *testing.T) {
+	report := new(ComplianceReport)
+
+	report.check(true, "1", ComplianceLevelShall, "passes")
+
+	if report.Grade() != ComplianceGradeCompliant {
+		t.Fatalf("Expected compliant grade.")
+	}
+
+	report.check(false, "2", ComplianceLevelShould, "fails")
+
+	if report.Grade() != ComplianceGradeDeviations {
+		t.Fatalf("Expected deviations grade.")
+	}
+
+	report.check(false, "3", ComplianceLevelShall, "fails")
+
+	if report.Grade() != ComplianceGradeNonCompliant {
+		t.Fatalf("Expected non-compliant grade.")
+	} else if report.CheckCount != 3 {
+		t.Fatalf("Check count not correct: (%d)", report.CheckCount)
+	}
+}
+
+func TestBootChecksum(t *testing.T) {
+	f, er := getTestFileAndParser()
+
+	defer f.Close()
+
+	err := er.Parse()
+	log.PanicIf(err)
+
+	sectorSize := int(er.SectorSize())
+
+	data := make([]byte, 12*sectorSize)
+
+	err = er.readAt(data, 0)
+	log.PanicIf(err)
+
+	checksum := bootChecksum(data[:11*sectorSize])
+	if defaultEncoding.Uint32(data[11*sectorSize:]) != checksum {
+		t.Fatalf("Checksum not correct: (0x%08x)", checksum)
+	}
+
+	// The excluded fields don't matter.
+	data[106] ^= 0xff
+	data[112] ^= 0xff
+
+	if bootChecksum(data[:11*sectorSize]) != checksum {
+		t.Fatalf("Excluded fields were included.")
+	}
+}
+
+func TestComplianceReport_MarshalJSON(t *testing.T) {
+	report := new(ComplianceReport)
+	report.check(false, "3.1.17", ComplianceLevelShould, "fails")
+
+	encoded, err := json.Marshal(report)
+	log.PanicIf(err)
+
+	expected := `{"check_count":1,"findings":[{"section":"3.1.17","level":"SHOULD","description":"fails"}],"grade":"compliant with deviations from recommendations"}`
+	if string(encoded) != expected {
+		t.Fatalf("Encoding not correct: %s", encoded)
+	}
+}
@@ -0,0 +1,104 @@
+// This package copies the files of one volume into a new one.
+
+package exfat
+
+import (
+	"github.com/dsoprea/go-logging"
+)
+
+// CopyFilterFunc decides whether the file or directory at the given path is
+// copied. The contents of directories that aren't copied are skipped.
+type CopyFilterFunc func(pathParts []string) bool
+
+// fileMetadataFromNode returns the attributes and timestamps of the node.
+func fileMetadataFromNode(node *TreeNode) FileMetadata {
+	fdf := node.FileDirectoryEntry()
+
+	return FileMetadata{
+		Attributes:   fdf.FileAttributes,
+		CreatedTime:  fdf.CreateTimestamp(),
+		ModifiedTime: fdf.LastModifiedTimestamp(),
+		AccessedTime: fdf.LastAccessedTimestamp(),
+	}
+}
+
+// Copy copies every file and directory on `src`, along with their attributes
+// and timestamps, into `dst`, which is then closed. Deleted files are not
+// copied. If `filter` is not nil, only the paths that it accepts are copied.
+// Since the destination is written from scratch, this can also be used to
+// move a volume to a smaller image or to one with a different geometry.
+func Copy(src *ExfatReader, dst *ExfatWriter, filter CopyFilterFunc) (err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	tree := NewTree(src)
+
+	err = copyDirectory(tree, tree.rootNode, []string{}, dst, filter)
+	log.PanicIf(err)
+
+	err = dst.Close()
+	log.PanicIf(err)
+
+	return nil
+}
+
+// copyDirectory copies the children of the given directory.
+func copyDirectory(tree *Tree, node *TreeNode, pathParts []string, dst *ExfatWriter, filter CopyFilterFunc) (err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	err = tree.loadNode(node)
+	log.PanicIf(err)
+
+	childPathParts := func(name string) []string {
+		current := make([]string, len(pathParts)+1)
+		copy(current, pathParts)
+		current[len(pathParts)] = name
+
+		return current
+	}
+
+	for _, name := range node.ChildFolders() {
+		child := node.GetChild(name)
+		if child.IsInUse() == false {
+			continue
+		}
+
+		currentPathParts := childPathParts(name)
+		if filter != nil && filter(currentPathParts) == false {
+			continue
+		}
+
+		err := dst.Mkdir(currentPathParts, fileMetadataFromNode(child))
+		log.PanicIf(err)
+
+		err = copyDirectory(tree, child, currentPathParts, dst, filter)
+		log.PanicIf(err)
+	}
+
+	for _, name := range node.ChildFiles() {
+		child := node.GetChild(name)
+		if child.IsInUse() == false {
+			continue
+		}
+
+		currentPathParts := childPathParts(name)
+		if filter != nil && filter(currentPathParts) == false {
+			continue
+		}
+
+		ewf, err := dst.createFile(currentPathParts, child.Size(), fileMetadataFromNode(child))
+		log.PanicIf(err)
+
+		err = child.WriteData(ewf, false)
+		log.PanicIf(err)
+	}
+
+	return nil
+}
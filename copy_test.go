@@ -0,0 +1,208 @@
+package exfat
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/dsoprea/go-logging"
+)
+
+func TestCopy(t *testing.T) {
+	f, er := getTestFileAndParser()
+
+	defer f.Close()
+
+	err := er.Parse()
+	log.PanicIf(err)
+
+	g, closer := getTestNewImage()
+
+	defer closer()
+
+	ew, err := NewExfatWriter(g, 4*1024*1024, FormatOptions{Label: "copy"})
+	log.PanicIf(err)
+
+	err = Copy(er, ew, nil)
+	log.PanicIf(err)
+
+	copiedEr, image := getTestParsedImage(g)
+
+	srcTree := NewTree(er)
+
+	srcFiles, _, err := srcTree.List()
+	log.PanicIf(err)
+
+	copiedTree := NewTree(copiedEr)
+
+	copiedFiles, copiedNodes, err := copiedTree.List()
+	log.PanicIf(err)
+
+	expectedFiles := make([]string, 0)
+	for _, filepath := range srcFiles {
+		node, err := srcTree.Lookup(strings.Split(filepath, `\`))
+		log.PanicIf(err)
+
+		if node.IsInUse() == true {
+			expectedFiles = append(expectedFiles, filepath)
+		}
+	}
+
+	if reflect.DeepEqual(copiedFiles, expectedFiles) != true {
+		t.Fatalf("Copied files not correct: %v != %v", copiedFiles, expectedFiles)
+	}
+
+	for _, filepath := range copiedFiles {
+		pathParts := strings.Split(filepath, `\`)
+
+		srcNode, err := srcTree.Lookup(pathParts)
+		log.PanicIf(err)
+
+		copiedNode := copiedNodes[filepath]
+
+		srcFdf := srcNode.FileDirectoryEntry()
+		copiedFdf := copiedNode.FileDirectoryEntry()
+
+		if copiedFdf.FileAttributes != srcFdf.FileAttributes {
+			t.Fatalf("Attributes not correct for [%s].", filepath)
+		} else if copiedFdf.LastModifiedTimestamp().Equal(srcFdf.LastModifiedTimestamp()) != true {
+			t.Fatalf("Modified time not correct for [%s].", filepath)
+		} else if copiedFdf.CreateTimestamp().Equal(srcFdf.CreateTimestamp()) != true {
+			t.Fatalf("Created time not correct for [%s].", filepath)
+		} else if copiedNode.Size() != srcNode.Size() {
+			t.Fatalf("Size not correct for [%s].", filepath)
+		}
+
+		if copiedFdf.FileAttributes.IsDirectory() == true {
+			continue
+		}
+
+		srcData := new(bytes.Buffer)
+
+		err = srcNode.WriteData(srcData, false)
+		log.PanicIf(err)
+
+		copiedData := new(bytes.Buffer)
+
+		err = copiedNode.WriteData(copiedData, false)
+		log.PanicIf(err)
+
+		if bytes.Equal(copiedData.Bytes(), srcData.Bytes()) != true {
+			t.Fatalf("Data not correct for [%s].", filepath)
+		}
+	}
+
+	node, err := copiedTree.Lookup([]string{"2-delahaye-type-165-cabriolet-dsc_8025.jpg"})
+	log.PanicIf(err)
+
+	if node == nil {
+		t.Fatalf("Image not copied.")
+	}
+
+	b := new(bytes.Buffer)
+
+	err = node.WriteData(b, false)
+	log.PanicIf(err)
+
+	digest := fmt.Sprintf("%x", sha1.Sum(b.Bytes()))
+	if len(b.Bytes()) != 313299 || digest != "a2219fa800ae2325003d8d4f5122b37f12f1e18e" {
+		t.Fatalf("Image data not correct: (%d) [%s]", len(b.Bytes()), digest)
+	}
+
+	// Deleted files aren't copied.
+
+	node, err = copiedTree.Lookup([]string{"8fd71ab132c59bf33cd7890c0acebf12.jpg"})
+	log.PanicIf(err)
+
+	if node != nil {
+		t.Fatalf("Deleted file was copied.")
+	}
+
+	report := getTestComplianceReport(image)
+
+	if len(report.Findings) != 0 {
+		t.Fatalf("Expected no compliance findings: %v", report.Findings)
+	}
+}
+
+func TestCopy__Filter(t *testing.T) {
+	f, er := getTestFileAndParser()
+
+	defer f.Close()
+
+	err := er.Parse()
+	log.PanicIf(err)
+
+	g, closer := getTestNewImage()
+
+	defer closer()
+
+	ew, err := NewExfatWriter(g, 4*1024*1024, FormatOptions{})
+	log.PanicIf(err)
+
+	filter := func(pathParts []string) bool {
+		return pathParts[0] != "testdirectory2"
+	}
+
+	err = Copy(er, ew, filter)
+	log.PanicIf(err)
+
+	copiedEr, _ := getTestParsedImage(g)
+
+	copiedTree := NewTree(copiedEr)
+
+	copiedFiles, _, err := copiedTree.List()
+	log.PanicIf(err)
+
+	for _, filepath := range copiedFiles {
+		if strings.HasPrefix(filepath, "testdirectory2") == true {
+			t.Fatalf("Filtered path was copied: [%s]", filepath)
+		}
+	}
+
+	node, err := copiedTree.Lookup([]string{"testdirectory"})
+	log.PanicIf(err)
+
+	if node == nil {
+		t.Fatalf("Unfiltered directory not copied.")
+	}
+}
+
+func TestCopy__RecreatedFile(t *testing.T) {
+	tree, data, closer := getTestRecreatedFileTree()
+
+	defer closer()
+
+	g, gCloser := getTestNewImage()
+
+	defer gCloser()
+
+	ew, err := NewExfatWriter(g, 2*1024*1024, FormatOptions{})
+	log.PanicIf(err)
+
+	err = Copy(tree.er, ew, nil)
+	log.PanicIf(err)
+
+	copiedEr, _ := getTestParsedImage(g)
+
+	copiedTree := NewTree(copiedEr)
+
+	node, err := copiedTree.Lookup([]string{"data.txt"})
+	log.PanicIf(err)
+
+	if node == nil {
+		t.Fatalf("Re-created file was not copied.")
+	}
+
+	b := new(bytes.Buffer)
+
+	err = node.WriteData(b, false)
+	log.PanicIf(err)
+
+	if bytes.Equal(b.Bytes(), data) != true {
+		t.Fatalf("Data not correct: [%s]", b.String())
+	}
+}
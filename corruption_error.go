@@ -0,0 +1,110 @@
+// This package describes errors caused by damaged filesystem structures.
+
+package exfat
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	// ErrCorrupt is matched (with errors.Is) by every CorruptionError.
+	ErrCorrupt = errors.New("filesystem structure is corrupt")
+)
+
+// CorruptionError describes a filesystem structure that could not be used
+// because it is damaged, along with where it was found. Use errors.As() to
+// retrieve it from an error returned by this package.
+type CorruptionError struct {
+	// Structure names the damaged structure (e.g. "directory entry").
+	Structure string
+
+	// Offset is the absolute offset of the structure in the image, or -1 if it
+	// isn't known.
+	Offset int64
+
+	// ClusterNumber is the cluster that the structure is in (or, for the FAT,
+	// the cluster whose entry is damaged), or zero if it doesn't apply.
+	ClusterNumber uint32
+
+	// EntryIndex is the index of the directory entry within its directory, or
+	// -1 if it doesn't apply.
+	EntryIndex int
+
+	// Err is the underlying cause.
+	Err error
+}
+
+// newCorruptionError returns a CorruptionError without a location. The
+// caller sets whichever of the location fields apply.
+func newCorruptionError(structure string, cause error) *CorruptionError {
+	return &CorruptionError{
+		Structure:  structure,
+		Offset:     -1,
+		EntryIndex: -1,
+		Err:        cause,
+	}
+}
+
+// Error returns the description of the error, along with whatever location
+// is known.
+func (ce *CorruptionError) Error() string {
+	location := make([]string, 0, 3)
+
+	if ce.Offset >= 0 {
+		location = append(location, fmt.Sprintf("OFFSET=(%d)", ce.Offset))
+	}
+
+	if ce.ClusterNumber != 0 {
+		location = append(location, fmt.Sprintf("CLUSTER=(%d)", ce.ClusterNumber))
+	}
+
+	if ce.EntryIndex >= 0 {
+		location = append(location, fmt.Sprintf("ENTRY=(%d)", ce.EntryIndex))
+	}
+
+	message := fmt.Sprintf("corrupt %s", ce.Structure)
+	if len(location) > 0 {
+		message = fmt.Sprintf("%s <%s>", message, strings.Join(location, " "))
+	}
+
+	if ce.Err != nil {
+		message = fmt.Sprintf("%s: %s", message, ce.Err.Error())
+	}
+
+	return message
+}
+
+// Unwrap returns the underlying cause.
+func (ce *CorruptionError) Unwrap() error {
+	return ce.Err
+}
+
+// Is matches ErrCorrupt.
+func (ce *CorruptionError) Is(target error) bool {
+	return target == ErrCorrupt
+}
+
+// newFatCorruptionError describes a damaged FAT entry. The offset is located
+// using the boot-sector rather than the loaded FAT, so that it's known even if
+// the FATs haven't been parsed.
+func (er *ExfatReader) newFatCorruptionError(clusterNumber uint32, cause error) *CorruptionError {
+	ce := newCorruptionError("FAT entry", cause)
+	ce.ClusterNumber = clusterNumber
+
+	if er.bootRegion.isEmpty() == false && clusterNumber >= 2 {
+		bsh := er.bootRegion.bsh
+
+		// This is the FAT that loadFats() selects.
+		fatIndex := int64(0)
+		if bsh.VolumeFlags.UseSecondFat() == true && bsh.NumberOfFats > 1 {
+			fatIndex = 1
+		}
+
+		fatOffset := (int64(bsh.FatOffset) + fatIndex*int64(bsh.FatLength)) * int64(er.SectorSize())
+		ce.Offset = fatOffset + int64(clusterNumber)*4
+	}
+
+	return ce
+}
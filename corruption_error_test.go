@@ -0,0 +1,116 @@
+package exfat
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/dsoprea/go-logging"
+)
+
+func TestCorruptionError_Error(t *testing.T) {
+	ce := newCorruptionError("directory entry", errors.New("bad"))
+	ce.Offset = 100
+	ce.ClusterNumber = 5
+	ce.EntryIndex = 2
+
+	if ce.Error() != "corrupt directory entry <OFFSET=(100) CLUSTER=(5) ENTRY=(2)>: bad" {
+		t.Fatalf("Error not correct: [%s]", ce.Error())
+	}
+
+	ce = newCorruptionError("up-case table", nil)
+	if ce.Error() != "corrupt up-case table" {
+		t.Fatalf("Error not correct without location: [%s]", ce.Error())
+	}
+}
+
+func TestCorruptionError__BootRegion(t *testing.T) {
+	image, _ := getTestImage()
+
+	// FileSystemName
+	image[3] = 'X'
+
+	er := NewExfatReader(bytes.NewReader(image))
+
+	err := er.Parse()
+	if errors.Is(err, ErrCorrupt) != true {
+		t.Fatalf("Expected corruption error: [%v]", err)
+	}
+
+	var ce *CorruptionError
+	if errors.As(err, &ce) != true {
+		t.Fatalf("Expected CorruptionError: [%v]", err)
+	} else if ce.Structure != "main boot region" || ce.Offset != 0 {
+		t.Fatalf("Location not correct: [%s]", ce)
+	}
+}
+
+func TestCorruptionError__DirectoryEntry(t *testing.T) {
+	image, er := getTestImage()
+
+	en := NewExfatNavigator(er, er.FirstClusterOfRootDirectory())
+
+	index, _, _, err := en.IndexDirectoryEntries()
+	log.PanicIf(err)
+
+	location := index["File"][0].Location
+
+	// An in-use, critical, primary type that doesn't exist.
+	image[location.Offset] = 0x9f
+
+	er = NewExfatReader(bytes.NewReader(image))
+
+	err = er.Parse()
+	log.PanicIf(err)
+
+	tree := NewTree(er)
+
+	err = tree.Load()
+
+	var ce *CorruptionError
+	if errors.As(err, &ce) != true {
+		t.Fatalf("Expected CorruptionError: [%v]", err)
+	} else if ce.Offset != location.Offset || ce.ClusterNumber != location.ClusterNumber || ce.EntryIndex != location.EntryNumber {
+		t.Fatalf("Location not correct: [%s] != [%s]", ce, location)
+	}
+}
+
+func TestCorruptionError__SentinelThroughWrap(t *testing.T) {
+	f, closer := getTestWritableImage()
+
+	defer closer()
+
+	er := NewExfatReader(NewReadOnly(f))
+
+	err := er.Parse()
+	log.PanicIf(err)
+
+	// The error has been wrapped by several layers.
+
+	err = er.SetDirty(true)
+	if errors.Is(err, ErrReadOnly) != true {
+		t.Fatalf("Expected read-only error: [%v]", err)
+	}
+}
+
+func TestCorruptionError__FatEntryDeferred(t *testing.T) {
+	f, er := getTestFileAndParser()
+
+	defer f.Close()
+
+	er.SetDeferFatParsing(true)
+
+	err := er.Parse()
+	log.PanicIf(err)
+
+	// The FATs haven't been parsed yet, but the offset is still known.
+
+	ce := er.newFatCorruptionError(5, ErrClusterLoop)
+
+	fat, err := er.ActiveFat()
+	log.PanicIf(err)
+
+	if ce.Offset != fat.offset+5*4 {
+		t.Fatalf("Offset not correct: (%d) != (%d)", ce.Offset, fat.offset+5*4)
+	}
+}
@@ -0,0 +1,108 @@
+// This package supports measuring the I/O that a reader performs.
+
+package exfat
+
+import (
+	"fmt"
+
+	"sync/atomic"
+)
+
+const (
+	// minimumSectorSize is used to count sectors before the boot-sector has
+	// been parsed.
+	minimumSectorSize = 512
+)
+
+// ReaderCounters describes the I/O that an ExfatReader has performed. These
+// are meant for measuring performance (e.g. in benchmarks) and are not exact
+// for reads that aren't sector-aligned.
+type ReaderCounters struct {
+	// SectorsRead is the number of sectors read, rounded up for each read.
+	SectorsRead uint64
+
+	// BytesRead is the number of bytes read from the image, including those
+	// served directly from a mapping.
+	BytesRead uint64
+
+	// CacheHits is the number of FAT lookups that were satisfied from the
+	// cache.
+	CacheHits uint64
+
+	// CacheMisses is the number of FAT lookups that had to read from the
+	// image.
+	CacheMisses uint64
+
+	// Seeks is the number of calls to Seek() on the underlying reader.
+	Seeks uint64
+}
+
+// String returns a descriptive string.
+func (rc ReaderCounters) String() string {
+	return fmt.Sprintf("ReaderCounters<SECTORS-READ=(%d) BYTES-READ=(%d) CACHE-HITS=(%d) CACHE-MISSES=(%d) SEEKS=(%d)>", rc.SectorsRead, rc.BytesRead, rc.CacheHits, rc.CacheMisses, rc.Seeks)
+}
+
+// Counters returns a snapshot of the I/O counters. This is safe to call while
+// other goroutines are reading.
+func (er *ExfatReader) Counters() ReaderCounters {
+	return ReaderCounters{
+		SectorsRead: atomic.LoadUint64(&er.counters.SectorsRead),
+		BytesRead:   atomic.LoadUint64(&er.counters.BytesRead),
+		CacheHits:   atomic.LoadUint64(&er.counters.CacheHits),
+		CacheMisses: atomic.LoadUint64(&er.counters.CacheMisses),
+		Seeks:       atomic.LoadUint64(&er.counters.Seeks),
+	}
+}
+
+// ResetCounters sets all of the I/O counters back to zero.
+func (er *ExfatReader) ResetCounters() {
+	atomic.StoreUint64(&er.counters.SectorsRead, 0)
+	atomic.StoreUint64(&er.counters.BytesRead, 0)
+	atomic.StoreUint64(&er.counters.CacheHits, 0)
+	atomic.StoreUint64(&er.counters.CacheMisses, 0)
+	atomic.StoreUint64(&er.counters.Seeks, 0)
+}
+
+// countRead records a read of the given number of bytes.
+func (er *ExfatReader) countRead(byteCount int) {
+	if byteCount <= 0 {
+		return
+	}
+
+	sectorSize := uint64(minimumSectorSize)
+	if er.bootRegion.bsh.BytesPerSectorShift != 0 {
+		sectorSize = uint64(er.bootRegion.bsh.SectorSize())
+	}
+
+	atomic.AddUint64(&er.counters.BytesRead, uint64(byteCount))
+	atomic.AddUint64(&er.counters.SectorsRead, (uint64(byteCount)+sectorSize-1)/sectorSize)
+}
+
+// countCacheLookup records a FAT lookup.
+func (er *ExfatReader) countCacheLookup(isHit bool) {
+	if isHit == true {
+		atomic.AddUint64(&er.counters.CacheHits, 1)
+	} else {
+		atomic.AddUint64(&er.counters.CacheMisses, 1)
+	}
+}
+
+// seek seeks the underlying reader and counts it.
+func (er *ExfatReader) seek(offset int64, whence int) (position int64, err error) {
+	atomic.AddUint64(&er.counters.Seeks, 1)
+
+	return er.rs.Seek(offset, whence)
+}
+
+// countingReader counts the sequential reads that are made while parsing.
+type countingReader struct {
+	er *ExfatReader
+}
+
+// Read reads from the underlying reader.
+func (cr countingReader) Read(p []byte) (n int, err error) {
+	n, err = cr.er.rs.Read(p)
+	cr.er.countRead(n)
+
+	return n, err
+}
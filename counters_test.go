@@ -0,0 +1,102 @@
+package exfat
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/dsoprea/go-logging"
+)
+
+func TestExfatReader_Counters(t *testing.T) {
+	f, er := getTestFileAndParser()
+
+	defer f.Close()
+
+	err := er.Parse()
+	log.PanicIf(err)
+
+	counters := er.Counters()
+	if counters.BytesRead == 0 || counters.SectorsRead == 0 || counters.Seeks == 0 {
+		t.Fatalf("Parse not counted: %s", counters)
+	}
+
+	er.ResetCounters()
+
+	if er.Counters() != (ReaderCounters{}) {
+		t.Fatalf("Counters not reset: %s", er.Counters())
+	}
+
+	tree := NewTree(er)
+
+	err = tree.Load()
+	log.PanicIf(err)
+
+	node, err := tree.LookupPath("2-delahaye-type-165-cabriolet-dsc_8025.jpg")
+	log.PanicIf(err)
+
+	er.ResetCounters()
+
+	err = node.WriteData(ioutil.Discard, false)
+	log.PanicIf(err)
+
+	counters = er.Counters()
+
+	if counters.BytesRead < node.Size() {
+		t.Fatalf("Bytes-read not correct: (%d) < (%d)", counters.BytesRead, node.Size())
+	} else if counters.SectorsRead < node.Size()/uint64(er.SectorSize()) {
+		t.Fatalf("Sectors-read not correct: (%d)", counters.SectorsRead)
+	} else if counters.Seeks != 0 {
+		t.Fatalf("Random-access reads should not seek: (%d)", counters.Seeks)
+	}
+
+	// The FAT was read when the first cluster was followed and every other
+	// cluster was found in the cache.
+
+	if counters.CacheMisses != 1 || counters.CacheHits == 0 {
+		t.Fatalf("Cache counters not correct: %s", counters)
+	}
+}
+
+func TestExfatReader_Counters__Sequential(t *testing.T) {
+	image, _ := getTestImage()
+
+	// Hide ReadAt() so that every read has to seek.
+	rs := struct {
+		io.ReadSeeker
+	}{
+		bytes.NewReader(image),
+	}
+
+	er := NewExfatReader(rs)
+
+	err := er.Parse()
+	log.PanicIf(err)
+
+	er.ResetCounters()
+
+	ec := er.GetCluster(er.FirstClusterOfRootDirectory())
+
+	_, err = ec.GetSectorByIndex(0)
+	log.PanicIf(err)
+
+	counters := er.Counters()
+	if counters.Seeks != 1 || counters.SectorsRead != 1 || counters.BytesRead != uint64(er.SectorSize()) {
+		t.Fatalf("Counters not correct: %s", counters)
+	}
+}
+
+func TestReaderCounters_String(t *testing.T) {
+	rc := ReaderCounters{
+		SectorsRead: 1,
+		BytesRead:   2,
+		CacheHits:   3,
+		CacheMisses: 4,
+		Seeks:       5,
+	}
+
+	if rc.String() != "ReaderCounters<SECTORS-READ=(1) BYTES-READ=(2) CACHE-HITS=(3) CACHE-MISSES=(4) SEEKS=(5)>" {
+		t.Fatalf("String not correct: [%s]", rc.String())
+	}
+}
@@ -0,0 +1,353 @@
+// This package supports comparing the contents of a volume against a local
+// directory.
+
+package exfat
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/dsoprea/go-logging"
+)
+
+// LocalDiffType describes how a path differs between the volume and the local
+// directory.
+type LocalDiffType int
+
+const (
+	// LocalDiffMissingLocally indicates that the path exists on the volume but
+	// not locally. The contents of a missing directory aren't listed.
+	LocalDiffMissingLocally LocalDiffType = iota
+
+	// LocalDiffMissingOnVolume indicates that the path exists locally but not
+	// on the volume. The contents of an extra directory aren't listed.
+	LocalDiffMissingOnVolume
+
+	// LocalDiffTypeMismatch indicates that the path is a file in one place and
+	// a directory in the other.
+	LocalDiffTypeMismatch
+
+	// LocalDiffSizeMismatch indicates that the file sizes differ.
+	LocalDiffSizeMismatch
+
+	// LocalDiffHashMismatch indicates that the files are the same size but
+	// their data differs. This is only checked if a hash algorithm was given.
+	LocalDiffHashMismatch
+)
+
+// String returns a descriptive string.
+func (ldt LocalDiffType) String() string {
+	switch ldt {
+	case LocalDiffMissingLocally:
+		return "missing-locally"
+	case LocalDiffMissingOnVolume:
+		return "missing-on-volume"
+	case LocalDiffTypeMismatch:
+		return "type-mismatch"
+	case LocalDiffSizeMismatch:
+		return "size-mismatch"
+	case LocalDiffHashMismatch:
+		return "hash-mismatch"
+	}
+
+	return fmt.Sprintf("LocalDiffType<%d>", int(ldt))
+}
+
+// LocalDiffEntry describes one path that differs.
+type LocalDiffEntry struct {
+	Type LocalDiffType
+
+	// VolumePath is the complete, backslash-separated path on the volume.
+	VolumePath string
+
+	// HostPath is the corresponding local path.
+	HostPath string
+
+	// VolumeSize and LocalSize are the file sizes, where they apply.
+	VolumeSize uint64
+	LocalSize  uint64
+}
+
+// String returns a descriptive string.
+func (lde LocalDiffEntry) String() string {
+	return fmt.Sprintf("LocalDiffEntry<TYPE=[%s] VOLUME-PATH=[%s] HOST-PATH=[%s] VOLUME-SIZE=(%d) LOCAL-SIZE=(%d)>", lde.Type, lde.VolumePath, lde.HostPath, lde.VolumeSize, lde.LocalSize)
+}
+
+// DiffReport is the result of comparing a volume against a local directory.
+type DiffReport struct {
+	// Differences are ordered by volume path.
+	Differences []LocalDiffEntry
+
+	// Matched is the count of files that were found to be the same.
+	Matched int
+
+	// HashName is the algorithm that the data was compared with. It is empty
+	// if only sizes were compared.
+	HashName string
+}
+
+// IsIdentical indicates whether no differences were found.
+func (dr *DiffReport) IsIdentical() bool {
+	return len(dr.Differences) == 0
+}
+
+// Dump prints the report.
+func (dr *DiffReport) Dump() {
+	dr.DumpTo(os.Stdout)
+}
+
+// DumpTo is the same as Dump() but writes to the given writer.
+func (dr *DiffReport) DumpTo(w io.Writer) {
+	fmt.Fprintf(w, "Diff Report\n")
+	fmt.Fprintf(w, "===========\n")
+	fmt.Fprintf(w, "\n")
+
+	for _, lde := range dr.Differences {
+		switch lde.Type {
+		case LocalDiffSizeMismatch:
+			fmt.Fprintf(w, "%s: %s (%d) != (%d)\n", lde.Type, lde.VolumePath, lde.VolumeSize, lde.LocalSize)
+		case LocalDiffMissingOnVolume:
+			fmt.Fprintf(w, "%s: %s\n", lde.Type, lde.HostPath)
+		default:
+			fmt.Fprintf(w, "%s: %s\n", lde.Type, lde.VolumePath)
+		}
+	}
+
+	if len(dr.Differences) > 0 {
+		fmt.Fprintf(w, "\n")
+	}
+
+	fmt.Fprintf(w, "Differences: (%d)\n", len(dr.Differences))
+	fmt.Fprintf(w, "Matched: (%d)\n", dr.Matched)
+
+	if dr.HashName != "" {
+		fmt.Fprintf(w, "Compared by: %s\n", dr.HashName)
+	} else {
+		fmt.Fprintf(w, "Compared by: size\n")
+	}
+
+	fmt.Fprintf(w, "\n")
+}
+
+// LocalDiffOptions are the options for DiffWithLocalOptions().
+type LocalDiffOptions struct {
+	// VolumePath is the directory on the volume to compare (empty for the
+	// whole volume).
+	VolumePath string
+
+	// HashName is the registered name of the hash algorithm to compare the
+	// data of files with the same size with (see RegisterHash). If empty, only
+	// sizes are compared.
+	HashName string
+
+	// CaseInsensitive should be true if the local filesystem is case-
+	// insensitive. This should match what the directory was synced with (see
+	// SyncOptions).
+	CaseInsensitive bool
+}
+
+// DiffWithLocal compares the whole volume against `localRoot` and reports
+// missing files, extra local files, and size mismatches. See
+// DiffWithLocalOptions() to also compare the data.
+func DiffWithLocal(tree *Tree, localRoot string) (report *DiffReport, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	report, err = DiffWithLocalOptions(tree, localRoot, LocalDiffOptions{})
+	log.PanicIf(err)
+
+	return report, nil
+}
+
+// DiffWithLocalOptions compares a directory on the volume against
+// `localRoot`, which is laid out the way SyncToDir() would have written it.
+// Deleted entries on the volume are ignored.
+func DiffWithLocalOptions(tree *Tree, localRoot string, opts LocalDiffOptions) (report *DiffReport, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	var newHash HashFactory
+	if opts.HashName != "" {
+		newHash, err = LookupHash(opts.HashName)
+		log.PanicIf(err)
+	}
+
+	rootNode, err := tree.LookupPath(opts.VolumePath)
+	log.PanicIf(err)
+
+	if rootNode == nil {
+		log.Panicf("volume path not found: [%s]", opts.VolumePath)
+	} else if rootNode.IsDirectory() == false {
+		log.Panicf("volume path is not a directory: [%s]", opts.VolumePath)
+	}
+
+	localRoot = filepath.Clean(localRoot)
+
+	fi, err := os.Stat(localRoot)
+	log.PanicIf(err)
+
+	if fi.IsDir() == false {
+		log.Panicf("local path is not a directory: [%s]", localRoot)
+	}
+
+	report = &DiffReport{
+		Differences: make([]LocalDiffEntry, 0),
+		HashName:    opts.HashName,
+	}
+
+	hpm := NewHostPathMapper(localRoot, opts.CaseInsensitive)
+	rootPathParts := SplitVolumePath(opts.VolumePath)
+
+	// Every host path that corresponds to something on the volume.
+	kept := make(map[string]bool)
+
+	// The local directories that are files on the volume. Their contents
+	// aren't listed.
+	mismatched := make(map[string]bool)
+
+	cb := func(pathParts []string, node *TreeNode) (err error) {
+		defer func() {
+			if errRaw := recover(); errRaw != nil {
+				err = log.Wrap(errRaw.(error))
+			}
+		}()
+
+		if node.IsInUse() == false {
+			if node.IsDirectory() == true {
+				return filepath.SkipDir
+			}
+
+			return nil
+		}
+
+		hostPath, err := hpm.HostPathFromParts(pathParts)
+		log.PanicIf(err)
+
+		kept[hostPath] = true
+
+		lde := LocalDiffEntry{
+			VolumePath: JoinVolumePath(append(append([]string{}, rootPathParts...), pathParts...)),
+			HostPath:   hostPath,
+		}
+
+		if node.IsDirectory() == false {
+			lde.VolumeSize = node.Size()
+		}
+
+		fi, err := os.Stat(hostPath)
+		if err != nil {
+			if os.IsNotExist(err) == false {
+				log.Panic(err)
+			}
+
+			lde.Type = LocalDiffMissingLocally
+			report.Differences = append(report.Differences, lde)
+
+			if node.IsDirectory() == true {
+				return filepath.SkipDir
+			}
+
+			return nil
+		}
+
+		if fi.IsDir() != node.IsDirectory() {
+			lde.Type = LocalDiffTypeMismatch
+			report.Differences = append(report.Differences, lde)
+
+			if node.IsDirectory() == true {
+				return filepath.SkipDir
+			}
+
+			mismatched[hostPath] = true
+
+			return nil
+		} else if node.IsDirectory() == true {
+			return nil
+		}
+
+		lde.LocalSize = uint64(fi.Size())
+
+		if lde.LocalSize != lde.VolumeSize {
+			lde.Type = LocalDiffSizeMismatch
+			report.Differences = append(report.Differences, lde)
+
+			return nil
+		}
+
+		if newHash != nil {
+			isCurrent, err := isSyncedFileCurrent(tree, node, hostPath, fi, SyncCompareHash, newHash)
+			log.PanicIf(err)
+
+			if isCurrent == false {
+				lde.Type = LocalDiffHashMismatch
+				report.Differences = append(report.Differences, lde)
+
+				return nil
+			}
+		}
+
+		report.Matched++
+
+		return nil
+	}
+
+	err = tree.walk(make([]string, 0), rootNode, cb)
+	log.PanicIf(err)
+
+	// Anything local that wasn't reached from the volume is extra. Directories
+	// that are missing locally or that are files locally weren't descended
+	// into, so their (nonexistent) children can't show up here.
+
+	walkFn := func(hostPath string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if mismatched[hostPath] == true {
+			return filepath.SkipDir
+		} else if hostPath == localRoot || kept[hostPath] == true {
+			return nil
+		}
+
+		relativeVolumePath, err := VolumePathFromHostPath(localRoot, hostPath)
+		if err != nil {
+			return err
+		}
+
+		relativePathParts := SplitVolumePath(relativeVolumePath)
+
+		lde := LocalDiffEntry{
+			Type:       LocalDiffMissingOnVolume,
+			VolumePath: JoinVolumePath(append(append([]string{}, rootPathParts...), relativePathParts...)),
+			HostPath:   hostPath,
+		}
+
+		if fi.IsDir() == true {
+			report.Differences = append(report.Differences, lde)
+			return filepath.SkipDir
+		}
+
+		lde.LocalSize = uint64(fi.Size())
+		report.Differences = append(report.Differences, lde)
+
+		return nil
+	}
+
+	err = filepath.Walk(localRoot, walkFn)
+	log.PanicIf(err)
+
+	sort.SliceStable(report.Differences, func(i, j int) bool {
+		return report.Differences[i].VolumePath < report.Differences[j].VolumePath
+	})
+
+	return report, nil
+}
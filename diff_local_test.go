@@ -0,0 +1,152 @@
+package exfat
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dsoprea/go-logging"
+)
+
+// getTestSyncedDir syncs the test volume to a new temporary directory.
+func getTestSyncedDir(tree *Tree) (destDir string) {
+	destDir, err := ioutil.TempDir("", "exfat-diff")
+	log.PanicIf(err)
+
+	_, err = SyncToDir(tree, "", destDir, SyncOptions{})
+	log.PanicIf(err)
+
+	return destDir
+}
+
+func TestDiffWithLocal__Identical(t *testing.T) {
+	tree, closer := getTestTree()
+
+	defer closer()
+
+	destDir := getTestSyncedDir(tree)
+
+	defer os.RemoveAll(destDir)
+
+	report, err := DiffWithLocal(tree, destDir)
+	log.PanicIf(err)
+
+	if report.IsIdentical() != true {
+		t.Fatalf("Expected no differences: %v", report.Differences)
+	} else if report.Matched != 7 {
+		t.Fatalf("Matched count not correct: (%d)", report.Matched)
+	}
+
+	report.Dump()
+}
+
+func TestDiffWithLocal__Differences(t *testing.T) {
+	tree, closer := getTestTree()
+
+	defer closer()
+
+	destDir := getTestSyncedDir(tree)
+
+	defer os.RemoveAll(destDir)
+
+	// Missing.
+
+	err := os.Remove(filepath.Join(destDir, "064cbfd4-cec3-11e9-926d-c362c80fab7b"))
+	log.PanicIf(err)
+
+	// Size.
+
+	err = ioutil.WriteFile(filepath.Join(destDir, "79c6d31a-cca1-11e9-8325-9746d045e868"), []byte("short"), 0644)
+	log.PanicIf(err)
+
+	// Extra.
+
+	err = ioutil.WriteFile(filepath.Join(destDir, "testdirectory", "extra"), []byte("extra"), 0644)
+	log.PanicIf(err)
+
+	// A directory that's a file locally.
+
+	err = os.RemoveAll(filepath.Join(destDir, "testdirectory3"))
+	log.PanicIf(err)
+
+	err = ioutil.WriteFile(filepath.Join(destDir, "testdirectory3"), []byte("not a directory"), 0644)
+	log.PanicIf(err)
+
+	report, err := DiffWithLocal(tree, destDir)
+	log.PanicIf(err)
+
+	expected := []struct {
+		diffType   LocalDiffType
+		volumePath string
+	}{
+		{LocalDiffMissingLocally, "064cbfd4-cec3-11e9-926d-c362c80fab7b"},
+		{LocalDiffSizeMismatch, "79c6d31a-cca1-11e9-8325-9746d045e868"},
+		{LocalDiffTypeMismatch, "testdirectory3"},
+		{LocalDiffMissingOnVolume, `testdirectory\extra`},
+	}
+
+	if len(report.Differences) != len(expected) {
+		t.Fatalf("Differences not correct: %v", report.Differences)
+	}
+
+	for i, lde := range report.Differences {
+		if lde.Type != expected[i].diffType || lde.VolumePath != expected[i].volumePath {
+			t.Fatalf("Difference (%d) not correct: %s", i, lde)
+		}
+	}
+
+	if report.Differences[1].VolumeSize != 29 || report.Differences[1].LocalSize != 5 {
+		t.Fatalf("Sizes not correct: %s", report.Differences[1])
+	} else if report.Matched != 4 {
+		t.Fatalf("Matched count not correct: (%d)", report.Matched)
+	}
+}
+
+func TestDiffWithLocalOptions__Hash(t *testing.T) {
+	tree, closer := getTestTree()
+
+	defer closer()
+
+	destDir := getTestSyncedDir(tree)
+
+	defer os.RemoveAll(destDir)
+
+	// Same size, different data.
+
+	hostFilepath := filepath.Join(destDir, "testdirectory2", "00c57ab0-cec3-11e9-b750-bbed8d2244c8")
+
+	data, err := ioutil.ReadFile(hostFilepath)
+	log.PanicIf(err)
+
+	data[0] ^= 0xff
+
+	err = ioutil.WriteFile(hostFilepath, data, 0644)
+	log.PanicIf(err)
+
+	report, err := DiffWithLocal(tree, destDir)
+	log.PanicIf(err)
+
+	if report.IsIdentical() != true {
+		t.Fatalf("Expected no differences by size: %v", report.Differences)
+	}
+
+	opts := LocalDiffOptions{
+		VolumePath: "testdirectory2",
+		HashName:   "sha1",
+	}
+
+	report, err = DiffWithLocalOptions(tree, filepath.Join(destDir, "testdirectory2"), opts)
+	log.PanicIf(err)
+
+	if len(report.Differences) != 1 {
+		t.Fatalf("Differences not correct: %v", report.Differences)
+	}
+
+	lde := report.Differences[0]
+	if lde.Type != LocalDiffHashMismatch || lde.VolumePath != `testdirectory2\00c57ab0-cec3-11e9-b750-bbed8d2244c8` || lde.HostPath != hostFilepath {
+		t.Fatalf("Difference not correct: %s", lde)
+	} else if report.Matched != 1 {
+		t.Fatalf("Matched count not correct: (%d)", report.Matched)
+	}
+}
@@ -0,0 +1,83 @@
+// This package computes a digest of data while it's being extracted.
+
+package exfat
+
+import (
+	"io"
+
+	"github.com/dsoprea/go-logging"
+)
+
+// digestWriter hashes and counts what it passes on.
+type digestWriter struct {
+	w       io.Writer
+	h       io.Writer
+	written uint64
+}
+
+// Write writes to the underlying writer and hashes whatever was written.
+func (dw *digestWriter) Write(p []byte) (n int, err error) {
+	n, err = dw.w.Write(p)
+
+	// A hash never fails to write.
+	dw.h.Write(p[:n])
+	dw.written += uint64(n)
+
+	return n, err
+}
+
+// WriteFromClusterChainWithDigest is the same as WriteFromClusterChain() but
+// also computes a digest of the data as it's written, so that it doesn't have
+// to be read a second time to be verified. `newHash` defaults to SHA-256 if
+// nil (see LookupHash() for the others).
+func (er *ExfatReader) WriteFromClusterChainWithDigest(firstClusterNumber uint32, dataSize uint64, useFat bool, w io.Writer, newHash HashFactory) (written uint64, digest []byte, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	if newHash == nil {
+		newHash, err = LookupHash(DefaultHashName)
+		log.PanicIf(err)
+	}
+
+	h := newHash()
+
+	dw := &digestWriter{
+		w: w,
+		h: h,
+	}
+
+	_, _, err = er.WriteFromClusterChain(firstClusterNumber, dataSize, useFat, dw)
+	log.PanicIf(err)
+
+	return dw.written, h.Sum(nil), nil
+}
+
+// WriteDataWithDigest is the same as WriteData() but also returns the number
+// of bytes written and their digest. `newHash` defaults to SHA-256 if nil.
+func (tn *TreeNode) WriteDataWithDigest(w io.Writer, includeSlack bool, newHash HashFactory) (written uint64, digest []byte, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	if newHash == nil {
+		newHash, err = LookupHash(DefaultHashName)
+		log.PanicIf(err)
+	}
+
+	h := newHash()
+
+	dw := &digestWriter{
+		w: w,
+		h: h,
+	}
+
+	err = tn.WriteData(dw, includeSlack)
+	log.PanicIf(err)
+
+	return dw.written, h.Sum(nil), nil
+}
@@ -0,0 +1,91 @@
+package exfat
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"crypto/md5"
+	"crypto/sha256"
+
+	"github.com/dsoprea/go-logging"
+)
+
+func TestExfatReader_WriteFromClusterChainWithDigest(t *testing.T) {
+	tree, closer := getTestTree()
+
+	defer closer()
+
+	node, err := tree.LookupPath("2-delahaye-type-165-cabriolet-dsc_8025.jpg")
+	log.PanicIf(err)
+
+	sede := node.StreamDirectoryEntry()
+	useFat := sede.GeneralSecondaryFlags.NoFatChain() == false
+
+	b := new(bytes.Buffer)
+
+	written, digest, err := tree.er.WriteFromClusterChainWithDigest(sede.FirstCluster, node.Size(), useFat, b, nil)
+	log.PanicIf(err)
+
+	expected := sha256.Sum256(b.Bytes())
+
+	if written != 313299 || b.Len() != 313299 {
+		t.Fatalf("Written count not correct: (%d) (%d)", written, b.Len())
+	} else if bytes.Equal(digest, expected[:]) != true {
+		t.Fatalf("Digest not correct: %x", digest)
+	}
+}
+
+func TestTreeNode_WriteDataWithDigest(t *testing.T) {
+	tree, closer := getTestTree()
+
+	defer closer()
+
+	node, err := tree.LookupPath("79c6d31a-cca1-11e9-8325-9746d045e868")
+	log.PanicIf(err)
+
+	b := new(bytes.Buffer)
+
+	written, digest, err := node.WriteDataWithDigest(b, false, md5.New)
+	log.PanicIf(err)
+
+	expected := md5.Sum(b.Bytes())
+
+	if written != node.Size() || uint64(b.Len()) != node.Size() {
+		t.Fatalf("Written count not correct: (%d) (%d)", written, b.Len())
+	} else if bytes.Equal(digest, expected[:]) != true {
+		t.Fatalf("Digest not correct: %x", digest)
+	}
+}
+
+// testFailingWriter accepts a limited number of bytes.
+type testFailingWriter struct {
+	remaining int
+}
+
+func (tfw *testFailingWriter) Write(p []byte) (n int, err error) {
+	if len(p) > tfw.remaining {
+		n = tfw.remaining
+		tfw.remaining = 0
+
+		return n, errors.New("writer full")
+	}
+
+	tfw.remaining -= len(p)
+
+	return len(p), nil
+}
+
+func TestTreeNode_WriteDataWithDigest__WriteError(t *testing.T) {
+	tree, closer := getTestTree()
+
+	defer closer()
+
+	node, err := tree.LookupPath("2-delahaye-type-165-cabriolet-dsc_8025.jpg")
+	log.PanicIf(err)
+
+	_, _, err = node.WriteDataWithDigest(&testFailingWriter{remaining: 1000}, false, nil)
+	if err == nil {
+		t.Fatalf("Expected an error.")
+	}
+}
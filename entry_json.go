@@ -0,0 +1,336 @@
+// This package supports serializing parsed directory entries.
+
+package exfat
+
+import (
+	"time"
+
+	"encoding/hex"
+	"encoding/json"
+)
+
+// EntryTypeInfo is the decoded form of EntryType, suitable for
+// serialization.
+type EntryTypeInfo struct {
+	Raw        uint8 `json:"raw" yaml:"raw"`
+	TypeCode   int   `json:"type_code" yaml:"type_code"`
+	IsCritical bool  `json:"is_critical" yaml:"is_critical"`
+	IsPrimary  bool  `json:"is_primary" yaml:"is_primary"`
+	IsInUse    bool  `json:"is_in_use" yaml:"is_in_use"`
+}
+
+// Info returns the decoded entry-type.
+func (et EntryType) Info() EntryTypeInfo {
+	return EntryTypeInfo{
+		Raw:        uint8(et),
+		TypeCode:   et.TypeCode(),
+		IsCritical: et.IsCritical(),
+		IsPrimary:  et.IsPrimary(),
+		IsInUse:    et.IsInUse(),
+	}
+}
+
+// FileAttributesInfo is the decoded form of FileAttributes, suitable for
+// serialization.
+type FileAttributesInfo struct {
+	Raw         uint16 `json:"raw" yaml:"raw"`
+	IsReadOnly  bool   `json:"is_read_only" yaml:"is_read_only"`
+	IsHidden    bool   `json:"is_hidden" yaml:"is_hidden"`
+	IsSystem    bool   `json:"is_system" yaml:"is_system"`
+	IsDirectory bool   `json:"is_directory" yaml:"is_directory"`
+	IsArchive   bool   `json:"is_archive" yaml:"is_archive"`
+
+	// ReservedBits are any bits that are set beyond the defined ones.
+	ReservedBits uint16 `json:"reserved_bits" yaml:"reserved_bits"`
+}
+
+// Info returns the decoded attributes.
+func (fa FileAttributes) Info() FileAttributesInfo {
+	return FileAttributesInfo{
+		Raw:         uint16(fa),
+		IsReadOnly:  fa.IsReadOnly(),
+		IsHidden:    fa.IsHidden(),
+		IsSystem:    fa.IsSystem(),
+		IsDirectory: fa.IsDirectory(),
+		IsArchive:   fa.IsArchive(),
+
+		ReservedBits: fa.ReservedBits(),
+	}
+}
+
+// GeneralPrimaryFlagsInfo is the decoded form of GeneralPrimaryFlags, suitable
+// for serialization.
+type GeneralPrimaryFlagsInfo struct {
+	Raw                  uint16 `json:"raw" yaml:"raw"`
+	IsAllocationPossible bool   `json:"is_allocation_possible" yaml:"is_allocation_possible"`
+	NoFatChain           bool   `json:"no_fat_chain" yaml:"no_fat_chain"`
+}
+
+// Info returns the decoded flags.
+func (gpf GeneralPrimaryFlags) Info() GeneralPrimaryFlagsInfo {
+	return GeneralPrimaryFlagsInfo{
+		Raw:                  uint16(gpf),
+		IsAllocationPossible: gpf.IsAllocationPossible(),
+		NoFatChain:           gpf.NoFatChain(),
+	}
+}
+
+// GeneralSecondaryFlagsInfo is the decoded form of GeneralSecondaryFlags,
+// suitable for serialization.
+type GeneralSecondaryFlagsInfo struct {
+	Raw                  uint8 `json:"raw" yaml:"raw"`
+	IsAllocationPossible bool  `json:"is_allocation_possible" yaml:"is_allocation_possible"`
+	NoFatChain           bool  `json:"no_fat_chain" yaml:"no_fat_chain"`
+}
+
+// Info returns the decoded flags.
+func (gsf GeneralSecondaryFlags) Info() GeneralSecondaryFlagsInfo {
+	return GeneralSecondaryFlagsInfo{
+		Raw:                  uint8(gsf),
+		IsAllocationPossible: gsf.IsAllocationPossible(),
+		NoFatChain:           gsf.NoFatChain(),
+	}
+}
+
+// MarshalJSON returns the decoded entry, including its timestamps, as JSON.
+func (fdf ExfatFileDirectoryEntry) MarshalJSON() ([]byte, error) {
+	encodable := struct {
+		Type                  string             `json:"type"`
+		EntryType             EntryTypeInfo      `json:"entry_type"`
+		SecondaryCount        uint8              `json:"secondary_count"`
+		SetChecksum           uint16             `json:"set_checksum"`
+		FileAttributes        FileAttributesInfo `json:"file_attributes"`
+		CreateTimestamp       time.Time          `json:"create_timestamp"`
+		LastModifiedTimestamp time.Time          `json:"last_modified_timestamp"`
+		LastAccessedTimestamp time.Time          `json:"last_accessed_timestamp"`
+	}{
+		Type:                  fdf.TypeName(),
+		EntryType:             fdf.EntryType.Info(),
+		SecondaryCount:        fdf.SecondaryCount(),
+		SetChecksum:           fdf.SetChecksum,
+		FileAttributes:        fdf.FileAttributes.Info(),
+		CreateTimestamp:       fdf.CreateTimestamp(),
+		LastModifiedTimestamp: fdf.LastModifiedTimestamp(),
+		LastAccessedTimestamp: fdf.LastAccessedTimestamp(),
+	}
+
+	return json.Marshal(encodable)
+}
+
+// MarshalJSON returns the decoded entry as JSON.
+func (abde ExfatAllocationBitmapDirectoryEntry) MarshalJSON() ([]byte, error) {
+	encodable := struct {
+		Type         string        `json:"type"`
+		EntryType    EntryTypeInfo `json:"entry_type"`
+		BitmapFlags  uint8         `json:"bitmap_flags"`
+		FirstCluster uint32        `json:"first_cluster"`
+		DataLength   uint64        `json:"data_length"`
+	}{
+		Type:         abde.TypeName(),
+		EntryType:    abde.EntryType.Info(),
+		BitmapFlags:  abde.BitmapFlags,
+		FirstCluster: abde.FirstCluster,
+		DataLength:   abde.DataLength,
+	}
+
+	return json.Marshal(encodable)
+}
+
+// MarshalJSON returns the decoded entry as JSON.
+func (utde ExfatUpcaseTableDirectoryEntry) MarshalJSON() ([]byte, error) {
+	encodable := struct {
+		Type          string        `json:"type"`
+		EntryType     EntryTypeInfo `json:"entry_type"`
+		TableChecksum uint32        `json:"table_checksum"`
+		FirstCluster  uint32        `json:"first_cluster"`
+		DataLength    uint64        `json:"data_length"`
+	}{
+		Type:          utde.TypeName(),
+		EntryType:     utde.EntryType.Info(),
+		TableChecksum: utde.TableChecksum,
+		FirstCluster:  utde.FirstCluster,
+		DataLength:    utde.DataLength,
+	}
+
+	return json.Marshal(encodable)
+}
+
+// MarshalJSON returns the decoded entry, including the label, as JSON.
+func (vlde ExfatVolumeLabelDirectoryEntry) MarshalJSON() ([]byte, error) {
+	encodable := struct {
+		Type           string        `json:"type"`
+		EntryType      EntryTypeInfo `json:"entry_type"`
+		CharacterCount uint8         `json:"character_count"`
+		Label          string        `json:"label"`
+	}{
+		Type:           vlde.TypeName(),
+		EntryType:      vlde.EntryType.Info(),
+		CharacterCount: vlde.CharacterCount,
+		Label:          vlde.Label(),
+	}
+
+	return json.Marshal(encodable)
+}
+
+// MarshalJSON returns the decoded entry, including the GUID in its canonical
+// form, as JSON.
+func (vgde ExfatVolumeGuidDirectoryEntry) MarshalJSON() ([]byte, error) {
+	encodable := struct {
+		Type                string                  `json:"type"`
+		EntryType           EntryTypeInfo           `json:"entry_type"`
+		SecondaryCount      uint8                   `json:"secondary_count"`
+		SetChecksum         uint16                  `json:"set_checksum"`
+		GeneralPrimaryFlags GeneralPrimaryFlagsInfo `json:"general_primary_flags"`
+		VolumeGuid          string                  `json:"volume_guid"`
+	}{
+		Type:                vgde.TypeName(),
+		EntryType:           vgde.EntryType.Info(),
+		SecondaryCount:      vgde.SecondaryCount(),
+		SetChecksum:         vgde.SetChecksum,
+		GeneralPrimaryFlags: vgde.GeneralPrimaryFlags.Info(),
+		VolumeGuid:          FormatGuid(vgde.VolumeGuid),
+	}
+
+	return json.Marshal(encodable)
+}
+
+// MarshalJSON returns the decoded entry as JSON.
+func (tfde ExfatTexFATDirectoryEntry) MarshalJSON() ([]byte, error) {
+	encodable := struct {
+		Type                string                  `json:"type"`
+		EntryType           EntryTypeInfo           `json:"entry_type"`
+		SecondaryCount      uint8                   `json:"secondary_count"`
+		SetChecksum         uint16                  `json:"set_checksum"`
+		GeneralPrimaryFlags GeneralPrimaryFlagsInfo `json:"general_primary_flags"`
+		FirstCluster        uint32                  `json:"first_cluster"`
+		DataLength          uint64                  `json:"data_length"`
+	}{
+		Type:                tfde.TypeName(),
+		EntryType:           tfde.EntryType.Info(),
+		SecondaryCount:      tfde.SecondaryCount(),
+		SetChecksum:         tfde.SetChecksum,
+		GeneralPrimaryFlags: tfde.GeneralPrimaryFlags.Info(),
+		FirstCluster:        tfde.FirstCluster,
+		DataLength:          tfde.DataLength,
+	}
+
+	return json.Marshal(encodable)
+}
+
+// MarshalJSON returns the decoded entry as JSON.
+func (sede ExfatStreamExtensionDirectoryEntry) MarshalJSON() ([]byte, error) {
+	encodable := struct {
+		Type                  string                    `json:"type"`
+		EntryType             EntryTypeInfo             `json:"entry_type"`
+		GeneralSecondaryFlags GeneralSecondaryFlagsInfo `json:"general_secondary_flags"`
+		NameLength            uint8                     `json:"name_length"`
+		NameHash              uint16                    `json:"name_hash"`
+		ValidDataLength       uint64                    `json:"valid_data_length"`
+		FirstCluster          uint32                    `json:"first_cluster"`
+		DataLength            uint64                    `json:"data_length"`
+	}{
+		Type:                  sede.TypeName(),
+		EntryType:             sede.EntryType.Info(),
+		GeneralSecondaryFlags: sede.GeneralSecondaryFlags.Info(),
+		NameLength:            sede.NameLength,
+		NameHash:              sede.NameHash,
+		ValidDataLength:       sede.ValidDataLength,
+		FirstCluster:          sede.FirstCluster,
+		DataLength:            sede.DataLength,
+	}
+
+	return json.Marshal(encodable)
+}
+
+// MarshalJSON returns the decoded entry, including its part of the filename,
+// as JSON.
+func (fnde ExfatFileNameDirectoryEntry) MarshalJSON() ([]byte, error) {
+	encodable := struct {
+		Type                  string                    `json:"type"`
+		EntryType             EntryTypeInfo             `json:"entry_type"`
+		GeneralSecondaryFlags GeneralSecondaryFlagsInfo `json:"general_secondary_flags"`
+		FileName              string                    `json:"file_name"`
+	}{
+		Type:                  fnde.TypeName(),
+		EntryType:             fnde.EntryType.Info(),
+		GeneralSecondaryFlags: fnde.GeneralSecondaryFlags.Info(),
+		FileName:              UnicodeFromAscii(fnde.FileName[:], fileNameEntryUnitCount),
+	}
+
+	return json.Marshal(encodable)
+}
+
+// MarshalJSON returns the decoded entry as JSON. The vendor-defined data is
+// hex-encoded.
+func (vede ExfatVendorExtensionDirectoryEntry) MarshalJSON() ([]byte, error) {
+	encodable := struct {
+		Type                  string                    `json:"type"`
+		EntryType             EntryTypeInfo             `json:"entry_type"`
+		GeneralSecondaryFlags GeneralSecondaryFlagsInfo `json:"general_secondary_flags"`
+		VendorGuid            string                    `json:"vendor_guid"`
+		VendorDefined         string                    `json:"vendor_defined"`
+	}{
+		Type:                  vede.TypeName(),
+		EntryType:             vede.EntryType.Info(),
+		GeneralSecondaryFlags: vede.GeneralSecondaryFlags.Info(),
+		VendorGuid:            FormatGuid(vede.VendorGuid),
+		VendorDefined:         hex.EncodeToString(vede.VendorDefined[:]),
+	}
+
+	return json.Marshal(encodable)
+}
+
+// MarshalJSON returns the decoded entry as JSON. The vendor-defined data is
+// hex-encoded.
+func (vade ExfatVendorAllocationDirectoryEntry) MarshalJSON() ([]byte, error) {
+	encodable := struct {
+		Type                  string                    `json:"type"`
+		EntryType             EntryTypeInfo             `json:"entry_type"`
+		GeneralSecondaryFlags GeneralSecondaryFlagsInfo `json:"general_secondary_flags"`
+		VendorGuid            string                    `json:"vendor_guid"`
+		VendorDefined         string                    `json:"vendor_defined"`
+		FirstCluster          uint32                    `json:"first_cluster"`
+		DataLength            uint64                    `json:"data_length"`
+	}{
+		Type:                  vade.TypeName(),
+		EntryType:             vade.EntryType.Info(),
+		GeneralSecondaryFlags: vade.GeneralSecondaryFlags.Info(),
+		VendorGuid:            FormatGuid(vade.VendorGuid),
+		VendorDefined:         hex.EncodeToString(vade.VendorDefined[:]),
+		FirstCluster:          vade.FirstCluster,
+		DataLength:            vade.DataLength,
+	}
+
+	return json.Marshal(encodable)
+}
+
+// MarshalJSON returns the entries of the set, decoded, along with the
+// complete filename and where the primary entry is stored.
+func (ide IndexedDirectoryEntry) MarshalJSON() ([]byte, error) {
+	secondaryEntries := ide.SecondaryEntries
+	if secondaryEntries == nil {
+		secondaryEntries = make([]DirectoryEntry, 0)
+	}
+
+	encodable := struct {
+		Filename         string                 `json:"filename,omitempty"`
+		Location         EntryLocation          `json:"location"`
+		PrimaryEntry     DirectoryEntry         `json:"primary_entry"`
+		SecondaryEntries []DirectoryEntry       `json:"secondary_entries"`
+		Extra            map[string]interface{} `json:"extra,omitempty"`
+	}{
+		Filename:         ide.Filename,
+		Location:         ide.Location,
+		PrimaryEntry:     ide.PrimaryEntry,
+		SecondaryEntries: secondaryEntries,
+		Extra:            ide.ExtraData(),
+	}
+
+	return json.Marshal(encodable)
+}
+
+// MarshalJSON returns the index as a JSON object keyed by type-name.
+func (dei DirectoryEntryIndex) MarshalJSON() ([]byte, error) {
+	// The conversion drops the method so that this doesn't recurse.
+	return json.Marshal(map[string][]IndexedDirectoryEntry(dei))
+}
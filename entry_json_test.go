@@ -0,0 +1,117 @@
+package exfat
+
+import (
+	"testing"
+	"time"
+
+	"encoding/json"
+
+	"github.com/dsoprea/go-logging"
+)
+
+func TestDirectoryEntryIndex_MarshalJSON(t *testing.T) {
+	f, er := getTestFileAndParser()
+
+	defer f.Close()
+
+	err := er.Parse()
+	log.PanicIf(err)
+
+	en := NewExfatNavigator(er, er.FirstClusterOfRootDirectory())
+
+	index, _, _, err := en.IndexDirectoryEntries()
+	log.PanicIf(err)
+
+	encoded, err := json.Marshal(index)
+	log.PanicIf(err)
+
+	decoded := make(map[string][]map[string]interface{})
+
+	err = json.Unmarshal(encoded, &decoded)
+	log.PanicIf(err)
+
+	if len(decoded["AllocationBitmap"]) != 1 || len(decoded["UpcaseTable"]) != 1 {
+		t.Fatalf("Critical entries not encoded: %v", decoded)
+	}
+
+	abde := decoded["AllocationBitmap"][0]["primary_entry"].(map[string]interface{})
+	if abde["type"].(string) != "AllocationBitmap" || abde["first_cluster"].(float64) != 2 {
+		t.Fatalf("Allocation-bitmap entry not correct: %v", abde)
+	}
+
+	var jpg map[string]interface{}
+	for _, ide := range decoded["File"] {
+		if ide["filename"].(string) == "2-delahaye-type-165-cabriolet-dsc_8025.jpg" {
+			jpg = ide
+		}
+	}
+
+	if jpg == nil {
+		t.Fatalf("File not encoded.")
+	}
+
+	ide, found := index.FindIndexedFile("2-delahaye-type-165-cabriolet-dsc_8025.jpg")
+	if found != true {
+		t.Fatalf("File not indexed.")
+	}
+
+	location := jpg["location"].(map[string]interface{})
+	if int64(location["offset"].(float64)) != ide.Location.Offset {
+		t.Fatalf("Location not correct: %v", location)
+	}
+
+	fdf := jpg["primary_entry"].(map[string]interface{})
+	if fdf["type"].(string) != "File" || fdf["secondary_count"].(float64) != 4 {
+		t.Fatalf("File entry not correct: %v", fdf)
+	} else if fdf["file_attributes"].(map[string]interface{})["is_directory"].(bool) != false {
+		t.Fatalf("Attributes not correct: %v", fdf)
+	}
+
+	_, err = time.Parse(time.RFC3339Nano, fdf["last_modified_timestamp"].(string))
+	log.PanicIf(err)
+
+	secondaryEntries := jpg["secondary_entries"].([]interface{})
+
+	sede := secondaryEntries[0].(map[string]interface{})
+	if sede["type"].(string) != "StreamExtension" || sede["data_length"].(float64) != 313299 {
+		t.Fatalf("Stream-extension entry not correct: %v", sede)
+	}
+
+	fnde := secondaryEntries[1].(map[string]interface{})
+	if fnde["type"].(string) != "FileName" || fnde["file_name"].(string) != "2-delahaye-type" {
+		t.Fatalf("Filename entry not correct: %v", fnde)
+	}
+}
+
+func TestExfatVolumeGuidDirectoryEntry_MarshalJSON(t *testing.T) {
+	vgde := ExfatVolumeGuidDirectoryEntry{
+		EntryType:  0xa0,
+		VolumeGuid: [16]byte{0x78, 0x56, 0x34, 0x12, 0x34, 0x12, 0x34, 0x12, 0x12, 0x34, 0x56, 0x78, 0x9a, 0xbc, 0xde, 0xf0},
+
+		GeneralPrimaryFlags: 2,
+	}
+
+	encoded, err := json.Marshal(vgde)
+	log.PanicIf(err)
+
+	decoded := make(map[string]interface{})
+
+	err = json.Unmarshal(encoded, &decoded)
+	log.PanicIf(err)
+
+	if decoded["volume_guid"].(string) != FormatGuid(vgde.VolumeGuid) {
+		t.Fatalf("GUID not correct: %v", decoded)
+	} else if decoded["entry_type"].(map[string]interface{})["is_primary"].(bool) != true {
+		t.Fatalf("Entry-type not correct: %v", decoded)
+	}
+
+	flags := decoded["general_primary_flags"].(map[string]interface{})
+
+	if flags["raw"].(float64) != 2 {
+		t.Fatalf("Raw flags not correct: %v", flags)
+	} else if flags["is_allocation_possible"].(bool) != false {
+		t.Fatalf("IsAllocationPossible not correct: %v", flags)
+	} else if flags["no_fat_chain"].(bool) != true {
+		t.Fatalf("NoFatChain not correct: %v", flags)
+	}
+}
@@ -0,0 +1,170 @@
+// This package exposes a volume over SFTP by implementing the request-server
+// handlers of `github.com/pkg/sftp`. It's kept out of the main package so that
+// the SFTP and SSH dependencies are only pulled in by programs that use it.
+//
+// The SSH side (host keys, authentication, and accepting the "sftp"
+// subsystem) is up to the caller. Once a channel has been accepted:
+//
+//	server := sftp.NewRequestServer(channel, exfatsftp.NewHandlers(tree))
+//
+//	err := server.Serve()
+//
+// The volume is read-only: uploads and any command that would change it are
+// refused with a permission-denied status.
+
+package exfatsftp
+
+import (
+	"io"
+	"os"
+	"sort"
+
+	"github.com/dsoprea/go-logging"
+	"github.com/pkg/sftp"
+
+	"github.com/dsoprea/go-exfat"
+)
+
+// fileInfoLister is a fixed list of entries.
+type fileInfoLister []os.FileInfo
+
+// ListAt copies the entries starting at the given offset. It returns io.EOF
+// along with the last of them.
+func (fil fileInfoLister) ListAt(entries []os.FileInfo, offset int64) (n int, err error) {
+	if offset >= int64(len(fil)) {
+		return 0, io.EOF
+	}
+
+	n = copy(entries, fil[offset:])
+
+	if offset+int64(n) >= int64(len(fil)) {
+		return n, io.EOF
+	}
+
+	return n, nil
+}
+
+// TreeHandlers serves SFTP requests from a tree.
+type TreeHandlers struct {
+	tree *exfat.Tree
+}
+
+// NewHandlers returns the handlers for serving the given tree read-only. The
+// tree may be loaded or not; directories are loaded as they're browsed.
+func NewHandlers(tree *exfat.Tree) sftp.Handlers {
+	th := &TreeHandlers{
+		tree: tree,
+	}
+
+	return sftp.Handlers{
+		FileGet:  th,
+		FilePut:  th,
+		FileCmd:  th,
+		FileList: th,
+	}
+}
+
+// lookup returns the node for the requested path or nil if it doesn't exist
+// or was deleted.
+func (th *TreeHandlers) lookup(r *sftp.Request) (node *exfat.TreeNode, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	node, err = th.tree.LookupPath(r.Filepath)
+	log.PanicIf(err)
+
+	if node == nil || node.IsInUse() == false {
+		return nil, nil
+	}
+
+	return node, nil
+}
+
+// Fileread returns a reader for the file's data. Reads only follow the chain
+// as far as they need to.
+func (th *TreeHandlers) Fileread(r *sftp.Request) (ra io.ReaderAt, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	node, err := th.lookup(r)
+	log.PanicIf(err)
+
+	if node == nil {
+		return nil, os.ErrNotExist
+	} else if node.IsDirectory() == true {
+		log.Panicf("path is a directory: [%s]", r.Filepath)
+	}
+
+	cr, err := node.NewReader()
+	log.PanicIf(err)
+
+	return cr, nil
+}
+
+// Filewrite refuses all uploads.
+func (th *TreeHandlers) Filewrite(r *sftp.Request) (wa io.WriterAt, err error) {
+	return nil, sftp.ErrSSHFxPermissionDenied
+}
+
+// Filecmd refuses every command (e.g. rename, remove, or mkdir), since they
+// would all change the volume.
+func (th *TreeHandlers) Filecmd(r *sftp.Request) (err error) {
+	return sftp.ErrSSHFxPermissionDenied
+}
+
+// Filelist returns the entries of a directory ("List") or the entry for a
+// single path ("Stat"). Deleted entries aren't included. There are no
+// symlinks on exFAT, so "Readlink" isn't supported.
+func (th *TreeHandlers) Filelist(r *sftp.Request) (la sftp.ListerAt, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	switch r.Method {
+	case "List", "Stat":
+	default:
+		return nil, sftp.ErrSSHFxOpUnsupported
+	}
+
+	node, err := th.lookup(r)
+	log.PanicIf(err)
+
+	if node == nil {
+		return nil, os.ErrNotExist
+	}
+
+	if r.Method == "Stat" {
+		return fileInfoLister{node.Stat()}, nil
+	}
+
+	if node.IsDirectory() == false {
+		log.Panicf("path is not a directory: [%s]", r.Filepath)
+	}
+
+	names := make([]string, 0)
+	names = append(names, node.ChildFolders()...)
+	names = append(names, node.ChildFiles()...)
+
+	sort.Strings(names)
+
+	entries := make(fileInfoLister, 0, len(names))
+	for _, name := range names {
+		child := node.GetChild(name)
+
+		if child.IsInUse() == false {
+			continue
+		}
+
+		entries = append(entries, child.Stat())
+	}
+
+	return entries, nil
+}
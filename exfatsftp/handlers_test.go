@@ -0,0 +1,163 @@
+package exfatsftp
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/dsoprea/go-logging"
+	"github.com/pkg/sftp"
+
+	"github.com/dsoprea/go-exfat"
+)
+
+// pipeConn is one end of a pair of pipes.
+type pipeConn struct {
+	io.Reader
+	io.WriteCloser
+}
+
+// getTestClient serves the test image with a request-server and returns a
+// client connected to it.
+func getTestClient() (client *sftp.Client, closer func()) {
+	f, err := os.Open(path.Join("..", "test", "assets", "test.exfat"))
+	log.PanicIf(err)
+
+	er := exfat.NewExfatReader(f)
+
+	err = er.Parse()
+	log.PanicIf(err)
+
+	tree := exfat.NewTree(er)
+
+	clientReader, serverWriter := io.Pipe()
+	serverReader, clientWriter := io.Pipe()
+
+	server := sftp.NewRequestServer(pipeConn{serverReader, serverWriter}, NewHandlers(tree))
+
+	go server.Serve()
+
+	client, err = sftp.NewClientPipe(clientReader, clientWriter)
+	log.PanicIf(err)
+
+	closer = func() {
+		// Closing the server ends the client's receive-loop.
+		server.Close()
+		client.Close()
+		f.Close()
+	}
+
+	return client, closer
+}
+
+func TestNewHandlers__ReadDir(t *testing.T) {
+	client, closer := getTestClient()
+
+	defer closer()
+
+	fis, err := client.ReadDir("/")
+	log.PanicIf(err)
+
+	names := make([]string, len(fis))
+	for i, fi := range fis {
+		names[i] = fi.Name()
+	}
+
+	expected := []string{
+		"064cbfd4-cec3-11e9-926d-c362c80fab7b",
+		"2-delahaye-type-165-cabriolet-dsc_8025.jpg",
+		"79c6d31a-cca1-11e9-8325-9746d045e868",
+		"testdirectory",
+		"testdirectory2",
+		"testdirectory3",
+	}
+
+	if len(names) != len(expected) {
+		t.Fatalf("Listing not correct: %v", names)
+	}
+
+	for i, name := range names {
+		if name != expected[i] {
+			t.Fatalf("Listing not correct: %v", names)
+		}
+	}
+
+	if fis[3].IsDir() != true || fis[1].IsDir() != false {
+		t.Fatalf("Types not correct.")
+	} else if fis[1].Size() != 313299 {
+		t.Fatalf("Size not correct: (%d)", fis[1].Size())
+	}
+}
+
+func TestNewHandlers__Stat(t *testing.T) {
+	client, closer := getTestClient()
+
+	defer closer()
+
+	fi, err := client.Stat("/testdirectory")
+	log.PanicIf(err)
+
+	if fi.IsDir() != true {
+		t.Fatalf("Expected directory.")
+	}
+
+	_, err = client.Stat("/testdirectory/not-there")
+	if os.IsNotExist(err) != true {
+		t.Fatalf("Expected not-exist error: %v", err)
+	}
+}
+
+func TestNewHandlers__Read(t *testing.T) {
+	client, closer := getTestClient()
+
+	defer closer()
+
+	f, err := os.Open(path.Join("..", "test", "assets", "test.exfat"))
+	log.PanicIf(err)
+
+	defer f.Close()
+
+	er := exfat.NewExfatReader(f)
+
+	err = er.Parse()
+	log.PanicIf(err)
+
+	expected, err := er.ReadFile("2-delahaye-type-165-cabriolet-dsc_8025.jpg")
+	log.PanicIf(err)
+
+	sf, err := client.Open("/2-delahaye-type-165-cabriolet-dsc_8025.jpg")
+	log.PanicIf(err)
+
+	defer sf.Close()
+
+	actual, err := ioutil.ReadAll(sf)
+	log.PanicIf(err)
+
+	if bytes.Equal(actual, expected) != true {
+		t.Fatalf("Data not correct.")
+	}
+}
+
+func TestNewHandlers__ReadOnly(t *testing.T) {
+	client, closer := getTestClient()
+
+	defer closer()
+
+	_, err := client.Create("/new-file")
+	if err == nil {
+		t.Fatalf("Expected create to fail.")
+	}
+
+	err = client.Mkdir("/new-directory")
+	if err == nil {
+		t.Fatalf("Expected mkdir to fail.")
+	}
+
+	err = client.Remove("/testdirectory2")
+	if err == nil {
+		t.Fatalf("Expected remove to fail.")
+	}
+}
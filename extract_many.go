@@ -0,0 +1,143 @@
+// This package supports extracting several files at once.
+
+package exfat
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/dsoprea/go-logging"
+)
+
+// ExtractResult is the outcome of extracting one file with ExtractMany().
+type ExtractResult struct {
+	// VolumePath is the path as it was requested.
+	VolumePath string
+
+	// HostPath is where the file was (or would have been) written.
+	HostPath string
+
+	// Size is the number of bytes that were written.
+	Size uint64
+
+	// Err is the error for this file, if any.
+	Err error
+}
+
+// String returns a descriptive string.
+func (result ExtractResult) String() string {
+	return fmt.Sprintf("ExtractResult<VOLUME-PATH=[%s] HOST-PATH=[%s] SIZE=(%d) ERR=[%v]>", result.VolumePath, result.HostPath, result.Size, result.Err)
+}
+
+// ExtractMany extracts the given files to `destDir`, reproducing their
+// directory structure under it, using up to `concurrency` goroutines. Every
+// path is resolved before any data is read. Failures are reported per-file in
+// the results (which are in the same order as `volumePaths`) rather than
+// stopping the other extractions; `err` is only returned if nothing could be
+// attempted. Concurrent reads are only uncontended if the underlying image
+// supports io.ReaderAt.
+func ExtractMany(tree *Tree, volumePaths []string, destDir string, concurrency int) (results []ExtractResult, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	err = os.MkdirAll(destDir, 0755)
+	log.PanicIf(err)
+
+	hpm := NewHostPathMapper(destDir, false)
+
+	// The tree loads directories lazily, so the lookups have to be done here
+	// rather than from the workers.
+
+	results = make([]ExtractResult, len(volumePaths))
+	nodes := make([]*TreeNode, len(volumePaths))
+	seen := make(map[string]bool)
+
+	for i, volumePath := range volumePaths {
+		results[i].VolumePath = volumePath
+
+		normalized := NormalizeVolumePath(volumePath)
+		if seen[normalized] == true {
+			results[i].Err = log.Errorf("path requested more than once: [%s]", volumePath)
+			continue
+		}
+
+		seen[normalized] = true
+
+		node, err := tree.LookupPath(volumePath)
+		if err != nil {
+			results[i].Err = err
+			continue
+		} else if node == nil {
+			results[i].Err = log.Errorf("path not found: [%s]", volumePath)
+			continue
+		} else if node.IsDirectory() == true {
+			results[i].Err = log.Errorf("path is a directory: [%s]", volumePath)
+			continue
+		}
+
+		hostPath, err := hpm.HostPath(normalized)
+		if err != nil {
+			results[i].Err = err
+			continue
+		}
+
+		results[i].HostPath = hostPath
+		nodes[i] = node
+	}
+
+	indices := make(chan int)
+
+	wg := new(sync.WaitGroup)
+
+	for j := 0; j < concurrency; j++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for i := range indices {
+				results[i].Err = extractOne(tree, nodes[i], results[i].HostPath)
+				if results[i].Err == nil {
+					results[i].Size = nodes[i].StreamDirectoryEntry().ValidDataLength
+				}
+			}
+		}()
+	}
+
+	for i, node := range nodes {
+		if node != nil {
+			indices <- i
+		}
+	}
+
+	close(indices)
+	wg.Wait()
+
+	return results, nil
+}
+
+// extractOne writes the data for one file.
+func extractOne(tree *Tree, node *TreeNode, hostPath string) (err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	err = os.MkdirAll(filepath.Dir(hostPath), 0755)
+	log.PanicIf(err)
+
+	err = syncFile(tree, node, hostPath)
+	log.PanicIf(err)
+
+	return nil
+}
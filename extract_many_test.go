@@ -0,0 +1,109 @@
+package exfat
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dsoprea/go-logging"
+)
+
+func testExtractMany(t *testing.T, tree *Tree) {
+	destDir, err := ioutil.TempDir("", "exfat-extract")
+	log.PanicIf(err)
+
+	defer os.RemoveAll(destDir)
+
+	volumePaths := []string{
+		"2-delahaye-type-165-cabriolet-dsc_8025.jpg",
+		"testdirectory2/00c57ab0-cec3-11e9-b750-bbed8d2244c8",
+		"testdirectory2/ff7b94be-cec2-11e9-b7b1-6b2e61bd775c",
+		"does-not-exist",
+		"testdirectory3",
+		`2-delahaye-type-165-cabriolet-dsc_8025.jpg`,
+	}
+
+	results, err := ExtractMany(tree, volumePaths, destDir, 4)
+	log.PanicIf(err)
+
+	if len(results) != len(volumePaths) {
+		t.Fatalf("Result count not correct: (%d)", len(results))
+	}
+
+	for i, result := range results[:3] {
+		if result.Err != nil {
+			t.Fatalf("Extraction (%d) failed: %v", i, result.Err)
+		} else if result.VolumePath != volumePaths[i] {
+			t.Fatalf("Result (%d) out of order: [%s]", i, result.VolumePath)
+		}
+
+		_, expectedData := getTestFileData(tree, result.VolumePath)
+
+		actualData, err := ioutil.ReadFile(result.HostPath)
+		log.PanicIf(err)
+
+		if bytes.Equal(actualData, expectedData) != true {
+			t.Fatalf("Extracted data not correct: [%s]", result.VolumePath)
+		} else if result.Size != uint64(len(expectedData)) {
+			t.Fatalf("Size not correct: [%s] (%d)", result.VolumePath, result.Size)
+		}
+	}
+
+	expectedHostPath := filepath.Join(destDir, "testdirectory2", "00c57ab0-cec3-11e9-b750-bbed8d2244c8")
+	if results[1].HostPath != expectedHostPath {
+		t.Fatalf("Host path not correct: [%s]", results[1].HostPath)
+	}
+
+	for i, result := range results[3:] {
+		if result.Err == nil {
+			t.Fatalf("Expected error for (%d): [%s]", i+3, result.VolumePath)
+		}
+	}
+}
+
+func TestExtractMany(t *testing.T) {
+	tree, closer := getTestTree()
+
+	defer closer()
+
+	testExtractMany(t, tree)
+}
+
+// readSeekerOnly hides any other interfaces (e.g. io.ReaderAt) of the
+// wrapped reader.
+type readSeekerOnly struct {
+	rs io.ReadSeeker
+}
+
+func (rso readSeekerOnly) Read(data []byte) (n int, err error) {
+	return rso.rs.Read(data)
+}
+
+func (rso readSeekerOnly) Seek(offset int64, whence int) (int64, error) {
+	return rso.rs.Seek(offset, whence)
+}
+
+func TestExtractMany__NoReaderAt(t *testing.T) {
+	f, _ := getTestFileAndParser()
+
+	defer f.Close()
+
+	er := NewExfatReader(readSeekerOnly{rs: f})
+
+	err := er.Parse()
+	log.PanicIf(err)
+
+	if er.ra != nil {
+		t.Fatalf("Expected no ReaderAt.")
+	}
+
+	tree := NewTree(er)
+
+	err = tree.Load()
+	log.PanicIf(err)
+
+	testExtractMany(t, tree)
+}
@@ -0,0 +1,89 @@
+// This package compares the two FATs of a TexFAT volume.
+
+package exfat
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/dsoprea/go-logging"
+)
+
+var (
+	// ErrSingleFat indicates that the volume only has one FAT, so there is
+	// nothing to compare it with.
+	ErrSingleFat = errors.New("volume only has one FAT")
+)
+
+// FatMismatch is a cluster whose entry differs between the two FATs.
+type FatMismatch struct {
+	ClusterNumber uint32        `json:"cluster_number" yaml:"cluster_number"`
+	FirstFat      MappedCluster `json:"first_fat" yaml:"first_fat"`
+	SecondFat     MappedCluster `json:"second_fat" yaml:"second_fat"`
+}
+
+// String returns a descriptive string.
+func (fm FatMismatch) String() string {
+	return fmt.Sprintf("FatMismatch<CLUSTER=(%d) FIRST=(0x%08x) SECOND=(0x%08x)>", fm.ClusterNumber, uint32(fm.FirstFat), uint32(fm.SecondFat))
+}
+
+// compareLazyFats returns every cluster whose entries differ.
+func compareLazyFats(first, second *LazyFat) (mismatches []FatMismatch, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	if first.EntryCount() != second.EntryCount() {
+		log.Panicf("FATs have different entry-counts: (%d) != (%d)", first.EntryCount(), second.EntryCount())
+	}
+
+	mismatches = make([]FatMismatch, 0)
+
+	lastClusterNumber := first.EntryCount() + 1
+	for clusterNumber := uint32(2); clusterNumber <= lastClusterNumber; clusterNumber++ {
+		firstEntry, err := first.Entry(clusterNumber)
+		log.PanicIf(err)
+
+		secondEntry, err := second.Entry(clusterNumber)
+		log.PanicIf(err)
+
+		if firstEntry != secondEntry {
+			fm := FatMismatch{
+				ClusterNumber: clusterNumber,
+				FirstFat:      firstEntry,
+				SecondFat:     secondEntry,
+			}
+
+			mismatches = append(mismatches, fm)
+		}
+	}
+
+	return mismatches, nil
+}
+
+// CompareFats returns every cluster whose entry differs between the first and
+// second FATs. Only TexFAT volumes have a second FAT, and the inactive one is
+// considered stale by the spec, but after an interrupted transaction a repair
+// tool may need to know which FAT to trust. ErrSingleFat is returned if there
+// is only one FAT.
+func (er *ExfatReader) CompareFats() (mismatches []FatMismatch, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	fats, err := er.Fats()
+	log.PanicIf(err)
+
+	if len(fats) < 2 {
+		return nil, ErrSingleFat
+	}
+
+	mismatches, err = compareLazyFats(fats[0], fats[1])
+	log.PanicIf(err)
+
+	return mismatches, nil
+}
@@ -0,0 +1,91 @@
+package exfat
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/dsoprea/go-logging"
+)
+
+func TestExfatReader_CompareFats__SingleFat(t *testing.T) {
+	f, er := getTestFileAndParser()
+
+	defer f.Close()
+
+	err := er.Parse()
+	log.PanicIf(err)
+
+	fats, err := er.Fats()
+	log.PanicIf(err)
+
+	if len(fats) != 1 {
+		t.Fatalf("Expected one FAT: (%d)", len(fats))
+	}
+
+	_, err = er.CompareFats()
+	if err != ErrSingleFat {
+		t.Fatalf("Expected ErrSingleFat: [%v]", err)
+	}
+}
+
+func TestCompareLazyFats(t *testing.T) {
+	image, er := getTestImage()
+
+	// Put a copy of the FAT after the end of the volume and change two of its
+	// entries.
+
+	bsh := er.ActiveBootSectorHeader()
+
+	fatOffset := int64(bsh.FatOffset) * int64(bsh.SectorSize())
+	fatSize := int64(bsh.FatLength) * int64(bsh.SectorSize())
+
+	copyOffset := int64(len(image))
+
+	image = append(image, image[fatOffset:fatOffset+fatSize]...)
+
+	defaultEncoding.PutUint32(image[copyOffset+5*4:], 0xfffffff7)
+	defaultEncoding.PutUint32(image[copyOffset+200*4:], 0x12345678)
+
+	er = NewExfatReader(bytes.NewReader(image))
+
+	err := er.Parse()
+	log.PanicIf(err)
+
+	first, err := er.ActiveFat()
+	log.PanicIf(err)
+
+	original5, err := first.Entry(5)
+	log.PanicIf(err)
+
+	original200, err := first.Entry(200)
+	log.PanicIf(err)
+
+	second := newLazyFat(er, copyOffset)
+
+	mismatches, err := compareLazyFats(first, second)
+	log.PanicIf(err)
+
+	expected := []FatMismatch{
+		{ClusterNumber: 5, FirstFat: original5, SecondFat: 0xfffffff7},
+		{ClusterNumber: 200, FirstFat: original200, SecondFat: 0x12345678},
+	}
+
+	if len(mismatches) != len(expected) {
+		t.Fatalf("Mismatches not correct: %v", mismatches)
+	}
+
+	for i, fm := range mismatches {
+		if fm != expected[i] {
+			t.Fatalf("Mismatch (%d) not correct: %s", i, fm)
+		}
+	}
+
+	// A FAT is the same as itself.
+
+	mismatches, err = compareLazyFats(first, first)
+	log.PanicIf(err)
+
+	if len(mismatches) != 0 {
+		t.Fatalf("Expected no mismatches: %v", mismatches)
+	}
+}
@@ -0,0 +1,201 @@
+// This package provides one-call access to single files for callers that
+// don't need to work with the tree or navigator directly.
+
+package exfat
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/dsoprea/go-logging"
+)
+
+var (
+	// ErrFileNotFound is returned by ReadFile and Stat when the path does not
+	// exist (or was deleted).
+	ErrFileNotFound = errors.New("file not found")
+)
+
+// FileStat is the metadata of one file or directory.
+type FileStat struct {
+	// Name is the last component of the path. It is empty for the root.
+	Name string `json:"name"`
+
+	// Path is the complete, backslash-separated path.
+	Path string `json:"path"`
+
+	IsDirectory  bool           `json:"is_directory"`
+	Attributes   FileAttributes `json:"attributes"`
+	Size         uint64         `json:"size"`
+	ModifiedTime time.Time      `json:"modified_time"`
+	CreatedTime  time.Time      `json:"created_time"`
+	AccessedTime time.Time      `json:"accessed_time"`
+}
+
+// String returns a descriptive string.
+func (fs FileStat) String() string {
+	return fmt.Sprintf("FileStat<PATH=[%s] IS-DIRECTORY=[%v] SIZE=(%d) MTIME=[%s]>", fs.Path, fs.IsDirectory, fs.Size, fs.ModifiedTime)
+}
+
+// lookupInUse finds the node for the given volume path, only loading the
+// directories along the way. Deleted entries are treated as missing.
+func (er *ExfatReader) lookupInUse(volumePath string) (pathParts []string, node *TreeNode, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	tree := NewTree(er)
+
+	pathParts = SplitVolumePath(volumePath)
+
+	node, err = tree.Lookup(pathParts)
+	log.PanicIf(err)
+
+	if node == nil || (len(pathParts) > 0 && node.IsInUse() == false) {
+		return nil, nil, ErrFileNotFound
+	}
+
+	return pathParts, node, nil
+}
+
+// ReadFile returns the contents of the file at the given volume path. Only the
+// directories along the path are read. The reader must already be parsed.
+// Returns ErrFileNotFound if there is no such file.
+func (er *ExfatReader) ReadFile(volumePath string) (data []byte, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	_, node, err := er.lookupInUse(volumePath)
+	if err == ErrFileNotFound {
+		return nil, err
+	}
+
+	log.PanicIf(err)
+
+	if node.IsDirectory() == true {
+		log.Panicf("path is a directory: [%s]", volumePath)
+	}
+
+	sede := node.StreamDirectoryEntry()
+
+	b := bytes.NewBuffer(make([]byte, 0, sede.ValidDataLength))
+
+	if sede.ValidDataLength > 0 {
+		useFat := sede.GeneralSecondaryFlags.NoFatChain() == false
+
+		_, _, err = er.WriteFromClusterChain(sede.FirstCluster, sede.ValidDataLength, useFat, b)
+		log.PanicIf(err)
+	}
+
+	return b.Bytes(), nil
+}
+
+// Stat returns the metadata of the file or directory at the given volume path.
+// An empty path describes the root directory, which has no timestamps. The
+// reader must already be parsed. Returns ErrFileNotFound if there is no such
+// entry.
+func (er *ExfatReader) Stat(volumePath string) (fs *FileStat, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	pathParts, node, err := er.lookupInUse(volumePath)
+	if err == ErrFileNotFound {
+		return nil, err
+	}
+
+	log.PanicIf(err)
+
+	fs = &FileStat{
+		Name:        node.Name(),
+		Path:        JoinVolumePath(pathParts),
+		IsDirectory: node.IsDirectory(),
+	}
+
+	if fde := node.FileDirectoryEntry(); fde != nil {
+		fs.Attributes = fde.FileAttributes
+		fs.ModifiedTime = fde.LastModifiedTimestamp()
+		fs.CreatedTime = fde.CreateTimestamp()
+		fs.AccessedTime = fde.LastAccessedTimestamp()
+	}
+
+	if sede := node.StreamDirectoryEntry(); sede != nil && node.IsDirectory() == false {
+		fs.Size = sede.ValidDataLength
+	}
+
+	return fs, nil
+}
+
+// ReadFile parses the image and returns the contents of the file at the given
+// volume path. To read many files, parse once and use a Tree instead.
+func ReadFile(rs io.ReadSeeker, volumePath string) (data []byte, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	// The image may have been read from before.
+	_, err = rs.Seek(0, os.SEEK_SET)
+	log.PanicIf(err)
+
+	er := NewExfatReader(rs)
+
+	// Small files often don't use the FAT, so only parse it if it's needed.
+	er.SetDeferFatParsing(true)
+
+	err = er.Parse()
+	log.PanicIf(err)
+
+	data, err = er.ReadFile(volumePath)
+	if err == ErrFileNotFound {
+		return nil, err
+	}
+
+	log.PanicIf(err)
+
+	return data, nil
+}
+
+// Stat parses the image and returns the metadata of the file or directory at
+// the given volume path.
+func Stat(rs io.ReadSeeker, volumePath string) (fs *FileStat, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	// The image may have been read from before.
+	_, err = rs.Seek(0, os.SEEK_SET)
+	log.PanicIf(err)
+
+	er := NewExfatReader(rs)
+
+	// Only the directory entries are needed, and they don't necessarily use
+	// the FAT.
+	er.SetDeferFatParsing(true)
+
+	err = er.Parse()
+	log.PanicIf(err)
+
+	fs, err = er.Stat(volumePath)
+	if err == ErrFileNotFound {
+		return nil, err
+	}
+
+	log.PanicIf(err)
+
+	return fs, nil
+}
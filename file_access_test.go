@@ -0,0 +1,127 @@
+package exfat
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/dsoprea/go-logging"
+)
+
+func TestReadFile(t *testing.T) {
+	f, err := os.Open(path.Join(assetPath, "test.exfat"))
+	log.PanicIf(err)
+
+	defer f.Close()
+
+	data, err := ReadFile(f, "2-delahaye-type-165-cabriolet-dsc_8025.jpg")
+	log.PanicIf(err)
+
+	if len(data) != 313299 {
+		t.Fatalf("Size not correct: (%d)", len(data))
+	}
+
+	digest := fmt.Sprintf("%x", sha1.Sum(data))
+	if digest != "a2219fa800ae2325003d8d4f5122b37f12f1e18e" {
+		t.Fatalf("Hash not correct: [%s]", digest)
+	}
+}
+
+func TestReadFile__Miss(t *testing.T) {
+	f, err := os.Open(path.Join(assetPath, "test.exfat"))
+	log.PanicIf(err)
+
+	defer f.Close()
+
+	_, err = ReadFile(f, "testdirectory2/invalid_file")
+	if err != ErrFileNotFound {
+		t.Fatalf("Expected not-found error: %v", err)
+	}
+
+	// Deleted files are not found.
+	_, err = ReadFile(f, "8fd71ab132c59bf33cd7890c0acebf12.jpg")
+	if err != ErrFileNotFound {
+		t.Fatalf("Expected not-found error for deleted file: %v", err)
+	}
+}
+
+func TestExfatReader_ReadFile(t *testing.T) {
+	f, er := getTestFileAndParser()
+
+	defer f.Close()
+
+	err := er.Parse()
+	log.PanicIf(err)
+
+	data, err := er.ReadFile(`testdirectory3\10422c86-cec3-11e9-953f-4f501efd2640`)
+	log.PanicIf(err)
+
+	tree := NewTree(er)
+
+	_, expected := getTestFileData(tree, `testdirectory3\10422c86-cec3-11e9-953f-4f501efd2640`)
+
+	if string(data) != string(expected) {
+		t.Fatalf("Data not correct: [%s]", string(data))
+	}
+
+	_, err = er.ReadFile("testdirectory3")
+	if err == nil {
+		t.Fatalf("Expected error for directory.")
+	}
+}
+
+func TestStat(t *testing.T) {
+	f, err := os.Open(path.Join(assetPath, "test.exfat"))
+	log.PanicIf(err)
+
+	defer f.Close()
+
+	fs, err := Stat(f, "/testdirectory2/00c57ab0-cec3-11e9-b750-bbed8d2244c8")
+	log.PanicIf(err)
+
+	if fs.Name != "00c57ab0-cec3-11e9-b750-bbed8d2244c8" {
+		t.Fatalf("Name not correct: [%s]", fs.Name)
+	} else if fs.Path != `testdirectory2\00c57ab0-cec3-11e9-b750-bbed8d2244c8` {
+		t.Fatalf("Path not correct: [%s]", fs.Path)
+	} else if fs.IsDirectory != false {
+		t.Fatalf("Expected file.")
+	} else if fs.Size == 0 {
+		t.Fatalf("Size not correct.")
+	} else if fs.ModifiedTime.IsZero() == true {
+		t.Fatalf("Modified time not set.")
+	}
+}
+
+func TestExfatReader_Stat(t *testing.T) {
+	f, er := getTestFileAndParser()
+
+	defer f.Close()
+
+	err := er.Parse()
+	log.PanicIf(err)
+
+	fs, err := er.Stat("testdirectory")
+	log.PanicIf(err)
+
+	if fs.IsDirectory != true {
+		t.Fatalf("Expected directory.")
+	} else if fs.Attributes.IsDirectory() != true {
+		t.Fatalf("Attributes not correct: %s", fs.Attributes)
+	} else if fs.Size != 0 {
+		t.Fatalf("Expected no size for directory: (%d)", fs.Size)
+	}
+
+	fs, err = er.Stat("")
+	log.PanicIf(err)
+
+	if fs.IsDirectory != true || fs.Path != "" {
+		t.Fatalf("Root not correct: %s", fs)
+	}
+
+	_, err = er.Stat("testdirectory/invalid_file")
+	if err != ErrFileNotFound {
+		t.Fatalf("Expected not-found error: %v", err)
+	}
+}
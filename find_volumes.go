@@ -0,0 +1,88 @@
+// This package supports finding volumes inside larger images.
+
+package exfat
+
+import (
+	"bytes"
+	"io"
+	"os"
+
+	"github.com/dsoprea/go-logging"
+)
+
+const (
+	// findVolumesAlignment is the granularity at which boot sectors are
+	// looked for. Volumes always start on a 512-byte boundary, whatever their
+	// sector-size.
+	findVolumesAlignment = 512
+
+	// findVolumesChunkSize is how much is read at a time while scanning.
+	findVolumesChunkSize = 1024 * 1024
+
+	// backupBootSectorIndex is the sector that the backup boot region starts
+	// at (Section 3.1).
+	backupBootSectorIndex = 12
+)
+
+// FindVolumes scans the whole image for exFAT boot sectors (the JumpBoot and
+// FileSystemName signatures) at every 512-byte boundary, and returns their
+// offsets. This locates volumes in images whose partition tables are damaged
+// or missing. The backup boot sector of a volume that was already found is
+// not reported again, but one whose main boot sector is gone is (in which
+// case the volume starts twelve sectors earlier). Nothing else is validated,
+// so each offset should still be parsed.
+func FindVolumes(rs io.ReadSeeker) (offsets []int64, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	_, err = rs.Seek(0, os.SEEK_SET)
+	log.PanicIf(err)
+
+	offsets = make([]int64, 0)
+	backupOffsets := make(map[int64]bool)
+
+	chunk := make([]byte, findVolumesChunkSize)
+	chunkOffset := int64(0)
+
+	for {
+		n, err := io.ReadFull(rs, chunk)
+		if err == io.EOF {
+			break
+		} else if err != nil && err != io.ErrUnexpectedEOF {
+			log.Panic(err)
+		}
+
+		for i := 0; i+findVolumesAlignment <= n; i += findVolumesAlignment {
+			sector := chunk[i : i+findVolumesAlignment]
+
+			if bytes.Equal(sector[0:3], requiredJumpBootSignature) == false || bytes.Equal(sector[3:11], requiredFileSystemName) == false {
+				continue
+			}
+
+			offset := chunkOffset + int64(i)
+
+			if backupOffsets[offset] == true {
+				continue
+			}
+
+			offsets = append(offsets, offset)
+
+			// BytesPerSectorShift. Only sane values are trusted.
+			shift := sector[108]
+			if shift >= 9 && shift <= 12 {
+				backupOffsets[offset+backupBootSectorIndex<<shift] = true
+			}
+		}
+
+		if n < len(chunk) {
+			break
+		}
+
+		chunkOffset += int64(n)
+	}
+
+	return offsets, nil
+}
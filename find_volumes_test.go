@@ -0,0 +1,45 @@
+package exfat
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/dsoprea/go-logging"
+)
+
+func TestFindVolumes(t *testing.T) {
+	volume, _ := getTestImage()
+
+	// Two copies of the volume at odd (but sector-aligned) offsets, the
+	// second without its main boot sector.
+
+	image := make([]byte, 3*512+len(volume)+7*512+len(volume))
+
+	firstOffset := 3 * 512
+	copy(image[firstOffset:], volume)
+
+	secondOffset := firstOffset + len(volume) + 7*512
+	copy(image[secondOffset:], volume)
+
+	for i := 0; i < 512; i++ {
+		image[secondOffset+i] = 0
+	}
+
+	offsets, err := FindVolumes(bytes.NewReader(image))
+	log.PanicIf(err)
+
+	expected := []int64{int64(firstOffset), int64(secondOffset + 12*512)}
+	if reflect.DeepEqual(offsets, expected) != true {
+		t.Fatalf("Offsets not correct: %v", offsets)
+	}
+}
+
+func TestFindVolumes__None(t *testing.T) {
+	offsets, err := FindVolumes(bytes.NewReader(make([]byte, 10000)))
+	log.PanicIf(err)
+
+	if len(offsets) != 0 {
+		t.Fatalf("Expected no volumes: %v", offsets)
+	}
+}
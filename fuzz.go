@@ -0,0 +1,29 @@
+//go:build gofuzz
+// +build gofuzz
+
+// This package exports the fuzz targets for go-fuzz. See fuzz_targets.go and
+// fuzz_corpus.go.
+
+package exfat
+
+// FuzzImage is a go-fuzz target that parses a complete image, loads its tree,
+// and reads every file.
+func FuzzImage(data []byte) int {
+	return fuzzImage(data)
+}
+
+// FuzzEntrySet is a go-fuzz target that parses a series of raw directory-
+// entries into entry-sets.
+func FuzzEntrySet(data []byte) int {
+	return fuzzEntrySet(data)
+}
+
+// FuzzBootSector is a go-fuzz target for ParseBootSector().
+func FuzzBootSector(data []byte) int {
+	return fuzzBootSector(data)
+}
+
+// FuzzDirectoryEntrySet is a go-fuzz target for ParseDirectoryEntrySet().
+func FuzzDirectoryEntrySet(data []byte) int {
+	return fuzzDirectoryEntrySet(data)
+}
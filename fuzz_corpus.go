@@ -0,0 +1,291 @@
+// This package generates fuzz corpora by making structure-aware mutations to a
+// valid image.
+
+package exfat
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"math/rand"
+
+	"github.com/dsoprea/go-logging"
+)
+
+// imageField is the location of one field within a structure.
+type imageField struct {
+	offset int
+	size   int
+}
+
+var (
+	// bootSectorHeaderFields are the numeric fields of the boot-sector header
+	// (Section 3.1) that the rest of the parse depends on.
+	bootSectorHeaderFields = []imageField{
+		{64, 8},  // PartitionOffset
+		{72, 8},  // VolumeLength
+		{80, 4},  // FatOffset
+		{84, 4},  // FatLength
+		{88, 4},  // ClusterHeapOffset
+		{92, 4},  // ClusterCount
+		{96, 4},  // FirstClusterOfRootDirectory
+		{106, 2}, // VolumeFlags
+		{108, 1}, // BytesPerSectorShift
+		{109, 1}, // SectorsPerClusterShift
+		{110, 1}, // NumberOfFats
+		{112, 1}, // PercentInUse
+	}
+
+	// directoryEntryFields are the fields of a directory-entry (Section 6.2)
+	// that determine how it and the rest of its set are interpreted. For a
+	// stream-extension entry, these also cover NameLength, ValidDataLength,
+	// FirstCluster, and DataLength.
+	directoryEntryFields = []imageField{
+		{0, 1},  // EntryType
+		{1, 1},  // SecondaryCount or GeneralSecondaryFlags
+		{3, 1},  // NameLength
+		{8, 8},  // ValidDataLength
+		{20, 4}, // FirstCluster
+		{24, 8}, // DataLength
+	}
+)
+
+// ImageMutator produces copies of a valid image with one structure-aware
+// mutation applied to the boot region, the FAT, or a directory-entry set.
+// These make a much better starting corpus for coverage-guided fuzzing (see
+// FuzzImage) than random data, since they get past the signature checks.
+type ImageMutator struct {
+	image []byte
+	r     *rand.Rand
+
+	clusterCount uint32
+
+	// fatOffset is the byte offset of the first FAT.
+	fatOffset int
+
+	// chainClusters are the clusters whose FAT entries are part of a chain.
+	chainClusters []uint32
+
+	// entryOffsets are the byte offsets of every directory-entry that belongs
+	// to a set.
+	entryOffsets []int64
+}
+
+// NewImageMutator returns a new ImageMutator for the given image, which must
+// be valid. The same seed always produces the same series of mutations.
+func NewImageMutator(image []byte, seed int64) (im *ImageMutator, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	er := NewExfatReader(bytes.NewReader(image))
+
+	err = er.Parse()
+	log.PanicIf(err)
+
+	bsh := er.ActiveBootSectorHeader()
+
+	im = &ImageMutator{
+		image:        image,
+		r:            rand.New(rand.NewSource(seed)),
+		clusterCount: bsh.ClusterCount,
+		fatOffset:    int(int64(bsh.FatOffset) * int64(bsh.SectorSize())),
+	}
+
+	lf, err := er.ActiveFat()
+	log.PanicIf(err)
+
+	fat, err := lf.Load()
+	log.PanicIf(err)
+
+	for i, mc := range fat {
+		if mc != 0 {
+			im.chainClusters = append(im.chainClusters, uint32(i)+2)
+		}
+	}
+
+	tree := NewTree(er)
+
+	err = tree.Load()
+	log.PanicIf(err)
+
+	directoryClusters := []uint32{er.FirstClusterOfRootDirectory()}
+
+	visitorCb := func(pathParts []string, node *TreeNode) (err error) {
+		if len(pathParts) > 0 && node.IsDirectory() == true && node.IsInUse() == true {
+			directoryClusters = append(directoryClusters, node.StreamDirectoryEntry().FirstCluster)
+		}
+
+		return nil
+	}
+
+	err = tree.Visit(visitorCb)
+	log.PanicIf(err)
+
+	entrySetCb := func(es *EntrySet) (err error) {
+		for _, location := range es.Locations {
+			im.entryOffsets = append(im.entryOffsets, location.Offset)
+		}
+
+		return nil
+	}
+
+	for _, clusterNumber := range directoryClusters {
+		en := NewExfatNavigator(er, clusterNumber)
+
+		_, _, err := en.EnumerateEntrySets(entrySetCb)
+		log.PanicIf(err)
+	}
+
+	return im, nil
+}
+
+// interestingValue returns a replacement for a field of the given size: zero,
+// all-ones, just off of the current value, or random.
+func (im *ImageMutator) interestingValue(current uint64, size int) uint64 {
+	// This is all-ones for eight bytes, too (the shift produces zero).
+	mask := uint64(1)<<(uint(size)*8) - 1
+
+	switch im.r.Intn(6) {
+	case 0:
+		return 0
+	case 1:
+		return mask
+	case 2:
+		return (current + 1) & mask
+	case 3:
+		return (current - 1) & mask
+	case 4:
+		return (current << 1) & mask
+	}
+
+	return im.r.Uint64() & mask
+}
+
+// mutateField replaces the given field of the image copy, at `base`, with an
+// interesting value.
+func (im *ImageMutator) mutateField(data []byte, base int, field imageField) {
+	raw := data[base+field.offset : base+field.offset+field.size]
+
+	current := uint64(0)
+	for i := field.size - 1; i >= 0; i-- {
+		current = current<<8 | uint64(raw[i])
+	}
+
+	value := im.interestingValue(current, field.size)
+
+	for i := 0; i < field.size; i++ {
+		raw[i] = byte(value >> (uint(i) * 8))
+	}
+}
+
+// MutateBootRegion returns a copy of the image with one field of the main
+// boot-sector header changed.
+func (im *ImageMutator) MutateBootRegion() []byte {
+	data := make([]byte, len(im.image))
+	copy(data, im.image)
+
+	field := bootSectorHeaderFields[im.r.Intn(len(bootSectorHeaderFields))]
+	im.mutateField(data, 0, field)
+
+	return data
+}
+
+// MutateFatChain returns a copy of the image with one FAT entry of an existing
+// chain changed to a boundary value: free, reserved, a loop back to itself, a
+// random cluster, bad, end-of-chain, or out-of-bounds.
+func (im *ImageMutator) MutateFatChain() []byte {
+	data := make([]byte, len(im.image))
+	copy(data, im.image)
+
+	if len(im.chainClusters) == 0 {
+		return data
+	}
+
+	clusterNumber := im.chainClusters[im.r.Intn(len(im.chainClusters))]
+
+	candidates := []uint32{
+		0,
+		1,
+		clusterNumber,
+		2 + uint32(im.r.Intn(int(im.clusterCount))),
+		0xfffffff7,
+		0xffffffff,
+		im.clusterCount + 2,
+		im.r.Uint32(),
+	}
+
+	value := candidates[im.r.Intn(len(candidates))]
+
+	offset := im.fatOffset + int(clusterNumber)*4
+	defaultEncoding.PutUint32(data[offset:offset+4], value)
+
+	return data
+}
+
+// MutateEntrySet returns a copy of the image with one field of one directory-
+// entry changed. Occasionally, a random byte of the entry is changed instead.
+func (im *ImageMutator) MutateEntrySet() []byte {
+	data := make([]byte, len(im.image))
+	copy(data, im.image)
+
+	if len(im.entryOffsets) == 0 {
+		return data
+	}
+
+	base := int(im.entryOffsets[im.r.Intn(len(im.entryOffsets))])
+
+	if im.r.Intn(8) == 0 {
+		data[base+im.r.Intn(directoryEntryBytesCount)] = byte(im.r.Intn(256))
+		return data
+	}
+
+	field := directoryEntryFields[im.r.Intn(len(directoryEntryFields))]
+	im.mutateField(data, base, field)
+
+	return data
+}
+
+// Mutate returns a copy of the image with a random one of the mutations
+// applied.
+func (im *ImageMutator) Mutate() []byte {
+	switch im.r.Intn(3) {
+	case 0:
+		return im.MutateBootRegion()
+	case 1:
+		return im.MutateFatChain()
+	}
+
+	return im.MutateEntrySet()
+}
+
+// WriteCorpus writes `count` mutated images into the given directory (which
+// is created if necessary), along with the unmodified image, in the layout
+// that go-fuzz expects for its corpus.
+func (im *ImageMutator) WriteCorpus(corpusPath string, count int) (err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	err = os.MkdirAll(corpusPath, 0755)
+	log.PanicIf(err)
+
+	err = ioutil.WriteFile(filepath.Join(corpusPath, "original"), im.image, 0644)
+	log.PanicIf(err)
+
+	for i := 0; i < count; i++ {
+		filename := fmt.Sprintf("mutation-%06d", i)
+
+		err := ioutil.WriteFile(filepath.Join(corpusPath, filename), im.Mutate(), 0644)
+		log.PanicIf(err)
+	}
+
+	return nil
+}
@@ -0,0 +1,84 @@
+package exfat
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/dsoprea/go-logging"
+)
+
+func getTestImageMutator(seed int64) *ImageMutator {
+	image, _ := getTestImage()
+
+	im, err := NewImageMutator(image, seed)
+	log.PanicIf(err)
+
+	return im
+}
+
+func TestFuzzImage__Original(t *testing.T) {
+	image, _ := getTestImage()
+
+	if fuzzImage(image) != 1 {
+		t.Fatalf("Expected original image to load.")
+	}
+}
+
+func TestFuzzImage__Mutations(t *testing.T) {
+	im := getTestImageMutator(1)
+
+	names := []string{"boot-region", "fat-chain", "entry-set"}
+	mutators := []func() []byte{
+		im.MutateBootRegion,
+		im.MutateFatChain,
+		im.MutateEntrySet,
+	}
+
+	for j, mutator := range mutators {
+		name := names[j]
+
+		for i := 0; i < 200; i++ {
+			data := mutator()
+
+			func() {
+				defer func() {
+					if errRaw := recover(); errRaw != nil {
+						t.Fatalf("Mutation (%s) (%d) crashed: %v", name, i, errRaw)
+					}
+				}()
+
+				fuzzImage(data)
+			}()
+		}
+	}
+}
+
+func TestFuzzEntrySet(t *testing.T) {
+	im := getTestImageMutator(1)
+
+	for _, offset := range im.entryOffsets[:3] {
+		if fuzzEntrySet(im.image[offset:]) != 1 {
+			t.Fatalf("Expected entries at (%d) to produce a set.", offset)
+		}
+	}
+}
+
+func TestImageMutator_WriteCorpus(t *testing.T) {
+	im := getTestImageMutator(1)
+
+	corpusPath, err := ioutil.TempDir("", "exfat-corpus")
+	log.PanicIf(err)
+
+	defer os.RemoveAll(corpusPath)
+
+	err = im.WriteCorpus(corpusPath, 3)
+	log.PanicIf(err)
+
+	files, err := ioutil.ReadDir(corpusPath)
+	log.PanicIf(err)
+
+	if len(files) != 4 {
+		t.Fatalf("Corpus file count not correct: (%d)", len(files))
+	}
+}
@@ -0,0 +1,208 @@
+// This package implements the fuzz targets. They are exported (for go-fuzz)
+// from fuzz.go, which is only built with the "gofuzz" tag, so that the normal
+// tests can also run them against generated corpora.
+
+package exfat
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+
+	"github.com/dsoprea/go-logging"
+)
+
+// checkFuzzError re-panics if the error came from a runtime failure (e.g. an
+// out-of-range index) rather than from our own validation. Since every
+// function recovers panics into errors, this is how crashes are surfaced to
+// the fuzzer.
+func checkFuzzError(err error) {
+	if strings.Contains(err.Error(), "runtime error") == true {
+		panic(err)
+	}
+}
+
+// fuzzImage parses the given data as a complete image, loads the entire tree,
+// and reads the data for every file. Returns 1 if the image was parsed and
+// fully loaded (so that the fuzzer prioritizes it) and 0 otherwise.
+func fuzzImage(data []byte) int {
+	er := NewExfatReader(bytes.NewReader(data))
+
+	err := er.Parse()
+	if err != nil {
+		checkFuzzError(err)
+		return 0
+	}
+
+	tree := NewTree(er)
+
+	err = tree.Load()
+	if err != nil {
+		checkFuzzError(err)
+		return 0
+	}
+
+	cb := func(pathParts []string, node *TreeNode) (err error) {
+		sede := node.StreamDirectoryEntry()
+		if node.IsDirectory() == true || sede == nil {
+			return nil
+		}
+
+		// Don't let a corrupted size have us read more than the image.
+		if sede.ValidDataLength == 0 || sede.ValidDataLength > uint64(len(data)) {
+			return nil
+		}
+
+		useFat := sede.GeneralSecondaryFlags.NoFatChain() == false
+
+		_, _, err = er.WriteFromClusterChain(sede.FirstCluster, sede.ValidDataLength, useFat, ioutil.Discard)
+		if err != nil {
+			checkFuzzError(err)
+		}
+
+		return nil
+	}
+
+	err = tree.Visit(cb)
+	if err != nil {
+		checkFuzzError(err)
+		return 0
+	}
+
+	return 1
+}
+
+// fuzzEntrySet parses the given data as a series of raw directory-entries,
+// groups them into sets, and exercises the set operations. Returns 1 if at
+// least one complete set was found and 0 otherwise.
+func fuzzEntrySet(data []byte) int {
+	esa := new(entrySetAssembler)
+	completeCount := 0
+
+	for i := 0; i+directoryEntryBytesCount <= len(data); i += directoryEntryBytesCount {
+		raw := data[i : i+directoryEntryBytesCount]
+		entryType := EntryType(raw[0])
+
+		if entryType.IsEndOfDirectory() == true {
+			break
+		}
+
+		de, err := esa.parse(entryType, raw)
+		if err != nil {
+			checkFuzzError(err)
+			return 0
+		}
+
+		location := EntryLocation{
+			Offset:      int64(i),
+			EntryNumber: i / directoryEntryBytesCount,
+		}
+
+		es := esa.add(entryType, de, location, raw)
+		if es == nil {
+			continue
+		}
+
+		err = exerciseEntrySet(es)
+		if err != nil {
+			checkFuzzError(err)
+			return 0
+		}
+
+		completeCount++
+	}
+
+	if completeCount == 0 {
+		return 0
+	}
+
+	return 1
+}
+
+// fuzzBootSector parses the given data with ParseBootSector() and exercises
+// the header. Returns 1 if it was valid and 0 otherwise.
+func fuzzBootSector(data []byte) int {
+	bsh, err := ParseBootSector(data)
+	if err != nil {
+		checkFuzzError(err)
+		return 0
+	}
+
+	err = exerciseBootSectorHeader(bsh)
+	if err != nil {
+		checkFuzzError(err)
+		return 0
+	}
+
+	return 1
+}
+
+// fuzzDirectoryEntrySet parses the given data with ParseDirectoryEntrySet()
+// and exercises the set. Returns 1 if it was a valid set and 0 otherwise.
+func fuzzDirectoryEntrySet(data []byte) int {
+	es, err := ParseDirectoryEntrySet(data)
+	if err != nil {
+		checkFuzzError(err)
+		return 0
+	}
+
+	err = exerciseEntrySet(es)
+	if err != nil {
+		checkFuzzError(err)
+		return 0
+	}
+
+	return 1
+}
+
+// exerciseBootSectorHeader calls every accessor on the header.
+func exerciseBootSectorHeader(bsh BootSectorHeader) (err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	bsh.SectorSize()
+	bsh.SectorsPerCluster()
+	bsh.ClusterSize()
+	bsh.Info()
+	_ = bsh.String()
+
+	_, err = bsh.MarshalJSON()
+	log.PanicIf(err)
+
+	_, err = bsh.MarshalBinary()
+	log.PanicIf(err)
+
+	bsh.DumpTo(ioutil.Discard)
+
+	return nil
+}
+
+// exerciseEntrySet calls every accessor on the set.
+func exerciseEntrySet(es *EntrySet) (err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	for i := 0; i < es.EntryCount(); i++ {
+		es.RawEntry(i)
+	}
+
+	es.StoredChecksum()
+	es.IsChecksumValid()
+	es.IsInUse()
+	es.Filename()
+	_ = es.String()
+
+	if fde, ok := es.PrimaryEntry.(*ExfatFileDirectoryEntry); ok == true {
+		fde.CreateTimestamp()
+		fde.LastModifiedTimestamp()
+		fde.LastAccessedTimestamp()
+	}
+
+	return nil
+}
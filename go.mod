@@ -1,14 +1,21 @@
 module github.com/dsoprea/go-exfat
 
-go 1.13
+go 1.18
 
 require (
 	github.com/dsoprea/go-logging v0.0.0-20190624164917-c4f10aab7696
 	github.com/dustin/go-humanize v1.0.0
-	github.com/go-errors/errors v1.0.1 // indirect
 	github.com/go-restruct/restruct v0.0.0-20190418070341-acd4e4c2cb35
 	github.com/jessevdk/go-flags v1.4.0
+	github.com/pkg/sftp v1.11.0
+	gopkg.in/yaml.v2 v2.2.2
+)
+
+require (
+	github.com/go-errors/errors v1.4.1 // indirect
+	github.com/kr/fs v0.1.0 // indirect
 	github.com/pkg/errors v0.8.1 // indirect
-	github.com/stretchr/testify v1.4.0 // indirect
+	golang.org/x/crypto v0.0.0-20190820162420-60c769a6c586 // indirect
 	golang.org/x/net v0.0.0-20190827160401-ba9fcec4b297 // indirect
+	golang.org/x/sys v0.0.0-20190412213103-97732733099d // indirect
 )
@@ -0,0 +1,98 @@
+// This package maintains the registry of hash algorithms that can be selected
+// by name.
+
+package exfat
+
+import (
+	"errors"
+	"hash"
+	"sort"
+	"strings"
+	"sync"
+
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"hash/crc32"
+
+	"github.com/dsoprea/go-logging"
+)
+
+const (
+	// DefaultHashName is the algorithm that is used when one isn't given.
+	DefaultHashName = "sha256"
+)
+
+var (
+	// ErrHashNotRegistered is returned when a hash algorithm is requested by a
+	// name that was never registered.
+	ErrHashNotRegistered = errors.New("hash algorithm not registered")
+)
+
+// HashFactory returns a new instance of a hash.
+type HashFactory func() hash.Hash
+
+var (
+	hashRegistry = map[string]HashFactory{
+		"crc32": func() hash.Hash {
+			return crc32.NewIEEE()
+		},
+		"md5":    md5.New,
+		"sha1":   sha1.New,
+		"sha256": sha256.New,
+		"sha512": sha512.New,
+	}
+
+	hashRegistryLocker sync.RWMutex
+)
+
+// RegisterHash makes a hash algorithm available by the given (case-
+// insensitive) name, replacing any previous registration. This allows
+// algorithms outside the standard library (e.g. xxHash or BLAKE3) to be used
+// with manifests and syncs without this project importing them:
+//
+//	exfat.RegisterHash("xxh64", func() hash.Hash { return xxhash.New() })
+func RegisterHash(name string, factory HashFactory) {
+	if name == "" {
+		log.Panicf("hash name can not be empty")
+	} else if factory == nil {
+		log.Panicf("hash factory can not be nil: [%s]", name)
+	}
+
+	hashRegistryLocker.Lock()
+	defer hashRegistryLocker.Unlock()
+
+	hashRegistry[strings.ToLower(name)] = factory
+}
+
+// LookupHash returns the factory for the hash algorithm registered under the
+// given (case-insensitive) name. Returns ErrHashNotRegistered if there isn't
+// one.
+func LookupHash(name string) (factory HashFactory, err error) {
+	hashRegistryLocker.RLock()
+	defer hashRegistryLocker.RUnlock()
+
+	factory, found := hashRegistry[strings.ToLower(name)]
+	if found == false {
+		return nil, ErrHashNotRegistered
+	}
+
+	return factory, nil
+}
+
+// RegisteredHashNames returns the sorted names of all registered hash
+// algorithms.
+func RegisteredHashNames() (names []string) {
+	hashRegistryLocker.RLock()
+	defer hashRegistryLocker.RUnlock()
+
+	names = make([]string, 0, len(hashRegistry))
+	for name := range hashRegistry {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
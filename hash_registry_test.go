@@ -0,0 +1,76 @@
+package exfat
+
+import (
+	"hash"
+	"reflect"
+	"testing"
+
+	"crypto/sha1"
+	"encoding/hex"
+	"hash/fnv"
+
+	"github.com/dsoprea/go-logging"
+)
+
+func TestLookupHash(t *testing.T) {
+	newHash, err := LookupHash("SHA1")
+	log.PanicIf(err)
+
+	h := newHash()
+	h.Write([]byte("abc"))
+
+	expected := sha1.Sum([]byte("abc"))
+	if reflect.DeepEqual(h.Sum(nil), expected[:]) != true {
+		t.Fatalf("Digest not correct: [%s]", hex.EncodeToString(h.Sum(nil)))
+	}
+}
+
+func TestLookupHash__NotRegistered(t *testing.T) {
+	_, err := LookupHash("not-a-hash")
+	if err == nil {
+		t.Fatalf("Expected error for unregistered hash.")
+	} else if log.Is(err, ErrHashNotRegistered) != true {
+		t.Fatalf("Error not correct: [%s]", err)
+	}
+}
+
+func TestRegisterHash(t *testing.T) {
+	RegisterHash("FNV64a", func() hash.Hash {
+		return fnv.New64a()
+	})
+
+	defer func() {
+		hashRegistryLocker.Lock()
+		delete(hashRegistry, "fnv64a")
+		hashRegistryLocker.Unlock()
+	}()
+
+	newHash, err := LookupHash("fnv64a")
+	log.PanicIf(err)
+
+	if newHash().Size() != 8 {
+		t.Fatalf("Registered hash not correct.")
+	}
+
+	tree, closer := getTestTree()
+
+	defer closer()
+
+	manifest, err := BuildManifest(tree, "fnv64a")
+	log.PanicIf(err)
+
+	for _, me := range manifest.Entries {
+		if me.IsDirectory == false && len(me.Hash) != 16 {
+			t.Fatalf("Manifest hash not correct: %s [%s]", me, me.Hash)
+		}
+	}
+}
+
+func TestRegisteredHashNames(t *testing.T) {
+	names := RegisteredHashNames()
+
+	expected := []string{"crc32", "md5", "sha1", "sha256", "sha512"}
+	if reflect.DeepEqual(names, expected) != true {
+		t.Fatalf("Names not correct: %v", names)
+	}
+}
@@ -0,0 +1,126 @@
+// This package supports hashing every file in a directory directly from the
+// image.
+
+package exfat
+
+import (
+	"fmt"
+	"hash"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"encoding/hex"
+
+	"github.com/dsoprea/go-logging"
+)
+
+// FileDigests are the digests of one file's data.
+type FileDigests struct {
+	// PathParts is the path of the file, relative to the directory that was
+	// hashed.
+	PathParts []string
+
+	// Size is the number of bytes that were hashed (ValidDataLength).
+	Size uint64
+
+	// Digests are the hex-encoded digests, keyed by the hash names that were
+	// requested.
+	Digests map[string]string
+}
+
+// String returns a descriptive string.
+func (fd FileDigests) String() string {
+	return fmt.Sprintf("FileDigests<PATH=[%s] SIZE=(%d) DIGESTS=%v>", JoinVolumePath(fd.PathParts), fd.Size, fd.Digests)
+}
+
+// ManifestLine returns the digest with the given hash name in the format of
+// `sha256sum` and similar tools ("<digest>  <path>"), which can be checked
+// with their `-c` option. The path is relative and forward-slash separated.
+func (fd FileDigests) ManifestLine(hashName string) string {
+	return fmt.Sprintf("%s  %s", fd.Digests[strings.ToLower(hashName)], strings.Join(fd.PathParts, "/"))
+}
+
+// FileDigestsVisitorFunc receives the digests of each file as it's hashed.
+type FileDigestsVisitorFunc func(fd FileDigests) (err error)
+
+// HashTree hashes every file under the directory at `volumePath` ("" or "/"
+// for the whole volume) with each of the given registered hash algorithms
+// (see RegisterHash) and passes the digests to `cb`. Each file's data is only
+// read once. Files are visited in the same order as Walk(). Deleted files and
+// the contents of deleted directories are skipped.
+func HashTree(tree *Tree, volumePath string, hashNames []string, cb FileDigestsVisitorFunc) (err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	if len(hashNames) == 0 {
+		log.Panicf("at least one hash algorithm is required")
+	}
+
+	factories := make([]HashFactory, len(hashNames))
+	for i, hashName := range hashNames {
+		factories[i], err = LookupHash(hashName)
+		log.PanicIf(err)
+	}
+
+	rootNode, err := tree.LookupPath(volumePath)
+	log.PanicIf(err)
+
+	if rootNode == nil {
+		log.Panicf("volume path not found: [%s]", volumePath)
+	} else if rootNode.IsDirectory() == false {
+		log.Panicf("volume path is not a directory: [%s]", volumePath)
+	}
+
+	walkCb := func(pathParts []string, node *TreeNode) (err error) {
+		defer func() {
+			if errRaw := recover(); errRaw != nil {
+				err = log.Wrap(errRaw.(error))
+			}
+		}()
+
+		if node.IsInUse() == false {
+			if node.IsDirectory() == true {
+				return filepath.SkipDir
+			}
+
+			return nil
+		} else if node.IsDirectory() == true {
+			return nil
+		}
+
+		hashes := make([]hash.Hash, len(factories))
+		writers := make([]io.Writer, len(factories))
+
+		for i, factory := range factories {
+			hashes[i] = factory()
+			writers[i] = hashes[i]
+		}
+
+		err = node.WriteData(io.MultiWriter(writers...), false)
+		log.PanicIf(err)
+
+		fd := FileDigests{
+			PathParts: pathParts,
+			Size:      node.Size(),
+			Digests:   make(map[string]string, len(hashNames)),
+		}
+
+		for i, hashName := range hashNames {
+			fd.Digests[strings.ToLower(hashName)] = hex.EncodeToString(hashes[i].Sum(nil))
+		}
+
+		err = cb(fd)
+		log.PanicIf(err)
+
+		return nil
+	}
+
+	err = tree.walk(make([]string, 0), rootNode, walkCb)
+	log.PanicIf(err)
+
+	return nil
+}
@@ -0,0 +1,94 @@
+package exfat
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/dsoprea/go-logging"
+)
+
+func TestHashTree(t *testing.T) {
+	tree, closer := getTestTree()
+
+	defer closer()
+
+	collected := make([]FileDigests, 0)
+
+	cb := func(fd FileDigests) (err error) {
+		collected = append(collected, fd)
+		return nil
+	}
+
+	err := HashTree(tree, "", []string{"SHA1", "md5"}, cb)
+	log.PanicIf(err)
+
+	paths := make([]string, len(collected))
+	for i, fd := range collected {
+		paths[i] = JoinVolumePath(fd.PathParts)
+	}
+
+	expectedPaths := []string{
+		`testdirectory\300daec8-cec3-11e9-bfa2-0f240e41d1d8`,
+		`testdirectory2\00c57ab0-cec3-11e9-b750-bbed8d2244c8`,
+		`testdirectory2\ff7b94be-cec2-11e9-b7b1-6b2e61bd775c`,
+		`testdirectory3\10422c86-cec3-11e9-953f-4f501efd2640`,
+		"064cbfd4-cec3-11e9-926d-c362c80fab7b",
+		"2-delahaye-type-165-cabriolet-dsc_8025.jpg",
+		"79c6d31a-cca1-11e9-8325-9746d045e868",
+	}
+
+	if reflect.DeepEqual(paths, expectedPaths) != true {
+		t.Fatalf("Hashed files not correct: %v", paths)
+	}
+
+	fd := collected[5]
+
+	if fd.Size != 313299 {
+		t.Fatalf("Size not correct: (%d)", fd.Size)
+	} else if fd.Digests["sha1"] != "a2219fa800ae2325003d8d4f5122b37f12f1e18e" {
+		t.Fatalf("SHA1 not correct: [%s]", fd.Digests["sha1"])
+	} else if len(fd.Digests["md5"]) != 32 {
+		t.Fatalf("MD5 not correct: [%s]", fd.Digests["md5"])
+	}
+
+	line := fd.ManifestLine("sha1")
+	if line != "a2219fa800ae2325003d8d4f5122b37f12f1e18e  2-delahaye-type-165-cabriolet-dsc_8025.jpg" {
+		t.Fatalf("Manifest line not correct: [%s]", line)
+	}
+}
+
+func TestHashTree__Subtree(t *testing.T) {
+	tree, closer := getTestTree()
+
+	defer closer()
+
+	collected := make([]FileDigests, 0)
+
+	cb := func(fd FileDigests) (err error) {
+		collected = append(collected, fd)
+		return nil
+	}
+
+	err := HashTree(tree, "/testdirectory2", []string{"sha256"}, cb)
+	log.PanicIf(err)
+
+	if len(collected) != 2 {
+		t.Fatalf("Hashed file count not correct: (%d)", len(collected))
+	}
+
+	line := collected[0].ManifestLine("sha256")
+	if line[64:] != "  00c57ab0-cec3-11e9-b750-bbed8d2244c8" {
+		t.Fatalf("Manifest line not correct: [%s]", line)
+	}
+}
+
+func TestHashTree__NotRegistered(t *testing.T) {
+	tree, closer := getTestTree()
+
+	defer closer()
+
+	err := HashTree(tree, "", []string{"nonexistent"}, nil)
+	if err == nil {
+		t.Fatalf("Expected error for an unregistered algorithm.")
+	}
+}
@@ -0,0 +1,169 @@
+// This package reads FAT entries on demand so that the cost of parsing does not
+// scale with the size of the volume.
+
+package exfat
+
+import (
+	"fmt"
+	"sync"
+
+	"container/list"
+
+	"github.com/dsoprea/go-logging"
+)
+
+const (
+	// defaultLazyFatMaxCachedPages is the number of pages (one sector's worth
+	// of entries each) that are cached before the least-recently-used one is
+	// dropped. This is 2M of entries for 4K sectors.
+	defaultLazyFatMaxCachedPages = 512
+)
+
+// lazyFatPage is one sector's worth of FAT entries.
+type lazyFatPage struct {
+	pageNumber uint32
+	entries    []MappedCluster
+}
+
+// LazyFat provides access to the entries of one FAT, reading them from the
+// image as they are needed and caching them a sector at a time. Only a bounded
+// number of sectors are kept, so memory use stays fixed no matter how large
+// the volume is. It is safe for concurrent use.
+type LazyFat struct {
+	er *ExfatReader
+
+	// offset is the absolute offset of FatEntry[0].
+	offset int64
+
+	clusterCount   uint32
+	pageEntryCount uint32
+	maxCachedPages int
+
+	locker sync.Mutex
+
+	// pages indexes the elements of `lru`, which holds *lazyFatPage values
+	// in most-recently-used order.
+	pages map[uint32]*list.Element
+	lru   *list.List
+}
+
+func newLazyFat(er *ExfatReader, offset int64) *LazyFat {
+	return &LazyFat{
+		er:             er,
+		offset:         offset,
+		clusterCount:   er.bootRegion.bsh.ClusterCount,
+		pageEntryCount: er.SectorSize() / 4,
+		maxCachedPages: defaultLazyFatMaxCachedPages,
+		pages:          make(map[uint32]*list.Element),
+		lru:            list.New(),
+	}
+}
+
+// EntryCount returns the number of entries that describe clusters (all but
+// the first two).
+func (lf *LazyFat) EntryCount() uint32 {
+	return lf.clusterCount
+}
+
+// Offset returns the absolute offset of the FAT in the image.
+func (lf *LazyFat) Offset() int64 {
+	return lf.offset
+}
+
+// CachedPageCount returns the number of sectors of entries currently cached.
+func (lf *LazyFat) CachedPageCount() int {
+	lf.locker.Lock()
+	defer lf.locker.Unlock()
+
+	return lf.lru.Len()
+}
+
+// Entry returns the FAT entry for the given cluster.
+func (lf *LazyFat) Entry(clusterNumber uint32) (mc MappedCluster, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	// FatEntry[2] represents the first cluster in the Cluster Heap and
+	// FatEntry[ClusterCount+1] represents the last (Section 4.1.3).
+	if clusterNumber < 2 || uint64(clusterNumber) > uint64(lf.clusterCount)+1 {
+		log.Panicf("cluster exceeds FAT bounds: (%d) > (%d)", clusterNumber, uint64(lf.clusterCount)+1)
+	}
+
+	pageNumber := clusterNumber / lf.pageEntryCount
+
+	lf.locker.Lock()
+	defer lf.locker.Unlock()
+
+	page, err := lf.page(pageNumber)
+	log.PanicIf(err)
+
+	return page.entries[clusterNumber%lf.pageEntryCount], nil
+}
+
+// page returns the given page, reading it if it is not cached. The lock must
+// be held.
+func (lf *LazyFat) page(pageNumber uint32) (page *lazyFatPage, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	element, found := lf.pages[pageNumber]
+	lf.er.countCacheLookup(found)
+
+	if found == true {
+		lf.lru.MoveToFront(element)
+		return element.Value.(*lazyFatPage), nil
+	}
+
+	data := make([]byte, lf.pageEntryCount*4)
+
+	err = lf.er.readAt(data, lf.offset+int64(pageNumber)*int64(len(data)))
+	log.PanicIf(err)
+
+	page = &lazyFatPage{
+		pageNumber: pageNumber,
+		entries:    make([]MappedCluster, lf.pageEntryCount),
+	}
+
+	for i := range page.entries {
+		page.entries[i] = MappedCluster(defaultEncoding.Uint32(data[i*4:]))
+	}
+
+	lf.pages[pageNumber] = lf.lru.PushFront(page)
+
+	if lf.lru.Len() > lf.maxCachedPages {
+		oldest := lf.lru.Back()
+		lf.lru.Remove(oldest)
+
+		delete(lf.pages, oldest.Value.(*lazyFatPage).pageNumber)
+	}
+
+	return page, nil
+}
+
+// Load reads every entry. This is only practical for smaller volumes but is
+// convenient when the whole table needs to be inspected.
+func (lf *LazyFat) Load() (fat Fat, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	fat = make(Fat, lf.clusterCount)
+	for i := uint32(0); i < lf.clusterCount; i++ {
+		fat[i], err = lf.Entry(i + 2)
+		log.PanicIf(err)
+	}
+
+	return fat, nil
+}
+
+func (lf *LazyFat) String() string {
+	return fmt.Sprintf("LazyFat<OFFSET=(%d) CLUSTER-COUNT=(%d) CACHED-PAGES=(%d)>", lf.offset, lf.clusterCount, lf.CachedPageCount())
+}
@@ -0,0 +1,123 @@
+package exfat
+
+import (
+	"testing"
+
+	"github.com/dsoprea/go-logging"
+)
+
+func TestLazyFat_Entry(t *testing.T) {
+	f, er := getTestFileAndParser()
+
+	defer f.Close()
+
+	err := er.Parse()
+	log.PanicIf(err)
+
+	lf, err := er.ActiveFat()
+	log.PanicIf(err)
+
+	if lf.CachedPageCount() != 0 {
+		t.Fatalf("Expected nothing to be read during the parse: (%d)", lf.CachedPageCount())
+	}
+
+	bsh := er.ActiveBootSectorHeader()
+
+	// Read the raw entry directly and compare.
+
+	clusterNumber := uint32(10)
+
+	raw := make([]byte, 4)
+
+	err = er.readAt(raw, int64(bsh.FatOffset)*int64(bsh.SectorSize())+int64(clusterNumber)*4)
+	log.PanicIf(err)
+
+	mc, err := lf.Entry(clusterNumber)
+	log.PanicIf(err)
+
+	if mc != MappedCluster(defaultEncoding.Uint32(raw)) {
+		t.Fatalf("Entry not correct: (0x%08x)", mc)
+	} else if lf.CachedPageCount() != 1 {
+		t.Fatalf("Expected one cached page: (%d)", lf.CachedPageCount())
+	}
+}
+
+func TestLazyFat_Entry__OutOfBounds(t *testing.T) {
+	f, er := getTestFileAndParser()
+
+	defer f.Close()
+
+	err := er.Parse()
+	log.PanicIf(err)
+
+	lf, err := er.ActiveFat()
+	log.PanicIf(err)
+
+	_, err = lf.Entry(1)
+	if err == nil {
+		t.Fatalf("Expected error for reserved entry.")
+	}
+
+	_, err = lf.Entry(lf.EntryCount() + 2)
+	if err == nil {
+		t.Fatalf("Expected error for entry past the end.")
+	}
+
+	_, err = lf.Entry(lf.EntryCount() + 1)
+	log.PanicIf(err)
+}
+
+func TestLazyFat_Entry__Eviction(t *testing.T) {
+	f, er := getTestFileAndParser()
+
+	defer f.Close()
+
+	err := er.Parse()
+	log.PanicIf(err)
+
+	lf, err := er.ActiveFat()
+	log.PanicIf(err)
+
+	lf.maxCachedPages = 1
+
+	expected, err := lf.Load()
+	log.PanicIf(err)
+
+	if lf.CachedPageCount() != 1 {
+		t.Fatalf("Cache not bounded: (%d)", lf.CachedPageCount())
+	}
+
+	// Walk backwards so that every page has to be reread.
+	for i := len(expected) - 1; i >= 0; i-- {
+		mc, err := lf.Entry(uint32(i) + 2)
+		log.PanicIf(err)
+
+		if mc != expected[i] {
+			t.Fatalf("Entry (%d) not correct after eviction: (0x%08x) != (0x%08x)", i+2, mc, expected[i])
+		}
+	}
+}
+
+func TestLazyFat_Load(t *testing.T) {
+	f, er := getTestFileAndParser()
+
+	defer f.Close()
+
+	err := er.Parse()
+	log.PanicIf(err)
+
+	lf, err := er.ActiveFat()
+	log.PanicIf(err)
+
+	fat, err := lf.Load()
+	log.PanicIf(err)
+
+	if uint32(len(fat)) != er.ActiveBootSectorHeader().ClusterCount {
+		t.Fatalf("Entry count not correct: (%d)", len(fat))
+	}
+
+	// The root directory is always allocated, so its entry can not be free.
+	if fat[er.FirstClusterOfRootDirectory()-2] == 0 {
+		t.Fatalf("Expected root-directory cluster to be allocated.")
+	}
+}
@@ -0,0 +1,88 @@
+// This package supports reporting what the parser is doing to a logger that
+// the caller provides.
+
+package exfat
+
+import (
+	"io"
+)
+
+const (
+	// EventBootRegionParsed is logged once the boot region has been parsed and
+	// selected.
+	EventBootRegionParsed = "boot region parsed"
+
+	// EventFatLoaded is logged once the FATs have been parsed.
+	EventFatLoaded = "FAT loaded"
+
+	// EventDirectoryIndexed is logged when a directory has been indexed.
+	EventDirectoryIndexed = "directory indexed"
+
+	// EventReadRetry is logged before a failed read is attempted again (see
+	// ReadRetryPolicy).
+	EventReadRetry = "retrying read"
+
+	// EventReadZeroFill is logged when a sector that can't be read is
+	// zero-filled (see ReadRetryPolicy).
+	EventReadZeroFill = "zero-filling unreadable sector"
+
+	// EventChainZeroFill is logged when the part of a damaged cluster chain
+	// that can't be reached is zero-filled (see SalvageFromClusterChain()).
+	EventChainZeroFill = "zero-filling damaged chain"
+)
+
+// Logger receives structured debug events. The arguments are alternating keys
+// (strings) and values, so a `*slog.Logger` can be used as-is.
+type Logger interface {
+	Debug(msg string, args ...interface{})
+}
+
+// ParserOptions configures a new ExfatReader. Anything left as zero gets the
+// default.
+type ParserOptions struct {
+	// Logger receives debug events as the structures are parsed. Nothing is
+	// logged if nil.
+	Logger Logger
+
+	// DeferFatParsing is the same as calling SetDeferFatParsing().
+	DeferFatParsing bool
+
+	// ReadAheadClusterCount is the same as calling
+	// SetReadAheadClusterCount().
+	ReadAheadClusterCount int
+
+	// ReadRetryPolicy is the same as calling SetReadRetryPolicy().
+	ReadRetryPolicy ReadRetryPolicy
+
+	// EmptyRootPolicy is the same as calling SetEmptyRootPolicy().
+	EmptyRootPolicy EmptyRootPolicy
+}
+
+// NewExfatReaderWithOptions returns a new instance of ExfatReader configured
+// with the given options. See NewExfatReader().
+func NewExfatReaderWithOptions(rs io.ReadSeeker, options ParserOptions) *ExfatReader {
+	er := NewExfatReader(rs)
+
+	er.SetLogger(options.Logger)
+	er.SetDeferFatParsing(options.DeferFatParsing)
+	er.SetReadAheadClusterCount(options.ReadAheadClusterCount)
+	er.SetReadRetryPolicy(options.ReadRetryPolicy)
+	er.SetEmptyRootPolicy(options.EmptyRootPolicy)
+
+	return er
+}
+
+// SetLogger sets the logger that receives debug events. Nil (the default)
+// disables them. Must be called before Parse().
+func (er *ExfatReader) SetLogger(logger Logger) {
+	er.logger = logger
+}
+
+// debug logs the given event if there is a logger.
+func (er *ExfatReader) debug(msg string, args ...interface{}) {
+	if er.logger == nil {
+		return
+	}
+
+	er.logger.Debug(msg, args...)
+}
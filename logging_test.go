@@ -0,0 +1,101 @@
+package exfat
+
+import (
+	"testing"
+
+	"github.com/dsoprea/go-logging"
+)
+
+type testLoggedEvent struct {
+	msg  string
+	args []interface{}
+}
+
+// testLogger records the events that it receives.
+type testLogger struct {
+	events []testLoggedEvent
+}
+
+func (tl *testLogger) Debug(msg string, args ...interface{}) {
+	tl.events = append(tl.events, testLoggedEvent{msg: msg, args: args})
+}
+
+func (tl *testLogger) find(msg string) []testLoggedEvent {
+	found := make([]testLoggedEvent, 0)
+	for _, event := range tl.events {
+		if event.msg == msg {
+			found = append(found, event)
+		}
+	}
+
+	return found
+}
+
+func TestNewExfatReaderWithOptions(t *testing.T) {
+	f, _ := getTestFileAndParser()
+
+	defer f.Close()
+
+	tl := new(testLogger)
+
+	options := ParserOptions{
+		Logger:                tl,
+		DeferFatParsing:       true,
+		ReadAheadClusterCount: 2,
+	}
+
+	er := NewExfatReaderWithOptions(f, options)
+
+	if er.ReadAheadClusterCount() != 2 {
+		t.Fatalf("Read-ahead not set.")
+	}
+
+	err := er.Parse()
+	log.PanicIf(err)
+
+	events := tl.find(EventBootRegionParsed)
+	if len(events) != 1 {
+		t.Fatalf("Expected one boot-region event: (%d)", len(events))
+	}
+
+	args := events[0].args
+	if len(args)%2 != 0 || args[0] != "sector_size" || args[1] != uint32(512) {
+		t.Fatalf("Boot-region event not correct: %v", args)
+	}
+
+	// The FATs are parsed later.
+
+	if len(tl.find(EventFatLoaded)) != 0 {
+		t.Fatalf("FAT should not have been loaded yet.")
+	}
+
+	tree := NewTree(er)
+
+	err = tree.Load()
+	log.PanicIf(err)
+
+	events = tl.find(EventDirectoryIndexed)
+	if len(events) != 1 {
+		t.Fatalf("Expected one directory event: (%d)", len(events))
+	} else if events[0].args[1] != er.FirstClusterOfRootDirectory() {
+		t.Fatalf("Directory event not correct: %v", events[0].args)
+	}
+
+	_, err = er.ActiveFat()
+	log.PanicIf(err)
+
+	if len(tl.find(EventFatLoaded)) != 1 {
+		t.Fatalf("Expected one FAT event.")
+	}
+}
+
+func TestExfatReader_SetLogger__Nil(t *testing.T) {
+	f, er := getTestFileAndParser()
+
+	defer f.Close()
+
+	er.SetLogger(nil)
+
+	err := er.Parse()
+	log.PanicIf(err)
+}
@@ -0,0 +1,307 @@
+// This package supports taking metadata snapshots of a volume and comparing
+// them.
+
+package exfat
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"encoding/hex"
+	"encoding/json"
+
+	"github.com/dsoprea/go-logging"
+)
+
+// ManifestEntry is the recorded metadata for one file or directory.
+type ManifestEntry struct {
+	// Path is the complete, backslash-separated path.
+	Path string `json:"path"`
+
+	IsDirectory  bool      `json:"is_directory"`
+	Size         uint64    `json:"size"`
+	ModifiedTime time.Time `json:"modified_time"`
+	CreatedTime  time.Time `json:"created_time"`
+	FirstCluster uint32    `json:"first_cluster"`
+
+	// Hash is the hex-encoded digest of the file's data. It is empty if the
+	// manifest was built without hashing or if this is a directory.
+	Hash string `json:"hash,omitempty"`
+}
+
+// String returns a descriptive string.
+func (me ManifestEntry) String() string {
+	return fmt.Sprintf("ManifestEntry<PATH=[%s] IS-DIRECTORY=[%v] SIZE=(%d) MTIME=[%s]>", me.Path, me.IsDirectory, me.Size, me.ModifiedTime)
+}
+
+// Manifest is a snapshot of the metadata of every file and directory on a
+// volume.
+type Manifest struct {
+	VolumeSerialNumber uint32 `json:"volume_serial_number"`
+
+	// HashName is the registered name of the algorithm that the entries were
+	// hashed with. It is empty if the manifest was built without hashing.
+	HashName string `json:"hash_name,omitempty"`
+
+	Entries []ManifestEntry `json:"entries"`
+}
+
+// BuildManifest records the metadata for every node in the tree that is in
+// use (entries for deleted files are skipped). If `hashName` is not empty, the
+// data of every file is also hashed with the algorithm registered under that
+// name (see RegisterHash); this requires reading all file data. Otherwise only
+// metadata is read.
+func BuildManifest(tree *Tree, hashName string) (manifest *Manifest, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	var newHash HashFactory
+	if hashName != "" {
+		newHash, err = LookupHash(hashName)
+		log.PanicIf(err)
+	}
+
+	manifest = &Manifest{
+		VolumeSerialNumber: tree.er.ActiveBootSectorHeader().VolumeSerialNumber,
+		HashName:           hashName,
+		Entries:            make([]ManifestEntry, 0),
+	}
+
+	cb := func(pathParts []string, node *TreeNode) (err error) {
+		defer func() {
+			if errRaw := recover(); errRaw != nil {
+				err = log.Wrap(errRaw.(error))
+			}
+		}()
+
+		if len(pathParts) == 0 || node.IsInUse() == false {
+			return nil
+		}
+
+		fde := node.FileDirectoryEntry()
+		sede := node.StreamDirectoryEntry()
+
+		me := ManifestEntry{
+			Path:         JoinVolumePath(pathParts),
+			IsDirectory:  node.IsDirectory(),
+			ModifiedTime: fde.LastModifiedTimestamp(),
+			CreatedTime:  fde.CreateTimestamp(),
+		}
+
+		if sede != nil {
+			me.FirstCluster = sede.FirstCluster
+
+			if node.IsDirectory() == false {
+				me.Size = sede.ValidDataLength
+			}
+		}
+
+		if newHash != nil && node.IsDirectory() == false && sede != nil {
+			h := newHash()
+
+			if sede.ValidDataLength > 0 {
+				useFat := sede.GeneralSecondaryFlags.NoFatChain() == false
+
+				_, _, err := tree.er.WriteFromClusterChain(sede.FirstCluster, sede.ValidDataLength, useFat, h)
+				log.PanicIf(err)
+			}
+
+			me.Hash = hex.EncodeToString(h.Sum(nil))
+		}
+
+		manifest.Entries = append(manifest.Entries, me)
+
+		return nil
+	}
+
+	err = tree.Visit(cb)
+	log.PanicIf(err)
+
+	return manifest, nil
+}
+
+// Write serializes the manifest as JSON.
+func (manifest *Manifest) Write(w io.Writer) (err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	e := json.NewEncoder(w)
+	e.SetIndent("", "  ")
+
+	err = e.Encode(manifest)
+	log.PanicIf(err)
+
+	return nil
+}
+
+// ReadManifest deserializes a manifest that was previously written with
+// Write().
+func ReadManifest(r io.Reader) (manifest *Manifest, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	manifest = new(Manifest)
+
+	err = json.NewDecoder(r).Decode(manifest)
+	log.PanicIf(err)
+
+	return manifest, nil
+}
+
+// ChangeType describes how an entry changed between two manifests.
+type ChangeType int
+
+const (
+	// ChangeCreated indicates that the entry only exists in the newer
+	// manifest.
+	ChangeCreated ChangeType = iota
+
+	// ChangeModified indicates that the entry exists in both manifests but its
+	// size, modified-time, or hash differs.
+	ChangeModified
+
+	// ChangeDeleted indicates that the entry only exists in the older
+	// manifest.
+	ChangeDeleted
+)
+
+// String returns a descriptive string.
+func (ct ChangeType) String() string {
+	switch ct {
+	case ChangeCreated:
+		return "created"
+	case ChangeModified:
+		return "modified"
+	case ChangeDeleted:
+		return "deleted"
+	}
+
+	return fmt.Sprintf("unknown(%d)", int(ct))
+}
+
+// MarshalJSON returns the change-type as a JSON string.
+func (ct ChangeType) MarshalJSON() ([]byte, error) {
+	return json.Marshal(ct.String())
+}
+
+// ChangeJournalEntry describes one change between two manifests.
+type ChangeJournalEntry struct {
+	Type ChangeType `json:"type"`
+	Path string     `json:"path"`
+
+	// Timestamp is the modified-time of the newer entry or, for deletions, of
+	// the older entry.
+	Timestamp time.Time `json:"timestamp"`
+
+	// Old is the entry from the older manifest (nil if created).
+	Old *ManifestEntry `json:"old,omitempty"`
+
+	// New is the entry from the newer manifest (nil if deleted).
+	New *ManifestEntry `json:"new,omitempty"`
+}
+
+// String returns a descriptive string.
+func (cje ChangeJournalEntry) String() string {
+	return fmt.Sprintf("ChangeJournalEntry<TYPE=[%s] PATH=[%s] TIMESTAMP=[%s]>", cje.Type, cje.Path, cje.Timestamp)
+}
+
+// isManifestEntryModified indicates whether the file described by the two
+// entries has changed. Hashes are only compared if `compareHashes` is true and
+// both entries have one. Directories are never considered modified (their
+// children are compared individually).
+func isManifestEntryModified(oldEntry, newEntry ManifestEntry, compareHashes bool) bool {
+	if oldEntry.IsDirectory != newEntry.IsDirectory {
+		return true
+	} else if newEntry.IsDirectory == true {
+		return false
+	}
+
+	if oldEntry.Size != newEntry.Size {
+		return true
+	} else if oldEntry.ModifiedTime.Equal(newEntry.ModifiedTime) == false {
+		return true
+	} else if compareHashes == true && oldEntry.Hash != "" && newEntry.Hash != "" && oldEntry.Hash != newEntry.Hash {
+		return true
+	}
+
+	return false
+}
+
+// DiffManifests compares an older and a newer manifest of the same volume and
+// returns the changes, ordered by path. Unchanged files are omitted, so only
+// the returned entries need to be copied (or hashed) to bring an archive up to
+// date. Hashes are only compared if both manifests were hashed with the same
+// algorithm.
+func DiffManifests(oldManifest, newManifest *Manifest) (journal []ChangeJournalEntry) {
+	compareHashes := strings.EqualFold(oldManifest.HashName, newManifest.HashName)
+
+	oldEntries := make(map[string]ManifestEntry, len(oldManifest.Entries))
+	for _, me := range oldManifest.Entries {
+		oldEntries[me.Path] = me
+	}
+
+	journal = make([]ChangeJournalEntry, 0)
+	seen := make(map[string]struct{}, len(newManifest.Entries))
+
+	for i := range newManifest.Entries {
+		newEntry := newManifest.Entries[i]
+		seen[newEntry.Path] = struct{}{}
+
+		oldEntry, found := oldEntries[newEntry.Path]
+		if found == false {
+			cje := ChangeJournalEntry{
+				Type:      ChangeCreated,
+				Path:      newEntry.Path,
+				Timestamp: newEntry.ModifiedTime,
+				New:       &newEntry,
+			}
+
+			journal = append(journal, cje)
+		} else if isManifestEntryModified(oldEntry, newEntry, compareHashes) == true {
+			cje := ChangeJournalEntry{
+				Type:      ChangeModified,
+				Path:      newEntry.Path,
+				Timestamp: newEntry.ModifiedTime,
+				Old:       &oldEntry,
+				New:       &newEntry,
+			}
+
+			journal = append(journal, cje)
+		}
+	}
+
+	for i := range oldManifest.Entries {
+		oldEntry := oldManifest.Entries[i]
+
+		if _, found := seen[oldEntry.Path]; found == true {
+			continue
+		}
+
+		cje := ChangeJournalEntry{
+			Type:      ChangeDeleted,
+			Path:      oldEntry.Path,
+			Timestamp: oldEntry.ModifiedTime,
+			Old:       &oldEntry,
+		}
+
+		journal = append(journal, cje)
+	}
+
+	sort.SliceStable(journal, func(i, j int) bool {
+		return journal[i].Path < journal[j].Path
+	})
+
+	return journal
+}
@@ -0,0 +1,160 @@
+package exfat
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/dsoprea/go-logging"
+)
+
+func TestBuildManifest(t *testing.T) {
+	tree, closer := getTestTree()
+
+	defer closer()
+
+	manifest, err := BuildManifest(tree, "sha1")
+	log.PanicIf(err)
+
+	if len(manifest.Entries) != 10 {
+		t.Fatalf("Entry count not correct: (%d)", len(manifest.Entries))
+	} else if manifest.VolumeSerialNumber != 0x3d51a058 {
+		t.Fatalf("Serial-number not correct: (0x%08x)", manifest.VolumeSerialNumber)
+	} else if manifest.HashName != "sha1" {
+		t.Fatalf("Hash name not correct: [%s]", manifest.HashName)
+	}
+
+	for _, me := range manifest.Entries {
+		if me.Path != "2-delahaye-type-165-cabriolet-dsc_8025.jpg" {
+			continue
+		}
+
+		if me.Size != 313299 {
+			t.Fatalf("Size not correct: (%d)", me.Size)
+		} else if me.Hash != "a2219fa800ae2325003d8d4f5122b37f12f1e18e" {
+			t.Fatalf("Hash not correct: [%s]", me.Hash)
+		}
+
+		return
+	}
+
+	t.Fatalf("Expected file not in manifest.")
+}
+
+func TestManifest_Write_ReadManifest(t *testing.T) {
+	tree, closer := getTestTree()
+
+	defer closer()
+
+	manifest, err := BuildManifest(tree, "")
+	log.PanicIf(err)
+
+	b := new(bytes.Buffer)
+
+	err = manifest.Write(b)
+	log.PanicIf(err)
+
+	recovered, err := ReadManifest(b)
+	log.PanicIf(err)
+
+	if len(recovered.Entries) != len(manifest.Entries) {
+		t.Fatalf("Recovered manifest not correct.")
+	}
+
+	for i, me := range recovered.Entries {
+		original := manifest.Entries[i]
+
+		if me.Path != original.Path || me.Size != original.Size || me.ModifiedTime.Equal(original.ModifiedTime) != true || me.Hash != "" {
+			t.Fatalf("Recovered entry not correct: %s != %s", me, original)
+		}
+	}
+}
+
+func TestDiffManifests(t *testing.T) {
+	now := time.Date(2019, 9, 1, 12, 0, 0, 0, time.UTC)
+	later := now.Add(time.Hour)
+
+	oldManifest := &Manifest{
+		Entries: []ManifestEntry{
+			{Path: "DCIM", IsDirectory: true, ModifiedTime: now},
+			{Path: `DCIM\a.mp4`, Size: 10, ModifiedTime: now},
+			{Path: `DCIM\b.mp4`, Size: 10, ModifiedTime: now},
+			{Path: `DCIM\c.mp4`, Size: 10, ModifiedTime: now, Hash: "aa"},
+			{Path: `DCIM\d.mp4`, Size: 10, ModifiedTime: now},
+		},
+	}
+
+	newManifest := &Manifest{
+		Entries: []ManifestEntry{
+			{Path: "DCIM", IsDirectory: true, ModifiedTime: later},
+			{Path: `DCIM\a.mp4`, Size: 10, ModifiedTime: now},
+			{Path: `DCIM\b.mp4`, Size: 20, ModifiedTime: later},
+			{Path: `DCIM\c.mp4`, Size: 10, ModifiedTime: now, Hash: "bb"},
+			{Path: `DCIM\e.mp4`, Size: 10, ModifiedTime: later},
+		},
+	}
+
+	journal := DiffManifests(oldManifest, newManifest)
+
+	actual := make([]string, len(journal))
+	for i, cje := range journal {
+		actual[i] = cje.Type.String() + " " + cje.Path
+	}
+
+	expected := []string{
+		`modified DCIM\b.mp4`,
+		`modified DCIM\c.mp4`,
+		`deleted DCIM\d.mp4`,
+		`created DCIM\e.mp4`,
+	}
+
+	if reflect.DeepEqual(actual, expected) != true {
+		t.Fatalf("Journal not correct: %v", actual)
+	}
+
+	if journal[0].Timestamp != later || journal[0].Old.Size != 10 || journal[0].New.Size != 20 {
+		t.Fatalf("Modified entry not correct: %s", journal[0])
+	} else if journal[2].New != nil || journal[2].Timestamp != now {
+		t.Fatalf("Deleted entry not correct: %s", journal[2])
+	} else if journal[3].Old != nil {
+		t.Fatalf("Created entry not correct: %s", journal[3])
+	}
+}
+
+func TestDiffManifests__Identical(t *testing.T) {
+	tree, closer := getTestTree()
+
+	defer closer()
+
+	manifest, err := BuildManifest(tree, "")
+	log.PanicIf(err)
+
+	journal := DiffManifests(manifest, manifest)
+	if len(journal) != 0 {
+		t.Fatalf("Expected no changes: %v", journal)
+	}
+}
+
+func TestDiffManifests__DifferentHashAlgorithms(t *testing.T) {
+	now := time.Date(2019, 9, 1, 12, 0, 0, 0, time.UTC)
+
+	oldManifest := &Manifest{
+		HashName: "sha1",
+		Entries: []ManifestEntry{
+			{Path: `DCIM\a.mp4`, Size: 10, ModifiedTime: now, Hash: "aa"},
+		},
+	}
+
+	newManifest := &Manifest{
+		HashName: "md5",
+		Entries: []ManifestEntry{
+			{Path: `DCIM\a.mp4`, Size: 10, ModifiedTime: now, Hash: "bb"},
+		},
+	}
+
+	journal := DiffManifests(oldManifest, newManifest)
+	if len(journal) != 0 {
+		t.Fatalf("Hashes from different algorithms should not be compared: %v", journal)
+	}
+}
@@ -0,0 +1,137 @@
+// This package supports reading images through a memory-mapping.
+
+package exfat
+
+import (
+	"bytes"
+	"errors"
+	"os"
+
+	"github.com/dsoprea/go-logging"
+)
+
+var (
+	// ErrMmapNotSupported is returned by NewExfatReaderFromMmap on platforms
+	// that can not memory-map files.
+	ErrMmapNotSupported = errors.New("memory-mapping not supported on this platform")
+)
+
+// mappedImage is a read-only, memory-mapped image.
+type mappedImage struct {
+	*bytes.Reader
+
+	data []byte
+}
+
+// Close releases the mapping. The data may not be accessed afterward.
+func (mi *mappedImage) Close() (err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	if mi.data == nil {
+		return nil
+	}
+
+	err = munmapFile(mi.data)
+	log.PanicIf(err)
+
+	mi.data = nil
+
+	return nil
+}
+
+// NewExfatReaderFromMmap returns a new ExfatReader that reads the image at the
+// given path through a read-only memory-mapping. Sectors are then passed to
+// visitors directly from the mapping without being copied, and random reads
+// (as the tree and navigator do) avoid a system call each. The image may be
+// read from several goroutines at once. Close() must be called to release the
+// mapping once the reader and any data it returned are no longer needed.
+// Returns ErrMmapNotSupported on platforms that can not map files.
+func NewExfatReaderFromMmap(filepath string) (er *ExfatReader, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	f, err := os.Open(filepath)
+	log.PanicIf(err)
+
+	// The mapping stays valid after the file is closed.
+	defer f.Close()
+
+	fi, err := f.Stat()
+	log.PanicIf(err)
+
+	size := fi.Size()
+	if size == 0 {
+		log.Panicf("image is empty: [%s]", filepath)
+	} else if int64(int(size)) != size {
+		log.Panicf("image is too large to map on this platform: [%s] (%d)", filepath, size)
+	}
+
+	data, err := mmapFile(f, int(size))
+	if err == ErrMmapNotSupported {
+		return nil, err
+	}
+
+	log.PanicIf(err)
+
+	mi := &mappedImage{
+		Reader: bytes.NewReader(data),
+		data:   data,
+	}
+
+	er = NewExfatReader(mi)
+	er.mapped = data
+	er.closer = mi
+
+	return er, nil
+}
+
+// Close releases any resources that the reader acquired itself (e.g. the
+// mapping from NewExfatReaderFromMmap). The ReadSeeker given to
+// NewExfatReader is not owned by the reader and is never closed.
+func (er *ExfatReader) Close() (err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	if er.closer == nil {
+		return nil
+	}
+
+	er.mapped = nil
+
+	err = er.closer.Close()
+	log.PanicIf(err)
+
+	return nil
+}
+
+// mappedRange returns the given range of the image directly from the mapping
+// if the image is mapped, and nil otherwise. The data must not be modified.
+func (er *ExfatReader) mappedRange(offset int64, size int) (data []byte, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	if er.mapped == nil {
+		return nil, nil
+	}
+
+	if offset < 0 || offset+int64(size) > int64(len(er.mapped)) {
+		log.Panicf("range extends past the end of the image: (%d) (%d) > (%d)", offset, size, len(er.mapped))
+	}
+
+	er.countRead(size)
+
+	return er.mapped[offset : offset+int64(size)], nil
+}
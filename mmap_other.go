@@ -0,0 +1,18 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd
+
+// This package stands in for memory-mapping on platforms that don't support it.
+
+package exfat
+
+import (
+	"os"
+)
+
+func mmapFile(f *os.File, size int) (data []byte, err error) {
+	return nil, ErrMmapNotSupported
+}
+
+func munmapFile(data []byte) error {
+	return ErrMmapNotSupported
+}
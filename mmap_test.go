@@ -0,0 +1,70 @@
+package exfat
+
+import (
+	"bytes"
+	"path"
+	"testing"
+
+	"github.com/dsoprea/go-logging"
+)
+
+func TestNewExfatReaderFromMmap(t *testing.T) {
+	er, err := NewExfatReaderFromMmap(path.Join(assetPath, "test.exfat"))
+	if err == ErrMmapNotSupported {
+		t.Skip("Memory-mapping not supported.")
+	}
+
+	log.PanicIf(err)
+
+	defer func() {
+		err := er.Close()
+		log.PanicIf(err)
+	}()
+
+	if er.mapped == nil {
+		t.Fatalf("Expected image to be mapped.")
+	}
+
+	err = er.Parse()
+	log.PanicIf(err)
+
+	tree := NewTree(er)
+
+	err = tree.Load()
+	log.PanicIf(err)
+
+	// Compare against the same file read normally.
+
+	expectedTree, closer := getTestTree()
+
+	defer closer()
+
+	for _, volumePath := range []string{"2-delahaye-type-165-cabriolet-dsc_8025.jpg", `testdirectory\300daec8-cec3-11e9-bfa2-0f240e41d1d8`} {
+		_, expectedData := getTestFileData(expectedTree, volumePath)
+		_, actualData := getTestFileData(tree, volumePath)
+
+		if bytes.Equal(actualData, expectedData) != true {
+			t.Fatalf("Data not correct: [%s]", volumePath)
+		}
+	}
+}
+
+func TestNewExfatReaderFromMmap__NotFound(t *testing.T) {
+	_, err := NewExfatReaderFromMmap(path.Join(assetPath, "does-not-exist"))
+	if err == nil {
+		t.Fatalf("Expected error for missing image.")
+	}
+}
+
+func TestExfatReader_Close__NotOwned(t *testing.T) {
+	f, er := getTestFileAndParser()
+
+	defer f.Close()
+
+	err := er.Close()
+	log.PanicIf(err)
+
+	// The file must still be usable.
+	err = er.Parse()
+	log.PanicIf(err)
+}
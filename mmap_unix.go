@@ -0,0 +1,19 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+// +build darwin dragonfly freebsd linux netbsd openbsd
+
+// This package implements memory-mapping for Unix-like platforms.
+
+package exfat
+
+import (
+	"os"
+	"syscall"
+)
+
+func mmapFile(f *os.File, size int) (data []byte, err error) {
+	return syscall.Mmap(int(f.Fd()), 0, size, syscall.PROT_READ, syscall.MAP_SHARED)
+}
+
+func munmapFile(data []byte) error {
+	return syscall.Munmap(data)
+}
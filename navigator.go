@@ -4,7 +4,10 @@
 package exfat
 
 import (
+	"errors"
 	"fmt"
+	"io"
+	"os"
 	"reflect"
 	"strings"
 
@@ -16,11 +19,45 @@ const (
 	directoryEntryBytesCount = 32
 )
 
+var (
+	// ErrEnumerationCanceled is returned when a progress callback requests
+	// that an enumeration stop early.
+	ErrEnumerationCanceled = errors.New("enumeration canceled")
+)
+
+// EnumerationProgress describes how far an enumeration of a directory has
+// progressed.
+type EnumerationProgress struct {
+	// ClusterCount is the number of clusters visited so far.
+	ClusterCount int
+
+	// SectorCount is the number of sectors visited so far.
+	SectorCount int
+
+	// EntryCount is the number of raw directory-entries visited so far.
+	EntryCount int
+
+	// SetCount is the number of complete entry-sets produced so far.
+	SetCount int
+}
+
+// EnumerationProgressFunc is called periodically (after every cluster) while a
+// directory is enumerated. Returning false cancels the enumeration, which will
+// then fail with ErrEnumerationCanceled.
+type EnumerationProgressFunc func(progress EnumerationProgress) (doContinue bool)
+
 // ExfatNavigator knows how to get and manipulate the entries of a single
 // directory.
 type ExfatNavigator struct {
 	er                 *ExfatReader
 	firstClusterNumber uint32
+
+	// dataLength is the size of the directory, if known (see
+	// SetDataLength()).
+	dataLength        uint64
+	isDataLengthKnown bool
+
+	progressCb EnumerationProgressFunc
 }
 
 // NewExfatNavigator returns a new ExfatNavigator instance.
@@ -31,13 +68,75 @@ func NewExfatNavigator(er *ExfatReader, firstClusterNumber uint32) (en *ExfatNav
 	}
 }
 
+// SetProgressCallback sets a callback that is periodically invoked during
+// enumeration so that callers can show activity for, or cancel the indexing
+// of, very large directories.
+func (en *ExfatNavigator) SetProgressCallback(cb EnumerationProgressFunc) {
+	en.progressCb = cb
+}
+
+// SetDataLength sets the size of the directory, from the DataLength of its
+// Stream Extension entry, so that enumeration stops at the end of the
+// directory even if it has no end-of-directory entry. Otherwise (e.g. for the
+// root directory, which doesn't have one), enumeration is only bounded by the
+// size of the cluster heap.
+func (en *ExfatNavigator) SetDataLength(dataLength uint64) {
+	en.dataLength = dataLength
+	en.isDataLengthKnown = true
+}
+
+// enumerateDirectoryClusters visits the clusters of the directory, starting
+// from the given one, up to the end of the directory if its size is known.
+func (en *ExfatNavigator) enumerateDirectoryClusters(startingClusterNumber uint32, cb ClusterVisitorFunc) (err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	// The specification is unclear whether the directory-entry clusters are
+	// inline (useFat == false) or use the FAT. However, this seems to imply
+	// that it's one long chain:
+	//
+	// (from the 6.13 "Directory Structure" table):
+	//
+	// 	"N, the number of DirectoryEntry fields, is the size, in bytes, of the
+	// 	cluster chain which contains the given directory, divided by the size of
+	// 	a DirectoryEntry field, 32 bytes."
+	//
+	// So, we'll instruct the enumerator to visit adjacent cluster chains.
+	useFat := false
+
+	if en.isDataLengthKnown == false {
+		err = en.er.EnumerateClusters(startingClusterNumber, cb, useFat)
+		log.PanicIf(err)
+
+		return nil
+	}
+
+	// Since the chain is contiguous, the clusters that were skipped come off
+	// of the total.
+	clusterCount := en.er.ClusterCountForLength(en.dataLength)
+	skippedCount := uint64(startingClusterNumber - en.firstClusterNumber)
+
+	if skippedCount >= clusterCount {
+		return nil
+	}
+
+	err = en.er.EnumerateClustersLimited(startingClusterNumber, clusterCount-skippedCount, cb, useFat)
+	log.PanicIf(err)
+
+	return nil
+}
+
 // DirectoryEntryVisitorFunc is a function type used as a callback over each
 // file directory entry.
 type DirectoryEntryVisitorFunc func(primaryEntry DirectoryEntry, secondaryEntries []DirectoryEntry) (err error)
 
 // EnumerateDirectoryEntries will enumerate each primary directory entry
 // associated with the given file along with an secondary entries that they're
-// associated with.
+// associated with. Use EnumerateEntrySets() to also get where each entry is
+// stored.
 func (en *ExfatNavigator) EnumerateDirectoryEntries(cb DirectoryEntryVisitorFunc) (visitedClusters, visitedSectors []uint32, err error) {
 	defer func() {
 		if errRaw := recover(); errRaw != nil {
@@ -45,19 +144,72 @@ func (en *ExfatNavigator) EnumerateDirectoryEntries(cb DirectoryEntryVisitorFunc
 		}
 	}()
 
-	// TODO(dustin): Add test.
+	esvf := func(es *EntrySet) (err error) {
+		return cb(es.PrimaryEntry, es.SecondaryEntries)
+	}
+
+	visitedClusters, visitedSectors, err = en.EnumerateEntrySets(esvf)
+	log.PanicIf(err)
+
+	return visitedClusters, visitedSectors, nil
+}
+
+// EntrySetVisitorFunc is a function type used as a callback over each
+// directory-entry set.
+type EntrySetVisitorFunc func(es *EntrySet) (err error)
+
+// EnumerateEntrySets will enumerate each primary directory entry in the
+// directory bundled with its secondary entries, their locations, and their raw
+// data.
+func (en *ExfatNavigator) EnumerateEntrySets(cb EntrySetVisitorFunc) (visitedClusters, visitedSectors []uint32, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	visitedClusters, visitedSectors, err = en.enumerateEntrySetsFrom(en.firstClusterNumber, cb)
+	log.PanicIf(err)
+
+	return visitedClusters, visitedSectors, nil
+}
+
+// enumerateEntrySetsFrom enumerates the entry-sets starting from the given
+// cluster in the directory's chain (rather than from the first cluster). Any
+// secondary entries at the start of that cluster that belong to a set that
+// started in a previous cluster are ignored.
+func (en *ExfatNavigator) enumerateEntrySetsFrom(startingClusterNumber uint32, cb EntrySetVisitorFunc) (visitedClusters, visitedSectors []uint32, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	if startingClusterNumber < en.firstClusterNumber {
+		log.Panicf("starting cluster precedes the first cluster of the directory: (%d) < (%d)", startingClusterNumber, en.firstClusterNumber)
+	}
 
 	// Enumerate clusters.
 
-	entryNumber := 0
+	// Directory chains are always contiguous (see
+	// enumerateDirectoryClusters()), so the entry-numbers can be calculated
+	// directly from the cluster's position in the chain.
+	entriesPerCluster := int(en.er.SectorsPerCluster() * en.er.SectorSize() / directoryEntryBytesCount)
+	entryNumber := int(startingClusterNumber-en.firstClusterNumber) * entriesPerCluster
+	setCount := 0
 	isDone := false
 
-	var primaryEntry DirectoryEntry
-	var secondaryEntries []DirectoryEntry
+	// The assembler holds on to a set until its last entry is found, so sets
+	// that straddle a sector or cluster boundary are assembled the same way
+	// as any other. Only the raw bytes of each entry are kept (copied), since
+	// the sector buffers are reused.
+	esa := new(entrySetAssembler)
 
 	visitedClusters = make([]uint32, 0)
 	visitedSectors = make([]uint32, 0)
 
+	sectorSize := en.er.SectorSize()
+
 	cvf := func(ec *ExfatCluster) (doContinue bool, err error) {
 		defer func() {
 			if errRaw := recover(); errRaw != nil {
@@ -74,6 +226,8 @@ func (en *ExfatNavigator) EnumerateDirectoryEntries(cb DirectoryEntryVisitorFunc
 
 		// Enumerate sectors.
 
+		sectorIndex := uint32(0)
+
 		svf := func(sectorNumber uint32, data []byte) (doContinue bool, err error) {
 			defer func() {
 				if errRaw := recover(); errRaw != nil {
@@ -87,7 +241,16 @@ func (en *ExfatNavigator) EnumerateDirectoryEntries(cb DirectoryEntryVisitorFunc
 			}()
 
 			visitedSectors = append(visitedSectors, sectorNumber)
-			sectorSize := en.er.SectorSize()
+
+			sectorOffset := ec.clusterOffset + int64(sectorIndex)*int64(sectorSize)
+
+			// A root directory that was never written on erased flash reads
+			// as 0xFF, which isn't a valid entry-type. Treat it as empty
+			// (see EmptyRootPolicy) rather than as a corrupt entry.
+			if entryNumber == 0 && en.firstClusterNumber == en.er.FirstClusterOfRootDirectory() && isFilledWith(data, 0xff) == true {
+				isDone = true
+				return false, nil
+			}
 
 			i := 0
 			for {
@@ -101,42 +264,29 @@ func (en *ExfatNavigator) EnumerateDirectoryEntries(cb DirectoryEntryVisitorFunc
 					return false, nil
 				}
 
-				de, err := parseDirectoryEntry(entryType, directoryEntryData)
-				log.PanicIf(err)
+				location := EntryLocation{
+					ClusterNumber: ec.ClusterNumber(),
+					SectorIndex:   sectorIndex,
+					SectorNumber:  uint64(sectorOffset) / uint64(sectorSize),
+					Offset:        sectorOffset + int64(i*directoryEntryBytesCount),
+					EntryNumber:   entryNumber,
+				}
 
-				if entryType.IsPrimary() == true {
-					primaryEntry = de
+				de, err := esa.parse(entryType, directoryEntryData)
+				if err != nil {
+					ce := newCorruptionError("directory entry", err)
+					ce.Offset = location.Offset
+					ce.ClusterNumber = location.ClusterNumber
+					ce.EntryIndex = location.EntryNumber
 
-					// We'll always overwrite the primary as part of our
-					// process. Note that any secordary entries that we
-					// encounter will be appended to `secondaryEntries` but
-					// unless the last primary entry indicate that it wanted any
-					// of those secondary entries, they'll be forgotten.
-					secondaryEntries = make([]DirectoryEntry, 0)
-				} else {
-					secondaryEntries = append(secondaryEntries, de)
+					log.Panic(ce)
 				}
 
-				// If the primary entry did not have a secondary entry
-				// requirement, or it did and we've met it, call the callback.
-				if pde, ok := primaryEntry.(PrimaryDirectoryEntry); ok == true {
-					if len(secondaryEntries) == int(pde.SecondaryCount()) {
-						err := cb(primaryEntry, secondaryEntries)
-						log.PanicIf(err)
-					}
-				} else if entryType.IsPrimary() == true {
-					// We're conceding the presence of primary entry-types that
-					// don't necessarily have a SecondaryCount field (which is
-					// the qualification to be considered a
-					// `PrimaryDirectoryEntry`). Therefore, if our primary was
-					// not a `PrimaryDirectoryEntry` *but* it's still
-					// purportedly a primary entry, call the callback with an
-					// empty list for the secondary entries (the
-					// `secondaryEntries` entry list will always be empty here
-					// due to above).
-
-					err := cb(primaryEntry, secondaryEntries)
+				if es := esa.add(entryType, de, location, directoryEntryData); es != nil {
+					err := cb(es)
 					log.PanicIf(err)
+
+					setCount++
 				}
 
 				entryNumber++
@@ -148,12 +298,27 @@ func (en *ExfatNavigator) EnumerateDirectoryEntries(cb DirectoryEntryVisitorFunc
 				}
 			}
 
+			sectorIndex++
+
 			return true, nil
 		}
 
 		err = ec.EnumerateSectors(svf)
 		log.PanicIf(err)
 
+		if en.progressCb != nil {
+			progress := EnumerationProgress{
+				ClusterCount: len(visitedClusters),
+				SectorCount:  len(visitedSectors),
+				EntryCount:   entryNumber,
+				SetCount:     setCount,
+			}
+
+			if en.progressCb(progress) == false {
+				log.Panic(ErrEnumerationCanceled)
+			}
+		}
+
 		if isDone == true {
 			return false, nil
 		}
@@ -161,31 +326,86 @@ func (en *ExfatNavigator) EnumerateDirectoryEntries(cb DirectoryEntryVisitorFunc
 		return true, nil
 	}
 
-	// The specification is unclear whether the directory-entry clusters are
-	// inline (useFat == false) or use the FAT. However, this seems to imply
-	// that it's one long chain:
-	//
-	// (from the 6.13 "Directory Structure" table):
-	//
-	// 	"N, the number of DirectoryEntry fields, is the size, in bytes, of the
-	// 	cluster chain which contains the given directory, divided by the size of
-	// 	a DirectoryEntry field, 32 bytes."
-	//
-	// So, we'll instruct the enumerator to visit adjacent cluster chains.
-	useFat := false
-
-	err = en.er.EnumerateClusters(en.firstClusterNumber, cvf, useFat)
+	err = en.enumerateDirectoryClusters(startingClusterNumber, cvf)
 	log.PanicIf(err)
 
 	return visitedClusters, visitedSectors, nil
 }
 
 // IndexedDirectoryEntry is an organization type that the raw directory entries
-// associated with a primary directory entry are assigned into.
+// associated with a primary directory entry are assigned into. When it was
+// indexed from a directory, EntrySet is the authoritative record of the
+// entries and the other fields are derived from it.
 type IndexedDirectoryEntry struct {
+	// PrimaryEntry and SecondaryEntries are the same values as
+	// EntrySet.PrimaryEntry and EntrySet.SecondaryEntries (the slice is shared,
+	// not copied), so they must not be modified. They predate EntrySet and are
+	// kept for existing callers; new code should use EntrySet.
 	PrimaryEntry     DirectoryEntry
 	SecondaryEntries []DirectoryEntry
-	Extra            map[string]interface{}
+
+	// Filename is the complete filename for file entries and empty for every
+	// other type.
+	Filename string
+
+	// Extra is arbitrary data. The indexer doesn't set it, so that indexing
+	// doesn't cost an allocation for every file.
+	//
+	// Deprecated: Use Filename, or ExtraData() for the old contents.
+	Extra map[string]interface{}
+
+	// EntrySet is the complete set that the entries were read from, including
+	// their locations and raw data.
+	EntrySet *EntrySet
+
+	// Location is where the primary entry is stored. The locations of the
+	// secondary entries are in `EntrySet.Locations` (the set is always
+	// contiguous).
+	Location EntryLocation
+}
+
+// newIndexedDirectoryEntry returns the IndexedDirectoryEntry for the given
+// set.
+func newIndexedDirectoryEntry(es *EntrySet) IndexedDirectoryEntry {
+	ide := IndexedDirectoryEntry{
+		PrimaryEntry:     es.PrimaryEntry,
+		SecondaryEntries: es.SecondaryEntries,
+		Filename:         es.Filename(),
+		EntrySet:         es,
+		Location:         es.Location(),
+	}
+
+	return ide
+}
+
+// ExtraData returns `Extra` if it was set. Otherwise, for file entries, it
+// returns what the indexer used to set: "complete_filename" with the same
+// value as `Filename`. This is built on every call.
+func (ide IndexedDirectoryEntry) ExtraData() map[string]interface{} {
+	if ide.Extra != nil {
+		return ide.Extra
+	}
+
+	if _, ok := ide.PrimaryEntry.(*ExfatFileDirectoryEntry); ok == false {
+		return nil
+	}
+
+	extra := map[string]interface{}{
+		"complete_filename": ide.Filename,
+	}
+
+	return extra
+}
+
+// RawEntries returns the primary and secondary entries along with their raw
+// bytes and on-disk locations. It is empty if the entry was not indexed from
+// an entry-set.
+func (ide IndexedDirectoryEntry) RawEntries() []RawDirectoryEntry {
+	if ide.EntrySet == nil {
+		return nil
+	}
+
+	return ide.EntrySet.RawEntries()
 }
 
 // DirectoryEntryIndex is a collection of all indexed-directory-entries in a
@@ -194,43 +414,54 @@ type DirectoryEntryIndex map[string][]IndexedDirectoryEntry
 
 // Dump prints a bunch of information about an index.
 func (dei DirectoryEntryIndex) Dump() {
-	fmt.Printf("Directory Entry Index\n")
-	fmt.Printf("=====================\n")
-	fmt.Printf("\n")
+	dei.DumpTo(os.Stdout)
+}
+
+// DumpTo is the same as Dump() but writes to the given writer.
+func (dei DirectoryEntryIndex) DumpTo(w io.Writer) {
+	fmt.Fprintf(w, "Directory Entry Index\n")
+	fmt.Fprintf(w, "=====================\n")
+	fmt.Fprintf(w, "\n")
 
 	for typeName, ideList := range dei {
-		fmt.Printf("%s\n", typeName)
-		fmt.Println(strings.Repeat("-", len(typeName)))
-		fmt.Printf("\n")
+		fmt.Fprintf(w, "%s\n", typeName)
+		fmt.Fprintln(w, strings.Repeat("-", len(typeName)))
+		fmt.Fprintf(w, "\n")
 
 		for i, ide := range ideList {
-			fmt.Printf("# %d\n", i)
-			fmt.Printf("\n")
+			fmt.Fprintf(w, "# %d\n", i)
+			fmt.Fprintf(w, "\n")
 
-			fmt.Printf("  Primary: %s\n", ide.PrimaryEntry)
+			fmt.Fprintf(w, "  Primary: %s\n", ide.PrimaryEntry)
 
 			for j, secondaryEntry := range ide.SecondaryEntries {
-				fmt.Printf("  Secondary (%d): %s\n", j, secondaryEntry)
+				fmt.Fprintf(w, "  Secondary (%d): %s\n", j, secondaryEntry)
 			}
 
-			fmt.Printf("\n")
+			fmt.Fprintf(w, "\n")
 
-			if len(ide.Extra) > 0 {
-				fmt.Printf("  Extra:\n")
+			if extra := ide.ExtraData(); len(extra) > 0 {
+				fmt.Fprintf(w, "  Extra:\n")
 
-				for k, v := range ide.Extra {
-					fmt.Printf("    %s: %s\n", k, v)
+				for k, v := range extra {
+					fmt.Fprintf(w, "    %s: %s\n", k, v)
 				}
 
-				fmt.Printf("\n")
+				fmt.Fprintf(w, "\n")
 			}
 
 			if fdf, ok := ide.PrimaryEntry.(*ExfatFileDirectoryEntry); ok == true {
-				fmt.Printf("  Attributes:\n")
+				fmt.Fprintf(w, "  Attributes:\n")
+
+				fdf.FileAttributes.DumpBareIndentedTo(w, "    ")
 
-				fdf.FileAttributes.DumpBareIndented("    ")
+				fmt.Fprintf(w, "\n")
+			} else if vgde, ok := ide.PrimaryEntry.(*ExfatVolumeGuidDirectoryEntry); ok == true {
+				fmt.Fprintf(w, "  General Primary Flags:\n")
 
-				fmt.Printf("\n")
+				vgde.GeneralPrimaryFlags.DumpBareIndentedTo(w, "    ")
+
+				fmt.Fprintf(w, "\n")
 			}
 		}
 	}
@@ -243,8 +474,7 @@ func (dei DirectoryEntryIndex) Filenames() (filenames map[string]bool) {
 	if found == true {
 		filenames = make(map[string]bool, len(fileIdeList))
 		for _, ide := range fileIdeList {
-			filename := ide.Extra["complete_filename"].(string)
-			filenames[filename] = ide.PrimaryEntry.(*ExfatFileDirectoryEntry).FileAttributes.IsDirectory()
+			filenames[ide.Filename] = ide.PrimaryEntry.(*ExfatFileDirectoryEntry).FileAttributes.IsDirectory()
 		}
 	} else {
 		filenames = make(map[string]bool, 0)
@@ -265,14 +495,14 @@ func (dei DirectoryEntryIndex) FileCount() (count int) {
 // GetFile returns the file directory-entry with index `i`.
 func (dei DirectoryEntryIndex) GetFile(i int) (filename string, fdf *ExfatFileDirectoryEntry) {
 	ide := dei["File"][i]
-	return ide.Extra["complete_filename"].(string), ide.PrimaryEntry.(*ExfatFileDirectoryEntry)
+	return ide.Filename, ide.PrimaryEntry.(*ExfatFileDirectoryEntry)
 }
 
 // FindIndexedFile returns an IDE for a given file.
 func (dei DirectoryEntryIndex) FindIndexedFile(filename string) (ide IndexedDirectoryEntry, found bool) {
 	for i := 0; i < dei.FileCount(); i++ {
 		ide := dei["File"][i]
-		if ide.Extra["complete_filename"].(string) == filename {
+		if ide.Filename == filename {
 			return ide, true
 		}
 	}
@@ -345,23 +575,10 @@ func (en *ExfatNavigator) IndexDirectoryEntries() (index DirectoryEntryIndex, vi
 
 	index = make(DirectoryEntryIndex)
 
-	cb := func(primaryEntry DirectoryEntry, secondaryEntries []DirectoryEntry) (err error) {
-		extra := make(map[string]interface{})
+	cb := func(es *EntrySet) (err error) {
+		ide := newIndexedDirectoryEntry(es)
 
-		ide := IndexedDirectoryEntry{
-			PrimaryEntry:     primaryEntry,
-			SecondaryEntries: secondaryEntries,
-			Extra:            extra,
-		}
-
-		if _, ok := primaryEntry.(*ExfatFileDirectoryEntry); ok == true {
-			mf := MultipartFilename(secondaryEntries)
-			completeFilename := mf.Filename()
-
-			extra["complete_filename"] = completeFilename
-		}
-
-		typeName := primaryEntry.TypeName()
+		typeName := es.PrimaryEntry.TypeName()
 		if ideList, found := index[typeName]; found == true {
 			index[typeName] = append(ideList, ide)
 		} else {
@@ -371,8 +588,17 @@ func (en *ExfatNavigator) IndexDirectoryEntries() (index DirectoryEntryIndex, vi
 		return nil
 	}
 
-	visitedClusters, visitedSectors, err = en.EnumerateDirectoryEntries(cb)
+	entrySetCount := 0
+
+	countingCb := func(es *EntrySet) (err error) {
+		entrySetCount++
+		return cb(es)
+	}
+
+	visitedClusters, visitedSectors, err = en.EnumerateEntrySets(countingCb)
 	log.PanicIf(err)
 
+	en.er.debug(EventDirectoryIndexed, "first_cluster", en.firstClusterNumber, "entry_sets", entrySetCount, "clusters", len(visitedClusters))
+
 	return index, visitedClusters, visitedSectors, nil
 }
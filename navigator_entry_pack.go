@@ -0,0 +1,227 @@
+// This package serializes directory entries back to their on-disk form.
+
+package exfat
+
+import (
+	"bytes"
+
+	"github.com/dsoprea/go-logging"
+	"github.com/go-restruct/restruct"
+)
+
+// packDirectoryEntry serializes any directory-entry struct with the same
+// layout that parseDirectoryEntry() reads.
+func packDirectoryEntry(de interface{}) (raw []byte, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	raw, err = restruct.Pack(defaultEncoding, de)
+	log.PanicIf(err)
+
+	if len(raw) != directoryEntryBytesCount {
+		log.Panicf("packed directory-entry is the wrong size: (%d)", len(raw))
+	}
+
+	return raw, nil
+}
+
+// MarshalBinary returns the raw directory-entry.
+func (fdf ExfatFileDirectoryEntry) MarshalBinary() (raw []byte, err error) {
+	raw = make([]byte, directoryEntryBytesCount)
+
+	raw[0] = uint8(fdf.EntryType)
+	raw[1] = fdf.SecondaryCountRaw
+	defaultEncoding.PutUint16(raw[2:4], fdf.SetChecksum)
+	defaultEncoding.PutUint16(raw[4:6], uint16(fdf.FileAttributes))
+	defaultEncoding.PutUint16(raw[6:8], fdf.Reserved1)
+	defaultEncoding.PutUint32(raw[8:12], uint32(fdf.CreateTimestampRaw))
+	defaultEncoding.PutUint32(raw[12:16], uint32(fdf.LastModifiedTimestampRaw))
+	defaultEncoding.PutUint32(raw[16:20], uint32(fdf.LastAccessedTimestampRaw))
+	raw[20] = fdf.Create10msIncrement
+	raw[21] = fdf.LastModified10msIncrement
+	raw[22] = fdf.CreateUtcOffset
+	raw[23] = fdf.LastModifiedUtcOffset
+	raw[24] = fdf.LastAccessedUtcOffset
+	copy(raw[25:32], fdf.Reserved2[:])
+
+	return raw, nil
+}
+
+// MarshalBinary returns the raw directory-entry.
+func (sede ExfatStreamExtensionDirectoryEntry) MarshalBinary() (raw []byte, err error) {
+	raw = make([]byte, directoryEntryBytesCount)
+
+	raw[0] = uint8(sede.EntryType)
+	raw[1] = uint8(sede.GeneralSecondaryFlags)
+	raw[2] = sede.Reserved1[0]
+	raw[3] = sede.NameLength
+	defaultEncoding.PutUint16(raw[4:6], sede.NameHash)
+	copy(raw[6:8], sede.Reserved2[:])
+	defaultEncoding.PutUint64(raw[8:16], sede.ValidDataLength)
+	copy(raw[16:20], sede.Reserved3[:])
+	defaultEncoding.PutUint32(raw[20:24], sede.FirstCluster)
+	defaultEncoding.PutUint64(raw[24:32], sede.DataLength)
+
+	return raw, nil
+}
+
+// MarshalBinary returns the raw directory-entry.
+func (fnde ExfatFileNameDirectoryEntry) MarshalBinary() (raw []byte, err error) {
+	raw = make([]byte, directoryEntryBytesCount)
+
+	raw[0] = uint8(fnde.EntryType)
+	raw[1] = uint8(fnde.GeneralSecondaryFlags)
+	copy(raw[2:32], fnde.FileName[:])
+
+	return raw, nil
+}
+
+// MarshalBinary returns the raw directory-entry.
+func (abde ExfatAllocationBitmapDirectoryEntry) MarshalBinary() (raw []byte, err error) {
+	return packDirectoryEntry(&abde)
+}
+
+// MarshalBinary returns the raw directory-entry.
+func (utde ExfatUpcaseTableDirectoryEntry) MarshalBinary() (raw []byte, err error) {
+	return packDirectoryEntry(&utde)
+}
+
+// MarshalBinary returns the raw directory-entry.
+func (vlde ExfatVolumeLabelDirectoryEntry) MarshalBinary() (raw []byte, err error) {
+	return packDirectoryEntry(&vlde)
+}
+
+// MarshalBinary returns the raw directory-entry.
+func (vgde ExfatVolumeGuidDirectoryEntry) MarshalBinary() (raw []byte, err error) {
+	return packDirectoryEntry(&vgde)
+}
+
+// MarshalBinary returns the raw directory-entry.
+func (tfde ExfatTexFATDirectoryEntry) MarshalBinary() (raw []byte, err error) {
+	return packDirectoryEntry(&tfde)
+}
+
+// MarshalBinary returns the raw directory-entry.
+func (vede ExfatVendorExtensionDirectoryEntry) MarshalBinary() (raw []byte, err error) {
+	return packDirectoryEntry(&vede)
+}
+
+// MarshalBinary returns the raw directory-entry.
+func (vade ExfatVendorAllocationDirectoryEntry) MarshalBinary() (raw []byte, err error) {
+	return packDirectoryEntry(&vade)
+}
+
+// PackDirectoryEntry returns the raw form of any of the directory-entry types.
+func PackDirectoryEntry(de DirectoryEntry) (raw []byte, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	marshaler, ok := de.(interface {
+		MarshalBinary() ([]byte, error)
+	})
+
+	if ok == false {
+		log.Panicf("directory-entry can not be packed: [%s]", de.TypeName())
+	}
+
+	raw, err = marshaler.MarshalBinary()
+	log.PanicIf(err)
+
+	return raw, nil
+}
+
+// EntrySetChecksum calculates the set-checksum over the raw data of all entries
+// in a set, starting with the primary, as described by Section 6.3.3 (the
+// checksum field itself is skipped).
+func EntrySetChecksum(raw []byte) uint16 {
+	checksum := uint16(0)
+
+	for i, c := range raw {
+		if i == 2 || i == 3 {
+			continue
+		}
+
+		if checksum&1 > 0 {
+			checksum = 0x8000 + (checksum >> 1) + uint16(c)
+		} else {
+			checksum = (checksum >> 1) + uint16(c)
+		}
+	}
+
+	return checksum
+}
+
+// PackEntrySet packs the primary entry and its secondary entries and, if the
+// primary entry-type carries a set-checksum, stores a freshly-calculated one
+// in the packed data. The entries themselves are not modified.
+func PackEntrySet(primaryEntry DirectoryEntry, secondaryEntries []DirectoryEntry) (raw []byte, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	b := new(bytes.Buffer)
+
+	entries := append([]DirectoryEntry{primaryEntry}, secondaryEntries...)
+	for _, de := range entries {
+		current, err := PackDirectoryEntry(de)
+		log.PanicIf(err)
+
+		b.Write(current)
+	}
+
+	raw = b.Bytes()
+
+	switch primaryEntry.(type) {
+	case *ExfatFileDirectoryEntry, ExfatFileDirectoryEntry, *ExfatVolumeGuidDirectoryEntry, ExfatVolumeGuidDirectoryEntry:
+		defaultEncoding.PutUint16(raw[2:4], EntrySetChecksum(raw))
+	}
+
+	return raw, nil
+}
+
+// NewEntrySet returns the EntrySet for the given primary entry and its
+// secondary entries, as they would be stored. It carries the packed data and,
+// where the primary entry-type has one, the calculated set-checksum. Since the
+// set hasn't been written anywhere, the locations only have the offset and
+// number of each entry within the set.
+func NewEntrySet(primaryEntry DirectoryEntry, secondaryEntries []DirectoryEntry) (es *EntrySet, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	raw, err := PackEntrySet(primaryEntry, secondaryEntries)
+	log.PanicIf(err)
+
+	es, err = ParseDirectoryEntrySet(raw)
+	log.PanicIf(err)
+
+	return es, nil
+}
+
+// NameHash calculates the hash of a filename that is stored in the stream-
+// extension entry (Section 7.6.4). It's calculated over the up-cased name.
+func (ut *UpcaseTable) NameHash(name string) uint16 {
+	hash := uint16(0)
+
+	for _, unit := range ut.upcaseUnits(name) {
+		for _, c := range []byte{uint8(unit), uint8(unit >> 8)} {
+			if hash&1 > 0 {
+				hash = 0x8000 + (hash >> 1) + uint16(c)
+			} else {
+				hash = (hash >> 1) + uint16(c)
+			}
+		}
+	}
+
+	return hash
+}
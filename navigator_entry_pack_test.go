@@ -0,0 +1,180 @@
+package exfat
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/dsoprea/go-logging"
+)
+
+func TestPackDirectoryEntry__RoundTrip(t *testing.T) {
+	sets := getRootEntrySets()
+
+	for _, es := range sets {
+		for i := 0; i < es.EntryCount(); i++ {
+			raw, err := PackDirectoryEntry(es.Entry(i))
+			log.PanicIf(err)
+
+			if bytes.Equal(raw, es.RawEntry(i)) != true {
+				t.Fatalf("Packed entry (%d) does not match the original: %s\n%x\n%x", i, es, raw, es.RawEntry(i))
+			}
+		}
+	}
+}
+
+func TestPackDirectoryEntry__AllTypes(t *testing.T) {
+	for depk := range directoryEntryParsers {
+		entryType := uint8(0x80) | uint8(depk.typeCode)
+		if depk.isCritical == false {
+			entryType |= 0x20
+		}
+
+		if depk.isPrimary == false {
+			entryType |= 0x40
+		}
+
+		original := make([]byte, directoryEntryBytesCount)
+		for i := range original {
+			original[i] = uint8(i * 7)
+		}
+
+		original[0] = entryType
+
+		de, err := parseDirectoryEntry(EntryType(entryType), original)
+		log.PanicIf(err)
+
+		raw, err := PackDirectoryEntry(de)
+		log.PanicIf(err)
+
+		if bytes.Equal(raw, original) != true {
+			t.Fatalf("Packed entry does not match the original: [%s]\n%x\n%x", de.TypeName(), raw, original)
+		}
+	}
+}
+
+func TestPackEntrySet(t *testing.T) {
+	sets := getRootEntrySets()
+
+	checked := 0
+	for _, es := range sets {
+		if es.IsInUse() == false {
+			continue
+		}
+
+		raw, err := PackEntrySet(es.PrimaryEntry, es.SecondaryEntries)
+		log.PanicIf(err)
+
+		if bytes.Equal(raw, es.Raw) != true {
+			t.Fatalf("Packed set does not match the original: %s", es)
+		}
+
+		checked++
+	}
+
+	if checked == 0 {
+		t.Fatalf("No sets checked.")
+	}
+}
+
+func TestPackEntrySet__RecalculatesChecksum(t *testing.T) {
+	sets := getRootEntrySets()
+
+	for _, es := range sets {
+		fdf, ok := es.PrimaryEntry.(*ExfatFileDirectoryEntry)
+		if ok == false || es.IsInUse() == false {
+			continue
+		}
+
+		// Change the file without updating the checksum.
+
+		modified := *fdf
+		modified.FileAttributes ^= 1
+
+		raw, err := PackEntrySet(&modified, es.SecondaryEntries)
+		log.PanicIf(err)
+
+		storedChecksum := defaultEncoding.Uint16(raw[2:4])
+
+		if storedChecksum != EntrySetChecksum(raw) {
+			t.Fatalf("Checksum not updated: (0x%04x)", storedChecksum)
+		} else if storedChecksum == fdf.SetChecksum {
+			t.Fatalf("Expected the checksum to change.")
+		} else if modified.SetChecksum != fdf.SetChecksum {
+			t.Fatalf("Entry should not have been modified.")
+		}
+
+		return
+	}
+
+	t.Fatalf("No file sets found.")
+}
+
+func TestUpcaseTable_NameHash(t *testing.T) {
+	f, er := getTestFileAndParser()
+
+	defer f.Close()
+
+	err := er.Parse()
+	log.PanicIf(err)
+
+	ut, err := er.ReadUpcaseTable()
+	log.PanicIf(err)
+
+	en := NewExfatNavigator(er, er.FirstClusterOfRootDirectory())
+
+	checked := 0
+
+	cb := func(es *EntrySet) (err error) {
+		if es.PrimaryEntry.TypeName() != "File" {
+			return nil
+		}
+
+		sede := es.SecondaryEntries[0].(*ExfatStreamExtensionDirectoryEntry)
+
+		if ut.NameHash(es.Filename()) != sede.NameHash {
+			t.Fatalf("Name hash not correct: [%s] (0x%04x) != (0x%04x)", es.Filename(), ut.NameHash(es.Filename()), sede.NameHash)
+		}
+
+		checked++
+
+		return nil
+	}
+
+	_, _, err = en.EnumerateEntrySets(cb)
+	log.PanicIf(err)
+
+	if checked == 0 {
+		t.Fatalf("No files checked.")
+	}
+}
+
+func TestNewEntrySet(t *testing.T) {
+	sets := getRootEntrySets()
+
+	for _, es := range sets {
+		fdf, ok := es.PrimaryEntry.(*ExfatFileDirectoryEntry)
+		if ok == false || es.IsInUse() == false {
+			continue
+		}
+
+		modified := *fdf
+		modified.FileAttributes ^= 1
+
+		newEs, err := NewEntrySet(&modified, es.SecondaryEntries)
+		log.PanicIf(err)
+
+		if newEs.EntryCount() != es.EntryCount() {
+			t.Fatalf("Entry count not correct: (%d)", newEs.EntryCount())
+		} else if newEs.IsChecksumValid() != true {
+			t.Fatalf("Checksum not valid: %s", newEs)
+		} else if newEs.Filename() != es.Filename() {
+			t.Fatalf("Filename not correct: [%s]", newEs.Filename())
+		} else if newEs.PrimaryEntry.(*ExfatFileDirectoryEntry).FileAttributes != modified.FileAttributes {
+			t.Fatalf("Attributes not correct.")
+		}
+
+		return
+	}
+
+	t.Fatalf("No file sets found.")
+}
@@ -0,0 +1,290 @@
+package exfat
+
+import (
+	"fmt"
+)
+
+// EntryLocation describes where a single directory-entry is stored.
+type EntryLocation struct {
+	// ClusterNumber is the cluster that the entry is stored in.
+	ClusterNumber uint32 `json:"cluster_number" yaml:"cluster_number"`
+
+	// SectorIndex is the index of the sector within the cluster.
+	SectorIndex uint32 `json:"sector_index" yaml:"sector_index"`
+
+	// SectorNumber is the absolute number of the sector within the volume.
+	SectorNumber uint64 `json:"sector_number" yaml:"sector_number"`
+
+	// Offset is the absolute byte offset of the entry within the image.
+	Offset int64 `json:"offset" yaml:"offset"`
+
+	// EntryNumber is the position of the entry within its directory.
+	EntryNumber int `json:"entry_number" yaml:"entry_number"`
+}
+
+// String returns a descriptive string.
+func (el EntryLocation) String() string {
+	return fmt.Sprintf("EntryLocation<CLUSTER=(%d) SECTOR-INDEX=(%d) SECTOR=(%d) OFFSET=(%d) ENTRY-NUMBER=(%d)>", el.ClusterNumber, el.SectorIndex, el.SectorNumber, el.Offset, el.EntryNumber)
+}
+
+// RawDirectoryEntry is a parsed directory-entry along with the 32 bytes that
+// it was parsed from and where they are stored, for tools that need to dump
+// or patch entries in place.
+type RawDirectoryEntry struct {
+	// Entry is the parsed entry.
+	Entry DirectoryEntry
+
+	// Raw is the on-disk data. This is shared with the EntrySet and should be
+	// copied before it is modified.
+	Raw []byte
+
+	// Location is where the entry is stored.
+	Location EntryLocation
+}
+
+// Offset returns the absolute byte offset of the entry within the image.
+func (rde RawDirectoryEntry) Offset() int64 {
+	return rde.Location.Offset
+}
+
+// EntryType returns the entry-type byte.
+func (rde RawDirectoryEntry) EntryType() EntryType {
+	return EntryType(rde.Raw[0])
+}
+
+// String returns a descriptive string.
+func (rde RawDirectoryEntry) String() string {
+	typeName := "(unknown)"
+	if rde.Entry != nil {
+		typeName = rde.Entry.TypeName()
+	}
+
+	return fmt.Sprintf("RawDirectoryEntry<TYPE=[%s] OFFSET=(%d) RAW=(0x%x)>", typeName, rde.Location.Offset, rde.Raw)
+}
+
+// EntrySet bundles a primary directory-entry with the secondary entries that
+// accompany it, along with where each was found and the raw bytes that they
+// were parsed from. This is the unit that the specification treats as atomic
+// (Section 6.3). The navigator enumerates them (EnumerateEntrySets()), every
+// IndexedDirectoryEntry and TreeNode read from a directory carries the one
+// that it came from, and the compliance checks validate checksums against
+// them. The writer builds the sets that it writes with NewEntrySet().
+type EntrySet struct {
+	// PrimaryEntry is the parsed primary entry.
+	PrimaryEntry DirectoryEntry
+
+	// SecondaryEntries are the parsed secondary entries, in order.
+	SecondaryEntries []DirectoryEntry
+
+	// Locations has one location for each entry, starting with the primary.
+	Locations []EntryLocation
+
+	// Raw is the concatenated raw data for every entry, starting with the
+	// primary.
+	Raw []byte
+
+	// fileNames holds the file-name entries of a file entry-set, which
+	// SecondaryEntries points into, so that they take one allocation rather
+	// than one each (see entrySetAssembler.parse()).
+	fileNames []ExfatFileNameDirectoryEntry
+}
+
+// newEntrySet returns a new EntrySet for the given primary entry. When the
+// primary entry declares how many secondary entries follow it, the slices are
+// allocated at their final size up front. For a file, this includes the
+// storage for the file-name entries, which are every secondary entry but the
+// stream extension in a conforming set.
+func newEntrySet(primaryEntry DirectoryEntry, location EntryLocation, raw []byte) *EntrySet {
+	secondaryCount := 0
+	if pde, ok := primaryEntry.(PrimaryDirectoryEntry); ok == true {
+		secondaryCount = int(pde.SecondaryCount())
+	}
+
+	es := &EntrySet{
+		PrimaryEntry:     primaryEntry,
+		SecondaryEntries: make([]DirectoryEntry, 0, secondaryCount),
+		Locations:        make([]EntryLocation, 1, 1+secondaryCount),
+		Raw:              make([]byte, directoryEntryBytesCount, (1+secondaryCount)*directoryEntryBytesCount),
+	}
+
+	es.Locations[0] = location
+	copy(es.Raw, raw)
+
+	if _, ok := primaryEntry.(*ExfatFileDirectoryEntry); ok == true && secondaryCount > 1 {
+		es.fileNames = make([]ExfatFileNameDirectoryEntry, 0, secondaryCount-1)
+	}
+
+	return es
+}
+
+// addSecondary appends a secondary entry to the set.
+func (es *EntrySet) addSecondary(secondaryEntry DirectoryEntry, location EntryLocation, raw []byte) {
+	es.SecondaryEntries = append(es.SecondaryEntries, secondaryEntry)
+	es.Locations = append(es.Locations, location)
+	es.Raw = append(es.Raw, raw...)
+}
+
+// entrySetAssembler collects consecutive directory entries into sets. It
+// knows nothing about sectors or clusters, so a set can be spread across any
+// number of them.
+type entrySetAssembler struct {
+	currentSet *EntrySet
+}
+
+// parse parses the next entry of the directory, which is then given to add().
+// A file-name entry that belongs to the set being assembled is decoded
+// directly into the set's storage for them. Anything else (including
+// file-name entries beyond what the set has room for) is parsed normally.
+func (esa *entrySetAssembler) parse(entryType EntryType, raw []byte) (de DirectoryEntry, err error) {
+	es := esa.currentSet
+
+	if es != nil && entryType.IsPrimary() == false && entryType.IsCritical() == true && entryType.TypeCode() == fileNameEntryType&0x1f && len(es.fileNames) < cap(es.fileNames) {
+		es.fileNames = es.fileNames[:len(es.fileNames)+1]
+
+		fnde := &es.fileNames[len(es.fileNames)-1]
+		decodeFileNameDirectoryEntryInto(fnde, raw)
+
+		return fnde, nil
+	}
+
+	return parseDirectoryEntry(entryType, raw)
+}
+
+// add adds the next entry of the directory and returns the set that it
+// completes, if any. A primary entry always starts a new set; secondary
+// entries that don't follow a primary (e.g. at the start of a cluster that
+// enumeration started from, or after a set that already has all of the
+// entries that it declared) are dropped.
+func (esa *entrySetAssembler) add(entryType EntryType, de DirectoryEntry, location EntryLocation, raw []byte) (completed *EntrySet) {
+	if entryType.IsPrimary() == true {
+		// Any set that was still waiting on secondary entries is abandoned.
+		esa.currentSet = newEntrySet(de, location, raw)
+	} else if esa.currentSet != nil {
+		esa.currentSet.addSecondary(de, location, raw)
+	} else {
+		return nil
+	}
+
+	// We're conceding the presence of primary entry-types that don't
+	// necessarily have a SecondaryCount field (which is the qualification to
+	// be considered a `PrimaryDirectoryEntry`). Those are complete as soon as
+	// they're found.
+	if pde, ok := esa.currentSet.PrimaryEntry.(PrimaryDirectoryEntry); ok == true {
+		if len(esa.currentSet.SecondaryEntries) < int(pde.SecondaryCount()) {
+			return nil
+		}
+	} else if entryType.IsPrimary() == false {
+		return nil
+	}
+
+	completed = esa.currentSet
+	esa.currentSet = nil
+
+	return completed
+}
+
+// EntryCount returns the total number of entries in the set, including the
+// primary.
+func (es *EntrySet) EntryCount() int {
+	return 1 + len(es.SecondaryEntries)
+}
+
+// Location returns the location of the primary entry.
+func (es *EntrySet) Location() EntryLocation {
+	return es.Locations[0]
+}
+
+// RawEntry returns the raw bytes for the i'th entry in the set (the primary is
+// zero).
+func (es *EntrySet) RawEntry(i int) []byte {
+	return es.Raw[i*directoryEntryBytesCount : (i+1)*directoryEntryBytesCount]
+}
+
+// Entry returns the parsed i'th entry in the set (the primary is zero).
+func (es *EntrySet) Entry(i int) DirectoryEntry {
+	if i == 0 {
+		return es.PrimaryEntry
+	}
+
+	return es.SecondaryEntries[i-1]
+}
+
+// RawEntries returns every entry in the set, starting with the primary, along
+// with its raw bytes and where it's stored.
+func (es *EntrySet) RawEntries() []RawDirectoryEntry {
+	rdes := make([]RawDirectoryEntry, es.EntryCount())
+	for i := range rdes {
+		rdes[i] = RawDirectoryEntry{
+			Entry:    es.Entry(i),
+			Raw:      es.RawEntry(i),
+			Location: es.Locations[i],
+		}
+	}
+
+	return rdes
+}
+
+// StoredChecksum returns the SetChecksum field of the primary entry. `ok` is
+// false if the primary entry-type does not carry a checksum.
+func (es *EntrySet) StoredChecksum() (checksum uint16, ok bool) {
+	switch pde := es.PrimaryEntry.(type) {
+	case *ExfatFileDirectoryEntry:
+		return pde.SetChecksum, true
+	case *ExfatVolumeGuidDirectoryEntry:
+		return pde.SetChecksum, true
+	}
+
+	return 0, false
+}
+
+// CalculatedChecksum calculates the set-checksum over the raw data of all
+// entries in the set, as described by Section 6.3.3 (the checksum field itself
+// is skipped).
+func (es *EntrySet) CalculatedChecksum() uint16 {
+	return EntrySetChecksum(es.Raw)
+}
+
+// IsChecksumValid indicates whether the stored checksum matches the
+// calculated one. Sets whose primary entry-type doesn't carry a checksum are
+// always valid.
+func (es *EntrySet) IsChecksumValid() bool {
+	storedChecksum, ok := es.StoredChecksum()
+	if ok == false {
+		return true
+	}
+
+	return storedChecksum == es.CalculatedChecksum()
+}
+
+// IsComplete indicates whether all of the secondary entries that the primary
+// entry requires have been collected.
+func (es *EntrySet) IsComplete() bool {
+	if pde, ok := es.PrimaryEntry.(PrimaryDirectoryEntry); ok == true {
+		return len(es.SecondaryEntries) >= int(pde.SecondaryCount())
+	}
+
+	return true
+}
+
+// IsInUse indicates whether the primary entry is in use. Sets that are not in
+// use (e.g. deleted files) were calculated while the in-use bits were still
+// set, so their checksums won't necessarily match.
+func (es *EntrySet) IsInUse() bool {
+	return EntryType(es.Raw[0]).IsInUse()
+}
+
+// Filename returns the complete filename if this is a file set, or an empty
+// string otherwise.
+func (es *EntrySet) Filename() string {
+	if _, ok := es.PrimaryEntry.(*ExfatFileDirectoryEntry); ok == false {
+		return ""
+	}
+
+	mf := MultipartFilename(es.SecondaryEntries)
+	return mf.Filename()
+}
+
+// String returns a descriptive string.
+func (es *EntrySet) String() string {
+	return fmt.Sprintf("EntrySet<TYPE=[%s] ENTRIES=(%d) CHECKSUM-VALID=[%v] %s>", es.PrimaryEntry.TypeName(), es.EntryCount(), es.IsChecksumValid(), es.Location())
+}
@@ -0,0 +1,478 @@
+package exfat
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/dsoprea/go-logging"
+)
+
+func getRootEntrySets() (sets []*EntrySet) {
+	f, er := getTestFileAndParser()
+
+	defer f.Close()
+
+	err := er.Parse()
+	log.PanicIf(err)
+
+	en := NewExfatNavigator(er, er.FirstClusterOfRootDirectory())
+
+	sets = make([]*EntrySet, 0)
+
+	cb := func(es *EntrySet) (err error) {
+		sets = append(sets, es)
+		return nil
+	}
+
+	_, _, err = en.EnumerateEntrySets(cb)
+	log.PanicIf(err)
+
+	return sets
+}
+
+func TestExfatNavigator_EnumerateEntrySets(t *testing.T) {
+	sets := getRootEntrySets()
+
+	if len(sets) != 10 {
+		t.Fatalf("Number of sets not correct: (%d)", len(sets))
+	}
+
+	lastEntryNumber := -1
+	for _, es := range sets {
+		if len(es.Raw) != es.EntryCount()*directoryEntryBytesCount {
+			t.Fatalf("Raw data not the right size: %s", es)
+		} else if len(es.Locations) != es.EntryCount() {
+			t.Fatalf("Location count not correct: %s", es)
+		} else if es.Location().EntryNumber <= lastEntryNumber {
+			t.Fatalf("Entry numbers not ascending: %s", es)
+		} else if es.IsComplete() != true {
+			t.Fatalf("Set not complete: %s", es)
+		}
+
+		if EntryType(es.RawEntry(0)[0]).TypeCode() != EntryType(es.Raw[0]).TypeCode() {
+			t.Fatalf("Raw entry not correct: %s", es)
+		}
+
+		lastEntryNumber = es.Location().EntryNumber
+	}
+}
+
+func TestEntrySet_IsChecksumValid(t *testing.T) {
+	sets := getRootEntrySets()
+
+	checked := 0
+	for _, es := range sets {
+		if _, ok := es.StoredChecksum(); ok == false || es.IsInUse() == false {
+			continue
+		}
+
+		if es.IsChecksumValid() != true {
+			storedChecksum, _ := es.StoredChecksum()
+			t.Fatalf("Checksum not valid: %s (0x%04x) != (0x%04x)", es, storedChecksum, es.CalculatedChecksum())
+		}
+
+		checked++
+	}
+
+	if checked != 6 {
+		t.Fatalf("Expected six in-use sets with checksums: (%d)", checked)
+	}
+}
+
+func TestEntrySet_IsChecksumValid__Corrupt(t *testing.T) {
+	sets := getRootEntrySets()
+
+	for _, es := range sets {
+		if _, ok := es.StoredChecksum(); ok == false || es.IsInUse() == false {
+			continue
+		}
+
+		es.Raw[len(es.Raw)-1]++
+
+		if es.IsChecksumValid() != false {
+			t.Fatalf("Expected checksum to be invalid after corruption: %s", es)
+		}
+
+		return
+	}
+
+	t.Fatalf("No checksummed set found.")
+}
+
+func TestEntrySet_Filename(t *testing.T) {
+	sets := getRootEntrySets()
+
+	for _, es := range sets {
+		if es.PrimaryEntry.TypeName() != "File" {
+			if es.Filename() != "" {
+				t.Fatalf("Expected no filename for non-file set: %s", es)
+			}
+
+			continue
+		}
+
+		if es.Filename() == "" {
+			t.Fatalf("Expected filename for file set: %s", es)
+		}
+	}
+}
+
+func TestEntrySet_RawEntries(t *testing.T) {
+	image, _ := getTestImage()
+
+	sets := getRootEntrySets()
+
+	for _, es := range sets {
+		rdes := es.RawEntries()
+
+		if len(rdes) != es.EntryCount() {
+			t.Fatalf("Raw-entry count not correct: (%d) %s", len(rdes), es)
+		} else if rdes[0].Entry != es.PrimaryEntry {
+			t.Fatalf("First raw entry is not the primary: %s", rdes[0])
+		}
+
+		for i, rde := range rdes {
+			if rde.Entry != es.Entry(i) {
+				t.Fatalf("Raw entry (%d) has the wrong entry: %s", i, rde)
+			} else if rde.EntryType().IsPrimary() != (i == 0) {
+				t.Fatalf("Raw entry (%d) has the wrong type: %s", i, rde)
+			}
+
+			// The bytes at the offset are the ones that were parsed.
+
+			onDisk := image[rde.Offset() : rde.Offset()+directoryEntryBytesCount]
+			if bytes.Equal(onDisk, rde.Raw) != true {
+				t.Fatalf("Raw entry (%d) does not match the image: %s", i, rde)
+			}
+		}
+	}
+}
+
+func TestIndexedDirectoryEntry_RawEntries(t *testing.T) {
+	f, er := getTestFileAndParser()
+
+	defer f.Close()
+
+	err := er.Parse()
+	log.PanicIf(err)
+
+	en := NewExfatNavigator(er, er.FirstClusterOfRootDirectory())
+
+	index, _, _, err := en.IndexDirectoryEntries()
+	log.PanicIf(err)
+
+	ide := index["VolumeLabel"][0]
+
+	rdes := ide.RawEntries()
+	if len(rdes) != 1 {
+		t.Fatalf("Expected one raw entry: (%d)", len(rdes))
+	} else if rdes[0].EntryType() != 0x83 {
+		t.Fatalf("Entry-type not correct: %s", rdes[0].EntryType())
+	}
+
+	ide.EntrySet = nil
+
+	if ide.RawEntries() != nil {
+		t.Fatalf("Expected no raw entries without an entry-set.")
+	}
+}
+
+func TestIndexedDirectoryEntry__DerivedFromEntrySet(t *testing.T) {
+	f, er := getTestFileAndParser()
+
+	defer f.Close()
+
+	err := er.Parse()
+	log.PanicIf(err)
+
+	en := NewExfatNavigator(er, er.FirstClusterOfRootDirectory())
+
+	index, _, _, err := en.IndexDirectoryEntries()
+	log.PanicIf(err)
+
+	for _, ideList := range index {
+		for _, ide := range ideList {
+			es := ide.EntrySet
+
+			if es == nil {
+				t.Fatalf("Expected an entry-set: %v", ide)
+			} else if ide.PrimaryEntry != es.PrimaryEntry {
+				t.Fatalf("Primary entry not from the entry-set.")
+			} else if len(ide.SecondaryEntries) != len(es.SecondaryEntries) {
+				t.Fatalf("Secondary entries not from the entry-set.")
+			} else if len(es.SecondaryEntries) > 0 && &ide.SecondaryEntries[0] != &es.SecondaryEntries[0] {
+				t.Fatalf("Secondary entries copied rather than shared.")
+			} else if ide.Location != es.Location() || ide.Filename != es.Filename() {
+				t.Fatalf("Location or filename not from the entry-set.")
+			}
+		}
+	}
+}
+
+// getTestStraddlingImage returns a new volume with a directory whose seventeen-
+// entry set (a 225-character name) starts eight entries before the end of the
+// directory's first cluster, so it crosses both sector and cluster
+// boundaries. The directory has 40 three-entry files before it and one after
+// it.
+func getTestStraddlingImage() (er *ExfatReader, longName string) {
+	f, closer := getTestNewImage()
+
+	defer closer()
+
+	ew, err := NewExfatWriter(f, 4*1024*1024, FormatOptions{})
+	log.PanicIf(err)
+
+	err = ew.Mkdir([]string{"directory"}, FileMetadata{})
+	log.PanicIf(err)
+
+	for i := 0; i < 40; i++ {
+		err := ew.CreateFile([]string{"directory", fmt.Sprintf("file%02d", i)}, bytes.NewReader(nil), 0, FileMetadata{})
+		log.PanicIf(err)
+	}
+
+	longName = strings.Repeat("0123456789abcde", 15)
+
+	err = ew.CreateFile([]string{"directory", longName}, bytes.NewReader(nil), 0, FileMetadata{})
+	log.PanicIf(err)
+
+	err = ew.CreateFile([]string{"directory", "last"}, bytes.NewReader(nil), 0, FileMetadata{})
+	log.PanicIf(err)
+
+	err = ew.Close()
+	log.PanicIf(err)
+
+	er, _ = getTestParsedImage(f)
+
+	return er, longName
+}
+
+func TestExfatNavigator_EnumerateEntrySets__StraddlesClusters(t *testing.T) {
+	er, longName := getTestStraddlingImage()
+
+	tree := NewTree(er)
+
+	err := tree.Load()
+	log.PanicIf(err)
+
+	directoryNode, err := tree.Lookup([]string{"directory"})
+	log.PanicIf(err)
+
+	firstCluster := directoryNode.StreamDirectoryEntry().FirstCluster
+
+	en := NewExfatNavigator(er, firstCluster)
+
+	sets := make([]*EntrySet, 0)
+
+	cb := func(es *EntrySet) (err error) {
+		sets = append(sets, es)
+		return nil
+	}
+
+	visitedClusters, _, err := en.EnumerateEntrySets(cb)
+	log.PanicIf(err)
+
+	if len(visitedClusters) != 2 {
+		t.Fatalf("Expected the directory to have two clusters: %v", visitedClusters)
+	} else if len(sets) != 42 {
+		t.Fatalf("Number of sets not correct: (%d)", len(sets))
+	}
+
+	es := sets[40]
+
+	if es.EntryCount() != 17 {
+		t.Fatalf("Entry count not correct: (%d)", es.EntryCount())
+	} else if es.Filename() != longName {
+		t.Fatalf("Filename not correct: [%s]", es.Filename())
+	} else if es.IsChecksumValid() != true {
+		t.Fatalf("Checksum not valid.")
+	} else if len(es.Raw) != 17*directoryEntryBytesCount {
+		t.Fatalf("Raw data not the right size: (%d)", len(es.Raw))
+	}
+
+	// Eight entries are in the first cluster and nine in the second, in
+	// order.
+
+	for i, location := range es.Locations {
+		expectedCluster := firstCluster
+		if i >= 8 {
+			expectedCluster = firstCluster + 1
+		}
+
+		if location.ClusterNumber != expectedCluster {
+			t.Fatalf("Cluster of entry (%d) not correct: (%d)", i, location.ClusterNumber)
+		} else if location.EntryNumber != 120+i {
+			t.Fatalf("Entry-number of entry (%d) not correct: (%d)", i, location.EntryNumber)
+		} else if i > 0 && location.Offset != es.Locations[i-1].Offset+directoryEntryBytesCount {
+			t.Fatalf("Offset of entry (%d) not correct: (%d)", i, location.Offset)
+		}
+	}
+
+	if sets[41].Filename() != "last" || sets[41].Location().EntryNumber != 137 {
+		t.Fatalf("Set after the straddling set not correct: %s", sets[41])
+	}
+
+	// Starting from the second cluster skips the tail of the straddling set.
+
+	sets = sets[:0]
+
+	_, _, err = en.enumerateEntrySetsFrom(firstCluster+1, cb)
+	log.PanicIf(err)
+
+	if len(sets) != 1 || sets[0].Filename() != "last" {
+		t.Fatalf("Sets from the second cluster not correct: %v", sets)
+	}
+}
+
+func TestTreeNode_ReadDirN__StraddlesClusters(t *testing.T) {
+	er, longName := getTestStraddlingImage()
+
+	loadedTree := NewTree(er)
+
+	err := loadedTree.Load()
+	log.PanicIf(err)
+
+	// Deliberately not loaded, and read in batches that stop right after the
+	// straddling set so that streaming resumes in the middle of it.
+
+	tree := NewTree(er)
+
+	rootChildren, err := tree.rootNode.ReadDirN(0)
+	log.PanicIf(err)
+
+	directoryNode := rootChildren[0]
+
+	names := make([]string, 0)
+
+	for {
+		children, err := directoryNode.ReadDirN(41)
+		if err == io.EOF {
+			break
+		}
+
+		log.PanicIf(err)
+
+		for _, child := range children {
+			names = append(names, child.Name())
+		}
+	}
+
+	if len(names) != 42 {
+		t.Fatalf("Number of children not correct: (%d)", len(names))
+	} else if names[40] != longName || names[41] != "last" {
+		t.Fatalf("Children not correct: %v", names[40:])
+	}
+
+	node, err := loadedTree.Lookup([]string{"directory", longName})
+	log.PanicIf(err)
+
+	if node == nil {
+		t.Fatalf("Straddling file not found in the loaded tree.")
+	}
+}
+
+func TestEntrySetAssembler_add(t *testing.T) {
+	esa := new(entrySetAssembler)
+
+	raw := make([]byte, directoryEntryBytesCount)
+
+	fileEntryType := EntryType(0x85)
+	streamEntryType := EntryType(0xc0)
+	filenameEntryType := EntryType(0xc1)
+
+	fde := &ExfatFileDirectoryEntry{EntryType: fileEntryType, SecondaryCountRaw: 2}
+	sede := &ExfatStreamExtensionDirectoryEntry{EntryType: streamEntryType}
+	fnde := &ExfatFileNameDirectoryEntry{EntryType: filenameEntryType}
+
+	// A secondary without a primary is dropped.
+
+	if es := esa.add(filenameEntryType, fnde, EntryLocation{EntryNumber: 0}, raw); es != nil {
+		t.Fatalf("Expected an orphaned secondary to be dropped: %s", es)
+	}
+
+	// A set that is interrupted by another primary is abandoned.
+
+	if es := esa.add(fileEntryType, fde, EntryLocation{EntryNumber: 1}, raw); es != nil {
+		t.Fatalf("Set should not be complete yet: %s", es)
+	}
+
+	if es := esa.add(fileEntryType, fde, EntryLocation{EntryNumber: 2}, raw); es != nil {
+		t.Fatalf("Set should not be complete yet: %s", es)
+	}
+
+	if es := esa.add(streamEntryType, sede, EntryLocation{EntryNumber: 3}, raw); es != nil {
+		t.Fatalf("Set should not be complete yet: %s", es)
+	}
+
+	es := esa.add(filenameEntryType, fnde, EntryLocation{EntryNumber: 4}, raw)
+	if es == nil {
+		t.Fatalf("Expected a complete set.")
+	} else if es.Location().EntryNumber != 2 || es.EntryCount() != 3 || len(es.Raw) != 3*directoryEntryBytesCount {
+		t.Fatalf("Set not correct: %s", es)
+	}
+
+	// Secondaries past the declared count are dropped.
+
+	if es := esa.add(filenameEntryType, fnde, EntryLocation{EntryNumber: 5}, raw); es != nil {
+		t.Fatalf("Expected an extra secondary to be dropped: %s", es)
+	}
+}
+
+func TestEntrySetAssembler_parse__Contiguous(t *testing.T) {
+	for _, es := range getRootEntrySets() {
+		if es.PrimaryEntry.TypeName() != "File" {
+			continue
+		}
+
+		fileNameCount := 0
+		for _, de := range es.SecondaryEntries {
+			fnde, ok := de.(*ExfatFileNameDirectoryEntry)
+			if ok == false {
+				continue
+			}
+
+			if fnde != &es.fileNames[fileNameCount] {
+				t.Fatalf("File-name entry (%d) not stored in the set: %s", fileNameCount, es)
+			}
+
+			fileNameCount++
+		}
+
+		if fileNameCount == 0 || fileNameCount != len(es.fileNames) {
+			t.Fatalf("File-name entries not correct: (%d) (%d)", fileNameCount, len(es.fileNames))
+		}
+	}
+}
+
+func TestEntrySetAssembler_parse__Overflow(t *testing.T) {
+	esa := new(entrySetAssembler)
+
+	// A file that only declares one secondary entry has no room for file-name
+	// entries, so the one that follows is parsed normally (and then dropped).
+
+	raw := make([]byte, directoryEntryBytesCount)
+	raw[0] = 0x85
+	raw[1] = 1
+
+	de, err := esa.parse(EntryType(raw[0]), raw)
+	log.PanicIf(err)
+
+	esa.add(EntryType(raw[0]), de, EntryLocation{}, raw)
+
+	raw = make([]byte, directoryEntryBytesCount)
+	raw[0] = 0xc1
+	raw[2] = 'a'
+
+	de, err = esa.parse(EntryType(raw[0]), raw)
+	log.PanicIf(err)
+
+	if fnde, ok := de.(*ExfatFileNameDirectoryEntry); ok != true {
+		t.Fatalf("Entry not correct: [%v]", de)
+	} else if fnde.FileName[0] != 'a' {
+		t.Fatalf("Entry not decoded: [%v]", fnde)
+	} else if len(esa.currentSet.fileNames) != 0 {
+		t.Fatalf("Expected the set's storage to be unused.")
+	}
+}
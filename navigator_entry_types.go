@@ -2,8 +2,9 @@ package exfat
 
 import (
 	"fmt"
+	"io"
+	"os"
 	"reflect"
-	"strings"
 	"time"
 
 	"github.com/dsoprea/go-logging"
@@ -75,31 +76,36 @@ func (et EntryType) IsInUse() bool {
 
 // Dump dumps all flags/states embedded in the entry-type value.
 func (et EntryType) Dump() {
-	fmt.Printf("Entry Type\n")
-	fmt.Printf("==========\n")
-	fmt.Printf("\n")
+	et.DumpTo(os.Stdout)
+}
+
+// DumpTo is the same as Dump() but writes to the given writer.
+func (et EntryType) DumpTo(w io.Writer) {
+	fmt.Fprintf(w, "Entry Type\n")
+	fmt.Fprintf(w, "==========\n")
+	fmt.Fprintf(w, "\n")
 
-	fmt.Printf("TypeCode: (%d)\n", et.TypeCode())
-	fmt.Printf("\n")
+	fmt.Fprintf(w, "TypeCode: (%d)\n", et.TypeCode())
+	fmt.Fprintf(w, "\n")
 
-	fmt.Printf("TypeImportance: [%v]\n", et.TypeImportance())
-	fmt.Printf("- IsCritical: [%v]\n", et.IsCritical())
-	fmt.Printf("- IsBenign: [%v]\n", et.IsBenign())
-	fmt.Printf("\n")
+	fmt.Fprintf(w, "TypeImportance: [%v]\n", et.TypeImportance())
+	fmt.Fprintf(w, "- IsCritical: [%v]\n", et.IsCritical())
+	fmt.Fprintf(w, "- IsBenign: [%v]\n", et.IsBenign())
+	fmt.Fprintf(w, "\n")
 
-	fmt.Printf("TypeCategory: [%v]\n", et.TypeCategory())
-	fmt.Printf("- IsPrimary: [%v]\n", et.IsPrimary())
-	fmt.Printf("- IsSecondary: [%v]\n", et.IsSecondary())
-	fmt.Printf("\n")
+	fmt.Fprintf(w, "TypeCategory: [%v]\n", et.TypeCategory())
+	fmt.Fprintf(w, "- IsPrimary: [%v]\n", et.IsPrimary())
+	fmt.Fprintf(w, "- IsSecondary: [%v]\n", et.IsSecondary())
+	fmt.Fprintf(w, "\n")
 
-	fmt.Printf("IsInUse: [%v]\n", et.IsInUse())
-	fmt.Printf("\n")
+	fmt.Fprintf(w, "IsInUse: [%v]\n", et.IsInUse())
+	fmt.Fprintf(w, "\n")
 
-	fmt.Printf("Entry-Type Classes\n")
-	fmt.Printf("- IsEndOfDirectory: [%v]\n", et.IsEndOfDirectory())
-	fmt.Printf("- IsUnusedEntryMarker: [%v]\n", et.IsUnusedEntryMarker())
-	fmt.Printf("- IsRegular: [%v]\n", et.IsRegular())
-	fmt.Printf("\n")
+	fmt.Fprintf(w, "Entry-Type Classes\n")
+	fmt.Fprintf(w, "- IsEndOfDirectory: [%v]\n", et.IsEndOfDirectory())
+	fmt.Fprintf(w, "- IsUnusedEntryMarker: [%v]\n", et.IsUnusedEntryMarker())
+	fmt.Fprintf(w, "- IsRegular: [%v]\n", et.IsRegular())
+	fmt.Fprintf(w, "\n")
 }
 
 // String returns a descriptive string.
@@ -192,9 +198,11 @@ type DumpableDirectoryEntry interface {
 // embeds its parsing semantics.
 type ExfatTimestamp uint32
 
-// Second returns the second component.
+// Second returns the second component. The raw field counts two-second
+// intervals (Section 7.4.8), so this is always even. The odd second, if any,
+// is in the 10ms-increment field (see Timestamp()).
 func (et ExfatTimestamp) Second() int {
-	return int(et & 31)
+	return int(et&31) * 2
 }
 
 // Minute returns the minute component.
@@ -222,13 +230,78 @@ func (et ExfatTimestamp) Year() int {
 	return 1980 + int(et&4261412864)>>25
 }
 
-// TimestampWithOffset returns a location-corrected timestamp.
+// TimestampWithOffset returns a location-corrected timestamp. The offset is in
+// seconds east of UTC. See Timestamp() for decoding the raw on-disk fields.
 func (et ExfatTimestamp) TimestampWithOffset(offset int) time.Time {
 	location := time.FixedZone(fmt.Sprintf("(off=%d)", offset), offset)
 
 	return time.Date(et.Year(), time.Month(et.Month()), et.Day(), et.Hour(), et.Minute(), et.Second(), 0, location)
 }
 
+// Timestamp returns the timestamp with the raw 10ms-increment (Section 7.4.9)
+// and UTC-offset (Section 7.4.10) fields applied. If the offset is not marked
+// as valid, the timestamp is in the (unknown) local time of whatever wrote it,
+// and it's returned as if it were UTC.
+func (et ExfatTimestamp) Timestamp(increment10ms uint8, utcOffset uint8) time.Time {
+	offset, _ := DecodeUtcOffset(utcOffset)
+
+	t := et.TimestampWithOffset(offset)
+
+	// Anything past 199 (two seconds) is not valid and is ignored.
+	if increment10ms <= 199 {
+		t = t.Add(time.Duration(increment10ms) * 10 * time.Millisecond)
+	}
+
+	return t
+}
+
+// DecodeUtcOffset decodes a raw UTC-offset field (Section 7.4.10) to seconds
+// east of UTC. The high bit indicates whether the offset is valid and the
+// low seven bits are a signed count of 15-minute intervals.
+func DecodeUtcOffset(utcOffset uint8) (offset int, isValid bool) {
+	if utcOffset&0x80 == 0 {
+		return 0, false
+	}
+
+	// Sign-extend the seven-bit value.
+	intervals := int(int8(utcOffset<<1) >> 1)
+
+	return intervals * 15 * 60, true
+}
+
+// NewExfatTimestamp encodes a time as the raw timestamp, 10ms-increment, and
+// UTC-offset fields. This is the inverse of Timestamp(). The time's own zone
+// is recorded unless it can't be expressed in 15-minute intervals, in which
+// case the time is converted to UTC. Times outside of what exFAT can represent
+// (1980 through 2107) are clamped.
+func NewExfatTimestamp(t time.Time) (et ExfatTimestamp, increment10ms uint8, utcOffset uint8) {
+	_, offset := t.Zone()
+
+	intervals := offset / (15 * 60)
+	if offset%(15*60) != 0 || intervals < -64 || intervals > 63 {
+		t = t.UTC()
+		intervals = 0
+	}
+
+	if t.Year() < 1980 {
+		t = time.Date(1980, 1, 1, 0, 0, 0, 0, t.Location())
+	} else if t.Year() > 2107 {
+		t = time.Date(2107, 12, 31, 23, 59, 59, 990*int(time.Millisecond), t.Location())
+	}
+
+	et = ExfatTimestamp(uint32(t.Year()-1980)<<25 |
+		uint32(t.Month())<<21 |
+		uint32(t.Day())<<16 |
+		uint32(t.Hour())<<11 |
+		uint32(t.Minute())<<5 |
+		uint32(t.Second()/2))
+
+	increment10ms = uint8(t.Second()%2*100 + t.Nanosecond()/int(10*time.Millisecond))
+	utcOffset = 0x80 | uint8(intervals)&0x7f
+
+	return et, increment10ms, utcOffset
+}
+
 // FileAttributes allows us to decompose the attributes integer into the various
 // attributes that a file/directory can have.
 type FileAttributes uint16
@@ -259,6 +332,53 @@ func (fa FileAttributes) IsArchive() bool {
 	return fa&32 > 0
 }
 
+// fileAttributesDefined are the bits that Section 7.4.4 defines. The rest are
+// reserved.
+const fileAttributesDefined = FileAttributes(1 | 2 | 4 | 16 | 32)
+
+// RawAttributes returns all sixteen bits of the field, including the reserved
+// ones that the other methods ignore.
+func (fa FileAttributes) RawAttributes() uint16 {
+	return uint16(fa)
+}
+
+// ReservedBits returns the bits that the specification reserves (bit 3 and
+// bits 6 through 15). They should be zero, but some writers use them to carry
+// Windows attributes that exFAT doesn't define (e.g. 0x400 for a reparse
+// point, such as a junction).
+func (fa FileAttributes) ReservedBits() uint16 {
+	return uint16(fa &^ fileAttributesDefined)
+}
+
+// Flags returns the attributes as the fixed-width "RHSDA" string used by
+// listings (read-only, hidden, system, directory, and archive), with a dash for
+// each one that isn't set.
+func (fa FileAttributes) Flags() string {
+	flags := []byte("-----")
+
+	if fa.IsReadOnly() == true {
+		flags[0] = 'R'
+	}
+
+	if fa.IsHidden() == true {
+		flags[1] = 'H'
+	}
+
+	if fa.IsSystem() == true {
+		flags[2] = 'S'
+	}
+
+	if fa.IsDirectory() == true {
+		flags[3] = 'D'
+	}
+
+	if fa.IsArchive() == true {
+		flags[4] = 'A'
+	}
+
+	return string(flags)
+}
+
 // String returns a descriptive string.
 func (fa FileAttributes) String() string {
 	return fmt.Sprintf("FileAttributes<IS-READONLY=[%v] IS-HIDDEN=[%v] IS-SYSTEM=[%v] IS-DIRECTORY=[%v] IS-ARCHIVE=[%v]>",
@@ -268,11 +388,21 @@ func (fa FileAttributes) String() string {
 // DumpBareIndented prints the various attribute states preceding by arbitrary
 // indentation.
 func (fa FileAttributes) DumpBareIndented(indent string) {
-	fmt.Printf("%sRead Only? [%v]\n", indent, fa.IsReadOnly())
-	fmt.Printf("%sHidden? [%v]\n", indent, fa.IsHidden())
-	fmt.Printf("%sSystem? [%v]\n", indent, fa.IsSystem())
-	fmt.Printf("%sDirectory? [%v]\n", indent, fa.IsDirectory())
-	fmt.Printf("%sArchive? [%v]\n", indent, fa.IsArchive())
+	fa.DumpBareIndentedTo(os.Stdout, indent)
+}
+
+// DumpBareIndentedTo is the same as DumpBareIndented() but writes to the
+// given writer.
+func (fa FileAttributes) DumpBareIndentedTo(w io.Writer, indent string) {
+	fmt.Fprintf(w, "%sRead Only? [%v]\n", indent, fa.IsReadOnly())
+	fmt.Fprintf(w, "%sHidden? [%v]\n", indent, fa.IsHidden())
+	fmt.Fprintf(w, "%sSystem? [%v]\n", indent, fa.IsSystem())
+	fmt.Fprintf(w, "%sDirectory? [%v]\n", indent, fa.IsDirectory())
+	fmt.Fprintf(w, "%sArchive? [%v]\n", indent, fa.IsArchive())
+
+	if reservedBits := fa.ReservedBits(); reservedBits != 0 {
+		fmt.Fprintf(w, "%sReserved Bits: (0x%04x)\n", indent, reservedBits)
+	}
 }
 
 // ExfatFileDirectoryEntry describes file entries.
@@ -340,37 +470,58 @@ func (fdf ExfatFileDirectoryEntry) TypeName() string {
 
 // CreateTimestamp returns the offset-corrected ctime.
 func (fdf ExfatFileDirectoryEntry) CreateTimestamp() time.Time {
-	return fdf.CreateTimestampRaw.TimestampWithOffset(int(fdf.CreateUtcOffset))
+	return fdf.CreateTimestampRaw.Timestamp(fdf.Create10msIncrement, fdf.CreateUtcOffset)
+}
+
+// CreateTimestampUTC returns the ctime in UTC.
+func (fdf ExfatFileDirectoryEntry) CreateTimestampUTC() time.Time {
+	return fdf.CreateTimestamp().UTC()
 }
 
 // LastModifiedTimestamp returns the offset-corrected mtime.
 func (fdf ExfatFileDirectoryEntry) LastModifiedTimestamp() time.Time {
-	return fdf.LastModifiedTimestampRaw.TimestampWithOffset(int(fdf.LastModifiedUtcOffset))
+	return fdf.LastModifiedTimestampRaw.Timestamp(fdf.LastModified10msIncrement, fdf.LastModifiedUtcOffset)
+}
+
+// LastModifiedTimestampUTC returns the mtime in UTC.
+func (fdf ExfatFileDirectoryEntry) LastModifiedTimestampUTC() time.Time {
+	return fdf.LastModifiedTimestamp().UTC()
 }
 
-// LastAccessedTimestamp returns the offset-corrected atime.
+// LastAccessedTimestamp returns the offset-corrected atime. There is no 10ms
+// increment for this one.
 func (fdf ExfatFileDirectoryEntry) LastAccessedTimestamp() time.Time {
-	return fdf.LastAccessedTimestampRaw.TimestampWithOffset(int(fdf.LastAccessedUtcOffset))
+	return fdf.LastAccessedTimestampRaw.Timestamp(0, fdf.LastAccessedUtcOffset)
+}
+
+// LastAccessedTimestampUTC returns the atime in UTC.
+func (fdf ExfatFileDirectoryEntry) LastAccessedTimestampUTC() time.Time {
+	return fdf.LastAccessedTimestamp().UTC()
 }
 
 // Dump prints the file entry's info to STDOUT.
 func (fdf ExfatFileDirectoryEntry) Dump() {
-	fmt.Printf("File Directory Entry\n")
-	fmt.Printf("====================\n")
-	fmt.Printf("\n")
+	fdf.DumpTo(os.Stdout)
+}
 
-	fmt.Printf("SecondaryCount: (%d)\n", fdf.SecondaryCount())
-	fmt.Printf("SetChecksum: (0x%04x)\n", fdf.SetChecksum)
-	fmt.Printf("CreateTimestamp: [%s]\n", fdf.CreateTimestamp())
-	fmt.Printf("LastModifiedTimestamp: [%s]\n", fdf.LastModifiedTimestamp())
-	fmt.Printf("LastAccessedTimestamp: [%s]\n", fdf.LastAccessedTimestamp())
-	fmt.Printf("\n")
+// DumpTo is the same as Dump() but writes to the given writer.
+func (fdf ExfatFileDirectoryEntry) DumpTo(w io.Writer) {
+	fmt.Fprintf(w, "File Directory Entry\n")
+	fmt.Fprintf(w, "====================\n")
+	fmt.Fprintf(w, "\n")
 
-	fmt.Printf("Attributes:\n")
+	fmt.Fprintf(w, "SecondaryCount: (%d)\n", fdf.SecondaryCount())
+	fmt.Fprintf(w, "SetChecksum: (0x%04x)\n", fdf.SetChecksum)
+	fmt.Fprintf(w, "CreateTimestamp: [%s]\n", fdf.CreateTimestamp())
+	fmt.Fprintf(w, "LastModifiedTimestamp: [%s]\n", fdf.LastModifiedTimestamp())
+	fmt.Fprintf(w, "LastAccessedTimestamp: [%s]\n", fdf.LastAccessedTimestamp())
+	fmt.Fprintf(w, "\n")
 
-	fdf.FileAttributes.DumpBareIndented("  ")
+	fmt.Fprintf(w, "Attributes:\n")
 
-	fmt.Printf("\n")
+	fdf.FileAttributes.DumpBareIndentedTo(w, "  ")
+
+	fmt.Fprintf(w, "\n")
 }
 
 // ExfatAllocationBitmapDirectoryEntry points to the cluster that has the
@@ -496,7 +647,7 @@ type ExfatVolumeGuidDirectoryEntry struct {
 	SetChecksum uint16
 
 	// GeneralPrimaryFlags: This field is mandatory and Section 7.5.4 defines its contents.
-	GeneralPrimaryFlags uint16
+	GeneralPrimaryFlags GeneralPrimaryFlags
 
 	// VolumeGuid: This field is mandatory and Section 7.5.5 defines its contents.
 	VolumeGuid [16]byte
@@ -507,7 +658,7 @@ type ExfatVolumeGuidDirectoryEntry struct {
 
 // String returns a descriptive string.
 func (vgde ExfatVolumeGuidDirectoryEntry) String() string {
-	return fmt.Sprintf("VolumeGuidDirectoryEntry<SECONDARY-COUNT=(%d) SET-CHECKSUM=(0x%04x) GENERAL-PRIMARY-FLAGS=(0x%04x) GUID=[0x%016x...]>", vgde.SecondaryCountRaw, vgde.SetChecksum, vgde.GeneralPrimaryFlags, vgde.VolumeGuid[:4])
+	return fmt.Sprintf("VolumeGuidDirectoryEntry<SECONDARY-COUNT=(%d) SET-CHECKSUM=(0x%04x) GENERAL-PRIMARY-FLAGS=(0x%04x) GUID=[0x%016x...]>", vgde.SecondaryCountRaw, vgde.SetChecksum, uint16(vgde.GeneralPrimaryFlags), vgde.VolumeGuid[:4])
 }
 
 // SecondaryCount returns the count of associated secondary-records.
@@ -520,16 +671,64 @@ func (ExfatVolumeGuidDirectoryEntry) TypeName() string {
 	return "VolumeGuid"
 }
 
-// ExfatTexFATDirectoryEntry is a mobile-device entry-type that is not defined
-// by exFAT.
+// Dump prints the volume-GUID entry's info to STDOUT.
+func (vgde ExfatVolumeGuidDirectoryEntry) Dump() {
+	vgde.DumpTo(os.Stdout)
+}
+
+// DumpTo is the same as Dump() but writes to the given writer.
+func (vgde ExfatVolumeGuidDirectoryEntry) DumpTo(w io.Writer) {
+	fmt.Fprintf(w, "Volume GUID Directory Entry\n")
+	fmt.Fprintf(w, "===========================\n")
+	fmt.Fprintf(w, "\n")
+
+	fmt.Fprintf(w, "SecondaryCount: (%d)\n", vgde.SecondaryCount())
+	fmt.Fprintf(w, "SetChecksum: (0x%04x)\n", vgde.SetChecksum)
+	fmt.Fprintf(w, "VolumeGuid: [%s]\n", FormatGuid(vgde.VolumeGuid))
+	fmt.Fprintf(w, "\n")
+
+	fmt.Fprintf(w, "General Primary Flags:\n")
+
+	vgde.GeneralPrimaryFlags.DumpBareIndentedTo(w, "  ")
+
+	fmt.Fprintf(w, "\n")
+}
+
+// ExfatTexFATDirectoryEntry is a TexFAT Padding entry (Section 7.10). TexFAT
+// reserves the first entries of each directory cluster with these. Their
+// contents are not defined by exFAT, so they are parsed using the generic
+// benign-primary template (Section 6.3) and should otherwise be left alone.
 type ExfatTexFATDirectoryEntry struct {
-	// Reserved: Not covered by the exFAT specification. Just mask the whole thing as reserved.
-	Reserved [32]byte
+	// EntryType: This field is mandatory and Section 6.3.1 defines its contents.
+	EntryType EntryType
+
+	// SecondaryCount: This field is mandatory and Section 6.3.2 defines its contents.
+	SecondaryCountRaw uint8
+
+	// SetChecksum: This field is mandatory and Section 6.3.3 defines its contents.
+	SetChecksum uint16
+
+	// GeneralPrimaryFlags: This field is mandatory and Section 6.3.4 defines its contents.
+	GeneralPrimaryFlags GeneralPrimaryFlags
+
+	// CustomDefined: Defined by TexFAT rather than exFAT.
+	CustomDefined [14]byte
+
+	// FirstCluster: This field is mandatory and Section 6.3.5 defines its contents.
+	FirstCluster uint32
+
+	// DataLength: This field is mandatory and Section 6.3.6 defines its contents.
+	DataLength uint64
 }
 
 // String returns a descriptive string.
-func (ExfatTexFATDirectoryEntry) String() string {
-	return "TexFATDirectoryEntry<>"
+func (tfde ExfatTexFATDirectoryEntry) String() string {
+	return fmt.Sprintf("TexFATDirectoryEntry<SECONDARY-COUNT=(%d) GENERAL-PRIMARY-FLAGS=(0x%04x) FIRST-CLUSTER=(%d) DATA-LENGTH=(%d)>", tfde.SecondaryCountRaw, uint16(tfde.GeneralPrimaryFlags), tfde.FirstCluster, tfde.DataLength)
+}
+
+// SecondaryCount returns the count of associated secondary-records.
+func (tfde ExfatTexFATDirectoryEntry) SecondaryCount() uint8 {
+	return tfde.SecondaryCountRaw
 }
 
 // TypeName returns a unique name for this entry-type.
@@ -537,6 +736,43 @@ func (ExfatTexFATDirectoryEntry) TypeName() string {
 	return "TexFAT"
 }
 
+// GeneralPrimaryFlags allows us to decompose the flags that are embedded in
+// primary directory entries (Section 6.3.4). Only the generic and benign
+// primary entries have them; the critical primaries define the field
+// themselves.
+type GeneralPrimaryFlags uint16
+
+// IsAllocationPossible indicates that a cluster allocation is associated with
+// the entry.
+func (gpf GeneralPrimaryFlags) IsAllocationPossible() bool {
+	return gpf&1 > 0
+}
+
+// NoFatChain indicates whether the allocation is stored contiguously on disk
+// or the FAT is required to find the subsequent clusters.
+func (gpf GeneralPrimaryFlags) NoFatChain() bool {
+	return gpf&2 > 0
+}
+
+// String returns a descriptive string.
+func (gpf GeneralPrimaryFlags) String() string {
+	return fmt.Sprintf("GeneralPrimaryFlags<IsAllocationPossible=[%v] NoFatChain=[%v]>",
+		gpf.IsAllocationPossible(), gpf.NoFatChain())
+}
+
+// DumpBareIndented prints the primary-flags with arbitrary indentation.
+func (gpf GeneralPrimaryFlags) DumpBareIndented(indent string) {
+	gpf.DumpBareIndentedTo(os.Stdout, indent)
+}
+
+// DumpBareIndentedTo is the same as DumpBareIndented() but writes to the
+// given writer.
+func (gpf GeneralPrimaryFlags) DumpBareIndentedTo(w io.Writer, indent string) {
+	fmt.Fprintf(w, "%sRaw Value: (%016b)\n", indent, gpf)
+	fmt.Fprintf(w, "%sIsAllocationPossible: [%v]\n", indent, gpf.IsAllocationPossible())
+	fmt.Fprintf(w, "%sNoFatChain: [%v]\n", indent, gpf.NoFatChain())
+}
+
 // GeneralSecondaryFlags allows us to decompose the flags frequently embedded in
 // secondary directory entries.
 type GeneralSecondaryFlags uint8
@@ -560,9 +796,15 @@ func (gsf GeneralSecondaryFlags) String() string {
 
 // DumpBareIndented prints the secondary-flags with arbitrary indentation.
 func (gsf GeneralSecondaryFlags) DumpBareIndented(indent string) {
-	fmt.Printf("%sRaw Value: (%08b)\n", indent, gsf)
-	fmt.Printf("%sIsAllocationPossible: [%v]\n", indent, gsf.IsAllocationPossible())
-	fmt.Printf("%sNoFatChain: [%v]\n", indent, gsf.NoFatChain())
+	gsf.DumpBareIndentedTo(os.Stdout, indent)
+}
+
+// DumpBareIndentedTo is the same as DumpBareIndented() but writes to the
+// given writer.
+func (gsf GeneralSecondaryFlags) DumpBareIndentedTo(w io.Writer, indent string) {
+	fmt.Fprintf(w, "%sRaw Value: (%08b)\n", indent, gsf)
+	fmt.Fprintf(w, "%sIsAllocationPossible: [%v]\n", indent, gsf.IsAllocationPossible())
+	fmt.Fprintf(w, "%sNoFatChain: [%v]\n", indent, gsf.NoFatChain())
 }
 
 // ExfatStreamExtensionDirectoryEntry describes the actual contents of a file.
@@ -637,21 +879,26 @@ func (sede ExfatStreamExtensionDirectoryEntry) String() string {
 
 // Dump prints the stream entry's info to STDOUT.
 func (sede ExfatStreamExtensionDirectoryEntry) Dump() {
-	fmt.Printf("Stream Extension Directory Entry\n")
-	fmt.Printf("================================\n")
-	fmt.Printf("\n")
+	sede.DumpTo(os.Stdout)
+}
+
+// DumpTo is the same as Dump() but writes to the given writer.
+func (sede ExfatStreamExtensionDirectoryEntry) DumpTo(w io.Writer) {
+	fmt.Fprintf(w, "Stream Extension Directory Entry\n")
+	fmt.Fprintf(w, "================================\n")
+	fmt.Fprintf(w, "\n")
 
-	fmt.Printf("NameLength: (%d)\n", sede.NameLength)
-	fmt.Printf("NameHash: (0x%04x)\n", sede.NameHash)
-	fmt.Printf("ValidDataLength: (%d)\n", sede.ValidDataLength)
-	fmt.Printf("FirstCluster: (%d)\n", sede.FirstCluster)
-	fmt.Printf("DataLength: (%d)\n", sede.DataLength)
-	fmt.Printf("\n")
+	fmt.Fprintf(w, "NameLength: (%d)\n", sede.NameLength)
+	fmt.Fprintf(w, "NameHash: (0x%04x)\n", sede.NameHash)
+	fmt.Fprintf(w, "ValidDataLength: (%d)\n", sede.ValidDataLength)
+	fmt.Fprintf(w, "FirstCluster: (%d)\n", sede.FirstCluster)
+	fmt.Fprintf(w, "DataLength: (%d)\n", sede.DataLength)
+	fmt.Fprintf(w, "\n")
 
-	fmt.Printf("General secondary flags:\n")
-	sede.GeneralSecondaryFlags.DumpBareIndented("  ")
+	fmt.Fprintf(w, "General secondary flags:\n")
+	sede.GeneralSecondaryFlags.DumpBareIndentedTo(w, "  ")
 
-	fmt.Printf("\n")
+	fmt.Fprintf(w, "\n")
 }
 
 // TypeName returns a unique name for this entry-type.
@@ -694,16 +941,17 @@ func (mf MultipartFilename) Filename() string {
 	// before these file-name directory-entries, but we don't implement/
 	// validate that count, here.
 
-	parts := make([]string, 0)
+	// Decode every part into the same buffer so that the filename is only
+	// allocated once.
+	decoded := make([]rune, 0, len(mf)*fileNameEntryUnitCount)
 
 	for _, deRaw := range mf {
 		if fnde, ok := deRaw.(*ExfatFileNameDirectoryEntry); ok == true {
-			part := UnicodeFromAscii(fnde.FileName[:], 15)
-			parts = append(parts, part)
+			decoded = appendUnicodeFromAscii(decoded, fnde.FileName[:], fileNameEntryUnitCount)
 		}
 	}
 
-	filename := strings.Join(parts, "")
+	filename := string(decoded)
 
 	return filename
 }
@@ -765,6 +1013,71 @@ func (ExfatVendorAllocationDirectoryEntry) TypeName() string {
 	return "VendorAllocation"
 }
 
+// directoryEntryDecoders are hand-written decoders for the entry-types that
+// occur once or more for every file. Since they're encountered so frequently,
+// we avoid the (reflection-driven) generic unpacking for them.
+var directoryEntryDecoders = map[DirectoryEntryParserKey]func(data []byte) DirectoryEntry{
+	{typeCode: 5, isCritical: true, isPrimary: true}:  decodeFileDirectoryEntry,
+	{typeCode: 0, isCritical: true, isPrimary: false}: decodeStreamExtensionDirectoryEntry,
+	{typeCode: 1, isCritical: true, isPrimary: false}: decodeFileNameDirectoryEntry,
+}
+
+func decodeFileDirectoryEntry(data []byte) DirectoryEntry {
+	fdf := &ExfatFileDirectoryEntry{
+		EntryType:                 EntryType(data[0]),
+		SecondaryCountRaw:         data[1],
+		SetChecksum:               defaultEncoding.Uint16(data[2:4]),
+		FileAttributes:            FileAttributes(defaultEncoding.Uint16(data[4:6])),
+		Reserved1:                 defaultEncoding.Uint16(data[6:8]),
+		CreateTimestampRaw:        ExfatTimestamp(defaultEncoding.Uint32(data[8:12])),
+		LastModifiedTimestampRaw:  ExfatTimestamp(defaultEncoding.Uint32(data[12:16])),
+		LastAccessedTimestampRaw:  ExfatTimestamp(defaultEncoding.Uint32(data[16:20])),
+		Create10msIncrement:       data[20],
+		LastModified10msIncrement: data[21],
+		CreateUtcOffset:           data[22],
+		LastModifiedUtcOffset:     data[23],
+		LastAccessedUtcOffset:     data[24],
+	}
+
+	copy(fdf.Reserved2[:], data[25:32])
+
+	return fdf
+}
+
+func decodeStreamExtensionDirectoryEntry(data []byte) DirectoryEntry {
+	sede := &ExfatStreamExtensionDirectoryEntry{
+		EntryType:             EntryType(data[0]),
+		GeneralSecondaryFlags: GeneralSecondaryFlags(data[1]),
+		NameLength:            data[3],
+		NameHash:              defaultEncoding.Uint16(data[4:6]),
+		ValidDataLength:       defaultEncoding.Uint64(data[8:16]),
+		FirstCluster:          defaultEncoding.Uint32(data[20:24]),
+		DataLength:            defaultEncoding.Uint64(data[24:32]),
+	}
+
+	sede.Reserved1[0] = data[2]
+	copy(sede.Reserved2[:], data[6:8])
+	copy(sede.Reserved3[:], data[16:20])
+
+	return sede
+}
+
+func decodeFileNameDirectoryEntry(data []byte) DirectoryEntry {
+	fnde := new(ExfatFileNameDirectoryEntry)
+	decodeFileNameDirectoryEntryInto(fnde, data)
+
+	return fnde
+}
+
+// decodeFileNameDirectoryEntryInto decodes into existing storage (see
+// entrySetAssembler.parse()).
+func decodeFileNameDirectoryEntryInto(fnde *ExfatFileNameDirectoryEntry, data []byte) {
+	fnde.EntryType = EntryType(data[0])
+	fnde.GeneralSecondaryFlags = GeneralSecondaryFlags(data[1])
+
+	copy(fnde.FileName[:], data[2:32])
+}
+
 func parseDirectoryEntry(entryType EntryType, directoryEntryData []byte) (parsed DirectoryEntry, err error) {
 	defer func() {
 		if errRaw := recover(); errRaw != nil {
@@ -778,6 +1091,10 @@ func parseDirectoryEntry(entryType EntryType, directoryEntryData []byte) (parsed
 		isPrimary:  entryType.IsPrimary(),
 	}
 
+	if decoder, found := directoryEntryDecoders[depk]; found == true {
+		return decoder(directoryEntryData), nil
+	}
+
 	structType, found := directoryEntryParsers[depk]
 	if found == false {
 		log.Panicf("no struct-type recorded for entry-type: %s", depk)
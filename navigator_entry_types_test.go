@@ -1,7 +1,14 @@
 package exfat
 
 import (
+	"bytes"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/dsoprea/go-logging"
+	"github.com/go-restruct/restruct"
 )
 
 func TestEntryType_Dump(t *testing.T) {
@@ -25,6 +32,127 @@ func TestExfatStreamExtensionDirectoryEntry_Dump(t *testing.T) {
 	sede.Dump()
 }
 
+func TestExfatStreamExtensionDirectoryEntry_DumpTo(t *testing.T) {
+	sede := ExfatStreamExtensionDirectoryEntry{
+		FirstCluster:          10,
+		GeneralSecondaryFlags: 3,
+	}
+
+	b := new(bytes.Buffer)
+	sede.DumpTo(b)
+
+	s := b.String()
+	if strings.Contains(s, "FirstCluster: (10)\n") != true {
+		t.Fatalf("Dump does not include the first cluster: [%s]", s)
+	} else if strings.Contains(s, "  NoFatChain: [true]\n") != true {
+		t.Fatalf("Dump does not include the flags: [%s]", s)
+	}
+}
+
+func TestExfatTimestamp_Timestamp(t *testing.T) {
+	// 2020-03-04 05:06:08 (four double-seconds).
+	et := ExfatTimestamp(40<<25 | 3<<21 | 4<<16 | 5<<11 | 6<<5 | 4)
+
+	if et.Second() != 8 {
+		t.Fatalf("Second not correct: (%d)", et.Second())
+	}
+
+	// 1.5 seconds past, at UTC-5 (-20 intervals as a seven-bit value, with
+	// the valid bit).
+	ts := et.Timestamp(150, 0x80|108)
+
+	_, offset := ts.Zone()
+	if offset != -5*60*60 {
+		t.Fatalf("Offset not correct: (%d)", offset)
+	}
+
+	expected := time.Date(2020, 3, 4, 10, 6, 9, 500000000, time.UTC)
+	if ts.UTC() != expected {
+		t.Fatalf("Timestamp not correct: [%s]", ts.UTC())
+	}
+
+	// An offset that isn't valid is taken as UTC, and an increment that's out
+	// of range is ignored.
+	ts = et.Timestamp(200, 108)
+
+	expected = time.Date(2020, 3, 4, 5, 6, 8, 0, time.UTC)
+	if ts.UTC() != expected {
+		t.Fatalf("Timestamp without valid offset not correct: [%s]", ts.UTC())
+	}
+}
+
+func TestDecodeUtcOffset(t *testing.T) {
+	offset, isValid := DecodeUtcOffset(0x80 | 0x3f)
+	if isValid != true || offset != 63*15*60 {
+		t.Fatalf("Positive offset not correct: (%d) [%v]", offset, isValid)
+	}
+
+	offset, isValid = DecodeUtcOffset(0x80 | 0x40)
+	if isValid != true || offset != -64*15*60 {
+		t.Fatalf("Negative offset not correct: (%d) [%v]", offset, isValid)
+	}
+
+	_, isValid = DecodeUtcOffset(0x7f)
+	if isValid != false {
+		t.Fatalf("Expected offset to not be valid.")
+	}
+}
+
+func TestNewExfatTimestamp(t *testing.T) {
+	location := time.FixedZone("test", -5*60*60)
+	original := time.Date(2020, 3, 4, 5, 6, 9, 500000000, location)
+
+	et, increment10ms, utcOffset := NewExfatTimestamp(original)
+
+	if increment10ms != 150 {
+		t.Fatalf("Increment not correct: (%d)", increment10ms)
+	} else if utcOffset != 0x80|108 {
+		t.Fatalf("UTC offset not correct: (0x%02x)", utcOffset)
+	}
+
+	recovered := et.Timestamp(increment10ms, utcOffset)
+	if recovered.Equal(original) != true {
+		t.Fatalf("Timestamp did not survive the round-trip: [%s] != [%s]", recovered, original)
+	}
+
+	// An offset that can't be expressed is converted to UTC.
+
+	location = time.FixedZone("test", 7*60)
+	original = time.Date(2020, 3, 4, 5, 6, 8, 0, location)
+
+	et, increment10ms, utcOffset = NewExfatTimestamp(original)
+
+	if utcOffset != 0x80 {
+		t.Fatalf("Expected UTC: (0x%02x)", utcOffset)
+	} else if et.Timestamp(increment10ms, utcOffset).Equal(original) != true {
+		t.Fatalf("UTC timestamp not correct.")
+	}
+
+	// Times before the epoch are clamped.
+
+	et, increment10ms, utcOffset = NewExfatTimestamp(time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	expected := time.Date(1980, 1, 1, 0, 0, 0, 0, time.UTC)
+	if et.Timestamp(increment10ms, utcOffset).Equal(expected) != true {
+		t.Fatalf("Timestamp not clamped: [%s]", et.Timestamp(increment10ms, utcOffset))
+	}
+}
+
+func TestExfatFileDirectoryEntry_LastModifiedTimestampUTC(t *testing.T) {
+	fdf := ExfatFileDirectoryEntry{
+		LastModifiedTimestampRaw:  ExfatTimestamp(40<<25 | 3<<21 | 4<<16 | 5<<11 | 6<<5 | 4),
+		LastModified10msIncrement: 1,
+		LastModifiedUtcOffset:     0x80 | 4,
+	}
+
+	expected := time.Date(2020, 3, 4, 4, 6, 8, 10000000, time.UTC)
+
+	actual := fdf.LastModifiedTimestampUTC()
+	if actual != expected {
+		t.Fatalf("Timestamp not correct: [%s]", actual)
+	}
+}
+
 func TestDirectoryEntryParserKey_String(t *testing.T) {
 	depk := DirectoryEntryParserKey{}
 	s := depk.String()
@@ -33,6 +161,30 @@ func TestDirectoryEntryParserKey_String(t *testing.T) {
 	}
 }
 
+func TestFileAttributes_Flags(t *testing.T) {
+	if flags := FileAttributes(0).Flags(); flags != "-----" {
+		t.Fatalf("Flags not correct for no attributes: [%s]", flags)
+	} else if flags := FileAttributes(0x1234).Flags(); flags != "--SDA" {
+		t.Fatalf("Flags not correct: [%s]", flags)
+	} else if flags := FileAttributes(0x37).Flags(); flags != "RHSDA" {
+		t.Fatalf("Flags not correct for all attributes: [%s]", flags)
+	}
+}
+
+func TestFileAttributes_ReservedBits(t *testing.T) {
+	fa := FileAttributes(0x0430)
+
+	if fa.RawAttributes() != 0x0430 {
+		t.Fatalf("RawAttributes not correct: (0x%04x)", fa.RawAttributes())
+	} else if fa.ReservedBits() != 0x0400 {
+		t.Fatalf("ReservedBits not correct: (0x%04x)", fa.ReservedBits())
+	} else if FileAttributes(0x37).ReservedBits() != 0 {
+		t.Fatalf("Expected no reserved bits for the defined attributes.")
+	} else if FileAttributes(0x08).ReservedBits() != 0x08 {
+		t.Fatalf("Expected bit 3 to be reserved.")
+	}
+}
+
 func TestFileAttributes_String(t *testing.T) {
 	s := FileAttributes(0x1234).String()
 	if s != "FileAttributes<IS-READONLY=[false] IS-HIDDEN=[false] IS-SYSTEM=[true] IS-DIRECTORY=[true] IS-ARCHIVE=[true]>" {
@@ -48,6 +200,43 @@ func TestExfatVolumeGuidDirectoryEntry_String(t *testing.T) {
 	}
 }
 
+func TestExfatVolumeGuidDirectoryEntry_DumpTo(t *testing.T) {
+	vgde := ExfatVolumeGuidDirectoryEntry{
+		GeneralPrimaryFlags: 3,
+	}
+
+	b := new(bytes.Buffer)
+	vgde.DumpTo(b)
+
+	if strings.Contains(b.String(), "IsAllocationPossible: [true]") != true {
+		t.Fatalf("Flags not dumped:\n%s", b.String())
+	} else if strings.Contains(b.String(), "NoFatChain: [true]") != true {
+		t.Fatalf("Flags not dumped:\n%s", b.String())
+	}
+}
+
+func TestGeneralPrimaryFlags(t *testing.T) {
+	gpf := GeneralPrimaryFlags(1)
+
+	if gpf.IsAllocationPossible() != true {
+		t.Fatalf("IsAllocationPossible not correct.")
+	} else if gpf.NoFatChain() != false {
+		t.Fatalf("NoFatChain not correct.")
+	}
+
+	gpf = GeneralPrimaryFlags(2)
+
+	if gpf.IsAllocationPossible() != false {
+		t.Fatalf("IsAllocationPossible not correct.")
+	} else if gpf.NoFatChain() != true {
+		t.Fatalf("NoFatChain not correct.")
+	}
+
+	if s := gpf.String(); s != "GeneralPrimaryFlags<IsAllocationPossible=[false] NoFatChain=[true]>" {
+		t.Fatalf("String not correct: [%s]", s)
+	}
+}
+
 func TestExfatVolumeGuidDirectoryEntry_SecondaryCount(t *testing.T) {
 	vgde := ExfatVolumeGuidDirectoryEntry{
 		SecondaryCountRaw: 99,
@@ -68,11 +257,38 @@ func TestExfatVolumeGuidDirectoryEntry_TypeName(t *testing.T) {
 func TestExfatTexFATDirectoryEntry_String(t *testing.T) {
 	tfde := ExfatTexFATDirectoryEntry{}
 	s := tfde.String()
-	if s != "TexFATDirectoryEntry<>" {
+	if s != "TexFATDirectoryEntry<SECONDARY-COUNT=(0) GENERAL-PRIMARY-FLAGS=(0x0000) FIRST-CLUSTER=(0) DATA-LENGTH=(0)>" {
 		t.Fatalf("String not correct: [%s]", s)
 	}
 }
 
+func TestExfatTexFATDirectoryEntry__Parse(t *testing.T) {
+	data := make([]byte, 32)
+	data[0] = 0xa1
+	data[1] = 0
+	defaultEncoding.PutUint16(data[4:6], 0x0002)
+	defaultEncoding.PutUint32(data[20:24], 17)
+	defaultEncoding.PutUint64(data[24:32], 4096)
+
+	de, err := parseDirectoryEntry(EntryType(data[0]), data)
+	log.PanicIf(err)
+
+	tfde, ok := de.(*ExfatTexFATDirectoryEntry)
+	if ok != true {
+		t.Fatalf("Entry not the right type: [%v]", de)
+	}
+
+	if tfde.SecondaryCount() != 0 {
+		t.Fatalf("SecondaryCount not correct: (%d)", tfde.SecondaryCount())
+	} else if tfde.GeneralPrimaryFlags != 2 {
+		t.Fatalf("GeneralPrimaryFlags not correct: (0x%04x)", tfde.GeneralPrimaryFlags)
+	} else if tfde.FirstCluster != 17 {
+		t.Fatalf("FirstCluster not correct: (%d)", tfde.FirstCluster)
+	} else if tfde.DataLength != 4096 {
+		t.Fatalf("DataLength not correct: (%d)", tfde.DataLength)
+	}
+}
+
 func TestExfatTexFATDirectoryEntry_TypeName(t *testing.T) {
 	tfde := ExfatTexFATDirectoryEntry{}
 	if tfde.TypeName() != "TexFAT" {
@@ -109,3 +325,24 @@ func TestExfatVendorAllocationDirectoryEntry_TypeName(t *testing.T) {
 		t.Fatalf("TypeName not correct.")
 	}
 }
+
+func TestParseDirectoryEntry__DecodersMatchUnpack(t *testing.T) {
+	raw := make([]byte, directoryEntryBytesCount)
+	for i := range raw {
+		raw[i] = byte(i*7 + 3)
+	}
+
+	for depk, decoder := range directoryEntryDecoders {
+		s := reflect.New(directoryEntryParsers[depk])
+		x := s.Interface()
+
+		err := restruct.Unpack(raw, defaultEncoding, x)
+		log.PanicIf(err)
+
+		decoded := decoder(raw)
+
+		if reflect.DeepEqual(decoded, x) != true {
+			t.Fatalf("Decoder does not match unpacked struct for %s: %v != %v", depk, decoded, x)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+// This package supports reading the raw entry stream of a directory.
+
+package exfat
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/dsoprea/go-logging"
+)
+
+// RawDirectory is the complete stream of directory-entry records for a single
+// directory, for analyzers that need to interpret the records themselves.
+type RawDirectory struct {
+	// Data is every 32-byte record, in order, up to but not including the
+	// end-of-directory record.
+	Data []byte
+
+	// Entries has one item for each record in Data, including records that
+	// are no longer in use and secondary records that don't belong to any
+	// set. `Entry` is nil for records with a type that we don't recognize.
+	Entries []RawDirectoryEntry
+}
+
+// String returns a descriptive string.
+func (rd *RawDirectory) String() string {
+	return fmt.Sprintf("RawDirectory<ENTRIES=(%d)>", len(rd.Entries))
+}
+
+// Dump prints a decoded listing of every record.
+func (rd *RawDirectory) Dump() {
+	rd.DumpTo(os.Stdout)
+}
+
+// DumpTo is the same as Dump() but writes to the given writer.
+func (rd *RawDirectory) DumpTo(w io.Writer) {
+	fmt.Fprintf(w, "Raw Directory\n")
+	fmt.Fprintf(w, "=============\n")
+	fmt.Fprintf(w, "\n")
+
+	for _, rde := range rd.Entries {
+		fmt.Fprintf(w, "(%d) %s IN-USE=[%v]\n", rde.Location.EntryNumber, rde, rde.EntryType().IsInUse())
+	}
+
+	fmt.Fprintf(w, "\n")
+}
+
+// ReadRawDirectory reads every record in the directory, whether or not it's in
+// use or part of a valid set, until the end-of-directory record.
+func (en *ExfatNavigator) ReadRawDirectory() (rd *RawDirectory, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	rd = &RawDirectory{
+		Data:    make([]byte, 0),
+		Entries: make([]RawDirectoryEntry, 0),
+	}
+
+	sectorSize := en.er.SectorSize()
+	entryNumber := 0
+	isDone := false
+
+	cvf := func(ec *ExfatCluster) (doContinue bool, err error) {
+		defer func() {
+			if errRaw := recover(); errRaw != nil {
+				err = log.Wrap(errRaw.(error))
+			}
+		}()
+
+		sectorIndex := uint32(0)
+
+		svf := func(sectorNumber uint32, data []byte) (doContinue bool, err error) {
+			sectorOffset := ec.clusterOffset + int64(sectorIndex)*int64(sectorSize)
+
+			for i := 0; uint32((i+1)*directoryEntryBytesCount) <= sectorSize; i++ {
+				directoryEntryData := data[i*directoryEntryBytesCount : (i+1)*directoryEntryBytesCount]
+
+				entryType := EntryType(directoryEntryData[0])
+				if entryType.IsEndOfDirectory() == true {
+					isDone = true
+					return false, nil
+				}
+
+				// The sector buffer is reused.
+				raw := make([]byte, directoryEntryBytesCount)
+				copy(raw, directoryEntryData)
+
+				// Records that we can't parse are still reported.
+				de, err := parseDirectoryEntry(entryType, raw)
+				if err != nil {
+					de = nil
+				}
+
+				rde := RawDirectoryEntry{
+					Entry: de,
+					Raw:   raw,
+					Location: EntryLocation{
+						ClusterNumber: ec.ClusterNumber(),
+						SectorIndex:   sectorIndex,
+						SectorNumber:  uint64(sectorOffset) / uint64(sectorSize),
+						Offset:        sectorOffset + int64(i*directoryEntryBytesCount),
+						EntryNumber:   entryNumber,
+					},
+				}
+
+				rd.Data = append(rd.Data, raw...)
+				rd.Entries = append(rd.Entries, rde)
+
+				entryNumber++
+			}
+
+			sectorIndex++
+
+			return true, nil
+		}
+
+		err = ec.EnumerateSectors(svf)
+		log.PanicIf(err)
+
+		return isDone == false, nil
+	}
+
+	err = en.enumerateDirectoryClusters(en.firstClusterNumber, cvf)
+	log.PanicIf(err)
+
+	return rd, nil
+}
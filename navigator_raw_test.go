@@ -0,0 +1,61 @@
+package exfat
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/dsoprea/go-logging"
+)
+
+func TestExfatNavigator_ReadRawDirectory(t *testing.T) {
+	f, er := getTestFileAndParser()
+
+	defer f.Close()
+
+	err := er.Parse()
+	log.PanicIf(err)
+
+	en := NewExfatNavigator(er, er.FirstClusterOfRootDirectory())
+
+	rd, err := en.ReadRawDirectory()
+	log.PanicIf(err)
+
+	if len(rd.Data) != len(rd.Entries)*directoryEntryBytesCount {
+		t.Fatalf("Data size not correct: (%d) != (%d)", len(rd.Data), len(rd.Entries)*directoryEntryBytesCount)
+	}
+
+	for i, rde := range rd.Entries {
+		if rde.Location.EntryNumber != i {
+			t.Fatalf("Entry-number not correct: (%d) != (%d)", rde.Location.EntryNumber, i)
+		} else if bytes.Equal(rde.Raw, rd.Data[i*directoryEntryBytesCount:(i+1)*directoryEntryBytesCount]) != true {
+			t.Fatalf("Raw data for (%d) not correct.", i)
+		}
+	}
+
+	// Every entry of every set is in the stream, at the same position.
+
+	sets := getRootEntrySets()
+
+	count := 0
+	for _, es := range sets {
+		for i, rde := range es.RawEntries() {
+			current := rd.Entries[rde.Location.EntryNumber]
+
+			if current.Location != rde.Location {
+				t.Fatalf("Location not correct: %s != %s", current.Location, rde.Location)
+			} else if bytes.Equal(current.Raw, es.RawEntry(i)) != true {
+				t.Fatalf("Raw data not correct: %s", current)
+			} else if current.Entry.TypeName() != rde.Entry.TypeName() {
+				t.Fatalf("Entry not correct: %s", current)
+			}
+
+			count++
+		}
+	}
+
+	if count > len(rd.Entries) {
+		t.Fatalf("Stream has fewer entries than the sets: (%d) > (%d)", count, len(rd.Entries))
+	}
+
+	rd.Dump()
+}
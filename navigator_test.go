@@ -1,6 +1,7 @@
 package exfat
 
 import (
+	"bytes"
 	"fmt"
 	"reflect"
 	"sort"
@@ -158,7 +159,13 @@ func TestExfatNavigator_IndexDirectoryEntries(t *testing.T) {
 	files := make([]string, len(index["File"]))
 
 	for i, ide := range index["File"] {
-		files[i] = ide.Extra["complete_filename"].(string)
+		files[i] = ide.ExtraData()["complete_filename"].(string)
+
+		if ide.Filename != files[i] {
+			t.Fatalf("Filename not correct: [%s] != [%s]", ide.Filename, files[i])
+		} else if ide.Extra != nil {
+			t.Fatalf("Extra should not be populated while indexing.")
+		}
 	}
 
 	expectedFilenames := []string{
@@ -184,6 +191,54 @@ func TestExfatNavigator_IndexDirectoryEntries(t *testing.T) {
 	}
 }
 
+func TestExfatNavigator_IndexDirectoryEntries__Location(t *testing.T) {
+	f, er := getTestFileAndParser()
+
+	defer f.Close()
+
+	err := er.Parse()
+	log.PanicIf(err)
+
+	firstClusterNumber := er.FirstClusterOfRootDirectory()
+	en := NewExfatNavigator(er, firstClusterNumber)
+
+	index, _, _, err := en.IndexDirectoryEntries()
+	log.PanicIf(err)
+
+	bsh := er.ActiveBootSectorHeader()
+	sectorSize := int64(bsh.SectorSize())
+
+	// The label is the first entry in the root directory.
+
+	location := index["VolumeLabel"][0].Location
+
+	expectedOffset := (int64(bsh.ClusterHeapOffset) + int64(firstClusterNumber-2)*int64(er.SectorsPerCluster())) * sectorSize
+	if location.EntryNumber != 0 || location.ClusterNumber != firstClusterNumber || location.Offset != expectedOffset {
+		t.Fatalf("Label location not correct: %s", location)
+	}
+
+	for _, ide := range index["File"] {
+		location := ide.Location
+
+		if location != ide.EntrySet.Locations[0] {
+			t.Fatalf("Location does not match the set: %s != %s", location, ide.EntrySet.Locations[0])
+		} else if int64(location.SectorNumber) != location.Offset/sectorSize {
+			t.Fatalf("Sector not correct: %s", location)
+		}
+
+		// The entry can be read back from where it says it is.
+
+		raw := make([]byte, directoryEntryBytesCount)
+
+		_, err := f.ReadAt(raw, location.Offset)
+		log.PanicIf(err)
+
+		if bytes.Equal(raw, ide.EntrySet.RawEntry(0)) != true {
+			t.Fatalf("Entry not found at its location: %s", location)
+		}
+	}
+}
+
 func TestExfatNavigator__NavigateSubdirectory(t *testing.T) {
 	f, er := getTestFileAndParser()
 
@@ -319,7 +374,7 @@ func TestDirectoryEntryIndex_FindIndexedFile__Hit(t *testing.T) {
 			t.Fatalf("File not found: [%s]", filename)
 		}
 
-		foundFilename := ide.Extra["complete_filename"].(string)
+		foundFilename := ide.ExtraData()["complete_filename"].(string)
 		if foundFilename != filename {
 			t.Fatalf("Found entry not correct: [%s] != [%s]", foundFilename, filename)
 		}
@@ -498,3 +553,147 @@ func TestDirectoryEntryIndex_FindIndexedFileDirectoryEntry__MissOnIndex(t *testi
 		t.Fatalf("Expected lookup miss.")
 	}
 }
+
+func TestExfatNavigator_SetProgressCallback(t *testing.T) {
+	f, er := getTestFileAndParser()
+
+	defer f.Close()
+
+	err := er.Parse()
+	log.PanicIf(err)
+
+	en := NewExfatNavigator(er, er.FirstClusterOfRootDirectory())
+
+	calls := make([]EnumerationProgress, 0)
+
+	cb := func(progress EnumerationProgress) (doContinue bool) {
+		calls = append(calls, progress)
+		return true
+	}
+
+	en.SetProgressCallback(cb)
+
+	index, _, _, err := en.IndexDirectoryEntries()
+	log.PanicIf(err)
+
+	if len(calls) != 1 {
+		t.Fatalf("Expected one progress call: (%d)", len(calls))
+	}
+
+	progress := calls[0]
+	if progress.ClusterCount != 1 {
+		t.Fatalf("Cluster count not correct: (%d)", progress.ClusterCount)
+	} else if progress.SetCount != 10 {
+		t.Fatalf("Set count not correct: (%d)", progress.SetCount)
+	} else if progress.EntryCount != 32 {
+		t.Fatalf("Entry count not correct: (%d)", progress.EntryCount)
+	} else if index.FileCount() != 7 {
+		t.Fatalf("Index not correct.")
+	}
+}
+
+func TestExfatNavigator_SetProgressCallback__Cancel(t *testing.T) {
+	f, er := getTestFileAndParser()
+
+	defer f.Close()
+
+	err := er.Parse()
+	log.PanicIf(err)
+
+	en := NewExfatNavigator(er, er.FirstClusterOfRootDirectory())
+
+	cb := func(progress EnumerationProgress) (doContinue bool) {
+		return false
+	}
+
+	en.SetProgressCallback(cb)
+
+	_, _, _, err = en.IndexDirectoryEntries()
+	if err == nil {
+		t.Fatalf("Expected cancellation.")
+	} else if log.Is(err, ErrEnumerationCanceled) != true {
+		t.Fatalf("Expected cancellation error: [%s]", err)
+	}
+}
+
+func BenchmarkExfatNavigator_IndexDirectoryEntries(b *testing.B) {
+	f, er := getTestFileAndParser()
+
+	defer f.Close()
+
+	err := er.Parse()
+	log.PanicIf(err)
+
+	firstClusterNumber := er.FirstClusterOfRootDirectory()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		en := NewExfatNavigator(er, firstClusterNumber)
+
+		_, _, _, err := en.IndexDirectoryEntries()
+		log.PanicIf(err)
+	}
+}
+
+func TestExfatNavigator_SetDataLength(t *testing.T) {
+	er, _ := getTestStraddlingImage()
+
+	tree := NewTree(er)
+
+	directoryNode, err := tree.Lookup([]string{"directory"})
+	log.PanicIf(err)
+
+	sede := directoryNode.StreamDirectoryEntry()
+	clusterSize := uint64(er.ActiveBootSectorHeader().ClusterSize())
+
+	if sede.DataLength != 2*clusterSize {
+		t.Fatalf("Expected the directory to have two clusters: (%d)", sede.DataLength)
+	}
+
+	cb := func(es *EntrySet) (err error) {
+		return nil
+	}
+
+	// Only the first cluster is visited, even though the end-of-directory
+	// entry is in the second.
+
+	en := NewExfatNavigator(er, sede.FirstCluster)
+	en.SetDataLength(clusterSize)
+
+	visitedClusters, _, err := en.EnumerateEntrySets(cb)
+	log.PanicIf(err)
+
+	if len(visitedClusters) != 1 || visitedClusters[0] != sede.FirstCluster {
+		t.Fatalf("Visited clusters not correct: %v", visitedClusters)
+	}
+
+	rd, err := en.ReadRawDirectory()
+	log.PanicIf(err)
+
+	entriesPerCluster := int(clusterSize / directoryEntryBytesCount)
+	if len(rd.Entries) != entriesPerCluster {
+		t.Fatalf("Raw entry count not correct: (%d)", len(rd.Entries))
+	}
+
+	// Resuming past the end visits nothing.
+
+	visitedClusters, _, err = en.enumerateEntrySetsFrom(sede.FirstCluster+1, cb)
+	log.PanicIf(err)
+
+	if len(visitedClusters) != 0 {
+		t.Fatalf("Expected no clusters to be visited: %v", visitedClusters)
+	}
+
+	// The whole directory is visited with its real length.
+
+	en.SetDataLength(sede.DataLength)
+
+	visitedClusters, _, err = en.EnumerateEntrySets(cb)
+	log.PanicIf(err)
+
+	if len(visitedClusters) != 2 {
+		t.Fatalf("Visited clusters not correct: %v", visitedClusters)
+	}
+}
@@ -0,0 +1,374 @@
+// This package supports exporting a file on the volume as a read-only network
+// block device (NBD).
+
+package exfat
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+
+	"encoding/binary"
+
+	"github.com/dsoprea/go-logging"
+)
+
+// See https://github.com/NetworkBlockDevice/nbd/blob/master/doc/proto.md .
+const (
+	nbdMagic            = 0x4e42444d41474943 // "NBDMAGIC"
+	nbdOptionMagic      = 0x49484156454f5054 // "IHAVEOPT"
+	nbdReplyMagic       = 0x0003e889045565a9
+	nbdRequestMagic     = 0x25609513
+	nbdSimpleReplyMagic = 0x67446698
+
+	nbdFlagFixedNewstyle = 1 << 0
+	nbdFlagNoZeroes      = 1 << 1
+
+	nbdFlagHasFlags = 1 << 0
+	nbdFlagReadOnly = 1 << 1
+
+	nbdOptExportName = 1
+	nbdOptAbort      = 2
+	nbdOptList       = 3
+	nbdOptInfo       = 6
+	nbdOptGo         = 7
+
+	nbdRepAck        = 1
+	nbdRepServer     = 2
+	nbdRepInfo       = 3
+	nbdRepErrUnsup   = 1<<31 + 1
+	nbdRepErrInvalid = 1<<31 + 3
+	nbdRepErrUnknown = 1<<31 + 6
+
+	nbdInfoExport = 0
+
+	nbdCmdRead  = 0
+	nbdCmdWrite = 1
+	nbdCmdDisc  = 2
+	nbdCmdFlush = 3
+	nbdCmdTrim  = 4
+
+	nbdEperm  = 1
+	nbdEio    = 5
+	nbdEinval = 22
+
+	// nbdMaxOptionLength bounds the data that a client may send with an
+	// option.
+	nbdMaxOptionLength = 4096
+
+	// nbdMaxReadLength bounds the size of a single read request.
+	nbdMaxReadLength = 32 * 1024 * 1024
+)
+
+var (
+	// errNbdDisconnect indicates that the client ended the session.
+	errNbdDisconnect = errors.New("client disconnected")
+)
+
+// NbdServer exports data (e.g. a disk image stored as a file on the volume)
+// as a read-only network block device, so that it can be attached by an NBD
+// client (e.g. `nbd-client` or `qemu-nbd`) and mounted without extracting it
+// first. Writes and trims are refused with EPERM. Only the fixed-newstyle
+// handshake is supported.
+type NbdServer struct {
+	name string
+	ra   io.ReaderAt
+	size int64
+}
+
+// NewNbdServer returns a server that exports `size` bytes from the given
+// reader under the given name. The reader must be safe for concurrent use by
+// ReadAt() if more than one client will connect.
+func NewNbdServer(name string, ra io.ReaderAt, size int64) *NbdServer {
+	return &NbdServer{
+		name: name,
+		ra:   ra,
+		size: size,
+	}
+}
+
+// NewNbdServer returns a server that exports the file's data under its name.
+// The data is read through a ChainReader, so only the clusters that clients
+// ask for are read.
+func (tn *TreeNode) NewNbdServer() (ns *NbdServer, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	cr, err := tn.NewReader()
+	log.PanicIf(err)
+
+	ns = NewNbdServer(tn.Name(), cr, cr.Size())
+
+	return ns, nil
+}
+
+// String returns a descriptive string.
+func (ns *NbdServer) String() string {
+	return fmt.Sprintf("NbdServer<NAME=[%s] SIZE=(%d)>", ns.name, ns.size)
+}
+
+// Serve accepts connections on the listener and serves each of them in its
+// own goroutine until the listener is closed.
+func (ns *NbdServer) Serve(listener net.Listener) (err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	for {
+		conn, err := listener.Accept()
+		log.PanicIf(err)
+
+		go func() {
+			defer conn.Close()
+
+			// A failed session only affects its own client.
+			ns.ServeConn(conn)
+		}()
+	}
+}
+
+// ServeConn performs the handshake with one client and then serves its
+// requests until it disconnects. The connection is not closed.
+func (ns *NbdServer) ServeConn(conn io.ReadWriter) (err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	isExported, err := ns.handshake(conn)
+	log.PanicIf(err)
+
+	if isExported == false {
+		return nil
+	}
+
+	for {
+		err := ns.serveRequest(conn)
+		if err == errNbdDisconnect {
+			return nil
+		}
+
+		log.PanicIf(err)
+	}
+}
+
+// handshake negotiates the export. It returns false if the client aborted.
+func (ns *NbdServer) handshake(conn io.ReadWriter) (isExported bool, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	greeting := make([]byte, 18)
+	binary.BigEndian.PutUint64(greeting[0:8], nbdMagic)
+	binary.BigEndian.PutUint64(greeting[8:16], nbdOptionMagic)
+	binary.BigEndian.PutUint16(greeting[16:18], nbdFlagFixedNewstyle|nbdFlagNoZeroes)
+
+	_, err = conn.Write(greeting)
+	log.PanicIf(err)
+
+	var clientFlags uint32
+
+	err = binary.Read(conn, binary.BigEndian, &clientFlags)
+	log.PanicIf(err)
+
+	if clientFlags&nbdFlagFixedNewstyle == 0 {
+		log.Panicf("client does not support the fixed-newstyle handshake: (0x%08x)", clientFlags)
+	}
+
+	sendZeroes := clientFlags&nbdFlagNoZeroes == 0
+
+	header := make([]byte, 16)
+
+	for {
+		_, err := io.ReadFull(conn, header)
+		log.PanicIf(err)
+
+		if binary.BigEndian.Uint64(header[0:8]) != nbdOptionMagic {
+			log.Panicf("option magic not valid")
+		}
+
+		option := binary.BigEndian.Uint32(header[8:12])
+		length := binary.BigEndian.Uint32(header[12:16])
+
+		if length > nbdMaxOptionLength {
+			log.Panicf("option data too long: (%d)", length)
+		}
+
+		data := make([]byte, length)
+
+		_, err = io.ReadFull(conn, data)
+		log.PanicIf(err)
+
+		switch option {
+		case nbdOptExportName:
+			// There is no way to refuse an unknown export other than hanging
+			// up.
+			if string(data) != ns.name && string(data) != "" {
+				log.Panicf("export not known: [%s]", string(data))
+			}
+
+			reply := make([]byte, 10)
+			binary.BigEndian.PutUint64(reply[0:8], uint64(ns.size))
+			binary.BigEndian.PutUint16(reply[8:10], nbdFlagHasFlags|nbdFlagReadOnly)
+
+			if sendZeroes == true {
+				reply = append(reply, make([]byte, 124)...)
+			}
+
+			_, err := conn.Write(reply)
+			log.PanicIf(err)
+
+			return true, nil
+		case nbdOptAbort:
+			err := ns.writeOptionReply(conn, option, nbdRepAck, nil)
+			log.PanicIf(err)
+
+			return false, nil
+		case nbdOptList:
+			server := make([]byte, 4+len(ns.name))
+			binary.BigEndian.PutUint32(server[0:4], uint32(len(ns.name)))
+			copy(server[4:], ns.name)
+
+			err := ns.writeOptionReply(conn, option, nbdRepServer, server)
+			log.PanicIf(err)
+
+			err = ns.writeOptionReply(conn, option, nbdRepAck, nil)
+			log.PanicIf(err)
+		case nbdOptInfo, nbdOptGo:
+			if len(data) < 6 || uint64(len(data)) < 6+uint64(binary.BigEndian.Uint32(data[0:4])) {
+				err := ns.writeOptionReply(conn, option, nbdRepErrInvalid, nil)
+				log.PanicIf(err)
+
+				continue
+			}
+
+			name := string(data[4 : 4+binary.BigEndian.Uint32(data[0:4])])
+
+			if name != ns.name && name != "" {
+				err := ns.writeOptionReply(conn, option, nbdRepErrUnknown, nil)
+				log.PanicIf(err)
+
+				continue
+			}
+
+			info := make([]byte, 12)
+			binary.BigEndian.PutUint16(info[0:2], nbdInfoExport)
+			binary.BigEndian.PutUint64(info[2:10], uint64(ns.size))
+			binary.BigEndian.PutUint16(info[10:12], nbdFlagHasFlags|nbdFlagReadOnly)
+
+			err := ns.writeOptionReply(conn, option, nbdRepInfo, info)
+			log.PanicIf(err)
+
+			err = ns.writeOptionReply(conn, option, nbdRepAck, nil)
+			log.PanicIf(err)
+
+			if option == nbdOptGo {
+				return true, nil
+			}
+		default:
+			err := ns.writeOptionReply(conn, option, nbdRepErrUnsup, nil)
+			log.PanicIf(err)
+		}
+	}
+}
+
+// writeOptionReply sends one reply to an option during the handshake.
+func (ns *NbdServer) writeOptionReply(w io.Writer, option, replyType uint32, data []byte) (err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	reply := make([]byte, 20, 20+len(data))
+	binary.BigEndian.PutUint64(reply[0:8], nbdReplyMagic)
+	binary.BigEndian.PutUint32(reply[8:12], option)
+	binary.BigEndian.PutUint32(reply[12:16], replyType)
+	binary.BigEndian.PutUint32(reply[16:20], uint32(len(data)))
+
+	reply = append(reply, data...)
+
+	_, err = w.Write(reply)
+	log.PanicIf(err)
+
+	return nil
+}
+
+// serveRequest reads and answers one request. It returns errNbdDisconnect
+// when the client disconnects.
+func (ns *NbdServer) serveRequest(conn io.ReadWriter) (err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	request := make([]byte, 28)
+
+	_, err = io.ReadFull(conn, request)
+	if err == io.EOF {
+		return errNbdDisconnect
+	}
+
+	log.PanicIf(err)
+
+	if binary.BigEndian.Uint32(request[0:4]) != nbdRequestMagic {
+		log.Panicf("request magic not valid")
+	}
+
+	command := binary.BigEndian.Uint16(request[6:8])
+	handle := request[8:16]
+	offset := binary.BigEndian.Uint64(request[16:24])
+	length := binary.BigEndian.Uint32(request[24:28])
+
+	reply := make([]byte, 16)
+	binary.BigEndian.PutUint32(reply[0:4], nbdSimpleReplyMagic)
+	copy(reply[8:16], handle)
+
+	switch command {
+	case nbdCmdRead:
+		if length > nbdMaxReadLength || offset > uint64(ns.size) || uint64(length) > uint64(ns.size)-offset {
+			binary.BigEndian.PutUint32(reply[4:8], nbdEinval)
+			break
+		}
+
+		data := make([]byte, length)
+
+		n, err := ns.ra.ReadAt(data, int64(offset))
+		if n < len(data) && err != nil {
+			binary.BigEndian.PutUint32(reply[4:8], nbdEio)
+			break
+		}
+
+		reply = append(reply, data...)
+	case nbdCmdWrite:
+		// The data follows the request and has to be consumed.
+		_, err := io.CopyN(ioutil.Discard, conn, int64(length))
+		log.PanicIf(err)
+
+		binary.BigEndian.PutUint32(reply[4:8], nbdEperm)
+	case nbdCmdTrim:
+		binary.BigEndian.PutUint32(reply[4:8], nbdEperm)
+	case nbdCmdFlush:
+		// Nothing is ever written, so there's nothing to flush.
+	case nbdCmdDisc:
+		return errNbdDisconnect
+	default:
+		binary.BigEndian.PutUint32(reply[4:8], nbdEinval)
+	}
+
+	_, err = conn.Write(reply)
+	log.PanicIf(err)
+
+	return nil
+}
@@ -0,0 +1,262 @@
+package exfat
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"testing"
+
+	"encoding/binary"
+
+	"github.com/dsoprea/go-logging"
+)
+
+// testNbdClient is the client side of an NBD session.
+type testNbdClient struct {
+	conn net.Conn
+}
+
+func (tnc testNbdClient) write(values ...interface{}) {
+	for _, value := range values {
+		if s, ok := value.(string); ok == true {
+			value = []byte(s)
+		}
+
+		err := binary.Write(tnc.conn, binary.BigEndian, value)
+		log.PanicIf(err)
+	}
+}
+
+func (tnc testNbdClient) read(size int) []byte {
+	data := make([]byte, size)
+
+	_, err := io.ReadFull(tnc.conn, data)
+	log.PanicIf(err)
+
+	return data
+}
+
+// readOptionReply returns the type and data of the next option reply.
+func (tnc testNbdClient) readOptionReply() (replyType uint32, data []byte) {
+	header := tnc.read(20)
+
+	if binary.BigEndian.Uint64(header[0:8]) != nbdReplyMagic {
+		log.Panicf("reply magic not correct")
+	}
+
+	replyType = binary.BigEndian.Uint32(header[12:16])
+	data = tnc.read(int(binary.BigEndian.Uint32(header[16:20])))
+
+	return replyType, data
+}
+
+// request sends a request and returns the error from the reply, along with the
+// data for a read.
+func (tnc testNbdClient) request(command uint16, offset uint64, length uint32, payload []byte) (errorCode uint32, data []byte) {
+	tnc.write(uint32(nbdRequestMagic), uint16(0), command, uint64(0x1122334455667788), offset, length)
+
+	// An empty write on a pipe blocks until the other side reads.
+	if len(payload) > 0 {
+		tnc.write(payload)
+	}
+
+	if command == nbdCmdDisc {
+		return 0, nil
+	}
+
+	reply := tnc.read(16)
+
+	if binary.BigEndian.Uint32(reply[0:4]) != nbdSimpleReplyMagic {
+		log.Panicf("simple-reply magic not correct")
+	} else if binary.BigEndian.Uint64(reply[8:16]) != 0x1122334455667788 {
+		log.Panicf("handle not correct")
+	}
+
+	errorCode = binary.BigEndian.Uint32(reply[4:8])
+
+	if command == nbdCmdRead && errorCode == 0 {
+		data = tnc.read(int(length))
+	}
+
+	return errorCode, data
+}
+
+// getTestNbdSession starts a server for the test data on one end of a pipe and
+// completes the greeting on the other.
+func getTestNbdSession(data []byte) (tnc testNbdClient, done chan error) {
+	ns := NewNbdServer("test", bytes.NewReader(data), int64(len(data)))
+
+	serverConn, clientConn := net.Pipe()
+
+	done = make(chan error, 1)
+
+	go func() {
+		done <- ns.ServeConn(serverConn)
+		serverConn.Close()
+	}()
+
+	tnc = testNbdClient{
+		conn: clientConn,
+	}
+
+	greeting := tnc.read(18)
+
+	if binary.BigEndian.Uint64(greeting[0:8]) != nbdMagic || binary.BigEndian.Uint64(greeting[8:16]) != nbdOptionMagic {
+		log.Panicf("greeting not correct")
+	}
+
+	tnc.write(uint32(nbdFlagFixedNewstyle | nbdFlagNoZeroes))
+
+	return tnc, done
+}
+
+func TestNbdServer_ServeConn__Go(t *testing.T) {
+	data := make([]byte, 100000)
+	for i := range data {
+		data[i] = byte(i % 251)
+	}
+
+	tnc, done := getTestNbdSession(data)
+
+	// List the exports.
+
+	tnc.write(uint64(nbdOptionMagic), uint32(nbdOptList), uint32(0))
+
+	replyType, server := tnc.readOptionReply()
+	if replyType != nbdRepServer || string(server[4:]) != "test" {
+		t.Fatalf("List not correct: (%d) [%s]", replyType, server)
+	}
+
+	replyType, _ = tnc.readOptionReply()
+	if replyType != nbdRepAck {
+		t.Fatalf("Expected ACK after list: (%d)", replyType)
+	}
+
+	// Ask for an unknown export.
+
+	tnc.write(uint64(nbdOptionMagic), uint32(nbdOptGo), uint32(4+5+2), uint32(5), "other", uint16(0))
+
+	replyType, _ = tnc.readOptionReply()
+	if replyType != nbdRepErrUnknown {
+		t.Fatalf("Expected unknown-export error: (%d)", replyType)
+	}
+
+	// Ask for the export.
+
+	tnc.write(uint64(nbdOptionMagic), uint32(nbdOptGo), uint32(4+4+2), uint32(4), "test", uint16(0))
+
+	replyType, info := tnc.readOptionReply()
+	if replyType != nbdRepInfo {
+		t.Fatalf("Expected info: (%d)", replyType)
+	} else if binary.BigEndian.Uint64(info[2:10]) != uint64(len(data)) {
+		t.Fatalf("Size not correct: (%d)", binary.BigEndian.Uint64(info[2:10]))
+	} else if binary.BigEndian.Uint16(info[10:12])&nbdFlagReadOnly == 0 {
+		t.Fatalf("Expected export to be read-only.")
+	}
+
+	replyType, _ = tnc.readOptionReply()
+	if replyType != nbdRepAck {
+		t.Fatalf("Expected ACK after go: (%d)", replyType)
+	}
+
+	// Transmission
+
+	errorCode, read := tnc.request(nbdCmdRead, 4000, 10000, nil)
+	if errorCode != 0 {
+		t.Fatalf("Read failed: (%d)", errorCode)
+	} else if bytes.Equal(read, data[4000:14000]) != true {
+		t.Fatalf("Read data not correct.")
+	}
+
+	errorCode, _ = tnc.request(nbdCmdRead, uint64(len(data))-10, 11, nil)
+	if errorCode != nbdEinval {
+		t.Fatalf("Expected read past the end to fail: (%d)", errorCode)
+	}
+
+	errorCode, _ = tnc.request(nbdCmdWrite, 0, 3, []byte{1, 2, 3})
+	if errorCode != nbdEperm {
+		t.Fatalf("Expected write to be refused: (%d)", errorCode)
+	}
+
+	errorCode, _ = tnc.request(nbdCmdFlush, 0, 0, nil)
+	if errorCode != 0 {
+		t.Fatalf("Flush failed: (%d)", errorCode)
+	}
+
+	tnc.request(nbdCmdDisc, 0, 0, nil)
+
+	err := <-done
+	log.PanicIf(err)
+
+	if data[0] != 0 || data[1] != 1 {
+		t.Fatalf("Data was modified.")
+	}
+}
+
+func TestNbdServer_ServeConn__ExportName(t *testing.T) {
+	tree, closer := getTestTree()
+
+	defer closer()
+
+	node, err := tree.LookupPath("2-delahaye-type-165-cabriolet-dsc_8025.jpg")
+	log.PanicIf(err)
+
+	ns, err := node.NewNbdServer()
+	log.PanicIf(err)
+
+	expected, err := tree.er.ReadFile("2-delahaye-type-165-cabriolet-dsc_8025.jpg")
+	log.PanicIf(err)
+
+	serverConn, clientConn := net.Pipe()
+
+	done := make(chan error, 1)
+
+	go func() {
+		done <- ns.ServeConn(serverConn)
+		serverConn.Close()
+	}()
+
+	tnc := testNbdClient{
+		conn: clientConn,
+	}
+
+	tnc.read(18)
+
+	// Without NO_ZEROES, the export-name reply is padded.
+	tnc.write(uint32(nbdFlagFixedNewstyle))
+
+	name := node.Name()
+	tnc.write(uint64(nbdOptionMagic), uint32(nbdOptExportName), uint32(len(name)), name)
+
+	reply := tnc.read(10 + 124)
+	if binary.BigEndian.Uint64(reply[0:8]) != uint64(len(expected)) {
+		t.Fatalf("Size not correct: (%d)", binary.BigEndian.Uint64(reply[0:8]))
+	}
+
+	// Read across several clusters.
+	errorCode, read := tnc.request(nbdCmdRead, 5000, 20000, nil)
+	if errorCode != 0 {
+		t.Fatalf("Read failed: (%d)", errorCode)
+	} else if bytes.Equal(read, expected[5000:25000]) != true {
+		t.Fatalf("Read data not correct.")
+	}
+
+	tnc.request(nbdCmdDisc, 0, 0, nil)
+
+	err = <-done
+	log.PanicIf(err)
+}
+
+func TestNbdServer_ServeConn__Abort(t *testing.T) {
+	tnc, done := getTestNbdSession([]byte{1, 2, 3})
+
+	tnc.write(uint64(nbdOptionMagic), uint32(nbdOptAbort), uint32(0))
+
+	replyType, _ := tnc.readOptionReply()
+	if replyType != nbdRepAck {
+		t.Fatalf("Expected ACK for abort: (%d)", replyType)
+	}
+
+	err := <-done
+	log.PanicIf(err)
+}
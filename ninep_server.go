@@ -0,0 +1,741 @@
+// This package supports exporting a tree read-only over the 9P2000.L
+// protocol.
+
+package exfat
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+
+	"encoding/binary"
+	"hash/fnv"
+
+	"github.com/dsoprea/go-logging"
+)
+
+// See https://github.com/chaos/diod/blob/master/protocol.md (9P2000.L) and
+// http://man.cat-v.org/plan_9/5/ (the base protocol).
+const (
+	ninePVersion = "9P2000.L"
+
+	ninePTlerror      = 6
+	ninePTstatfs      = 8
+	ninePTlopen       = 12
+	ninePTlcreate     = 14
+	ninePTsymlink     = 16
+	ninePTmknod       = 18
+	ninePTrename      = 20
+	ninePTreadlink    = 22
+	ninePTgetattr     = 24
+	ninePTsetattr     = 26
+	ninePTxattrwalk   = 30
+	ninePTxattrcreate = 32
+	ninePTreaddir     = 40
+	ninePTfsync       = 50
+	ninePTlink        = 70
+	ninePTmkdir       = 72
+	ninePTrenameat    = 74
+	ninePTunlinkat    = 76
+	ninePTversion     = 100
+	ninePTauth        = 102
+	ninePTattach      = 104
+	ninePTflush       = 108
+	ninePTwalk        = 110
+	ninePTread        = 116
+	ninePTwrite       = 118
+	ninePTclunk       = 120
+	ninePTremove      = 122
+
+	ninePQidTypeDir  = 0x80
+	ninePQidTypeFile = 0x00
+
+	// ninePGetattrBasic is the set of fields that Rgetattr always fills.
+	ninePGetattrBasic = 0x000007ff
+
+	// ninePHeaderSize is the size of the size, type, and tag fields.
+	ninePHeaderSize = 4 + 1 + 2
+
+	// ninePMaxMessageSize bounds the message-size that a client may
+	// negotiate.
+	ninePMaxMessageSize = 1024 * 1024
+
+	// ninePMinMessageSize is the smallest message-size that is useful.
+	ninePMinMessageSize = 4096
+
+	ninePWalkMaxElements = 16
+
+	ninePV9fsMagic = 0x01021997
+
+	ninePModeDir     = 0040000
+	ninePModeRegular = 0100000
+
+	ninePDirentTypeDir     = 4
+	ninePDirentTypeRegular = 8
+
+	ninePOpenAccessMask = 3
+	ninePOpenTrunc      = 01000
+)
+
+// ninePErrno is a Linux error-number that is returned to the client in an
+// Rlerror.
+type ninePErrno uint32
+
+const (
+	ninePEnoent     ninePErrno = 2
+	ninePEio        ninePErrno = 5
+	ninePEbadf      ninePErrno = 9
+	ninePEnotdir    ninePErrno = 20
+	ninePEisdir     ninePErrno = 21
+	ninePEinval     ninePErrno = 22
+	ninePErofs      ninePErrno = 30
+	ninePEnodata    ninePErrno = 61
+	ninePEopnotsupp ninePErrno = 95
+)
+
+// Error returns the error-number as a string.
+func (errno ninePErrno) Error() string {
+	return fmt.Sprintf("9P error (%d)", uint32(errno))
+}
+
+var (
+	// errNinePShortMessage indicates that a message ended before all of its
+	// fields.
+	errNinePShortMessage = errors.New("9P message too short")
+)
+
+// ninePDecoder reads the fields of a message.
+type ninePDecoder struct {
+	data []byte
+}
+
+func (npd *ninePDecoder) next(size int) []byte {
+	if len(npd.data) < size {
+		log.Panic(errNinePShortMessage)
+	}
+
+	field := npd.data[:size]
+	npd.data = npd.data[size:]
+
+	return field
+}
+
+func (npd *ninePDecoder) uint16() uint16 {
+	return binary.LittleEndian.Uint16(npd.next(2))
+}
+
+func (npd *ninePDecoder) uint32() uint32 {
+	return binary.LittleEndian.Uint32(npd.next(4))
+}
+
+func (npd *ninePDecoder) uint64() uint64 {
+	return binary.LittleEndian.Uint64(npd.next(8))
+}
+
+func (npd *ninePDecoder) string() string {
+	length := npd.uint16()
+	return string(npd.next(int(length)))
+}
+
+// ninePEncoder builds a message.
+type ninePEncoder struct {
+	data []byte
+}
+
+func (npe *ninePEncoder) uint8(value uint8) {
+	npe.data = append(npe.data, value)
+}
+
+func (npe *ninePEncoder) uint16(value uint16) {
+	npe.data = append(npe.data, 0, 0)
+	binary.LittleEndian.PutUint16(npe.data[len(npe.data)-2:], value)
+}
+
+func (npe *ninePEncoder) uint32(value uint32) {
+	npe.data = append(npe.data, 0, 0, 0, 0)
+	binary.LittleEndian.PutUint32(npe.data[len(npe.data)-4:], value)
+}
+
+func (npe *ninePEncoder) uint64(value uint64) {
+	npe.data = append(npe.data, 0, 0, 0, 0, 0, 0, 0, 0)
+	binary.LittleEndian.PutUint64(npe.data[len(npe.data)-8:], value)
+}
+
+func (npe *ninePEncoder) string(value string) {
+	npe.uint16(uint16(len(value)))
+	npe.data = append(npe.data, value...)
+}
+
+func (npe *ninePEncoder) qid(qid ninePQid) {
+	npe.uint8(qid.qidType)
+	npe.uint32(0)
+	npe.uint64(qid.path)
+}
+
+// ninePQid uniquely identifies a file to the client.
+type ninePQid struct {
+	qidType uint8
+	path    uint64
+}
+
+// ninePQidSize is the encoded size of a qid.
+const ninePQidSize = 1 + 4 + 8
+
+// ninePFid is a file that the client has walked to.
+type ninePFid struct {
+	pathParts []string
+	node      *TreeNode
+	isOpen    bool
+
+	// cr reads the data of an open file.
+	cr *ChainReader
+}
+
+// NinePServer exports a tree read-only over 9P2000.L, so that the image can
+// be mounted by the Linux 9P client (e.g. inside a VM or under WSL) without
+// FUSE:
+//
+//	mount -t 9p -o trans=tcp,port=5640,version=9p2000.L,ro 10.0.2.2 /mnt
+//
+// Any request that would change the volume fails with EROFS.
+type NinePServer struct {
+	tree *Tree
+
+	freeClustersOnce sync.Once
+	freeClusters     uint32
+}
+
+// NewNinePServer returns a server for the given tree. The tree may be loaded
+// or not; directories are loaded as they're walked.
+func NewNinePServer(tree *Tree) *NinePServer {
+	return &NinePServer{
+		tree: tree,
+	}
+}
+
+// String returns a descriptive string.
+func (nps *NinePServer) String() string {
+	return fmt.Sprintf("NinePServer<VERSION=[%s]>", ninePVersion)
+}
+
+// Serve accepts connections on the listener and serves each of them in its
+// own goroutine until the listener is closed.
+func (nps *NinePServer) Serve(listener net.Listener) (err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	for {
+		conn, err := listener.Accept()
+		log.PanicIf(err)
+
+		go func() {
+			defer conn.Close()
+
+			// A failed session only affects its own client.
+			nps.ServeConn(conn)
+		}()
+	}
+}
+
+// ninePSession is the state of one connection.
+type ninePSession struct {
+	nps *NinePServer
+
+	messageSize uint32
+	fids        map[uint32]*ninePFid
+}
+
+// ServeConn serves requests from one client, in order, until it disconnects.
+// The connection is not closed.
+func (nps *NinePServer) ServeConn(conn io.ReadWriter) (err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	nps.freeClustersOnce.Do(func() {
+		ab, err := nps.tree.er.ReadAllocationBitmap()
+		if err == nil {
+			nps.freeClusters = ab.FreeClusterCount()
+		}
+	})
+
+	session := &ninePSession{
+		nps:         nps,
+		messageSize: ninePMinMessageSize,
+		fids:        make(map[uint32]*ninePFid),
+	}
+
+	header := make([]byte, ninePHeaderSize)
+
+	for {
+		_, err := io.ReadFull(conn, header)
+		if err == io.EOF {
+			return nil
+		}
+
+		log.PanicIf(err)
+
+		size := binary.LittleEndian.Uint32(header[0:4])
+		messageType := header[4]
+		tag := binary.LittleEndian.Uint16(header[5:7])
+
+		if size < ninePHeaderSize || size > ninePMaxMessageSize {
+			log.Panicf("9P message-size not valid: (%d)", size)
+		}
+
+		body := make([]byte, size-ninePHeaderSize)
+
+		_, err = io.ReadFull(conn, body)
+		log.PanicIf(err)
+
+		replyType := messageType + 1
+
+		npe := &ninePEncoder{
+			data: make([]byte, ninePHeaderSize, 64),
+		}
+
+		err = session.handle(messageType, &ninePDecoder{data: body}, npe)
+		if err != nil {
+			var errno ninePErrno
+			if errors.As(err, &errno) == false {
+				errno = ninePEio
+			}
+
+			replyType = ninePTlerror + 1
+
+			npe.data = npe.data[:ninePHeaderSize]
+			npe.uint32(uint32(errno))
+		}
+
+		binary.LittleEndian.PutUint32(npe.data[0:4], uint32(len(npe.data)))
+		npe.data[4] = replyType
+		binary.LittleEndian.PutUint16(npe.data[5:7], tag)
+
+		_, err = conn.Write(npe.data)
+		log.PanicIf(err)
+	}
+}
+
+// handle decodes one request and encodes the body of its reply. A returned
+// ninePErrno (possibly wrapped) is sent to the client as-is; any other error
+// is sent as EIO.
+func (session *ninePSession) handle(messageType uint8, npd *ninePDecoder, npe *ninePEncoder) (err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	switch messageType {
+	case ninePTversion:
+		messageSize := npd.uint32()
+		version := npd.string()
+
+		if messageSize > ninePMaxMessageSize {
+			messageSize = ninePMaxMessageSize
+		} else if messageSize < ninePMinMessageSize {
+			return ninePEinval
+		}
+
+		session.messageSize = messageSize
+
+		// A new version resets the session.
+		session.fids = make(map[uint32]*ninePFid)
+
+		if version != ninePVersion {
+			version = "unknown"
+		}
+
+		npe.uint32(messageSize)
+		npe.string(version)
+	case ninePTauth:
+		// No authentication is required.
+		return ninePEopnotsupp
+	case ninePTattach:
+		fid := npd.uint32()
+
+		if _, found := session.fids[fid]; found == true {
+			return ninePEinval
+		}
+
+		root, err := session.nps.tree.Lookup([]string{})
+		log.PanicIf(err)
+
+		session.fids[fid] = &ninePFid{
+			pathParts: []string{},
+			node:      root,
+		}
+
+		npe.qid(session.qid([]string{}, root))
+	case ninePTflush:
+		// Requests are answered in order, so there's never anything to
+		// cancel.
+	case ninePTwalk:
+		return session.walk(npd, npe)
+	case ninePTlopen:
+		f, err := session.fid(npd.uint32())
+		if err != nil {
+			return err
+		}
+
+		flags := npd.uint32()
+
+		if flags&ninePOpenAccessMask != 0 || flags&ninePOpenTrunc != 0 {
+			return ninePErofs
+		} else if f.isOpen == true {
+			return ninePEinval
+		}
+
+		if f.node.IsDirectory() == false {
+			cr, err := f.node.NewReader()
+			log.PanicIf(err)
+
+			f.cr = cr
+		}
+
+		f.isOpen = true
+
+		npe.qid(session.qid(f.pathParts, f.node))
+		npe.uint32(0)
+	case ninePTread:
+		f, err := session.fid(npd.uint32())
+		if err != nil {
+			return err
+		}
+
+		offset := npd.uint64()
+		count := npd.uint32()
+
+		if f.isOpen == false {
+			return ninePEbadf
+		} else if f.cr == nil {
+			return ninePEisdir
+		}
+
+		if maximum := session.messageSize - ninePHeaderSize - 4; count > maximum {
+			count = maximum
+		}
+
+		size := uint64(f.cr.Size())
+		if offset >= size {
+			count = 0
+		} else if uint64(count) > size-offset {
+			count = uint32(size - offset)
+		}
+
+		data := make([]byte, count)
+
+		n, err := f.cr.ReadAt(data, int64(offset))
+		if n < len(data) {
+			log.PanicIf(err)
+		}
+
+		npe.uint32(count)
+		npe.data = append(npe.data, data...)
+	case ninePTreaddir:
+		return session.readdir(npd, npe)
+	case ninePTgetattr:
+		f, err := session.fid(npd.uint32())
+		if err != nil {
+			return err
+		}
+
+		session.getattr(f, npe)
+	case ninePTstatfs:
+		_, err := session.fid(npd.uint32())
+		if err != nil {
+			return err
+		}
+
+		bsh := session.nps.tree.er.ActiveBootSectorHeader()
+
+		npe.uint32(ninePV9fsMagic)
+		npe.uint32(bsh.ClusterSize())
+		npe.uint64(uint64(bsh.ClusterCount))
+		npe.uint64(uint64(session.nps.freeClusters))
+		npe.uint64(uint64(session.nps.freeClusters))
+		npe.uint64(0)
+		npe.uint64(0)
+		npe.uint64(uint64(bsh.VolumeSerialNumber))
+		npe.uint32(255)
+	case ninePTreadlink:
+		// There are no symlinks on exFAT.
+		return ninePEinval
+	case ninePTxattrwalk:
+		return ninePEnodata
+	case ninePTfsync:
+		// Nothing is ever written.
+	case ninePTclunk, ninePTremove:
+		fid := npd.uint32()
+
+		if _, found := session.fids[fid]; found == false {
+			return ninePEbadf
+		}
+
+		// A remove clunks the fid even though it fails.
+		delete(session.fids, fid)
+
+		if messageType == ninePTremove {
+			return ninePErofs
+		}
+	case ninePTwrite, ninePTlcreate, ninePTsymlink, ninePTmknod, ninePTrename, ninePTsetattr, ninePTxattrcreate, ninePTlink, ninePTmkdir, ninePTrenameat, ninePTunlinkat:
+		return ninePErofs
+	default:
+		return ninePEopnotsupp
+	}
+
+	return nil
+}
+
+// fid returns the state for the given fid.
+func (session *ninePSession) fid(fid uint32) (f *ninePFid, err error) {
+	f, found := session.fids[fid]
+	if found == false {
+		return nil, ninePEbadf
+	}
+
+	return f, nil
+}
+
+// qid returns the qid for the node at the given path. The path of the qid is
+// a hash of the file's path, which is stable for as long as it isn't renamed.
+func (session *ninePSession) qid(pathParts []string, node *TreeNode) ninePQid {
+	h := fnv.New64a()
+	h.Write([]byte(JoinVolumePath(pathParts)))
+
+	qid := ninePQid{
+		qidType: ninePQidTypeFile,
+		path:    h.Sum64(),
+	}
+
+	if node.IsDirectory() == true {
+		qid.qidType = ninePQidTypeDir
+	}
+
+	return qid
+}
+
+// walk handles Twalk, which moves from one file to another by name.
+func (session *ninePSession) walk(npd *ninePDecoder, npe *ninePEncoder) (err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	f, err := session.fid(npd.uint32())
+	if err != nil {
+		return err
+	}
+
+	newFid := npd.uint32()
+	count := npd.uint16()
+
+	if count > ninePWalkMaxElements {
+		return ninePEinval
+	} else if f.isOpen == true {
+		return ninePEbadf
+	}
+
+	if existing, found := session.fids[newFid]; found == true && existing != f {
+		return ninePEinval
+	}
+
+	pathParts := append([]string{}, f.pathParts...)
+	node := f.node
+
+	qids := make([]ninePQid, 0, count)
+
+	for i := uint16(0); i < count; i++ {
+		name := npd.string()
+
+		if node.IsDirectory() == false {
+			break
+		}
+
+		if name == ".." {
+			if len(pathParts) > 0 {
+				pathParts = pathParts[:len(pathParts)-1]
+			}
+		} else if name != "." {
+			pathParts = append(pathParts, name)
+		}
+
+		child, err := session.nps.tree.Lookup(pathParts)
+		log.PanicIf(err)
+
+		if child == nil || child.IsInUse() == false {
+			break
+		}
+
+		node = child
+		qids = append(qids, session.qid(pathParts, node))
+	}
+
+	if len(qids) == 0 && count > 0 {
+		return ninePEnoent
+	}
+
+	// The new fid is only established if every element was walked.
+	if len(qids) == int(count) {
+		session.fids[newFid] = &ninePFid{
+			pathParts: pathParts,
+			node:      node,
+		}
+	}
+
+	npe.uint16(uint16(len(qids)))
+	for _, qid := range qids {
+		npe.qid(qid)
+	}
+
+	return nil
+}
+
+// readdir handles Treaddir. The offset of each entry is its index plus one,
+// starting with "." and "..".
+func (session *ninePSession) readdir(npd *ninePDecoder, npe *ninePEncoder) (err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	f, err := session.fid(npd.uint32())
+	if err != nil {
+		return err
+	}
+
+	offset := npd.uint64()
+	count := npd.uint32()
+
+	if f.isOpen == false {
+		return ninePEbadf
+	} else if f.node.IsDirectory() == false {
+		return ninePEnotdir
+	}
+
+	if maximum := session.messageSize - ninePHeaderSize - 4; count > maximum {
+		count = maximum
+	}
+
+	type ninePDirent struct {
+		name string
+		qid  ninePQid
+	}
+
+	entries := []ninePDirent{
+		{".", session.qid(f.pathParts, f.node)},
+	}
+
+	parentPathParts := f.pathParts
+	if len(parentPathParts) > 0 {
+		parentPathParts = parentPathParts[:len(parentPathParts)-1]
+	}
+
+	// The parent is always a directory, like this one.
+	entries = append(entries, ninePDirent{"..", session.qid(parentPathParts, f.node)})
+
+	names := append(append([]string{}, f.node.ChildFolders()...), f.node.ChildFiles()...)
+
+	for _, name := range names {
+		child := f.node.GetChild(name)
+		if child.IsInUse() == false {
+			continue
+		}
+
+		childPathParts := append(append([]string{}, f.pathParts...), name)
+		entries = append(entries, ninePDirent{name, session.qid(childPathParts, child)})
+	}
+
+	lengthOffset := len(npe.data)
+	npe.uint32(0)
+
+	dataStart := len(npe.data)
+
+	for i := offset; i < uint64(len(entries)); i++ {
+		entry := entries[i]
+
+		if len(npe.data)-dataStart+ninePQidSize+8+1+2+len(entry.name) > int(count) {
+			break
+		}
+
+		npe.qid(entry.qid)
+		npe.uint64(i + 1)
+
+		if entry.qid.qidType == ninePQidTypeDir {
+			npe.uint8(ninePDirentTypeDir)
+		} else {
+			npe.uint8(ninePDirentTypeRegular)
+		}
+
+		npe.string(entry.name)
+	}
+
+	binary.LittleEndian.PutUint32(npe.data[lengthOffset:], uint32(len(npe.data)-dataStart))
+
+	return nil
+}
+
+// getattr encodes the Rgetattr body for the given file.
+func (session *ninePSession) getattr(f *ninePFid, npe *ninePEncoder) {
+	fi := f.node.Stat()
+
+	mode := uint32(fi.Mode().Perm())
+	nlink := uint64(1)
+
+	if f.node.IsDirectory() == true {
+		mode |= ninePModeDir
+		nlink = 2
+	} else {
+		mode |= ninePModeRegular
+	}
+
+	clusterSize := session.nps.tree.er.ActiveBootSectorHeader().ClusterSize()
+
+	npe.uint64(ninePGetattrBasic)
+	npe.qid(session.qid(f.pathParts, f.node))
+	npe.uint32(mode)
+	npe.uint32(0)
+	npe.uint32(0)
+	npe.uint64(nlink)
+	npe.uint64(0)
+	npe.uint64(uint64(fi.Size()))
+	npe.uint64(uint64(clusterSize))
+	npe.uint64((f.node.AllocatedSize() + 511) / 512)
+
+	var atimeSec, atimeNsec, mtimeSec, mtimeNsec, ctimeSec, ctimeNsec uint64
+
+	// The root has no timestamps.
+	if fde := f.node.FileDirectoryEntry(); fde != nil {
+		atime := fde.LastAccessedTimestamp()
+		atimeSec, atimeNsec = uint64(atime.Unix()), uint64(atime.Nanosecond())
+
+		mtime := fde.LastModifiedTimestamp()
+		mtimeSec, mtimeNsec = uint64(mtime.Unix()), uint64(mtime.Nanosecond())
+
+		ctime := fde.CreateTimestamp()
+		ctimeSec, ctimeNsec = uint64(ctime.Unix()), uint64(ctime.Nanosecond())
+	}
+
+	npe.uint64(atimeSec)
+	npe.uint64(atimeNsec)
+	npe.uint64(mtimeSec)
+	npe.uint64(mtimeNsec)
+
+	// exFAT has no change-time, so the creation-time is reported for both.
+	npe.uint64(ctimeSec)
+	npe.uint64(ctimeNsec)
+	npe.uint64(ctimeSec)
+	npe.uint64(ctimeNsec)
+
+	npe.uint64(0)
+	npe.uint64(0)
+}
@@ -0,0 +1,340 @@
+package exfat
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"testing"
+
+	"encoding/binary"
+
+	"github.com/dsoprea/go-logging"
+)
+
+// testNinePClient is the client side of a 9P session.
+type testNinePClient struct {
+	conn net.Conn
+	tag  uint16
+}
+
+// call sends one request and returns the type and body of the reply.
+func (tnpc *testNinePClient) call(messageType uint8, body *ninePEncoder) (replyType uint8, reply *ninePDecoder) {
+	message := &ninePEncoder{
+		data: make([]byte, ninePHeaderSize),
+	}
+
+	message.data = append(message.data, body.data...)
+
+	tnpc.tag++
+
+	binary.LittleEndian.PutUint32(message.data[0:4], uint32(len(message.data)))
+	message.data[4] = messageType
+	binary.LittleEndian.PutUint16(message.data[5:7], tnpc.tag)
+
+	_, err := tnpc.conn.Write(message.data)
+	log.PanicIf(err)
+
+	header := make([]byte, ninePHeaderSize)
+
+	_, err = io.ReadFull(tnpc.conn, header)
+	log.PanicIf(err)
+
+	if binary.LittleEndian.Uint16(header[5:7]) != tnpc.tag {
+		log.Panicf("reply tag not correct")
+	}
+
+	data := make([]byte, binary.LittleEndian.Uint32(header[0:4])-ninePHeaderSize)
+
+	_, err = io.ReadFull(tnpc.conn, data)
+	log.PanicIf(err)
+
+	return header[4], &ninePDecoder{data: data}
+}
+
+// callError sends one request that is expected to fail and returns the
+// error-number.
+func (tnpc *testNinePClient) callError(messageType uint8, body *ninePEncoder) ninePErrno {
+	replyType, reply := tnpc.call(messageType, body)
+	if replyType != ninePTlerror+1 {
+		log.Panicf("expected an error: (%d)", replyType)
+	}
+
+	return ninePErrno(reply.uint32())
+}
+
+// walk walks from the root (fid 0) to the given path, as the given fid.
+func (tnpc *testNinePClient) walk(fid uint32, names ...string) (replyType uint8, reply *ninePDecoder) {
+	npe := new(ninePEncoder)
+	npe.uint32(0)
+	npe.uint32(fid)
+	npe.uint16(uint16(len(names)))
+
+	for _, name := range names {
+		npe.string(name)
+	}
+
+	return tnpc.call(ninePTwalk, npe)
+}
+
+// open opens the given fid for reading.
+func (tnpc *testNinePClient) open(fid uint32) {
+	npe := new(ninePEncoder)
+	npe.uint32(fid)
+	npe.uint32(0)
+
+	replyType, _ := tnpc.call(ninePTlopen, npe)
+	if replyType != ninePTlopen+1 {
+		log.Panicf("open failed")
+	}
+}
+
+// getTestNinePSession serves the test image on one end of a pipe and
+// negotiates the version and attaches the root as fid 0 on the other.
+func getTestNinePSession() (tnpc *testNinePClient, closer func()) {
+	tree, treeCloser := getTestTree()
+
+	nps := NewNinePServer(tree)
+
+	serverConn, clientConn := net.Pipe()
+
+	go func() {
+		nps.ServeConn(serverConn)
+		serverConn.Close()
+	}()
+
+	tnpc = &testNinePClient{
+		conn: clientConn,
+	}
+
+	npe := new(ninePEncoder)
+	npe.uint32(8192)
+	npe.string(ninePVersion)
+
+	replyType, reply := tnpc.call(ninePTversion, npe)
+	if replyType != ninePTversion+1 {
+		log.Panicf("version failed")
+	} else if reply.uint32() != 8192 || reply.string() != ninePVersion {
+		log.Panicf("version not negotiated")
+	}
+
+	npe = new(ninePEncoder)
+	npe.uint32(0)
+	npe.uint32(0xffffffff)
+	npe.string("user")
+	npe.string("")
+	npe.uint32(0)
+
+	replyType, reply = tnpc.call(ninePTattach, npe)
+	if replyType != ninePTattach+1 {
+		log.Panicf("attach failed")
+	} else if reply.next(1)[0] != ninePQidTypeDir {
+		log.Panicf("root is not a directory")
+	}
+
+	closer = func() {
+		clientConn.Close()
+		treeCloser()
+	}
+
+	return tnpc, closer
+}
+
+func TestNinePServer_ServeConn__Readdir(t *testing.T) {
+	tnpc, closer := getTestNinePSession()
+
+	defer closer()
+
+	replyType, reply := tnpc.walk(1, "testdirectory2")
+	if replyType != ninePTwalk+1 || reply.uint16() != 1 {
+		t.Fatalf("Walk failed.")
+	}
+
+	tnpc.open(1)
+
+	npe := new(ninePEncoder)
+	npe.uint32(1)
+	npe.uint64(0)
+	npe.uint32(4096)
+
+	replyType, reply = tnpc.call(ninePTreaddir, npe)
+	if replyType != ninePTreaddir+1 {
+		t.Fatalf("Readdir failed.")
+	}
+
+	entries := &ninePDecoder{data: reply.next(int(reply.uint32()))}
+
+	names := make([]string, 0)
+	lastOffset := uint64(0)
+	for len(entries.data) > 0 {
+		entries.next(ninePQidSize)
+		lastOffset = entries.uint64()
+		entries.next(1)
+
+		names = append(names, entries.string())
+	}
+
+	expected := []string{".", "..", "00c57ab0-cec3-11e9-b750-bbed8d2244c8", "ff7b94be-cec2-11e9-b7b1-6b2e61bd775c"}
+
+	if len(names) != len(expected) {
+		t.Fatalf("Entries not correct: %v", names)
+	}
+
+	for i, name := range names {
+		if name != expected[i] {
+			t.Fatalf("Entries not correct: %v", names)
+		}
+	}
+
+	// Continuing from the last offset returns nothing more.
+
+	npe = new(ninePEncoder)
+	npe.uint32(1)
+	npe.uint64(lastOffset)
+	npe.uint32(4096)
+
+	_, reply = tnpc.call(ninePTreaddir, npe)
+	if reply.uint32() != 0 {
+		t.Fatalf("Expected no more entries.")
+	}
+}
+
+func TestNinePServer_ServeConn__Read(t *testing.T) {
+	tnpc, closer := getTestNinePSession()
+
+	defer closer()
+
+	filename := "2-delahaye-type-165-cabriolet-dsc_8025.jpg"
+
+	replyType, _ := tnpc.walk(1, "testdirectory", "..", filename)
+	if replyType != ninePTwalk+1 {
+		t.Fatalf("Walk failed.")
+	}
+
+	npe := new(ninePEncoder)
+	npe.uint32(1)
+	npe.uint64(ninePGetattrBasic)
+
+	replyType, reply := tnpc.call(ninePTgetattr, npe)
+	if replyType != ninePTgetattr+1 {
+		t.Fatalf("Getattr failed.")
+	}
+
+	reply.uint64()
+	reply.next(ninePQidSize)
+
+	mode := reply.uint32()
+	reply.next(4 + 4 + 8 + 8)
+	size := reply.uint64()
+
+	if mode&ninePModeRegular == 0 {
+		t.Fatalf("Mode not correct: (0%o)", mode)
+	} else if size != 313299 {
+		t.Fatalf("Size not correct: (%d)", size)
+	}
+
+	tnpc.open(1)
+
+	// Every read is bounded by the message-size.
+
+	actual := make([]byte, 0)
+	for {
+		npe := new(ninePEncoder)
+		npe.uint32(1)
+		npe.uint64(uint64(len(actual)))
+		npe.uint32(65536)
+
+		replyType, reply := tnpc.call(ninePTread, npe)
+		if replyType != ninePTread+1 {
+			t.Fatalf("Read failed.")
+		}
+
+		count := reply.uint32()
+		if count == 0 {
+			break
+		} else if count > 8192 {
+			t.Fatalf("Read exceeds the message-size: (%d)", count)
+		}
+
+		actual = append(actual, reply.next(int(count))...)
+	}
+
+	f, er := getTestFileAndParser()
+
+	defer f.Close()
+
+	err := er.Parse()
+	log.PanicIf(err)
+
+	expected, err := er.ReadFile(filename)
+	log.PanicIf(err)
+
+	if bytes.Equal(actual, expected) != true {
+		t.Fatalf("Data not correct.")
+	}
+}
+
+func TestNinePServer_ServeConn__Errors(t *testing.T) {
+	tnpc, closer := getTestNinePSession()
+
+	defer closer()
+
+	replyType, reply := tnpc.walk(1, "not-there")
+	if replyType != ninePTlerror+1 || ninePErrno(reply.uint32()) != ninePEnoent {
+		t.Fatalf("Expected ENOENT.")
+	}
+
+	// A partial walk returns the qids that were found but doesn't establish
+	// the new fid.
+
+	replyType, reply = tnpc.walk(1, "testdirectory", "not-there")
+	if replyType != ninePTwalk+1 || reply.uint16() != 1 {
+		t.Fatalf("Expected a partial walk.")
+	}
+
+	npe := new(ninePEncoder)
+	npe.uint32(1)
+	npe.uint64(ninePGetattrBasic)
+
+	if errno := tnpc.callError(ninePTgetattr, npe); errno != ninePEbadf {
+		t.Fatalf("Expected EBADF: (%d)", errno)
+	}
+
+	replyType, _ = tnpc.walk(1, "79c6d31a-cca1-11e9-8325-9746d045e868")
+	if replyType != ninePTwalk+1 {
+		t.Fatalf("Walk failed.")
+	}
+
+	npe = new(ninePEncoder)
+	npe.uint32(1)
+	npe.uint32(2)
+
+	if errno := tnpc.callError(ninePTlopen, npe); errno != ninePErofs {
+		t.Fatalf("Expected EROFS for open-for-writing: (%d)", errno)
+	}
+
+	npe = new(ninePEncoder)
+	npe.uint32(0)
+	npe.string("new-directory")
+	npe.uint32(0755)
+	npe.uint32(0)
+
+	if errno := tnpc.callError(ninePTmkdir, npe); errno != ninePErofs {
+		t.Fatalf("Expected EROFS for mkdir: (%d)", errno)
+	}
+
+	npe = new(ninePEncoder)
+	npe.uint32(1)
+
+	if errno := tnpc.callError(ninePTremove, npe); errno != ninePErofs {
+		t.Fatalf("Expected EROFS for remove: (%d)", errno)
+	}
+
+	// The remove clunked the fid.
+
+	npe = new(ninePEncoder)
+	npe.uint32(1)
+
+	if errno := tnpc.callError(ninePTclunk, npe); errno != ninePEbadf {
+		t.Fatalf("Expected EBADF after remove: (%d)", errno)
+	}
+}
@@ -0,0 +1,211 @@
+// This package decodes the OEM parameters of the boot region (Section 3.3).
+
+package exfat
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+
+	"encoding/binary"
+	"encoding/json"
+
+	"github.com/dsoprea/go-logging"
+)
+
+var (
+	// FlashParametersGuid identifies the flash-parameters OEM parameter
+	// (Section 3.3.3), as stored (in the mixed-endian form that FormatGuid()
+	// expects). The canonical form is 0A0C7E46-3399-4021-90C8-FA6D389C4BA2.
+	FlashParametersGuid = [16]byte{
+		0x46, 0x7e, 0x0c, 0x0a,
+		0x99, 0x33,
+		0x21, 0x40,
+		0x90, 0xc8,
+		0xfa, 0x6d, 0x38, 0x9c, 0x4b, 0xa2,
+	}
+)
+
+// OemParameter is one OEM parameter. The first sixteen bytes are the GUID
+// that identifies the parameter and the rest are defined by that GUID.
+type OemParameter struct {
+	Parameter [48]byte
+}
+
+// Guid returns the GUID that identifies the parameter.
+func (op OemParameter) Guid() (guid [16]byte) {
+	copy(guid[:], op.Parameter[:16])
+	return guid
+}
+
+// CustomDefined returns the thirty-two bytes that follow the GUID.
+func (op OemParameter) CustomDefined() []byte {
+	return op.Parameter[16:]
+}
+
+// IsNull indicates that the parameter is unused. Section 3.3.2 defines the
+// null parameter by its all-zero GUID.
+func (op OemParameter) IsNull() bool {
+	return op.Guid() == [16]byte{}
+}
+
+// IsFlashParameters indicates that the parameter describes the flash media.
+func (op OemParameter) IsFlashParameters() bool {
+	return op.Guid() == FlashParametersGuid
+}
+
+// FlashParameters decodes the parameter as flash parameters. `ok` is false if
+// it has a different GUID.
+func (op OemParameter) FlashParameters() (fp FlashParameters, ok bool) {
+	if op.IsFlashParameters() == false {
+		return fp, false
+	}
+
+	// The data is fixed-size and in memory, so this can't fail.
+	err := binary.Read(bytes.NewReader(op.CustomDefined()), defaultEncoding, &fp)
+	log.PanicIf(err)
+
+	return fp, true
+}
+
+// String returns a descriptive string.
+func (op OemParameter) String() string {
+	return fmt.Sprintf("OemParameter<GUID=[%s]>", FormatGuid(op.Guid()))
+}
+
+// FlashParameters describes the flash media that the volume is on (Section
+// 3.3.3). The sizes are in bytes and the times are in nanoseconds. A zero
+// means that the value is not known.
+type FlashParameters struct {
+	EraseBlockSize   uint32  `json:"erase_block_size" yaml:"erase_block_size"`
+	PageSize         uint32  `json:"page_size" yaml:"page_size"`
+	SpareSectors     uint32  `json:"spare_sectors" yaml:"spare_sectors"`
+	RandomAccessTime uint32  `json:"random_access_time" yaml:"random_access_time"`
+	ProgrammingTime  uint32  `json:"programming_time" yaml:"programming_time"`
+	ReadCycle        uint32  `json:"read_cycle" yaml:"read_cycle"`
+	WriteCycle       uint32  `json:"write_cycle" yaml:"write_cycle"`
+	Reserved         [4]byte `json:"-" yaml:"-"`
+}
+
+// String returns a descriptive string.
+func (fp FlashParameters) String() string {
+	return fmt.Sprintf("FlashParameters<ERASE-BLOCK-SIZE=(%d) PAGE-SIZE=(%d) SPARE-SECTORS=(%d)>", fp.EraseBlockSize, fp.PageSize, fp.SpareSectors)
+}
+
+// DumpBareIndented prints the parameters without a header and with the given
+// indent.
+func (fp FlashParameters) DumpBareIndented(indent string) {
+	fp.DumpBareIndentedTo(os.Stdout, indent)
+}
+
+// DumpBareIndentedTo is the same as DumpBareIndented() but writes to the given
+// writer.
+func (fp FlashParameters) DumpBareIndentedTo(w io.Writer, indent string) {
+	fmt.Fprintf(w, "%sEraseBlockSize: (%d)\n", indent, fp.EraseBlockSize)
+	fmt.Fprintf(w, "%sPageSize: (%d)\n", indent, fp.PageSize)
+	fmt.Fprintf(w, "%sSpareSectors: (%d)\n", indent, fp.SpareSectors)
+	fmt.Fprintf(w, "%sRandomAccessTime: (%d)\n", indent, fp.RandomAccessTime)
+	fmt.Fprintf(w, "%sProgrammingTime: (%d)\n", indent, fp.ProgrammingTime)
+	fmt.Fprintf(w, "%sReadCycle: (%d)\n", indent, fp.ReadCycle)
+	fmt.Fprintf(w, "%sWriteCycle: (%d)\n", indent, fp.WriteCycle)
+}
+
+// OemParameters is the set of OEM parameters.
+type OemParameters struct {
+	Parameters [10]OemParameter
+}
+
+// FlashParameters returns the first flash-parameters parameter. `ok` is false
+// if there isn't one.
+func (ops OemParameters) FlashParameters() (fp FlashParameters, ok bool) {
+	for _, op := range ops.Parameters {
+		if fp, ok := op.FlashParameters(); ok == true {
+			return fp, true
+		}
+	}
+
+	return fp, false
+}
+
+// OemParameterInfo is the decoded form of one (non-null) OEM parameter,
+// suitable for serialization.
+type OemParameterInfo struct {
+	Index           int              `json:"index" yaml:"index"`
+	Guid            string           `json:"guid" yaml:"guid"`
+	FlashParameters *FlashParameters `json:"flash_parameters,omitempty" yaml:"flash_parameters,omitempty"`
+	CustomDefined   string           `json:"custom_defined,omitempty" yaml:"custom_defined,omitempty"`
+}
+
+// Info returns the parameters that are in use. Flash parameters are decoded
+// and the data of any others is returned as hex.
+func (ops OemParameters) Info() []OemParameterInfo {
+	infos := make([]OemParameterInfo, 0)
+
+	for i, op := range ops.Parameters {
+		if op.IsNull() == true {
+			continue
+		}
+
+		opi := OemParameterInfo{
+			Index: i,
+			Guid:  FormatGuid(op.Guid()),
+		}
+
+		if fp, ok := op.FlashParameters(); ok == true {
+			opi.FlashParameters = &fp
+		} else {
+			opi.CustomDefined = fmt.Sprintf("%x", op.CustomDefined())
+		}
+
+		infos = append(infos, opi)
+	}
+
+	return infos
+}
+
+// MarshalJSON returns the parameters that are in use as JSON.
+func (ops OemParameters) MarshalJSON() ([]byte, error) {
+	return json.Marshal(ops.Info())
+}
+
+// Dump prints the parameters that are in use.
+func (ops OemParameters) Dump() {
+	ops.DumpTo(os.Stdout)
+}
+
+// DumpTo is the same as Dump() but writes to the given writer.
+func (ops OemParameters) DumpTo(w io.Writer) {
+	fmt.Fprintf(w, "OEM Parameters\n")
+	fmt.Fprintf(w, "==============\n")
+	fmt.Fprintf(w, "\n")
+
+	count := 0
+	for i, op := range ops.Parameters {
+		if op.IsNull() == true {
+			continue
+		}
+
+		fmt.Fprintf(w, "Parameter (%d): [%s]\n", i, FormatGuid(op.Guid()))
+
+		if fp, ok := op.FlashParameters(); ok == true {
+			fmt.Fprintf(w, "  (Flash Parameters)\n")
+			fp.DumpBareIndentedTo(w, "  ")
+		} else {
+			fmt.Fprintf(w, "  CustomDefined: [%x]\n", op.CustomDefined())
+		}
+
+		count++
+	}
+
+	if count == 0 {
+		fmt.Fprintf(w, "(none)\n")
+	}
+
+	fmt.Fprintf(w, "\n")
+}
+
+// OemParameters returns the OEM parameters of the active boot region.
+func (er *ExfatReader) OemParameters() OemParameters {
+	return er.bootRegion.oemParameters
+}
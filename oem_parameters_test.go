@@ -0,0 +1,145 @@
+package exfat
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"encoding/binary"
+	"encoding/json"
+
+	"github.com/dsoprea/go-logging"
+)
+
+func getTestFlashParameter() (op OemParameter) {
+	copy(op.Parameter[:16], FlashParametersGuid[:])
+
+	values := []uint32{4 * 1024 * 1024, 16384, 8, 100, 200, 300, 400}
+	for i, value := range values {
+		binary.LittleEndian.PutUint32(op.Parameter[16+i*4:], value)
+	}
+
+	return op
+}
+
+func TestFlashParametersGuid(t *testing.T) {
+	if FormatGuid(FlashParametersGuid) != "0a0c7e46-3399-4021-90c8-fa6d389c4ba2" {
+		t.Fatalf("GUID not correct: [%s]", FormatGuid(FlashParametersGuid))
+	}
+}
+
+func TestOemParameter_FlashParameters(t *testing.T) {
+	op := getTestFlashParameter()
+
+	if op.IsNull() != false {
+		t.Fatalf("Parameter should not be null.")
+	} else if op.IsFlashParameters() != true {
+		t.Fatalf("Parameter should be flash parameters.")
+	}
+
+	fp, ok := op.FlashParameters()
+	if ok != true {
+		t.Fatalf("Flash parameters not decoded.")
+	}
+
+	expected := FlashParameters{
+		EraseBlockSize:   4 * 1024 * 1024,
+		PageSize:         16384,
+		SpareSectors:     8,
+		RandomAccessTime: 100,
+		ProgrammingTime:  200,
+		ReadCycle:        300,
+		WriteCycle:       400,
+	}
+
+	if fp != expected {
+		t.Fatalf("Flash parameters not correct: %v", fp)
+	}
+}
+
+func TestOemParameter_FlashParameters__Other(t *testing.T) {
+	op := OemParameter{}
+	op.Parameter[0] = 1
+
+	if op.IsNull() != false {
+		t.Fatalf("Parameter should not be null.")
+	} else if _, ok := op.FlashParameters(); ok != false {
+		t.Fatalf("Parameter should not be flash parameters.")
+	}
+}
+
+func TestOemParameters_Info(t *testing.T) {
+	ops := OemParameters{}
+	ops.Parameters[2] = getTestFlashParameter()
+	ops.Parameters[5].Parameter[0] = 0x11
+	ops.Parameters[5].Parameter[16] = 0x22
+
+	infos := ops.Info()
+	if len(infos) != 2 {
+		t.Fatalf("Expected two parameters: (%d)", len(infos))
+	} else if infos[0].Index != 2 || infos[0].FlashParameters == nil || infos[0].FlashParameters.PageSize != 16384 {
+		t.Fatalf("First parameter not correct: %v", infos[0])
+	} else if infos[1].Index != 5 || infos[1].FlashParameters != nil || strings.HasPrefix(infos[1].CustomDefined, "22") != true {
+		t.Fatalf("Second parameter not correct: %v", infos[1])
+	}
+
+	encoded, err := json.Marshal(ops)
+	log.PanicIf(err)
+
+	decoded := make([]map[string]interface{}, 0)
+
+	err = json.Unmarshal(encoded, &decoded)
+	log.PanicIf(err)
+
+	if decoded[0]["guid"].(string) != "0a0c7e46-3399-4021-90c8-fa6d389c4ba2" {
+		t.Fatalf("GUID not correct: %v", decoded[0])
+	} else if decoded[0]["flash_parameters"].(map[string]interface{})["erase_block_size"].(float64) != 4*1024*1024 {
+		t.Fatalf("Erase-block size not correct: %v", decoded[0])
+	}
+}
+
+func TestOemParameters_DumpTo(t *testing.T) {
+	ops := OemParameters{}
+
+	b := new(bytes.Buffer)
+	ops.DumpTo(b)
+
+	if strings.Contains(b.String(), "(none)\n") != true {
+		t.Fatalf("Dump not correct for no parameters: [%s]", b.String())
+	}
+
+	ops.Parameters[0] = getTestFlashParameter()
+
+	b = new(bytes.Buffer)
+	ops.DumpTo(b)
+
+	s := b.String()
+	if strings.Contains(s, "Parameter (0): [0a0c7e46-3399-4021-90c8-fa6d389c4ba2]\n") != true {
+		t.Fatalf("Dump does not include the GUID: [%s]", s)
+	} else if strings.Contains(s, "  EraseBlockSize: (4194304)\n") != true {
+		t.Fatalf("Dump does not include the erase-block size: [%s]", s)
+	}
+}
+
+func TestExfatReader_OemParameters(t *testing.T) {
+	image, _ := getTestImage()
+
+	// The OEM parameters are in the tenth sector of the main boot region.
+
+	op := getTestFlashParameter()
+	copy(image[9*512+48:], op.Parameter[:])
+
+	er := NewExfatReader(bytes.NewReader(image))
+
+	err := er.Parse()
+	log.PanicIf(err)
+
+	fp, ok := er.OemParameters().FlashParameters()
+	if ok != true {
+		t.Fatalf("Flash parameters not found.")
+	} else if fp.EraseBlockSize != 4*1024*1024 {
+		t.Fatalf("Erase-block size not correct: (%d)", fp.EraseBlockSize)
+	} else if er.OemParameters().Parameters[1].IsFlashParameters() != true {
+		t.Fatalf("Flash parameters not in the second slot.")
+	}
+}
@@ -0,0 +1,240 @@
+// This package supports finding and recovering clusters that are allocated but
+// not referenced by anything.
+
+package exfat
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/dsoprea/go-logging"
+)
+
+// OrphanRun is a series of adjacent clusters that the allocation bitmap marks
+// as allocated but that aren't part of any file, directory, or critical
+// structure. These are typically the remains of files whose directory entries
+// were lost (e.g. the card was pulled during a write).
+type OrphanRun struct {
+	FirstCluster uint32
+	ClusterCount uint32
+}
+
+// String returns a descriptive string.
+func (or OrphanRun) String() string {
+	return fmt.Sprintf("OrphanRun<FIRST-CLUSTER=(%d) CLUSTER-COUNT=(%d)>", or.FirstCluster, or.ClusterCount)
+}
+
+// clusterReachability records which clusters are referenced.
+type clusterReachability struct {
+	er          *ExfatReader
+	clusterSize uint64
+	reached     []bool
+}
+
+// markChain marks the clusters of the given chain. If `length` is zero, the
+// FAT chain is followed until it ends. Marking stops quietly at the first
+// cluster that isn't in the heap since this is used on damaged volumes.
+func (cr *clusterReachability) markChain(firstCluster uint32, length uint64, useFat bool) (err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	maximumCount := uint64(len(cr.reached))
+	if length > 0 {
+		maximumCount = (length + cr.clusterSize - 1) / cr.clusterSize
+	}
+
+	clusterNumber := firstCluster
+	for i := uint64(0); i < maximumCount; i++ {
+		if clusterNumber < 2 || uint64(clusterNumber-2) >= uint64(len(cr.reached)) {
+			break
+		}
+
+		cr.reached[clusterNumber-2] = true
+
+		nextClusterNumber, isLast, err := cr.er.nextClusterNumber(clusterNumber, useFat)
+		log.PanicIf(err)
+
+		if isLast == true {
+			break
+		}
+
+		clusterNumber = nextClusterNumber
+	}
+
+	return nil
+}
+
+// FindOrphanClusters returns the runs of clusters that are marked as allocated
+// but that can't be reached from the root directory, the allocation bitmaps,
+// the up-case table, or the data of any file or directory that is in use.
+// Every directory is loaded.
+func FindOrphanClusters(tree *Tree) (runs []OrphanRun, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	er := tree.er
+
+	ab, err := er.ReadAllocationBitmap()
+	log.PanicIf(err)
+
+	bsh := er.ActiveBootSectorHeader()
+
+	cr := &clusterReachability{
+		er:          er,
+		clusterSize: uint64(bsh.ClusterSize()),
+		reached:     make([]bool, bsh.ClusterCount),
+	}
+
+	// The root directory always has a FAT chain.
+
+	rootClusterNumber := er.FirstClusterOfRootDirectory()
+
+	err = cr.markChain(rootClusterNumber, 0, true)
+	log.PanicIf(err)
+
+	en := NewExfatNavigator(er, rootClusterNumber)
+
+	index, _, _, err := en.IndexDirectoryEntries()
+	log.PanicIf(err)
+
+	for _, ide := range index["AllocationBitmap"] {
+		abde := ide.PrimaryEntry.(*ExfatAllocationBitmapDirectoryEntry)
+
+		err := cr.markChain(abde.FirstCluster, abde.DataLength, true)
+		log.PanicIf(err)
+	}
+
+	for _, ide := range index["UpcaseTable"] {
+		utde := ide.PrimaryEntry.(*ExfatUpcaseTableDirectoryEntry)
+
+		err := cr.markChain(utde.FirstCluster, utde.DataLength, true)
+		log.PanicIf(err)
+	}
+
+	for _, ide := range index["TexFAT"] {
+		tfde := ide.PrimaryEntry.(*ExfatTexFATDirectoryEntry)
+
+		err := cr.markChain(tfde.FirstCluster, tfde.DataLength, true)
+		log.PanicIf(err)
+	}
+
+	cb := func(pathParts []string, node *TreeNode) (err error) {
+		defer func() {
+			if errRaw := recover(); errRaw != nil {
+				err = log.Wrap(errRaw.(error))
+			}
+		}()
+
+		if len(pathParts) == 0 {
+			return nil
+		} else if node.IsInUse() == false {
+			if node.IsDirectory() == true {
+				return filepath.SkipDir
+			}
+
+			return nil
+		}
+
+		sede := node.StreamDirectoryEntry()
+		if sede.DataLength > 0 {
+			useFat := sede.GeneralSecondaryFlags.NoFatChain() == false
+
+			err := cr.markChain(sede.FirstCluster, sede.DataLength, useFat)
+			log.PanicIf(err)
+		}
+
+		for _, de := range node.IndexedDirectoryEntry().SecondaryEntries {
+			vade, ok := de.(*ExfatVendorAllocationDirectoryEntry)
+			if ok == false || vade.DataLength == 0 {
+				continue
+			}
+
+			useFat := vade.GeneralSecondaryFlags.NoFatChain() == false
+
+			err := cr.markChain(vade.FirstCluster, vade.DataLength, useFat)
+			log.PanicIf(err)
+		}
+
+		return nil
+	}
+
+	err = tree.Walk(cb)
+	log.PanicIf(err)
+
+	runs = make([]OrphanRun, 0)
+
+	var current *OrphanRun
+	for i, isReached := range cr.reached {
+		clusterNumber := uint32(i) + 2
+
+		if isReached == true || ab.IsAllocated(clusterNumber) == false {
+			current = nil
+			continue
+		}
+
+		if current == nil {
+			runs = append(runs, OrphanRun{FirstCluster: clusterNumber})
+			current = &runs[len(runs)-1]
+		}
+
+		current.ClusterCount++
+	}
+
+	return runs, nil
+}
+
+// ExportOrphanRuns writes the data of each run to its own numbered file in
+// `destDir` ("orphan-0001-cluster-123.bin", where 123 is the first cluster)
+// and returns the paths of the files, in the same order as the runs.
+func ExportOrphanRuns(er *ExfatReader, runs []OrphanRun, destDir string) (filepaths []string, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	err = os.MkdirAll(destDir, 0755)
+	log.PanicIf(err)
+
+	bsh := er.ActiveBootSectorHeader()
+
+	clusterSize := int64(bsh.ClusterSize())
+	clusterHeapOffset := int64(bsh.ClusterHeapOffset) * int64(bsh.SectorSize())
+
+	data := make([]byte, clusterSize)
+	filepaths = make([]string, len(runs))
+
+	for i, run := range runs {
+		filename := fmt.Sprintf("orphan-%04d-cluster-%d.bin", i+1, run.FirstCluster)
+		filepaths[i] = filepath.Join(destDir, filename)
+
+		f, err := os.Create(filepaths[i])
+		log.PanicIf(err)
+
+		for j := uint32(0); j < run.ClusterCount; j++ {
+			offset := clusterHeapOffset + int64(run.FirstCluster-2+j)*clusterSize
+
+			err := er.readAt(data, offset)
+			if err == nil {
+				_, err = f.Write(data)
+			}
+
+			if err != nil {
+				f.Close()
+				log.Panic(err)
+			}
+		}
+
+		err = f.Close()
+		log.PanicIf(err)
+	}
+
+	return filepaths, nil
+}
@@ -0,0 +1,80 @@
+package exfat
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dsoprea/go-logging"
+)
+
+func TestFindOrphanClusters__None(t *testing.T) {
+	tree, closer := getTestTree()
+
+	defer closer()
+
+	runs, err := FindOrphanClusters(tree)
+	log.PanicIf(err)
+
+	if len(runs) != 0 {
+		t.Fatalf("Expected no orphans: %v", runs)
+	}
+}
+
+func TestFindOrphanClusters(t *testing.T) {
+	_, node, data, closer := getTestSparseNode()
+
+	defer closer()
+
+	firstCluster := node.StreamDirectoryEntry().FirstCluster
+	location := node.IndexedDirectoryEntry().Location
+
+	// Mark the file's entry-set as deleted without freeing its clusters.
+
+	image, _ := getTestImage()
+
+	image[location.Offset] &^= 0x80
+
+	er := NewExfatReader(bytes.NewReader(image))
+
+	err := er.Parse()
+	log.PanicIf(err)
+
+	tree := NewTree(er)
+
+	runs, err := FindOrphanClusters(tree)
+	log.PanicIf(err)
+
+	clusterSize := int(er.ActiveBootSectorHeader().ClusterSize())
+	clusterCount := (len(data) + clusterSize - 1) / clusterSize
+
+	if len(runs) != 1 {
+		t.Fatalf("Orphans not correct: %v", runs)
+	} else if runs[0].FirstCluster != firstCluster || runs[0].ClusterCount != uint32(clusterCount) {
+		t.Fatalf("Orphan run not correct: %s", runs[0])
+	}
+
+	destDir, err := ioutil.TempDir("", "exfat-orphans")
+	log.PanicIf(err)
+
+	defer os.RemoveAll(destDir)
+
+	filepaths, err := ExportOrphanRuns(er, runs, destDir)
+	log.PanicIf(err)
+
+	if len(filepaths) != 1 || filepath.Base(filepaths[0]) != fmt.Sprintf("orphan-0001-cluster-%d.bin", firstCluster) {
+		t.Fatalf("Exported files not correct: %v", filepaths)
+	}
+
+	recovered, err := ioutil.ReadFile(filepaths[0])
+	log.PanicIf(err)
+
+	if len(recovered) != clusterCount*clusterSize {
+		t.Fatalf("Recovered size not correct: (%d)", len(recovered))
+	} else if bytes.Equal(recovered[:len(data)], data) != true {
+		t.Fatalf("Recovered data not correct.")
+	}
+}
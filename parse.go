@@ -0,0 +1,140 @@
+// This package parses individual structures from raw bytes, without an image
+// or an ExfatReader, for callers that are given untrusted data.
+
+package exfat
+
+import (
+	"errors"
+	"io"
+
+	"github.com/dsoprea/go-logging"
+	"github.com/go-restruct/restruct"
+)
+
+var (
+	// ErrShortData is matched (with errors.Is) by the error returned when the
+	// data given to ParseBootSector(), NewBootSectorHeaderFromReader(), or
+	// ParseDirectoryEntrySet() is too short for the structure.
+	ErrShortData = errors.New("data too short for structure")
+)
+
+// ParseBootSector parses and validates the boot-sector header at the start of
+// `data`, which must have at least 512 bytes; anything after them is ignored.
+// Unlike Parse(), nothing other than the boot sector is checked (e.g. the
+// checksum or the FAT), so the volume that it describes can still fail to
+// parse. Any failure is a CorruptionError.
+func ParseBootSector(data []byte) (bsh BootSectorHeader, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	if len(data) < bootSectorHeaderSize {
+		log.Panic(newCorruptionError("boot sector", ErrShortData))
+	}
+
+	err = restruct.Unpack(data[:bootSectorHeaderSize], defaultEncoding, &bsh)
+	if err != nil {
+		log.Panic(newCorruptionError("boot sector", err))
+	}
+
+	err = validateBootSectorHeader(bsh)
+	if err != nil {
+		log.Panic(newCorruptionError("boot sector", err))
+	}
+
+	return bsh, nil
+}
+
+// NewBootSectorHeaderFromReader reads the first 512 bytes of a boot sector
+// from `r` and parses and validates them with ParseBootSector(). Nothing past
+// those bytes is read, so, if the sectors are larger, the reader is left in
+// the middle of the sector. A reader that ends early returns an error that
+// matches ErrShortData; other read failures are returned as they are.
+func NewBootSectorHeaderFromReader(r io.Reader) (bsh BootSectorHeader, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	raw := make([]byte, bootSectorHeaderSize)
+
+	_, err = io.ReadFull(r, raw)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		log.Panic(newCorruptionError("boot sector", ErrShortData))
+	} else if err != nil {
+		log.Panic(err)
+	}
+
+	bsh, err = ParseBootSector(raw)
+	log.PanicIf(err)
+
+	return bsh, nil
+}
+
+// ParseDirectoryEntrySet parses a single entry-set from `data`, which must be
+// exactly the raw entries of the set: a primary entry followed by as many
+// secondary entries as it declares. Since the entries weren't read from an
+// image, their locations only have the offset and number of each entry within
+// `data`. The checksum isn't checked (see EntrySet.IsChecksumValid()). Any
+// failure is a CorruptionError.
+func ParseDirectoryEntrySet(data []byte) (es *EntrySet, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	if len(data) < directoryEntryBytesCount {
+		log.Panic(newCorruptionError("entry-set", ErrShortData))
+	} else if len(data)%directoryEntryBytesCount != 0 {
+		log.Panic(newCorruptionError("entry-set", log.Errorf("data is not a multiple of the entry size: (%d)", len(data))))
+	}
+
+	esa := new(entrySetAssembler)
+
+	entryCount := len(data) / directoryEntryBytesCount
+	for i := 0; i < entryCount; i++ {
+		raw := data[i*directoryEntryBytesCount : (i+1)*directoryEntryBytesCount]
+		entryType := EntryType(raw[0])
+
+		var cause error
+		if entryType.IsEndOfDirectory() == true {
+			cause = log.Errorf("entry marks the end of the directory")
+		} else if i == 0 && entryType.IsPrimary() == false {
+			cause = log.Errorf("set does not start with a primary entry: %s", entryType)
+		} else if i > 0 && entryType.IsPrimary() == true {
+			cause = log.Errorf("primary entry found before the set is complete: %s", entryType)
+		}
+
+		var de DirectoryEntry
+		if cause == nil {
+			de, cause = esa.parse(entryType, raw)
+		}
+
+		if cause != nil {
+			ce := newCorruptionError("directory entry", cause)
+			ce.EntryIndex = i
+
+			log.Panic(ce)
+		}
+
+		location := EntryLocation{
+			Offset:      int64(i * directoryEntryBytesCount),
+			EntryNumber: i,
+		}
+
+		es = esa.add(entryType, de, location, raw)
+		if es != nil && i < entryCount-1 {
+			log.Panic(newCorruptionError("entry-set", log.Errorf("set is complete after (%d) entries but (%d) were given", i+1, entryCount)))
+		}
+	}
+
+	if es == nil {
+		log.Panic(newCorruptionError("entry-set", ErrShortData))
+	}
+
+	return es, nil
+}
@@ -0,0 +1,26 @@
+package exfat
+
+import (
+	"testing"
+)
+
+func FuzzParseBootSector(f *testing.F) {
+	data := getTestBootSector()
+
+	f.Add(data)
+	f.Add(data[:bootSectorHeaderSize-1])
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		fuzzBootSector(data)
+	})
+}
+
+func FuzzParseDirectoryEntrySet(f *testing.F) {
+	for _, es := range getRootEntrySets() {
+		f.Add(es.Raw)
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		fuzzDirectoryEntrySet(data)
+	})
+}
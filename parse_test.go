@@ -0,0 +1,261 @@
+package exfat
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/dsoprea/go-logging"
+)
+
+func getTestBootSector() []byte {
+	image, _ := getTestImage()
+
+	return image[:bootSectorHeaderSize]
+}
+
+func getTestFileEntrySet() *EntrySet {
+	for _, es := range getRootEntrySets() {
+		if strings.Contains(es.Filename(), "delahaye") == true {
+			return es
+		}
+	}
+
+	log.Panicf("file entry-set not found")
+	return nil
+}
+
+func TestParseBootSector(t *testing.T) {
+	f, er := getTestFileAndParser()
+
+	defer f.Close()
+
+	err := er.Parse()
+	log.PanicIf(err)
+
+	bsh, err := ParseBootSector(getTestBootSector())
+	log.PanicIf(err)
+
+	if reflect.DeepEqual(bsh, er.ActiveBootSectorHeader()) != true {
+		t.Fatalf("Boot-sector header not correct: %s", bsh)
+	}
+}
+
+func TestParseBootSector__TrailingData(t *testing.T) {
+	data := append(getTestBootSector(), make([]byte, 100)...)
+
+	bsh, err := ParseBootSector(data)
+	log.PanicIf(err)
+
+	if bsh.SectorSize() != 512 {
+		t.Fatalf("Sector size not correct: (%d)", bsh.SectorSize())
+	}
+}
+
+func TestParseBootSector__Short(t *testing.T) {
+	data := getTestBootSector()
+
+	for _, n := range []int{0, 1, 11, 511} {
+		_, err := ParseBootSector(data[:n])
+		if err == nil {
+			t.Fatalf("Expected error for (%d) bytes.", n)
+		} else if errors.Is(err, ErrShortData) != true {
+			t.Fatalf("Expected short-data error for (%d) bytes: [%v]", n, err)
+		} else if errors.Is(err, ErrCorrupt) != true {
+			t.Fatalf("Expected corruption error for (%d) bytes: [%v]", n, err)
+		}
+	}
+}
+
+func TestParseBootSector__Invalid(t *testing.T) {
+	data := getTestBootSector()
+
+	// Sectors can't be larger than 4096 bytes.
+	data[108] = 13
+
+	_, err := ParseBootSector(data)
+	if err == nil {
+		t.Fatalf("Expected error for invalid header.")
+	} else if errors.Is(err, ErrCorrupt) != true {
+		t.Fatalf("Expected corruption error: [%v]", err)
+	} else if errors.Is(err, ErrShortData) == true {
+		t.Fatalf("Did not expect short-data error: [%v]", err)
+	}
+}
+
+func TestNewBootSectorHeaderFromReader(t *testing.T) {
+	f, er := getTestFileAndParser()
+
+	defer f.Close()
+
+	bsh, err := NewBootSectorHeaderFromReader(f)
+	log.PanicIf(err)
+
+	position, err := f.Seek(0, io.SeekCurrent)
+	log.PanicIf(err)
+
+	if position != bootSectorHeaderSize {
+		t.Fatalf("Read too much: (%d)", position)
+	}
+
+	_, err = f.Seek(0, io.SeekStart)
+	log.PanicIf(err)
+
+	err = er.Parse()
+	log.PanicIf(err)
+
+	if reflect.DeepEqual(bsh, er.ActiveBootSectorHeader()) != true {
+		t.Fatalf("Boot-sector header not correct: %s", bsh)
+	}
+}
+
+func TestNewBootSectorHeaderFromReader__Short(t *testing.T) {
+	data := getTestBootSector()
+
+	for _, n := range []int{0, 100, 511} {
+		_, err := NewBootSectorHeaderFromReader(bytes.NewReader(data[:n]))
+		if err == nil {
+			t.Fatalf("Expected error for (%d) bytes.", n)
+		} else if errors.Is(err, ErrShortData) != true {
+			t.Fatalf("Expected short-data error for (%d) bytes: [%v]", n, err)
+		}
+	}
+}
+
+type testFailingReader struct {
+	err error
+}
+
+func (tfr testFailingReader) Read(p []byte) (n int, err error) {
+	return 0, tfr.err
+}
+
+func TestNewBootSectorHeaderFromReader__ReadError(t *testing.T) {
+	readErr := errors.New("read failed")
+	r := io.MultiReader(bytes.NewReader(getTestBootSector()[:100]), testFailingReader{err: readErr})
+
+	_, err := NewBootSectorHeaderFromReader(r)
+	if err == nil {
+		t.Fatalf("Expected error.")
+	} else if errors.Is(err, readErr) != true {
+		t.Fatalf("Expected read error: [%v]", err)
+	} else if errors.Is(err, ErrCorrupt) == true {
+		t.Fatalf("Did not expect corruption error: [%v]", err)
+	}
+}
+
+func TestParseDirectoryEntrySet(t *testing.T) {
+	original := getTestFileEntrySet()
+
+	es, err := ParseDirectoryEntrySet(original.Raw)
+	log.PanicIf(err)
+
+	if es.Filename() != original.Filename() {
+		t.Fatalf("Filename not correct: [%s]", es.Filename())
+	} else if es.EntryCount() != original.EntryCount() {
+		t.Fatalf("Entry count not correct: (%d)", es.EntryCount())
+	} else if es.IsChecksumValid() != true {
+		t.Fatalf("Checksum not valid.")
+	}
+
+	for i, location := range es.Locations {
+		if location.EntryNumber != i || location.Offset != int64(i*directoryEntryBytesCount) {
+			t.Fatalf("Location (%d) not correct: %s", i, location)
+		}
+	}
+
+	// The set should have its own copy of the data.
+	original.Raw[0] = 0
+	if es.Raw[0] == 0 {
+		t.Fatalf("Raw data is shared with the input.")
+	}
+}
+
+func TestParseDirectoryEntrySet__Short(t *testing.T) {
+	raw := getTestFileEntrySet().Raw
+
+	for _, n := range []int{0, 31, directoryEntryBytesCount, len(raw) - directoryEntryBytesCount} {
+		_, err := ParseDirectoryEntrySet(raw[:n])
+		if err == nil {
+			t.Fatalf("Expected error for (%d) bytes.", n)
+		} else if errors.Is(err, ErrCorrupt) != true {
+			t.Fatalf("Expected corruption error for (%d) bytes: [%v]", n, err)
+		} else if n%directoryEntryBytesCount == 0 && errors.Is(err, ErrShortData) != true {
+			t.Fatalf("Expected short-data error for (%d) bytes: [%v]", n, err)
+		}
+	}
+}
+
+func TestParseDirectoryEntrySet__Invalid(t *testing.T) {
+	raw := getTestFileEntrySet().Raw
+
+	// Starts with a secondary entry.
+	_, err := ParseDirectoryEntrySet(raw[directoryEntryBytesCount:])
+	if err == nil {
+		t.Fatalf("Expected error for missing primary entry.")
+	}
+
+	// Has an entry after the set is complete.
+	data := append(append([]byte{}, raw...), raw[len(raw)-directoryEntryBytesCount:]...)
+
+	_, err = ParseDirectoryEntrySet(data)
+	if err == nil {
+		t.Fatalf("Expected error for trailing entry.")
+	}
+
+	// Has a second primary entry before the set is complete.
+	data = append([]byte{}, raw...)
+	copy(data[directoryEntryBytesCount:], raw[:directoryEntryBytesCount])
+
+	_, err = ParseDirectoryEntrySet(data)
+	if err == nil {
+		t.Fatalf("Expected error for early primary entry.")
+	}
+
+	var ce *CorruptionError
+	if errors.As(err, &ce) != true {
+		t.Fatalf("Expected corruption error: [%v]", err)
+	} else if ce.EntryIndex != 1 {
+		t.Fatalf("Entry index not correct: (%d)", ce.EntryIndex)
+	}
+
+	// Marks the end of the directory.
+	data = append([]byte{}, raw...)
+	data[len(data)-directoryEntryBytesCount] = 0
+
+	_, err = ParseDirectoryEntrySet(data)
+	if err == nil {
+		t.Fatalf("Expected error for end-of-directory entry.")
+	}
+}
+
+func TestFuzzBootSector(t *testing.T) {
+	data := getTestBootSector()
+
+	if fuzzBootSector(data) != 1 {
+		t.Fatalf("Expected original boot-sector to parse.")
+	}
+
+	for n := 0; n < len(data); n++ {
+		if fuzzBootSector(data[:n]) != 0 {
+			t.Fatalf("Expected truncated boot-sector to fail: (%d)", n)
+		}
+	}
+}
+
+func TestFuzzDirectoryEntrySet(t *testing.T) {
+	raw := getTestFileEntrySet().Raw
+
+	if fuzzDirectoryEntrySet(raw) != 1 {
+		t.Fatalf("Expected original entry-set to parse.")
+	}
+
+	for n := 0; n < len(raw); n++ {
+		if fuzzDirectoryEntrySet(raw[:n]) != 0 {
+			t.Fatalf("Expected truncated entry-set to fail: (%d)", n)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+// This package reads GUID partition tables.
+
+package partition
+
+import (
+	"bytes"
+	"io"
+	"strings"
+
+	"hash/crc32"
+	"unicode/utf16"
+
+	"github.com/dsoprea/go-logging"
+
+	"github.com/dsoprea/go-exfat"
+)
+
+const (
+	gptMinimumHeaderSize = 92
+	gptMinimumEntrySize  = 128
+
+	// maxGptEntryCount bounds the size of the entry array that is read.
+	maxGptEntryCount = 1024
+
+	// GptTypeBasicData is the partition-type that Windows uses for FAT,
+	// exFAT, and NTFS volumes alike. The boot sector tells them apart.
+	GptTypeBasicData = "ebd0a0a2-b9e5-4433-87c0-68b6b72699c7"
+)
+
+var (
+	gptSignature = []byte("EFI PART")
+
+	// gptSectorSizes are the logical sector-sizes that are tried, in order,
+	// to find the header.
+	gptSectorSizes = []int64{logicalSectorSize, 4096}
+)
+
+// scanGpt returns the partitions in the GPT. The header and the entry array
+// must have valid checksums.
+func scanGpt(ra io.ReaderAt, size int64) (partitions []Partition, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	var header []byte
+	var sectorSize int64
+
+	for _, currentSectorSize := range gptSectorSizes {
+		if 2*currentSectorSize > size {
+			break
+		}
+
+		current := make([]byte, currentSectorSize)
+
+		err := readAt(ra, current, currentSectorSize)
+		log.PanicIf(err)
+
+		if bytes.Equal(current[:8], gptSignature) == true {
+			header = current
+			sectorSize = currentSectorSize
+
+			break
+		}
+	}
+
+	if header == nil {
+		log.Panicf("protective MBR found but no GPT header")
+	}
+
+	headerSize := defaultEncoding.Uint32(header[12:16])
+	if headerSize < gptMinimumHeaderSize || int64(headerSize) > sectorSize {
+		log.Panicf("GPT header-size not valid: (%d)", headerSize)
+	}
+
+	// The checksum is calculated with its own field zeroed.
+
+	storedHeaderChecksum := defaultEncoding.Uint32(header[16:20])
+
+	checksummed := make([]byte, headerSize)
+	copy(checksummed, header[:headerSize])
+	copy(checksummed[16:20], []byte{0, 0, 0, 0})
+
+	if crc32.ChecksumIEEE(checksummed) != storedHeaderChecksum {
+		log.Panicf("GPT header checksum not correct")
+	}
+
+	entriesLba := int64(defaultEncoding.Uint64(header[72:80]))
+	entryCount := defaultEncoding.Uint32(header[80:84])
+	entrySize := defaultEncoding.Uint32(header[84:88])
+	storedEntriesChecksum := defaultEncoding.Uint32(header[88:92])
+
+	if entryCount > maxGptEntryCount {
+		log.Panicf("too many GPT entries: (%d)", entryCount)
+	} else if entrySize < gptMinimumEntrySize || entrySize%8 != 0 {
+		log.Panicf("GPT entry-size not valid: (%d)", entrySize)
+	} else if entriesLba*sectorSize+int64(entryCount)*int64(entrySize) > size {
+		log.Panicf("GPT entries extend past the end of the image")
+	}
+
+	entries := make([]byte, entryCount*entrySize)
+
+	err = readAt(ra, entries, entriesLba*sectorSize)
+	log.PanicIf(err)
+
+	if crc32.ChecksumIEEE(entries) != storedEntriesChecksum {
+		log.Panicf("GPT entries checksum not correct")
+	}
+
+	partitions = make([]Partition, 0)
+
+	emptyGuid := [16]byte{}
+
+	for i := 0; i < int(entryCount); i++ {
+		raw := entries[i*int(entrySize) : (i+1)*int(entrySize)]
+
+		var typeGuid [16]byte
+		copy(typeGuid[:], raw[0:16])
+
+		if typeGuid == emptyGuid {
+			continue
+		}
+
+		firstLba := int64(defaultEncoding.Uint64(raw[32:40]))
+		lastLba := int64(defaultEncoding.Uint64(raw[40:48]))
+
+		if lastLba < firstLba || (lastLba+1)*sectorSize > size {
+			log.Panicf("GPT partition (%d) not valid: LBA (%d)-(%d)", i+1, firstLba, lastLba)
+		}
+
+		nameUnits := make([]uint16, 36)
+		for j := range nameUnits {
+			nameUnits[j] = defaultEncoding.Uint16(raw[56+j*2 : 56+(j+1)*2])
+		}
+
+		name := strings.TrimRight(string(utf16.Decode(nameUnits)), "\x00")
+
+		p := Partition{
+			Scheme:  SchemeGpt,
+			Index:   i + 1,
+			GptType: exfat.FormatGuid(typeGuid),
+			Name:    name,
+			Offset:  firstLba * sectorSize,
+			Length:  (lastLba - firstLba + 1) * sectorSize,
+		}
+
+		partitions = append(partitions, p)
+	}
+
+	return partitions, nil
+}
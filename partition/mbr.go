@@ -0,0 +1,178 @@
+// This package reads MBR partition tables.
+
+package partition
+
+import (
+	"io"
+
+	"github.com/dsoprea/go-logging"
+)
+
+const (
+	mbrTableOffset    = 0x1be
+	mbrEntrySize      = 16
+	mbrEntryCount     = 4
+	mbrSignatureValue = 0xaa55
+
+	mbrTypeEmpty         = 0x00
+	mbrTypeExtendedChs   = 0x05
+	mbrTypeExtendedLba   = 0x0f
+	mbrTypeGptProtective = 0xee
+
+	// maxLogicalPartitions bounds the extended-partition chain in case it's
+	// circular.
+	maxLogicalPartitions = 128
+)
+
+// mbrEntry is one entry of a partition table.
+type mbrEntry struct {
+	partitionType uint8
+	firstLba      uint32
+	sectorCount   uint32
+}
+
+// readMbrTable reads the four entries of the table in the sector at the given
+// offset. `isValid` is false if the sector doesn't have the signature.
+func readMbrTable(ra io.ReaderAt, offset int64) (entries []mbrEntry, isValid bool, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	sector := make([]byte, logicalSectorSize)
+
+	err = readAt(ra, sector, offset)
+	log.PanicIf(err)
+
+	if defaultEncoding.Uint16(sector[510:512]) != mbrSignatureValue {
+		return nil, false, nil
+	}
+
+	entries = make([]mbrEntry, mbrEntryCount)
+	for i := 0; i < mbrEntryCount; i++ {
+		raw := sector[mbrTableOffset+i*mbrEntrySize : mbrTableOffset+(i+1)*mbrEntrySize]
+
+		entries[i] = mbrEntry{
+			partitionType: raw[4],
+			firstLba:      defaultEncoding.Uint32(raw[8:12]),
+			sectorCount:   defaultEncoding.Uint32(raw[12:16]),
+		}
+	}
+
+	return entries, true, nil
+}
+
+// isExtended indicates whether the entry is an extended partition.
+func (me mbrEntry) isExtended() bool {
+	return me.partitionType == mbrTypeExtendedChs || me.partitionType == mbrTypeExtendedLba
+}
+
+// scanMbr returns the primary and logical partitions. `isProtective` is true
+// if the MBR only exists to protect a GPT.
+func scanMbr(ra io.ReaderAt, size int64) (partitions []Partition, isProtective bool, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	entries, isValid, err := readMbrTable(ra, 0)
+	log.PanicIf(err)
+
+	if isValid == false {
+		log.Panicf("no partition table found")
+	}
+
+	partitions = make([]Partition, 0)
+
+	for i, entry := range entries {
+		if entry.partitionType == mbrTypeGptProtective {
+			return nil, true, nil
+		} else if entry.partitionType == mbrTypeEmpty || entry.sectorCount == 0 {
+			continue
+		}
+
+		if entry.isExtended() == true {
+			logical, err := scanExtended(ra, size, int64(entry.firstLba))
+			log.PanicIf(err)
+
+			partitions = append(partitions, logical...)
+
+			continue
+		}
+
+		p, err := newMbrPartition(size, i+1, entry, 0)
+		log.PanicIf(err)
+
+		partitions = append(partitions, p)
+	}
+
+	return partitions, false, nil
+}
+
+// scanExtended follows the chain of extended boot records. Each one has the
+// logical partition (relative to itself) and a link to the next one (relative
+// to the start of the extended partition).
+func scanExtended(ra io.ReaderAt, size int64, extendedLba int64) (partitions []Partition, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	partitions = make([]Partition, 0)
+
+	ebrLba := extendedLba
+	for i := 0; i < maxLogicalPartitions; i++ {
+		entries, isValid, err := readMbrTable(ra, ebrLba*logicalSectorSize)
+		log.PanicIf(err)
+
+		if isValid == false {
+			log.Panicf("extended boot record not valid: LBA (%d)", ebrLba)
+		}
+
+		if entries[0].partitionType != mbrTypeEmpty && entries[0].sectorCount > 0 {
+			p, err := newMbrPartition(size, 5+i, entries[0], ebrLba)
+			log.PanicIf(err)
+
+			partitions = append(partitions, p)
+		}
+
+		if entries[1].isExtended() == false {
+			return partitions, nil
+		}
+
+		ebrLba = extendedLba + int64(entries[1].firstLba)
+	}
+
+	log.Panicf("too many logical partitions")
+	return nil, nil
+}
+
+// newMbrPartition describes the given entry, whose LBA is relative to
+// `baseLba`.
+func newMbrPartition(size int64, index int, entry mbrEntry, baseLba int64) (p Partition, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	offset := (baseLba + int64(entry.firstLba)) * logicalSectorSize
+	length := int64(entry.sectorCount) * logicalSectorSize
+
+	if offset+length > size {
+		log.Panicf("MBR partition (%d) extends past the end of the image: (%d) > (%d)", index, offset+length, size)
+	}
+
+	p = Partition{
+		Scheme:  SchemeMbr,
+		Index:   index,
+		MbrType: entry.partitionType,
+		Offset:  offset,
+		Length:  length,
+	}
+
+	return p, nil
+}
@@ -0,0 +1,171 @@
+// This package finds the exFAT partitions in a raw disk image by reading its
+// MBR or GPT partition table.
+
+package partition
+
+import (
+	"fmt"
+	"io"
+
+	"encoding/binary"
+
+	"github.com/dsoprea/go-logging"
+
+	"github.com/dsoprea/go-exfat"
+)
+
+const (
+	// logicalSectorSize is the size of an LBA for MBR, and the first size
+	// that is tried for GPT.
+	logicalSectorSize = 512
+)
+
+var (
+	defaultEncoding = binary.LittleEndian
+)
+
+// Scheme is the kind of partition table that a partition was found in.
+type Scheme string
+
+const (
+	// SchemeNone means that the image has no partition table and the volume
+	// starts at the beginning (a "superfloppy").
+	SchemeNone Scheme = "none"
+
+	// SchemeMbr is a classic MBR partition table, including logical
+	// partitions in extended partitions.
+	SchemeMbr Scheme = "mbr"
+
+	// SchemeGpt is a GUID partition table.
+	SchemeGpt Scheme = "gpt"
+)
+
+// Partition describes one partition.
+type Partition struct {
+	Scheme Scheme `json:"scheme"`
+
+	// Index is the position of the partition in its table (starting with one).
+	// For MBR, logical partitions are numbered from five.
+	Index int `json:"index"`
+
+	// MbrType is the partition-type byte for MBR partitions.
+	MbrType uint8 `json:"mbr_type,omitempty"`
+
+	// GptType is the partition-type GUID for GPT partitions.
+	GptType string `json:"gpt_type,omitempty"`
+
+	// Name is the GPT partition name, if any.
+	Name string `json:"name,omitempty"`
+
+	// Offset is the byte-offset of the partition within the image.
+	Offset int64 `json:"offset"`
+
+	// Length is the size of the partition in bytes.
+	Length int64 `json:"length"`
+
+	// IsExfat indicates whether the partition starts with an exFAT boot
+	// sector.
+	IsExfat bool `json:"is_exfat"`
+}
+
+// String returns a descriptive string.
+func (p Partition) String() string {
+	return fmt.Sprintf("Partition<SCHEME=[%s] INDEX=(%d) OFFSET=(%d) LENGTH=(%d) IS-EXFAT=[%v]>", p.Scheme, p.Index, p.Offset, p.Length, p.IsExfat)
+}
+
+// NewExfatReader returns a reader for the volume in this partition. It must
+// still be parsed.
+func (p Partition) NewExfatReader(ra io.ReaderAt) *exfat.ExfatReader {
+	sr := io.NewSectionReader(ra, p.Offset, p.Length)
+	return exfat.NewExfatReader(sr)
+}
+
+// readAt fills the buffer from the given offset.
+func readAt(ra io.ReaderAt, data []byte, offset int64) (err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	n, err := ra.ReadAt(data, offset)
+	if err == io.EOF && n == len(data) {
+		err = nil
+	}
+
+	log.PanicIf(err)
+
+	return nil
+}
+
+// isExfatAt indicates whether an exFAT boot sector starts at the given
+// offset.
+func isExfatAt(ra io.ReaderAt, offset int64) bool {
+	isExfat, _, err := exfat.Probe(ra, offset)
+	if err != nil {
+		return false
+	}
+
+	return isExfat
+}
+
+// Scan reads the partition table of the given image, which is `size` bytes
+// long, and returns every partition that it describes. If the image has no
+// partition table but is itself an exFAT volume, that is returned as the only
+// partition.
+func Scan(ra io.ReaderAt, size int64) (partitions []Partition, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	// An exFAT boot sector has the same signature as an MBR, so check for one
+	// first.
+	if isExfatAt(ra, 0) == true {
+		p := Partition{
+			Scheme:  SchemeNone,
+			Index:   1,
+			Offset:  0,
+			Length:  size,
+			IsExfat: true,
+		}
+
+		return []Partition{p}, nil
+	}
+
+	partitions, isProtective, err := scanMbr(ra, size)
+	log.PanicIf(err)
+
+	if isProtective == true {
+		partitions, err = scanGpt(ra, size)
+		log.PanicIf(err)
+	}
+
+	for i, p := range partitions {
+		partitions[i].IsExfat = isExfatAt(ra, p.Offset)
+	}
+
+	return partitions, nil
+}
+
+// ExfatPartitions returns only the partitions that contain exFAT volumes.
+func ExfatPartitions(ra io.ReaderAt, size int64) (partitions []Partition, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	all, err := Scan(ra, size)
+	log.PanicIf(err)
+
+	partitions = make([]Partition, 0)
+	for _, p := range all {
+		if p.IsExfat == true {
+			partitions = append(partitions, p)
+		}
+	}
+
+	return partitions, nil
+}
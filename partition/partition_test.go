@@ -0,0 +1,173 @@
+package partition
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path"
+	"testing"
+
+	"hash/crc32"
+	"unicode/utf16"
+
+	"github.com/dsoprea/go-logging"
+)
+
+var (
+	assetPath = path.Join("..", "test", "assets")
+)
+
+func getTestVolume() []byte {
+	volume, err := ioutil.ReadFile(path.Join(assetPath, "test.exfat"))
+	log.PanicIf(err)
+
+	return volume
+}
+
+// putMbrEntry writes a partition entry into the table in the given sector.
+func putMbrEntry(sector []byte, i int, partitionType uint8, firstLba, sectorCount uint32) {
+	raw := sector[mbrTableOffset+i*mbrEntrySize : mbrTableOffset+(i+1)*mbrEntrySize]
+
+	raw[4] = partitionType
+	defaultEncoding.PutUint32(raw[8:12], firstLba)
+	defaultEncoding.PutUint32(raw[12:16], sectorCount)
+
+	defaultEncoding.PutUint16(sector[510:512], mbrSignatureValue)
+}
+
+func checkTestPartition(t *testing.T, image []byte, p Partition) {
+	if p.IsExfat != true {
+		t.Fatalf("Expected exFAT partition: %s", p)
+	}
+
+	er := p.NewExfatReader(bytes.NewReader(image))
+
+	err := er.Parse()
+	log.PanicIf(err)
+
+	vi, err := er.VolumeInfo()
+	log.PanicIf(err)
+
+	if vi.Label != "testvolumelabel" {
+		t.Fatalf("Label not correct: [%s]", vi.Label)
+	}
+}
+
+func TestScan__None(t *testing.T) {
+	image := getTestVolume()
+
+	partitions, err := Scan(bytes.NewReader(image), int64(len(image)))
+	log.PanicIf(err)
+
+	if len(partitions) != 1 {
+		t.Fatalf("Expected one partition: %v", partitions)
+	} else if partitions[0].Scheme != SchemeNone || partitions[0].Length != int64(len(image)) {
+		t.Fatalf("Partition not correct: %s", partitions[0])
+	}
+
+	checkTestPartition(t, image, partitions[0])
+}
+
+func TestScan__Mbr(t *testing.T) {
+	volume := getTestVolume()
+	sectorCount := uint32(len(volume) / logicalSectorSize)
+
+	// A primary partition followed by an extended partition with one
+	// logical partition in it (after its EBR).
+
+	image := make([]byte, 2048*logicalSectorSize+len(volume)+logicalSectorSize+len(volume))
+
+	copy(image[2048*logicalSectorSize:], volume)
+	putMbrEntry(image, 0, 0x07, 2048, sectorCount)
+
+	extendedLba := 2048 + sectorCount
+	putMbrEntry(image, 1, mbrTypeExtendedLba, extendedLba, 1+sectorCount)
+
+	ebr := image[extendedLba*logicalSectorSize:]
+	putMbrEntry(ebr, 0, 0x07, 1, sectorCount)
+
+	copy(image[(extendedLba+1)*logicalSectorSize:], volume)
+
+	partitions, err := ExfatPartitions(bytes.NewReader(image), int64(len(image)))
+	log.PanicIf(err)
+
+	if len(partitions) != 2 {
+		t.Fatalf("Expected two partitions: %v", partitions)
+	} else if partitions[0].Index != 1 || partitions[0].Offset != 2048*logicalSectorSize {
+		t.Fatalf("Primary partition not correct: %s", partitions[0])
+	} else if partitions[1].Index != 5 || partitions[1].Offset != int64(extendedLba+1)*logicalSectorSize {
+		t.Fatalf("Logical partition not correct: %s", partitions[1])
+	}
+
+	for _, p := range partitions {
+		checkTestPartition(t, image, p)
+	}
+}
+
+func TestScan__Gpt(t *testing.T) {
+	volume := getTestVolume()
+	sectorCount := int64(len(volume) / logicalSectorSize)
+
+	image := make([]byte, 2048*logicalSectorSize+len(volume))
+	copy(image[2048*logicalSectorSize:], volume)
+
+	putMbrEntry(image, 0, mbrTypeGptProtective, 1, uint32(len(image)/logicalSectorSize-1))
+
+	// One entry in an array of 128 at LBA 2.
+
+	entries := make([]byte, 128*128)
+
+	typeGuid := []byte{0xa2, 0xa0, 0xd0, 0xeb, 0xe5, 0xb9, 0x33, 0x44, 0x87, 0xc0, 0x68, 0xb6, 0xb7, 0x26, 0x99, 0xc7}
+	copy(entries[0:16], typeGuid)
+	entries[16] = 1
+	defaultEncoding.PutUint64(entries[32:40], 2048)
+	defaultEncoding.PutUint64(entries[40:48], uint64(2048+sectorCount-1))
+
+	for i, unit := range utf16.Encode([]rune("card")) {
+		defaultEncoding.PutUint16(entries[56+i*2:], unit)
+	}
+
+	copy(image[2*logicalSectorSize:], entries)
+
+	header := image[logicalSectorSize : 2*logicalSectorSize]
+	copy(header, gptSignature)
+	defaultEncoding.PutUint32(header[12:16], gptMinimumHeaderSize)
+	defaultEncoding.PutUint64(header[72:80], 2)
+	defaultEncoding.PutUint32(header[80:84], 128)
+	defaultEncoding.PutUint32(header[84:88], 128)
+	defaultEncoding.PutUint32(header[88:92], crc32.ChecksumIEEE(entries))
+	defaultEncoding.PutUint32(header[16:20], crc32.ChecksumIEEE(header[:gptMinimumHeaderSize]))
+
+	partitions, err := Scan(bytes.NewReader(image), int64(len(image)))
+	log.PanicIf(err)
+
+	if len(partitions) != 1 {
+		t.Fatalf("Expected one partition: %v", partitions)
+	}
+
+	p := partitions[0]
+	if p.Scheme != SchemeGpt || p.GptType != GptTypeBasicData || p.Name != "card" {
+		t.Fatalf("Partition not correct: %s", p)
+	} else if p.Offset != 2048*logicalSectorSize || p.Length != int64(len(volume)) {
+		t.Fatalf("Partition range not correct: %s", p)
+	}
+
+	checkTestPartition(t, image, p)
+
+	// A damaged header is caught.
+
+	header[20] = 0xff
+
+	_, err = Scan(bytes.NewReader(image), int64(len(image)))
+	if err == nil {
+		t.Fatalf("Expected checksum error.")
+	}
+}
+
+func TestScan__NoTable(t *testing.T) {
+	image := make([]byte, 4096)
+
+	_, err := Scan(bytes.NewReader(image), int64(len(image)))
+	if err == nil {
+		t.Fatalf("Expected error for image without partition table.")
+	}
+}
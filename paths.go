@@ -0,0 +1,296 @@
+// This package supports translating between paths on the volume and paths on
+// the host.
+
+package exfat
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/dsoprea/go-logging"
+)
+
+const (
+	// VolumePathSeparator is the separator used between path components on
+	// the volume.
+	VolumePathSeparator = `\`
+)
+
+var (
+	// ErrUnsafeHostPath is matched (with errors.Is) by the error returned when
+	// a volume path can't be mapped to a host path without the risk of it
+	// leaving the host root: it has a component that is "." or "..", or that
+	// has a separator or a NUL in it. A damaged or crafted volume can have
+	// entries with any of these names.
+	ErrUnsafeHostPath = errors.New("volume path can not be mapped safely to the host")
+)
+
+// SplitVolumePath splits a volume path into its components. Both forward- and
+// backward-slashes are accepted as separators and empty components (leading,
+// trailing, or repeated separators) are dropped. The components aren't
+// otherwise checked (see HostPathFromVolumePath()).
+func SplitVolumePath(volumePath string) (pathParts []string) {
+	normalized := strings.Replace(volumePath, "/", VolumePathSeparator, -1)
+
+	pathParts = make([]string, 0)
+	for _, part := range strings.Split(normalized, VolumePathSeparator) {
+		if part == "" {
+			continue
+		}
+
+		pathParts = append(pathParts, part)
+	}
+
+	return pathParts
+}
+
+// JoinVolumePath joins the given components using the volume separator. This
+// is the form that `Tree.List()` returns.
+func JoinVolumePath(pathParts []string) string {
+	return strings.Join(pathParts, VolumePathSeparator)
+}
+
+// NormalizeVolumePath returns the canonical (backslash-separated) form of the
+// given volume path.
+func NormalizeVolumePath(volumePath string) string {
+	pathParts := SplitVolumePath(volumePath)
+	return JoinVolumePath(pathParts)
+}
+
+// VolumePathsEqual indicates whether the two volume paths refer to the same
+// entry. exFAT filenames are case-insensitive, so the comparison is, too.
+// Since the up-case table is not read, this relies on Unicode simple case-
+// folding rather than the table stored on the volume.
+func VolumePathsEqual(a, b string) bool {
+	aParts := SplitVolumePath(a)
+	bParts := SplitVolumePath(b)
+
+	if len(aParts) != len(bParts) {
+		return false
+	}
+
+	for i, part := range aParts {
+		if strings.EqualFold(part, bParts[i]) == false {
+			return false
+		}
+	}
+
+	return true
+}
+
+// checkHostPathParts fails with ErrUnsafeHostPath if any of the components
+// can't be used as a host filename as it is.
+func checkHostPathParts(pathParts []string) (err error) {
+	for _, part := range pathParts {
+		if part == "" || IsSpecialEntry(part, 0) == true || strings.ContainsAny(part, "/\\\x00") == true {
+			return fmt.Errorf("%w: [%s]", ErrUnsafeHostPath, part)
+		}
+	}
+
+	return nil
+}
+
+// isUnderHostRoot indicates whether the (joined) host path is the host root or
+// something under it.
+func isUnderHostRoot(hostRoot string, hostPath string) bool {
+	relPath, err := filepath.Rel(hostRoot, hostPath)
+	if err != nil {
+		return false
+	}
+
+	return relPath != ".." && strings.HasPrefix(relPath, ".."+string(filepath.Separator)) == false
+}
+
+// joinHostPath joins the components onto the host root, refusing anything
+// that could end up outside of it.
+func joinHostPath(hostRoot string, pathParts ...string) (hostPath string, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	err = checkHostPathParts(pathParts)
+	log.PanicIf(err)
+
+	hostParts := make([]string, len(pathParts)+1)
+	hostParts[0] = hostRoot
+	copy(hostParts[1:], pathParts)
+
+	hostPath = filepath.Join(hostParts...)
+
+	if isUnderHostRoot(hostRoot, hostPath) == false {
+		log.Panic(fmt.Errorf("%w: [%s]", ErrUnsafeHostPath, JoinVolumePath(pathParts)))
+	}
+
+	return hostPath, nil
+}
+
+// HostPathFromVolumePath returns the path on the host that the given volume
+// path corresponds to when rooted at `hostRoot`. An error matching
+// ErrUnsafeHostPath is returned if it would not be under `hostRoot`.
+func HostPathFromVolumePath(hostRoot string, volumePath string) (hostPath string, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	hostPath, err = joinHostPath(hostRoot, SplitVolumePath(volumePath)...)
+	log.PanicIf(err)
+
+	return hostPath, nil
+}
+
+// VolumePathFromHostPath returns the volume path that corresponds to the given
+// host path, which must be located under `hostRoot`.
+func VolumePathFromHostPath(hostRoot string, hostPath string) (volumePath string, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	relPath, err := filepath.Rel(hostRoot, hostPath)
+	log.PanicIf(err)
+
+	if relPath == ".." || strings.HasPrefix(relPath, ".."+string(filepath.Separator)) == true {
+		log.Panicf("host path is not under the root: [%s] [%s]", hostPath, hostRoot)
+	} else if relPath == "." {
+		return "", nil
+	}
+
+	pathParts := strings.Split(filepath.ToSlash(relPath), "/")
+	volumePath = JoinVolumePath(pathParts)
+
+	return volumePath, nil
+}
+
+// HostPathMapper assigns host paths to volume paths while guaranteeing that
+// two volume entries never land on the same host path. This matters when two
+// names differ only by case (legal for us since we don't apply the up-case
+// table) and the host directory is case-insensitive.
+type HostPathMapper struct {
+	hostRoot        string
+	caseInsensitive bool
+
+	// assigned maps the collision key of each host path that was assigned to
+	// the volume path that it was assigned for.
+	assigned map[string]string
+
+	// mapped maps each volume path to the host path it was assigned.
+	mapped map[string]string
+}
+
+// NewHostPathMapper returns a new HostPathMapper instance. If
+// `caseInsensitive` is true, host paths that differ only by case are
+// considered to collide.
+func NewHostPathMapper(hostRoot string, caseInsensitive bool) *HostPathMapper {
+	return &HostPathMapper{
+		hostRoot:        hostRoot,
+		caseInsensitive: caseInsensitive,
+		assigned:        make(map[string]string),
+		mapped:          make(map[string]string),
+	}
+}
+
+func (hpm *HostPathMapper) collisionKey(hostPath string) string {
+	if hpm.caseInsensitive == true {
+		return strings.ToLower(hostPath)
+	}
+
+	return hostPath
+}
+
+// HostPath returns the host path for the given volume path. The same volume
+// path always returns the same host path. If the natural host path was already
+// assigned to a different volume path, a numeric suffix is inserted before the
+// extension (e.g. "file (1).txt") until a free name is found. Parent
+// directories are mapped first so that entries under a renamed directory
+// follow it. An error matching ErrUnsafeHostPath is returned if the path has a
+// component that could take it outside of the host root.
+func (hpm *HostPathMapper) HostPath(volumePath string) (hostPath string, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	hostPath, err = hpm.HostPathFromParts(SplitVolumePath(volumePath))
+	log.PanicIf(err)
+
+	return hostPath, nil
+}
+
+// HostPathFromParts is the same as HostPath() but takes the components of the
+// volume path, as they are named on the volume. This is what should be used
+// with names read from the volume (e.g. from Tree.Walk()) since, unlike a
+// joined path, a name with a separator in it is then recognized as unsafe
+// rather than being split.
+func (hpm *HostPathMapper) HostPathFromParts(pathParts []string) (hostPath string, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	err = checkHostPathParts(pathParts)
+	log.PanicIf(err)
+
+	normalized := JoinVolumePath(pathParts)
+
+	if hostPath, found := hpm.mapped[normalized]; found == true {
+		return hostPath, nil
+	}
+
+	parentHostPath := hpm.hostRoot
+	if len(pathParts) > 1 {
+		parentHostPath, err = hpm.HostPathFromParts(pathParts[:len(pathParts)-1])
+		log.PanicIf(err)
+	} else if len(pathParts) == 0 {
+		return hpm.hostRoot, nil
+	}
+
+	name := pathParts[len(pathParts)-1]
+	extension := filepath.Ext(name)
+	stem := name[:len(name)-len(extension)]
+
+	hostPath, err = joinHostPath(parentHostPath, name)
+	log.PanicIf(err)
+
+	for i := 1; ; i++ {
+		key := hpm.collisionKey(hostPath)
+		if _, found := hpm.assigned[key]; found == false {
+			hpm.assigned[key] = normalized
+			break
+		}
+
+		hostPath, err = joinHostPath(parentHostPath, fmt.Sprintf("%s (%d)%s", stem, i, extension))
+		log.PanicIf(err)
+	}
+
+	if isUnderHostRoot(hpm.hostRoot, hostPath) == false {
+		log.Panic(fmt.Errorf("%w: [%s]", ErrUnsafeHostPath, normalized))
+	}
+
+	hpm.mapped[normalized] = hostPath
+
+	return hostPath, nil
+}
+
+// Collisions returns the volume paths whose own names had to be changed to
+// avoid a collision, mapped to the host path they were given.
+func (hpm *HostPathMapper) Collisions() (collisions map[string]string) {
+	collisions = make(map[string]string)
+
+	for volumePath, hostPath := range hpm.mapped {
+		pathParts := SplitVolumePath(volumePath)
+		if filepath.Base(hostPath) != pathParts[len(pathParts)-1] {
+			collisions[volumePath] = hostPath
+		}
+	}
+
+	return collisions
+}
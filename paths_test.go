@@ -0,0 +1,251 @@
+package exfat
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/dsoprea/go-logging"
+)
+
+func TestSplitVolumePath(t *testing.T) {
+	pathParts := SplitVolumePath(`/testdirectory2\\file1/`)
+
+	expected := []string{"testdirectory2", "file1"}
+	if reflect.DeepEqual(pathParts, expected) != true {
+		t.Fatalf("Path parts not correct: %v", pathParts)
+	}
+}
+
+func TestSplitVolumePath__Root(t *testing.T) {
+	pathParts := SplitVolumePath(`\`)
+
+	if len(pathParts) != 0 {
+		t.Fatalf("Expected no path parts for root: %v", pathParts)
+	}
+}
+
+func TestNormalizeVolumePath(t *testing.T) {
+	normalized := NormalizeVolumePath("testdirectory2/file1")
+
+	if normalized != `testdirectory2\file1` {
+		t.Fatalf("Normalized path not correct: [%s]", normalized)
+	}
+}
+
+func TestVolumePathsEqual__true(t *testing.T) {
+	if VolumePathsEqual(`TestDirectory2\FILE1`, "testdirectory2/file1") != true {
+		t.Fatalf("Expected paths to be equal.")
+	}
+}
+
+func TestVolumePathsEqual__false(t *testing.T) {
+	if VolumePathsEqual(`testdirectory2\file1`, "testdirectory2/file2") != false {
+		t.Fatalf("Expected paths to not be equal.")
+	}
+}
+
+func TestHostPathFromVolumePath(t *testing.T) {
+	hostPath, err := HostPathFromVolumePath("root", `testdirectory2\file1`)
+	log.PanicIf(err)
+
+	expected := filepath.Join("root", "testdirectory2", "file1")
+	if hostPath != expected {
+		t.Fatalf("Host path not correct: [%s]", hostPath)
+	}
+}
+
+func TestHostPathFromVolumePath__Unsafe(t *testing.T) {
+	for _, volumePath := range []string{`..`, `..\file1`, `testdirectory2\..\..\file1`, `.\file1`, "file\x001"} {
+		_, err := HostPathFromVolumePath("root", volumePath)
+		if err == nil {
+			t.Fatalf("Expected error for [%s].", volumePath)
+		} else if errors.Is(err, ErrUnsafeHostPath) != true {
+			t.Fatalf("Expected unsafe-path error for [%s]: [%v]", volumePath, err)
+		}
+	}
+}
+
+func TestVolumePathFromHostPath(t *testing.T) {
+	volumePath, err := VolumePathFromHostPath("root", filepath.Join("root", "testdirectory2", "file1"))
+	log.PanicIf(err)
+
+	if volumePath != `testdirectory2\file1` {
+		t.Fatalf("Volume path not correct: [%s]", volumePath)
+	}
+}
+
+func TestVolumePathFromHostPath__Outside(t *testing.T) {
+	_, err := VolumePathFromHostPath("root", filepath.Join("other", "file1"))
+	if err == nil {
+		t.Fatalf("Expected error for path outside of root.")
+	}
+}
+
+func TestHostPathMapper_HostPath__CaseInsensitive(t *testing.T) {
+	hpm := NewHostPathMapper("root", true)
+
+	first, err := hpm.HostPath(`dir\File.txt`)
+	log.PanicIf(err)
+
+	second, err := hpm.HostPath(`dir\file.txt`)
+	log.PanicIf(err)
+
+	child, err := hpm.HostPath(`DIR\child`)
+	log.PanicIf(err)
+
+	if first != filepath.Join("root", "dir", "File.txt") {
+		t.Fatalf("First host path not correct: [%s]", first)
+	} else if second != filepath.Join("root", "dir", "file (1).txt") {
+		t.Fatalf("Second host path not correct: [%s]", second)
+	} else if child != filepath.Join("root", "DIR (1)", "child") {
+		t.Fatalf("Child host path not correct: [%s]", child)
+	}
+
+	again, err := hpm.HostPath(`dir\file.txt`)
+	log.PanicIf(err)
+
+	if again != second {
+		t.Fatalf("Host path not stable.")
+	}
+
+	collisions := hpm.Collisions()
+
+	expected := map[string]string{
+		`dir\file.txt`: second,
+		`DIR`:          filepath.Join("root", "DIR (1)"),
+	}
+
+	if reflect.DeepEqual(collisions, expected) != true {
+		t.Fatalf("Collisions not correct: %v", collisions)
+	}
+}
+
+func TestHostPathMapper_HostPath__CaseSensitive(t *testing.T) {
+	hpm := NewHostPathMapper("root", false)
+
+	first, err := hpm.HostPath("File.txt")
+	log.PanicIf(err)
+
+	second, err := hpm.HostPath("file.txt")
+	log.PanicIf(err)
+
+	if first != filepath.Join("root", "File.txt") {
+		t.Fatalf("First host path not correct: [%s]", first)
+	} else if second != filepath.Join("root", "file.txt") {
+		t.Fatalf("Second host path not correct: [%s]", second)
+	}
+}
+
+func TestHostPathMapper_HostPathFromParts__Unsafe(t *testing.T) {
+	hpm := NewHostPathMapper("root", false)
+
+	unsafe := [][]string{
+		{".."},
+		{"dir", ".."},
+		{"."},
+		{`..\file1`},
+		{"dir", "../file1"},
+		{"file\x001"},
+		{""},
+	}
+
+	for _, pathParts := range unsafe {
+		_, err := hpm.HostPathFromParts(pathParts)
+		if err == nil {
+			t.Fatalf("Expected error for %q.", pathParts)
+		} else if errors.Is(err, ErrUnsafeHostPath) != true {
+			t.Fatalf("Expected unsafe-path error for %q: [%v]", pathParts, err)
+		}
+	}
+
+	hostPath, err := hpm.HostPathFromParts([]string{"dir", "..file"})
+	log.PanicIf(err)
+
+	if hostPath != filepath.Join("root", "dir", "..file") {
+		t.Fatalf("Host path not correct: [%s]", hostPath)
+	}
+}
+
+// getTestTraversalTree returns a volume with entries whose names would take
+// them outside of wherever they're extracted to if they were used as they
+// are: a directory named "..", and a file whose name has a separator in it.
+// The writer refuses such names, so they're added behind its back.
+func getTestTraversalTree() (tree *Tree, closer func()) {
+	f, closer := getTestNewImage()
+
+	ew, err := NewExfatWriter(f, 4*1024*1024, FormatOptions{})
+	log.PanicIf(err)
+
+	dotDot := newWriterNode("..", true, FileMetadata{Attributes: fileAttributeDirectory})
+	ew.root.children = append(ew.root.children, dotDot)
+	ew.root.childIndex[".."] = dotDot
+
+	data := []byte("escaped")
+
+	err = ew.CreateFile([]string{"..", "escaped1.txt"}, bytes.NewReader(data), uint64(len(data)), FileMetadata{})
+	log.PanicIf(err)
+
+	err = ew.CreateFile([]string{"placeholder"}, bytes.NewReader(data), uint64(len(data)), FileMetadata{})
+	log.PanicIf(err)
+
+	ew.root.childIndex["PLACEHOLDER"].name = `..\escaped2.txt`
+
+	err = ew.Close()
+	log.PanicIf(err)
+
+	er, _ := getTestParsedImage(f)
+
+	return NewTree(er), closer
+}
+
+func TestHostPathMapper__TraversalImage(t *testing.T) {
+	tree, closer := getTestTraversalTree()
+
+	defer closer()
+
+	parentDir, err := ioutil.TempDir("", "exfat-traversal-")
+	log.PanicIf(err)
+
+	defer os.RemoveAll(parentDir)
+
+	destDir := filepath.Join(parentDir, "dest")
+
+	assertNotEscaped := func() {
+		files, err := ioutil.ReadDir(parentDir)
+		log.PanicIf(err)
+
+		if len(files) != 1 || files[0].Name() != "dest" {
+			t.Fatalf("Something was written outside of the destination: %v", files)
+		}
+	}
+
+	_, err = SyncToDir(tree, "", destDir, SyncOptions{})
+	if err == nil {
+		t.Fatalf("Expected error from SyncToDir.")
+	} else if errors.Is(err, ErrUnsafeHostPath) != true {
+		t.Fatalf("Expected unsafe-path error from SyncToDir: [%v]", err)
+	}
+
+	assertNotEscaped()
+
+	results, err := ExtractMany(tree, []string{`..\escaped1.txt`}, destDir, 1)
+	log.PanicIf(err)
+
+	if errors.Is(results[0].Err, ErrUnsafeHostPath) != true {
+		t.Fatalf("Expected unsafe-path error from ExtractMany: [%v]", results[0].Err)
+	}
+
+	assertNotEscaped()
+
+	_, err = DiffWithLocal(tree, destDir)
+	if err == nil {
+		t.Fatalf("Expected error from DiffWithLocal.")
+	} else if errors.Is(err, ErrUnsafeHostPath) != true {
+		t.Fatalf("Expected unsafe-path error from DiffWithLocal: [%v]", err)
+	}
+}
@@ -0,0 +1,125 @@
+package exfat
+
+import (
+	"fmt"
+	"hash"
+
+	"github.com/dsoprea/go-logging"
+)
+
+// PieceHash is the digest of one fixed-size piece of a file.
+type PieceHash struct {
+	// Index is the position of the piece within the file.
+	Index int
+
+	// Offset is the offset of the piece within the file.
+	Offset uint64
+
+	// Length is the amount of data in the piece. Only the last piece may be
+	// shorter than the piece-size.
+	Length uint64
+
+	// Digest is the hash of the piece's data.
+	Digest []byte
+}
+
+// String returns a descriptive string.
+func (ph PieceHash) String() string {
+	return fmt.Sprintf("PieceHash<INDEX=(%d) OFFSET=(%d) LENGTH=(%d) DIGEST=[%x]>", ph.Index, ph.Offset, ph.Length, ph.Digest)
+}
+
+// pieceHashWriter hashes everything written to it in fixed-size pieces.
+type pieceHashWriter struct {
+	pieceSize uint64
+	newHash   func() hash.Hash
+
+	current       hash.Hash
+	currentLength uint64
+	offset        uint64
+
+	pieces []PieceHash
+}
+
+// Write adds the given data to the current piece, finishing pieces as they
+// fill.
+func (phw *pieceHashWriter) Write(data []byte) (n int, err error) {
+	n = len(data)
+
+	for len(data) > 0 {
+		if phw.current == nil {
+			phw.current = phw.newHash()
+		}
+
+		chunkSize := phw.pieceSize - phw.currentLength
+		if uint64(len(data)) < chunkSize {
+			chunkSize = uint64(len(data))
+		}
+
+		phw.current.Write(data[:chunkSize])
+		phw.currentLength += chunkSize
+		data = data[chunkSize:]
+
+		if phw.currentLength == phw.pieceSize {
+			phw.finishPiece()
+		}
+	}
+
+	return n, nil
+}
+
+// finishPiece records the current piece, if it has any data.
+func (phw *pieceHashWriter) finishPiece() {
+	if phw.current == nil || phw.currentLength == 0 {
+		return
+	}
+
+	ph := PieceHash{
+		Index:  len(phw.pieces),
+		Offset: phw.offset,
+		Length: phw.currentLength,
+		Digest: phw.current.Sum(nil),
+	}
+
+	phw.pieces = append(phw.pieces, ph)
+
+	phw.offset += phw.currentLength
+	phw.current = nil
+	phw.currentLength = 0
+}
+
+// PieceHashesFromClusterChain hashes the data for the given cluster chain in
+// pieces of `pieceSize` bytes, which must be a whole multiple of the cluster-
+// size so that every piece boundary is also a cluster boundary. If zero, the
+// cluster-size is used. `newHash` constructs a fresh hash for each piece (e.g.
+// `sha256.New` or a factory from LookupHash). This supports incremental backup tools that need to know
+// which parts of a large file changed.
+func (er *ExfatReader) PieceHashesFromClusterChain(firstClusterNumber uint32, dataSize uint64, useFat bool, pieceSize uint64, newHash func() hash.Hash) (pieces []PieceHash, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	clusterSize := uint64(er.bootRegion.bsh.ClusterSize())
+
+	if pieceSize == 0 {
+		pieceSize = clusterSize
+	} else if pieceSize%clusterSize != 0 {
+		log.Panicf("piece-size must be a multiple of the cluster-size: (%d) %% (%d) != 0", pieceSize, clusterSize)
+	}
+
+	phw := &pieceHashWriter{
+		pieceSize: pieceSize,
+		newHash:   newHash,
+		pieces:    make([]PieceHash, 0),
+	}
+
+	if dataSize > 0 {
+		_, _, err = er.WriteFromClusterChain(firstClusterNumber, dataSize, useFat, phw)
+		log.PanicIf(err)
+	}
+
+	phw.finishPiece()
+
+	return phw.pieces, nil
+}
@@ -0,0 +1,107 @@
+package exfat
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"crypto/sha1"
+
+	"github.com/dsoprea/go-logging"
+)
+
+func TestExfatReader_PieceHashesFromClusterChain(t *testing.T) {
+	tree, closer := getTestTree()
+
+	defer closer()
+
+	sede, data := getTestFileData(tree, "2-delahaye-type-165-cabriolet-dsc_8025.jpg")
+	useFat := sede.GeneralSecondaryFlags.NoFatChain() == false
+
+	pieceSize := uint64(tree.er.ActiveBootSectorHeader().ClusterSize()) * 4
+
+	pieces, err := tree.er.PieceHashesFromClusterChain(sede.FirstCluster, sede.ValidDataLength, useFat, pieceSize, sha1.New)
+	log.PanicIf(err)
+
+	expectedCount := (uint64(len(data)) + pieceSize - 1) / pieceSize
+	if uint64(len(pieces)) != expectedCount {
+		t.Fatalf("Piece count not correct: (%d) != (%d)", len(pieces), expectedCount)
+	}
+
+	for i, piece := range pieces {
+		end := piece.Offset + piece.Length
+
+		digest := sha1.Sum(data[piece.Offset:end])
+
+		if piece.Index != i {
+			t.Fatalf("Piece index not correct: %s", piece)
+		} else if piece.Offset != uint64(i)*pieceSize {
+			t.Fatalf("Piece offset not correct: %s", piece)
+		} else if bytes.Equal(piece.Digest, digest[:]) != true {
+			t.Fatalf("Piece digest not correct: %s", piece)
+		}
+	}
+
+	last := pieces[len(pieces)-1]
+	if last.Offset+last.Length != uint64(len(data)) {
+		t.Fatalf("Pieces do not cover the whole file.")
+	}
+}
+
+func TestExfatReader_PieceHashesFromClusterChain__DefaultPieceSize(t *testing.T) {
+	tree, closer := getTestTree()
+
+	defer closer()
+
+	sede, _ := getTestFileData(tree, "testdirectory2/file1")
+	useFat := sede.GeneralSecondaryFlags.NoFatChain() == false
+
+	pieces, err := tree.er.PieceHashesFromClusterChain(sede.FirstCluster, sede.ValidDataLength, useFat, 0, sha1.New)
+	log.PanicIf(err)
+
+	if len(pieces) != 1 {
+		t.Fatalf("Expected one piece: (%d)", len(pieces))
+	} else if pieces[0].Length != sede.ValidDataLength {
+		t.Fatalf("Piece length not correct: %s", pieces[0])
+	}
+}
+
+func TestExfatReader_PieceHashesFromClusterChain__Unaligned(t *testing.T) {
+	tree, closer := getTestTree()
+
+	defer closer()
+
+	sede, _ := getTestFileData(tree, "testdirectory2/file1")
+	useFat := sede.GeneralSecondaryFlags.NoFatChain() == false
+
+	_, err := tree.er.PieceHashesFromClusterChain(sede.FirstCluster, sede.ValidDataLength, useFat, 1000, sha1.New)
+	if err == nil {
+		t.Fatalf("Expected error for unaligned piece-size.")
+	}
+}
+
+func TestPieceHashWriter_Write(t *testing.T) {
+	phw := &pieceHashWriter{
+		pieceSize: 4,
+		newHash:   sha1.New,
+		pieces:    make([]PieceHash, 0),
+	}
+
+	phw.Write([]byte("abc"))
+	phw.Write([]byte("defghij"))
+	phw.finishPiece()
+
+	lengths := make([]uint64, len(phw.pieces))
+	for i, piece := range phw.pieces {
+		lengths[i] = piece.Length
+	}
+
+	if reflect.DeepEqual(lengths, []uint64{4, 4, 2}) != true {
+		t.Fatalf("Piece lengths not correct: %v", lengths)
+	}
+
+	digest := sha1.Sum([]byte("efgh"))
+	if bytes.Equal(phw.pieces[1].Digest, digest[:]) != true {
+		t.Fatalf("Piece digest not correct.")
+	}
+}
@@ -0,0 +1,53 @@
+// This package supports detecting exFAT volumes cheaply.
+
+package exfat
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/dsoprea/go-logging"
+	"github.com/go-restruct/restruct"
+)
+
+// Probe indicates whether an exFAT volume starts at the given offset, by
+// reading and checking only its main boot sector. This is meant for
+// detection layers that choose between several filesystem parsers, and costs
+// a single read. If it is exFAT, the parsed boot-sector header is returned.
+// Neither the checksum nor anything past the boot sector is checked, so the
+// volume can still fail to parse. `err` is only returned if the read fails;
+// a short image is just not exFAT.
+func Probe(r io.ReaderAt, offset int64) (isExfat bool, bsh *BootSectorHeader, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	raw := make([]byte, bootSectorHeaderSize)
+
+	n, err := r.ReadAt(raw, offset)
+	if n < len(raw) {
+		if err == nil || err == io.EOF {
+			return false, nil, nil
+		}
+
+		log.Panic(err)
+	}
+
+	// Check the signatures before doing any real parsing.
+	if bytes.Equal(raw[0:3], requiredJumpBootSignature) == false || bytes.Equal(raw[3:11], requiredFileSystemName) == false {
+		return false, nil, nil
+	}
+
+	bsh = new(BootSectorHeader)
+
+	err = restruct.Unpack(raw, defaultEncoding, bsh)
+	log.PanicIf(err)
+
+	if validateBootSectorHeader(*bsh) != nil {
+		return false, nil, nil
+	}
+
+	return true, bsh, nil
+}
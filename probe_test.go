@@ -0,0 +1,53 @@
+package exfat
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/dsoprea/go-logging"
+)
+
+func TestProbe(t *testing.T) {
+	volume, _ := getTestImage()
+
+	image := append(make([]byte, 1024), volume...)
+
+	isExfat, bsh, err := Probe(bytes.NewReader(image), 1024)
+	log.PanicIf(err)
+
+	if isExfat != true {
+		t.Fatalf("Expected exFAT.")
+	} else if bsh.ClusterCount != 239 || bsh.SectorSize() != 512 {
+		t.Fatalf("Boot-sector header not correct: %s", bsh)
+	}
+
+	isExfat, bsh, err = Probe(bytes.NewReader(image), 0)
+	log.PanicIf(err)
+
+	if isExfat != false || bsh != nil {
+		t.Fatalf("Expected no exFAT at the start.")
+	}
+
+	// Too short to hold a boot sector.
+
+	isExfat, _, err = Probe(bytes.NewReader(image), int64(len(image))-100)
+	log.PanicIf(err)
+
+	if isExfat != false {
+		t.Fatalf("Expected no exFAT at the end.")
+	}
+}
+
+func TestProbe__InvalidGeometry(t *testing.T) {
+	volume, _ := getTestImage()
+
+	// BytesPerSectorShift.
+	volume[108] = 20
+
+	isExfat, _, err := Probe(bytes.NewReader(volume), 0)
+	log.PanicIf(err)
+
+	if isExfat != false {
+		t.Fatalf("Expected invalid geometry to not be exFAT.")
+	}
+}
@@ -0,0 +1,223 @@
+// This package supports reading clusters ahead of when they're needed.
+
+package exfat
+
+import (
+	"io"
+	"sync"
+
+	"github.com/dsoprea/go-logging"
+)
+
+// SetReadAheadClusterCount sets the number of clusters that will be read, on a
+// background goroutine, ahead of the one that is currently being written when
+// streaming a cluster chain (e.g. `WriteFromClusterChain`). This is of benefit
+// when the underlying storage has a high latency (e.g. a network block
+// device). Zero (the default) disables read-ahead.
+func (er *ExfatReader) SetReadAheadClusterCount(clusterCount int) {
+	if clusterCount < 0 {
+		log.Panicf("read-ahead cluster-count can not be negative: (%d)", clusterCount)
+	}
+
+	er.readAheadClusterCount = clusterCount
+}
+
+// ReadAheadClusterCount returns the number of clusters that are read ahead.
+func (er *ExfatReader) ReadAheadClusterCount() int {
+	return er.readAheadClusterCount
+}
+
+// readInto reads the whole cluster into the given buffer with a single read.
+func (ec *ExfatCluster) readInto(data []byte) (err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	if uint32(len(data)) != ec.clusterSize {
+		log.Panicf("buffer is not the size of a cluster: (%d) != (%d)", len(data), ec.clusterSize)
+	}
+
+	err = ec.er.readAt(data, ec.clusterOffset)
+	log.PanicIf(err)
+
+	return nil
+}
+
+// readAheadCluster is one cluster that was read by the read-ahead goroutine.
+type readAheadCluster struct {
+	clusterNumber uint32
+	data          *[]byte
+	err           error
+}
+
+// readAheadClusterChain reads `clusterCount` clusters from the chain starting
+// at the given cluster and sends them, in order, to the returned channel. No
+// more than `readAheadClusterCount` clusters will be waiting to be consumed.
+// The channel is closed when all clusters have been read, after an error has
+// been sent, or after `done` is closed.
+func (er *ExfatReader) readAheadClusterChain(firstClusterNumber uint32, clusterCount uint64, useFat bool, buffers *sync.Pool, done <-chan struct{}) <-chan readAheadCluster {
+	clusters := make(chan readAheadCluster, er.readAheadClusterCount)
+
+	lastClusterNumber := uint64(er.bootRegion.bsh.ClusterCount) + 1
+
+	go func() {
+		defer close(clusters)
+
+		currentClusterNumber := firstClusterNumber
+		previousClusterNumber := uint32(0)
+		for i := uint64(0); i < clusterCount; i++ {
+			rac := readAheadCluster{
+				clusterNumber: currentClusterNumber,
+			}
+
+			// The same checks as enumerateClusters().
+			if currentClusterNumber < 2 {
+				rac.err = er.newFatCorruptionError(previousClusterNumber, log.Errorf("cluster-number too low: (%d)", currentClusterNumber))
+			} else if uint64(currentClusterNumber) > lastClusterNumber {
+				if useFat == true {
+					rac.err = er.newFatCorruptionError(previousClusterNumber, ErrClusterOutOfRange)
+				} else {
+					ce := newCorruptionError("cluster chain", ErrClusterOutOfRange)
+					ce.ClusterNumber = firstClusterNumber
+
+					rac.err = ce
+				}
+			} else {
+				rac.data = buffers.Get().(*[]byte)
+
+				ec := er.GetCluster(currentClusterNumber)
+				rac.err = ec.readInto(*rac.data)
+			}
+
+			if rac.err == nil && i < clusterCount-1 {
+				var isLast bool
+
+				previousClusterNumber = currentClusterNumber
+
+				currentClusterNumber, isLast, rac.err = er.nextClusterNumber(currentClusterNumber, useFat)
+				if rac.err == nil && isLast == true {
+					rac.err = er.newFatCorruptionError(previousClusterNumber, log.Errorf("cluster chain ended early: (%d) < (%d)", i+1, clusterCount))
+				}
+			}
+
+			select {
+			case clusters <- rac:
+			case <-done:
+				return
+			}
+
+			if rac.err != nil {
+				return
+			}
+		}
+	}()
+
+	return clusters
+}
+
+// writeFromClusterChainWithReadAhead has the same behavior as
+// `WriteFromClusterChain` but reads whole clusters ahead of time on a
+// background goroutine.
+func (er *ExfatReader) writeFromClusterChainWithReadAhead(firstClusterNumber uint32, dataSize uint64, useFat bool, w io.Writer) (visitedClusters, visitedSectors []uint32, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	// Empty files usually don't have a chain at all (the first cluster is
+	// zero), so there's nothing to read ahead.
+	if dataSize == 0 {
+		return []uint32{}, []uint32{}, nil
+	} else if firstClusterNumber < 2 {
+		log.Panicf("cluster can not be less than (2): (%d)", firstClusterNumber)
+	}
+
+	sectorSize := er.SectorSize()
+	sectorsPerCluster := er.SectorsPerCluster()
+	clusterSize := uint64(sectorSize * sectorsPerCluster)
+	tailFragmentSize := dataSize % uint64(sectorSize)
+
+	clusterCount := (dataSize + clusterSize - 1) / clusterSize
+
+	buffers := er.clusterBuffers()
+
+	done := make(chan struct{})
+	clusters := er.readAheadClusterChain(firstClusterNumber, clusterCount, useFat, buffers, done)
+
+	// Don't return until the goroutine has let go of the reader.
+	defer func() {
+		close(done)
+
+		for rac := range clusters {
+			if rac.data != nil {
+				buffers.Put(rac.data)
+			}
+		}
+	}()
+
+	written := uint64(0)
+	sectorCount := uint32(0)
+
+	visitedClusters = make([]uint32, 0, clusterCount)
+	visitedSectors = make([]uint32, 0, clusterCount*uint64(sectorsPerCluster))
+
+	doContinue := true
+	for doContinue == true {
+		rac, ok := <-clusters
+		if ok == false {
+			break
+		}
+
+		if rac.err != nil {
+			if rac.data != nil {
+				buffers.Put(rac.data)
+			}
+
+			log.Panic(rac.err)
+		}
+
+		visitedClusters = append(visitedClusters, rac.clusterNumber)
+
+		for i := uint32(0); i < sectorsPerCluster; i++ {
+			sectorNumber := er.bootRegion.bsh.ClusterHeapOffset + rac.clusterNumber + i
+			visitedSectors = append(visitedSectors, sectorNumber)
+
+			data := (*rac.data)[i*sectorSize : (i+1)*sectorSize]
+
+			// If we're in the last sector.
+			if (uint64(sectorCount)+1)*uint64(sectorSize) >= dataSize {
+				// If we're in the last sector and the file-size is not an
+				// exact multiple of sectors.
+				if tailFragmentSize > 0 {
+					data = data[:tailFragmentSize]
+				}
+
+				doContinue = false
+			}
+
+			_, err = w.Write(data)
+			if err != nil {
+				buffers.Put(rac.data)
+				log.Panic(err)
+			}
+
+			written += uint64(len(data))
+			sectorCount++
+
+			if doContinue == false {
+				break
+			}
+		}
+
+		buffers.Put(rac.data)
+	}
+
+	if written != dataSize {
+		log.Panicf("written bytes do not equal data-size: (%d) != (%d)", written, dataSize)
+	}
+
+	return visitedClusters, visitedSectors, nil
+}
@@ -0,0 +1,159 @@
+package exfat
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"reflect"
+	"testing"
+
+	"github.com/dsoprea/go-logging"
+)
+
+func TestExfatReader_WriteFromClusterChain__ReadAhead(t *testing.T) {
+	tree, closer := getTestTree()
+
+	defer closer()
+
+	volumePaths := []string{
+		"2-delahaye-type-165-cabriolet-dsc_8025.jpg",
+		`testdirectory\300daec8-cec3-11e9-bfa2-0f240e41d1d8`,
+	}
+
+	for _, volumePath := range volumePaths {
+		node, err := tree.LookupPath(volumePath)
+		log.PanicIf(err)
+
+		sede := node.StreamDirectoryEntry()
+		useFat := sede.GeneralSecondaryFlags.NoFatChain() == false
+
+		tree.er.SetReadAheadClusterCount(0)
+
+		expectedBuffer := new(bytes.Buffer)
+
+		expectedClusters, expectedSectors, err := tree.er.WriteFromClusterChain(sede.FirstCluster, sede.ValidDataLength, useFat, expectedBuffer)
+		log.PanicIf(err)
+
+		for _, clusterCount := range []int{1, 4, 1000} {
+			tree.er.SetReadAheadClusterCount(clusterCount)
+
+			actualBuffer := new(bytes.Buffer)
+
+			actualClusters, actualSectors, err := tree.er.WriteFromClusterChain(sede.FirstCluster, sede.ValidDataLength, useFat, actualBuffer)
+			log.PanicIf(err)
+
+			if bytes.Equal(actualBuffer.Bytes(), expectedBuffer.Bytes()) != true {
+				t.Fatalf("Read-ahead data not correct: [%s] (%d)", volumePath, clusterCount)
+			} else if reflect.DeepEqual(actualClusters, expectedClusters) != true {
+				t.Fatalf("Read-ahead clusters not correct: [%s] (%d)", volumePath, clusterCount)
+			} else if reflect.DeepEqual(actualSectors, expectedSectors) != true {
+				t.Fatalf("Read-ahead sectors not correct: [%s] (%d)", volumePath, clusterCount)
+			}
+		}
+	}
+}
+
+type failingWriter struct {
+	remaining int
+}
+
+func (fw *failingWriter) Write(data []byte) (n int, err error) {
+	if fw.remaining <= 0 {
+		return 0, errors.New("write failed")
+	}
+
+	fw.remaining--
+
+	return len(data), nil
+}
+
+func TestExfatReader_WriteFromClusterChain__ReadAhead_WriteError(t *testing.T) {
+	tree, closer := getTestTree()
+
+	defer closer()
+
+	node, err := tree.LookupPath("2-delahaye-type-165-cabriolet-dsc_8025.jpg")
+	log.PanicIf(err)
+
+	sede := node.StreamDirectoryEntry()
+	useFat := sede.GeneralSecondaryFlags.NoFatChain() == false
+
+	tree.er.SetReadAheadClusterCount(4)
+
+	fw := &failingWriter{
+		remaining: 20,
+	}
+
+	_, _, err = tree.er.WriteFromClusterChain(sede.FirstCluster, sede.ValidDataLength, useFat, fw)
+	if err == nil {
+		t.Fatalf("Expected error from writer.")
+	} else if err.Error() != "write failed" {
+		t.Fatalf("Error not correct: [%s]", err.Error())
+	}
+
+	// The reader must still be usable afterwards.
+
+	tree.er.SetReadAheadClusterCount(0)
+
+	_, data := getTestFileData(tree, "2-delahaye-type-165-cabriolet-dsc_8025.jpg")
+	if len(data) != 313299 {
+		t.Fatalf("Data not correct after failed read-ahead: (%d)", len(data))
+	}
+}
+
+func TestExfatReader_WriteFromClusterChain__ReadAhead_OutOfHeap(t *testing.T) {
+	tree, closer := getTestTree()
+
+	defer closer()
+
+	er := tree.er
+	er.SetReadAheadClusterCount(4)
+
+	// The chain would run two clusters past the end of the heap.
+	lastClusterNumber := er.ActiveBootSectorHeader().ClusterCount + 1
+	dataSize := 3 * uint64(er.ActiveBootSectorHeader().ClusterSize())
+
+	_, _, err := er.WriteFromClusterChain(lastClusterNumber, dataSize, false, ioutil.Discard)
+	if err == nil {
+		t.Fatalf("Expected error for a chain that leaves the heap.")
+	} else if errors.Is(err, ErrClusterOutOfRange) != true {
+		t.Fatalf("Expected out-of-range error: [%v]", err)
+	}
+}
+
+func TestExfatReader_WriteFromClusterChain__ReadAhead_Empty(t *testing.T) {
+	f, closer := getTestNewImage()
+
+	defer closer()
+
+	ew, err := NewExfatWriter(f, 4*1024*1024, FormatOptions{})
+	log.PanicIf(err)
+
+	err = ew.CreateFile([]string{"empty"}, bytes.NewReader(nil), 0, FileMetadata{})
+	log.PanicIf(err)
+
+	err = ew.Close()
+	log.PanicIf(err)
+
+	er, _ := getTestParsedImage(f)
+	er.SetReadAheadClusterCount(4)
+
+	tree := NewTree(er)
+
+	node, err := tree.Lookup([]string{"empty"})
+	log.PanicIf(err)
+
+	sede := node.StreamDirectoryEntry()
+	useFat := sede.GeneralSecondaryFlags.NoFatChain() == false
+
+	b := new(bytes.Buffer)
+
+	visitedClusters, visitedSectors, err := er.WriteFromClusterChain(sede.FirstCluster, sede.ValidDataLength, useFat, b)
+	log.PanicIf(err)
+
+	if b.Len() != 0 {
+		t.Fatalf("Expected no data: (%d)", b.Len())
+	} else if len(visitedClusters) != 0 || len(visitedSectors) != 0 {
+		t.Fatalf("Expected no clusters or sectors to be visited: %v %v", visitedClusters, visitedSectors)
+	}
+}
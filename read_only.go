@@ -0,0 +1,106 @@
+// This package supports guaranteeing that an image is never modified.
+
+package exfat
+
+import (
+	"errors"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/dsoprea/go-logging"
+)
+
+var (
+	// ErrReadOnly indicates an attempt to write to an image that was opened
+	// with NewReadOnly().
+	ErrReadOnly = errors.New("image is read-only")
+)
+
+// ReadOnlyImage wraps an image so that nothing can write to it, whatever the
+// underlying type supports. The underlying ReadSeeker is not exposed, so
+// type-assertions (e.g. for io.WriterAt) can't get around it. Any write
+// returns ErrReadOnly.
+type ReadOnlyImage struct {
+	rs io.ReadSeeker
+
+	// ra is the same as `rs` if it also supports random-access reads.
+	ra io.ReaderAt
+
+	// locker serializes ReadAt() calls when `ra` is not available.
+	locker sync.Mutex
+}
+
+// NewReadOnly returns a ReadSeeker for the given image that can be passed to
+// NewExfatReader() with the guarantee that the image won't be modified (e.g.
+// for evidence images). Anything that would write to the volume (e.g.
+// SetDirty(), SetVolumeLabel(), or WriteBootRegion()) fails with ErrReadOnly.
+func NewReadOnly(rs io.ReadSeeker) *ReadOnlyImage {
+	roi := &ReadOnlyImage{
+		rs: rs,
+	}
+
+	if ra, ok := rs.(io.ReaderAt); ok == true {
+		roi.ra = ra
+	}
+
+	return roi
+}
+
+// Read reads from the current position.
+func (roi *ReadOnlyImage) Read(p []byte) (n int, err error) {
+	return roi.rs.Read(p)
+}
+
+// Seek sets the current position.
+func (roi *ReadOnlyImage) Seek(offset int64, whence int) (position int64, err error) {
+	return roi.rs.Seek(offset, whence)
+}
+
+// ReadAt reads from the given offset. If the underlying image doesn't support
+// random-access reads, this seeks, reads, and restores the current position.
+func (roi *ReadOnlyImage) ReadAt(p []byte, offset int64) (n int, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	if roi.ra != nil {
+		return roi.ra.ReadAt(p, offset)
+	}
+
+	roi.locker.Lock()
+	defer roi.locker.Unlock()
+
+	currentOffset, err := roi.rs.Seek(0, os.SEEK_CUR)
+	log.PanicIf(err)
+
+	_, err = roi.rs.Seek(offset, os.SEEK_SET)
+	log.PanicIf(err)
+
+	n, readErr := io.ReadFull(roi.rs, p)
+	if readErr == io.ErrUnexpectedEOF {
+		readErr = io.EOF
+	}
+
+	_, err = roi.rs.Seek(currentOffset, os.SEEK_SET)
+	log.PanicIf(err)
+
+	return n, readErr
+}
+
+// Write always fails with ErrReadOnly.
+func (roi *ReadOnlyImage) Write(p []byte) (n int, err error) {
+	return 0, ErrReadOnly
+}
+
+// WriteAt always fails with ErrReadOnly.
+func (roi *ReadOnlyImage) WriteAt(p []byte, offset int64) (n int, err error) {
+	return 0, ErrReadOnly
+}
+
+// Truncate always fails with ErrReadOnly.
+func (roi *ReadOnlyImage) Truncate(size int64) (err error) {
+	return ErrReadOnly
+}
@@ -0,0 +1,110 @@
+package exfat
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/dsoprea/go-logging"
+)
+
+func TestNewReadOnly(t *testing.T) {
+	f, closer := getTestWritableImage()
+
+	defer closer()
+
+	original, _ := getTestImage()
+
+	roi := NewReadOnly(f)
+
+	er := NewExfatReader(roi)
+
+	err := er.Parse()
+	log.PanicIf(err)
+
+	tree := NewTree(er)
+
+	err = tree.Load()
+	log.PanicIf(err)
+
+	node, err := tree.LookupPath("79c6d31a-cca1-11e9-8325-9746d045e868")
+	log.PanicIf(err)
+
+	err = node.WriteData(ioutil.Discard, false)
+	log.PanicIf(err)
+
+	// Every write path fails.
+
+	err = er.SetDirty(true)
+	if log.Is(err, ErrReadOnly) != true {
+		t.Fatalf("Expected read-only error for SetDirty: [%v]", err)
+	}
+
+	err = er.WriteBootRegion(er.ActiveBootSectorHeader())
+	if log.Is(err, ErrReadOnly) != true {
+		t.Fatalf("Expected read-only error for WriteBootRegion: [%v]", err)
+	}
+
+	err = SetVolumeLabel(roi, "EVIDENCE")
+	if log.Is(err, ErrReadOnly) != true {
+		t.Fatalf("Expected read-only error for SetVolumeLabel: [%v]", err)
+	}
+
+	_, err = NewExfatWriter(roi, 1024*1024, FormatOptions{})
+	if log.Is(err, ErrReadOnly) != true {
+		t.Fatalf("Expected read-only error for NewExfatWriter: [%v]", err)
+	}
+
+	var rs io.ReadSeeker = roi
+	if _, ok := rs.(*os.File); ok == true {
+		t.Fatalf("Underlying image should not be exposed.")
+	}
+
+	current, err := ioutil.ReadFile(f.Name())
+	log.PanicIf(err)
+
+	if bytes.Equal(current, original) != true {
+		t.Fatalf("Image was modified.")
+	}
+}
+
+func TestReadOnlyImage_ReadAt__Sequential(t *testing.T) {
+	data := []byte("0123456789")
+
+	// Hide ReadAt() so that it has to be emulated.
+	rs := struct {
+		io.ReadSeeker
+	}{
+		bytes.NewReader(data),
+	}
+
+	roi := NewReadOnly(rs)
+
+	_, err := roi.Seek(2, os.SEEK_SET)
+	log.PanicIf(err)
+
+	b := make([]byte, 3)
+
+	n, err := roi.ReadAt(b, 5)
+	log.PanicIf(err)
+
+	if n != 3 || string(b) != "567" {
+		t.Fatalf("ReadAt not correct: (%d) [%s]", n, b)
+	}
+
+	// The position is restored.
+
+	n, err = roi.Read(b)
+	log.PanicIf(err)
+
+	if n != 3 || string(b) != "234" {
+		t.Fatalf("Read not correct: (%d) [%s]", n, b)
+	}
+
+	n, err = roi.ReadAt(b, 8)
+	if err != io.EOF || n != 2 || string(b[:2]) != "89" {
+		t.Fatalf("Expected short read at the end: (%d) [%v]", n, err)
+	}
+}
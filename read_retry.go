@@ -0,0 +1,181 @@
+// This package supports reading from failing media by retrying reads and, if
+// asked, zero-filling the sectors that still can't be read.
+
+package exfat
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/dsoprea/go-logging"
+)
+
+// ReadRetryPolicy determines what happens when a read from the image fails.
+// The zero value fails immediately, which is the default. This applies to
+// every read after the boot region; reads served from a memory-mapping can't
+// be retried.
+type ReadRetryPolicy struct {
+	// Retries is the number of times that a failed read is attempted again.
+	Retries int
+
+	// Backoff is how long to wait before the first retry. It doubles for
+	// each retry after that.
+	Backoff time.Duration
+
+	// ZeroFill has a read that still fails after the retries be reattempted
+	// one sector at a time, with the sectors that can't be read filled with
+	// zeros rather than failing. They are recorded (see UnreadableRanges())
+	// so that they can be reported. This allows an image of a damaged card to
+	// be browsed and extracted from.
+	ZeroFill bool
+}
+
+// String returns a descriptive string.
+func (rrp ReadRetryPolicy) String() string {
+	return fmt.Sprintf("ReadRetryPolicy<RETRIES=(%d) BACKOFF=[%s] ZERO-FILL=[%v]>", rrp.Retries, rrp.Backoff, rrp.ZeroFill)
+}
+
+// UnreadableRange is a range of the image that couldn't be read and was
+// zero-filled.
+type UnreadableRange struct {
+	// Offset is the offset of the range in the image.
+	Offset int64
+
+	// Length is the size of the range.
+	Length int64
+
+	// Err is the last error that was encountered in the range.
+	Err error
+}
+
+// String returns a descriptive string.
+func (ur UnreadableRange) String() string {
+	return fmt.Sprintf("UnreadableRange<OFFSET=(%d) LENGTH=(%d) ERROR=[%v]>", ur.Offset, ur.Length, ur.Err)
+}
+
+// SetReadRetryPolicy sets what happens when a read from the image fails.
+func (er *ExfatReader) SetReadRetryPolicy(policy ReadRetryPolicy) {
+	if policy.Retries < 0 {
+		log.Panicf("read retry-count can not be negative: (%d)", policy.Retries)
+	}
+
+	er.readRetryPolicy = policy
+}
+
+// ReadRetryPolicy returns the policy that applies when a read fails.
+func (er *ExfatReader) ReadRetryPolicy() ReadRetryPolicy {
+	return er.readRetryPolicy
+}
+
+// UnreadableRanges returns the ranges of the image that couldn't be read and
+// were zero-filled, in the order that they were found. Adjacent ranges are
+// merged. This is always empty unless the policy has ZeroFill set.
+func (er *ExfatReader) UnreadableRanges() []UnreadableRange {
+	er.unreadableRangesLocker.Lock()
+	defer er.unreadableRangesLocker.Unlock()
+
+	ranges := make([]UnreadableRange, len(er.unreadableRanges))
+	copy(ranges, er.unreadableRanges)
+
+	return ranges
+}
+
+// addUnreadableRange records a range that was zero-filled.
+func (er *ExfatReader) addUnreadableRange(offset, length int64, cause error) {
+	er.unreadableRangesLocker.Lock()
+	defer er.unreadableRangesLocker.Unlock()
+
+	if len(er.unreadableRanges) > 0 {
+		last := &er.unreadableRanges[len(er.unreadableRanges)-1]
+
+		if last.Offset+last.Length == offset {
+			last.Length += length
+			last.Err = cause
+
+			return
+		}
+	}
+
+	ur := UnreadableRange{
+		Offset: offset,
+		Length: length,
+		Err:    cause,
+	}
+
+	er.unreadableRanges = append(er.unreadableRanges, ur)
+}
+
+// readAtWithRetries reads with as many attempts as the policy allows.
+func (er *ExfatReader) readAtWithRetries(data []byte, offset int64) (err error) {
+	err = er.readAtOnce(data, offset)
+	if err == nil {
+		return nil
+	}
+
+	backoff := er.readRetryPolicy.Backoff
+
+	for i := 0; i < er.readRetryPolicy.Retries; i++ {
+		er.debug(EventReadRetry, "offset", offset, "size", len(data), "attempt", i+1, "error", err)
+
+		if backoff > 0 {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+
+		err = er.readAtOnce(data, offset)
+		if err == nil {
+			return nil
+		}
+	}
+
+	return err
+}
+
+// readAt fills the buffer from the given absolute offset, applying the retry
+// policy. This is safe to call concurrently once the structures have been
+// parsed.
+func (er *ExfatReader) readAt(data []byte, offset int64) (err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	err = er.readAtWithRetries(data, offset)
+	if err == nil {
+		return nil
+	} else if er.readRetryPolicy.ZeroFill == false {
+		log.Panic(err)
+	}
+
+	// Salvage what we can, one sector at a time.
+
+	sectorSize := int64(minimumSectorSize)
+	if er.bootRegion.bsh.BytesPerSectorShift != 0 {
+		sectorSize = int64(er.bootRegion.bsh.SectorSize())
+	}
+
+	for position := int64(0); position < int64(len(data)); {
+		// Stay aligned to sectors, in case the read wasn't.
+		chunkSize := sectorSize - (offset+position)%sectorSize
+		if position+chunkSize > int64(len(data)) {
+			chunkSize = int64(len(data)) - position
+		}
+
+		chunk := data[position : position+chunkSize]
+
+		err := er.readAtWithRetries(chunk, offset+position)
+		if err != nil {
+			for i := range chunk {
+				chunk[i] = 0
+			}
+
+			er.debug(EventReadZeroFill, "offset", offset+position, "size", chunkSize, "error", err)
+			er.addUnreadableRange(offset+position, chunkSize, err)
+		}
+
+		position += chunkSize
+	}
+
+	return nil
+}
@@ -0,0 +1,181 @@
+package exfat
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/dsoprea/go-logging"
+)
+
+var (
+	errTestReadFailed = errors.New("read failed")
+)
+
+// testFlakyReader fails reads that touch a given range, either a number of
+// times or always.
+type testFlakyReader struct {
+	*bytes.Reader
+
+	badOffset int64
+	badLength int64
+
+	// failuresLeft is the number of reads of the bad range that will fail.
+	// Negative fails forever.
+	failuresLeft int
+
+	attempts int
+}
+
+func (tfr *testFlakyReader) ReadAt(p []byte, offset int64) (n int, err error) {
+	if offset < tfr.badOffset+tfr.badLength && offset+int64(len(p)) > tfr.badOffset {
+		tfr.attempts++
+
+		if tfr.failuresLeft != 0 {
+			if tfr.failuresLeft > 0 {
+				tfr.failuresLeft--
+			}
+
+			return 0, errTestReadFailed
+		}
+	}
+
+	return tfr.Reader.ReadAt(p, offset)
+}
+
+func getTestFlakyReader(failuresLeft int) (tfr *testFlakyReader, image []byte) {
+	image, _ = getTestImage()
+
+	// The second sector of the data of the JPG (cluster 7).
+	clusterHeapOffset := int64(136 * 512)
+	badOffset := clusterHeapOffset + int64(7-2)*4096 + 512
+
+	tfr = &testFlakyReader{
+		Reader:       bytes.NewReader(image),
+		badOffset:    badOffset,
+		badLength:    512,
+		failuresLeft: failuresLeft,
+	}
+
+	return tfr, image
+}
+
+func TestExfatReader_readAt__NoRetries(t *testing.T) {
+	tfr, _ := getTestFlakyReader(1)
+
+	er := NewExfatReader(tfr)
+
+	err := er.readAt(make([]byte, 4096), tfr.badOffset)
+	if err == nil || errors.Is(err, errTestReadFailed) != true {
+		t.Fatalf("Expected the read error: [%v]", err)
+	}
+}
+
+func TestExfatReader_readAt__Retries(t *testing.T) {
+	tfr, image := getTestFlakyReader(2)
+
+	er := NewExfatReader(tfr)
+
+	rrp := ReadRetryPolicy{
+		Retries: 2,
+	}
+
+	er.SetReadRetryPolicy(rrp)
+
+	tl := new(testLogger)
+	er.SetLogger(tl)
+
+	data := make([]byte, 4096)
+
+	err := er.readAt(data, tfr.badOffset)
+	log.PanicIf(err)
+
+	if bytes.Equal(data, image[tfr.badOffset:tfr.badOffset+4096]) != true {
+		t.Fatalf("Data not correct.")
+	} else if tfr.attempts != 3 {
+		t.Fatalf("Attempt count not correct: (%d)", tfr.attempts)
+	} else if len(er.UnreadableRanges()) != 0 {
+		t.Fatalf("Expected no unreadable ranges.")
+	} else if len(tl.find(EventReadRetry)) != 2 {
+		t.Fatalf("Expected two retry events: %v", tl.events)
+	}
+}
+
+func TestExfatReader_readAt__ZeroFill(t *testing.T) {
+	tfr, image := getTestFlakyReader(-1)
+
+	er := NewExfatReader(tfr)
+
+	rrp := ReadRetryPolicy{
+		Retries:  1,
+		ZeroFill: true,
+	}
+
+	er.SetReadRetryPolicy(rrp)
+
+	tl := new(testLogger)
+	er.SetLogger(tl)
+
+	err := er.Parse()
+	log.PanicIf(err)
+
+	tree := NewTree(er)
+
+	node, err := tree.LookupPath("2-delahaye-type-165-cabriolet-dsc_8025.jpg")
+	log.PanicIf(err)
+
+	b := new(bytes.Buffer)
+
+	err = node.WriteData(b, false)
+	log.PanicIf(err)
+
+	actual := b.Bytes()
+
+	if len(actual) != 313299 {
+		t.Fatalf("Size not correct: (%d)", len(actual))
+	}
+
+	// Only the bad sector is zeroed.
+
+	dataOffset := tfr.badOffset - 512
+
+	if bytes.Equal(actual[:512], image[dataOffset:dataOffset+512]) != true {
+		t.Fatalf("Sector before the bad one not correct.")
+	} else if bytes.Equal(actual[512:1024], make([]byte, 512)) != true {
+		t.Fatalf("Bad sector not zero-filled.")
+	} else if bytes.Equal(actual[1024:4096], image[dataOffset+1024:dataOffset+4096]) != true {
+		t.Fatalf("Sectors after the bad one not correct.")
+	}
+
+	ranges := er.UnreadableRanges()
+	if len(ranges) != 1 {
+		t.Fatalf("Expected one unreadable range: %v", ranges)
+	}
+
+	ur := ranges[0]
+	if ur.Offset != tfr.badOffset || ur.Length != 512 || errors.Is(ur.Err, errTestReadFailed) != true {
+		t.Fatalf("Unreadable range not correct: %s", ur)
+	}
+
+	if len(tl.find(EventReadZeroFill)) != 1 {
+		t.Fatalf("Expected one zero-fill event: %v", tl.events)
+	}
+}
+
+func TestExfatReader_addUnreadableRange(t *testing.T) {
+	er := NewExfatReader(bytes.NewReader(nil))
+
+	er.addUnreadableRange(1024, 512, errTestReadFailed)
+	er.addUnreadableRange(1536, 512, errTestReadFailed)
+	er.addUnreadableRange(4096, 512, errTestReadFailed)
+
+	ranges := er.UnreadableRanges()
+
+	if len(ranges) != 2 {
+		t.Fatalf("Adjacent ranges not merged: %v", ranges)
+	} else if ranges[0].Offset != 1024 || ranges[0].Length != 1024 {
+		t.Fatalf("First range not correct: %s", ranges[0])
+	} else if ranges[1].Offset != 4096 || ranges[1].Length != 512 {
+		t.Fatalf("Second range not correct: %s", ranges[1])
+	}
+}
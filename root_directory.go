@@ -0,0 +1,113 @@
+// This package handles root directories that have no entries, as found on
+// some freshly-formatted or never-written volumes.
+
+package exfat
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/dsoprea/go-logging"
+)
+
+var (
+	// ErrEmptyRootDirectory is matched (with errors.Is) by the error that
+	// Parse() returns for a root directory without any entries when the
+	// policy is EmptyRootPolicyError.
+	ErrEmptyRootDirectory = errors.New("root directory is empty")
+)
+
+// EmptyRootPolicy determines how a root directory without any entries is
+// handled. Such a directory has its end-of-directory entry in its very first
+// record, is full of zeros, or (on erased flash that was never written) is full
+// of 0xFF.
+type EmptyRootPolicy int
+
+const (
+	// EmptyRootPolicyAllow treats the volume as empty. Parse() succeeds and
+	// the tree has no children, but anything that needs one of the mandatory
+	// root entries (e.g. ReadAllocationBitmap()) fails since it can't be
+	// found. This is the default.
+	EmptyRootPolicyAllow EmptyRootPolicy = iota
+
+	// EmptyRootPolicyError has Parse() fail with a CorruptionError that
+	// matches ErrEmptyRootDirectory, since the specification requires the
+	// root directory to have an allocation bitmap and an up-case table.
+	EmptyRootPolicyError
+)
+
+// String returns the name of the policy.
+func (erp EmptyRootPolicy) String() string {
+	switch erp {
+	case EmptyRootPolicyAllow:
+		return "allow"
+	case EmptyRootPolicyError:
+		return "error"
+	}
+
+	return fmt.Sprintf("EmptyRootPolicy(%d)", int(erp))
+}
+
+// SetEmptyRootPolicy sets how a root directory without any entries is
+// handled. Must be called before Parse().
+func (er *ExfatReader) SetEmptyRootPolicy(policy EmptyRootPolicy) {
+	er.emptyRootPolicy = policy
+}
+
+// EmptyRootPolicy returns how a root directory without any entries is
+// handled.
+func (er *ExfatReader) EmptyRootPolicy() EmptyRootPolicy {
+	return er.emptyRootPolicy
+}
+
+// IsRootDirectoryEmpty indicates whether the root directory has no entries
+// that are in use.
+func (er *ExfatReader) IsRootDirectoryEmpty() (isEmpty bool, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	en := NewExfatNavigator(er, er.FirstClusterOfRootDirectory())
+
+	isEmpty = true
+
+	cb := func(es *EntrySet) (err error) {
+		if es.IsInUse() == true {
+			isEmpty = false
+			return errStopEnumeration
+		}
+
+		return nil
+	}
+
+	_, _, err = en.EnumerateEntrySets(cb)
+	if err != nil && log.Is(err, errStopEnumeration) == false {
+		log.Panic(err)
+	}
+
+	return isEmpty, nil
+}
+
+// checkRootDirectoryNotEmpty fails if the root directory has no entries that
+// are in use.
+func (er *ExfatReader) checkRootDirectoryNotEmpty() (err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	isEmpty, err := er.IsRootDirectoryEmpty()
+	log.PanicIf(err)
+
+	if isEmpty == true {
+		ce := newCorruptionError("root directory", ErrEmptyRootDirectory)
+		ce.ClusterNumber = er.FirstClusterOfRootDirectory()
+
+		log.Panic(ce)
+	}
+
+	return nil
+}
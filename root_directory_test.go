@@ -0,0 +1,128 @@
+package exfat
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/dsoprea/go-logging"
+)
+
+// getTestEmptyRootImage returns the test image after the given function has
+// modified the first cluster of its root directory.
+func getTestEmptyRootImage(modify func(rootCluster []byte)) []byte {
+	image, er := getTestImage()
+
+	bsh := er.ActiveBootSectorHeader()
+
+	clusterSize := int64(bsh.ClusterSize())
+	offset := int64(bsh.ClusterHeapOffset)*int64(bsh.SectorSize()) + int64(er.FirstClusterOfRootDirectory()-2)*clusterSize
+
+	modify(image[offset : offset+clusterSize])
+
+	return image
+}
+
+func fillTestRootCluster(value byte) func(rootCluster []byte) {
+	return func(rootCluster []byte) {
+		for i := range rootCluster {
+			rootCluster[i] = value
+		}
+	}
+}
+
+func TestExfatReader_IsRootDirectoryEmpty(t *testing.T) {
+	f, er := getTestFileAndParser()
+
+	defer f.Close()
+
+	er.SetEmptyRootPolicy(EmptyRootPolicyError)
+
+	err := er.Parse()
+	log.PanicIf(err)
+
+	isEmpty, err := er.IsRootDirectoryEmpty()
+	log.PanicIf(err)
+
+	if isEmpty != false {
+		t.Fatalf("Expected the root directory to not be empty.")
+	}
+}
+
+func TestEmptyRootPolicy__Allow(t *testing.T) {
+	cases := map[string]func(rootCluster []byte){
+		"terminal-first": func(rootCluster []byte) {
+			rootCluster[0] = 0
+		},
+		"zeros":  fillTestRootCluster(0),
+		"erased": fillTestRootCluster(0xff),
+	}
+
+	for name, modify := range cases {
+		image := getTestEmptyRootImage(modify)
+
+		er := NewExfatReader(bytes.NewReader(image))
+
+		if er.EmptyRootPolicy() != EmptyRootPolicyAllow {
+			t.Fatalf("Policy should default to allow: [%s]", er.EmptyRootPolicy())
+		}
+
+		err := er.Parse()
+		log.PanicIf(err)
+
+		isEmpty, err := er.IsRootDirectoryEmpty()
+		log.PanicIf(err)
+
+		if isEmpty != true {
+			t.Fatalf("Expected the root directory to be empty: [%s]", name)
+		}
+
+		tree := NewTree(er)
+
+		err = tree.Load()
+		log.PanicIf(err)
+
+		if len(tree.rootNode.ChildFiles()) != 0 || len(tree.rootNode.ChildFolders()) != 0 {
+			t.Fatalf("Expected no children: [%s]", name)
+		}
+
+		// The mandatory entries can't be found.
+
+		_, err = er.ReadAllocationBitmap()
+		if err == nil {
+			t.Fatalf("Expected the allocation bitmap to be missing: [%s]", name)
+		}
+	}
+}
+
+func TestEmptyRootPolicy__Error(t *testing.T) {
+	image := getTestEmptyRootImage(fillTestRootCluster(0))
+
+	options := ParserOptions{
+		EmptyRootPolicy: EmptyRootPolicyError,
+	}
+
+	er := NewExfatReaderWithOptions(bytes.NewReader(image), options)
+
+	err := er.Parse()
+	if errors.Is(err, ErrEmptyRootDirectory) != true {
+		t.Fatalf("Expected empty-root error: [%v]", err)
+	}
+
+	var ce *CorruptionError
+	if errors.As(err, &ce) != true {
+		t.Fatalf("Expected a CorruptionError: [%v]", err)
+	} else if ce.ClusterNumber != 5 {
+		t.Fatalf("Cluster not correct: (%d)", ce.ClusterNumber)
+	}
+}
+
+func TestEmptyRootPolicy_String(t *testing.T) {
+	if EmptyRootPolicyAllow.String() != "allow" {
+		t.Fatalf("String not correct: [%s]", EmptyRootPolicyAllow)
+	} else if EmptyRootPolicyError.String() != "error" {
+		t.Fatalf("String not correct: [%s]", EmptyRootPolicyError)
+	} else if EmptyRootPolicy(99).String() != "EmptyRootPolicy(99)" {
+		t.Fatalf("String not correct: [%s]", EmptyRootPolicy(99))
+	}
+}
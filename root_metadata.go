@@ -0,0 +1,100 @@
+// This package collects the metadata entries of the root directory.
+
+package exfat
+
+import (
+	"fmt"
+
+	"github.com/dsoprea/go-logging"
+)
+
+// RootMetadata is the set of metadata entries that are only found in the root
+// directory. Entries that aren't in use are ignored. Entries that aren't
+// present are nil.
+type RootMetadata struct {
+	// AllocationBitmap is the bitmap for the active FAT.
+	AllocationBitmap *ExfatAllocationBitmapDirectoryEntry
+
+	// AllocationBitmaps are all of the bitmaps, in order. There are two on
+	// TexFAT volumes.
+	AllocationBitmaps []*ExfatAllocationBitmapDirectoryEntry
+
+	UpcaseTable *ExfatUpcaseTableDirectoryEntry
+	VolumeLabel *ExfatVolumeLabelDirectoryEntry
+	VolumeGuid  *ExfatVolumeGuidDirectoryEntry
+}
+
+// String returns a descriptive string.
+func (rm RootMetadata) String() string {
+	label := ""
+	if rm.VolumeLabel != nil {
+		label = rm.VolumeLabel.Label()
+	}
+
+	return fmt.Sprintf("RootMetadata<BITMAPS=(%d) HAS-UPCASE-TABLE=[%v] LABEL=[%s] HAS-GUID=[%v]>", len(rm.AllocationBitmaps), rm.UpcaseTable != nil, label, rm.VolumeGuid != nil)
+}
+
+// RootMetadata reads the root directory and returns its metadata entries.
+// This saves building a navigator on the root cluster and filtering the index
+// by type.
+func (er *ExfatReader) RootMetadata() (rm RootMetadata, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	en := NewExfatNavigator(er, er.FirstClusterOfRootDirectory())
+
+	index, _, _, err := en.IndexDirectoryEntries()
+	log.PanicIf(err)
+
+	// A label (or GUID) that was removed leaves an entry that is not in use
+	// behind.
+	isInUse := func(ide IndexedDirectoryEntry) bool {
+		return ide.EntrySet == nil || ide.EntrySet.IsInUse() == true
+	}
+
+	// The lowest bit of the BitmapFlags field is the BitmapIdentifier, which
+	// is set for the bitmap that goes with the second FAT (Section 7.1.2.1).
+	useSecond := er.ActiveBootSectorHeader().VolumeFlags.UseSecondFat()
+
+	rm.AllocationBitmaps = make([]*ExfatAllocationBitmapDirectoryEntry, 0)
+
+	for _, ide := range index["AllocationBitmap"] {
+		if isInUse(ide) == false {
+			continue
+		}
+
+		abde := ide.PrimaryEntry.(*ExfatAllocationBitmapDirectoryEntry)
+		rm.AllocationBitmaps = append(rm.AllocationBitmaps, abde)
+
+		isSecond := abde.BitmapFlags&1 == 1
+		if rm.AllocationBitmap == nil && isSecond == useSecond {
+			rm.AllocationBitmap = abde
+		}
+	}
+
+	for _, ide := range index["UpcaseTable"] {
+		if isInUse(ide) == true {
+			rm.UpcaseTable = ide.PrimaryEntry.(*ExfatUpcaseTableDirectoryEntry)
+			break
+		}
+	}
+
+	for _, ide := range index["VolumeLabel"] {
+		if isInUse(ide) == true {
+			rm.VolumeLabel = ide.PrimaryEntry.(*ExfatVolumeLabelDirectoryEntry)
+			break
+		}
+	}
+
+	for _, ide := range index["VolumeGuid"] {
+		if isInUse(ide) == true {
+			rm.VolumeGuid = ide.PrimaryEntry.(*ExfatVolumeGuidDirectoryEntry)
+			break
+		}
+	}
+
+	return rm, nil
+}
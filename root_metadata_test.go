@@ -0,0 +1,38 @@
+package exfat
+
+import (
+	"testing"
+
+	"github.com/dsoprea/go-logging"
+)
+
+func TestExfatReader_RootMetadata(t *testing.T) {
+	f, er := getTestFileAndParser()
+
+	defer f.Close()
+
+	err := er.Parse()
+	log.PanicIf(err)
+
+	rm, err := er.RootMetadata()
+	log.PanicIf(err)
+
+	if len(rm.AllocationBitmaps) != 1 {
+		t.Fatalf("Expected one allocation-bitmap: (%d)", len(rm.AllocationBitmaps))
+	} else if rm.AllocationBitmap != rm.AllocationBitmaps[0] {
+		t.Fatalf("Active allocation-bitmap not correct.")
+	} else if rm.AllocationBitmap.FirstCluster != 2 {
+		t.Fatalf("Allocation-bitmap first-cluster not correct: (%d)", rm.AllocationBitmap.FirstCluster)
+	} else if rm.UpcaseTable == nil {
+		t.Fatalf("Expected an up-case table.")
+	} else if rm.VolumeLabel == nil || rm.VolumeLabel.Label() != "testvolumelabel" {
+		t.Fatalf("Volume label not correct.")
+	} else if rm.VolumeGuid != nil {
+		t.Fatalf("Expected no volume GUID.")
+	}
+
+	expected := "RootMetadata<BITMAPS=(1) HAS-UPCASE-TABLE=[true] LABEL=[testvolumelabel] HAS-GUID=[false]>"
+	if rm.String() != expected {
+		t.Fatalf("String not correct: [%s]", rm.String())
+	}
+}
@@ -0,0 +1,199 @@
+// This package supports images that are split across several segment files.
+
+package exfat
+
+import (
+	"io"
+	"os"
+	"sort"
+
+	"github.com/dsoprea/go-logging"
+)
+
+// imageSegment is one of the files that make up a segmented image.
+type imageSegment struct {
+	f *os.File
+
+	// offset is where this segment starts in the whole image.
+	offset int64
+	size   int64
+}
+
+// SegmentedReader reads an image that was split across several segment files
+// (e.g. "image.001", "image.002", etc.., as produced by many imaging tools) as
+// though they were one, so that it can be passed to NewExfatReader(). It
+// supports io.ReadSeeker and io.ReaderAt, and ReadAt() is safe for concurrent
+// use. The segments are never written.
+type SegmentedReader struct {
+	segments []imageSegment
+	size     int64
+
+	// position is the offset for Read() and Seek().
+	position int64
+}
+
+// NewSegmentedReader opens the given segment files, in the given order, and
+// returns a reader for the image that they make up. Any segment may be empty.
+// Close() must be called when done.
+func NewSegmentedReader(paths ...string) (sr *SegmentedReader, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	if len(paths) == 0 {
+		log.Panicf("at least one segment is required")
+	}
+
+	sr = &SegmentedReader{
+		segments: make([]imageSegment, 0, len(paths)),
+	}
+
+	isDone := false
+
+	defer func() {
+		if isDone == false {
+			sr.Close()
+		}
+	}()
+
+	for _, filepath := range paths {
+		f, err := os.Open(filepath)
+		log.PanicIf(err)
+
+		fi, err := f.Stat()
+		if err != nil {
+			f.Close()
+			log.Panic(err)
+		}
+
+		segment := imageSegment{
+			f:      f,
+			offset: sr.size,
+			size:   fi.Size(),
+		}
+
+		sr.segments = append(sr.segments, segment)
+		sr.size += segment.size
+	}
+
+	isDone = true
+
+	return sr, nil
+}
+
+// Size returns the size of the whole image.
+func (sr *SegmentedReader) Size() int64 {
+	return sr.size
+}
+
+// SegmentCount returns the number of segment files.
+func (sr *SegmentedReader) SegmentCount() int {
+	return len(sr.segments)
+}
+
+// ReadAt reads from the given offset in the whole image, crossing from one
+// segment to the next as necessary.
+func (sr *SegmentedReader) ReadAt(data []byte, offset int64) (n int, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	if offset < 0 {
+		log.Panicf("offset can not be negative: (%d)", offset)
+	}
+
+	// Find the last segment that starts at or before the offset. Empty
+	// segments are skipped over by the loop below.
+	i := sort.Search(len(sr.segments), func(i int) bool {
+		return sr.segments[i].offset > offset
+	}) - 1
+
+	for n < len(data) {
+		current := offset + int64(n)
+		if current >= sr.size {
+			return n, io.EOF
+		}
+
+		segment := sr.segments[i]
+
+		if current >= segment.offset+segment.size {
+			i++
+			continue
+		}
+
+		segmentOffset := current - segment.offset
+
+		want := len(data) - n
+		if remaining := segment.size - segmentOffset; int64(want) > remaining {
+			want = int(remaining)
+		}
+
+		read, err := segment.f.ReadAt(data[n:n+want], segmentOffset)
+		n += read
+
+		// A segment that is shorter than it was when it was opened is an
+		// error rather than the end of the image.
+		if err == io.EOF && read == want {
+			err = nil
+		}
+
+		log.PanicIf(err)
+	}
+
+	return n, nil
+}
+
+// Read reads from the current position.
+func (sr *SegmentedReader) Read(data []byte) (n int, err error) {
+	n, err = sr.ReadAt(data, sr.position)
+	sr.position += int64(n)
+
+	if err == io.EOF && n > 0 {
+		err = nil
+	}
+
+	return n, err
+}
+
+// Seek moves the current position.
+func (sr *SegmentedReader) Seek(offset int64, whence int) (position int64, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	switch whence {
+	case os.SEEK_SET:
+		position = offset
+	case os.SEEK_CUR:
+		position = sr.position + offset
+	case os.SEEK_END:
+		position = sr.size + offset
+	default:
+		log.Panicf("whence not valid: (%d)", whence)
+	}
+
+	if position < 0 {
+		log.Panicf("position can not be negative: (%d)", position)
+	}
+
+	sr.position = position
+
+	return position, nil
+}
+
+// Close closes all of the segment files.
+func (sr *SegmentedReader) Close() (err error) {
+	for _, segment := range sr.segments {
+		if closeErr := segment.f.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}
+
+	return err
+}
@@ -0,0 +1,166 @@
+package exfat
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/dsoprea/go-logging"
+)
+
+// getTestSegmentedImage splits the test image into segment files of the given
+// sizes (the last segment gets the remainder).
+func getTestSegmentedImage(sizes ...int) (paths []string, image []byte, closer func()) {
+	image, _ = getTestImage()
+
+	tempPath, err := ioutil.TempDir("", "exfat-segments-")
+	log.PanicIf(err)
+
+	closer = func() {
+		os.RemoveAll(tempPath)
+	}
+
+	paths = make([]string, 0)
+
+	remaining := image
+	for i := 0; i <= len(sizes); i++ {
+		var segment []byte
+
+		if i < len(sizes) {
+			segment = remaining[:sizes[i]]
+			remaining = remaining[sizes[i]:]
+		} else {
+			segment = remaining
+		}
+
+		filepath := path.Join(tempPath, fmt.Sprintf("image.%03d", i+1))
+
+		err := ioutil.WriteFile(filepath, segment, 0644)
+		log.PanicIf(err)
+
+		paths = append(paths, filepath)
+	}
+
+	return paths, image, closer
+}
+
+func TestSegmentedReader_ReadAt(t *testing.T) {
+	paths, image, closer := getTestSegmentedImage(1000, 0, 300000, 1)
+	defer closer()
+
+	sr, err := NewSegmentedReader(paths...)
+	log.PanicIf(err)
+
+	defer sr.Close()
+
+	if sr.SegmentCount() != 5 {
+		t.Fatalf("Segment count not correct: (%d)", sr.SegmentCount())
+	} else if sr.Size() != int64(len(image)) {
+		t.Fatalf("Size not correct: (%d)", sr.Size())
+	}
+
+	for _, offset := range []int64{0, 999, 1000, 299999, 301000, 500000} {
+		data := make([]byte, 5000)
+
+		n, err := sr.ReadAt(data, offset)
+		log.PanicIf(err)
+
+		if n != len(data) {
+			t.Fatalf("Read count not correct at (%d): (%d)", offset, n)
+		} else if bytes.Equal(data, image[offset:offset+int64(len(data))]) != true {
+			t.Fatalf("Data not correct at (%d).", offset)
+		}
+	}
+
+	// Read past the end.
+
+	data := make([]byte, 100)
+
+	n, err := sr.ReadAt(data, int64(len(image))-10)
+	if err != io.EOF {
+		t.Fatalf("Expected EOF: [%v]", err)
+	} else if n != 10 {
+		t.Fatalf("Read count at end not correct: (%d)", n)
+	} else if bytes.Equal(data[:10], image[len(image)-10:]) != true {
+		t.Fatalf("Data at end not correct.")
+	}
+}
+
+func TestSegmentedReader_ReadAndSeek(t *testing.T) {
+	paths, image, closer := getTestSegmentedImage(512, 512)
+	defer closer()
+
+	sr, err := NewSegmentedReader(paths...)
+	log.PanicIf(err)
+
+	defer sr.Close()
+
+	position, err := sr.Seek(500, os.SEEK_SET)
+	log.PanicIf(err)
+
+	if position != 500 {
+		t.Fatalf("Position not correct: (%d)", position)
+	}
+
+	data := make([]byte, 600)
+
+	_, err = io.ReadFull(sr, data)
+	log.PanicIf(err)
+
+	if bytes.Equal(data, image[500:1100]) != true {
+		t.Fatalf("Data not correct.")
+	}
+
+	position, err = sr.Seek(-100, os.SEEK_END)
+	log.PanicIf(err)
+
+	if position != int64(len(image))-100 {
+		t.Fatalf("Position from end not correct: (%d)", position)
+	}
+
+	all, err := ioutil.ReadAll(sr)
+	log.PanicIf(err)
+
+	if bytes.Equal(all, image[len(image)-100:]) != true {
+		t.Fatalf("Data at end not correct.")
+	}
+}
+
+func TestSegmentedReader__Parse(t *testing.T) {
+	paths, image, closer := getTestSegmentedImage(100000, 100000, 100000)
+	defer closer()
+
+	sr, err := NewSegmentedReader(paths...)
+	log.PanicIf(err)
+
+	defer sr.Close()
+
+	er := NewExfatReader(sr)
+
+	err = er.Parse()
+	log.PanicIf(err)
+
+	actual, err := er.ReadFile("2-delahaye-type-165-cabriolet-dsc_8025.jpg")
+	log.PanicIf(err)
+
+	expected, err := ReadFile(bytes.NewReader(image), "2-delahaye-type-165-cabriolet-dsc_8025.jpg")
+	log.PanicIf(err)
+
+	if bytes.Equal(actual, expected) != true {
+		t.Fatalf("File read through segments not correct.")
+	}
+}
+
+func TestNewSegmentedReader__MissingSegment(t *testing.T) {
+	paths, _, closer := getTestSegmentedImage(1000)
+	defer closer()
+
+	_, err := NewSegmentedReader(paths[0], paths[1]+".missing")
+	if err == nil {
+		t.Fatalf("Expected error for missing segment.")
+	}
+}
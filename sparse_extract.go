@@ -0,0 +1,153 @@
+// This package supports extracting files without writing the regions that
+// hold no data.
+
+package exfat
+
+import (
+	"io"
+	"os"
+
+	"github.com/dsoprea/go-logging"
+)
+
+// truncater is implemented by writers (e.g. *os.File) whose size can be set
+// directly.
+type truncater interface {
+	Truncate(size int64) error
+}
+
+// WriteSparseData writes the whole data stream of the file (DataLength) to
+// `ws`, starting at its current position. The regions past the valid data
+// (ValidDataLength) and, if `ab` is not nil, the clusters that the allocation
+// bitmap doesn't mark as allocated are skipped by seeking rather than written.
+// On filesystems that support sparse files these become holes, which read
+// back as zeros (which is also what the spec says a read past the valid data
+// returns). `written` and `skipped` are the number of bytes that were written
+// and seeked over, respectively. The node must belong to a tree.
+func (tn *TreeNode) WriteSparseData(ws io.WriteSeeker, ab *AllocationBitmap) (written, skipped uint64, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	if tn.tree == nil {
+		log.Panicf("node does not belong to a tree: [%s]", tn.name)
+	} else if tn.isDirectory == true {
+		log.Panicf("node is a directory: [%s]", tn.name)
+	}
+
+	dataLength := tn.AllocatedSize()
+	if dataLength == 0 {
+		return 0, 0, nil
+	}
+
+	validDataLength := tn.Size()
+	if validDataLength > dataLength {
+		validDataLength = dataLength
+	}
+
+	startOffset, err := ws.Seek(0, os.SEEK_CUR)
+	log.PanicIf(err)
+
+	er := tn.tree.er
+	clusterSize := uint64(er.ActiveBootSectorHeader().ClusterSize())
+
+	position := uint64(0)
+	endsWithHole := false
+
+	clusterCb := func(ec *ExfatCluster) (doContinue bool, err error) {
+		defer func() {
+			if errRaw := recover(); errRaw != nil {
+				err = log.Wrap(errRaw.(error))
+			}
+		}()
+
+		clusterBytes := validDataLength - position
+		if clusterBytes > clusterSize {
+			clusterBytes = clusterSize
+		}
+
+		if ab != nil && ab.IsAllocated(ec.ClusterNumber()) == false {
+			_, err := ws.Seek(int64(clusterBytes), os.SEEK_CUR)
+			log.PanicIf(err)
+
+			skipped += clusterBytes
+			position += clusterBytes
+			endsWithHole = true
+
+			return position < validDataLength, nil
+		}
+
+		clusterEnd := position + clusterBytes
+		endsWithHole = false
+
+		sectorCb := func(sectorNumber uint32, data []byte) (doContinueSector bool, err error) {
+			defer func() {
+				if errRaw := recover(); errRaw != nil {
+					err = log.Wrap(errRaw.(error))
+				}
+			}()
+
+			if uint64(len(data)) > clusterEnd-position {
+				data = data[:clusterEnd-position]
+			}
+
+			_, err = ws.Write(data)
+			log.PanicIf(err)
+
+			written += uint64(len(data))
+			position += uint64(len(data))
+
+			return position < clusterEnd, nil
+		}
+
+		err = ec.EnumerateSectors(sectorCb)
+		log.PanicIf(err)
+
+		return position < validDataLength, nil
+	}
+
+	if validDataLength > 0 {
+		useFat := tn.sede.GeneralSecondaryFlags.NoFatChain() == false
+
+		err = er.EnumerateClustersLimited(tn.sede.FirstCluster, er.ClusterCountForLength(validDataLength), clusterCb, useFat)
+		log.PanicIf(err)
+
+		if position != validDataLength {
+			log.Panicf("cluster chain ended before the valid data: (%d) < (%d)", position, validDataLength)
+		}
+	}
+
+	// Everything past the valid data is a hole.
+
+	if dataLength > validDataLength {
+		skipped += dataLength - validDataLength
+		endsWithHole = true
+	}
+
+	if endsWithHole == false {
+		return written, skipped, nil
+	}
+
+	// Seeking doesn't extend the file by itself, so the end has to be set
+	// explicitly. If the writer can't be truncated, the last byte is written.
+
+	endOffset := startOffset + int64(dataLength)
+
+	if t, ok := ws.(truncater); ok == true {
+		err := t.Truncate(endOffset)
+		log.PanicIf(err)
+
+		_, err = ws.Seek(endOffset, os.SEEK_SET)
+		log.PanicIf(err)
+	} else {
+		_, err := ws.Seek(endOffset-1, os.SEEK_SET)
+		log.PanicIf(err)
+
+		_, err = ws.Write([]byte{0})
+		log.PanicIf(err)
+	}
+
+	return written, skipped, nil
+}
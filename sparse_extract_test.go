@@ -0,0 +1,170 @@
+package exfat
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/dsoprea/go-logging"
+)
+
+// testWriteSeeker is an in-memory io.WriteSeeker that can't be truncated.
+type testWriteSeeker struct {
+	data     []byte
+	position int64
+}
+
+func (tws *testWriteSeeker) Write(p []byte) (n int, err error) {
+	end := tws.position + int64(len(p))
+	if end > int64(len(tws.data)) {
+		tws.data = append(tws.data, make([]byte, end-int64(len(tws.data)))...)
+	}
+
+	copy(tws.data[tws.position:], p)
+	tws.position = end
+
+	return len(p), nil
+}
+
+func (tws *testWriteSeeker) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case os.SEEK_SET:
+		tws.position = offset
+	case os.SEEK_CUR:
+		tws.position += offset
+	default:
+		return 0, errors.New("whence not supported")
+	}
+
+	return tws.position, nil
+}
+
+// getTestSparseNode returns the large image from the test volume, along with
+// its data.
+func getTestSparseNode() (tree *Tree, node *TreeNode, data []byte, closer func()) {
+	tree, closer = getTestTree()
+
+	node, err := tree.Lookup([]string{"2-delahaye-type-165-cabriolet-dsc_8025.jpg"})
+	log.PanicIf(err)
+
+	b := new(bytes.Buffer)
+
+	err = node.WriteData(b, false)
+	log.PanicIf(err)
+
+	return tree, node, b.Bytes(), closer
+}
+
+func TestTreeNode_WriteSparseData__ValidDataLength(t *testing.T) {
+	_, node, data, closer := getTestSparseNode()
+
+	defer closer()
+
+	node.sede.ValidDataLength = 100000
+
+	f, err := ioutil.TempFile("", "exfat-sparse-")
+	log.PanicIf(err)
+
+	defer func() {
+		f.Close()
+		os.Remove(f.Name())
+	}()
+
+	written, skipped, err := node.WriteSparseData(f, nil)
+	log.PanicIf(err)
+
+	if written != 100000 {
+		t.Fatalf("Written bytes not correct: (%d)", written)
+	} else if skipped != uint64(len(data))-100000 {
+		t.Fatalf("Skipped bytes not correct: (%d)", skipped)
+	}
+
+	extracted, err := ioutil.ReadFile(f.Name())
+	log.PanicIf(err)
+
+	if len(extracted) != len(data) {
+		t.Fatalf("Extracted size not correct: (%d)", len(extracted))
+	} else if bytes.Equal(extracted[:100000], data[:100000]) != true {
+		t.Fatalf("Valid data not correct.")
+	} else if bytes.Equal(extracted[100000:], make([]byte, len(data)-100000)) != true {
+		t.Fatalf("Expected zeros past the valid data.")
+	}
+}
+
+func TestTreeNode_WriteSparseData__UnallocatedCluster(t *testing.T) {
+	tree, node, data, closer := getTestSparseNode()
+
+	defer closer()
+
+	ab, err := tree.er.ReadAllocationBitmap()
+	log.PanicIf(err)
+
+	// Mark the second cluster of the file as free.
+
+	i := node.sede.FirstCluster + 1 - 2
+	ab.data[i/8] &^= 1 << (i % 8)
+
+	tws := new(testWriteSeeker)
+
+	written, skipped, err := node.WriteSparseData(tws, ab)
+	log.PanicIf(err)
+
+	clusterSize := int(tree.er.ActiveBootSectorHeader().ClusterSize())
+
+	if skipped != uint64(clusterSize) {
+		t.Fatalf("Skipped bytes not correct: (%d)", skipped)
+	} else if written != uint64(len(data)-clusterSize) {
+		t.Fatalf("Written bytes not correct: (%d)", written)
+	}
+
+	expected := make([]byte, len(data))
+	copy(expected, data)
+
+	for j := clusterSize; j < clusterSize*2; j++ {
+		expected[j] = 0
+	}
+
+	if bytes.Equal(tws.data, expected) != true {
+		t.Fatalf("Extracted data not correct.")
+	}
+}
+
+func TestTreeNode_WriteSparseData__TrailingHoleWithoutTruncate(t *testing.T) {
+	_, node, data, closer := getTestSparseNode()
+
+	defer closer()
+
+	node.sede.ValidDataLength = 5000
+
+	tws := new(testWriteSeeker)
+
+	_, _, err := node.WriteSparseData(tws, nil)
+	log.PanicIf(err)
+
+	if len(tws.data) != len(data) {
+		t.Fatalf("Extracted size not correct: (%d)", len(tws.data))
+	} else if bytes.Equal(tws.data[:5000], data[:5000]) != true {
+		t.Fatalf("Valid data not correct.")
+	} else if bytes.Equal(tws.data[5000:], make([]byte, len(data)-5000)) != true {
+		t.Fatalf("Expected zeros past the valid data.")
+	}
+}
+
+func TestTreeNode_WriteSparseData__NoHoles(t *testing.T) {
+	_, node, data, closer := getTestSparseNode()
+
+	defer closer()
+
+	tws := new(testWriteSeeker)
+
+	written, skipped, err := node.WriteSparseData(tws, nil)
+	log.PanicIf(err)
+
+	if written != uint64(len(data)) || skipped != 0 {
+		t.Fatalf("Counts not correct: (%d) (%d)", written, skipped)
+	} else if bytes.Equal(tws.data, data) != true {
+		t.Fatalf("Extracted data not correct.")
+	}
+}
@@ -9,8 +9,10 @@ import (
 	"math"
 	"os"
 	"reflect"
+	"sync"
 
 	"encoding/binary"
+	"encoding/json"
 
 	"github.com/dsoprea/go-logging"
 	"github.com/go-restruct/restruct"
@@ -30,25 +32,188 @@ var (
 )
 
 type bootRegion struct {
-	bsh        BootSectorHeader
-	sectorSize uint32
+	bsh              BootSectorHeader
+	extendedBootCode [mainExtendedBootSectorCount]ExtendedBootCode
+	oemParameters    OemParameters
+	sectorSize       uint32
+}
+
+// BootRegionKind identifies one of the two boot regions.
+type BootRegionKind int
+
+const (
+	// BootRegionMain is the main boot region, at the start of the volume.
+	BootRegionMain BootRegionKind = iota
+
+	// BootRegionBackup is the backup boot region, which immediately follows
+	// the main one.
+	BootRegionBackup
+)
+
+// String returns the name of the region.
+func (brk BootRegionKind) String() string {
+	switch brk {
+	case BootRegionMain:
+		return "main"
+	case BootRegionBackup:
+		return "backup"
+	}
+
+	return fmt.Sprintf("BootRegionKind(%d)", int(brk))
+}
+
+// isEmpty indicates that the region hasn't been parsed yet.
+func (br bootRegion) isEmpty() bool {
+	return br.bsh == BootSectorHeader{}
 }
 
 // ExfatReader knows where to find all of the statically-located structures and
 // how to parse them, and how to find clusters and chains of clusters.
 type ExfatReader struct {
+	// counters is first so that it is aligned for atomic access on 32-bit
+	// platforms.
+	counters ReaderCounters
+
 	rs io.ReadSeeker
 
+	// sr reads sequentially from `rs` while counting.
+	sr io.Reader
+
+	// ra is the same as `rs` if it also supports random-access reads.
+	ra io.ReaderAt
+
+	// readLocker serializes seek-and-read pairs when `ra` is not available.
+	readLocker sync.Mutex
+
+	// mapped is the whole image if it was memory-mapped. Sectors are then
+	// passed to visitors without copying.
+	mapped []byte
+
+	// closer releases anything that we acquired ourselves (e.g. a mapping).
+	closer io.Closer
+
 	bootRegion bootRegion
 
-	activeFat Fat
+	// selectedBootRegion is the region that `bootRegion` was taken from.
+	selectedBootRegion BootRegionKind
+
+	// mainBootRegion and backupBootRegion are both kept so that they can be
+	// compared.
+	mainBootRegion   bootRegion
+	backupBootRegion bootRegion
+
+	activeFat *LazyFat
+
+	// fats are all of the FATs, in order. There are two on TexFAT volumes.
+	fats []*LazyFat
+
+	// deferFatParsing leaves the FATs to be parsed on first use rather than
+	// in Parse().
+	deferFatParsing bool
+
+	fatOnce sync.Once
+	fatErr  error
+
+	// logger receives debug events. It may be nil.
+	logger Logger
+
+	// readRetryPolicy determines what happens when a read fails.
+	readRetryPolicy ReadRetryPolicy
+
+	// emptyRootPolicy determines whether a root directory without any
+	// entries is an error.
+	emptyRootPolicy EmptyRootPolicy
+
+	// unreadableRanges are the ranges that were zero-filled, in the order
+	// that they were found.
+	unreadableRanges       []UnreadableRange
+	unreadableRangesLocker sync.Mutex
+
+	// readAheadClusterCount is the number of clusters to read ahead when
+	// writing a cluster chain. Zero disables read-ahead.
+	readAheadClusterCount int
+
+	// clusterBufferPool holds cluster-sized buffers for read-ahead.
+	clusterBufferPool     *sync.Pool
+	clusterBufferPoolOnce sync.Once
+
+	// sectorBufferPool holds sector-sized buffers for sector enumeration.
+	sectorBufferPool     *sync.Pool
+	sectorBufferPoolOnce sync.Once
 }
 
-// NewExfatReader returns a new instance of ExfatReader.
+// NewExfatReader returns a new instance of ExfatReader. If the given reader
+// also implements io.ReaderAt (e.g. *os.File), cluster data is read with
+// ReadAt() and may be read from several goroutines at once without
+// contention. Otherwise, cluster reads are serialized.
 func NewExfatReader(rs io.ReadSeeker) *ExfatReader {
-	return &ExfatReader{
+	er := &ExfatReader{
 		rs: rs,
 	}
+
+	er.sr = countingReader{er: er}
+
+	if ra, ok := rs.(io.ReaderAt); ok == true {
+		er.ra = ra
+	}
+
+	return er
+}
+
+// imageSize returns the size of the image without changing the current
+// position.
+func (er *ExfatReader) imageSize() (size int64, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	currentOffset, err := er.seek(0, os.SEEK_CUR)
+	log.PanicIf(err)
+
+	size, err = er.seek(0, os.SEEK_END)
+	log.PanicIf(err)
+
+	_, err = er.seek(currentOffset, os.SEEK_SET)
+	log.PanicIf(err)
+
+	return size, nil
+}
+
+// readAtOnce fills the buffer from the given offset with a single attempt.
+// See readAt().
+func (er *ExfatReader) readAtOnce(data []byte, offset int64) (err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	if er.ra != nil {
+		n, err := er.ra.ReadAt(data, offset)
+		er.countRead(n)
+
+		// ReadAt() may return EOF with a full read at the end of the image.
+		if err == io.EOF && n == len(data) {
+			err = nil
+		}
+
+		log.PanicIf(err)
+
+		return nil
+	}
+
+	er.readLocker.Lock()
+	defer er.readLocker.Unlock()
+
+	_, err = er.seek(offset, os.SEEK_SET)
+	log.PanicIf(err)
+
+	_, err = io.ReadFull(er.sr, data)
+	log.PanicIf(err)
+
+	return nil
 }
 
 func (er *ExfatReader) parseN(byteCount int, x interface{}) (err error) {
@@ -60,7 +225,7 @@ func (er *ExfatReader) parseN(byteCount int, x interface{}) (err error) {
 
 	raw := make([]byte, byteCount)
 
-	_, err = io.ReadFull(er.rs, raw)
+	_, err = io.ReadFull(er.sr, raw)
 	log.PanicIf(err)
 
 	err = restruct.Unpack(raw, defaultEncoding, x)
@@ -358,12 +523,18 @@ func (vf VolumeFlags) ClearToZero() bool {
 
 // DumpBareIndented prints the volume flags with arbitrary indentation.
 func (vf VolumeFlags) DumpBareIndented(indent string) {
-	fmt.Printf("%sRaw Value: (%08b)\n", indent, vf)
-	fmt.Printf("%sUseFirstFat: [%v]\n", indent, vf.UseFirstFat())
-	fmt.Printf("%sUseSecondFat: [%v]\n", indent, vf.UseSecondFat())
-	fmt.Printf("%sIsDirty: [%v]\n", indent, vf.IsDirty())
-	fmt.Printf("%sHasHadMediaFailures: [%v]\n", indent, vf.HasHadMediaFailures())
-	fmt.Printf("%sClearToZero: [%v]\n", indent, vf.ClearToZero())
+	vf.DumpBareIndentedTo(os.Stdout, indent)
+}
+
+// DumpBareIndentedTo is the same as DumpBareIndented() but writes to the
+// given writer.
+func (vf VolumeFlags) DumpBareIndentedTo(w io.Writer, indent string) {
+	fmt.Fprintf(w, "%sRaw Value: (%08b)\n", indent, vf)
+	fmt.Fprintf(w, "%sUseFirstFat: [%v]\n", indent, vf.UseFirstFat())
+	fmt.Fprintf(w, "%sUseSecondFat: [%v]\n", indent, vf.UseSecondFat())
+	fmt.Fprintf(w, "%sIsDirty: [%v]\n", indent, vf.IsDirty())
+	fmt.Fprintf(w, "%sHasHadMediaFailures: [%v]\n", indent, vf.HasHadMediaFailures())
+	fmt.Fprintf(w, "%sClearToZero: [%v]\n", indent, vf.ClearToZero())
 }
 
 // SectorSize returns the effective sector-size.
@@ -376,34 +547,125 @@ func (bsh BootSectorHeader) SectorsPerCluster() uint32 {
 	return uint32(math.Pow(float64(2), float64(bsh.SectorsPerClusterShift)))
 }
 
+// ClusterSize returns the effective cluster-size in bytes.
+func (bsh BootSectorHeader) ClusterSize() uint32 {
+	return bsh.SectorSize() * bsh.SectorsPerCluster()
+}
+
+// VolumeFlagsInfo is the decoded form of VolumeFlags, suitable for
+// serialization.
+type VolumeFlagsInfo struct {
+	Raw                 uint16 `json:"raw" yaml:"raw"`
+	UseFirstFat         bool   `json:"use_first_fat" yaml:"use_first_fat"`
+	UseSecondFat        bool   `json:"use_second_fat" yaml:"use_second_fat"`
+	IsDirty             bool   `json:"is_dirty" yaml:"is_dirty"`
+	HasHadMediaFailures bool   `json:"has_had_media_failures" yaml:"has_had_media_failures"`
+	ClearToZero         bool   `json:"clear_to_zero" yaml:"clear_to_zero"`
+}
+
+// Info returns the decoded flags.
+func (vf VolumeFlags) Info() VolumeFlagsInfo {
+	return VolumeFlagsInfo{
+		Raw:                 uint16(vf),
+		UseFirstFat:         vf.UseFirstFat(),
+		UseSecondFat:        vf.UseSecondFat(),
+		IsDirty:             vf.IsDirty(),
+		HasHadMediaFailures: vf.HasHadMediaFailures(),
+		ClearToZero:         vf.ClearToZero(),
+	}
+}
+
+// MarshalJSON returns the decoded flags as JSON.
+func (vf VolumeFlags) MarshalJSON() ([]byte, error) {
+	return json.Marshal(vf.Info())
+}
+
+// BootSectorHeaderInfo is the decoded form of BootSectorHeader, with the
+// common calculated parameters included. It is suitable for serialization.
+type BootSectorHeaderInfo struct {
+	PartitionOffset             uint64          `json:"partition_offset" yaml:"partition_offset"`
+	VolumeLength                uint64          `json:"volume_length" yaml:"volume_length"`
+	FatOffset                   uint32          `json:"fat_offset" yaml:"fat_offset"`
+	FatLength                   uint32          `json:"fat_length" yaml:"fat_length"`
+	ClusterHeapOffset           uint32          `json:"cluster_heap_offset" yaml:"cluster_heap_offset"`
+	ClusterCount                uint32          `json:"cluster_count" yaml:"cluster_count"`
+	FirstClusterOfRootDirectory uint32          `json:"first_cluster_of_root_directory" yaml:"first_cluster_of_root_directory"`
+	VolumeSerialNumber          uint32          `json:"volume_serial_number" yaml:"volume_serial_number"`
+	FileSystemRevision          string          `json:"file_system_revision" yaml:"file_system_revision"`
+	BytesPerSectorShift         uint8           `json:"bytes_per_sector_shift" yaml:"bytes_per_sector_shift"`
+	SectorSize                  uint32          `json:"sector_size" yaml:"sector_size"`
+	SectorsPerClusterShift      uint8           `json:"sectors_per_cluster_shift" yaml:"sectors_per_cluster_shift"`
+	SectorsPerCluster           uint32          `json:"sectors_per_cluster" yaml:"sectors_per_cluster"`
+	ClusterSize                 uint32          `json:"cluster_size" yaml:"cluster_size"`
+	NumberOfFats                uint8           `json:"number_of_fats" yaml:"number_of_fats"`
+	DriveSelect                 uint8           `json:"drive_select" yaml:"drive_select"`
+	PercentInUse                uint8           `json:"percent_in_use" yaml:"percent_in_use"`
+	VolumeFlags                 VolumeFlagsInfo `json:"volume_flags" yaml:"volume_flags"`
+}
+
+// Info returns the decoded BSH parameters along with the common calculated
+// ones.
+func (bsh BootSectorHeader) Info() BootSectorHeaderInfo {
+	return BootSectorHeaderInfo{
+		PartitionOffset:             bsh.PartitionOffset,
+		VolumeLength:                bsh.VolumeLength,
+		FatOffset:                   bsh.FatOffset,
+		FatLength:                   bsh.FatLength,
+		ClusterHeapOffset:           bsh.ClusterHeapOffset,
+		ClusterCount:                bsh.ClusterCount,
+		FirstClusterOfRootDirectory: bsh.FirstClusterOfRootDirectory,
+		VolumeSerialNumber:          bsh.VolumeSerialNumber,
+		FileSystemRevision:          fmt.Sprintf("%d.%02d", bsh.FileSystemRevision[1], bsh.FileSystemRevision[0]),
+		BytesPerSectorShift:         bsh.BytesPerSectorShift,
+		SectorSize:                  bsh.SectorSize(),
+		SectorsPerClusterShift:      bsh.SectorsPerClusterShift,
+		SectorsPerCluster:           bsh.SectorsPerCluster(),
+		ClusterSize:                 bsh.ClusterSize(),
+		NumberOfFats:                bsh.NumberOfFats,
+		DriveSelect:                 bsh.DriveSelect,
+		PercentInUse:                bsh.PercentInUse,
+		VolumeFlags:                 bsh.VolumeFlags.Info(),
+	}
+}
+
+// MarshalJSON returns the decoded BSH parameters as JSON.
+func (bsh BootSectorHeader) MarshalJSON() ([]byte, error) {
+	return json.Marshal(bsh.Info())
+}
+
 // Dump prints all of the BSH parameters along with the common calculated ones.
 func (bsh BootSectorHeader) Dump() {
-	fmt.Printf("Boot Sector Header\n")
-	fmt.Printf("==================\n")
-	fmt.Printf("\n")
-
-	fmt.Printf("PartitionOffset: (%d)\n", bsh.PartitionOffset)
-	fmt.Printf("VolumeLength: (%d)\n", bsh.VolumeLength)
-	fmt.Printf("FatOffset: (%d)\n", bsh.FatOffset)
-	fmt.Printf("FatLength: (%d)\n", bsh.FatLength)
-	fmt.Printf("ClusterHeapOffset: (%d)\n", bsh.ClusterHeapOffset)
-	fmt.Printf("ClusterCount: (%d)\n", bsh.ClusterCount)
-	fmt.Printf("FirstClusterOfRootDirectory: (%d)\n", bsh.FirstClusterOfRootDirectory)
-	fmt.Printf("VolumeSerialNumber: (0x%08x)\n", bsh.VolumeSerialNumber)
-	fmt.Printf("FileSystemRevision: (0x%02x) (0x%02x)\n", bsh.FileSystemRevision[0], bsh.FileSystemRevision[1])
-	fmt.Printf("BytesPerSectorShift: (%d)\n", bsh.BytesPerSectorShift)
-	fmt.Printf("-> Sector-size: 2^(%d) -> %d\n", bsh.BytesPerSectorShift, bsh.SectorSize())
-	fmt.Printf("SectorsPerClusterShift: (%d)\n", bsh.SectorsPerClusterShift)
-	fmt.Printf("-> Sectors-per-cluster: 2^(%d) -> %d\n", bsh.SectorsPerClusterShift, bsh.SectorsPerCluster())
-	fmt.Printf("NumberOfFats: (%d)\n", bsh.NumberOfFats)
-	fmt.Printf("DriveSelect: (%d)\n", bsh.DriveSelect)
-	fmt.Printf("PercentInUse: (%d)\n", bsh.PercentInUse)
-	fmt.Printf("\n")
-
-	fmt.Printf("VolumeFlags: (%d)\n", bsh.VolumeFlags)
-	bsh.VolumeFlags.DumpBareIndented("  ")
-
-	fmt.Printf("\n")
+	bsh.DumpTo(os.Stdout)
+}
+
+// DumpTo is the same as Dump() but writes to the given writer.
+func (bsh BootSectorHeader) DumpTo(w io.Writer) {
+	fmt.Fprintf(w, "Boot Sector Header\n")
+	fmt.Fprintf(w, "==================\n")
+	fmt.Fprintf(w, "\n")
+
+	fmt.Fprintf(w, "PartitionOffset: (%d)\n", bsh.PartitionOffset)
+	fmt.Fprintf(w, "VolumeLength: (%d)\n", bsh.VolumeLength)
+	fmt.Fprintf(w, "FatOffset: (%d)\n", bsh.FatOffset)
+	fmt.Fprintf(w, "FatLength: (%d)\n", bsh.FatLength)
+	fmt.Fprintf(w, "ClusterHeapOffset: (%d)\n", bsh.ClusterHeapOffset)
+	fmt.Fprintf(w, "ClusterCount: (%d)\n", bsh.ClusterCount)
+	fmt.Fprintf(w, "FirstClusterOfRootDirectory: (%d)\n", bsh.FirstClusterOfRootDirectory)
+	fmt.Fprintf(w, "VolumeSerialNumber: (0x%08x)\n", bsh.VolumeSerialNumber)
+	fmt.Fprintf(w, "FileSystemRevision: (0x%02x) (0x%02x)\n", bsh.FileSystemRevision[0], bsh.FileSystemRevision[1])
+	fmt.Fprintf(w, "BytesPerSectorShift: (%d)\n", bsh.BytesPerSectorShift)
+	fmt.Fprintf(w, "-> Sector-size: 2^(%d) -> %d\n", bsh.BytesPerSectorShift, bsh.SectorSize())
+	fmt.Fprintf(w, "SectorsPerClusterShift: (%d)\n", bsh.SectorsPerClusterShift)
+	fmt.Fprintf(w, "-> Sectors-per-cluster: 2^(%d) -> %d\n", bsh.SectorsPerClusterShift, bsh.SectorsPerCluster())
+	fmt.Fprintf(w, "NumberOfFats: (%d)\n", bsh.NumberOfFats)
+	fmt.Fprintf(w, "DriveSelect: (%d)\n", bsh.DriveSelect)
+	fmt.Fprintf(w, "PercentInUse: (%d)\n", bsh.PercentInUse)
+	fmt.Fprintf(w, "\n")
+
+	fmt.Fprintf(w, "VolumeFlags: (%d)\n", bsh.VolumeFlags)
+	bsh.VolumeFlags.DumpBareIndentedTo(w, "  ")
+
+	fmt.Fprintf(w, "\n")
 }
 
 // Strings return a description of BSH.
@@ -421,6 +683,30 @@ func (er *ExfatReader) readBootSectorHead() (bsh BootSectorHeader, sectorSize ui
 	err = er.parseN(bootSectorHeaderSize, &bsh)
 	log.PanicIf(err)
 
+	err = validateBootSectorHeader(bsh)
+	log.PanicIf(err)
+
+	// Forward through the excess bytes.
+	sectorSize = bsh.SectorSize()
+	excessByteCount := sectorSize - 512
+
+	if excessByteCount != 0 {
+		_, err := er.seek(int64(excessByteCount), os.SEEK_CUR)
+		log.PanicIf(err)
+	}
+
+	return bsh, sectorSize, nil
+}
+
+// validateBootSectorHeader checks the signatures and the fields that
+// everything else depends on.
+func validateBootSectorHeader(bsh BootSectorHeader) (err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
 	if bytes.Equal(bsh.JumpBoot[:], requiredJumpBootSignature) != true {
 		log.Panicf("jump-boot value not correct: %x", bsh.JumpBoot[:])
 	} else if bytes.Equal(bsh.FileSystemName[:], requiredFileSystemName) != true {
@@ -435,16 +721,17 @@ func (er *ExfatReader) readBootSectorHead() (bsh BootSectorHeader, sectorSize ui
 		}
 	}
 
-	// Forward through the excess bytes.
-	sectorSize = bsh.SectorSize()
-	excessByteCount := sectorSize - 512
-
-	if excessByteCount != 0 {
-		_, err := er.rs.Seek(int64(excessByteCount), os.SEEK_CUR)
-		log.PanicIf(err)
+	// Sections 3.1.14 and 3.1.15 limit sectors to 512-4096 bytes and clusters
+	// to 32MB. Section 3.1.16 allows one or two FATs.
+	if bsh.BytesPerSectorShift < 9 || bsh.BytesPerSectorShift > 12 {
+		log.Panicf("bytes-per-sector shift not valid: (%d)", bsh.BytesPerSectorShift)
+	} else if bsh.SectorsPerClusterShift > 25-bsh.BytesPerSectorShift {
+		log.Panicf("sectors-per-cluster shift not valid: (%d)", bsh.SectorsPerClusterShift)
+	} else if bsh.NumberOfFats != 1 && bsh.NumberOfFats != 2 {
+		log.Panicf("number of FATs not valid: (%d)", bsh.NumberOfFats)
 	}
 
-	return bsh, sectorSize, nil
+	return nil
 }
 
 // ExtendedBootCode is additional boot-code that might be involved in the boot
@@ -467,7 +754,7 @@ func (er *ExfatReader) readExtendedBootSector(sectorSize uint32) (extendedBootCo
 	extendedBootCodeSize := sectorSize - 4
 	extendedBootCode = make(ExtendedBootCode, extendedBootCodeSize)
 
-	_, err = io.ReadFull(er.rs, extendedBootCode)
+	_, err = io.ReadFull(er.sr, extendedBootCode)
 	log.PanicIf(err)
 
 	// This field is mandatory and Section 3.2.2 defines its contents.
@@ -479,7 +766,7 @@ func (er *ExfatReader) readExtendedBootSector(sectorSize uint32) (extendedBootCo
 	// The valid value for this field is AA550000h. Any other value in this field invalidates its respective Main or Backup Extended Boot Sector. Implementations should verify the contents of this field prior to depending on any other field in its respective Extended Boot Sector.
 
 	extendedBootSignature := uint32(0)
-	err = binary.Read(er.rs, defaultEncoding, &extendedBootSignature)
+	err = binary.Read(er.sr, defaultEncoding, &extendedBootSignature)
 	log.PanicIf(err)
 
 	if extendedBootSignature != requiredExtendedBootSignature {
@@ -506,16 +793,6 @@ func (er *ExfatReader) readExtendedBootSectors(sectorSize uint32) (extendedBootC
 	return extendedBootCodeList, nil
 }
 
-// OemParameter is one OEM parameter.
-type OemParameter struct {
-	Parameter [48]byte
-}
-
-// OemParameters is the set of OEM parameters.
-type OemParameters struct {
-	Parameters [10]OemParameter
-}
-
 func (er *ExfatReader) readOemParameters(sectorSize uint32) (oemParameters OemParameters, err error) {
 	defer func() {
 		if errRaw := recover(); errRaw != nil {
@@ -531,7 +808,7 @@ func (er *ExfatReader) readOemParameters(sectorSize uint32) (oemParameters OemPa
 	remainder := sectorSize - 480
 	buffer := make([]byte, remainder)
 
-	_, err = io.ReadFull(er.rs, buffer)
+	_, err = io.ReadFull(er.sr, buffer)
 	log.PanicIf(err)
 
 	return oemParameters, nil
@@ -550,7 +827,7 @@ func (er *ExfatReader) readMainReserved(sectorSize uint32) (err error) {
 
 	buffer := make([]byte, sectorSize)
 
-	_, err = io.ReadFull(er.rs, buffer)
+	_, err = io.ReadFull(er.sr, buffer)
 	log.PanicIf(err)
 
 	return nil
@@ -569,7 +846,7 @@ func (er *ExfatReader) readMainBootChecksum(sectorSize uint32) (err error) {
 
 	buffer := make([]byte, sectorSize)
 
-	_, err = io.ReadFull(er.rs, buffer)
+	_, err = io.ReadFull(er.sr, buffer)
 	log.PanicIf(err)
 
 	// TODO(dustin): Implement the checksum validation.
@@ -577,47 +854,34 @@ func (er *ExfatReader) readMainBootChecksum(sectorSize uint32) (err error) {
 	return nil
 }
 
-func (er *ExfatReader) getCurrentSector() (sector uint32, offset uint32) {
-
-	// TODO(dustin): Add test.
-
-	sectorSize := er.SectorSize()
+func (er *ExfatReader) getCurrentSector() (sector uint64, offset uint32) {
+	sectorSize := uint64(er.SectorSize())
 
-	currentOffsetRaw, err := er.rs.Seek(0, os.SEEK_CUR)
+	currentOffsetRaw, err := er.seek(0, os.SEEK_CUR)
 	log.PanicIf(err)
 
-	currentOffset := uint32(currentOffsetRaw)
+	currentOffset := uint64(currentOffsetRaw)
 
-	return currentOffset / sectorSize, currentOffset % sectorSize
+	return currentOffset / sectorSize, uint32(currentOffset % sectorSize)
 }
 
 func (er *ExfatReader) printCurrentSector() {
 
 	// TODO(dustin): Add test.
 
-	sectorSize := er.SectorSize()
-
-	currentOffsetRaw, err := er.rs.Seek(0, os.SEEK_CUR)
-	log.PanicIf(err)
-
-	currentOffset := uint32(currentOffsetRaw)
+	sector, offset := er.getCurrentSector()
 
-	fmt.Printf("CURRENT SECTOR: (%d) (%d)\n", currentOffset/sectorSize, currentOffset%sectorSize)
+	fmt.Printf("CURRENT SECTOR: (%d) (%d)\n", sector, offset)
 }
 
 func (er *ExfatReader) assertAlignedToSector() {
 
 	// TODO(dustin): Add test.
 
-	sectorSize := er.SectorSize()
-
-	currentOffsetRaw, err := er.rs.Seek(0, os.SEEK_CUR)
-	log.PanicIf(err)
-
-	currentOffset := uint32(currentOffsetRaw)
+	sector, offset := er.getCurrentSector()
 
-	if currentOffset%sectorSize != 0 {
-		log.Panicf("not currently aligned to a sector: (%d) (%d)", currentOffset/sectorSize, currentOffset%sectorSize)
+	if offset != 0 {
+		log.Panicf("not currently aligned to a sector: (%d) (%d)", sector, offset)
 	}
 }
 
@@ -633,12 +897,10 @@ func (er *ExfatReader) parseBootRegion() (br bootRegion, err error) {
 	bsh, sectorSize, err := er.readBootSectorHead()
 	log.PanicIf(err)
 
-	// We don't care about these (for now, at least).
-	_, err = er.readExtendedBootSectors(sectorSize)
+	extendedBootCode, err := er.readExtendedBootSectors(sectorSize)
 	log.PanicIf(err)
 
-	// We don't care about these (for now, at least).
-	_, err = er.readOemParameters(sectorSize)
+	oemParameters, err := er.readOemParameters(sectorSize)
 	log.PanicIf(err)
 
 	err = er.readMainReserved(sectorSize)
@@ -648,7 +910,9 @@ func (er *ExfatReader) parseBootRegion() (br bootRegion, err error) {
 	log.PanicIf(err)
 
 	br = bootRegion{
-		bsh: bsh,
+		bsh:              bsh,
+		extendedBootCode: extendedBootCode,
+		oemParameters:    oemParameters,
 	}
 
 	return br, nil
@@ -663,8 +927,12 @@ func (er *ExfatReader) selectBootRegion(bootRegionMain, bootRegionBackup bootReg
 
 	// TODO(dustin): !! Add test.
 
+	er.mainBootRegion = bootRegionMain
+	er.backupBootRegion = bootRegionBackup
+
 	// We currently always elect the main region.
 	er.bootRegion = bootRegionMain
+	er.selectedBootRegion = BootRegionMain
 
 	// TODO(dustin): Add validation logic to select the backup region if the main region is no good.
 
@@ -686,10 +954,13 @@ func (mc MappedCluster) IsLast() bool {
 	return mc == 0xffffffff
 }
 
-// Fat is the collection of all FAT entries.
+// Fat is the collection of all FAT entries that describe clusters, starting
+// with the entry for cluster (2). See LazyFat.Load().
 type Fat []MappedCluster
 
-func (er *ExfatReader) parseFat() (fat Fat, err error) {
+// parseFat validates the leading entries of the FAT at the given offset. The
+// rest are read on demand.
+func (er *ExfatReader) parseFat(fatOffset int64) (fat *LazyFat, err error) {
 	defer func() {
 		if errRaw := recover(); errRaw != nil {
 			err = log.Wrap(errRaw.(error))
@@ -698,9 +969,10 @@ func (er *ExfatReader) parseFat() (fat Fat, err error) {
 
 	// TODO(dustin): !! Add test
 
-	er.assertAlignedToSector()
+	leadingEntries := make([]byte, 8)
 
-	sectorSize := er.SectorSize()
+	err = er.readAt(leadingEntries, fatOffset)
+	log.PanicIf(err)
 
 	// This field is mandatory and Section 4.1.1 defines its contents.
 	//
@@ -708,10 +980,7 @@ func (er *ExfatReader) parseFat() (fat Fat, err error) {
 	//
 	// The media type (the first byte) should be F8h.
 
-	mediaTypeRaw := uint32(0)
-	err = binary.Read(er.rs, defaultEncoding, &mediaTypeRaw)
-	log.PanicIf(err)
-
+	mediaTypeRaw := defaultEncoding.Uint32(leadingEntries[:4])
 	mediaType := mediaTypeRaw & 0xff
 
 	if mediaType != 0xf8 {
@@ -724,21 +993,11 @@ func (er *ExfatReader) parseFat() (fat Fat, err error) {
 	//
 	// The valid value for this field is FFFFFFFFh. Implementations shall initialize this field to its prescribed value and should not use this field for any purpose. Implementations should not interpret this field and shall preserve its contents across operations which modify surrounding fields.
 
-	value := uint32(0)
-	err = binary.Read(er.rs, defaultEncoding, &value)
-	log.PanicIf(err)
-
+	value := defaultEncoding.Uint32(leadingEntries[4:])
 	if value != 0xffffffff {
 		log.Panicf("second fat-entry has unexpected value: (0x%08x)", value)
 	}
 
-	totalFatSize := er.bootRegion.bsh.FatLength * sectorSize
-
-	// Includes the two uint32s above.
-	actualFatSize := ((er.bootRegion.bsh.ClusterCount + 1) * 4)
-
-	excessSize := totalFatSize - actualFatSize
-
 	// This field is mandatory and Section 4.1.3 defines its contents.
 	//
 	// ClusterCount + 1 can never exceed FFFFFFF6h.
@@ -755,23 +1014,14 @@ func (er *ExfatReader) parseFat() (fat Fat, err error) {
 	//
 	// Exactly FFFFFFFFh, which marks the given FatEntry's corresponding cluster as the last cluster of a cluster chain; this is the only valid value for the last FatEntry of any given cluster chain
 
-	entryCount := er.bootRegion.bsh.ClusterCount - 1
-
-	fat = make(Fat, entryCount)
-	for i := uint32(0); i < entryCount; i++ {
-		err := binary.Read(er.rs, defaultEncoding, &fat[i])
-		log.PanicIf(err)
-	}
-
-	excess := make([]byte, excessSize)
-
-	_, err = io.ReadFull(er.rs, excess)
-	log.PanicIf(err)
+	fat = newLazyFat(er, fatOffset)
 
 	return fat, nil
 }
 
-func (er *ExfatReader) parseFats() (fats []Fat, err error) {
+// checkFatRegion validates the location and size of the FATs (Sections
+// 3.1.6-3.1.9) and moves past them. The FATs themselves are not read.
+func (er *ExfatReader) checkFatRegion() (err error) {
 	defer func() {
 		if errRaw := recover(); errRaw != nil {
 			err = log.Wrap(errRaw.(error))
@@ -780,27 +1030,64 @@ func (er *ExfatReader) parseFats() (fats []Fat, err error) {
 
 	sectorSize := er.SectorSize()
 
-	emptyBootRegion := bootRegion{}
-	if er.bootRegion == emptyBootRegion {
+	if er.bootRegion.isEmpty() == true {
 		log.Panicf("boot-sectors not loaded yet")
 	}
 
-	// This sub-region is mandatory and its contents, if any, are undefined.
-	//
-	// Note: the Main and Backup Boot Sectors both contain the FatOffset field.
+	bsh := er.bootRegion.bsh
+
+	// Check these before we allocate or read anything based on them.
+	fatRegionEnd := uint64(bsh.FatOffset) + uint64(bsh.FatLength)*uint64(bsh.NumberOfFats)
+
+	if bsh.FatOffset < 24 {
+		log.Panicf("FAT offset not valid: (%d)", bsh.FatOffset)
+	} else if (uint64(bsh.ClusterCount)+1)*4 > uint64(bsh.FatLength)*uint64(sectorSize) || bsh.ClusterCount < 1 {
+		log.Panicf("cluster-count does not fit in the FAT: (%d) (%d)", bsh.ClusterCount, bsh.FatLength)
+	} else if uint64(bsh.ClusterHeapOffset) < fatRegionEnd {
+		log.Panicf("cluster-heap offset overlaps the FATs: (%d) < (%d)", bsh.ClusterHeapOffset, fatRegionEnd)
+	}
+
+	imageSize, err := er.imageSize()
+	log.PanicIf(err)
 
-	fatAlignment := make([]byte, (uint32(er.bootRegion.bsh.FatOffset)-24)*sectorSize)
+	if fatRegionEnd*uint64(sectorSize) > uint64(imageSize) {
+		log.Panicf("FATs extend past the end of the image: (%d) > (%d)", fatRegionEnd*uint64(sectorSize), imageSize)
+	}
+
+	// The FAT alignment sub-region is mandatory and its contents, if any, are
+	// undefined. The FATs are read on demand. Skip both.
+	//
+	// Note: the Main and Backup Boot Sectors both contain the FatOffset and FatLength fields.
 
-	_, err = io.ReadFull(er.rs, fatAlignment)
+	_, err = er.seek(int64(fatRegionEnd)*int64(sectorSize), os.SEEK_SET)
 	log.PanicIf(err)
 
+	return nil
+}
+
+func (er *ExfatReader) parseFats() (fats []*LazyFat, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	if er.bootRegion.isEmpty() == true {
+		log.Panicf("boot-sectors not loaded yet")
+	}
+
+	bsh := er.bootRegion.bsh
+	sectorSize := int64(er.SectorSize())
+
 	// This sub-region is mandatory and Section 4.1 defines its contents.
 	//
 	// Note: the Main and Backup Boot Sectors both contain the FatOffset and FatLength fields.
 
-	fats = make([]Fat, er.bootRegion.bsh.NumberOfFats)
-	for i := 0; i < int(er.bootRegion.bsh.NumberOfFats); i++ {
-		fat, err := er.parseFat()
+	fats = make([]*LazyFat, bsh.NumberOfFats)
+	for i := 0; i < int(bsh.NumberOfFats); i++ {
+		fatOffset := (int64(bsh.FatOffset) + int64(i)*int64(bsh.FatLength)) * sectorSize
+
+		fat, err := er.parseFat(fatOffset)
 		log.PanicIf(err)
 
 		fats[i] = fat
@@ -809,6 +1096,73 @@ func (er *ExfatReader) parseFats() (fats []Fat, err error) {
 	return fats, nil
 }
 
+// loadFats parses the FATs and selects the active one.
+func (er *ExfatReader) loadFats() (err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	fats, err := er.parseFats()
+	log.PanicIf(err)
+
+	er.fats = fats
+
+	// Technically, the spec says that only the active-fat flag in the main
+	// boot-sector should be used (not the backup):
+	//
+	// 	The ActiveFat field of the VolumeFlags field describes which FAT is
+	// 	active. Only the VolumeFlags field in the Main Boot Sector is current.
+	// 	Implementations shall treat the FAT which is not active as stale. Use
+	// 	of the inactive FAT and switching between FATs is implementation
+	// 	specific.
+	//
+	// Obviously, the backup boot sector is there for a reason and, in the event
+	// that the main boot-sector is garbage, we want to be consistent with the
+	// boot-sector that we're supposed to be using.
+
+	if er.bootRegion.bsh.VolumeFlags.UseFirstFat() == true {
+		er.activeFat = fats[0]
+	} else if er.bootRegion.bsh.VolumeFlags.UseSecondFat() == true {
+		if len(fats) == 1 {
+			log.Panicf("boot-sector-header says to use the second FAT but only one FAT is available")
+		}
+
+		er.activeFat = fats[1]
+	} else {
+		log.Panicf("no fat selected")
+	}
+
+	er.debug(EventFatLoaded, "fat_count", len(fats), "use_second_fat", er.bootRegion.bsh.VolumeFlags.UseSecondFat(), "offset", er.activeFat.offset)
+
+	return nil
+}
+
+// fat returns the active FAT, parsing the FATs if that hasn't happened yet.
+// This is safe to call concurrently.
+func (er *ExfatReader) fat() (fat *LazyFat, err error) {
+	er.fatOnce.Do(func() {
+		er.fatErr = er.loadFats()
+	})
+
+	if er.fatErr != nil {
+		return nil, er.fatErr
+	}
+
+	return er.activeFat, nil
+}
+
+// SetDeferFatParsing determines whether Parse() leaves the FATs to be parsed
+// when a cluster chain is first followed rather than parsing them right away.
+// Tools that only need the boot-sector, or that only read NoFatChain data,
+// then never touch the FATs at all. The trade-off is that a corrupt FAT is
+// reported by whatever first needs it rather than by Parse(). Must be called
+// before Parse().
+func (er *ExfatReader) SetDeferFatParsing(deferFatParsing bool) {
+	er.deferFatParsing = deferFatParsing
+}
+
 // SectorSize is the sector-size from the active FAT.
 func (er *ExfatReader) SectorSize() uint32 {
 
@@ -834,6 +1188,39 @@ func (er *ExfatReader) ActiveBootSectorHeader() BootSectorHeader {
 	return er.bootRegion.bsh
 }
 
+// MainBootSectorHeader returns the boot-sector struct from the main boot
+// region, whether or not it is the active one.
+func (er *ExfatReader) MainBootSectorHeader() BootSectorHeader {
+	return er.mainBootRegion.bsh
+}
+
+// BackupBootSectorHeader returns the boot-sector struct from the backup boot
+// region, whether or not it is the active one.
+func (er *ExfatReader) BackupBootSectorHeader() BootSectorHeader {
+	return er.backupBootRegion.bsh
+}
+
+// SelectedBootRegion returns which boot region was selected when the volume
+// was parsed, and so which one ActiveBootSectorHeader() (and everything that's
+// located using it) comes from. This is for diagnostics: the header itself is
+// always retrieved with ActiveBootSectorHeader(). The backup region is never
+// selected yet, so this currently always returns BootRegionMain.
+func (er *ExfatReader) SelectedBootRegion() BootRegionKind {
+	return er.selectedBootRegion
+}
+
+// ExtendedBootCode returns the boot-code of each of the extended boot-sectors
+// of the active boot region, in order. These are all NULs unless the volume
+// was made bootable. The returned slices are copies.
+func (er *ExfatReader) ExtendedBootCode() (extendedBootCode [mainExtendedBootSectorCount][]byte) {
+	for i, code := range er.bootRegion.extendedBootCode {
+		extendedBootCode[i] = make([]byte, len(code))
+		copy(extendedBootCode[i], code)
+	}
+
+	return extendedBootCode
+}
+
 // FirstClusterOfRootDirectory is the first-cluster of the directory-entry data.
 func (er *ExfatReader) FirstClusterOfRootDirectory() uint32 {
 
@@ -842,6 +1229,36 @@ func (er *ExfatReader) FirstClusterOfRootDirectory() uint32 {
 	return er.bootRegion.bsh.FirstClusterOfRootDirectory
 }
 
+// ActiveFat returns the FAT that the boot-sector says to use.
+func (er *ExfatReader) ActiveFat() (fat *LazyFat, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	fat, err = er.fat()
+	log.PanicIf(err)
+
+	return fat, nil
+}
+
+// Fats returns every FAT, in order, parsing them if that hasn't happened yet.
+// There is only more than one on TexFAT volumes, and only the active one is
+// used to follow cluster chains.
+func (er *ExfatReader) Fats() (fats []*LazyFat, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	_, err = er.fat()
+	log.PanicIf(err)
+
+	return er.fats, nil
+}
+
 // GetCluster gets a Cluster instance for the given cluster.
 func (er *ExfatReader) GetCluster(clusterNumber uint32) *ExfatCluster {
 	ec, err := newExfatCluster(er, clusterNumber)
@@ -855,7 +1272,11 @@ func (er *ExfatReader) GetCluster(clusterNumber uint32) *ExfatCluster {
 type ClusterVisitorFunc func(ec *ExfatCluster) (doContinue bool, err error)
 
 // EnumerateClusters calls the given callback for each cluster in the chain
-// starting from the given cluster.
+// starting from the given cluster. A chain can't be longer than the number of
+// clusters in the heap, so if the FAT leads around in a circle, a
+// CorruptionError that matches ErrClusterLoop is returned once it's gone that
+// far rather than the enumeration never ending. A chain that leads out of the
+// heap returns one that matches ErrClusterOutOfRange.
 func (er *ExfatReader) EnumerateClusters(startingClusterNumber uint32, cb ClusterVisitorFunc, useFat bool) (err error) {
 	defer func() {
 		if errRaw := recover(); errRaw != nil {
@@ -863,14 +1284,79 @@ func (er *ExfatReader) EnumerateClusters(startingClusterNumber uint32, cb Cluste
 		}
 	}()
 
+	err = er.enumerateClusters(startingClusterNumber, 0, cb, useFat)
+	log.PanicIf(err)
+
+	return nil
+}
+
+// EnumerateClustersLimited is the same as EnumerateClusters() but stops
+// quietly after `clusterCount` clusters, whatever the callback returns. Pass
+// the number of clusters that the data needs (see ClusterCountForLength())
+// so that a callback that doesn't stop at the end of the data can't read
+// into whatever follows it, which matters most for contiguous (NoFatChain)
+// allocations since nothing else marks where they end.
+func (er *ExfatReader) EnumerateClustersLimited(startingClusterNumber uint32, clusterCount uint64, cb ClusterVisitorFunc, useFat bool) (err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	if clusterCount == 0 {
+		return nil
+	}
+
+	err = er.enumerateClusters(startingClusterNumber, clusterCount, cb, useFat)
+	log.PanicIf(err)
+
+	return nil
+}
+
+// ClusterCountForLength returns the number of clusters needed to store the
+// given number of bytes.
+func (er *ExfatReader) ClusterCountForLength(length uint64) uint64 {
+	clusterSize := uint64(er.bootRegion.bsh.ClusterSize())
+
+	return (length + clusterSize - 1) / clusterSize
+}
+
+// enumerateClusters visits the chain. If `clusterCount` is zero, the only
+// limit is the size of the heap.
+func (er *ExfatReader) enumerateClusters(startingClusterNumber uint32, clusterCount uint64, cb ClusterVisitorFunc, useFat bool) (err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
 	if startingClusterNumber < 2 {
 		log.Panicf("cluster can not be less than (2): (%d)", startingClusterNumber)
 	}
 
+	heapClusterCount := uint64(er.bootRegion.bsh.ClusterCount)
+	lastClusterNumber := heapClusterCount + 1
+
 	currentClusterNumber := startingClusterNumber
-	for {
+	previousClusterNumber := uint32(0)
+	for visitedCount := uint64(0); ; visitedCount++ {
+		if clusterCount > 0 && visitedCount >= clusterCount {
+			break
+		}
+
 		if currentClusterNumber < 2 {
-			log.Panicf("cluster-number too low: (%d)", currentClusterNumber)
+			log.Panic(er.newFatCorruptionError(previousClusterNumber, log.Errorf("cluster-number too low: (%d)", currentClusterNumber)))
+		} else if uint64(currentClusterNumber) > lastClusterNumber {
+			if useFat == true {
+				log.Panic(er.newFatCorruptionError(previousClusterNumber, ErrClusterOutOfRange))
+			}
+
+			ce := newCorruptionError("cluster chain", ErrClusterOutOfRange)
+			ce.ClusterNumber = startingClusterNumber
+
+			log.Panic(ce)
+		} else if visitedCount >= heapClusterCount {
+			log.Panic(er.newFatCorruptionError(previousClusterNumber, ErrClusterLoop))
 		}
 
 		ec := er.GetCluster(currentClusterNumber)
@@ -882,42 +1368,62 @@ func (er *ExfatReader) EnumerateClusters(startingClusterNumber uint32, cb Cluste
 			break
 		}
 
-		if useFat == true {
-			if currentClusterNumber >= uint32(len(er.activeFat)) {
-				log.Panicf("cluster exceeds FAT bounds: (%d) >= (%d)", currentClusterNumber, len(er.activeFat))
-			}
-
-			nextMappedCluster := er.activeFat[currentClusterNumber-2]
-			if nextMappedCluster.IsLast() == true {
-				break
-			}
+		nextClusterNumber, isLast, err := er.nextClusterNumber(currentClusterNumber, useFat)
+		log.PanicIf(err)
 
-			currentClusterNumber = uint32(nextMappedCluster)
-		} else {
-			// If not using fat, just move to the next, adjacent cluster.
-			//
-			// The specification implies that "no fat" means that the data
-			// could be allocated in adjacent clusters on disk:
-			//
-			//  6.3.4.2 NoFatChain Field:
-			//
-			// 	"...the associated allocation is one contiguous series of
-			// 	clusters; the corresponding FAT entries for the clusters are
-			// 	invalid and implementations shall not interpret them"
-			//
-			// However, in practice this is only used when only one cluster is
-			// needed. So, this measure is just a theoretical exercise (since we
-			// should never even reach the increment if the callback is properly
-			// consuming the correct amount of data and stopping when that is
-			// reached).
-
-			currentClusterNumber++
+		if isLast == true {
+			break
 		}
+
+		previousClusterNumber = currentClusterNumber
+		currentClusterNumber = nextClusterNumber
 	}
 
 	return nil
 }
 
+// nextClusterNumber returns the cluster that follows the given one in its
+// chain. `isLast` is true if the given cluster is the last one.
+func (er *ExfatReader) nextClusterNumber(currentClusterNumber uint32, useFat bool) (nextClusterNumber uint32, isLast bool, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	if useFat == true {
+		fat, err := er.fat()
+		log.PanicIf(err)
+
+		nextMappedCluster, err := fat.Entry(currentClusterNumber)
+		log.PanicIf(err)
+
+		if nextMappedCluster.IsLast() == true {
+			return 0, true, nil
+		}
+
+		return uint32(nextMappedCluster), false, nil
+	}
+
+	// If not using fat, just move to the next, adjacent cluster.
+	//
+	// The specification implies that "no fat" means that the data could be
+	// allocated in adjacent clusters on disk:
+	//
+	//  6.3.4.2 NoFatChain Field:
+	//
+	// 	"...the associated allocation is one contiguous series of clusters;
+	// 	the corresponding FAT entries for the clusters are invalid and
+	// 	implementations shall not interpret them"
+	//
+	// However, in practice this is only used when only one cluster is needed.
+	// So, this measure is just a theoretical exercise (since we should never
+	// even reach the increment if the callback is properly consuming the
+	// correct amount of data and stopping when that is reached).
+
+	return currentClusterNumber + 1, false, nil
+}
+
 func (er *ExfatReader) checkClusterHeapOffset() (err error) {
 	defer func() {
 		if errRaw := recover(); errRaw != nil {
@@ -929,23 +1435,24 @@ func (er *ExfatReader) checkClusterHeapOffset() (err error) {
 
 	sectorSize := er.SectorSize()
 
-	alignmentSectors := er.bootRegion.bsh.ClusterHeapOffset - (er.bootRegion.bsh.FatOffset + er.bootRegion.bsh.FatLength*uint32(er.bootRegion.bsh.NumberOfFats))
-	alignmentByteCount := alignmentSectors * sectorSize
+	imageSize, err := er.imageSize()
+	log.PanicIf(err)
 
-	alignmentBytes := make([]byte, alignmentByteCount)
+	if uint64(er.bootRegion.bsh.ClusterHeapOffset)*uint64(sectorSize) > uint64(imageSize) {
+		log.Panicf("cluster-heap offset is past the end of the image: (%d)", er.bootRegion.bsh.ClusterHeapOffset)
+	}
 
-	_, err = io.ReadFull(er.rs, alignmentBytes)
-	log.PanicIf(err)
+	alignmentSectors := er.bootRegion.bsh.ClusterHeapOffset - (er.bootRegion.bsh.FatOffset + er.bootRegion.bsh.FatLength*uint32(er.bootRegion.bsh.NumberOfFats))
+	alignmentByteCount := int64(alignmentSectors) * int64(sectorSize)
 
-	currentOffsetRaw, err := er.rs.Seek(0, os.SEEK_CUR)
+	// The alignment is skipped rather than read since it may be arbitrarily
+	// large.
+	_, err = er.seek(alignmentByteCount, os.SEEK_CUR)
 	log.PanicIf(err)
 
-	clusterHeapOffset := uint32(currentOffsetRaw)
-
-	currentSectorNumber := clusterHeapOffset / sectorSize
-	remainder := clusterHeapOffset % sectorSize
+	currentSectorNumber, remainder := er.getCurrentSector()
 
-	if uint32(currentSectorNumber) != er.bootRegion.bsh.ClusterHeapOffset || remainder != 0 {
+	if currentSectorNumber != uint64(er.bootRegion.bsh.ClusterHeapOffset) || remainder != 0 {
 		log.Panicf("calculated cluster offset does not match expected cluster offset: (%d) (%d) != (%d)", currentSectorNumber, remainder, er.bootRegion.bsh.ClusterHeapOffset)
 	}
 
@@ -953,7 +1460,8 @@ func (er *ExfatReader) checkClusterHeapOffset() (err error) {
 }
 
 // Parse loads all of the main filesystem structures. This is always a small
-// read (does not scale with size).
+// read (does not scale with size). FAT entries are read as they are needed.
+// See SetDeferFatParsing().
 func (er *ExfatReader) Parse() (err error) {
 	defer func() {
 		if errRaw := recover(); errRaw != nil {
@@ -962,44 +1470,45 @@ func (er *ExfatReader) Parse() (err error) {
 	}()
 
 	bootRegionMain, err := er.parseBootRegion()
-	log.PanicIf(err)
+	if err != nil {
+		ce := newCorruptionError("main boot region", err)
+		ce.Offset = 0
+
+		log.Panic(ce)
+	}
 
 	bootRegionBackup, err := er.parseBootRegion()
-	log.PanicIf(err)
+	if err != nil {
+		ce := newCorruptionError("backup boot region", err)
+		ce.Offset = int64(bootRegionSectorCount) * int64(bootRegionMain.bsh.SectorSize())
+
+		log.Panic(ce)
+	}
 
 	er.selectBootRegion(bootRegionMain, bootRegionBackup)
 
-	fats, err := er.parseFats()
-	log.PanicIf(err)
+	bsh := er.bootRegion.bsh
 
-	// Technically, the spec says that only the active-fat flag in the main
-	// boot-sector should be used (not the backup):
-	//
-	// 	The ActiveFat field of the VolumeFlags field describes which FAT is
-	// 	active. Only the VolumeFlags field in the Main Boot Sector is current.
-	// 	Implementations shall treat the FAT which is not active as stale. Use
-	// 	of the inactive FAT and switching between FATs is implementation
-	// 	specific.
-	//
-	// Obviously, the backup boot sector is there for a reason and, in the event
-	// that the main boot-sector is garbage, we want to be consistent with the
-	// boot-sector that we're supposed to be using.
+	er.debug(EventBootRegionParsed, "sector_size", bsh.SectorSize(), "cluster_size", bsh.ClusterSize(), "cluster_count", bsh.ClusterCount, "cluster_heap_offset", bsh.ClusterHeapOffset)
 
-	if er.bootRegion.bsh.VolumeFlags.UseFirstFat() == true {
-		er.activeFat = fats[0]
-	} else if er.bootRegion.bsh.VolumeFlags.UseSecondFat() == true {
-		if len(fats) == 1 {
-			log.Panicf("boot-sector-header says to use the second FAT but only one FAT is available")
-		}
-
-		er.activeFat = fats[1]
-	} else {
-		log.Panicf("no fat selected")
-	}
+	err = er.checkFatRegion()
+	log.PanicIf(err)
 
 	err = er.checkClusterHeapOffset()
 	log.PanicIf(err)
 
+	// This is done last since, without ReaderAt support, it moves the
+	// position that the steps above rely on.
+	if er.deferFatParsing == false {
+		_, err := er.fat()
+		log.PanicIf(err)
+	}
+
+	if er.emptyRootPolicy == EmptyRootPolicyError {
+		err := er.checkRootDirectoryNotEmpty()
+		log.PanicIf(err)
+	}
+
 	return nil
 }
 
@@ -1014,6 +1523,13 @@ func (er *ExfatReader) WriteFromClusterChain(firstClusterNumber uint32, dataSize
 
 	// TODO(dustin): !! Add test
 
+	if er.readAheadClusterCount > 0 {
+		visitedClusters, visitedSectors, err = er.writeFromClusterChainWithReadAhead(firstClusterNumber, dataSize, useFat, w)
+		log.PanicIf(err)
+
+		return visitedClusters, visitedSectors, nil
+	}
+
 	sectorSize := er.SectorSize()
 	tailFragmentSize := dataSize % uint64(sectorSize)
 
@@ -1053,7 +1569,7 @@ func (er *ExfatReader) WriteFromClusterChain(firstClusterNumber uint32, dataSize
 			visitedSectors = append(visitedSectors, sectorNumber)
 
 			// If we're in the last sector.
-			if uint64((sectorCount+1)*sectorSize) > dataSize {
+			if (uint64(sectorCount)+1)*uint64(sectorSize) >= dataSize {
 				// If we're in the last sector and the file-size is not an exact
 				// multiple of sectors.
 				if tailFragmentSize > 0 {
@@ -1078,7 +1594,7 @@ func (er *ExfatReader) WriteFromClusterChain(firstClusterNumber uint32, dataSize
 		return doContinue, nil
 	}
 
-	err = er.EnumerateClusters(firstClusterNumber, clusterCb, useFat)
+	err = er.EnumerateClustersLimited(firstClusterNumber, er.ClusterCountForLength(dataSize), clusterCb, useFat)
 	log.PanicIf(err)
 
 	if written != dataSize {
@@ -1096,7 +1612,7 @@ type ExfatCluster struct {
 	clusterNumber     uint32
 	clusterSize       uint32
 	sectorsPerCluster uint32
-	clusterOffset     uint32
+	clusterOffset     int64
 }
 
 func newExfatCluster(er *ExfatReader, clusterNumber uint32) (ec *ExfatCluster, err error) {
@@ -1111,10 +1627,12 @@ func newExfatCluster(er *ExfatReader, clusterNumber uint32) (ec *ExfatCluster, e
 	sectorSize := er.SectorSize()
 
 	clusterSize := sectorsPerCluster * sectorSize
-	clusterHeapOffset := er.bootRegion.bsh.ClusterHeapOffset * er.SectorSize()
+	// The offsets are calculated in 64-bits since the cluster heap may start
+	// or extend past 4GB.
+	clusterHeapOffset := int64(er.bootRegion.bsh.ClusterHeapOffset) * int64(sectorSize)
 
 	// Only clusters numbering (2) and above are stored on disk.
-	clusterOffset := clusterHeapOffset + clusterSize*(clusterNumber-2)
+	clusterOffset := clusterHeapOffset + int64(clusterSize)*int64(clusterNumber-2)
 
 	ec = &ExfatCluster{
 		er: er,
@@ -1134,7 +1652,8 @@ func (ec *ExfatCluster) ClusterNumber() uint32 {
 }
 
 // GetSectorByIndex gets the data for the given sector within the cluster that
-// this instance represents.
+// this instance represents. A new buffer is allocated for every call; use
+// ReadSectorInto() to avoid this.
 func (ec *ExfatCluster) GetSectorByIndex(sectorIndex uint32) (data []byte, err error) {
 	defer func() {
 		if errRaw := recover(); errRaw != nil {
@@ -1142,7 +1661,23 @@ func (ec *ExfatCluster) GetSectorByIndex(sectorIndex uint32) (data []byte, err e
 		}
 	}()
 
-	// TODO(dustin): !! Add test.
+	data = make([]byte, ec.er.SectorSize())
+
+	err = ec.ReadSectorInto(sectorIndex, data)
+	log.PanicIf(err)
+
+	return data, nil
+}
+
+// ReadSectorInto reads the given sector within the cluster that this instance
+// represents into the given, caller-provided buffer, which must be exactly one
+// sector in size.
+func (ec *ExfatCluster) ReadSectorInto(sectorIndex uint32, data []byte) (err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
 
 	if sectorIndex >= ec.sectorsPerCluster {
 		log.Panicf("sector-index exceeds the number of sectors per cluster: (%d) >= (%d)", sectorIndex, ec.sectorsPerCluster)
@@ -1150,21 +1685,22 @@ func (ec *ExfatCluster) GetSectorByIndex(sectorIndex uint32) (data []byte, err e
 
 	sectorSize := ec.er.SectorSize()
 
-	offset := ec.clusterOffset + sectorSize*sectorIndex
-
-	_, err = ec.er.rs.Seek(int64(offset), os.SEEK_SET)
-	log.PanicIf(err)
+	if uint32(len(data)) != sectorSize {
+		log.Panicf("buffer is not the size of a sector: (%d) != (%d)", len(data), sectorSize)
+	}
 
-	data = make([]byte, sectorSize)
+	offset := ec.clusterOffset + int64(sectorSize)*int64(sectorIndex)
 
-	_, err = io.ReadFull(ec.er.rs, data)
+	err = ec.er.readAt(data, offset)
 	log.PanicIf(err)
 
-	return data, nil
+	return nil
 }
 
 // SectorVisitorFunc is a visitor callback that is called for each sector in a
-// cluster.
+// cluster. The data is only valid until the callback returns (the buffer is
+// reused); copy it if it needs to be kept. It must not be modified (it may be
+// read-only memory).
 type SectorVisitorFunc func(sectorNumber uint32, data []byte) (bool, error)
 
 // EnumerateSectors calls the given callback for each sector in the cluster that
@@ -1176,10 +1712,25 @@ func (ec *ExfatCluster) EnumerateSectors(cb SectorVisitorFunc) (err error) {
 		}
 	}()
 
+	buffers := ec.er.sectorBuffers()
+
+	sectorDataPointer := buffers.Get().(*[]byte)
+	defer buffers.Put(sectorDataPointer)
+
+	sectorSize := ec.er.SectorSize()
+
 	for i := uint32(0); i < ec.sectorsPerCluster; i++ {
-		sectorData, err := ec.GetSectorByIndex(i)
+		// Use the memory-mapping directly if we have one.
+		sectorData, err := ec.er.mappedRange(ec.clusterOffset+int64(sectorSize)*int64(i), int(sectorSize))
 		log.PanicIf(err)
 
+		if sectorData == nil {
+			sectorData = *sectorDataPointer
+
+			err := ec.ReadSectorInto(i, sectorData)
+			log.PanicIf(err)
+		}
+
 		sectorNumber := ec.er.bootRegion.bsh.ClusterHeapOffset + ec.clusterNumber + i
 
 		doContinue, err := cb(sectorNumber, sectorData)
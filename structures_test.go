@@ -2,11 +2,16 @@ package exfat
 
 import (
 	"bytes"
+	"errors"
+	"io/ioutil"
 	"os"
 	"path"
 	"reflect"
+	"strings"
 	"testing"
 
+	"encoding/json"
+
 	"github.com/dsoprea/go-logging"
 )
 
@@ -88,6 +93,42 @@ func TestExfatReader_readExtendedBootSectors(t *testing.T) {
 	}
 }
 
+func TestExfatReader_ExtendedBootCode(t *testing.T) {
+	image, _ := getTestImage()
+
+	// Put some code in the third extended boot-sector (the fourth sector).
+	copy(image[3*512:], []byte{0xeb, 0xfe})
+
+	er := NewExfatReader(bytes.NewReader(image))
+
+	err := er.Parse()
+	log.PanicIf(err)
+
+	extendedBootCode := er.ExtendedBootCode()
+
+	for i, code := range extendedBootCode {
+		if len(code) != 508 {
+			t.Fatalf("Extended boot-code (%d) not the right size: (%d)", i, len(code))
+		}
+
+		if i == 2 {
+			if bytes.Equal(code[:3], []byte{0xeb, 0xfe, 0}) != true {
+				t.Fatalf("Extended boot-code (%d) not correct: %x", i, code[:3])
+			}
+		} else if bytes.Equal(code, make([]byte, 508)) != true {
+			t.Fatalf("Extended boot-code (%d) not empty.", i)
+		}
+	}
+
+	// We get copies.
+
+	extendedBootCode[2][0] = 0
+
+	if er.ExtendedBootCode()[2][0] != 0xeb {
+		t.Fatalf("Extended boot-code was modified through the returned slice.")
+	}
+}
+
 func TestBootSectorHeader_Dump(t *testing.T) {
 	f, er := getTestFileAndParser()
 
@@ -99,6 +140,27 @@ func TestBootSectorHeader_Dump(t *testing.T) {
 	bsh.Dump()
 }
 
+func TestBootSectorHeader_DumpTo(t *testing.T) {
+	f, er := getTestFileAndParser()
+
+	defer f.Close()
+
+	bsh, _, err := er.readBootSectorHead()
+	log.PanicIf(err)
+
+	b := new(bytes.Buffer)
+	bsh.DumpTo(b)
+
+	s := b.String()
+	if strings.HasPrefix(s, "Boot Sector Header\n") != true {
+		t.Fatalf("Dump not correct: [%s]", s)
+	} else if strings.Contains(s, "ClusterCount: (239)\n") != true {
+		t.Fatalf("Dump does not include the cluster-count: [%s]", s)
+	} else if strings.Contains(s, "  UseFirstFat: [true]\n") != true {
+		t.Fatalf("Dump does not include the volume flags: [%s]", s)
+	}
+}
+
 func TestExfatReader_readOemParameters(t *testing.T) {
 	f, er := getTestFileAndParser()
 
@@ -278,3 +340,435 @@ func TestMappedCluster_IsBad__false(t *testing.T) {
 		t.Fatalf("Expected MC to be bad.")
 	}
 }
+
+func TestBootSectorHeader_MarshalJSON(t *testing.T) {
+	f, er := getTestFileAndParser()
+
+	defer f.Close()
+
+	bsh, _, err := er.readBootSectorHead()
+	log.PanicIf(err)
+
+	encoded, err := json.Marshal(bsh)
+	log.PanicIf(err)
+
+	decoded := make(map[string]interface{})
+
+	err = json.Unmarshal(encoded, &decoded)
+	log.PanicIf(err)
+
+	if decoded["sector_size"].(float64) != 512 {
+		t.Fatalf("Sector-size not correct: %v", decoded["sector_size"])
+	} else if decoded["cluster_size"].(float64) != 4096 {
+		t.Fatalf("Cluster-size not correct: %v", decoded["cluster_size"])
+	} else if decoded["file_system_revision"].(string) != "1.00" {
+		t.Fatalf("Revision not correct: %v", decoded["file_system_revision"])
+	}
+
+	volumeFlags := decoded["volume_flags"].(map[string]interface{})
+	if volumeFlags["use_first_fat"].(bool) != true {
+		t.Fatalf("Volume flags not decoded correctly: %v", volumeFlags)
+	}
+}
+
+func BenchmarkExfatReader_WriteFromClusterChain(b *testing.B) {
+	tree, closer := getTestTree()
+
+	defer closer()
+
+	node, err := tree.LookupPath("2-delahaye-type-165-cabriolet-dsc_8025.jpg")
+	log.PanicIf(err)
+
+	sede := node.StreamDirectoryEntry()
+	useFat := sede.GeneralSecondaryFlags.NoFatChain() == false
+
+	b.ReportAllocs()
+	b.SetBytes(int64(sede.ValidDataLength))
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		_, _, err := tree.er.WriteFromClusterChain(sede.FirstCluster, sede.ValidDataLength, useFat, ioutil.Discard)
+		log.PanicIf(err)
+	}
+}
+
+func TestExfatCluster_ReadSectorInto(t *testing.T) {
+	f, er := getTestFileAndParser()
+
+	defer f.Close()
+
+	err := er.Parse()
+	log.PanicIf(err)
+
+	ec := er.GetCluster(er.FirstClusterOfRootDirectory())
+
+	expected, err := ec.GetSectorByIndex(1)
+	log.PanicIf(err)
+
+	data := make([]byte, er.SectorSize())
+
+	err = ec.ReadSectorInto(1, data)
+	log.PanicIf(err)
+
+	if bytes.Equal(data, expected) != true {
+		t.Fatalf("Sector data not correct.")
+	}
+
+	err = ec.ReadSectorInto(1, data[:10])
+	if err == nil {
+		t.Fatalf("Expected error for short buffer.")
+	}
+
+	err = ec.ReadSectorInto(er.SectorsPerCluster(), data)
+	if err == nil {
+		t.Fatalf("Expected error for sector-index out of range.")
+	}
+}
+
+func TestExfatReader_WriteFromClusterChain__SectorMultiple(t *testing.T) {
+	tree, closer := getTestTree()
+
+	defer closer()
+
+	node, err := tree.LookupPath("2-delahaye-type-165-cabriolet-dsc_8025.jpg")
+	log.PanicIf(err)
+
+	sede := node.StreamDirectoryEntry()
+
+	full := new(bytes.Buffer)
+
+	_, _, err = tree.er.WriteFromClusterChain(sede.FirstCluster, sede.ValidDataLength, true, full)
+	log.PanicIf(err)
+
+	// Read only the first three sectors, which ends in the middle of the
+	// first cluster.
+	dataSize := uint64(3 * tree.er.SectorSize())
+
+	for _, readAheadClusterCount := range []int{0, 2} {
+		tree.er.SetReadAheadClusterCount(readAheadClusterCount)
+
+		b := new(bytes.Buffer)
+
+		_, visitedSectors, err := tree.er.WriteFromClusterChain(sede.FirstCluster, dataSize, true, b)
+		log.PanicIf(err)
+
+		if bytes.Equal(b.Bytes(), full.Bytes()[:dataSize]) != true {
+			t.Fatalf("Data not correct with read-ahead (%d): (%d)", readAheadClusterCount, b.Len())
+		} else if readAheadClusterCount == 0 && len(visitedSectors) != 3 {
+			t.Fatalf("Visited sector-count not correct: (%d)", len(visitedSectors))
+		}
+	}
+}
+
+func TestExfatReader_WriteFromClusterChain__ExactMultipleWithoutFat(t *testing.T) {
+	tree, closer := getTestTree()
+
+	defer closer()
+
+	// Directories occupy exactly one cluster and have no FAT chain, so
+	// nothing stops the enumeration but the data-size.
+	node, err := tree.LookupPath("testdirectory")
+	log.PanicIf(err)
+
+	sede := node.StreamDirectoryEntry()
+
+	for _, readAheadClusterCount := range []int{0, 2} {
+		tree.er.SetReadAheadClusterCount(readAheadClusterCount)
+
+		b := new(bytes.Buffer)
+
+		visitedClusters, _, err := tree.er.WriteFromClusterChain(sede.FirstCluster, sede.ValidDataLength, false, b)
+		log.PanicIf(err)
+
+		if uint64(b.Len()) != sede.ValidDataLength {
+			t.Fatalf("Written size not correct with read-ahead (%d): (%d)", readAheadClusterCount, b.Len())
+		} else if len(visitedClusters) != 1 {
+			t.Fatalf("Visited cluster-count not correct with read-ahead (%d): (%d)", readAheadClusterCount, len(visitedClusters))
+		}
+	}
+}
+
+// getTestImageWithBadFat returns the test image with the media-type in the
+// first FAT corrupted.
+func getTestImageWithBadFat() []byte {
+	image, er := getTestImage()
+
+	bsh := er.ActiveBootSectorHeader()
+	image[bsh.FatOffset*bsh.SectorSize()] = 0
+
+	return image
+}
+
+func TestExfatReader_Parse__BadFat(t *testing.T) {
+	image := getTestImageWithBadFat()
+
+	er := NewExfatReader(bytes.NewReader(image))
+
+	err := er.Parse()
+	if err == nil {
+		t.Fatalf("Expected error for bad FAT.")
+	}
+}
+
+func TestExfatReader_SetDeferFatParsing(t *testing.T) {
+	image := getTestImageWithBadFat()
+
+	er := NewExfatReader(bytes.NewReader(image))
+	er.SetDeferFatParsing(true)
+
+	err := er.Parse()
+	log.PanicIf(err)
+
+	// Directories and small files don't use the FAT.
+
+	tree := NewTree(er)
+
+	err = tree.Load()
+	log.PanicIf(err)
+
+	node, err := tree.LookupPath(`testdirectory\300daec8-cec3-11e9-bfa2-0f240e41d1d8`)
+	log.PanicIf(err)
+
+	sede := node.StreamDirectoryEntry()
+
+	_, _, err = er.WriteFromClusterChain(sede.FirstCluster, sede.ValidDataLength, false, ioutil.Discard)
+	log.PanicIf(err)
+
+	if er.activeFat != nil {
+		t.Fatalf("FATs should not have been parsed.")
+	}
+
+	// The first file that needs the FAT reports the problem.
+
+	node, err = tree.LookupPath("2-delahaye-type-165-cabriolet-dsc_8025.jpg")
+	log.PanicIf(err)
+
+	sede = node.StreamDirectoryEntry()
+
+	_, _, err = er.WriteFromClusterChain(sede.FirstCluster, sede.ValidDataLength, true, ioutil.Discard)
+	if err == nil {
+		t.Fatalf("Expected error for bad FAT.")
+	}
+
+	_, err = er.ActiveFat()
+	if err == nil {
+		t.Fatalf("Expected error for bad FAT.")
+	}
+}
+
+// getTestLargeImage writes a sparse copy of the test image in which the
+// cluster heap has been moved past 4G, as it would be on a large volume.
+func getTestLargeImage() (f *os.File, closer func()) {
+	image, er := getTestImage()
+
+	bsh := er.ActiveBootSectorHeader()
+	sectorSize := int64(bsh.SectorSize())
+
+	heapOffset := int64(bsh.ClusterHeapOffset) * sectorSize
+	gapSectorCount := uint32((1 << 32) / sectorSize)
+
+	// Both boot regions describe the new layout.
+
+	for i := 0; i < 2; i++ {
+		region := image[int64(i)*bootRegionSectorCount*sectorSize : int64(i+1)*bootRegionSectorCount*sectorSize]
+
+		defaultEncoding.PutUint64(region[72:80], bsh.VolumeLength+uint64(gapSectorCount))
+		defaultEncoding.PutUint32(region[88:92], bsh.ClusterHeapOffset+gapSectorCount)
+
+		fillBootChecksum(region, int(sectorSize))
+	}
+
+	f, err := ioutil.TempFile("", "exfat-large-")
+	log.PanicIf(err)
+
+	closer = func() {
+		f.Close()
+		os.Remove(f.Name())
+	}
+
+	_, err = f.WriteAt(image[:heapOffset], 0)
+	log.PanicIf(err)
+
+	_, err = f.WriteAt(image[heapOffset:], heapOffset+int64(gapSectorCount)*sectorSize)
+	log.PanicIf(err)
+
+	return f, closer
+}
+
+func TestExfatReader__LargeClusterHeapOffset(t *testing.T) {
+	f, closer := getTestLargeImage()
+
+	defer closer()
+
+	er := NewExfatReader(f)
+
+	err := er.Parse()
+	log.PanicIf(err)
+
+	ec := er.GetCluster(er.FirstClusterOfRootDirectory())
+	if ec.clusterOffset <= 1<<32 {
+		t.Fatalf("Cluster offset not past 4G: (%d)", ec.clusterOffset)
+	}
+
+	tree := NewTree(er)
+
+	err = tree.Load()
+	log.PanicIf(err)
+
+	srcTree, srcCloser := getTestTree()
+
+	defer srcCloser()
+
+	for _, filepath := range []string{"2-delahaye-type-165-cabriolet-dsc_8025.jpg", `testdirectory\300daec8-cec3-11e9-bfa2-0f240e41d1d8`} {
+		srcNode, err := srcTree.LookupPath(filepath)
+		log.PanicIf(err)
+
+		expected := new(bytes.Buffer)
+
+		err = srcNode.WriteData(expected, false)
+		log.PanicIf(err)
+
+		node, err := tree.LookupPath(filepath)
+		log.PanicIf(err)
+
+		actual := new(bytes.Buffer)
+
+		err = node.WriteData(actual, false)
+		log.PanicIf(err)
+
+		if bytes.Equal(actual.Bytes(), expected.Bytes()) != true {
+			t.Fatalf("Data not correct: [%s]", filepath)
+		}
+	}
+}
+
+func TestExfatReader_getCurrentSector__Large(t *testing.T) {
+	f, closer := getTestLargeImage()
+
+	defer closer()
+
+	er := NewExfatReader(f)
+
+	err := er.Parse()
+	log.PanicIf(err)
+
+	offset := int64(1<<32) + 3*int64(er.SectorSize()) + 10
+
+	_, err = f.Seek(offset, os.SEEK_SET)
+	log.PanicIf(err)
+
+	sector, remainder := er.getCurrentSector()
+	if sector != uint64(offset)/uint64(er.SectorSize()) || remainder != 10 {
+		t.Fatalf("Current sector not correct: (%d) (%d)", sector, remainder)
+	}
+}
+
+func TestExfatReader_EnumerateClustersLimited(t *testing.T) {
+	f, er := getTestFileAndParser()
+
+	defer f.Close()
+
+	err := er.Parse()
+	log.PanicIf(err)
+
+	// The callback never stops on its own.
+
+	visited := make([]uint32, 0)
+	cb := func(ec *ExfatCluster) (doContinue bool, err error) {
+		visited = append(visited, ec.ClusterNumber())
+		return true, nil
+	}
+
+	err = er.EnumerateClustersLimited(10, er.ClusterCountForLength(3*4096-1), cb, false)
+	log.PanicIf(err)
+
+	if reflect.DeepEqual(visited, []uint32{10, 11, 12}) != true {
+		t.Fatalf("Visited clusters not correct: %v", visited)
+	}
+
+	// Nothing is visited for empty data.
+
+	visited = visited[:0]
+
+	err = er.EnumerateClustersLimited(10, 0, cb, false)
+	log.PanicIf(err)
+
+	if len(visited) != 0 {
+		t.Fatalf("Expected no clusters to be visited: %v", visited)
+	}
+}
+
+func TestExfatReader_EnumerateClusters__PastHeap(t *testing.T) {
+	f, er := getTestFileAndParser()
+
+	defer f.Close()
+
+	err := er.Parse()
+	log.PanicIf(err)
+
+	lastClusterNumber := er.ActiveBootSectorHeader().ClusterCount + 1
+
+	visitedCount := 0
+	cb := func(ec *ExfatCluster) (doContinue bool, err error) {
+		visitedCount++
+		return true, nil
+	}
+
+	err = er.EnumerateClusters(lastClusterNumber-1, cb, false)
+	if errors.Is(err, ErrClusterOutOfRange) != true {
+		t.Fatalf("Expected out-of-range error: [%v]", err)
+	} else if visitedCount != 2 {
+		t.Fatalf("Visited count not correct: (%d)", visitedCount)
+	}
+}
+
+func TestExfatReader_ClusterCountForLength(t *testing.T) {
+	f, er := getTestFileAndParser()
+
+	defer f.Close()
+
+	err := er.Parse()
+	log.PanicIf(err)
+
+	cases := map[uint64]uint64{
+		0:        0,
+		1:        1,
+		4096:     1,
+		4097:     2,
+		313299:   77,
+		3 * 4096: 3,
+	}
+
+	for length, expected := range cases {
+		if clusterCount := er.ClusterCountForLength(length); clusterCount != expected {
+			t.Fatalf("Cluster-count for (%d) not correct: (%d) != (%d)", length, clusterCount, expected)
+		}
+	}
+}
+
+func TestExfatReader_SelectedBootRegion(t *testing.T) {
+	f, er := getTestFileAndParser()
+
+	defer f.Close()
+
+	err := er.Parse()
+	log.PanicIf(err)
+
+	if er.SelectedBootRegion() != BootRegionMain {
+		t.Fatalf("Selected boot region not correct: [%s]", er.SelectedBootRegion())
+	} else if er.ActiveBootSectorHeader() != er.MainBootSectorHeader() {
+		t.Fatalf("Active boot-sector header is not the main one.")
+	} else if er.BackupBootSectorHeader().VolumeSerialNumber != er.MainBootSectorHeader().VolumeSerialNumber {
+		t.Fatalf("Backup boot-sector header not correct: %s", er.BackupBootSectorHeader())
+	}
+}
+
+func TestBootRegionKind_String(t *testing.T) {
+	if BootRegionMain.String() != "main" {
+		t.Fatalf("Main name not correct: [%s]", BootRegionMain)
+	} else if BootRegionBackup.String() != "backup" {
+		t.Fatalf("Backup name not correct: [%s]", BootRegionBackup)
+	} else if BootRegionKind(5).String() != "BootRegionKind(5)" {
+		t.Fatalf("Unknown name not correct: [%s]", BootRegionKind(5))
+	}
+}
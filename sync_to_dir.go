@@ -0,0 +1,396 @@
+// This package supports one-way synchronization from a volume to a local
+// directory.
+
+package exfat
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/dsoprea/go-logging"
+)
+
+// SyncCompareMode determines how a file that already exists locally is
+// determined to be current.
+type SyncCompareMode int
+
+const (
+	// SyncCompareSizeAndModifiedTime considers a local file to be current if
+	// its size and modified-time (to the second) match. This only requires
+	// reading metadata.
+	SyncCompareSizeAndModifiedTime SyncCompareMode = iota
+
+	// SyncCompareHash considers a local file to be current if its size and
+	// hash match. This requires reading the data of both files.
+	SyncCompareHash
+)
+
+// String returns a descriptive string.
+func (scm SyncCompareMode) String() string {
+	switch scm {
+	case SyncCompareSizeAndModifiedTime:
+		return "size-and-mtime"
+	case SyncCompareHash:
+		return "hash"
+	}
+
+	return fmt.Sprintf("SyncCompareMode<%d>", int(scm))
+}
+
+// SyncOptions are the options for SyncToDir().
+type SyncOptions struct {
+	// CompareMode determines how existing local files are compared.
+	CompareMode SyncCompareMode
+
+	// HashName is the registered name of the hash algorithm to use with
+	// SyncCompareHash (see RegisterHash). Defaults to DefaultHashName.
+	HashName string
+
+	// DeleteRemoved removes local files and directories that don't exist on
+	// the volume.
+	DeleteRemoved bool
+
+	// CaseInsensitive should be true if the local filesystem is case-
+	// insensitive. Names that only differ by case are given distinct local
+	// names (see HostPathMapper).
+	CaseInsensitive bool
+}
+
+// SyncSummary describes what SyncToDir() did.
+type SyncSummary struct {
+	// Created are the volume paths of files that did not exist locally.
+	Created []string
+
+	// Updated are the volume paths of files that existed locally but were not
+	// current.
+	Updated []string
+
+	// Unchanged is the count of files that were already current.
+	Unchanged int
+
+	// Deleted are the local paths that were removed (only with
+	// DeleteRemoved).
+	Deleted []string
+
+	// BytesCopied is the total amount of file data that was written.
+	BytesCopied uint64
+}
+
+// String returns a descriptive string.
+func (ss SyncSummary) String() string {
+	return fmt.Sprintf("SyncSummary<CREATED=(%d) UPDATED=(%d) UNCHANGED=(%d) DELETED=(%d) BYTES=(%d)>", len(ss.Created), len(ss.Updated), ss.Unchanged, len(ss.Deleted), ss.BytesCopied)
+}
+
+// Dump prints the summary.
+func (ss SyncSummary) Dump() {
+	ss.DumpTo(os.Stdout)
+}
+
+// DumpTo is the same as Dump() but writes to the given writer.
+func (ss SyncSummary) DumpTo(w io.Writer) {
+	fmt.Fprintf(w, "Sync Summary\n")
+	fmt.Fprintf(w, "============\n")
+	fmt.Fprintf(w, "\n")
+
+	for _, volumePath := range ss.Created {
+		fmt.Fprintf(w, "Created: %s\n", volumePath)
+	}
+
+	for _, volumePath := range ss.Updated {
+		fmt.Fprintf(w, "Updated: %s\n", volumePath)
+	}
+
+	for _, hostPath := range ss.Deleted {
+		fmt.Fprintf(w, "Deleted: %s\n", hostPath)
+	}
+
+	fmt.Fprintf(w, "\n")
+	fmt.Fprintf(w, "Created: (%d)\n", len(ss.Created))
+	fmt.Fprintf(w, "Updated: (%d)\n", len(ss.Updated))
+	fmt.Fprintf(w, "Unchanged: (%d)\n", ss.Unchanged)
+	fmt.Fprintf(w, "Deleted: (%d)\n", len(ss.Deleted))
+	fmt.Fprintf(w, "Bytes copied: (%d)\n", ss.BytesCopied)
+	fmt.Fprintf(w, "\n")
+}
+
+// SyncToDir copies the directory at `volumePath` (empty for the whole volume)
+// to `destDir`, only writing files that are new or that have changed since a
+// previous sync. Files are written to a temporary file and then renamed into
+// place so that an interrupted sync never leaves a partial file under the
+// final name. Modified-times are copied from the volume. Deleted entries are
+// ignored.
+func SyncToDir(tree *Tree, volumePath string, destDir string, opts SyncOptions) (summary SyncSummary, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	rootNode, err := tree.LookupPath(volumePath)
+	log.PanicIf(err)
+
+	if rootNode == nil {
+		log.Panicf("volume path not found: [%s]", volumePath)
+	} else if rootNode.IsDirectory() == false {
+		log.Panicf("volume path is not a directory: [%s]", volumePath)
+	}
+
+	hashName := opts.HashName
+	if hashName == "" {
+		hashName = DefaultHashName
+	}
+
+	newHash, err := LookupHash(hashName)
+	log.PanicIf(err)
+
+	summary.Created = make([]string, 0)
+	summary.Updated = make([]string, 0)
+	summary.Deleted = make([]string, 0)
+
+	destDir = filepath.Clean(destDir)
+
+	err = os.MkdirAll(destDir, 0755)
+	log.PanicIf(err)
+
+	hpm := NewHostPathMapper(destDir, opts.CaseInsensitive)
+
+	// Every host path that corresponds to something on the volume.
+	kept := make(map[string]bool)
+
+	// The volume paths of directories that are not in use. Their children are
+	// skipped, too.
+	skipped := make(map[string]bool)
+
+	rootPathParts := SplitVolumePath(volumePath)
+
+	cb := func(pathParts []string, node *TreeNode) (err error) {
+		defer func() {
+			if errRaw := recover(); errRaw != nil {
+				err = log.Wrap(errRaw.(error))
+			}
+		}()
+
+		if len(pathParts) == 0 {
+			return nil
+		}
+
+		relativeVolumePath := JoinVolumePath(pathParts)
+
+		if node.IsInUse() == false || skipped[JoinVolumePath(pathParts[:len(pathParts)-1])] == true {
+			if node.IsDirectory() == true {
+				skipped[relativeVolumePath] = true
+			}
+
+			return nil
+		}
+
+		hostPath, err := hpm.HostPathFromParts(pathParts)
+		log.PanicIf(err)
+
+		kept[hostPath] = true
+
+		if node.IsDirectory() == true {
+			err := os.MkdirAll(hostPath, 0755)
+			log.PanicIf(err)
+
+			return nil
+		}
+
+		fullPathParts := append(append([]string{}, rootPathParts...), pathParts...)
+		fullVolumePath := JoinVolumePath(fullPathParts)
+
+		fi, err := os.Stat(hostPath)
+		if err != nil {
+			if os.IsNotExist(err) == false {
+				log.Panic(err)
+			}
+
+			fi = nil
+		}
+
+		if fi != nil {
+			if fi.IsDir() == true {
+				log.Panicf("local path is a directory but volume path is a file: [%s] [%s]", hostPath, fullVolumePath)
+			}
+
+			isCurrent, err := isSyncedFileCurrent(tree, node, hostPath, fi, opts.CompareMode, newHash)
+			log.PanicIf(err)
+
+			if isCurrent == true {
+				summary.Unchanged++
+				return nil
+			}
+		}
+
+		err = syncFile(tree, node, hostPath)
+		log.PanicIf(err)
+
+		if fi == nil {
+			summary.Created = append(summary.Created, fullVolumePath)
+		} else {
+			summary.Updated = append(summary.Updated, fullVolumePath)
+		}
+
+		summary.BytesCopied += node.StreamDirectoryEntry().ValidDataLength
+
+		return nil
+	}
+
+	err = tree.visit(make([]string, 0), rootNode, cb)
+	log.PanicIf(err)
+
+	if opts.DeleteRemoved == true {
+		summary.Deleted, err = removeUnkeptPaths(destDir, kept)
+		log.PanicIf(err)
+	}
+
+	return summary, nil
+}
+
+// isSyncedFileCurrent indicates whether the local copy of the given node is
+// current.
+func isSyncedFileCurrent(tree *Tree, node *TreeNode, hostPath string, fi os.FileInfo, compareMode SyncCompareMode, newHash HashFactory) (isCurrent bool, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	sede := node.StreamDirectoryEntry()
+
+	if uint64(fi.Size()) != sede.ValidDataLength {
+		return false, nil
+	}
+
+	switch compareMode {
+	case SyncCompareSizeAndModifiedTime:
+		// Local filesystems don't all have the same precision as exFAT (10ms).
+		localTime := fi.ModTime().Truncate(time.Second)
+		volumeTime := node.FileDirectoryEntry().LastModifiedTimestamp().Truncate(time.Second)
+
+		return localTime.Equal(volumeTime), nil
+
+	case SyncCompareHash:
+		h := newHash()
+
+		if sede.ValidDataLength > 0 {
+			useFat := sede.GeneralSecondaryFlags.NoFatChain() == false
+
+			_, _, err := tree.er.WriteFromClusterChain(sede.FirstCluster, sede.ValidDataLength, useFat, h)
+			log.PanicIf(err)
+		}
+
+		f, err := os.Open(hostPath)
+		log.PanicIf(err)
+
+		defer f.Close()
+
+		g := newHash()
+
+		_, err = io.Copy(g, f)
+		log.PanicIf(err)
+
+		return bytes.Equal(h.Sum(nil), g.Sum(nil)), nil
+	}
+
+	log.Panicf("compare-mode not valid: [%s]", compareMode)
+	return false, nil
+}
+
+// syncFile writes the data for the given node to a temporary file alongside
+// `hostPath`, sets its modified-time, and renames it into place.
+func syncFile(tree *Tree, node *TreeNode, hostPath string) (err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	sede := node.StreamDirectoryEntry()
+
+	f, err := ioutil.TempFile(filepath.Dir(hostPath), ".exfat-sync-")
+	log.PanicIf(err)
+
+	tempFilepath := f.Name()
+
+	isDone := false
+
+	defer func() {
+		if isDone == false {
+			f.Close()
+			os.Remove(tempFilepath)
+		}
+	}()
+
+	if sede.ValidDataLength > 0 {
+		useFat := sede.GeneralSecondaryFlags.NoFatChain() == false
+
+		_, _, err := tree.er.WriteFromClusterChain(sede.FirstCluster, sede.ValidDataLength, useFat, f)
+		log.PanicIf(err)
+	}
+
+	err = f.Close()
+	log.PanicIf(err)
+
+	err = os.Chmod(tempFilepath, 0644)
+	log.PanicIf(err)
+
+	mtime := node.FileDirectoryEntry().LastModifiedTimestamp()
+
+	err = os.Chtimes(tempFilepath, mtime, mtime)
+	log.PanicIf(err)
+
+	err = os.Rename(tempFilepath, hostPath)
+	log.PanicIf(err)
+
+	isDone = true
+
+	return nil
+}
+
+// removeUnkeptPaths removes everything under `destDir` that isn't in `kept`.
+func removeUnkeptPaths(destDir string, kept map[string]bool) (deleted []string, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	deleted = make([]string, 0)
+
+	walkFn := func(hostPath string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if hostPath == destDir || kept[hostPath] == true {
+			return nil
+		}
+
+		err = os.RemoveAll(hostPath)
+		if err != nil {
+			return err
+		}
+
+		deleted = append(deleted, hostPath)
+
+		if fi.IsDir() == true {
+			return filepath.SkipDir
+		}
+
+		return nil
+	}
+
+	err = filepath.Walk(destDir, walkFn)
+	log.PanicIf(err)
+
+	sort.Strings(deleted)
+
+	return deleted, nil
+}
@@ -0,0 +1,143 @@
+package exfat
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/dsoprea/go-logging"
+)
+
+func TestSyncToDir(t *testing.T) {
+	tree, closer := getTestTree()
+
+	defer closer()
+
+	destDir, err := ioutil.TempDir("", "exfat-sync")
+	log.PanicIf(err)
+
+	defer os.RemoveAll(destDir)
+
+	summary, err := SyncToDir(tree, "", destDir, SyncOptions{})
+	log.PanicIf(err)
+
+	// Deleted files are not synced.
+	if len(summary.Created) != 7 {
+		t.Fatalf("Created count not correct: %v", summary.Created)
+	} else if len(summary.Updated) != 0 || summary.Unchanged != 0 || len(summary.Deleted) != 0 {
+		t.Fatalf("Summary not correct: %s", summary)
+	}
+
+	hostFilepath := filepath.Join(destDir, "2-delahaye-type-165-cabriolet-dsc_8025.jpg")
+
+	data, err := ioutil.ReadFile(hostFilepath)
+	log.PanicIf(err)
+
+	_, expectedData := getTestFileData(tree, "2-delahaye-type-165-cabriolet-dsc_8025.jpg")
+	if reflect.DeepEqual(data, expectedData) != true {
+		t.Fatalf("Synced data not correct.")
+	}
+
+	fi, err := os.Stat(hostFilepath)
+	log.PanicIf(err)
+
+	node, err := tree.LookupPath("2-delahaye-type-165-cabriolet-dsc_8025.jpg")
+	log.PanicIf(err)
+
+	mtime := node.FileDirectoryEntry().LastModifiedTimestamp()
+	if fi.ModTime().Truncate(time.Second).Equal(mtime.Truncate(time.Second)) != true {
+		t.Fatalf("Modified-time not correct: [%s] != [%s]", fi.ModTime(), mtime)
+	}
+
+	if _, err := os.Stat(filepath.Join(destDir, "testdirectory2", "file1")); os.IsNotExist(err) == false {
+		t.Fatalf("Deleted file should not have been synced.")
+	}
+
+	// A second sync shouldn't copy anything.
+
+	summary, err = SyncToDir(tree, "", destDir, SyncOptions{})
+	log.PanicIf(err)
+
+	if len(summary.Created) != 0 || len(summary.Updated) != 0 || summary.Unchanged != 7 || summary.BytesCopied != 0 {
+		t.Fatalf("Second sync not correct: %s", summary)
+	}
+}
+
+func TestSyncToDir__ChangedAndRemoved(t *testing.T) {
+	tree, closer := getTestTree()
+
+	defer closer()
+
+	destDir, err := ioutil.TempDir("", "exfat-sync")
+	log.PanicIf(err)
+
+	defer os.RemoveAll(destDir)
+
+	_, err = SyncToDir(tree, "testdirectory2", destDir, SyncOptions{})
+	log.PanicIf(err)
+
+	// Modify one file without changing its size or modified-time, so that
+	// only a hash comparison will notice.
+
+	changedFilepath := filepath.Join(destDir, "00c57ab0-cec3-11e9-b750-bbed8d2244c8")
+
+	fi, err := os.Stat(changedFilepath)
+	log.PanicIf(err)
+
+	data, err := ioutil.ReadFile(changedFilepath)
+	log.PanicIf(err)
+
+	data[0]++
+
+	err = ioutil.WriteFile(changedFilepath, data, 0644)
+	log.PanicIf(err)
+
+	err = os.Chtimes(changedFilepath, fi.ModTime(), fi.ModTime())
+	log.PanicIf(err)
+
+	extraFilepath := filepath.Join(destDir, "extra")
+
+	err = ioutil.WriteFile(extraFilepath, []byte("extra"), 0644)
+	log.PanicIf(err)
+
+	summary, err := SyncToDir(tree, "testdirectory2", destDir, SyncOptions{})
+	log.PanicIf(err)
+
+	if len(summary.Updated) != 0 || summary.Unchanged != 2 || len(summary.Deleted) != 0 {
+		t.Fatalf("Size/mtime sync not correct: %s", summary)
+	}
+
+	opts := SyncOptions{
+		CompareMode:   SyncCompareHash,
+		DeleteRemoved: true,
+	}
+
+	summary, err = SyncToDir(tree, "testdirectory2", destDir, opts)
+	log.PanicIf(err)
+
+	if reflect.DeepEqual(summary.Updated, []string{`testdirectory2\00c57ab0-cec3-11e9-b750-bbed8d2244c8`}) != true {
+		t.Fatalf("Updated not correct: %v", summary.Updated)
+	} else if reflect.DeepEqual(summary.Deleted, []string{extraFilepath}) != true {
+		t.Fatalf("Deleted not correct: %v", summary.Deleted)
+	} else if summary.Unchanged != 1 {
+		t.Fatalf("Unchanged not correct: (%d)", summary.Unchanged)
+	}
+
+	if _, err := os.Stat(extraFilepath); os.IsNotExist(err) == false {
+		t.Fatalf("Removed file was not deleted.")
+	}
+}
+
+func TestSyncToDir__NotDirectory(t *testing.T) {
+	tree, closer := getTestTree()
+
+	defer closer()
+
+	_, err := SyncToDir(tree, "2-delahaye-type-165-cabriolet-dsc_8025.jpg", "unused", SyncOptions{})
+	if err == nil {
+		t.Fatalf("Expected error for non-directory.")
+	}
+}
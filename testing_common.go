@@ -1,7 +1,11 @@
 package exfat
 
 import (
+	"bytes"
+	"io/ioutil"
 	"path"
+
+	"github.com/dsoprea/go-logging"
 )
 
 var (
@@ -11,3 +15,17 @@ var (
 func init() {
 	assetPath = path.Join("test", "assets")
 }
+
+// getTestImage returns a copy of the test image, which the caller is free to
+// change, along with a reader over it that has already been parsed.
+func getTestImage() (image []byte, er *ExfatReader) {
+	image, err := ioutil.ReadFile(path.Join(assetPath, "test.exfat"))
+	log.PanicIf(err)
+
+	er = NewExfatReader(bytes.NewReader(image))
+
+	err = er.Parse()
+	log.PanicIf(err)
+
+	return image, er
+}
@@ -3,12 +3,23 @@
 package exfat
 
 import (
+	"errors"
+	"io"
 	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
 
 	"github.com/dsoprea/go-logging"
 )
 
+var (
+	// ErrSpecialEntry is matched (with errors.Is) by the error returned when a
+	// directory has a special entry and the tree's SpecialEntryPolicy is
+	// SpecialEntryPolicyError. See IsSpecialEntry().
+	ErrSpecialEntry = errors.New("special directory entry")
+)
+
 // TreeNode represents a single file or directory.
 type TreeNode struct {
 	name string
@@ -25,13 +36,36 @@ type TreeNode struct {
 	childrenFiles   sort.StringSlice
 
 	childrenMap map[string]*TreeNode
+
+	// tree is the tree that the node belongs to, if any.
+	tree *Tree
+
+	// parent is the directory that the node was found in. It is nil for the
+	// root and for nodes that were created directly.
+	parent *TreeNode
+
+	// readDirState is the cursor for ReadDirN(). It's created under the lock.
+	readDirState *treeNodeReadDirState
+
+	// loadError is the error that prevented this directory from being
+	// loaded, if the tree is recording rather than failing on errors.
+	loadError error
+
+	// totals caches the totals of a directory once they're computed.
+	totals       *TreeTotals
+	totalsLocker sync.Mutex
+
+	// locker protects the children and the loaded state. Once a directory is
+	// loaded, its children no longer change. It isn't taken once the tree is
+	// frozen.
+	locker sync.Mutex
 }
 
 // NewTreeNode returns a new instance of TreeNode.
 func NewTreeNode(name string, isDirectory bool, ide IndexedDirectoryEntry, fde *ExfatFileDirectoryEntry, sede *ExfatStreamExtensionDirectoryEntry) (tn *TreeNode) {
-	childrenList := make(sort.StringSlice, 0)
 	childrenMap := make(map[string]*TreeNode)
 
+	// The two lists must not share storage.
 	tn = &TreeNode{
 		name:        name,
 		isDirectory: isDirectory,
@@ -40,8 +74,8 @@ func NewTreeNode(name string, isDirectory bool, ide IndexedDirectoryEntry, fde *
 		sede: sede,
 		fde:  fde,
 
-		childrenFolders: childrenList,
-		childrenFiles:   childrenList,
+		childrenFolders: make(sort.StringSlice, 0),
+		childrenFiles:   make(sort.StringSlice, 0),
 
 		childrenMap: childrenMap,
 	}
@@ -55,6 +89,35 @@ func (tn *TreeNode) Name() string {
 	return tn.name
 }
 
+// Parent returns the node for the directory that this node is in, or nil for
+// the root.
+func (tn *TreeNode) Parent() *TreeNode {
+	return tn.parent
+}
+
+// PathParts returns the components of the node's absolute path, as Visit()
+// would pass them. It is empty for the root.
+func (tn *TreeNode) PathParts() []string {
+	depth := 0
+	for current := tn; current.parent != nil; current = current.parent {
+		depth++
+	}
+
+	pathParts := make([]string, depth)
+	for current := tn; current.parent != nil; current = current.parent {
+		depth--
+		pathParts[depth] = current.name
+	}
+
+	return pathParts
+}
+
+// Path returns the node's absolute path in the form that `Tree.List()`
+// returns. It is empty for the root.
+func (tn *TreeNode) Path() string {
+	return JoinVolumePath(tn.PathParts())
+}
+
 // IndexedDirectoryEntry returns the underlying, low-level directory-entry
 // information that were retrieved for this directory.
 func (tn *TreeNode) IndexedDirectoryEntry() IndexedDirectoryEntry {
@@ -80,18 +143,149 @@ func (tn *TreeNode) IsDirectory() bool {
 	return tn.isDirectory
 }
 
+// IsInUse indicates whether the node's directory-entries are marked as being
+// in use. Entries that are no longer in use (e.g. deleted files) are still
+// indexed but their data can not be relied upon.
+func (tn *TreeNode) IsInUse() bool {
+	if tn.ide.EntrySet == nil {
+		return true
+	}
+
+	return tn.ide.EntrySet.IsInUse()
+}
+
+// Size returns the amount of data that has actually been written to the file
+// (ValidDataLength). This is what a read of the file returns. It is zero for
+// the root.
+func (tn *TreeNode) Size() uint64 {
+	if tn.sede == nil {
+		return 0
+	}
+
+	return tn.sede.ValidDataLength
+}
+
+// AllocatedSize returns the size of the data stream that is allocated to the
+// file (DataLength), which may be more than has been written.
+func (tn *TreeNode) AllocatedSize() uint64 {
+	if tn.sede == nil {
+		return 0
+	}
+
+	return tn.sede.DataLength
+}
+
+// SlackBytes returns the number of bytes that are allocated to the file beyond
+// its valid data (DataLength - ValidDataLength). The spec leaves their content
+// undefined, so they may hold remnants of earlier data. See WriteData().
+func (tn *TreeNode) SlackBytes() uint64 {
+	if tn.sede == nil || tn.sede.DataLength < tn.sede.ValidDataLength {
+		return 0
+	}
+
+	return tn.sede.DataLength - tn.sede.ValidDataLength
+}
+
+// WriteData writes the file's data to the given writer. If `includeSlack` is
+// true, the slack that follows the valid data is written as it exists on the
+// volume (see SlackBytes()) rather than being left off. The node must belong
+// to a tree.
+func (tn *TreeNode) WriteData(w io.Writer, includeSlack bool) (err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	if tn.tree == nil {
+		log.Panicf("node does not belong to a tree: [%s]", tn.name)
+	} else if tn.isDirectory == true {
+		log.Panicf("node is a directory: [%s]", tn.name)
+	}
+
+	dataSize := tn.Size()
+	if includeSlack == true && tn.AllocatedSize() > dataSize {
+		dataSize = tn.AllocatedSize()
+	}
+
+	if dataSize == 0 {
+		return nil
+	}
+
+	useFat := tn.sede.GeneralSecondaryFlags.NoFatChain() == false
+
+	_, _, err = tn.tree.er.WriteFromClusterChain(tn.sede.FirstCluster, dataSize, useFat, w)
+	log.PanicIf(err)
+
+	return nil
+}
+
+// IsSpecial indicates whether the node is for a special entry. See
+// IsSpecialEntry().
+func (tn *TreeNode) IsSpecial() bool {
+	if tn.fde == nil {
+		return false
+	}
+
+	return IsSpecialEntry(tn.name, tn.fde.FileAttributes)
+}
+
+// LoadError returns the error that was encountered while loading this
+// directory's children, if any. This is only ever set when the tree's error
+// policy is TreeErrorPolicyRecord. The children that were read before the
+// failure, if any, are still available.
+func (tn *TreeNode) LoadError() error {
+	if tn.isFrozen() == false {
+		tn.locker.Lock()
+		defer tn.locker.Unlock()
+	}
+
+	return tn.loadError
+}
+
+// isFrozen indicates whether the node belongs to a frozen tree, in which case
+// nothing can change and no locking is needed.
+func (tn *TreeNode) isFrozen() bool {
+	return tn.tree != nil && tn.tree.IsFrozen() == true
+}
+
+// isLoaded indicates whether the children have been loaded.
+func (tn *TreeNode) isLoaded() bool {
+	if tn.isFrozen() == false {
+		tn.locker.Lock()
+		defer tn.locker.Unlock()
+	}
+
+	return tn.loaded
+}
+
 // ChildFolders lists any child-folders. Only applies to directory nodes.
 func (tn *TreeNode) ChildFolders() []string {
+	if tn.isFrozen() == false {
+		tn.locker.Lock()
+		defer tn.locker.Unlock()
+	}
+
 	return tn.childrenFolders
 }
 
 // ChildFiles lists any child files. Only applies to directory nodes.
 func (tn *TreeNode) ChildFiles() []string {
+	if tn.isFrozen() == false {
+		tn.locker.Lock()
+		defer tn.locker.Unlock()
+	}
+
 	return tn.childrenFiles
 }
 
 // GetChild a particular child node.
 func (tn *TreeNode) GetChild(filename string) *TreeNode {
+	if tn.isFrozen() == false {
+		tn.locker.Lock()
+		defer tn.locker.Unlock()
+	}
+
 	return tn.childrenMap[filename]
 }
 
@@ -102,7 +296,7 @@ func (tn *TreeNode) Lookup(pathParts []string) (lastPathParts []string, lastNode
 		return nil, nil, tn
 	}
 
-	childNode := tn.childrenMap[pathParts[0]]
+	childNode := tn.GetChild(pathParts[0])
 	if childNode == nil {
 		// An intermediate part was not found.
 		return pathParts, tn, nil
@@ -113,8 +307,31 @@ func (tn *TreeNode) Lookup(pathParts []string) (lastPathParts []string, lastNode
 }
 
 // AddChild registers a new child to this node. It's stored in sorted order.
+// A child that already has the same name is replaced. This must not be called
+// on a node of a frozen tree.
 func (tn *TreeNode) AddChild(name string, isDirectory bool, fde *ExfatFileDirectoryEntry, sede *ExfatStreamExtensionDirectoryEntry, ide IndexedDirectoryEntry) *TreeNode {
+	tn.locker.Lock()
+	defer tn.locker.Unlock()
+
+	childNode := tn.addChild(name, isDirectory, fde, sede, ide)
+	tn.invalidateTotals()
+
+	return childNode
+}
+
+// addChild registers a new child. The lock must be held.
+//
+// The name lists are never modified in place. They are replaced, so that a
+// list that was already returned by ChildFolders() or ChildFiles() doesn't
+// change underneath the caller.
+func (tn *TreeNode) addChild(name string, isDirectory bool, fde *ExfatFileDirectoryEntry, sede *ExfatStreamExtensionDirectoryEntry, ide IndexedDirectoryEntry) *TreeNode {
+	if _, found := tn.childrenMap[name]; found == true {
+		tn.removeChild(name)
+	}
+
 	childNode := NewTreeNode(name, isDirectory, ide, fde, sede)
+	childNode.tree = tn.tree
+	childNode.parent = tn
 
 	// The adds are driven through a process based on a map, so the order will
 	// always be random. Use insertion sort to order the children so their order
@@ -127,20 +344,23 @@ func (tn *TreeNode) AddChild(name string, isDirectory bool, fde *ExfatFileDirect
 		list = tn.childrenFiles
 	}
 
-	insertOrEqualAt := list.Search(name)
+	insertAt := list.Search(name)
 
-	if insertOrEqualAt >= len(list) {
-		list = append(list, name)
-	} else if list[insertOrEqualAt] != name {
-		leftHalf := list[:insertOrEqualAt]
-		rightHalf := list[insertOrEqualAt:]
-		list = append(leftHalf, append([]string{name}, rightHalf...)...)
+	var updated sort.StringSlice
+	if insertAt >= len(list) {
+		// Appending never changes what a shorter, earlier list can see.
+		updated = append(list, name)
+	} else {
+		updated = make(sort.StringSlice, 0, len(list)+1)
+		updated = append(updated, list[:insertAt]...)
+		updated = append(updated, name)
+		updated = append(updated, list[insertAt:]...)
 	}
 
 	if isDirectory == true {
-		tn.childrenFolders = list
+		tn.childrenFolders = updated
 	} else {
-		tn.childrenFiles = list
+		tn.childrenFiles = updated
 	}
 
 	tn.childrenMap[name] = childNode
@@ -148,20 +368,185 @@ func (tn *TreeNode) AddChild(name string, isDirectory bool, fde *ExfatFileDirect
 	return childNode
 }
 
-// Tree is a higher-level struct that wraps the root-node.
+// RemoveChild unregisters the child with the given name and returns it, or
+// returns nil if there isn't one. The removed node no longer has a parent.
+// This must not be called on a node of a frozen tree.
+func (tn *TreeNode) RemoveChild(name string) *TreeNode {
+	tn.locker.Lock()
+	defer tn.locker.Unlock()
+
+	childNode := tn.removeChild(name)
+	if childNode != nil {
+		tn.invalidateTotals()
+	}
+
+	return childNode
+}
+
+// removeChild unregisters a child. The lock must be held.
+func (tn *TreeNode) removeChild(name string) *TreeNode {
+	childNode, found := tn.childrenMap[name]
+	if found == false {
+		return nil
+	}
+
+	var list sort.StringSlice
+	if childNode.isDirectory == true {
+		list = tn.childrenFolders
+	} else {
+		list = tn.childrenFiles
+	}
+
+	updated := make(sort.StringSlice, 0, len(list))
+	for _, current := range list {
+		if current != name {
+			updated = append(updated, current)
+		}
+	}
+
+	if childNode.isDirectory == true {
+		tn.childrenFolders = updated
+	} else {
+		tn.childrenFiles = updated
+	}
+
+	delete(tn.childrenMap, name)
+	childNode.parent = nil
+
+	return childNode
+}
+
+// TreeErrorPolicy determines what happens when a directory can not be loaded.
+type TreeErrorPolicy int
+
+const (
+	// TreeErrorPolicyFail stops the load, lookup, or traversal and returns the
+	// error. This is the default.
+	TreeErrorPolicyFail TreeErrorPolicy = iota
+
+	// TreeErrorPolicyRecord stores the error on the directory's node (see
+	// TreeNode.LoadError()) and carries on with the rest of the tree as if the
+	// directory had no further children. This is useful when recovering data
+	// from a damaged volume.
+	TreeErrorPolicyRecord
+)
+
+// IsSpecialEntry indicates whether a file entry is one that a conforming
+// writer wouldn't create: one named "." or ".." (which exFAT directories
+// don't have) or one with reserved attribute bits set (e.g. a Windows junction
+// or other reparse point that was copied on by a tool that carries the bits
+// over).
+func IsSpecialEntry(name string, attributes FileAttributes) bool {
+	return name == "." || name == ".." || attributes.ReservedBits() != 0
+}
+
+// SpecialEntryPolicy determines what happens to special entries (see
+// IsSpecialEntry()) when a directory is loaded.
+type SpecialEntryPolicy int
+
+const (
+	// SpecialEntryPolicyExpose adds them to the tree like any other entry (see
+	// TreeNode.IsSpecial()). This is the default.
+	SpecialEntryPolicyExpose SpecialEntryPolicy = iota
+
+	// SpecialEntryPolicySkip leaves them out of the tree.
+	SpecialEntryPolicySkip
+
+	// SpecialEntryPolicyError fails the load of the directory with a
+	// CorruptionError that matches ErrSpecialEntry. The error policy then
+	// applies as for any other error.
+	SpecialEntryPolicyError
+)
+
+// Tree is a higher-level struct that wraps the root-node. Directories are
+// loaded as they are first needed, and a Tree may be shared by several
+// goroutines (e.g. a server's handlers). Each directory is only loaded once,
+// and lookups of directories that are already loaded don't contend with
+// loads of others. The progress callback may then be called concurrently.
+// LoadAll() loads everything up-front and then drops the locking altogether.
 type Tree struct {
 	er       *ExfatReader
 	rootNode *TreeNode
+
+	progressCb EnumerationProgressFunc
+
+	errorPolicy        TreeErrorPolicy
+	specialEntryPolicy SpecialEntryPolicy
+
+	// frozen is set (atomically) once every directory has been loaded by
+	// LoadAll().
+	frozen int32
 }
 
 // NewTree returns a new Tree instance.
 func NewTree(er *ExfatReader) *Tree {
 	rootNode := NewTreeNode("", true, IndexedDirectoryEntry{}, nil, nil)
 
-	return &Tree{
+	tree := &Tree{
 		er:       er,
 		rootNode: rootNode,
 	}
+
+	rootNode.tree = tree
+
+	return tree
+}
+
+// SetProgressCallback sets a callback that is periodically invoked while each
+// directory is being indexed. See ExfatNavigator.SetProgressCallback().
+func (tree *Tree) SetProgressCallback(cb EnumerationProgressFunc) {
+	tree.progressCb = cb
+}
+
+// SetErrorPolicy sets what happens when a directory can not be loaded.
+func (tree *Tree) SetErrorPolicy(policy TreeErrorPolicy) {
+	tree.errorPolicy = policy
+}
+
+// SetSpecialEntryPolicy sets what happens to special entries. It must be set
+// before anything is loaded.
+func (tree *Tree) SetSpecialEntryPolicy(policy SpecialEntryPolicy) {
+	tree.specialEntryPolicy = policy
+}
+
+// loadNode loads the children of the given directory node, applying the error
+// policy, if they haven't been loaded already. This is safe to call
+// concurrently.
+func (tree *Tree) loadNode(node *TreeNode) (err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	// Everything in a frozen tree is already loaded.
+	if tree.IsFrozen() == true {
+		return nil
+	}
+
+	node.locker.Lock()
+	defer node.locker.Unlock()
+
+	if node.loaded == true {
+		return nil
+	}
+
+	clusterNumber := tree.er.FirstClusterOfRootDirectory()
+	if node.sede != nil {
+		clusterNumber = node.sede.FirstCluster
+	}
+
+	err = tree.loadDirectory(clusterNumber, node)
+	if err != nil {
+		if tree.errorPolicy != TreeErrorPolicyRecord {
+			log.Panic(err)
+		}
+
+		node.loadError = err
+		node.loaded = true
+	}
+
+	return nil
 }
 
 func (tree *Tree) loadDirectory(clusterNumber uint32, node *TreeNode) (err error) {
@@ -172,23 +557,36 @@ func (tree *Tree) loadDirectory(clusterNumber uint32, node *TreeNode) (err error
 	}()
 
 	en := NewExfatNavigator(tree.er, clusterNumber)
+	en.SetProgressCallback(tree.progressCb)
+
+	if node.sede != nil {
+		en.SetDataLength(node.sede.DataLength)
+	}
 
 	index, _, _, err := en.IndexDirectoryEntries()
 	log.PanicIf(err)
 
-	filenames := index.Filenames()
-
-	for filename, isDirectory := range filenames {
-		ide, found := index.FindIndexedFile(filename)
-		if found != true {
-			log.Panicf("could not find indexed-entry for filename that definitely exists: [%s]", filename)
+	// Iterate the file entries directly rather than looking each one up by
+	// name, which would be quadratic in the size of the directory.
+	for _, ide := range index["File"] {
+		// If the same name appears more than once, the first one wins unless
+		// it was deleted and a later one is in use (e.g. a file that was
+		// deleted and then created again).
+		if existing, found := node.childrenMap[ide.Filename]; found == true {
+			if existing.IsInUse() == true || ide.EntrySet.IsInUse() == false {
+				continue
+			}
 		}
 
-		fde := index.FindIndexedFileFileDirectoryEntry(filename)
-		sede := index.FindIndexedFileStreamExtensionDirectoryEntry(filename)
+		fde, sede, isSkipped, err := tree.fileEntries(ide)
+		log.PanicIf(err)
+
+		if isSkipped == true {
+			continue
+		}
 
 		// Since we load lazily, we won't immediately load the child.
-		node.AddChild(filename, isDirectory, fde, sede, ide)
+		node.addChild(ide.Filename, fde.FileAttributes.IsDirectory(), fde, sede, ide)
 	}
 
 	node.loaded = true
@@ -196,6 +594,51 @@ func (tree *Tree) loadDirectory(clusterNumber uint32, node *TreeNode) (err error
 	return nil
 }
 
+// fileEntries returns the file and stream-extension entries of the given file
+// entry-set. `isSkipped` is true if the special-entry policy leaves it out.
+func (tree *Tree) fileEntries(ide IndexedDirectoryEntry) (fde *ExfatFileDirectoryEntry, sede *ExfatStreamExtensionDirectoryEntry, isSkipped bool, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	fde = ide.PrimaryEntry.(*ExfatFileDirectoryEntry)
+
+	if IsSpecialEntry(ide.Filename, fde.FileAttributes) == true {
+		if tree.specialEntryPolicy == SpecialEntryPolicySkip {
+			return nil, nil, true, nil
+		} else if tree.specialEntryPolicy == SpecialEntryPolicyError {
+			ce := newCorruptionError("file entry-set", ErrSpecialEntry)
+			ce.Offset = ide.Location.Offset
+			ce.ClusterNumber = ide.Location.ClusterNumber
+			ce.EntryIndex = ide.Location.EntryNumber
+
+			log.Panic(ce)
+		}
+	}
+
+	for _, de := range ide.SecondaryEntries {
+		if current, ok := de.(*ExfatStreamExtensionDirectoryEntry); ok == true {
+			sede = current
+			break
+		}
+	}
+
+	// Section 7.4 requires every file entry-set to have one, and nothing can
+	// be read without it.
+	if sede == nil {
+		ce := newCorruptionError("file entry-set", log.Errorf("no stream-extension entry: [%s]", ide.Filename))
+		ce.Offset = ide.Location.Offset
+		ce.ClusterNumber = ide.Location.ClusterNumber
+		ce.EntryIndex = ide.Location.EntryNumber
+
+		log.Panic(ce)
+	}
+
+	return fde, sede, false, nil
+}
+
 // Load loads the whole tree.
 func (tree *Tree) Load() (err error) {
 	defer func() {
@@ -204,50 +647,97 @@ func (tree *Tree) Load() (err error) {
 		}
 	}()
 
-	clusterNumber := tree.er.FirstClusterOfRootDirectory()
-
-	err = tree.loadDirectory(clusterNumber, tree.rootNode)
+	err = tree.loadNode(tree.rootNode)
 	log.PanicIf(err)
 
 	return nil
 }
 
-// Lookup finds the node for the given absolute path.
-func (tree *Tree) Lookup(pathParts []string) (node *TreeNode, err error) {
+// LoadAll loads every directory in the tree and then freezes it. Since a
+// frozen tree can no longer change, lookups and traversals no longer take any
+// locks, which suits read-heavy servers that share one tree among many
+// goroutines. The directories are read up-front, so this reads all of the
+// directory entries on the volume.
+func (tree *Tree) LoadAll() (err error) {
 	defer func() {
 		if errRaw := recover(); errRaw != nil {
 			err = log.Wrap(errRaw.(error))
 		}
 	}()
 
-	startNode := tree.rootNode
+	if tree.IsFrozen() == true {
+		return nil
+	}
+
+	// Visiting loads every directory before its children are visited.
+	cb := func(pathParts []string, node *TreeNode) (err error) {
+		return nil
+	}
 
-	for {
-		lastPathParts, lastNode, foundNode := startNode.Lookup(pathParts)
-		if foundNode != nil {
-			// The node was found. Make sure that it's fully constituted before
-			// returning.
+	err = tree.Visit(cb)
+	log.PanicIf(err)
 
-			if foundNode.isDirectory == true && foundNode.loaded == false {
-				err := tree.loadDirectory(foundNode.sede.FirstCluster, foundNode)
-				log.PanicIf(err)
-			}
+	atomic.StoreInt32(&tree.frozen, 1)
 
-			return foundNode, nil
+	return nil
+}
+
+// IsFrozen indicates whether the tree has been completely loaded by LoadAll()
+// and can no longer change.
+func (tree *Tree) IsFrozen() bool {
+	return atomic.LoadInt32(&tree.frozen) == 1
+}
+
+// Lookup finds the node for the given absolute path. Returns nil if it was
+// not found.
+func (tree *Tree) Lookup(pathParts []string) (node *TreeNode, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
 		}
+	}()
+
+	node = tree.rootNode
 
-		// If we've already loaded all children for that node, return nil (find
-		// unsuccessful).
-		if lastNode.loaded == true {
+	for _, part := range pathParts {
+		// A file doesn't have children.
+		if node.isDirectory == false {
 			return nil, nil
 		}
 
-		err := tree.loadDirectory(lastNode.sede.FirstCluster, lastNode)
+		err := tree.loadNode(node)
 		log.PanicIf(err)
 
-		startNode = lastNode
-		pathParts = lastPathParts
+		node = node.GetChild(part)
+		if node == nil {
+			return nil, nil
+		}
+	}
+
+	// Make sure that the node is fully constituted before returning.
+	if node.isDirectory == true {
+		err := tree.loadNode(node)
+		log.PanicIf(err)
 	}
+
+	return node, nil
+}
+
+// LookupPath finds the node for the given absolute path string. Either
+// forward- or backward-slashes may be used as separators.
+func (tree *Tree) LookupPath(volumePath string) (node *TreeNode, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	pathParts := SplitVolumePath(volumePath)
+
+	node, err = tree.Lookup(pathParts)
+	log.PanicIf(err)
+
+	return node, nil
 }
 
 // TreeVisitorFunc is a visitor function that receives a series of visited
@@ -277,29 +767,38 @@ func (tree *Tree) visit(pathParts []string, node *TreeNode, cb TreeVisitorFunc)
 		}
 	}()
 
+	// Finish loading the node before it's visited.
+	err = tree.loadNode(node)
+	log.PanicIf(err)
+
 	err = cb(pathParts, node)
 	log.PanicIf(err)
 
-	for _, childFolderName := range node.childrenFolders {
-		childNode := node.childrenMap[childFolderName]
+	// Children can still be added or removed while we iterate. The name lists
+	// are replaced rather than modified, so the ones we get stay the same, but
+	// the nodes are looked up under the lock and skipped if they've since been
+	// removed.
+
+	for _, childFolderName := range node.ChildFolders() {
+		childNode := node.GetChild(childFolderName)
+		if childNode == nil {
+			continue
+		}
 
 		childPathParts := make([]string, len(pathParts)+1)
 		copy(childPathParts, pathParts)
 		childPathParts[len(childPathParts)-1] = childNode.name
 
-		// Finish loading node.
-		if childNode.loaded == false {
-			err := tree.loadDirectory(childNode.sede.FirstCluster, childNode)
-			log.PanicIf(err)
-		}
-
 		err := tree.visit(childPathParts, childNode, cb)
 		log.PanicIf(err)
 	}
 
 	// Do the files all at once, at the bottom.
-	for _, childFilename := range node.childrenFiles {
-		childNode := node.childrenMap[childFilename]
+	for _, childFilename := range node.ChildFiles() {
+		childNode := node.GetChild(childFilename)
+		if childNode == nil {
+			continue
+		}
 
 		childPathParts := make([]string, len(pathParts)+1)
 		copy(childPathParts, pathParts)
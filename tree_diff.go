@@ -0,0 +1,104 @@
+// This package supports comparing the contents of two volumes.
+
+package exfat
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/dsoprea/go-logging"
+)
+
+// TreeDiff lists the differences between two trees, each ordered by path.
+type TreeDiff struct {
+	// Added are the files and directories that only exist in the newer tree.
+	Added []ChangeJournalEntry
+
+	// Removed are the files and directories that only exist in the older tree.
+	Removed []ChangeJournalEntry
+
+	// Changed are the files whose size, modified-time, or (if a hash algorithm
+	// was given) data differs.
+	Changed []ChangeJournalEntry
+}
+
+// String returns a descriptive string.
+func (td *TreeDiff) String() string {
+	return fmt.Sprintf("TreeDiff<ADDED=(%d) REMOVED=(%d) CHANGED=(%d)>", len(td.Added), len(td.Removed), len(td.Changed))
+}
+
+// IsIdentical indicates whether no differences were found.
+func (td *TreeDiff) IsIdentical() bool {
+	return len(td.Added) == 0 && len(td.Removed) == 0 && len(td.Changed) == 0
+}
+
+// Dump prints the differences.
+func (td *TreeDiff) Dump() {
+	td.DumpTo(os.Stdout)
+}
+
+// DumpTo is the same as Dump() but writes to the given writer.
+func (td *TreeDiff) DumpTo(w io.Writer) {
+	fmt.Fprintf(w, "Tree Diff\n")
+	fmt.Fprintf(w, "=========\n")
+	fmt.Fprintf(w, "\n")
+
+	for _, cje := range td.Added {
+		fmt.Fprintf(w, "Added: %s\n", cje.Path)
+	}
+
+	for _, cje := range td.Removed {
+		fmt.Fprintf(w, "Removed: %s\n", cje.Path)
+	}
+
+	for _, cje := range td.Changed {
+		fmt.Fprintf(w, "Changed: %s\n", cje.Path)
+	}
+
+	fmt.Fprintf(w, "\n")
+	fmt.Fprintf(w, "Added: (%d)\n", len(td.Added))
+	fmt.Fprintf(w, "Removed: (%d)\n", len(td.Removed))
+	fmt.Fprintf(w, "Changed: (%d)\n", len(td.Changed))
+	fmt.Fprintf(w, "\n")
+}
+
+// DiffTrees compares the files and directories of an older and a newer tree
+// (e.g. two images of the same card, or a volume and a copy of it). Files are
+// compared by size and modified-time and, if `hashName` is not empty, by the
+// digest of their data with that registered algorithm (which requires reading
+// all file data from both). Deleted entries are ignored.
+func DiffTrees(oldTree, newTree *Tree, hashName string) (td *TreeDiff, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	oldManifest, err := BuildManifest(oldTree, hashName)
+	log.PanicIf(err)
+
+	newManifest, err := BuildManifest(newTree, hashName)
+	log.PanicIf(err)
+
+	journal := DiffManifests(oldManifest, newManifest)
+
+	td = &TreeDiff{
+		Added:   make([]ChangeJournalEntry, 0),
+		Removed: make([]ChangeJournalEntry, 0),
+		Changed: make([]ChangeJournalEntry, 0),
+	}
+
+	for _, cje := range journal {
+		switch cje.Type {
+		case ChangeCreated:
+			td.Added = append(td.Added, cje)
+		case ChangeDeleted:
+			td.Removed = append(td.Removed, cje)
+		case ChangeModified:
+			td.Changed = append(td.Changed, cje)
+		}
+	}
+
+	return td, nil
+}
@@ -0,0 +1,83 @@
+package exfat
+
+import (
+	"testing"
+
+	"github.com/dsoprea/go-logging"
+)
+
+// getTestFilteredCopy copies the test volume to a new image without the
+// `testdirectory2` directory and returns a tree for the copy.
+func getTestFilteredCopy(srcTree *Tree) (tree *Tree, closer func()) {
+	g, closer := getTestNewImage()
+
+	ew, err := NewExfatWriter(g, 4*1024*1024, FormatOptions{})
+	log.PanicIf(err)
+
+	filter := func(pathParts []string) bool {
+		return pathParts[0] != "testdirectory2"
+	}
+
+	err = Copy(srcTree.er, ew, filter)
+	log.PanicIf(err)
+
+	er, _ := getTestParsedImage(g)
+
+	return NewTree(er), closer
+}
+
+func TestDiffTrees__Identical(t *testing.T) {
+	oldTree, oldCloser := getTestTree()
+
+	defer oldCloser()
+
+	newTree, newCloser := getTestTree()
+
+	defer newCloser()
+
+	td, err := DiffTrees(oldTree, newTree, "sha1")
+	log.PanicIf(err)
+
+	if td.IsIdentical() != true {
+		t.Fatalf("Expected no differences: %s", td)
+	}
+}
+
+func TestDiffTrees(t *testing.T) {
+	oldTree, oldCloser := getTestTree()
+
+	defer oldCloser()
+
+	newTree, newCloser := getTestFilteredCopy(oldTree)
+
+	defer newCloser()
+
+	// Make one of the copied files look like it has grown.
+
+	node, err := newTree.LookupPath("79c6d31a-cca1-11e9-8325-9746d045e868")
+	log.PanicIf(err)
+
+	node.StreamDirectoryEntry().ValidDataLength++
+
+	td, err := DiffTrees(oldTree, newTree, "")
+	log.PanicIf(err)
+
+	if len(td.Added) != 0 {
+		t.Fatalf("Added not correct: %v", td.Added)
+	} else if len(td.Removed) != 3 || td.Removed[0].Path != "testdirectory2" {
+		t.Fatalf("Removed not correct: %v", td.Removed)
+	} else if len(td.Changed) != 1 || td.Changed[0].Path != "79c6d31a-cca1-11e9-8325-9746d045e868" {
+		t.Fatalf("Changed not correct: %v", td.Changed)
+	}
+
+	// In the other direction, the directory was added.
+
+	td, err = DiffTrees(newTree, oldTree, "")
+	log.PanicIf(err)
+
+	if len(td.Added) != 3 || len(td.Removed) != 0 || len(td.Changed) != 1 {
+		t.Fatalf("Reversed diff not correct: %s", td)
+	}
+
+	td.Dump()
+}
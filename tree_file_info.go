@@ -0,0 +1,110 @@
+// This package describes tree nodes in the standard library's terms.
+
+package exfat
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/dsoprea/go-logging"
+)
+
+// treeNodeFileInfo describes a node as an `os.FileInfo` (which is the same as
+// `fs.FileInfo` on newer Go versions).
+type treeNodeFileInfo struct {
+	node *TreeNode
+}
+
+// Name returns the name of the file or directory. It is empty for the root.
+func (tnfi treeNodeFileInfo) Name() string {
+	return tnfi.node.Name()
+}
+
+// Size returns the valid data-length of the file or directory.
+func (tnfi treeNodeFileInfo) Size() int64 {
+	sede := tnfi.node.StreamDirectoryEntry()
+	if sede == nil {
+		return 0
+	}
+
+	return int64(sede.ValidDataLength)
+}
+
+// Mode returns the file mode. exFAT has no permissions, so these are derived
+// from the directory and read-only attributes.
+func (tnfi treeNodeFileInfo) Mode() os.FileMode {
+	mode := os.FileMode(0644)
+
+	fde := tnfi.node.FileDirectoryEntry()
+	if fde != nil && fde.FileAttributes.IsReadOnly() == true {
+		mode = 0444
+	}
+
+	if tnfi.node.IsDirectory() == true {
+		// Directories need to be searchable.
+		mode |= os.ModeDir | 0111
+	}
+
+	return mode
+}
+
+// ModTime returns the offset-corrected modification time. It is the zero time
+// for the root, which has no timestamps.
+func (tnfi treeNodeFileInfo) ModTime() time.Time {
+	fde := tnfi.node.FileDirectoryEntry()
+	if fde == nil {
+		return time.Time{}
+	}
+
+	return fde.LastModifiedTimestamp()
+}
+
+// IsDir indicates whether the node is a directory.
+func (tnfi treeNodeFileInfo) IsDir() bool {
+	return tnfi.node.IsDirectory()
+}
+
+// Sys returns the underlying *TreeNode.
+func (tnfi treeNodeFileInfo) Sys() interface{} {
+	return tnfi.node
+}
+
+// String returns a descriptive string.
+func (tnfi treeNodeFileInfo) String() string {
+	return fmt.Sprintf("FileInfo<NAME=[%s] SIZE=(%d) MODE=[%s] MTIME=[%s]>", tnfi.Name(), tnfi.Size(), tnfi.Mode(), tnfi.ModTime())
+}
+
+// Stat describes the node as an `os.FileInfo` (the same as `fs.FileInfo` on
+// newer Go versions) so that it can be passed to standard-library code. Sys()
+// returns the node itself.
+func (tn *TreeNode) Stat() os.FileInfo {
+	return treeNodeFileInfo{
+		node: tn,
+	}
+}
+
+// ApplyMetadata sets the modified- and accessed-times of the given file or
+// directory on the host to those of the node, and its permissions to those
+// from Stat() (which reflect the read-only attribute). This is used to
+// preserve the metadata of extracted files.
+func (tn *TreeNode) ApplyMetadata(hostPath string) (err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	fde := tn.FileDirectoryEntry()
+	if fde == nil {
+		log.Panicf("node has no metadata to apply: [%s]", tn.Name())
+	}
+
+	err = os.Chmod(hostPath, tn.Stat().Mode().Perm())
+	log.PanicIf(err)
+
+	err = os.Chtimes(hostPath, fde.LastAccessedTimestamp(), fde.LastModifiedTimestamp())
+	log.PanicIf(err)
+
+	return nil
+}
@@ -0,0 +1,102 @@
+package exfat
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/dsoprea/go-logging"
+)
+
+func TestTreeNode_Stat__File(t *testing.T) {
+	tree, closer := getTestTree()
+
+	defer closer()
+
+	node, err := tree.LookupPath("2-delahaye-type-165-cabriolet-dsc_8025.jpg")
+	log.PanicIf(err)
+
+	fi := node.Stat()
+
+	if fi.Name() != "2-delahaye-type-165-cabriolet-dsc_8025.jpg" {
+		t.Fatalf("Name not correct: [%s]", fi.Name())
+	} else if fi.Size() != 313299 {
+		t.Fatalf("Size not correct: (%d)", fi.Size())
+	} else if fi.IsDir() != false {
+		t.Fatalf("Expected file.")
+	} else if fi.Mode().IsRegular() != true {
+		t.Fatalf("Expected regular mode: [%s]", fi.Mode())
+	} else if fi.ModTime().Equal(node.FileDirectoryEntry().LastModifiedTimestamp()) != true {
+		t.Fatalf("Modified time not correct: [%s]", fi.ModTime())
+	} else if fi.Sys().(*TreeNode) != node {
+		t.Fatalf("Sys() did not return the node.")
+	}
+}
+
+func TestTreeNode_Stat__Directory(t *testing.T) {
+	tree, closer := getTestTree()
+
+	defer closer()
+
+	node, err := tree.LookupPath("testdirectory")
+	log.PanicIf(err)
+
+	fi := node.Stat()
+
+	if fi.IsDir() != true {
+		t.Fatalf("Expected directory.")
+	} else if fi.Mode()&os.ModeDir == 0 {
+		t.Fatalf("Expected directory mode: [%s]", fi.Mode())
+	} else if fi.Mode().Perm() != 0755 {
+		t.Fatalf("Permissions not correct: [%s]", fi.Mode())
+	}
+
+	// The root has no entries of its own.
+
+	fi = tree.rootNode.Stat()
+
+	if fi.IsDir() != true || fi.Size() != 0 || fi.ModTime().IsZero() != true {
+		t.Fatalf("Root not correct: %s", fi)
+	}
+}
+
+func TestTreeNode_ApplyMetadata(t *testing.T) {
+	tree, closer := getTestTree()
+
+	defer closer()
+
+	node, err := tree.LookupPath("79c6d31a-cca1-11e9-8325-9746d045e868")
+	log.PanicIf(err)
+
+	f, err := ioutil.TempFile("", "exfat-metadata-")
+	log.PanicIf(err)
+
+	f.Close()
+
+	defer os.Remove(f.Name())
+
+	err = node.ApplyMetadata(f.Name())
+	log.PanicIf(err)
+
+	fi, err := os.Stat(f.Name())
+	log.PanicIf(err)
+
+	if fi.ModTime().Equal(node.FileDirectoryEntry().LastModifiedTimestamp()) != true {
+		t.Fatalf("Modified time not correct: [%s]", fi.ModTime())
+	} else if fi.Mode().Perm() != 0644 {
+		t.Fatalf("Permissions not correct: [%s]", fi.Mode())
+	}
+
+	// Mark the file as read-only.
+	node.FileDirectoryEntry().FileAttributes |= 1
+
+	err = node.ApplyMetadata(f.Name())
+	log.PanicIf(err)
+
+	fi, err = os.Stat(f.Name())
+	log.PanicIf(err)
+
+	if fi.Mode().Perm() != 0444 {
+		t.Fatalf("Read-only permissions not correct: [%s]", fi.Mode())
+	}
+}
@@ -0,0 +1,172 @@
+// This package searches the tree.
+
+package exfat
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dsoprea/go-logging"
+)
+
+// TreePredicateFunc decides whether a node is included in the results of
+// Find().
+type TreePredicateFunc func(node *TreeNode) bool
+
+// Find returns every node (other than the root) that the predicate accepts,
+// in the order that Visit() passes them. Directories are loaded as they're
+// reached. Entries that are no longer in use are passed to the predicate,
+// too. Each node knows its own path (see TreeNode.Path()).
+func (tree *Tree) Find(predicate TreePredicateFunc) (nodes []*TreeNode, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	nodes = make([]*TreeNode, 0)
+
+	cb := func(pathParts []string, node *TreeNode) (err error) {
+		if len(pathParts) == 0 {
+			return nil
+		}
+
+		if predicate(node) == true {
+			nodes = append(nodes, node)
+		}
+
+		return nil
+	}
+
+	err = tree.Visit(cb)
+	log.PanicIf(err)
+
+	return nodes, nil
+}
+
+// FindByName returns the files and directories that are in use and whose
+// names contain the given string, ignoring case.
+func (tree *Tree) FindByName(substring string) (nodes []*TreeNode, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	substring = strings.ToUpper(substring)
+
+	predicate := func(node *TreeNode) bool {
+		if node.IsInUse() == false {
+			return false
+		}
+
+		return strings.Contains(strings.ToUpper(node.Name()), substring)
+	}
+
+	nodes, err = tree.Find(predicate)
+	log.PanicIf(err)
+
+	return nodes, nil
+}
+
+// FindBySize returns the files that are in use and whose size (see
+// TreeNode.Size()) is at least `minimum` and at most `maximum`, inclusively.
+func (tree *Tree) FindBySize(minimum, maximum uint64) (nodes []*TreeNode, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	predicate := func(node *TreeNode) bool {
+		if node.IsInUse() == false || node.IsDirectory() == true {
+			return false
+		}
+
+		size := node.Size()
+
+		return size >= minimum && size <= maximum
+	}
+
+	nodes, err = tree.Find(predicate)
+	log.PanicIf(err)
+
+	return nodes, nil
+}
+
+// FindModifiedBetween returns the files and directories that are in use and
+// that were last modified at or after `start` and before `end`.
+func (tree *Tree) FindModifiedBetween(start, end time.Time) (nodes []*TreeNode, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	predicate := func(node *TreeNode) bool {
+		if node.IsInUse() == false {
+			return false
+		}
+
+		modifiedTime := node.Stat().ModTime()
+
+		return modifiedTime.Before(start) == false && modifiedTime.Before(end) == true
+	}
+
+	nodes, err = tree.Find(predicate)
+	log.PanicIf(err)
+
+	return nodes, nil
+}
+
+// LargerThan returns the files that are in use and whose size (see
+// TreeNode.Size()) is more than the given number of bytes.
+func (tree *Tree) LargerThan(size uint64) (nodes []*TreeNode, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	predicate := func(node *TreeNode) bool {
+		return node.IsInUse() == true && node.IsDirectory() == false && node.Size() > size
+	}
+
+	nodes, err = tree.Find(predicate)
+	log.PanicIf(err)
+
+	return nodes, nil
+}
+
+// Newest returns the (up to) `n` files that are in use and that were modified
+// most recently, newest first (e.g. the latest photos on a card). Files with
+// the same modified-time are ordered by path. If `n` is not positive, every
+// file is returned.
+func (tree *Tree) Newest(n int) (nodes []*TreeNode, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	predicate := func(node *TreeNode) bool {
+		return node.IsInUse() == true && node.IsDirectory() == false
+	}
+
+	nodes, err = tree.Find(predicate)
+	log.PanicIf(err)
+
+	// Visit() order is by path, so a stable sort keeps ties in path order.
+	less := func(i, j int) bool {
+		return nodes[i].Stat().ModTime().After(nodes[j].Stat().ModTime())
+	}
+
+	sort.SliceStable(nodes, less)
+
+	if n > 0 && len(nodes) > n {
+		nodes = nodes[:n]
+	}
+
+	return nodes, nil
+}
@@ -0,0 +1,168 @@
+package exfat
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/dsoprea/go-logging"
+)
+
+func getTestNodePaths(nodes []*TreeNode) []string {
+	paths := make([]string, len(nodes))
+	for i, node := range nodes {
+		paths[i] = node.Path()
+	}
+
+	return paths
+}
+
+func TestTree_Find(t *testing.T) {
+	tree, closer := getTestTree()
+
+	defer closer()
+
+	// Not loaded ahead of time.
+	tree = NewTree(tree.er)
+
+	predicate := func(node *TreeNode) bool {
+		return node.IsDirectory() == true
+	}
+
+	nodes, err := tree.Find(predicate)
+	log.PanicIf(err)
+
+	expected := []string{"testdirectory", "testdirectory2", "testdirectory3"}
+
+	if reflect.DeepEqual(getTestNodePaths(nodes), expected) != true {
+		t.Fatalf("Directories not correct: %v", getTestNodePaths(nodes))
+	}
+}
+
+func TestTree_FindByName(t *testing.T) {
+	tree, closer := getTestTree()
+
+	defer closer()
+
+	nodes, err := tree.FindByName("-CEC3-11E9-9")
+	log.PanicIf(err)
+
+	expected := []string{
+		`testdirectory3\10422c86-cec3-11e9-953f-4f501efd2640`,
+		"064cbfd4-cec3-11e9-926d-c362c80fab7b",
+	}
+
+	if reflect.DeepEqual(getTestNodePaths(nodes), expected) != true {
+		t.Fatalf("Matches not correct: %v", getTestNodePaths(nodes))
+	}
+}
+
+func TestTree_FindBySize(t *testing.T) {
+	tree, closer := getTestTree()
+
+	defer closer()
+
+	nodes, err := tree.FindBySize(100000, 400000)
+	log.PanicIf(err)
+
+	expected := []string{"2-delahaye-type-165-cabriolet-dsc_8025.jpg"}
+
+	if reflect.DeepEqual(getTestNodePaths(nodes), expected) != true {
+		t.Fatalf("Matches not correct: %v", getTestNodePaths(nodes))
+	}
+
+	// The bounds are inclusive.
+
+	nodes, err = tree.FindBySize(313299, 313299)
+	log.PanicIf(err)
+
+	if len(nodes) != 1 {
+		t.Fatalf("Expected exactly one match: %v", getTestNodePaths(nodes))
+	}
+}
+
+func TestTree_FindModifiedBetween(t *testing.T) {
+	tree, closer := getTestTree()
+
+	defer closer()
+
+	node, err := tree.LookupPath("79c6d31a-cca1-11e9-8325-9746d045e868")
+	log.PanicIf(err)
+
+	modifiedTime := node.Stat().ModTime()
+
+	nodes, err := tree.FindModifiedBetween(modifiedTime, modifiedTime.Add(time.Second))
+	log.PanicIf(err)
+
+	if reflect.DeepEqual(getTestNodePaths(nodes), []string{node.Path()}) != true {
+		t.Fatalf("Matches not correct: %v", getTestNodePaths(nodes))
+	}
+
+	// The end is exclusive.
+
+	nodes, err = tree.FindModifiedBetween(modifiedTime.Add(-time.Second), modifiedTime)
+	log.PanicIf(err)
+
+	for _, current := range nodes {
+		if current == node {
+			t.Fatalf("End should be exclusive.")
+		}
+	}
+}
+
+func TestTree_LargerThan(t *testing.T) {
+	tree, closer := getTestTree()
+
+	defer closer()
+
+	nodes, err := tree.LargerThan(37)
+	log.PanicIf(err)
+
+	for _, node := range nodes {
+		if node.Size() <= 37 || node.IsDirectory() == true {
+			t.Fatalf("Match not correct: [%s]", node.Path())
+		}
+	}
+
+	// The bound is exclusive.
+
+	nodes, err = tree.LargerThan(313299)
+	log.PanicIf(err)
+
+	if len(nodes) != 0 {
+		t.Fatalf("Expected no matches: %v", getTestNodePaths(nodes))
+	}
+
+	nodes, err = tree.LargerThan(313298)
+	log.PanicIf(err)
+
+	if reflect.DeepEqual(getTestNodePaths(nodes), []string{"2-delahaye-type-165-cabriolet-dsc_8025.jpg"}) != true {
+		t.Fatalf("Matches not correct: %v", getTestNodePaths(nodes))
+	}
+}
+
+func TestTree_Newest(t *testing.T) {
+	tree, closer := getTestTree()
+
+	defer closer()
+
+	all, err := tree.Newest(0)
+	log.PanicIf(err)
+
+	for i, node := range all {
+		if node.IsDirectory() == true || node.IsInUse() == false {
+			t.Fatalf("Only files that are in use should be returned: [%s]", node.Path())
+		} else if i > 0 && node.Stat().ModTime().After(all[i-1].Stat().ModTime()) == true {
+			t.Fatalf("Not ordered newest first: %v", getTestNodePaths(all))
+		}
+	}
+
+	nodes, err := tree.Newest(2)
+	log.PanicIf(err)
+
+	if len(nodes) != 2 {
+		t.Fatalf("Expected two files: %v", getTestNodePaths(nodes))
+	} else if reflect.DeepEqual(nodes, all[:2]) != true {
+		t.Fatalf("Newest files not correct: %v", getTestNodePaths(nodes))
+	}
+}
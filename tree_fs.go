@@ -0,0 +1,258 @@
+// This package exposes a tree as an `fs.FS`.
+
+package exfat
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"sort"
+	"strings"
+)
+
+var (
+	// errTreeFSNotDirectory is returned when a file is read as a directory.
+	errTreeFSNotDirectory = errors.New("not a directory")
+)
+
+// TreeFS is a read-only `fs.FS` over a tree, so that the volume can be used
+// with `fs.WalkDir()`, `fs.Glob()`, `http.FS()`, `template.ParseFS()`, etc..
+// It also implements `fs.ReadDirFS` and `fs.StatFS`. Deleted entries are
+// treated as missing, and directory listings are sorted by name.
+type TreeFS struct {
+	tree *Tree
+}
+
+// NewTreeFS returns an `fs.FS` for the given tree. The tree may be loaded or
+// not; directories are loaded as they're opened.
+func NewTreeFS(tree *Tree) *TreeFS {
+	return &TreeFS{
+		tree: tree,
+	}
+}
+
+// lookup returns the node for the given `fs.FS` path (slash-separated and
+// unrooted, with "." being the root).
+func (tfs *TreeFS) lookup(op, name string) (node *TreeNode, err error) {
+	if fs.ValidPath(name) == false {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+
+	pathParts := []string{}
+	if name != "." {
+		pathParts = strings.Split(name, "/")
+	}
+
+	node, err = tfs.tree.Lookup(pathParts)
+	if err != nil {
+		return nil, &fs.PathError{Op: op, Path: name, Err: err}
+	}
+
+	if node == nil {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+
+	// Nothing beneath a deleted directory exists, either, just as readDir()
+	// leaves the directory out of its parent.
+	for current := node; current.Parent() != nil; current = current.Parent() {
+		if current.IsInUse() == false {
+			return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+		}
+	}
+
+	return node, nil
+}
+
+// Open opens the named file or directory. Files also implement `io.Seeker`
+// and `io.ReaderAt`.
+func (tfs *TreeFS) Open(name string) (f fs.File, err error) {
+	node, err := tfs.lookup("open", name)
+	if err != nil {
+		return nil, err
+	}
+
+	info := tfs.fileInfo(node, name)
+
+	if node.IsDirectory() == true {
+		td := &treeFSDirectory{
+			tfs:  tfs,
+			node: node,
+			name: name,
+			info: info,
+		}
+
+		return td, nil
+	}
+
+	cr, err := node.NewReader()
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+
+	tf := &treeFSFile{
+		ChainReader: cr,
+		name:        name,
+		info:        info,
+	}
+
+	return tf, nil
+}
+
+// Stat describes the named file or directory.
+func (tfs *TreeFS) Stat(name string) (fi fs.FileInfo, err error) {
+	node, err := tfs.lookup("stat", name)
+	if err != nil {
+		return nil, err
+	}
+
+	return tfs.fileInfo(node, name), nil
+}
+
+// ReadDir returns the entries of the named directory, sorted by name.
+func (tfs *TreeFS) ReadDir(name string) (entries []fs.DirEntry, err error) {
+	node, err := tfs.lookup("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+
+	if node.IsDirectory() == false {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: errTreeFSNotDirectory}
+	}
+
+	return tfs.readDir(node), nil
+}
+
+// readDir returns the entries that are in use, sorted by name.
+func (tfs *TreeFS) readDir(node *TreeNode) []fs.DirEntry {
+	names := make([]string, 0)
+	names = append(names, node.ChildFolders()...)
+	names = append(names, node.ChildFiles()...)
+
+	sort.Strings(names)
+
+	entries := make([]fs.DirEntry, 0, len(names))
+	for _, name := range names {
+		child := node.GetChild(name)
+		if child.IsInUse() == false {
+			continue
+		}
+
+		entries = append(entries, fs.FileInfoToDirEntry(child.Stat()))
+	}
+
+	return entries
+}
+
+// fileInfo returns the `fs.FileInfo` for the node. The root is named "." as
+// `fs.FS` requires.
+func (tfs *TreeFS) fileInfo(node *TreeNode, name string) fs.FileInfo {
+	if name == "." {
+		return treeFSRootInfo{
+			FileInfo: node.Stat(),
+		}
+	}
+
+	return node.Stat()
+}
+
+// treeFSRootInfo renames the root.
+type treeFSRootInfo struct {
+	fs.FileInfo
+}
+
+// Name returns ".".
+func (tfri treeFSRootInfo) Name() string {
+	return "."
+}
+
+// treeFSFile is an open file.
+type treeFSFile struct {
+	*ChainReader
+
+	name     string
+	info     fs.FileInfo
+	isClosed bool
+}
+
+// Stat describes the file.
+func (tf *treeFSFile) Stat() (fs.FileInfo, error) {
+	if tf.isClosed == true {
+		return nil, &fs.PathError{Op: "stat", Path: tf.name, Err: fs.ErrClosed}
+	}
+
+	return tf.info, nil
+}
+
+// Read reads from the current position.
+func (tf *treeFSFile) Read(p []byte) (n int, err error) {
+	if tf.isClosed == true {
+		return 0, &fs.PathError{Op: "read", Path: tf.name, Err: fs.ErrClosed}
+	}
+
+	return tf.ChainReader.Read(p)
+}
+
+// Close closes the file. Nothing needs to be released.
+func (tf *treeFSFile) Close() error {
+	if tf.isClosed == true {
+		return &fs.PathError{Op: "close", Path: tf.name, Err: fs.ErrClosed}
+	}
+
+	tf.isClosed = true
+
+	return nil
+}
+
+// treeFSDirectory is an open directory.
+type treeFSDirectory struct {
+	tfs  *TreeFS
+	node *TreeNode
+	name string
+	info fs.FileInfo
+
+	// entries is read on the first call to ReadDir().
+	entries []fs.DirEntry
+	offset  int
+}
+
+// Stat describes the directory.
+func (td *treeFSDirectory) Stat() (fs.FileInfo, error) {
+	return td.info, nil
+}
+
+// Read always fails for a directory.
+func (td *treeFSDirectory) Read(p []byte) (n int, err error) {
+	return 0, &fs.PathError{Op: "read", Path: td.name, Err: fs.ErrInvalid}
+}
+
+// ReadDir returns the next `n` entries, or all of the remaining entries if
+// `n` is not positive, as `fs.ReadDirFile` describes.
+func (td *treeFSDirectory) ReadDir(n int) (entries []fs.DirEntry, err error) {
+	if td.entries == nil {
+		td.entries = td.tfs.readDir(td.node)
+	}
+
+	remaining := td.entries[td.offset:]
+
+	if n <= 0 {
+		td.offset = len(td.entries)
+		return remaining, nil
+	}
+
+	if len(remaining) == 0 {
+		return nil, io.EOF
+	}
+
+	if n > len(remaining) {
+		n = len(remaining)
+	}
+
+	td.offset += n
+
+	return remaining[:n], nil
+}
+
+// Close closes the directory. Nothing needs to be released.
+func (td *treeFSDirectory) Close() error {
+	return nil
+}
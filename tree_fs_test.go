@@ -0,0 +1,187 @@
+package exfat
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+
+	"github.com/dsoprea/go-logging"
+)
+
+func TestTreeFS__TestFS(t *testing.T) {
+	tree, closer := getTestTree()
+
+	defer closer()
+
+	tfs := NewTreeFS(tree)
+
+	err := fstest.TestFS(
+		tfs,
+		"2-delahaye-type-165-cabriolet-dsc_8025.jpg",
+		"79c6d31a-cca1-11e9-8325-9746d045e868",
+		"testdirectory",
+		"testdirectory2/00c57ab0-cec3-11e9-b750-bbed8d2244c8",
+		"testdirectory3")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTreeFS__WalkDir(t *testing.T) {
+	tree, closer := getTestTree()
+
+	defer closer()
+
+	tfs := NewTreeFS(tree)
+
+	visited := make([]string, 0)
+
+	err := fs.WalkDir(tfs, ".", func(path string, d fs.DirEntry, err error) error {
+		log.PanicIf(err)
+
+		visited = append(visited, path)
+		return nil
+	})
+
+	log.PanicIf(err)
+
+	// The walk is in lexical order at every level.
+	expected := []string{
+		".",
+		"064cbfd4-cec3-11e9-926d-c362c80fab7b",
+		"2-delahaye-type-165-cabriolet-dsc_8025.jpg",
+		"79c6d31a-cca1-11e9-8325-9746d045e868",
+		"testdirectory",
+	}
+
+	for i, path := range expected {
+		if visited[i] != path {
+			t.Fatalf("Walk not correct: %v", visited)
+		}
+	}
+
+	if len(visited) <= len(expected) {
+		t.Fatalf("Walk did not descend: %v", visited)
+	}
+}
+
+func TestTreeFS_Open(t *testing.T) {
+	tree, closer := getTestTree()
+
+	defer closer()
+
+	tfs := NewTreeFS(tree)
+
+	data, err := fs.ReadFile(tfs, "2-delahaye-type-165-cabriolet-dsc_8025.jpg")
+	log.PanicIf(err)
+
+	expected, err := tree.er.ReadFile("2-delahaye-type-165-cabriolet-dsc_8025.jpg")
+	log.PanicIf(err)
+
+	if bytes.Equal(data, expected) != true {
+		t.Fatalf("Data not correct.")
+	}
+
+	f, err := tfs.Open("testdirectory")
+	log.PanicIf(err)
+
+	defer f.Close()
+
+	_, err = f.Read(make([]byte, 1))
+	if err == nil || err == io.EOF {
+		t.Fatalf("Expected read of a directory to fail.")
+	}
+
+	_, err = tfs.Open("not-there")
+	if errors.Is(err, fs.ErrNotExist) != true {
+		t.Fatalf("Expected not-exist error: %v", err)
+	}
+
+	_, err = tfs.Open("/testdirectory")
+	if errors.Is(err, fs.ErrInvalid) != true {
+		t.Fatalf("Expected invalid-path error: %v", err)
+	}
+}
+
+func TestTreeFS__RecreatedFile(t *testing.T) {
+	tree, data, closer := getTestRecreatedFileTree()
+
+	defer closer()
+
+	tfs := NewTreeFS(tree)
+
+	recovered, err := fs.ReadFile(tfs, "data.txt")
+	log.PanicIf(err)
+
+	if bytes.Equal(recovered, data) != true {
+		t.Fatalf("Data not correct: [%s]", recovered)
+	}
+
+	entries, err := fs.ReadDir(tfs, ".")
+	log.PanicIf(err)
+
+	if len(entries) != 1 || entries[0].Name() != "data.txt" {
+		t.Fatalf("Entries not correct: %v", entries)
+	}
+}
+
+func TestTreeFS__DeletedDirectory(t *testing.T) {
+	f, closer := getTestNewImage()
+
+	defer closer()
+
+	ew, err := NewExfatWriter(f, 2*1024*1024, FormatOptions{})
+	log.PanicIf(err)
+
+	err = ew.Mkdir([]string{"deleted_dir"}, FileMetadata{})
+	log.PanicIf(err)
+
+	err = ew.CreateFile([]string{"deleted_dir", "live_child"}, bytes.NewReader([]byte("data")), 4, FileMetadata{})
+	log.PanicIf(err)
+
+	err = ew.Close()
+	log.PanicIf(err)
+
+	er, _ := getTestParsedImage(f)
+
+	node, err := NewTree(er).Lookup([]string{"deleted_dir"})
+	log.PanicIf(err)
+
+	// Mark only the directory as deleted. Its child is untouched.
+	for _, rde := range node.IndexedDirectoryEntry().RawEntries() {
+		_, err = f.WriteAt([]byte{rde.Raw[0] &^ 0x80}, rde.Offset())
+		log.PanicIf(err)
+	}
+
+	er, _ = getTestParsedImage(f)
+
+	tfs := NewTreeFS(NewTree(er))
+
+	for _, name := range []string{"deleted_dir", "deleted_dir/live_child"} {
+		_, err = tfs.Open(name)
+		if errors.Is(err, fs.ErrNotExist) != true {
+			t.Fatalf("Expected not-exist error from open for [%s]: %v", name, err)
+		}
+
+		_, err = tfs.Stat(name)
+		if errors.Is(err, fs.ErrNotExist) != true {
+			t.Fatalf("Expected not-exist error from stat for [%s]: %v", name, err)
+		}
+	}
+
+	_, err = tfs.ReadDir("deleted_dir")
+	if errors.Is(err, fs.ErrNotExist) != true {
+		t.Fatalf("Expected not-exist error from readdir: %v", err)
+	}
+
+	entries, err := tfs.ReadDir(".")
+	log.PanicIf(err)
+
+	if len(entries) != 0 {
+		t.Fatalf("Expected no entries: %v", entries)
+	}
+}
@@ -0,0 +1,173 @@
+package exfat
+
+import (
+	"path"
+	"regexp"
+
+	"github.com/dsoprea/go-logging"
+)
+
+// globMatchParts matches the given path parts against the given pattern parts.
+// A "**" pattern part matches zero or more path parts and every other pattern
+// part is matched against a single path part using `path.Match` semantics.
+func globMatchParts(patternParts, pathParts []string) (isMatched bool, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	if len(patternParts) == 0 {
+		return len(pathParts) == 0, nil
+	}
+
+	if patternParts[0] == "**" {
+		// Try consuming zero, one, two, etc.. path parts.
+		for i := 0; i <= len(pathParts); i++ {
+			isMatched, err := globMatchParts(patternParts[1:], pathParts[i:])
+			log.PanicIf(err)
+
+			if isMatched == true {
+				return true, nil
+			}
+		}
+
+		return false, nil
+	}
+
+	if len(pathParts) == 0 {
+		return false, nil
+	}
+
+	isMatched, err = path.Match(patternParts[0], pathParts[0])
+	log.PanicIf(err)
+
+	if isMatched == false {
+		return false, nil
+	}
+
+	isMatched, err = globMatchParts(patternParts[1:], pathParts[1:])
+	log.PanicIf(err)
+
+	return isMatched, nil
+}
+
+// splitGlobPattern splits the given pattern into its parts and validates each
+// of them so that a bad pattern isn't silently ignored when there is nothing
+// to match it against.
+func splitGlobPattern(pattern string) (patternParts []string, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	patternParts = SplitVolumePath(pattern)
+
+	for _, patternPart := range patternParts {
+		_, err := path.Match(patternPart, "")
+		log.PanicIf(err)
+	}
+
+	return patternParts, nil
+}
+
+// MatchVolumePath indicates whether the complete volume path matches the given
+// pattern, using the same rules as Glob(). Either may be separated with
+// forward- or backward-slashes.
+func MatchVolumePath(pattern, volumePath string) (isMatched bool, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	patternParts, err := splitGlobPattern(pattern)
+	log.PanicIf(err)
+
+	isMatched, err = globMatchParts(patternParts, SplitVolumePath(volumePath))
+	log.PanicIf(err)
+
+	return isMatched, nil
+}
+
+// Glob returns the paths (and a map of those paths to their nodes) that match
+// the given pattern. The pattern may be separated with forward- or backward-
+// slashes, each component supports the usual wildcards ("*", "?", and
+// character classes), and a "**" component matches any number of directories.
+// The returned paths are backslash-separated, like List().
+func (tree *Tree) Glob(pattern string) (matches []string, nodes map[string]*TreeNode, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	patternParts, err := splitGlobPattern(pattern)
+	log.PanicIf(err)
+
+	matches = make([]string, 0)
+	nodes = make(map[string]*TreeNode)
+
+	cb := func(pathParts []string, node *TreeNode) (err error) {
+		defer func() {
+			if errRaw := recover(); errRaw != nil {
+				err = log.Wrap(errRaw.(error))
+			}
+		}()
+
+		if len(pathParts) == 0 {
+			return nil
+		}
+
+		isMatched, err := globMatchParts(patternParts, pathParts)
+		log.PanicIf(err)
+
+		if isMatched == true {
+			nodePath := JoinVolumePath(pathParts)
+
+			matches = append(matches, nodePath)
+			nodes[nodePath] = node
+		}
+
+		return nil
+	}
+
+	err = tree.Visit(cb)
+	log.PanicIf(err)
+
+	return matches, nodes, nil
+}
+
+// Match returns the paths (and a map of those paths to their nodes) whose
+// complete, backslash-separated path matches the given expression.
+func (tree *Tree) Match(re *regexp.Regexp) (matches []string, nodes map[string]*TreeNode, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	matches = make([]string, 0)
+	nodes = make(map[string]*TreeNode)
+
+	cb := func(pathParts []string, node *TreeNode) (err error) {
+		if len(pathParts) == 0 {
+			return nil
+		}
+
+		nodePath := JoinVolumePath(pathParts)
+
+		if re.MatchString(nodePath) == true {
+			matches = append(matches, nodePath)
+			nodes[nodePath] = node
+		}
+
+		return nil
+	}
+
+	err = tree.Visit(cb)
+	log.PanicIf(err)
+
+	return matches, nodes, nil
+}
@@ -0,0 +1,143 @@
+package exfat
+
+import (
+	"reflect"
+	"regexp"
+	"testing"
+
+	"github.com/dsoprea/go-logging"
+)
+
+func getTestTree() (tree *Tree, closer func()) {
+	f, er := getTestFileAndParser()
+
+	err := er.Parse()
+	log.PanicIf(err)
+
+	tree = NewTree(er)
+
+	err = tree.Load()
+	log.PanicIf(err)
+
+	closer = func() {
+		f.Close()
+	}
+
+	return tree, closer
+}
+
+func TestTree_Glob__SingleLevel(t *testing.T) {
+	tree, closer := getTestTree()
+
+	defer closer()
+
+	matches, nodes, err := tree.Glob("testdirectory2/file*")
+	log.PanicIf(err)
+
+	expected := []string{
+		`testdirectory2\file1`,
+		`testdirectory2\file2`,
+	}
+
+	if reflect.DeepEqual(matches, expected) != true {
+		t.Fatalf("Matches not correct: %v", matches)
+	} else if nodes[`testdirectory2\file1`].Name() != "file1" {
+		t.Fatalf("Node not correct.")
+	}
+}
+
+func TestTree_Glob__Recursive(t *testing.T) {
+	tree, closer := getTestTree()
+
+	defer closer()
+
+	matches, _, err := tree.Glob(`**\*.jpg`)
+	log.PanicIf(err)
+
+	expected := []string{
+		"2-delahaye-type-165-cabriolet-dsc_8025.jpg",
+		"8fd71ab132c59bf33cd7890c0acebf12.jpg",
+	}
+
+	if reflect.DeepEqual(matches, expected) != true {
+		t.Fatalf("Matches not correct: %v", matches)
+	}
+
+	matches, _, err = tree.Glob("testdirectory*/**/*-11e9-*")
+	log.PanicIf(err)
+
+	expected = []string{
+		`testdirectory\300daec8-cec3-11e9-bfa2-0f240e41d1d8`,
+		`testdirectory2\00c57ab0-cec3-11e9-b750-bbed8d2244c8`,
+		`testdirectory2\ff7b94be-cec2-11e9-b7b1-6b2e61bd775c`,
+		`testdirectory3\10422c86-cec3-11e9-953f-4f501efd2640`,
+	}
+
+	if reflect.DeepEqual(matches, expected) != true {
+		t.Fatalf("Recursive matches not correct: %v", matches)
+	}
+}
+
+func TestTree_Glob__BadPattern(t *testing.T) {
+	tree, closer := getTestTree()
+
+	defer closer()
+
+	_, _, err := tree.Glob("[")
+	if err == nil {
+		t.Fatalf("Expected error for bad pattern.")
+	}
+}
+
+func TestMatchVolumePath(t *testing.T) {
+	cases := []struct {
+		pattern   string
+		path      string
+		isMatched bool
+	}{
+		{"testdirectory2/file*", `testdirectory2\file1`, true},
+		{`testdirectory2\file*`, "testdirectory2/file1", true},
+		{"file*", `testdirectory2\file1`, false},
+		{"**/file?", `testdirectory2\file1`, true},
+		{"**/file?", "file1", true},
+		{"**", `a\b\c`, true},
+		{"testdirectory*/**/*.jpg", `testdirectory\8fd71ab132c59bf33cd7890c0acebf12.jpg`, true},
+		{"testdirectory*/**/*.jpg", `testdirectory\8fd71ab132c59bf33cd7890c0acebf12.png`, false},
+	}
+
+	for _, c := range cases {
+		isMatched, err := MatchVolumePath(c.pattern, c.path)
+		log.PanicIf(err)
+
+		if isMatched != c.isMatched {
+			t.Fatalf("Match of [%s] against [%s] not correct: [%v]", c.pattern, c.path, isMatched)
+		}
+	}
+
+	_, err := MatchVolumePath("[", "file1")
+	if err == nil {
+		t.Fatalf("Expected error for bad pattern.")
+	}
+}
+
+func TestTree_Match(t *testing.T) {
+	tree, closer := getTestTree()
+
+	defer closer()
+
+	re := regexp.MustCompile(`^testdirectory\d\\file\d$`)
+
+	matches, nodes, err := tree.Match(re)
+	log.PanicIf(err)
+
+	expected := []string{
+		`testdirectory2\file1`,
+		`testdirectory2\file2`,
+	}
+
+	if reflect.DeepEqual(matches, expected) != true {
+		t.Fatalf("Matches not correct: %v", matches)
+	} else if len(nodes) != 2 {
+		t.Fatalf("Nodes not correct: %v", nodes)
+	}
+}
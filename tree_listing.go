@@ -0,0 +1,205 @@
+// This package supports producing a hierarchical listing of a tree.
+
+package exfat
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"time"
+
+	"github.com/dsoprea/go-logging"
+	"github.com/dustin/go-humanize"
+)
+
+// TreeListingNode is one file or directory in a hierarchical listing, along
+// with the nodes under it.
+type TreeListingNode struct {
+	// Name is empty for the root.
+	Name string `json:"name"`
+
+	IsDirectory  bool      `json:"is_directory"`
+	Size         uint64    `json:"size"`
+	ModifiedTime time.Time `json:"modified_time"`
+	CreatedTime  time.Time `json:"created_time"`
+
+	// Children are in the same order as Walk() visits them (directories
+	// first). It is empty for files.
+	Children []*TreeListingNode `json:"children,omitempty"`
+}
+
+// String returns a descriptive string.
+func (tln *TreeListingNode) String() string {
+	return fmt.Sprintf("TreeListingNode<NAME=[%s] IS-DIRECTORY=[%v] SIZE=(%d) CHILDREN=(%d)>", tln.Name, tln.IsDirectory, tln.Size, len(tln.Children))
+}
+
+// Counts returns the number of directories and files under this node, at any
+// depth. The node itself is not counted.
+func (tln *TreeListingNode) Counts() (directoryCount, fileCount int) {
+	for _, child := range tln.Children {
+		if child.IsDirectory == true {
+			directoryCount++
+
+			childDirectoryCount, childFileCount := child.Counts()
+
+			directoryCount += childDirectoryCount
+			fileCount += childFileCount
+		} else {
+			fileCount++
+		}
+	}
+
+	return directoryCount, fileCount
+}
+
+// WriteText writes one line for each node under this one, indented two spaces
+// for each level, with the size and modified-time of each.
+func (tln *TreeListingNode) WriteText(w io.Writer) (err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	err = tln.writeText(w, "")
+	log.PanicIf(err)
+
+	return nil
+}
+
+func (tln *TreeListingNode) writeText(w io.Writer, indent string) (err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	for _, child := range tln.Children {
+		name := child.Name
+		if child.IsDirectory == true {
+			name += `\`
+		}
+
+		_, err := fmt.Fprintf(w, "%15s %30s %s%s\n", humanize.Comma(int64(child.Size)), child.ModifiedTime, indent, name)
+		log.PanicIf(err)
+
+		err = child.writeText(w, indent+"  ")
+		log.PanicIf(err)
+	}
+
+	return nil
+}
+
+// WriteAscii draws the nodes under this one in the style of tree(1), with the
+// size and modified-time of each, followed by a count of the directories and
+// files.
+func (tln *TreeListingNode) WriteAscii(w io.Writer) (err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	_, err = fmt.Fprintf(w, ".\n")
+	log.PanicIf(err)
+
+	err = tln.writeAscii(w, "")
+	log.PanicIf(err)
+
+	directoryCount, fileCount := tln.Counts()
+
+	_, err = fmt.Fprintf(w, "\n%d directories, %d files\n", directoryCount, fileCount)
+	log.PanicIf(err)
+
+	return nil
+}
+
+func (tln *TreeListingNode) writeAscii(w io.Writer, prefix string) (err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	for i, child := range tln.Children {
+		branch := "├── "
+		childPrefix := prefix + "│   "
+
+		if i == len(tln.Children)-1 {
+			branch = "└── "
+			childPrefix = prefix + "    "
+		}
+
+		_, err := fmt.Fprintf(w, "%s%s[%10s  %s]  %s\n", prefix, branch, humanize.Comma(int64(child.Size)), child.ModifiedTime.Format("2006-01-02 15:04"), child.Name)
+		log.PanicIf(err)
+
+		err = child.writeAscii(w, childPrefix)
+		log.PanicIf(err)
+	}
+
+	return nil
+}
+
+// BuildTreeListing returns the root of a hierarchical listing of every file
+// and directory that is in use. Every directory is loaded.
+func BuildTreeListing(tree *Tree) (root *TreeListingNode, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	root = &TreeListingNode{
+		IsDirectory: true,
+		Children:    make([]*TreeListingNode, 0),
+	}
+
+	// The directories that have been added, by path.
+	directories := map[string]*TreeListingNode{
+		"": root,
+	}
+
+	cb := func(pathParts []string, node *TreeNode) (err error) {
+		defer func() {
+			if errRaw := recover(); errRaw != nil {
+				err = log.Wrap(errRaw.(error))
+			}
+		}()
+
+		if len(pathParts) == 0 {
+			return nil
+		} else if node.IsInUse() == false {
+			if node.IsDirectory() == true {
+				return filepath.SkipDir
+			}
+
+			return nil
+		}
+
+		fde := node.FileDirectoryEntry()
+
+		tln := &TreeListingNode{
+			Name:         node.Name(),
+			IsDirectory:  node.IsDirectory(),
+			ModifiedTime: fde.LastModifiedTimestamp(),
+			CreatedTime:  fde.CreateTimestamp(),
+		}
+
+		if tln.IsDirectory == true {
+			tln.Children = make([]*TreeListingNode, 0)
+			directories[JoinVolumePath(pathParts)] = tln
+		} else {
+			tln.Size = node.Size()
+		}
+
+		parent := directories[JoinVolumePath(pathParts[:len(pathParts)-1])]
+		parent.Children = append(parent.Children, tln)
+
+		return nil
+	}
+
+	err = tree.Walk(cb)
+	log.PanicIf(err)
+
+	return root, nil
+}
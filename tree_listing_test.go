@@ -0,0 +1,98 @@
+package exfat
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/dsoprea/go-logging"
+)
+
+func TestBuildTreeListing(t *testing.T) {
+	tree, closer := getTestTree()
+
+	defer closer()
+
+	root, err := BuildTreeListing(tree)
+	log.PanicIf(err)
+
+	directoryCount, fileCount := root.Counts()
+	if directoryCount != 3 || fileCount != 7 {
+		t.Fatalf("Counts not correct: (%d) (%d)", directoryCount, fileCount)
+	}
+
+	if len(root.Children) != 6 {
+		t.Fatalf("Root children not correct: (%d)", len(root.Children))
+	}
+
+	testdirectory2 := root.Children[1]
+	if testdirectory2.Name != "testdirectory2" || testdirectory2.IsDirectory != true {
+		t.Fatalf("Directory not correct: %s", testdirectory2)
+	}
+
+	// The deleted files are not included.
+	if len(testdirectory2.Children) != 2 {
+		t.Fatalf("Deleted files should not be listed: (%d)", len(testdirectory2.Children))
+	}
+
+	jpg := root.Children[4]
+	if jpg.Name != "2-delahaye-type-165-cabriolet-dsc_8025.jpg" || jpg.Size != 313299 || jpg.ModifiedTime.IsZero() == true {
+		t.Fatalf("File not correct: %s", jpg)
+	}
+}
+
+func TestTreeListingNode_WriteAscii(t *testing.T) {
+	tree, closer := getTestTree()
+
+	defer closer()
+
+	root, err := BuildTreeListing(tree)
+	log.PanicIf(err)
+
+	b := new(bytes.Buffer)
+
+	err = root.WriteAscii(b)
+	log.PanicIf(err)
+
+	expected := `.
+├── [         0  2019-09-03 23:22]  testdirectory
+│   └── [        37  2019-09-03 23:22]  300daec8-cec3-11e9-bfa2-0f240e41d1d8
+├── [         0  2019-09-03 23:21]  testdirectory2
+│   ├── [        37  2019-09-03 23:20]  00c57ab0-cec3-11e9-b750-bbed8d2244c8
+│   └── [        37  2019-09-03 23:20]  ff7b94be-cec2-11e9-b7b1-6b2e61bd775c
+├── [         0  2019-09-03 23:21]  testdirectory3
+│   └── [        37  2019-09-03 23:21]  10422c86-cec3-11e9-953f-4f501efd2640
+├── [        37  2019-09-03 23:21]  064cbfd4-cec3-11e9-926d-c362c80fab7b
+├── [   313,299  2019-09-01 06:17]  2-delahaye-type-165-cabriolet-dsc_8025.jpg
+└── [        29  2019-09-01 06:15]  79c6d31a-cca1-11e9-8325-9746d045e868
+
+3 directories, 7 files
+`
+
+	if b.String() != expected {
+		t.Fatalf("ASCII tree not correct:\n%s", b.String())
+	}
+}
+
+func TestTreeListingNode_WriteText(t *testing.T) {
+	tree, closer := getTestTree()
+
+	defer closer()
+
+	root, err := BuildTreeListing(tree)
+	log.PanicIf(err)
+
+	b := new(bytes.Buffer)
+
+	err = root.WriteText(b)
+	log.PanicIf(err)
+
+	lines := strings.Split(strings.TrimRight(b.String(), "\n"), "\n")
+	if len(lines) != 10 {
+		t.Fatalf("Line count not correct: (%d)", len(lines))
+	} else if strings.HasSuffix(lines[0], ` testdirectory\`) != true {
+		t.Fatalf("Directory line not correct: [%s]", lines[0])
+	} else if strings.HasSuffix(lines[1], `   300daec8-cec3-11e9-bfa2-0f240e41d1d8`) != true {
+		t.Fatalf("File line not indented: [%s]", lines[1])
+	}
+}
@@ -0,0 +1,109 @@
+// This package lists the children of a directory in different orders.
+
+package exfat
+
+import (
+	"fmt"
+	"sort"
+)
+
+// ChildOrder is the order that children are listed in.
+type ChildOrder int
+
+const (
+	// ChildOrderName orders children by name. This is the order that
+	// ChildFiles() and ChildFolders() use.
+	ChildOrderName ChildOrder = iota
+
+	// ChildOrderSize orders children by size (see TreeNode.Size()), smallest
+	// first.
+	ChildOrderSize
+
+	// ChildOrderModified orders children by their modification time, oldest
+	// first.
+	ChildOrderModified
+)
+
+// String returns the name of the order.
+func (co ChildOrder) String() string {
+	switch co {
+	case ChildOrderName:
+		return "name"
+	case ChildOrderSize:
+		return "size"
+	case ChildOrderModified:
+		return "modified"
+	}
+
+	return fmt.Sprintf("ChildOrder(%d)", int(co))
+}
+
+// sortChildren sorts the nodes in the given order. Ties are broken by name,
+// so the result is always the same.
+func sortChildren(nodes []*TreeNode, order ChildOrder) {
+	less := func(i, j int) bool {
+		a := nodes[i]
+		b := nodes[j]
+
+		switch order {
+		case ChildOrderSize:
+			if a.Size() != b.Size() {
+				return a.Size() < b.Size()
+			}
+		case ChildOrderModified:
+			aTime := a.Stat().ModTime()
+			bTime := b.Stat().ModTime()
+
+			if aTime.Equal(bTime) == false {
+				return aTime.Before(bTime)
+			}
+		}
+
+		return a.name < b.name
+	}
+
+	sort.SliceStable(nodes, less)
+}
+
+// childNodes returns the nodes for the given names.
+func (tn *TreeNode) childNodes(names []string) []*TreeNode {
+	nodes := make([]*TreeNode, len(names))
+	for i, name := range names {
+		nodes[i] = tn.GetChild(name)
+	}
+
+	return nodes
+}
+
+// ChildFoldersBy returns the child-folders in the given order. Like
+// ChildFolders(), this only covers what has already been loaded.
+func (tn *TreeNode) ChildFoldersBy(order ChildOrder) []*TreeNode {
+	nodes := tn.childNodes(tn.ChildFolders())
+	sortChildren(nodes, order)
+
+	return nodes
+}
+
+// ChildFilesBy returns the child files in the given order. Like ChildFiles(),
+// this only covers what has already been loaded.
+func (tn *TreeNode) ChildFilesBy(order ChildOrder) []*TreeNode {
+	nodes := tn.childNodes(tn.ChildFiles())
+	sortChildren(nodes, order)
+
+	return nodes
+}
+
+// ChildrenBy returns all of the children: the folders first and then the
+// files, each in the given order.
+func (tn *TreeNode) ChildrenBy(order ChildOrder) []*TreeNode {
+	folders := tn.ChildFoldersBy(order)
+	files := tn.ChildFilesBy(order)
+
+	return append(folders, files...)
+}
+
+// Children returns all of the children: the folders first and then the files,
+// each by name. This is the same order that Tree.Visit() uses.
+func (tn *TreeNode) Children() []*TreeNode {
+	return tn.ChildrenBy(ChildOrderName)
+}
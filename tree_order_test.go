@@ -0,0 +1,107 @@
+package exfat
+
+import (
+	"reflect"
+	"testing"
+)
+
+func getTestChildNames(nodes []*TreeNode) []string {
+	names := make([]string, len(nodes))
+	for i, node := range nodes {
+		names[i] = node.Name()
+	}
+
+	return names
+}
+
+func TestTreeNode_Children(t *testing.T) {
+	tree, closer := getTestTree()
+
+	defer closer()
+
+	names := getTestChildNames(tree.rootNode.Children())
+
+	expected := append(append([]string{}, tree.rootNode.ChildFolders()...), tree.rootNode.ChildFiles()...)
+
+	if reflect.DeepEqual(names, expected) != true {
+		t.Fatalf("Children not correct: %v", names)
+	} else if names[0] != "testdirectory" {
+		t.Fatalf("Folders should be first: %v", names)
+	}
+}
+
+func TestTreeNode_ChildFilesBy__Size(t *testing.T) {
+	tree, closer := getTestTree()
+
+	defer closer()
+
+	nodes := tree.rootNode.ChildFilesBy(ChildOrderSize)
+
+	if len(nodes) != len(tree.rootNode.ChildFiles()) {
+		t.Fatalf("Not all files returned: (%d)", len(nodes))
+	}
+
+	for i := 1; i < len(nodes); i++ {
+		if nodes[i-1].Size() > nodes[i].Size() {
+			t.Fatalf("Files not ordered by size: %v", getTestChildNames(nodes))
+		} else if nodes[i-1].Size() == nodes[i].Size() && nodes[i-1].Name() > nodes[i].Name() {
+			t.Fatalf("Ties not ordered by name: %v", getTestChildNames(nodes))
+		}
+	}
+
+	if nodes[len(nodes)-1].Name() != "2-delahaye-type-165-cabriolet-dsc_8025.jpg" {
+		t.Fatalf("Largest file not last: %v", getTestChildNames(nodes))
+	}
+}
+
+func TestTreeNode_ChildrenBy__Modified(t *testing.T) {
+	tree, closer := getTestTree()
+
+	defer closer()
+
+	nodes := tree.rootNode.ChildrenBy(ChildOrderModified)
+
+	folderCount := len(tree.rootNode.ChildFolders())
+
+	for i := 1; i < len(nodes); i++ {
+		if i == folderCount {
+			// The files start over.
+			continue
+		}
+
+		if nodes[i-1].Stat().ModTime().After(nodes[i].Stat().ModTime()) == true {
+			t.Fatalf("Children not ordered by modified-time: %v", getTestChildNames(nodes))
+		}
+	}
+
+	for i, node := range nodes {
+		if (i < folderCount) != node.IsDirectory() {
+			t.Fatalf("Folders should be first: %v", getTestChildNames(nodes))
+		}
+	}
+}
+
+func TestTreeNode_ChildFoldersBy__Ties(t *testing.T) {
+	rootNode := NewTreeNode("root", true, IndexedDirectoryEntry{}, nil, nil)
+	rootNode.AddChild("c", true, nil, nil, IndexedDirectoryEntry{})
+	rootNode.AddChild("a", true, nil, nil, IndexedDirectoryEntry{})
+	rootNode.AddChild("b", true, nil, nil, IndexedDirectoryEntry{})
+
+	// Nothing has a size or a timestamp, so everything ties.
+
+	for _, order := range []ChildOrder{ChildOrderName, ChildOrderSize, ChildOrderModified} {
+		names := getTestChildNames(rootNode.ChildFoldersBy(order))
+
+		if reflect.DeepEqual(names, []string{"a", "b", "c"}) != true {
+			t.Fatalf("Order [%s] not correct: %v", order, names)
+		}
+	}
+}
+
+func TestChildOrder_String(t *testing.T) {
+	if ChildOrderModified.String() != "modified" {
+		t.Fatalf("String not correct: [%s]", ChildOrderModified.String())
+	} else if ChildOrder(99).String() != "ChildOrder(99)" {
+		t.Fatalf("String not correct for unknown order: [%s]", ChildOrder(99).String())
+	}
+}
@@ -1,8 +1,12 @@
 package exfat
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"io/ioutil"
 	"reflect"
+	"sync"
 	"testing"
 
 	"github.com/dsoprea/go-logging"
@@ -313,9 +317,157 @@ func TestTreeNode_AddChild(t *testing.T) {
 
 	if childNode.Name() != "child name" {
 		t.Fatalf("New child does not have the right name.")
+	} else if childNode.Parent() != rootNode {
+		t.Fatalf("New child does not have the right parent.")
+	} else if rootNode.Parent() != nil {
+		t.Fatalf("Root should not have a parent.")
 	}
 }
 
+func TestTreeNode_AddChild__SeparateLists(t *testing.T) {
+	rootNode := NewTreeNode("root", true, IndexedDirectoryEntry{}, nil, nil)
+
+	rootNode.AddChild("folder", true, nil, nil, IndexedDirectoryEntry{})
+	rootNode.AddChild("file", false, nil, nil, IndexedDirectoryEntry{})
+
+	if reflect.DeepEqual(rootNode.ChildFolders(), []string{"folder"}) != true {
+		t.Fatalf("Folders not correct: %v", rootNode.ChildFolders())
+	} else if reflect.DeepEqual(rootNode.ChildFiles(), []string{"file"}) != true {
+		t.Fatalf("Files not correct: %v", rootNode.ChildFiles())
+	}
+}
+
+func TestTreeNode_AddChild__ReturnedListsDontChange(t *testing.T) {
+	rootNode := NewTreeNode("root", true, IndexedDirectoryEntry{}, nil, nil)
+
+	rootNode.AddChild("b", false, nil, nil, IndexedDirectoryEntry{})
+	rootNode.AddChild("d", false, nil, nil, IndexedDirectoryEntry{})
+
+	before := rootNode.ChildFiles()
+
+	rootNode.AddChild("a", false, nil, nil, IndexedDirectoryEntry{})
+	rootNode.AddChild("c", false, nil, nil, IndexedDirectoryEntry{})
+	rootNode.RemoveChild("b")
+
+	if reflect.DeepEqual(before, []string{"b", "d"}) != true {
+		t.Fatalf("Earlier list was modified: %v", before)
+	} else if reflect.DeepEqual(rootNode.ChildFiles(), []string{"a", "c", "d"}) != true {
+		t.Fatalf("Files not correct: %v", rootNode.ChildFiles())
+	}
+}
+
+func TestTreeNode_AddChild__Replace(t *testing.T) {
+	rootNode := NewTreeNode("root", true, IndexedDirectoryEntry{}, nil, nil)
+
+	rootNode.AddChild("name", false, nil, nil, IndexedDirectoryEntry{})
+	replacement := rootNode.AddChild("name", true, nil, nil, IndexedDirectoryEntry{})
+
+	if len(rootNode.ChildFiles()) != 0 {
+		t.Fatalf("Replaced file still listed: %v", rootNode.ChildFiles())
+	} else if reflect.DeepEqual(rootNode.ChildFolders(), []string{"name"}) != true {
+		t.Fatalf("Folders not correct: %v", rootNode.ChildFolders())
+	} else if rootNode.GetChild("name") != replacement {
+		t.Fatalf("Child not replaced.")
+	}
+}
+
+func TestTreeNode_RemoveChild(t *testing.T) {
+	rootNode := NewTreeNode("root", true, IndexedDirectoryEntry{}, nil, nil)
+
+	childNode := rootNode.AddChild("folder", true, nil, nil, IndexedDirectoryEntry{})
+	rootNode.AddChild("file", false, nil, nil, IndexedDirectoryEntry{})
+
+	removed := rootNode.RemoveChild("folder")
+	if removed != childNode {
+		t.Fatalf("Removed node not correct.")
+	} else if removed.Parent() != nil {
+		t.Fatalf("Removed node still has a parent.")
+	} else if rootNode.GetChild("folder") != nil {
+		t.Fatalf("Removed node still registered.")
+	} else if len(rootNode.ChildFolders()) != 0 {
+		t.Fatalf("Removed node still listed: %v", rootNode.ChildFolders())
+	} else if reflect.DeepEqual(rootNode.ChildFiles(), []string{"file"}) != true {
+		t.Fatalf("Files not correct: %v", rootNode.ChildFiles())
+	}
+
+	if rootNode.RemoveChild("not-there") != nil {
+		t.Fatalf("Expected nil for a missing child.")
+	}
+}
+
+func TestTreeNode_RemoveChild__InvalidatesTotals(t *testing.T) {
+	tree, closer := getTestTree()
+
+	defer closer()
+
+	before, err := tree.Totals()
+	log.PanicIf(err)
+
+	node, err := tree.LookupPath("testdirectory2")
+	log.PanicIf(err)
+
+	removed := tree.rootNode.RemoveChild("2-delahaye-type-165-cabriolet-dsc_8025.jpg")
+	if removed == nil {
+		t.Fatalf("Child not removed.")
+	}
+
+	after, err := tree.Totals()
+	log.PanicIf(err)
+
+	if after.FileCount != before.FileCount-1 || after.Size != before.Size-313299 {
+		t.Fatalf("Totals not updated: %s -> %s", before, after)
+	}
+
+	// Adding to a subdirectory invalidates the root, too.
+
+	sede := *removed.StreamDirectoryEntry()
+	node.AddChild("copy", false, removed.FileDirectoryEntry(), &sede, removed.IndexedDirectoryEntry())
+
+	after, err = tree.Totals()
+	log.PanicIf(err)
+
+	if after != before {
+		t.Fatalf("Totals not restored: %s != %s", after, before)
+	}
+}
+
+func TestTreeNode_Path(t *testing.T) {
+	tree, closer := getTestTree()
+
+	defer closer()
+
+	node, err := tree.LookupPath(`testdirectory2\00c57ab0-cec3-11e9-b750-bbed8d2244c8`)
+	log.PanicIf(err)
+
+	if reflect.DeepEqual(node.PathParts(), []string{"testdirectory2", "00c57ab0-cec3-11e9-b750-bbed8d2244c8"}) != true {
+		t.Fatalf("Path parts not correct: %v", node.PathParts())
+	} else if node.Path() != `testdirectory2\00c57ab0-cec3-11e9-b750-bbed8d2244c8` {
+		t.Fatalf("Path not correct: [%s]", node.Path())
+	} else if node.Parent().Name() != "testdirectory2" {
+		t.Fatalf("Parent not correct: [%s]", node.Parent().Name())
+	}
+
+	rootNode, err := tree.Lookup([]string{})
+	log.PanicIf(err)
+
+	if len(rootNode.PathParts()) != 0 || rootNode.Path() != "" {
+		t.Fatalf("Root path not correct: [%s]", rootNode.Path())
+	}
+
+	// Every visited node knows the path that it was visited at.
+
+	cb := func(pathParts []string, node *TreeNode) (err error) {
+		if reflect.DeepEqual(node.PathParts(), pathParts) != true {
+			t.Fatalf("Path parts not correct: %v != %v", node.PathParts(), pathParts)
+		}
+
+		return nil
+	}
+
+	err = tree.Visit(cb)
+	log.PanicIf(err)
+}
+
 func TestTreeNode_Name(t *testing.T) {
 	tn := NewTreeNode("some name", true, IndexedDirectoryEntry{}, nil, nil)
 
@@ -745,3 +897,613 @@ func TestTree_visit(t *testing.T) {
 		t.Fatalf("Collected paths not correct.")
 	}
 }
+
+func TestTree_LookupPath(t *testing.T) {
+	f, er := getTestFileAndParser()
+
+	defer f.Close()
+
+	err := er.Parse()
+	log.PanicIf(err)
+
+	tree := NewTree(er)
+
+	err = tree.Load()
+	log.PanicIf(err)
+
+	for _, volumePath := range []string{"testdirectory2/file1", `testdirectory2\file1`, "/testdirectory2//file1"} {
+		node, err := tree.LookupPath(volumePath)
+		log.PanicIf(err)
+
+		if node == nil {
+			t.Fatalf("Did not find the node: [%s]", volumePath)
+		} else if node.Name() != "file1" {
+			t.Fatalf("Found node not correct: [%s]", volumePath)
+		}
+	}
+
+	node, err := tree.LookupPath("testdirectory2/invalid_file")
+	log.PanicIf(err)
+
+	if node != nil {
+		t.Fatalf("Expected miss.")
+	}
+}
+
+func TestTree_LookupPath__Concurrent(t *testing.T) {
+	f, er := getTestFileAndParser()
+
+	defer f.Close()
+
+	err := er.Parse()
+	log.PanicIf(err)
+
+	// Nothing is loaded up-front, so the lookups race to load the same
+	// directories.
+	tree := NewTree(er)
+
+	volumePaths := []string{
+		`testdirectory\300daec8-cec3-11e9-bfa2-0f240e41d1d8`,
+		`testdirectory2\00c57ab0-cec3-11e9-b750-bbed8d2244c8`,
+		`testdirectory2\file2`,
+		`testdirectory3\10422c86-cec3-11e9-953f-4f501efd2640`,
+		`2-delahaye-type-165-cabriolet-dsc_8025.jpg`,
+	}
+
+	found := make([]*TreeNode, len(volumePaths)*10)
+	errs := make([]error, len(found))
+
+	wg := new(sync.WaitGroup)
+
+	for i := range found {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+
+			found[i], errs[i] = tree.LookupPath(volumePaths[i%len(volumePaths)])
+		}(i)
+	}
+
+	wg.Wait()
+
+	for i, node := range found {
+		volumePath := volumePaths[i%len(volumePaths)]
+
+		if errs[i] != nil {
+			t.Fatalf("Lookup failed: [%s] %v", volumePath, errs[i])
+		} else if node == nil {
+			t.Fatalf("Did not find the node: [%s]", volumePath)
+		} else if node != found[i%len(volumePaths)] {
+			t.Fatalf("Lookups did not agree on the node: [%s]", volumePath)
+		}
+	}
+
+	// Every directory was loaded exactly once, so there are no duplicate
+	// children.
+	files, _, err := tree.List()
+	log.PanicIf(err)
+
+	if len(files) != 13 {
+		t.Fatalf("File count not correct: (%d)", len(files))
+	}
+}
+
+func TestTree_LoadAll(t *testing.T) {
+	f, er := getTestFileAndParser()
+
+	defer f.Close()
+
+	err := er.Parse()
+	log.PanicIf(err)
+
+	tree := NewTree(er)
+
+	if tree.IsFrozen() != false {
+		t.Fatalf("Expected new tree to not be frozen.")
+	}
+
+	err = tree.LoadAll()
+	log.PanicIf(err)
+
+	if tree.IsFrozen() != true {
+		t.Fatalf("Expected tree to be frozen.")
+	}
+
+	// Every directory should already be loaded, without visiting.
+
+	directories := []*TreeNode{tree.rootNode}
+	for len(directories) > 0 {
+		node := directories[0]
+		directories = directories[1:]
+
+		if node.loaded != true {
+			t.Fatalf("Directory not loaded: [%s]", node.Name())
+		}
+
+		for _, childFolderName := range node.childrenFolders {
+			directories = append(directories, node.childrenMap[childFolderName])
+		}
+	}
+
+	// Lookups and traversals run concurrently without locking.
+
+	wg := new(sync.WaitGroup)
+	errs := make([]error, 20)
+
+	for i := range errs {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+
+			if i%2 == 0 {
+				_, _, errs[i] = tree.List()
+				return
+			}
+
+			node, err := tree.LookupPath(`testdirectory2\file2`)
+			if err == nil && node == nil {
+				err = fmt.Errorf("node not found")
+			}
+
+			errs[i] = err
+		}(i)
+	}
+
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			t.Fatalf("Concurrent operation (%d) failed: %v", i, err)
+		}
+	}
+
+	// Loading again is a no-op.
+	err = tree.LoadAll()
+	log.PanicIf(err)
+}
+
+func BenchmarkTree_List(b *testing.B) {
+	f, er := getTestFileAndParser()
+
+	defer f.Close()
+
+	err := er.Parse()
+	log.PanicIf(err)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		tree := NewTree(er)
+
+		err := tree.Load()
+		log.PanicIf(err)
+
+		_, _, err = tree.List()
+		log.PanicIf(err)
+	}
+}
+
+// reportCounters reports the I/O per operation as benchmark metrics.
+func reportCounters(b *testing.B, er *ExfatReader) {
+	counters := er.Counters()
+
+	b.ReportMetric(float64(counters.SectorsRead)/float64(b.N), "sectors/op")
+	b.ReportMetric(float64(counters.Seeks)/float64(b.N), "seeks/op")
+}
+
+func BenchmarkTree_Load(b *testing.B) {
+	f, er := getTestFileAndParser()
+
+	defer f.Close()
+
+	err := er.Parse()
+	log.PanicIf(err)
+
+	b.ReportAllocs()
+	er.ResetCounters()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		tree := NewTree(er)
+
+		err := tree.Load()
+		log.PanicIf(err)
+	}
+
+	reportCounters(b, er)
+}
+
+func BenchmarkTree_LookupPath(b *testing.B) {
+	f, er := getTestFileAndParser()
+
+	defer f.Close()
+
+	err := er.Parse()
+	log.PanicIf(err)
+
+	b.ReportAllocs()
+	er.ResetCounters()
+	b.ResetTimer()
+
+	// A new tree is used each time so that the directories are loaded along
+	// the way.
+
+	for i := 0; i < b.N; i++ {
+		tree := NewTree(er)
+
+		_, err := tree.LookupPath(`testdirectory\300daec8-cec3-11e9-bfa2-0f240e41d1d8`)
+		log.PanicIf(err)
+	}
+
+	reportCounters(b, er)
+}
+
+func BenchmarkTreeNode_WriteData(b *testing.B) {
+	tree, closer := getTestTree()
+
+	defer closer()
+
+	node, err := tree.LookupPath("2-delahaye-type-165-cabriolet-dsc_8025.jpg")
+	log.PanicIf(err)
+
+	b.ReportAllocs()
+	b.SetBytes(int64(node.Size()))
+	tree.er.ResetCounters()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		err := node.WriteData(ioutil.Discard, false)
+		log.PanicIf(err)
+	}
+
+	reportCounters(b, tree.er)
+}
+
+func TestTreeNode_IsInUse(t *testing.T) {
+	f, er := getTestFileAndParser()
+
+	defer f.Close()
+
+	err := er.Parse()
+	log.PanicIf(err)
+
+	tree := NewTree(er)
+
+	err = tree.Load()
+	log.PanicIf(err)
+
+	node, err := tree.LookupPath("2-delahaye-type-165-cabriolet-dsc_8025.jpg")
+	log.PanicIf(err)
+
+	if node.IsInUse() != true {
+		t.Fatalf("Expected node to be in use.")
+	}
+
+	// This file was deleted.
+	node, err = tree.LookupPath("8fd71ab132c59bf33cd7890c0acebf12.jpg")
+	log.PanicIf(err)
+
+	if node.IsInUse() != false {
+		t.Fatalf("Expected node to not be in use.")
+	}
+}
+
+func TestTreeNode_Size(t *testing.T) {
+	tree, closer := getTestTree()
+
+	defer closer()
+
+	node, err := tree.LookupPath("2-delahaye-type-165-cabriolet-dsc_8025.jpg")
+	log.PanicIf(err)
+
+	if node.Size() != 313299 {
+		t.Fatalf("Size not correct: (%d)", node.Size())
+	} else if node.AllocatedSize() != node.StreamDirectoryEntry().DataLength {
+		t.Fatalf("Allocated size not correct: (%d)", node.AllocatedSize())
+	} else if node.SlackBytes() != node.AllocatedSize()-node.Size() {
+		t.Fatalf("Slack not correct: (%d)", node.SlackBytes())
+	}
+
+	if tree.rootNode.Size() != 0 || tree.rootNode.AllocatedSize() != 0 || tree.rootNode.SlackBytes() != 0 {
+		t.Fatalf("Expected no sizes for root.")
+	}
+}
+
+func TestTreeNode_WriteData__Slack(t *testing.T) {
+	tree, closer := getTestTree()
+
+	defer closer()
+
+	node, err := tree.LookupPath("2-delahaye-type-165-cabriolet-dsc_8025.jpg")
+	log.PanicIf(err)
+
+	expected := new(bytes.Buffer)
+
+	err = node.WriteData(expected, false)
+	log.PanicIf(err)
+
+	if uint64(expected.Len()) != node.Size() {
+		t.Fatalf("Written size not correct: (%d)", expected.Len())
+	}
+
+	// Pretend that only the start of the file was ever written.
+
+	sede := *node.StreamDirectoryEntry()
+	sede.ValidDataLength = 1000
+
+	truncatedNode := tree.rootNode.AddChild("truncated", false, node.FileDirectoryEntry(), &sede, node.IndexedDirectoryEntry())
+
+	if truncatedNode.SlackBytes() != sede.DataLength-1000 {
+		t.Fatalf("Slack not correct: (%d)", truncatedNode.SlackBytes())
+	}
+
+	b := new(bytes.Buffer)
+
+	err = truncatedNode.WriteData(b, false)
+	log.PanicIf(err)
+
+	if bytes.Equal(b.Bytes(), expected.Bytes()[:1000]) != true {
+		t.Fatalf("Valid data not correct.")
+	}
+
+	b = new(bytes.Buffer)
+
+	err = truncatedNode.WriteData(b, true)
+	log.PanicIf(err)
+
+	if uint64(b.Len()) != sede.DataLength {
+		t.Fatalf("Size with slack not correct: (%d)", b.Len())
+	} else if bytes.Equal(b.Bytes(), expected.Bytes()[:b.Len()]) != true {
+		t.Fatalf("Data with slack not correct.")
+	}
+}
+
+// getTestCorruptTree returns a tree for a copy of the test image in which the
+// first cluster of "testdirectory2" is invalid.
+func getTestCorruptTree() *Tree {
+	image, _ := getTestImage()
+
+	tree, closer := getTestTree()
+
+	defer closer()
+
+	node, err := tree.LookupPath("testdirectory2")
+	log.PanicIf(err)
+
+	// The stream-extension always directly follows the file entry.
+	offset := node.IndexedDirectoryEntry().EntrySet.Locations[1].Offset
+	defaultEncoding.PutUint32(image[offset+20:offset+24], 1)
+
+	er := NewExfatReader(bytes.NewReader(image))
+
+	err = er.Parse()
+	log.PanicIf(err)
+
+	return NewTree(er)
+}
+
+func TestTree_Visit__ErrorPolicyFail(t *testing.T) {
+	tree := getTestCorruptTree()
+
+	err := tree.Load()
+	log.PanicIf(err)
+
+	cb := func(pathParts []string, node *TreeNode) (err error) {
+		return nil
+	}
+
+	err = tree.Visit(cb)
+	if err == nil {
+		t.Fatalf("Expected error for corrupt directory.")
+	}
+}
+
+func TestTree_Visit__ErrorPolicyRecord(t *testing.T) {
+	tree := getTestCorruptTree()
+	tree.SetErrorPolicy(TreeErrorPolicyRecord)
+
+	err := tree.Load()
+	log.PanicIf(err)
+
+	files, nodes, err := tree.List()
+	log.PanicIf(err)
+
+	expectedFiles := []string{
+		"testdirectory",
+		"testdirectory\\300daec8-cec3-11e9-bfa2-0f240e41d1d8",
+		"testdirectory2",
+		"testdirectory3",
+		"testdirectory3\\10422c86-cec3-11e9-953f-4f501efd2640",
+		"064cbfd4-cec3-11e9-926d-c362c80fab7b",
+		"2-delahaye-type-165-cabriolet-dsc_8025.jpg",
+		"79c6d31a-cca1-11e9-8325-9746d045e868",
+		"8fd71ab132c59bf33cd7890c0acebf12.jpg",
+	}
+
+	if reflect.DeepEqual(files, expectedFiles) != true {
+		t.Fatalf("Files not correct: %v", files)
+	}
+
+	if nodes["testdirectory2"].LoadError() == nil {
+		t.Fatalf("Expected load error on corrupt directory.")
+	} else if nodes["testdirectory3"].LoadError() != nil {
+		t.Fatalf("Expected no load error on intact directory.")
+	}
+
+	// A lookup beneath the failed directory just isn't found.
+	node, err := tree.LookupPath(`testdirectory2\file1`)
+	log.PanicIf(err)
+
+	if node != nil {
+		t.Fatalf("Expected no node beneath corrupt directory.")
+	}
+}
+
+// getTestSpecialEntryTree returns a tree for a new image that has a regular
+// file and a directory with the reparse-point attribute bit set.
+func getTestSpecialEntryTree() (tree *Tree, closer func()) {
+	f, closer := getTestNewImage()
+
+	ew, err := NewExfatWriter(f, 2*1024*1024, FormatOptions{})
+	log.PanicIf(err)
+
+	err = ew.Mkdir([]string{"junction"}, FileMetadata{Attributes: 0x400})
+	log.PanicIf(err)
+
+	err = ew.CreateFile([]string{"regular.txt"}, bytes.NewReader([]byte("data")), 4, FileMetadata{})
+	log.PanicIf(err)
+
+	err = ew.Close()
+	log.PanicIf(err)
+
+	er, _ := getTestParsedImage(f)
+
+	tree = NewTree(er)
+
+	return tree, closer
+}
+
+// getTestRecreatedFileTree returns a tree for a new image whose root has a
+// deleted "data.txt" followed by a live "data.txt", as left behind when a file
+// is deleted and then created again. The live file has the given data.
+func getTestRecreatedFileTree() (tree *Tree, data []byte, closer func()) {
+	f, closer := getTestNewImage()
+
+	ew, err := NewExfatWriter(f, 2*1024*1024, FormatOptions{})
+	log.PanicIf(err)
+
+	err = ew.CreateFile([]string{"data.txt"}, bytes.NewReader([]byte("old")), 3, FileMetadata{})
+	log.PanicIf(err)
+
+	data = []byte("new data")
+
+	err = ew.CreateFile([]string{"datb.txt"}, bytes.NewReader(data), uint64(len(data)), FileMetadata{})
+	log.PanicIf(err)
+
+	err = ew.Close()
+	log.PanicIf(err)
+
+	er, _ := getTestParsedImage(f)
+
+	tree = NewTree(er)
+
+	deletedNode, err := tree.Lookup([]string{"data.txt"})
+	log.PanicIf(err)
+
+	liveNode, err := tree.Lookup([]string{"datb.txt"})
+	log.PanicIf(err)
+
+	// Mark every entry of the first set as deleted.
+	for _, rde := range deletedNode.IndexedDirectoryEntry().RawEntries() {
+		_, err = f.WriteAt([]byte{rde.Raw[0] &^ 0x80}, rde.Offset())
+		log.PanicIf(err)
+	}
+
+	// Rename the second to the same name. The fourth character of the name is
+	// at offset 2 + (3 * 2) of the first file-name entry.
+	nameLocation := liveNode.IndexedDirectoryEntry().EntrySet.Locations[2]
+
+	_, err = f.WriteAt([]byte{'a'}, nameLocation.Offset+8)
+	log.PanicIf(err)
+
+	er, _ = getTestParsedImage(f)
+
+	tree = NewTree(er)
+
+	return tree, data, closer
+}
+
+func TestIsSpecialEntry(t *testing.T) {
+	if IsSpecialEntry(".", 0x10) != true {
+		t.Fatalf("Expected '.' to be special.")
+	} else if IsSpecialEntry("..", 0x10) != true {
+		t.Fatalf("Expected '..' to be special.")
+	} else if IsSpecialEntry("...", 0x20) != false {
+		t.Fatalf("Expected '...' not to be special.")
+	} else if IsSpecialEntry("file", 0x408) != true {
+		t.Fatalf("Expected reserved bits to be special.")
+	}
+}
+
+func TestTree_Load__RecreatedFile(t *testing.T) {
+	tree, data, closer := getTestRecreatedFileTree()
+
+	defer closer()
+
+	node, err := tree.Lookup([]string{"data.txt"})
+	log.PanicIf(err)
+
+	if node == nil {
+		t.Fatalf("File not found.")
+	} else if node.IsInUse() != true {
+		t.Fatalf("Expected the live entry to replace the deleted one.")
+	}
+
+	b := new(bytes.Buffer)
+
+	err = node.WriteData(b, false)
+	log.PanicIf(err)
+
+	if bytes.Equal(b.Bytes(), data) != true {
+		t.Fatalf("Data not correct: [%s]", b.String())
+	}
+}
+
+func TestTree_Load__SpecialEntryPolicyExpose(t *testing.T) {
+	tree, closer := getTestSpecialEntryTree()
+
+	defer closer()
+
+	files, nodes, err := tree.List()
+	log.PanicIf(err)
+
+	if reflect.DeepEqual(files, []string{"junction", "regular.txt"}) != true {
+		t.Fatalf("Files not correct: %v", files)
+	} else if nodes["junction"].IsSpecial() != true {
+		t.Fatalf("Expected junction to be special.")
+	} else if nodes["regular.txt"].IsSpecial() != false {
+		t.Fatalf("Expected regular file not to be special.")
+	} else if nodes["junction"].FileDirectoryEntry().FileAttributes.RawAttributes() != 0x410 {
+		t.Fatalf("Raw attributes not correct: (0x%04x)", nodes["junction"].FileDirectoryEntry().FileAttributes.RawAttributes())
+	}
+}
+
+func TestTree_Load__SpecialEntryPolicySkip(t *testing.T) {
+	tree, closer := getTestSpecialEntryTree()
+
+	defer closer()
+
+	tree.SetSpecialEntryPolicy(SpecialEntryPolicySkip)
+
+	files, _, err := tree.List()
+	log.PanicIf(err)
+
+	if reflect.DeepEqual(files, []string{"regular.txt"}) != true {
+		t.Fatalf("Files not correct: %v", files)
+	}
+}
+
+func TestTree_Load__SpecialEntryPolicyError(t *testing.T) {
+	tree, closer := getTestSpecialEntryTree()
+
+	defer closer()
+
+	tree.SetSpecialEntryPolicy(SpecialEntryPolicyError)
+
+	err := tree.Load()
+	if err == nil {
+		t.Fatalf("Expected error for special entry.")
+	} else if errors.Is(err, ErrSpecialEntry) != true {
+		t.Fatalf("Expected ErrSpecialEntry: [%v]", err)
+	}
+
+	var ce *CorruptionError
+	if errors.As(err, &ce) != true {
+		t.Fatalf("Expected CorruptionError: [%v]", err)
+	} else if ce.EntryIndex < 0 {
+		t.Fatalf("Expected entry index: [%v]", err)
+	}
+}
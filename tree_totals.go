@@ -0,0 +1,149 @@
+// This package adds up the files, directories, and sizes under a node.
+
+package exfat
+
+import (
+	"fmt"
+
+	"github.com/dsoprea/go-logging"
+)
+
+// TreeTotals describes everything under a directory. Entries that are no
+// longer in use (e.g. deleted files) are not counted.
+type TreeTotals struct {
+	// FileCount is the number of files, at any depth.
+	FileCount uint64 `json:"file_count" yaml:"file_count"`
+
+	// DirectoryCount is the number of directories, at any depth, not
+	// including the directory itself.
+	DirectoryCount uint64 `json:"directory_count" yaml:"directory_count"`
+
+	// Size is the sum of the ValidDataLength of every file.
+	Size uint64 `json:"size" yaml:"size"`
+
+	// AllocatedSize is the sum of the space allocated to every file.
+	AllocatedSize uint64 `json:"allocated_size" yaml:"allocated_size"`
+}
+
+// String returns a descriptive string.
+func (tt TreeTotals) String() string {
+	return fmt.Sprintf("TreeTotals<FILES=(%d) DIRECTORIES=(%d) SIZE=(%d) ALLOCATED=(%d)>", tt.FileCount, tt.DirectoryCount, tt.Size, tt.AllocatedSize)
+}
+
+// add adds the given totals to ours.
+func (tt *TreeTotals) add(other TreeTotals) {
+	tt.FileCount += other.FileCount
+	tt.DirectoryCount += other.DirectoryCount
+	tt.Size += other.Size
+	tt.AllocatedSize += other.AllocatedSize
+}
+
+// Totals returns the totals for everything under this directory, loading
+// whatever hasn't been loaded yet. For a file, they describe just that file.
+// Since the children of a loaded directory never change, the totals of each
+// directory are cached.
+func (tn *TreeNode) Totals() (tt TreeTotals, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	if tn.isDirectory == false {
+		tt = TreeTotals{
+			FileCount:     1,
+			Size:          tn.Size(),
+			AllocatedSize: tn.AllocatedSize(),
+		}
+
+		return tt, nil
+	}
+
+	tn.totalsLocker.Lock()
+	cached := tn.totals
+	tn.totalsLocker.Unlock()
+
+	if cached != nil {
+		return *cached, nil
+	}
+
+	if tn.tree == nil {
+		log.Panicf("node is not attached to a tree: [%s]", tn.name)
+	}
+
+	err = tn.tree.loadNode(tn)
+	log.PanicIf(err)
+
+	// The children of a loaded node never change, so they can be read without
+	// the lock.
+
+	for _, childFolderName := range tn.childrenFolders {
+		childNode := tn.childrenMap[childFolderName]
+		if childNode.IsInUse() == false {
+			continue
+		}
+
+		childTotals, err := childNode.Totals()
+		log.PanicIf(err)
+
+		tt.add(childTotals)
+		tt.DirectoryCount++
+	}
+
+	for _, childFilename := range tn.childrenFiles {
+		childNode := tn.childrenMap[childFilename]
+		if childNode.IsInUse() == false {
+			continue
+		}
+
+		childTotals, err := childNode.Totals()
+		log.PanicIf(err)
+
+		tt.add(childTotals)
+	}
+
+	tn.totalsLocker.Lock()
+	tn.totals = &tt
+	tn.totalsLocker.Unlock()
+
+	return tt, nil
+}
+
+// invalidateTotals drops the cached totals of this node and of every
+// directory above it.
+func (tn *TreeNode) invalidateTotals() {
+	for current := tn; current != nil; current = current.parent {
+		current.totalsLocker.Lock()
+		current.totals = nil
+		current.totalsLocker.Unlock()
+	}
+}
+
+// RecursiveSize returns the sum of the ValidDataLength of every file under
+// this directory (or the size of this file).
+func (tn *TreeNode) RecursiveSize() (size uint64, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	tt, err := tn.Totals()
+	log.PanicIf(err)
+
+	return tt.Size, nil
+}
+
+// Totals returns the totals for the whole volume.
+func (tree *Tree) Totals() (tt TreeTotals, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	tt, err = tree.rootNode.Totals()
+	log.PanicIf(err)
+
+	return tt, nil
+}
@@ -0,0 +1,88 @@
+package exfat
+
+import (
+	"testing"
+
+	"github.com/dsoprea/go-logging"
+)
+
+func TestTree_Totals(t *testing.T) {
+	tree, closer := getTestTree()
+
+	defer closer()
+
+	expected := TreeTotals{}
+
+	cb := func(pathParts []string, node *TreeNode) (err error) {
+		if len(pathParts) == 0 || node.IsInUse() == false {
+			return nil
+		}
+
+		if node.IsDirectory() == true {
+			expected.DirectoryCount++
+		} else {
+			expected.FileCount++
+			expected.Size += node.Size()
+			expected.AllocatedSize += node.AllocatedSize()
+		}
+
+		return nil
+	}
+
+	err := tree.Visit(cb)
+	log.PanicIf(err)
+
+	tt, err := tree.Totals()
+	log.PanicIf(err)
+
+	if tt != expected {
+		t.Fatalf("Totals not correct: %s != %s", tt, expected)
+	} else if tt.DirectoryCount != 3 {
+		t.Fatalf("Directory count not correct: (%d)", tt.DirectoryCount)
+	} else if tree.rootNode.totals == nil {
+		t.Fatalf("Totals not cached.")
+	}
+
+	// A second call returns the cached totals.
+
+	tt, err = tree.Totals()
+	log.PanicIf(err)
+
+	if tt != expected {
+		t.Fatalf("Cached totals not correct: %s", tt)
+	}
+}
+
+func TestTreeNode_RecursiveSize(t *testing.T) {
+	tree, closer := getTestTree()
+
+	defer closer()
+
+	node, err := tree.LookupPath("testdirectory2")
+	log.PanicIf(err)
+
+	size, err := node.RecursiveSize()
+	log.PanicIf(err)
+
+	expected := uint64(0)
+	for _, filename := range node.ChildFiles() {
+		child := node.GetChild(filename)
+		if child.IsInUse() == true {
+			expected += child.Size()
+		}
+	}
+
+	if size != expected {
+		t.Fatalf("Directory size not correct: (%d) != (%d)", size, expected)
+	}
+
+	node, err = tree.LookupPath("2-delahaye-type-165-cabriolet-dsc_8025.jpg")
+	log.PanicIf(err)
+
+	size, err = node.RecursiveSize()
+	log.PanicIf(err)
+
+	if size != 313299 {
+		t.Fatalf("File size not correct: (%d)", size)
+	}
+}
@@ -0,0 +1,390 @@
+package exfat
+
+import (
+	"errors"
+	"io"
+	"path/filepath"
+
+	"github.com/dsoprea/go-logging"
+)
+
+var (
+	// errStopEnumeration is used internally to stop an enumeration early.
+	errStopEnumeration = errors.New("stop enumeration")
+)
+
+// Walk passes every node in the tree to the given callback, loading each
+// directory only as it's reached. Nodes are visited in the same order as
+// Visit(). If the callback returns `filepath.SkipDir` for a directory, that
+// directory's children are neither loaded nor visited. If it returns
+// `filepath.SkipDir` for a file, the remaining files in the same directory are
+// skipped. Any other error stops the walk and is returned.
+func (tree *Tree) Walk(cb TreeVisitorFunc) (err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	pathParts := make([]string, 0)
+
+	err = cb(pathParts, tree.rootNode)
+	if err == filepath.SkipDir {
+		return nil
+	}
+
+	log.PanicIf(err)
+
+	err = tree.walk(pathParts, tree.rootNode, cb)
+	log.PanicIf(err)
+
+	return nil
+}
+
+// walk visits the children of the given directory node.
+func (tree *Tree) walk(pathParts []string, node *TreeNode, cb TreeVisitorFunc) (err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	err = tree.loadNode(node)
+	log.PanicIf(err)
+
+	// As in visit(), the children are read through the locking accessors
+	// since they can change while we iterate.
+
+	for _, childFolderName := range node.ChildFolders() {
+		childNode := node.GetChild(childFolderName)
+		if childNode == nil {
+			continue
+		}
+
+		childPathParts := make([]string, len(pathParts)+1)
+		copy(childPathParts, pathParts)
+		childPathParts[len(childPathParts)-1] = childFolderName
+
+		err := cb(childPathParts, childNode)
+		if err == filepath.SkipDir {
+			continue
+		}
+
+		log.PanicIf(err)
+
+		err = tree.walk(childPathParts, childNode, cb)
+		log.PanicIf(err)
+	}
+
+	for _, childFilename := range node.ChildFiles() {
+		childNode := node.GetChild(childFilename)
+		if childNode == nil {
+			continue
+		}
+
+		childPathParts := make([]string, len(pathParts)+1)
+		copy(childPathParts, pathParts)
+		childPathParts[len(childPathParts)-1] = childFilename
+
+		err := cb(childPathParts, childNode)
+		if err == filepath.SkipDir {
+			break
+		}
+
+		log.PanicIf(err)
+	}
+
+	return nil
+}
+
+// treeNodeReadDirState is the cursor for a paged read of a node's children.
+type treeNodeReadDirState struct {
+	// offset is the number of children already returned from a loaded node.
+	offset int
+
+	// nextClusterNumber is the cluster to resume streaming from for an
+	// unloaded node.
+	nextClusterNumber uint32
+
+	// lastEntryNumber is the entry-number of the last set that was read while
+	// streaming.
+	lastEntryNumber int
+
+	// isStreamed indicates that the read started before the node was loaded.
+	// It carries on streaming even if the node is loaded in the meantime.
+	isStreamed bool
+
+	// returnedNames are the names of the children in use that were already
+	// returned while streaming. As when loading, the first one wins.
+	returnedNames map[string]struct{}
+
+	// deletedChildren are the children that are no longer in use, which are
+	// held back while streaming since a later child in use with the same name
+	// replaces them.
+	deletedChildren []*TreeNode
+
+	// deletedNames are the names in `deletedChildren`.
+	deletedNames map[string]struct{}
+
+	// isEnumerated indicates that the directory has been streamed through,
+	// leaving only `deletedChildren` to be returned.
+	isEnumerated bool
+
+	isDone bool
+}
+
+// ReadDirN returns up to `n` more children of this directory, continuing from
+// where the previous call left off. If `n` is zero or less, all remaining
+// children are returned. Once no children remain, `io.EOF` is returned (only
+// when `n` is greater than zero, as with `os.File.Readdir`).
+//
+// If the node has already been loaded, the children are returned in the same
+// order as ChildFolders() followed by ChildFiles(). Otherwise, they are read
+// directly from disk in on-disk order and are *not* added to the node, so the
+// directory never has to be held in memory in its entirety. This is decided by
+// the first call and holds until all of the children have been returned.
+// Either way, the children are the same ones that loading would add: when a
+// name appears more than once, a child that's in use replaces one that isn't.
+// While streaming, this means that the children that are no longer in use are
+// returned after the rest.
+//
+// As with `os.File.Readdir`, there is one cursor per node, so a node should
+// only be paged through by one goroutine at a time.
+func (tn *TreeNode) ReadDirN(n int) (children []*TreeNode, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	if tn.isDirectory == false {
+		log.Panicf("node is not a directory: [%s]", tn.name)
+	}
+
+	state := tn.readDirCursor()
+
+	if state.isStreamed == true {
+		children, err = tn.readDirStreamed(n)
+		log.PanicIf(err)
+	} else {
+		children = tn.readDirLoaded(n)
+	}
+
+	if n > 0 && len(children) == 0 {
+		return nil, io.EOF
+	}
+
+	if n <= 0 {
+		state.isDone = true
+	}
+
+	return children, nil
+}
+
+// readDirCursor returns the cursor for ReadDirN(), creating it if this is the
+// first call. The node may be loaded by another goroutine at the same time, so
+// this is always done under the lock.
+func (tn *TreeNode) readDirCursor() *treeNodeReadDirState {
+	tn.locker.Lock()
+	defer tn.locker.Unlock()
+
+	if tn.readDirState == nil {
+		tn.readDirState = &treeNodeReadDirState{
+			lastEntryNumber: -1,
+			isStreamed:      tn.loaded == false,
+		}
+	}
+
+	return tn.readDirState
+}
+
+// readDirLoaded returns the next children of a loaded node. As in walk(), the
+// children can be added or removed while we page through them, so the names
+// and their nodes are read together under the lock.
+func (tn *TreeNode) readDirLoaded(n int) (children []*TreeNode) {
+	if tn.isFrozen() == false {
+		tn.locker.Lock()
+		defer tn.locker.Unlock()
+	}
+
+	state := tn.readDirState
+
+	names := make([]string, 0, len(tn.childrenFolders)+len(tn.childrenFiles))
+	names = append(names, tn.childrenFolders...)
+	names = append(names, tn.childrenFiles...)
+
+	if state.offset >= len(names) {
+		return []*TreeNode{}
+	}
+
+	names = names[state.offset:]
+	if n > 0 && len(names) > n {
+		names = names[:n]
+	}
+
+	children = make([]*TreeNode, len(names))
+	for i, name := range names {
+		children[i] = tn.childrenMap[name]
+	}
+
+	state.offset += len(children)
+
+	return children
+}
+
+func (tn *TreeNode) readDirStreamed(n int) (children []*TreeNode, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	state := tn.readDirState
+
+	children = make([]*TreeNode, 0)
+
+	if state.isDone == true {
+		return children, nil
+	}
+
+	if state.returnedNames == nil {
+		state.returnedNames = make(map[string]struct{})
+		state.deletedNames = make(map[string]struct{})
+	}
+
+	if state.isEnumerated == false {
+		children, err = tn.readDirEnumerate(n)
+		log.PanicIf(err)
+
+		if n > 0 && len(children) >= n {
+			return children, nil
+		}
+	}
+
+	// Only now is it known which of the deleted children weren't replaced.
+
+	for len(state.deletedChildren) > 0 && (n <= 0 || len(children) < n) {
+		childNode := state.deletedChildren[0]
+		state.deletedChildren = state.deletedChildren[1:]
+
+		if _, found := state.returnedNames[childNode.name]; found == true {
+			continue
+		}
+
+		children = append(children, childNode)
+	}
+
+	if len(state.deletedChildren) == 0 {
+		state.isDone = true
+	}
+
+	return children, nil
+}
+
+// readDirEnumerate streams up to `n` more children that are in use from disk,
+// holding back the ones that aren't. It sets `isEnumerated` once the end of
+// the directory is reached.
+func (tn *TreeNode) readDirEnumerate(n int) (children []*TreeNode, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	state := tn.readDirState
+
+	children = make([]*TreeNode, 0)
+
+	if tn.tree == nil {
+		log.Panicf("node is not attached to a tree: [%s]", tn.name)
+	}
+
+	er := tn.tree.er
+
+	firstClusterNumber := er.FirstClusterOfRootDirectory()
+	if tn.sede != nil {
+		firstClusterNumber = tn.sede.FirstCluster
+	}
+
+	if state.nextClusterNumber == 0 {
+		state.nextClusterNumber = firstClusterNumber
+	}
+
+	en := NewExfatNavigator(er, firstClusterNumber)
+	en.SetProgressCallback(tn.tree.progressCb)
+
+	if tn.sede != nil {
+		en.SetDataLength(tn.sede.DataLength)
+	}
+
+	cb := func(es *EntrySet) (err error) {
+		if es.Location().EntryNumber <= state.lastEntryNumber {
+			return nil
+		}
+
+		if _, ok := es.PrimaryEntry.(*ExfatFileDirectoryEntry); ok == false {
+			return nil
+		}
+
+		ide := newIndexedDirectoryEntry(es)
+
+		// Loading applies the same checks.
+		fde, sede, isSkipped, err := tn.tree.fileEntries(ide)
+		if err != nil {
+			return err
+		}
+
+		// Resume from the cluster that has the last entry of this set.
+		lastLocation := es.Locations[len(es.Locations)-1]
+
+		state.lastEntryNumber = es.Location().EntryNumber
+		state.nextClusterNumber = lastLocation.ClusterNumber
+
+		if isSkipped == true {
+			return nil
+		}
+
+		if _, found := state.returnedNames[ide.Filename]; found == true {
+			return nil
+		}
+
+		isInUse := es.IsInUse()
+
+		if isInUse == false {
+			if _, found := state.deletedNames[ide.Filename]; found == true {
+				return nil
+			}
+		}
+
+		childNode := NewTreeNode(ide.Filename, fde.FileAttributes.IsDirectory(), ide, fde, sede)
+		childNode.tree = tn.tree
+		childNode.parent = tn
+
+		if isInUse == false {
+			state.deletedChildren = append(state.deletedChildren, childNode)
+			state.deletedNames[ide.Filename] = struct{}{}
+
+			return nil
+		}
+
+		state.returnedNames[ide.Filename] = struct{}{}
+		children = append(children, childNode)
+
+		if n > 0 && len(children) >= n {
+			return errStopEnumeration
+		}
+
+		return nil
+	}
+
+	_, _, err = en.enumerateEntrySetsFrom(state.nextClusterNumber, cb)
+	if err != nil && log.Is(err, errStopEnumeration) == true {
+		return children, nil
+	}
+
+	log.PanicIf(err)
+
+	state.isEnumerated = true
+
+	return children, nil
+}
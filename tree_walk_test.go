@@ -0,0 +1,467 @@
+package exfat
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/dsoprea/go-logging"
+)
+
+func TestTree_Walk(t *testing.T) {
+	tree, closer := getTestTree()
+
+	defer closer()
+
+	walked := make([]string, 0)
+
+	cb := func(pathParts []string, node *TreeNode) (err error) {
+		walked = append(walked, JoinVolumePath(pathParts))
+		return nil
+	}
+
+	err := tree.Walk(cb)
+	log.PanicIf(err)
+
+	visited := make([]string, 0)
+
+	cb = func(pathParts []string, node *TreeNode) (err error) {
+		visited = append(visited, JoinVolumePath(pathParts))
+		return nil
+	}
+
+	err = tree.Visit(cb)
+	log.PanicIf(err)
+
+	if reflect.DeepEqual(walked, visited) != true {
+		t.Fatalf("Walk order not correct: %v != %v", walked, visited)
+	}
+}
+
+func TestTree_Walk__Concurrent(t *testing.T) {
+	tree, closer := getTestTree()
+
+	defer closer()
+
+	rootNode := tree.rootNode
+
+	done := make(chan struct{})
+	wg := new(sync.WaitGroup)
+
+	// Keep changing the children of the root while it's walked.
+
+	wg.Add(1)
+
+	go func() {
+		defer wg.Done()
+
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+
+			rootNode.AddChild("added-folder", true, nil, nil, IndexedDirectoryEntry{})
+			rootNode.AddChild("added-file", false, nil, nil, IndexedDirectoryEntry{})
+
+			rootNode.RemoveChild("added-folder")
+			rootNode.RemoveChild("added-file")
+
+			runtime.Gosched()
+		}
+	}()
+
+	cb := func(pathParts []string, node *TreeNode) (err error) {
+		if node == nil {
+			t.Errorf("Node is nil: [%s]", JoinVolumePath(pathParts))
+		}
+
+		// Let the other goroutine change the children mid-walk.
+		runtime.Gosched()
+
+		return nil
+	}
+
+	for i := 0; i < 2; i++ {
+		err := tree.Walk(cb)
+		log.PanicIf(err)
+
+		err = tree.Visit(cb)
+		log.PanicIf(err)
+	}
+
+	close(done)
+	wg.Wait()
+}
+
+func TestTree_Walk__SkipDir(t *testing.T) {
+	f, er := getTestFileAndParser()
+
+	defer f.Close()
+
+	err := er.Parse()
+	log.PanicIf(err)
+
+	// Deliberately not loaded.
+	tree := NewTree(er)
+
+	walked := make([]string, 0)
+
+	cb := func(pathParts []string, node *TreeNode) (err error) {
+		walked = append(walked, JoinVolumePath(pathParts))
+
+		if node.Name() == "testdirectory2" {
+			return filepath.SkipDir
+		}
+
+		return nil
+	}
+
+	err = tree.Walk(cb)
+	log.PanicIf(err)
+
+	expected := []string{
+		"",
+		"testdirectory",
+		`testdirectory\300daec8-cec3-11e9-bfa2-0f240e41d1d8`,
+		"testdirectory2",
+		"testdirectory3",
+		`testdirectory3\10422c86-cec3-11e9-953f-4f501efd2640`,
+		"064cbfd4-cec3-11e9-926d-c362c80fab7b",
+		"2-delahaye-type-165-cabriolet-dsc_8025.jpg",
+		"79c6d31a-cca1-11e9-8325-9746d045e868",
+		"8fd71ab132c59bf33cd7890c0acebf12.jpg",
+	}
+
+	if reflect.DeepEqual(walked, expected) != true {
+		t.Fatalf("Walk not correct: %v", walked)
+	}
+
+	node := tree.rootNode.GetChild("testdirectory2")
+	if node.loaded != false {
+		t.Fatalf("Skipped directory should not have been loaded.")
+	}
+}
+
+func TestTreeNode_ReadDirN__Streamed(t *testing.T) {
+	f, er := getTestFileAndParser()
+
+	defer f.Close()
+
+	err := er.Parse()
+	log.PanicIf(err)
+
+	// Deliberately not loaded.
+	tree := NewTree(er)
+
+	names := make([]string, 0)
+	all := make([]*TreeNode, 0)
+
+	for {
+		children, err := tree.rootNode.ReadDirN(3)
+		if err == io.EOF {
+			break
+		}
+
+		log.PanicIf(err)
+
+		if len(children) > 3 {
+			t.Fatalf("Too many children returned: (%d)", len(children))
+		}
+
+		for _, child := range children {
+			if child.Parent() != tree.rootNode || child.Path() != child.Name() {
+				t.Fatalf("Child path not correct: [%s]", child.Path())
+			}
+
+			names = append(names, child.Name())
+		}
+
+		all = append(all, children...)
+	}
+
+	expected := []string{
+		"79c6d31a-cca1-11e9-8325-9746d045e868",
+		"2-delahaye-type-165-cabriolet-dsc_8025.jpg",
+		"testdirectory",
+		"testdirectory2",
+		"064cbfd4-cec3-11e9-926d-c362c80fab7b",
+		"testdirectory3",
+
+		// Deleted, so it's held back until the end.
+		"8fd71ab132c59bf33cd7890c0acebf12.jpg",
+	}
+
+	if reflect.DeepEqual(names, expected) != true {
+		t.Fatalf("Streamed children not correct: %v", names)
+	}
+
+	if tree.rootNode.loaded != false || len(tree.rootNode.childrenMap) != 0 {
+		t.Fatalf("Streaming should not have loaded the node.")
+	}
+
+	// The streamed nodes are usable as directories, too.
+
+	for _, child := range all {
+		if child.IsDirectory() == true {
+			children, err := child.ReadDirN(0)
+			log.PanicIf(err)
+
+			if len(children) == 0 {
+				t.Fatalf("Expected children.")
+			}
+		}
+	}
+}
+
+func TestTreeNode_ReadDirN__Loaded(t *testing.T) {
+	tree, closer := getTestTree()
+
+	defer closer()
+
+	node, err := tree.LookupPath("testdirectory2")
+	log.PanicIf(err)
+
+	first, err := node.ReadDirN(3)
+	log.PanicIf(err)
+
+	rest, err := node.ReadDirN(0)
+	log.PanicIf(err)
+
+	_, err = node.ReadDirN(1)
+	if err != io.EOF {
+		t.Fatalf("Expected EOF: [%v]", err)
+	}
+
+	names := make([]string, 0)
+	for _, child := range append(first, rest...) {
+		names = append(names, child.Name())
+	}
+
+	sort.Strings(names)
+
+	expected := []string{
+		"00c57ab0-cec3-11e9-b750-bbed8d2244c8",
+		"ff7b94be-cec2-11e9-b7b1-6b2e61bd775c",
+		"file1",
+		"file2",
+	}
+
+	if len(first) != 3 {
+		t.Fatalf("First page not correct: (%d)", len(first))
+	} else if reflect.DeepEqual(names, expected) != true {
+		t.Fatalf("Children not correct: %v", names)
+	}
+}
+
+func TestTreeNode_ReadDirN__Concurrent(t *testing.T) {
+	tree, closer := getTestTree()
+
+	defer closer()
+
+	rootNode := tree.rootNode
+
+	done := make(chan struct{})
+	wg := new(sync.WaitGroup)
+
+	// Keep changing the children of the root while it's paged through.
+
+	wg.Add(1)
+
+	go func() {
+		defer wg.Done()
+
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+
+			rootNode.AddChild("added-file", false, nil, nil, IndexedDirectoryEntry{})
+			rootNode.RemoveChild("added-file")
+
+			runtime.Gosched()
+		}
+	}()
+
+	for {
+		children, err := rootNode.ReadDirN(1)
+		if err == io.EOF {
+			break
+		}
+
+		log.PanicIf(err)
+
+		if children[0] == nil {
+			t.Fatalf("Child is nil.")
+		}
+
+		runtime.Gosched()
+	}
+
+	close(done)
+	wg.Wait()
+}
+
+func TestTreeNode_ReadDirN__LoadedWhileStreaming(t *testing.T) {
+	f, er := getTestFileAndParser()
+
+	defer f.Close()
+
+	err := er.Parse()
+	log.PanicIf(err)
+
+	tree := NewTree(er)
+
+	children, err := tree.rootNode.ReadDirN(3)
+	log.PanicIf(err)
+
+	// The read started streaming, so it has to carry on streaming rather than
+	// starting over on the loaded children.
+
+	err = tree.Load()
+	log.PanicIf(err)
+
+	for {
+		more, err := tree.rootNode.ReadDirN(3)
+		if err == io.EOF {
+			break
+		}
+
+		log.PanicIf(err)
+
+		children = append(children, more...)
+	}
+
+	names := make([]string, len(children))
+	for i, child := range children {
+		names[i] = child.Name()
+	}
+
+	expected := []string{
+		"79c6d31a-cca1-11e9-8325-9746d045e868",
+		"2-delahaye-type-165-cabriolet-dsc_8025.jpg",
+		"testdirectory",
+		"testdirectory2",
+		"064cbfd4-cec3-11e9-926d-c362c80fab7b",
+		"testdirectory3",
+
+		// Deleted, so it's held back until the end.
+		"8fd71ab132c59bf33cd7890c0acebf12.jpg",
+	}
+
+	if reflect.DeepEqual(names, expected) != true {
+		t.Fatalf("Children not correct: %v", names)
+	}
+}
+
+func TestTreeNode_ReadDirN__RecreatedFile(t *testing.T) {
+	for _, isLoaded := range []bool{false, true} {
+		for _, n := range []int{0, 1} {
+			tree, _, closer := getTestRecreatedFileTree()
+
+			if isLoaded == true {
+				err := tree.Load()
+				log.PanicIf(err)
+			}
+
+			children := make([]*TreeNode, 0)
+
+			for {
+				more, err := tree.rootNode.ReadDirN(n)
+				if err == io.EOF {
+					break
+				}
+
+				log.PanicIf(err)
+
+				children = append(children, more...)
+
+				if n <= 0 {
+					break
+				}
+			}
+
+			closer()
+
+			if len(children) != 1 || children[0].Name() != "data.txt" {
+				t.Fatalf("Children not correct (loaded=%v, n=%d): %v", isLoaded, n, children)
+			} else if children[0].IsInUse() != true {
+				t.Fatalf("Expected the live entry (loaded=%v, n=%d).", isLoaded, n)
+			}
+		}
+	}
+}
+
+func TestTreeNode_ReadDirN__Streamed_SpecialEntryPolicy(t *testing.T) {
+	tree, closer := getTestSpecialEntryTree()
+
+	defer closer()
+
+	tree.SetSpecialEntryPolicy(SpecialEntryPolicySkip)
+
+	children, err := tree.rootNode.ReadDirN(0)
+	log.PanicIf(err)
+
+	if len(children) != 1 || children[0].Name() != "regular.txt" {
+		t.Fatalf("Expected the special entry to be skipped: %v", children)
+	}
+
+	tree, closer = getTestSpecialEntryTree()
+
+	defer closer()
+
+	tree.SetSpecialEntryPolicy(SpecialEntryPolicyError)
+
+	_, err = tree.rootNode.ReadDirN(0)
+	if err == nil {
+		t.Fatalf("Expected error for special entry.")
+	} else if errors.Is(err, ErrSpecialEntry) != true {
+		t.Fatalf("Expected ErrSpecialEntry: [%v]", err)
+	}
+}
+
+func TestTreeNode_ReadDirN__Streamed_NoStreamExtension(t *testing.T) {
+	image, _ := getTestImage()
+
+	tree, closer := getTestTree()
+
+	defer closer()
+
+	node, err := tree.LookupPath("testdirectory2")
+	log.PanicIf(err)
+
+	// Turn the stream-extension into a vendor-extension entry, which is a
+	// benign secondary that readers skip.
+	offset := node.IndexedDirectoryEntry().EntrySet.Locations[1].Offset
+	image[offset] = 0xe0
+
+	er := NewExfatReader(bytes.NewReader(image))
+
+	err = er.Parse()
+	log.PanicIf(err)
+
+	tree = NewTree(er)
+
+	_, err = tree.rootNode.ReadDirN(0)
+	if err == nil {
+		t.Fatalf("Expected error for a missing stream-extension.")
+	} else if errors.Is(err, ErrCorrupt) != true {
+		t.Fatalf("Expected corruption error: [%v]", err)
+	}
+
+	// Loading fails the same way.
+
+	err = NewTree(er).Load()
+	if err == nil {
+		t.Fatalf("Expected error from load for a missing stream-extension.")
+	}
+}
@@ -0,0 +1,179 @@
+// This package reads the up-case table and compares names the way that the
+// filesystem does.
+
+package exfat
+
+import (
+	"bytes"
+	"fmt"
+
+	"unicode/utf16"
+
+	"github.com/dsoprea/go-logging"
+)
+
+// UpcaseTable maps each UTF-16 code unit to its upper-case form (Section 7.2).
+// The filesystem uses it, rather than any locale's rules, to decide whether
+// two names are the same.
+type UpcaseTable struct {
+	// mapping has an entry for each code unit that the table covers. Code
+	// units past the end map to themselves.
+	mapping []uint16
+}
+
+// readUpcaseTableData finds the up-case table entry in the root directory and
+// reads the (possibly compressed) table.
+func (er *ExfatReader) readUpcaseTableData() (utde *ExfatUpcaseTableDirectoryEntry, data []byte, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	en := NewExfatNavigator(er, er.FirstClusterOfRootDirectory())
+
+	index, _, _, err := en.IndexDirectoryEntries()
+	log.PanicIf(err)
+
+	ideList := index["UpcaseTable"]
+	if len(ideList) == 0 {
+		log.Panicf("up-case table not found")
+	}
+
+	utde = ideList[0].PrimaryEntry.(*ExfatUpcaseTableDirectoryEntry)
+
+	// The table can describe, at most, every code unit, with each possibly
+	// preceded by a compression marker.
+	if utde.DataLength > 0x10000*4 {
+		log.Panicf("up-case table too large: (%d)", utde.DataLength)
+	}
+
+	b := new(bytes.Buffer)
+
+	_, _, err = er.WriteFromClusterChain(utde.FirstCluster, utde.DataLength, true, b)
+	log.PanicIf(err)
+
+	return utde, b.Bytes(), nil
+}
+
+// ReadUpcaseTable reads and decompresses the volume's up-case table.
+func (er *ExfatReader) ReadUpcaseTable() (ut *UpcaseTable, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	utde, data, err := er.readUpcaseTableData()
+	log.PanicIf(err)
+
+	checksum := upcaseTableChecksum(data)
+	if checksum != utde.TableChecksum {
+		ce := newCorruptionError("up-case table", log.Errorf("checksum does not match: (0x%08x) != (0x%08x)", checksum, utde.TableChecksum))
+		ce.ClusterNumber = utde.FirstCluster
+
+		log.Panic(ce)
+	}
+
+	ut, err = newUpcaseTable(data)
+	log.PanicIf(err)
+
+	return ut, nil
+}
+
+// newUpcaseTable decodes a raw up-case table. In the compressed form (Section
+// 7.2.5.1), FFFFh followed by a count stands for that many code units that
+// map to themselves.
+func newUpcaseTable(data []byte) (ut *UpcaseTable, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	if len(data)%2 != 0 {
+		log.Panicf("up-case table has an odd size: (%d)", len(data))
+	}
+
+	mapping := make([]uint16, 0, 0x10000)
+
+	for i := 0; i < len(data); i += 2 {
+		value := defaultEncoding.Uint16(data[i : i+2])
+
+		if value == 0xffff && i+4 <= len(data) {
+			i += 2
+			count := int(defaultEncoding.Uint16(data[i : i+2]))
+
+			for j := 0; j < count; j++ {
+				mapping = append(mapping, uint16(len(mapping)))
+			}
+		} else {
+			mapping = append(mapping, value)
+		}
+
+		if len(mapping) > 0x10000 {
+			log.Panicf("up-case table describes more than every code unit")
+		}
+	}
+
+	ut = &UpcaseTable{
+		mapping: mapping,
+	}
+
+	return ut, nil
+}
+
+// upcaseUnits returns the up-cased UTF-16 code units of the given name.
+func (ut *UpcaseTable) upcaseUnits(name string) []uint16 {
+	units := utf16.Encode([]rune(name))
+
+	for i, unit := range units {
+		if int(unit) < len(ut.mapping) {
+			units[i] = ut.mapping[unit]
+		}
+	}
+
+	return units
+}
+
+// ToUpper returns the name as the filesystem up-cases it.
+func (ut *UpcaseTable) ToUpper(name string) string {
+	return string(utf16.Decode(ut.upcaseUnits(name)))
+}
+
+// EqualFold indicates whether the two names are the same to the filesystem
+// (i.e. the same once up-cased with the volume's table). Unlike
+// `strings.EqualFold`, this does not apply Unicode folding that the
+// filesystem doesn't.
+func (ut *UpcaseTable) EqualFold(a, b string) bool {
+	return ut.CompareNames(a, b) == 0
+}
+
+// CompareNames compares the up-cased forms of the two names, code unit by code
+// unit. It returns a negative number, zero, or a positive number if `a` sorts
+// before, the same as, or after `b`.
+func (ut *UpcaseTable) CompareNames(a, b string) int {
+	aUnits := ut.upcaseUnits(a)
+	bUnits := ut.upcaseUnits(b)
+
+	for i := 0; i < len(aUnits) && i < len(bUnits); i++ {
+		if aUnits[i] < bUnits[i] {
+			return -1
+		} else if aUnits[i] > bUnits[i] {
+			return 1
+		}
+	}
+
+	if len(aUnits) < len(bUnits) {
+		return -1
+	} else if len(aUnits) > len(bUnits) {
+		return 1
+	}
+
+	return 0
+}
+
+// String returns a string description.
+func (ut *UpcaseTable) String() string {
+	return fmt.Sprintf("UpcaseTable<MAPPED-CODE-UNITS=(%d)>", len(ut.mapping))
+}
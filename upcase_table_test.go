@@ -0,0 +1,106 @@
+package exfat
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/dsoprea/go-logging"
+)
+
+func getTestUpcaseTable() *UpcaseTable {
+	f, er := getTestFileAndParser()
+
+	defer f.Close()
+
+	err := er.Parse()
+	log.PanicIf(err)
+
+	ut, err := er.ReadUpcaseTable()
+	log.PanicIf(err)
+
+	return ut
+}
+
+func TestExfatReader_ReadUpcaseTable(t *testing.T) {
+	ut := getTestUpcaseTable()
+
+	if len(ut.mapping) != 0x10000 {
+		t.Fatalf("Mapped code-unit count not correct: (%d)", len(ut.mapping))
+	}
+
+	upper := ut.ToUpper("testdirectory2-straße")
+	if upper != "TESTDIRECTORY2-STRAßE" {
+		t.Fatalf("Up-cased name not correct: [%s]", upper)
+	}
+}
+
+func TestUpcaseTable_EqualFold(t *testing.T) {
+	ut := getTestUpcaseTable()
+
+	if ut.EqualFold("TestDirectory2", "testdirectory2") != true {
+		t.Fatalf("Expected names to be equal.")
+	} else if ut.EqualFold("äpfel", "ÄPFEL") != true {
+		t.Fatalf("Expected non-ASCII names to be equal.")
+	} else if ut.EqualFold("file1", "file2") != false {
+		t.Fatalf("Expected names to differ.")
+	}
+
+	// Go folds the long-s to "s" but the volume's table leaves it alone.
+	if strings.EqualFold("ſ", "s") != true {
+		t.Fatalf("Expected Go to fold the long-s.")
+	} else if ut.EqualFold("ſ", "s") != false {
+		t.Fatalf("Expected the table not to fold the long-s.")
+	}
+}
+
+func TestUpcaseTable_CompareNames(t *testing.T) {
+	ut := getTestUpcaseTable()
+
+	cases := []struct {
+		a        string
+		b        string
+		expected int
+	}{
+		{"a", "B", -1},
+		{"B", "a", 1},
+		{"abc", "ABC", 0},
+		{"ab", "ABC", -1},
+		{"abc", "AB", 1},
+		{"", "", 0},
+	}
+
+	for _, c := range cases {
+		if actual := ut.CompareNames(c.a, c.b); actual != c.expected {
+			t.Fatalf("Comparison of [%s] and [%s] not correct: (%d) != (%d)", c.a, c.b, actual, c.expected)
+		}
+	}
+}
+
+func TestNewUpcaseTable__Compressed(t *testing.T) {
+	// 97 code units that map to themselves, then "a" and "b" up-cased.
+	data := []byte{
+		0xff, 0xff, 0x61, 0x00,
+		0x41, 0x00,
+		0x42, 0x00,
+	}
+
+	ut, err := newUpcaseTable(data)
+	log.PanicIf(err)
+
+	if len(ut.mapping) != 99 {
+		t.Fatalf("Mapped code-unit count not correct: (%d)", len(ut.mapping))
+	}
+
+	// "c" is past the end of the table.
+	upper := ut.ToUpper("0abc")
+	if upper != "0ABc" {
+		t.Fatalf("Up-cased name not correct: [%s]", upper)
+	}
+}
+
+func TestNewUpcaseTable__OddSize(t *testing.T) {
+	_, err := newUpcaseTable([]byte{0x41, 0x00, 0x42})
+	if err == nil {
+		t.Fatalf("Expected error for odd size.")
+	}
+}
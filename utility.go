@@ -1,6 +1,8 @@
 package exfat
 
 import (
+	"unicode"
+
 	"unicode/utf16"
 )
 
@@ -10,20 +12,33 @@ func UnicodeFromAscii(raw []byte, unicodeCharCount int) string {
 	// corresponds to the number of Unicode characters. The character-count may
 	// still include trailing NULs, sowe intentional skip over those.
 
-	decodedString := make([]rune, 0)
+	decodedString := appendUnicodeFromAscii(make([]rune, 0, unicodeCharCount), raw, unicodeCharCount)
+
+	return string(decodedString)
+}
+
+// appendUnicodeFromAscii decodes like UnicodeFromAscii() but appends to the
+// given runes, so that a string spread over several entries can be decoded
+// into one buffer.
+func appendUnicodeFromAscii(decodedString []rune, raw []byte, unicodeCharCount int) []rune {
 	for i := 0; i < unicodeCharCount; i++ {
 		wchar1 := uint16(raw[i*2+1])
 		wchar2 := uint16(raw[i*2])
 
-		bytes := []uint16{wchar1<<8 | wchar2}
-		runes := utf16.Decode(bytes)
+		r := rune(wchar1<<8 | wchar2)
 
-		if runes[0] == 0 {
+		if r == 0 {
 			continue
 		}
 
-		decodedString = append(decodedString, runes...)
+		// Each unit is decoded on its own, so half of a surrogate pair is
+		// replaced like utf16.Decode() would.
+		if utf16.IsSurrogate(r) == true {
+			r = unicode.ReplacementChar
+		}
+
+		decodedString = append(decodedString, r)
 	}
 
-	return string(decodedString)
+	return decodedString
 }
@@ -12,3 +12,13 @@ func TestUnicodeFromAscii(t *testing.T) {
 		t.Fatalf("Ascii not decoded to Unicode correctly.")
 	}
 }
+
+func TestUnicodeFromAscii__Surrogate(t *testing.T) {
+	// "a", then a surrogate pair, then a NUL.
+	b := []byte{'a', 0, 0x3d, 0xd8, 0x00, 0xde, 0, 0}
+	s := UnicodeFromAscii(b, 4)
+
+	if s != "a\ufffd\ufffd" {
+		t.Fatalf("Surrogates not decoded like utf16.Decode(): [%q]", s)
+	}
+}
@@ -0,0 +1,110 @@
+package exfat
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/dsoprea/go-logging"
+)
+
+// compareWriter compares everything written to it against the data read from
+// another reader and records the offset of the first difference.
+type compareWriter struct {
+	r io.Reader
+
+	// buffer is reused for the data read from `r`.
+	buffer []byte
+
+	offset         uint64
+	mismatchOffset uint64
+	isMismatched   bool
+}
+
+// Write compares the given data against the next chunk of the reader.
+func (cw *compareWriter) Write(data []byte) (n int, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	if cw.isMismatched == true {
+		cw.offset += uint64(len(data))
+		return len(data), nil
+	}
+
+	if cap(cw.buffer) < len(data) {
+		cw.buffer = make([]byte, len(data))
+	}
+
+	actual := cw.buffer[:len(data)]
+
+	readCount, err := io.ReadFull(cw.r, actual)
+	if err == io.ErrUnexpectedEOF || err == io.EOF {
+		err = nil
+	}
+
+	log.PanicIf(err)
+
+	actual = actual[:readCount]
+
+	if bytes.Equal(actual, data) == false {
+		cw.isMismatched = true
+
+		// If every byte that we could read matched, the local data was just
+		// short.
+		cw.mismatchOffset = cw.offset + uint64(readCount)
+
+		for i := range actual {
+			if actual[i] != data[i] {
+				cw.mismatchOffset = cw.offset + uint64(i)
+				break
+			}
+		}
+	}
+
+	cw.offset += uint64(len(data))
+
+	return len(data), nil
+}
+
+// VerifyFromClusterChain re-reads the data for the given cluster chain from the
+// image and compares it, byte for byte, against the data from the given reader
+// (e.g. a previously extracted file). If they differ, `isMatched` will be false
+// and `mismatchOffset` will be the offset of the first difference. Data that
+// follows the expected amount in the reader is also considered a difference.
+func (er *ExfatReader) VerifyFromClusterChain(firstClusterNumber uint32, dataSize uint64, useFat bool, r io.Reader) (isMatched bool, mismatchOffset uint64, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	cw := &compareWriter{
+		r: r,
+	}
+
+	if dataSize > 0 {
+		_, _, err = er.WriteFromClusterChain(firstClusterNumber, dataSize, useFat, cw)
+		log.PanicIf(err)
+	}
+
+	if cw.isMismatched == true {
+		return false, cw.mismatchOffset, nil
+	}
+
+	// Make sure that there is no excess data.
+
+	excess := make([]byte, 1)
+
+	readCount, err := r.Read(excess)
+	if err != io.EOF {
+		log.PanicIf(err)
+	}
+
+	if readCount > 0 {
+		return false, dataSize, nil
+	}
+
+	return true, 0, nil
+}
@@ -0,0 +1,97 @@
+package exfat
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/dsoprea/go-logging"
+)
+
+func getTestFileData(tree *Tree, volumePath string) (sede *ExfatStreamExtensionDirectoryEntry, data []byte) {
+	node, err := tree.LookupPath(volumePath)
+	log.PanicIf(err)
+
+	sede = node.StreamDirectoryEntry()
+	useFat := sede.GeneralSecondaryFlags.NoFatChain() == false
+
+	b := new(bytes.Buffer)
+
+	_, _, err = tree.er.WriteFromClusterChain(sede.FirstCluster, sede.ValidDataLength, useFat, b)
+	log.PanicIf(err)
+
+	return sede, b.Bytes()
+}
+
+func TestExfatReader_VerifyFromClusterChain__Match(t *testing.T) {
+	tree, closer := getTestTree()
+
+	defer closer()
+
+	sede, data := getTestFileData(tree, "2-delahaye-type-165-cabriolet-dsc_8025.jpg")
+	useFat := sede.GeneralSecondaryFlags.NoFatChain() == false
+
+	isMatched, _, err := tree.er.VerifyFromClusterChain(sede.FirstCluster, sede.ValidDataLength, useFat, bytes.NewReader(data))
+	log.PanicIf(err)
+
+	if isMatched != true {
+		t.Fatalf("Expected match.")
+	}
+}
+
+func TestExfatReader_VerifyFromClusterChain__Mismatch(t *testing.T) {
+	tree, closer := getTestTree()
+
+	defer closer()
+
+	sede, data := getTestFileData(tree, "2-delahaye-type-165-cabriolet-dsc_8025.jpg")
+	useFat := sede.GeneralSecondaryFlags.NoFatChain() == false
+
+	data[1000]++
+
+	isMatched, mismatchOffset, err := tree.er.VerifyFromClusterChain(sede.FirstCluster, sede.ValidDataLength, useFat, bytes.NewReader(data))
+	log.PanicIf(err)
+
+	if isMatched != false {
+		t.Fatalf("Expected mismatch.")
+	} else if mismatchOffset != 1000 {
+		t.Fatalf("Mismatch offset not correct: (%d)", mismatchOffset)
+	}
+}
+
+func TestExfatReader_VerifyFromClusterChain__Short(t *testing.T) {
+	tree, closer := getTestTree()
+
+	defer closer()
+
+	sede, data := getTestFileData(tree, "2-delahaye-type-165-cabriolet-dsc_8025.jpg")
+	useFat := sede.GeneralSecondaryFlags.NoFatChain() == false
+
+	isMatched, mismatchOffset, err := tree.er.VerifyFromClusterChain(sede.FirstCluster, sede.ValidDataLength, useFat, bytes.NewReader(data[:5000]))
+	log.PanicIf(err)
+
+	if isMatched != false {
+		t.Fatalf("Expected mismatch.")
+	} else if mismatchOffset != 5000 {
+		t.Fatalf("Mismatch offset not correct: (%d)", mismatchOffset)
+	}
+}
+
+func TestExfatReader_VerifyFromClusterChain__Long(t *testing.T) {
+	tree, closer := getTestTree()
+
+	defer closer()
+
+	sede, data := getTestFileData(tree, "testdirectory2/file1")
+	useFat := sede.GeneralSecondaryFlags.NoFatChain() == false
+
+	data = append(data, 'x')
+
+	isMatched, mismatchOffset, err := tree.er.VerifyFromClusterChain(sede.FirstCluster, sede.ValidDataLength, useFat, bytes.NewReader(data))
+	log.PanicIf(err)
+
+	if isMatched != false {
+		t.Fatalf("Expected mismatch.")
+	} else if mismatchOffset != sede.ValidDataLength {
+		t.Fatalf("Mismatch offset not correct: (%d)", mismatchOffset)
+	}
+}
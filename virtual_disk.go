@@ -0,0 +1,296 @@
+// This package supports finding the image inside of common virtual-disk
+// wrappers.
+
+package exfat
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"encoding/binary"
+
+	"github.com/dsoprea/go-logging"
+)
+
+const (
+	// vhdFooterSize is the size of the footer at the end of every VHD.
+	vhdFooterSize = 512
+
+	vhdDiskTypeFixed        = 2
+	vhdDiskTypeDynamic      = 3
+	vhdDiskTypeDifferencing = 4
+)
+
+var (
+	vhdCookie   = []byte("conectix")
+	vhdxCookie  = []byte("vhdxfile")
+	qcow2Cookie = []byte("QFI\xfb")
+)
+
+var (
+	// ErrVirtualDiskNotSupported indicates that the image is in a virtual-disk
+	// format that was recognized but whose data can not be read directly
+	// (e.g. a dynamically-sized VHD, a VHDX, or a qcow2). Convert it to a
+	// raw or fixed-size image first.
+	ErrVirtualDiskNotSupported = errors.New("virtual-disk format not supported")
+)
+
+// VirtualDiskFormat identifies the wrapper around an image.
+type VirtualDiskFormat string
+
+const (
+	// VirtualDiskFormatRaw is an image without a wrapper.
+	VirtualDiskFormatRaw VirtualDiskFormat = "raw"
+
+	// VirtualDiskFormatFixedVhd is a fixed-size VHD, which is the raw data
+	// followed by a footer.
+	VirtualDiskFormatFixedVhd VirtualDiskFormat = "vhd-fixed"
+
+	// VirtualDiskFormatDynamicVhd is a dynamically-sized or differencing VHD.
+	VirtualDiskFormatDynamicVhd VirtualDiskFormat = "vhd-dynamic"
+
+	// VirtualDiskFormatVhdx is a VHDX.
+	VirtualDiskFormatVhdx VirtualDiskFormat = "vhdx"
+
+	// VirtualDiskFormatQcow2 is a QEMU qcow2 image.
+	VirtualDiskFormatQcow2 VirtualDiskFormat = "qcow2"
+)
+
+// VirtualDiskInfo describes where the data is in a (possibly wrapped) image.
+type VirtualDiskInfo struct {
+	Format VirtualDiskFormat
+
+	// PayloadOffset and PayloadSize locate the data within the file. They are
+	// only set if IsSupported() is true.
+	PayloadOffset int64
+	PayloadSize   int64
+}
+
+// String returns a descriptive string.
+func (vdi VirtualDiskInfo) String() string {
+	return fmt.Sprintf("VirtualDiskInfo<FORMAT=[%s] PAYLOAD-OFFSET=(%d) PAYLOAD-SIZE=(%d)>", vdi.Format, vdi.PayloadOffset, vdi.PayloadSize)
+}
+
+// IsSupported indicates whether the data can be read directly from the
+// payload region.
+func (vdi VirtualDiskInfo) IsSupported() bool {
+	return vdi.Format == VirtualDiskFormatRaw || vdi.Format == VirtualDiskFormatFixedVhd
+}
+
+// readVhdFooter returns the disk-type and disk-size from the VHD footer at
+// the given offset. The disk-type is zero if there isn't a valid footer there.
+func readVhdFooter(ra io.ReaderAt, offset int64) (diskType uint32, currentSize uint64, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	footer := make([]byte, vhdFooterSize)
+
+	n, err := ra.ReadAt(footer, offset)
+	if n < len(footer) {
+		if err == nil || err == io.EOF {
+			return 0, 0, nil
+		}
+
+		log.Panic(err)
+	}
+
+	if bytes.Equal(footer[0:8], vhdCookie) == false {
+		return 0, 0, nil
+	}
+
+	// The checksum is the ones'-complement of the sum of every byte but
+	// those of the checksum itself.
+
+	sum := uint32(0)
+	for i, b := range footer {
+		if i >= 64 && i < 68 {
+			continue
+		}
+
+		sum += uint32(b)
+	}
+
+	if ^sum != binary.BigEndian.Uint32(footer[64:68]) {
+		return 0, 0, nil
+	}
+
+	currentSize = binary.BigEndian.Uint64(footer[48:56])
+	diskType = binary.BigEndian.Uint32(footer[60:64])
+
+	return diskType, currentSize, nil
+}
+
+// DetectVirtualDisk identifies the virtual-disk wrapper (if any) around the
+// given image, which is `size` bytes long, and locates its data. Fixed-size
+// VHDs are supported by skipping the footer. Other recognized formats are
+// reported with IsSupported() returning false. Anything that isn't recognized
+// is taken to be raw.
+func DetectVirtualDisk(ra io.ReaderAt, size int64) (vdi VirtualDiskInfo, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	vdi = VirtualDiskInfo{
+		Format:      VirtualDiskFormatRaw,
+		PayloadSize: size,
+	}
+
+	header := make([]byte, 8)
+
+	n, err := ra.ReadAt(header, 0)
+	if n < len(header) {
+		if err == nil || err == io.EOF {
+			return vdi, nil
+		}
+
+		log.Panic(err)
+	}
+
+	if bytes.Equal(header, vhdxCookie) == true {
+		return VirtualDiskInfo{Format: VirtualDiskFormatVhdx}, nil
+	} else if bytes.Equal(header[:4], qcow2Cookie) == true {
+		return VirtualDiskInfo{Format: VirtualDiskFormatQcow2}, nil
+	}
+
+	if size < vhdFooterSize {
+		return vdi, nil
+	}
+
+	diskType, currentSize, err := readVhdFooter(ra, size-vhdFooterSize)
+	log.PanicIf(err)
+
+	switch diskType {
+	case vhdDiskTypeFixed:
+		payloadSize := size - vhdFooterSize
+
+		// The payload may have been padded to a multiple of the sector-size,
+		// but it can't be smaller than the disk.
+		if currentSize > uint64(payloadSize) {
+			log.Panicf("fixed VHD is smaller than its disk-size: (%d) < (%d)", payloadSize, currentSize)
+		}
+
+		vdi = VirtualDiskInfo{
+			Format:        VirtualDiskFormatFixedVhd,
+			PayloadOffset: 0,
+			PayloadSize:   int64(currentSize),
+		}
+	case vhdDiskTypeDynamic, vhdDiskTypeDifferencing:
+		vdi = VirtualDiskInfo{
+			Format: VirtualDiskFormatDynamicVhd,
+		}
+	}
+
+	return vdi, nil
+}
+
+// sectionBackend is the payload region of a file.
+type sectionBackend struct {
+	*io.SectionReader
+
+	f      *os.File
+	offset int64
+}
+
+// WriteAt writes to the payload. Writes can't extend past it.
+func (sb sectionBackend) WriteAt(data []byte, offset int64) (n int, err error) {
+	if offset < 0 || offset+int64(len(data)) > sb.Size() {
+		return 0, log.Errorf("write not within the payload: (%d) + (%d) > (%d)", offset, len(data), sb.Size())
+	}
+
+	return sb.f.WriteAt(data, sb.offset+offset)
+}
+
+// Close closes the file.
+func (sb sectionBackend) Close() error {
+	return sb.f.Close()
+}
+
+// OpenImageBackend opens an image file as a Backend, unwrapping it if it is a
+// fixed-size VHD so that the tools can be pointed at one directly. Every
+// command opens its image this way (or with OpenWritableImageBackend()), so
+// none of them have to handle VHDs themselves. Returns
+// ErrVirtualDiskNotSupported for other virtual-disk formats.
+func OpenImageBackend(filepath string) (backend Backend, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	backend, err = openImageBackend(filepath, os.O_RDONLY)
+	log.PanicIf(err)
+
+	return backend, nil
+}
+
+// OpenWritableImageBackend is the same as OpenImageBackend() but the file is
+// opened for writing, and the backend also implements io.WriterAt (writing
+// to the payload if the image is wrapped). Wrap it with NewBackendReader() to
+// change the image in place (e.g. with SetVolumeLabel()).
+func OpenWritableImageBackend(filepath string) (backend Backend, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	backend, err = openImageBackend(filepath, os.O_RDWR)
+	log.PanicIf(err)
+
+	return backend, nil
+}
+
+// openImageBackend opens the image file with the given flags and unwraps it.
+func openImageBackend(filepath string, flags int) (backend Backend, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	f, err := os.OpenFile(filepath, flags, 0)
+	log.PanicIf(err)
+
+	isDone := false
+
+	defer func() {
+		if isDone == false {
+			f.Close()
+		}
+	}()
+
+	fi, err := f.Stat()
+	log.PanicIf(err)
+
+	vdi, err := DetectVirtualDisk(f, fi.Size())
+	log.PanicIf(err)
+
+	if vdi.IsSupported() == false {
+		return nil, ErrVirtualDiskNotSupported
+	}
+
+	if vdi.Format == VirtualDiskFormatRaw {
+		backend = rawBackend{
+			File: f,
+			size: fi.Size(),
+		}
+	} else {
+		backend = sectionBackend{
+			SectionReader: io.NewSectionReader(f, vdi.PayloadOffset, vdi.PayloadSize),
+			f:             f,
+			offset:        vdi.PayloadOffset,
+		}
+	}
+
+	isDone = true
+
+	return backend, nil
+}
@@ -0,0 +1,223 @@
+package exfat
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"encoding/binary"
+
+	"github.com/dsoprea/go-logging"
+)
+
+// getTestVhdFooter returns a VHD footer with a valid checksum.
+func getTestVhdFooter(diskType uint32, diskSize uint64) []byte {
+	footer := make([]byte, vhdFooterSize)
+
+	copy(footer[0:8], vhdCookie)
+	binary.BigEndian.PutUint64(footer[40:48], diskSize)
+	binary.BigEndian.PutUint64(footer[48:56], diskSize)
+	binary.BigEndian.PutUint32(footer[60:64], diskType)
+
+	sum := uint32(0)
+	for _, b := range footer {
+		sum += uint32(b)
+	}
+
+	binary.BigEndian.PutUint32(footer[64:68], ^sum)
+
+	return footer
+}
+
+// getTestWrappedImage writes the test image to a temporary file with the given
+// prefix and suffix.
+func getTestWrappedImage(prefix, suffix []byte) (filepath string, image []byte, closer func()) {
+	image, _ = getTestImage()
+
+	f, err := ioutil.TempFile("", "exfat-vhd-")
+	log.PanicIf(err)
+
+	defer f.Close()
+
+	for _, data := range [][]byte{prefix, image, suffix} {
+		_, err := f.Write(data)
+		log.PanicIf(err)
+	}
+
+	closer = func() {
+		os.Remove(f.Name())
+	}
+
+	return f.Name(), image, closer
+}
+
+func TestOpenImageBackend__FixedVhd(t *testing.T) {
+	image, _ := getTestImage()
+
+	footer := getTestVhdFooter(vhdDiskTypeFixed, uint64(len(image)))
+
+	filepath, _, closer := getTestWrappedImage(nil, footer)
+	defer closer()
+
+	f, err := os.Open(filepath)
+	log.PanicIf(err)
+
+	vdi, err := DetectVirtualDisk(f, int64(len(image)+len(footer)))
+	log.PanicIf(err)
+
+	f.Close()
+
+	if vdi.Format != VirtualDiskFormatFixedVhd {
+		t.Fatalf("Format not correct: %s", vdi)
+	} else if vdi.PayloadOffset != 0 || vdi.PayloadSize != int64(len(image)) {
+		t.Fatalf("Payload not correct: %s", vdi)
+	}
+
+	backend, err := OpenImageBackend(filepath)
+	log.PanicIf(err)
+
+	er := NewExfatReaderFromBackend(backend)
+
+	defer er.Close()
+
+	err = er.Parse()
+	log.PanicIf(err)
+
+	if backend.Size() != int64(len(image)) {
+		t.Fatalf("Size not correct: (%d)", backend.Size())
+	}
+
+	data, err := er.ReadFile("79c6d31a-cca1-11e9-8325-9746d045e868")
+	log.PanicIf(err)
+
+	if len(data) != 29 {
+		t.Fatalf("File not read correctly: (%d)", len(data))
+	}
+}
+
+func TestOpenWritableImageBackend__FixedVhd(t *testing.T) {
+	image, _ := getTestImage()
+
+	footer := getTestVhdFooter(vhdDiskTypeFixed, uint64(len(image)))
+
+	filepath, _, closer := getTestWrappedImage(nil, footer)
+	defer closer()
+
+	backend, err := OpenWritableImageBackend(filepath)
+	log.PanicIf(err)
+
+	br := NewBackendReader(backend)
+
+	err = SetVolumeLabel(br, "vhd label")
+	log.PanicIf(err)
+
+	_, err = br.WriteAt(make([]byte, 10), int64(len(image))-5)
+	if err == nil {
+		t.Fatalf("Expected error for a write past the payload.")
+	}
+
+	br.Close()
+
+	// The label should have been written to the payload rather than the
+	// start of the file, and the footer left alone.
+
+	backend, err = OpenImageBackend(filepath)
+	log.PanicIf(err)
+
+	er := NewExfatReaderFromBackend(backend)
+
+	defer er.Close()
+
+	err = er.Parse()
+	log.PanicIf(err)
+
+	vi, err := er.VolumeInfo()
+	log.PanicIf(err)
+
+	if vi.Label != "vhd label" {
+		t.Fatalf("Label not correct: [%s]", vi.Label)
+	}
+
+	wrapped, err := ioutil.ReadFile(filepath)
+	log.PanicIf(err)
+
+	if len(wrapped) != len(image)+len(footer) {
+		t.Fatalf("Image size changed: (%d)", len(wrapped))
+	} else if bytes.Equal(wrapped[len(image):], footer) != true {
+		t.Fatalf("Footer was changed.")
+	}
+}
+
+func TestOpenImageBackend__Raw(t *testing.T) {
+	backend, err := OpenImageBackend(path.Join(assetPath, "test.exfat"))
+	log.PanicIf(err)
+
+	defer backend.Close()
+
+	if _, ok := backend.(rawBackend); ok != true {
+		t.Fatalf("Expected a raw backend: [%T]", backend)
+	}
+}
+
+func TestOpenImageBackend__BadChecksum(t *testing.T) {
+	image, _ := getTestImage()
+
+	footer := getTestVhdFooter(vhdDiskTypeFixed, uint64(len(image)))
+	footer[100]++
+
+	filepath, _, closer := getTestWrappedImage(nil, footer)
+	defer closer()
+
+	backend, err := OpenImageBackend(filepath)
+	log.PanicIf(err)
+
+	defer backend.Close()
+
+	// Without a valid footer, the whole file is taken as the image.
+	if backend.Size() != int64(len(image)+len(footer)) {
+		t.Fatalf("Expected the footer to be ignored: (%d)", backend.Size())
+	}
+}
+
+func TestOpenImageBackend__NotSupported(t *testing.T) {
+	cases := []struct {
+		prefix []byte
+		suffix []byte
+		format VirtualDiskFormat
+	}{
+		{getTestVhdFooter(vhdDiskTypeDynamic, 1), getTestVhdFooter(vhdDiskTypeDynamic, 1), VirtualDiskFormatDynamicVhd},
+		{vhdxCookie, nil, VirtualDiskFormatVhdx},
+		{qcow2Cookie, nil, VirtualDiskFormatQcow2},
+	}
+
+	for _, c := range cases {
+		filepath, _, closer := getTestWrappedImage(c.prefix, c.suffix)
+
+		f, err := os.Open(filepath)
+		log.PanicIf(err)
+
+		fi, err := f.Stat()
+		log.PanicIf(err)
+
+		vdi, err := DetectVirtualDisk(f, fi.Size())
+		log.PanicIf(err)
+
+		f.Close()
+
+		if vdi.Format != c.format {
+			t.Fatalf("Format not correct: [%s] != [%s]", vdi.Format, c.format)
+		} else if vdi.IsSupported() != false {
+			t.Fatalf("Expected format to not be supported: [%s]", vdi.Format)
+		}
+
+		_, err = OpenImageBackend(filepath)
+		if errors.Is(err, ErrVirtualDiskNotSupported) != true {
+			t.Fatalf("Expected not-supported error for [%s]: [%v]", c.format, err)
+		}
+
+		closer()
+	}
+}
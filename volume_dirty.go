@@ -0,0 +1,94 @@
+// This package supports marking a volume as dirty or clean.
+
+package exfat
+
+import (
+	"io"
+	"os"
+
+	"github.com/dsoprea/go-logging"
+)
+
+const (
+	// volumeFlagsOffset is the offset of the VolumeFlags field within the
+	// main boot sector (Section 3.1.13). The boot checksum skips it, so it can
+	// be changed without recalculating anything.
+	volumeFlagsOffset = 106
+)
+
+// writeAt writes the given data at the given absolute offset. The underlying
+// image must support writes.
+func (er *ExfatReader) writeAt(data []byte, offset int64) (err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	if wa, ok := er.rs.(io.WriterAt); ok == true {
+		_, err := wa.WriteAt(data, offset)
+		log.PanicIf(err)
+
+		return nil
+	}
+
+	w, ok := er.rs.(io.Writer)
+	if ok == false {
+		log.Panicf("image is not writable")
+	}
+
+	er.readLocker.Lock()
+	defer er.readLocker.Unlock()
+
+	_, err = er.seek(offset, os.SEEK_SET)
+	log.PanicIf(err)
+
+	_, err = w.Write(data)
+	log.PanicIf(err)
+
+	return nil
+}
+
+// SetDirty sets or clears the VolumeDirty flag in the main boot sector
+// (Section 3.1.13.2). Anything that changes the volume should set it first and
+// clear it once the volume is consistent again, so that an interrupted change
+// is caught by the next check. The reader must have been created with an
+// image that is also writable (e.g. a file opened for writing) and must be
+// parsed. The backup boot sector is left alone, as the spec requires. This
+// must not be called concurrently with anything else on the reader.
+func (er *ExfatReader) SetDirty(isDirty bool) (err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	if er.bootRegion.isEmpty() == true {
+		log.Panicf("boot-sectors not loaded yet")
+	}
+
+	// Start from what's on disk, since the active boot sector might have been
+	// the backup.
+
+	raw := make([]byte, 2)
+
+	err = er.readAt(raw, volumeFlagsOffset)
+	log.PanicIf(err)
+
+	vf := VolumeFlags(defaultEncoding.Uint16(raw))
+
+	if isDirty == true {
+		vf |= VolumeFlagVolumeDirty
+	} else {
+		vf &^= VolumeFlagVolumeDirty
+	}
+
+	defaultEncoding.PutUint16(raw, uint16(vf))
+
+	err = er.writeAt(raw, volumeFlagsOffset)
+	log.PanicIf(err)
+
+	er.bootRegion.bsh.VolumeFlags = vf
+
+	return nil
+}
@@ -0,0 +1,74 @@
+package exfat
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/dsoprea/go-logging"
+)
+
+func TestExfatReader_SetDirty(t *testing.T) {
+	f, closer := getTestWritableImage()
+
+	defer closer()
+
+	er := NewExfatReader(f)
+
+	err := er.Parse()
+	log.PanicIf(err)
+
+	if er.ActiveBootSectorHeader().VolumeFlags.IsDirty() != false {
+		t.Fatalf("Expected test image to be clean.")
+	}
+
+	err = er.SetDirty(true)
+	log.PanicIf(err)
+
+	if er.ActiveBootSectorHeader().VolumeFlags.IsDirty() != true {
+		t.Fatalf("Expected flag to be set in memory.")
+	}
+
+	// Reparse to make sure it was written and that the checksum still holds.
+
+	_, err = f.Seek(0, os.SEEK_SET)
+	log.PanicIf(err)
+
+	er = NewExfatReader(f)
+
+	err = er.Parse()
+	log.PanicIf(err)
+
+	if er.ActiveBootSectorHeader().VolumeFlags.IsDirty() != true {
+		t.Fatalf("Expected flag to be set on disk.")
+	}
+
+	err = er.SetDirty(false)
+	log.PanicIf(err)
+
+	// The image should be back to how it started.
+
+	original, _ := getTestImage()
+
+	updated, err := ioutil.ReadFile(f.Name())
+	log.PanicIf(err)
+
+	if bytes.Equal(original, updated) != true {
+		t.Fatalf("Image not restored after clearing the flag.")
+	}
+}
+
+func TestExfatReader_SetDirty__NotWritable(t *testing.T) {
+	f, er := getTestFileAndParser()
+
+	defer f.Close()
+
+	err := er.Parse()
+	log.PanicIf(err)
+
+	err = er.SetDirty(true)
+	if err == nil {
+		t.Fatalf("Expected error for read-only image.")
+	}
+}
@@ -0,0 +1,99 @@
+// This package summarizes the identity of a volume.
+
+package exfat
+
+import (
+	"fmt"
+
+	"github.com/dsoprea/go-logging"
+)
+
+// VolumeInfo combines the identifying parameters from the boot-sector with the
+// label and GUID from the root directory.
+type VolumeInfo struct {
+	// Label is the volume label. It is empty if the volume doesn't have one.
+	Label string `json:"label" yaml:"label"`
+
+	// Guid is the volume GUID in its canonical, hyphenated form. It is empty
+	// if the volume doesn't have one (it's optional).
+	Guid string `json:"guid,omitempty" yaml:"guid,omitempty"`
+
+	SerialNumber       uint32 `json:"serial_number" yaml:"serial_number"`
+	FileSystemRevision string `json:"file_system_revision" yaml:"file_system_revision"`
+
+	// Size is the size of the volume in bytes.
+	Size uint64 `json:"size" yaml:"size"`
+
+	ClusterSize  uint32 `json:"cluster_size" yaml:"cluster_size"`
+	ClusterCount uint32 `json:"cluster_count" yaml:"cluster_count"`
+	PercentInUse uint8  `json:"percent_in_use" yaml:"percent_in_use"`
+
+	// IsTexFat indicates whether the volume has a second FAT and allocation
+	// bitmap (TexFAT).
+	IsTexFat bool `json:"is_texfat" yaml:"is_texfat"`
+
+	// ActiveFat is the zero-based index of the FAT and allocation bitmap that
+	// are in use. It can only be nonzero on TexFAT volumes.
+	ActiveFat int `json:"active_fat" yaml:"active_fat"`
+
+	// SelectedBootRegion is the boot region ("main" or "backup") that the rest
+	// of the volume was located with. See ExfatReader.SelectedBootRegion().
+	SelectedBootRegion string `json:"selected_boot_region" yaml:"selected_boot_region"`
+}
+
+// String returns a descriptive string.
+func (vi VolumeInfo) String() string {
+	return fmt.Sprintf("VolumeInfo<LABEL=[%s] GUID=[%s] SN=(0x%08x) SIZE=(%d) IS-TEXFAT=[%v]>", vi.Label, vi.Guid, vi.SerialNumber, vi.Size, vi.IsTexFat)
+}
+
+// FormatGuid returns the canonical form of a raw GUID. As with all GUIDs
+// stored by Microsoft, the first three groups are little-endian.
+func FormatGuid(raw [16]byte) string {
+	return fmt.Sprintf(
+		"%08x-%04x-%04x-%x-%x",
+		defaultEncoding.Uint32(raw[0:4]),
+		defaultEncoding.Uint16(raw[4:6]),
+		defaultEncoding.Uint16(raw[6:8]),
+		raw[8:10],
+		raw[10:16])
+}
+
+// VolumeInfo returns the volume's label, GUID, serial number, and geometry.
+// This reads the root directory.
+func (er *ExfatReader) VolumeInfo() (vi VolumeInfo, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	bsh := er.ActiveBootSectorHeader()
+
+	vi = VolumeInfo{
+		SerialNumber:       bsh.VolumeSerialNumber,
+		FileSystemRevision: fmt.Sprintf("%d.%02d", bsh.FileSystemRevision[1], bsh.FileSystemRevision[0]),
+		Size:               bsh.VolumeLength * uint64(bsh.SectorSize()),
+		ClusterSize:        bsh.ClusterSize(),
+		ClusterCount:       bsh.ClusterCount,
+		PercentInUse:       bsh.PercentInUse,
+		IsTexFat:           bsh.NumberOfFats == 2,
+		SelectedBootRegion: er.SelectedBootRegion().String(),
+	}
+
+	if bsh.VolumeFlags.UseSecondFat() == true {
+		vi.ActiveFat = 1
+	}
+
+	rm, err := er.RootMetadata()
+	log.PanicIf(err)
+
+	if rm.VolumeLabel != nil {
+		vi.Label = rm.VolumeLabel.Label()
+	}
+
+	if rm.VolumeGuid != nil {
+		vi.Guid = FormatGuid(rm.VolumeGuid.VolumeGuid)
+	}
+
+	return vi, nil
+}
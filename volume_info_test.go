@@ -0,0 +1,46 @@
+package exfat
+
+import (
+	"testing"
+
+	"github.com/dsoprea/go-logging"
+)
+
+func TestExfatReader_VolumeInfo(t *testing.T) {
+	f, er := getTestFileAndParser()
+
+	defer f.Close()
+
+	err := er.Parse()
+	log.PanicIf(err)
+
+	vi, err := er.VolumeInfo()
+	log.PanicIf(err)
+
+	bsh := er.ActiveBootSectorHeader()
+
+	if vi.Label != "testvolumelabel" {
+		t.Fatalf("Label not correct: [%s]", vi.Label)
+	} else if vi.Guid != "" {
+		t.Fatalf("Expected no GUID: [%s]", vi.Guid)
+	} else if vi.SerialNumber != bsh.VolumeSerialNumber {
+		t.Fatalf("Serial number not correct: (0x%08x)", vi.SerialNumber)
+	} else if vi.ClusterSize != 4096 || vi.ClusterCount != 239 {
+		t.Fatalf("Geometry not correct: %s", vi)
+	} else if vi.Size != bsh.VolumeLength*512 {
+		t.Fatalf("Size not correct: (%d)", vi.Size)
+	} else if vi.IsTexFat != false || vi.ActiveFat != 0 {
+		t.Fatalf("Expected a volume with one FAT: %s", vi)
+	} else if vi.SelectedBootRegion != "main" {
+		t.Fatalf("Selected boot region not correct: [%s]", vi.SelectedBootRegion)
+	}
+}
+
+func TestFormatGuid(t *testing.T) {
+	raw := [16]byte{0x33, 0x22, 0x11, 0x00, 0x55, 0x44, 0x77, 0x66, 0x88, 0x99, 0xaa, 0xbb, 0xcc, 0xdd, 0xee, 0xff}
+
+	guid := FormatGuid(raw)
+	if guid != "00112233-4455-6677-8899-aabbccddeeff" {
+		t.Fatalf("GUID not correct: [%s]", guid)
+	}
+}
@@ -0,0 +1,181 @@
+// This package supports changing the volume label in place.
+
+package exfat
+
+import (
+	"io"
+	"os"
+	"strings"
+
+	"unicode/utf16"
+
+	"github.com/dsoprea/go-logging"
+)
+
+const (
+	// MaxVolumeLabelLength is the most UTF-16 code units that a volume label
+	// may have (Section 7.3.2). Some tools write longer labels into the
+	// reserved bytes that follow (see ExfatVolumeLabelDirectoryEntry), and
+	// those are still read, but we don't write them.
+	MaxVolumeLabelLength = 11
+
+	// volumeLabelEntryType is the entry-type of an in-use volume label.
+	volumeLabelEntryType = 0x83
+
+	// invalidLabelCharacters are the characters, other than control
+	// characters, that a volume label may not contain (Section 7.7.3).
+	invalidLabelCharacters = "\"*/:<>?\\|"
+)
+
+// encodeVolumeLabelEntry returns the raw directory-entry for the given label.
+func encodeVolumeLabelEntry(label string) (raw []byte, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	for _, r := range label {
+		if r < 0x20 || strings.ContainsRune(invalidLabelCharacters, r) == true {
+			log.Panicf("volume label has an invalid character: [%s] (0x%x)", label, r)
+		}
+	}
+
+	units := utf16.Encode([]rune(label))
+	if len(units) > MaxVolumeLabelLength {
+		log.Panicf("volume label is too long: [%s] (%d) > (%d)", label, len(units), MaxVolumeLabelLength)
+	}
+
+	vlde := ExfatVolumeLabelDirectoryEntry{
+		EntryType:      volumeLabelEntryType,
+		CharacterCount: uint8(len(units)),
+	}
+
+	for i, unit := range units {
+		defaultEncoding.PutUint16(vlde.VolumeLabel[i*2:], unit)
+	}
+
+	raw, err = vlde.MarshalBinary()
+	log.PanicIf(err)
+
+	return raw, nil
+}
+
+// findVolumeLabelSlot returns the offset of the directory-entry that the label
+// should be written to: the current label if there is one, otherwise a label
+// entry that is no longer in use, otherwise the end-of-directory entry (as
+// long as it's in the same cluster as the last entry). `isCurrent` is true if
+// it's the current label.
+func (er *ExfatReader) findVolumeLabelSlot() (offset int64, isCurrent bool, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	var currentOffset, unusedOffset int64
+	var lastLocation EntryLocation
+
+	cb := func(es *EntrySet) (err error) {
+		if _, ok := es.PrimaryEntry.(*ExfatVolumeLabelDirectoryEntry); ok == true {
+			if es.IsInUse() == true {
+				if currentOffset == 0 {
+					currentOffset = es.Location().Offset
+				}
+			} else if unusedOffset == 0 {
+				unusedOffset = es.Location().Offset
+			}
+		}
+
+		lastLocation = es.Locations[len(es.Locations)-1]
+
+		return nil
+	}
+
+	en := NewExfatNavigator(er, er.FirstClusterOfRootDirectory())
+
+	_, _, err = en.EnumerateEntrySets(cb)
+	log.PanicIf(err)
+
+	if currentOffset != 0 {
+		return currentOffset, true, nil
+	} else if unusedOffset != 0 {
+		return unusedOffset, false, nil
+	}
+
+	// Growing the root directory into another cluster would mean allocating
+	// one, so only the space that is already allocated is used.
+
+	entriesPerCluster := int(er.SectorsPerCluster() * er.SectorSize() / directoryEntryBytesCount)
+	if lastLocation.EntryNumber%entriesPerCluster == entriesPerCluster-1 {
+		log.Panicf("no room in the root directory for a volume label")
+	}
+
+	offset = lastLocation.Offset + directoryEntryBytesCount
+
+	entryType := make([]byte, 1)
+
+	err = er.readAt(entryType, offset)
+	log.PanicIf(err)
+
+	if EntryType(entryType[0]).IsEndOfDirectory() == false {
+		log.Panicf("entry following the root directory is not the end-of-directory entry: (0x%02x)", entryType[0])
+	}
+
+	return offset, false, nil
+}
+
+// SetVolumeLabel updates the volume label of the image in place, creating the
+// label entry in the root directory if there isn't one. An empty label clears
+// it by marking the entry as no longer in use, and nothing is written if there
+// isn't one. The label may have at most MaxVolumeLabelLength UTF-16 code units and
+// not contain any of the characters that filenames may not.
+func SetVolumeLabel(rws io.ReadWriteSeeker, label string) (err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	raw, err := encodeVolumeLabelEntry(label)
+	log.PanicIf(err)
+
+	_, err = rws.Seek(0, os.SEEK_SET)
+	log.PanicIf(err)
+
+	er := NewExfatReader(rws)
+
+	err = er.Parse()
+	log.PanicIf(err)
+
+	offset, isCurrent, err := er.findVolumeLabelSlot()
+	log.PanicIf(err)
+
+	if label == "" {
+		if isCurrent == false {
+			return nil
+		}
+
+		raw[0] &^= 0x80
+	}
+
+	// Mark the volume as dirty while it's being changed unless it already was
+	// (in which case, it's not ours to clear).
+
+	wasDirty := er.ActiveBootSectorHeader().VolumeFlags.IsDirty()
+
+	if wasDirty == false {
+		err = er.SetDirty(true)
+		log.PanicIf(err)
+	}
+
+	err = er.writeAt(raw, offset)
+	log.PanicIf(err)
+
+	if wasDirty == false {
+		err = er.SetDirty(false)
+		log.PanicIf(err)
+	}
+
+	return nil
+}
@@ -0,0 +1,178 @@
+package exfat
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/dsoprea/go-logging"
+)
+
+// getTestWritableImage returns a temporary copy of the test image.
+func getTestWritableImage() (f *os.File, closer func()) {
+	image, _ := getTestImage()
+
+	f, err := ioutil.TempFile("", "exfat-label-")
+	log.PanicIf(err)
+
+	closer = func() {
+		f.Close()
+		os.Remove(f.Name())
+	}
+
+	_, err = f.Write(image)
+	log.PanicIf(err)
+
+	_, err = f.Seek(0, os.SEEK_SET)
+	log.PanicIf(err)
+
+	return f, closer
+}
+
+// getTestVolumeLabel reads the label back from the image.
+func getTestVolumeLabel(f *os.File) string {
+	_, err := f.Seek(0, os.SEEK_SET)
+	log.PanicIf(err)
+
+	er := NewExfatReader(f)
+
+	err = er.Parse()
+	log.PanicIf(err)
+
+	vi, err := er.VolumeInfo()
+	log.PanicIf(err)
+
+	return vi.Label
+}
+
+func TestSetVolumeLabel__Update(t *testing.T) {
+	f, closer := getTestWritableImage()
+
+	defer closer()
+
+	err := SetVolumeLabel(f, "new label ü")
+	log.PanicIf(err)
+
+	label := getTestVolumeLabel(f)
+	if label != "new label ü" {
+		t.Fatalf("Label not correct: [%s]", label)
+	}
+
+	err = SetVolumeLabel(f, "")
+	log.PanicIf(err)
+
+	label = getTestVolumeLabel(f)
+	if label != "" {
+		t.Fatalf("Label not cleared: [%s]", label)
+	}
+
+	// Nothing else should have been disturbed.
+
+	image, err := ioutil.ReadFile(f.Name())
+	log.PanicIf(err)
+
+	report := getTestComplianceReport(image)
+
+	if len(report.Findings) != 1 {
+		t.Fatalf("Unexpected findings: %v", report.Findings)
+	}
+}
+
+func TestSetVolumeLabel__Clear(t *testing.T) {
+	f, closer := getTestWritableImage()
+
+	defer closer()
+
+	er := NewExfatReader(f)
+
+	err := er.Parse()
+	log.PanicIf(err)
+
+	offset, _, err := er.findVolumeLabelSlot()
+	log.PanicIf(err)
+
+	err = SetVolumeLabel(f, "")
+	log.PanicIf(err)
+
+	entryType := make([]byte, 1)
+
+	_, err = f.ReadAt(entryType, offset)
+	log.PanicIf(err)
+
+	if entryType[0] != volumeLabelEntryType&^0x80 {
+		t.Fatalf("Label entry not marked as unused: (0x%02x)", entryType[0])
+	}
+
+	// Clearing it again doesn't write anything, rather than creating an empty
+	// label.
+
+	original, err := ioutil.ReadFile(f.Name())
+	log.PanicIf(err)
+
+	err = SetVolumeLabel(f, "")
+	log.PanicIf(err)
+
+	image, err := ioutil.ReadFile(f.Name())
+	log.PanicIf(err)
+
+	if bytes.Equal(image, original) != true {
+		t.Fatalf("Image should not have changed.")
+	}
+}
+
+func TestSetVolumeLabel__Create(t *testing.T) {
+	f, closer := getTestWritableImage()
+
+	defer closer()
+
+	_, err := f.Seek(0, os.SEEK_SET)
+	log.PanicIf(err)
+
+	er := NewExfatReader(f)
+
+	err = er.Parse()
+	log.PanicIf(err)
+
+	offset, _, err := er.findVolumeLabelSlot()
+	log.PanicIf(err)
+
+	// Mark the existing label as no longer in use.
+
+	_, err = f.WriteAt([]byte{volumeLabelEntryType &^ 0x80}, offset)
+	log.PanicIf(err)
+
+	label := getTestVolumeLabel(f)
+	if label != "" {
+		t.Fatalf("Label not removed: [%s]", label)
+	}
+
+	err = SetVolumeLabel(f, "recreated")
+	log.PanicIf(err)
+
+	label = getTestVolumeLabel(f)
+	if label != "recreated" {
+		t.Fatalf("Label not correct: [%s]", label)
+	}
+}
+
+func TestSetVolumeLabel__Invalid(t *testing.T) {
+	f, closer := getTestWritableImage()
+
+	defer closer()
+
+	err := SetVolumeLabel(f, "twelve chars")
+	if err == nil {
+		t.Fatalf("Expected error for long label.")
+	}
+
+	err = SetVolumeLabel(f, "a:b")
+	if err == nil {
+		t.Fatalf("Expected error for invalid character.")
+	}
+
+	label := getTestVolumeLabel(f)
+	if label != "testvolumelabel" {
+		t.Fatalf("Label should not have changed: [%s]", label)
+	}
+}
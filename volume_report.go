@@ -0,0 +1,240 @@
+// This package collects everything that is known about a volume into one
+// report.
+
+package exfat
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/dsoprea/go-logging"
+	"github.com/dustin/go-humanize"
+)
+
+// volumeReportEntryTypes are the root-directory entries that describe the
+// volume rather than a file, in the order that they're reported.
+var volumeReportEntryTypes = []string{
+	"VolumeLabel",
+	"VolumeGuid",
+	"AllocationBitmap",
+	"UpcaseTable",
+	"TexFAT",
+}
+
+// FatReport describes one FAT.
+type FatReport struct {
+	Offset     int64  `json:"offset" yaml:"offset"`
+	EntryCount uint32 `json:"entry_count" yaml:"entry_count"`
+	IsActive   bool   `json:"is_active" yaml:"is_active"`
+}
+
+// String returns a descriptive string.
+func (fr FatReport) String() string {
+	return fmt.Sprintf("FatReport<OFFSET=(%d) ENTRY-COUNT=(%d) IS-ACTIVE=[%v]>", fr.Offset, fr.EntryCount, fr.IsActive)
+}
+
+// VolumeHealth is the set of flags that indicate whether a volume needs
+// attention.
+type VolumeHealth struct {
+	IsDirty             bool `json:"is_dirty" yaml:"is_dirty"`
+	HasHadMediaFailures bool `json:"has_had_media_failures" yaml:"has_had_media_failures"`
+
+	// BootRegionsMatch indicates whether the main and backup boot-sectors are
+	// the same. The volume-flags and percent-in-use are not compared since the
+	// spec considers them stale in the backup.
+	BootRegionsMatch bool `json:"boot_regions_match" yaml:"boot_regions_match"`
+
+	// FatMismatchCount is the number of clusters whose entries differ between
+	// the two FATs. It is always zero if there is only one FAT.
+	FatMismatchCount int `json:"fat_mismatch_count" yaml:"fat_mismatch_count"`
+}
+
+// VolumeReport is everything that can be said about a volume without reading
+// any of its files.
+type VolumeReport struct {
+	Volume VolumeInfo `json:"volume" yaml:"volume"`
+
+	MainBootSectorHeader   BootSectorHeader `json:"main_boot_sector_header" yaml:"main_boot_sector_header"`
+	BackupBootSectorHeader BootSectorHeader `json:"backup_boot_sector_header" yaml:"backup_boot_sector_header"`
+
+	Fats []FatReport `json:"fats" yaml:"fats"`
+
+	// RootMetadataEntries are the entries in the root directory that describe
+	// the volume (label, GUID, allocation bitmaps, up-case table, and TexFAT
+	// padding), whether or not they are in use.
+	RootMetadataEntries []DirectoryEntry `json:"root_metadata_entries" yaml:"root_metadata_entries"`
+
+	FreeClusterCount          uint32 `json:"free_cluster_count" yaml:"free_cluster_count"`
+	FreeBytes                 uint64 `json:"free_bytes" yaml:"free_bytes"`
+	LargestContiguousFileSize uint64 `json:"largest_contiguous_file_size" yaml:"largest_contiguous_file_size"`
+
+	Health VolumeHealth `json:"health" yaml:"health"`
+}
+
+// String returns a descriptive string.
+func (vr *VolumeReport) String() string {
+	return fmt.Sprintf("VolumeReport<LABEL=[%s] FATS=(%d) FREE-CLUSTERS=(%d) IS-DIRTY=[%v]>", vr.Volume.Label, len(vr.Fats), vr.FreeClusterCount, vr.Health.IsDirty)
+}
+
+// VolumeReport reads the boot regions, the FATs, the root directory, and the
+// allocation bitmap and summarizes them.
+func (er *ExfatReader) VolumeReport() (vr *VolumeReport, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	vi, err := er.VolumeInfo()
+	log.PanicIf(err)
+
+	vr = &VolumeReport{
+		Volume:                 vi,
+		MainBootSectorHeader:   er.MainBootSectorHeader(),
+		BackupBootSectorHeader: er.BackupBootSectorHeader(),
+		Fats:                   make([]FatReport, 0),
+		RootMetadataEntries:    make([]DirectoryEntry, 0),
+	}
+
+	// FATs
+
+	fats, err := er.Fats()
+	log.PanicIf(err)
+
+	activeFat, err := er.ActiveFat()
+	log.PanicIf(err)
+
+	for _, fat := range fats {
+		fr := FatReport{
+			Offset:     fat.Offset(),
+			EntryCount: fat.EntryCount(),
+			IsActive:   fat == activeFat,
+		}
+
+		vr.Fats = append(vr.Fats, fr)
+	}
+
+	// Root directory
+
+	en := NewExfatNavigator(er, er.FirstClusterOfRootDirectory())
+
+	index, _, _, err := en.IndexDirectoryEntries()
+	log.PanicIf(err)
+
+	for _, typeName := range volumeReportEntryTypes {
+		for _, ide := range index[typeName] {
+			vr.RootMetadataEntries = append(vr.RootMetadataEntries, ide.PrimaryEntry)
+		}
+	}
+
+	// Free space
+
+	ab, err := er.ReadAllocationBitmap()
+	log.PanicIf(err)
+
+	vr.FreeClusterCount = ab.FreeClusterCount()
+	vr.FreeBytes = uint64(vr.FreeClusterCount) * uint64(er.ActiveBootSectorHeader().ClusterSize())
+
+	vr.LargestContiguousFileSize, err = ab.LargestContiguousFileSize()
+	log.PanicIf(err)
+
+	// Health
+
+	volumeFlags := er.ActiveBootSectorHeader().VolumeFlags
+
+	vr.Health.IsDirty = volumeFlags.IsDirty()
+	vr.Health.HasHadMediaFailures = volumeFlags.HasHadMediaFailures()
+
+	mainBsh := vr.MainBootSectorHeader
+	mainBsh.VolumeFlags = 0
+	mainBsh.PercentInUse = 0
+
+	backupBsh := vr.BackupBootSectorHeader
+	backupBsh.VolumeFlags = 0
+	backupBsh.PercentInUse = 0
+
+	vr.Health.BootRegionsMatch = mainBsh == backupBsh
+
+	if len(fats) > 1 {
+		mismatches, err := er.CompareFats()
+		log.PanicIf(err)
+
+		vr.Health.FatMismatchCount = len(mismatches)
+	}
+
+	return vr, nil
+}
+
+// Dump prints the report.
+func (vr *VolumeReport) Dump() {
+	vr.DumpTo(os.Stdout)
+}
+
+// DumpTo is the same as Dump() but writes to the given writer.
+func (vr *VolumeReport) DumpTo(w io.Writer) {
+	vi := vr.Volume
+
+	fmt.Fprintf(w, "Volume\n")
+	fmt.Fprintf(w, "======\n")
+	fmt.Fprintf(w, "\n")
+
+	fmt.Fprintf(w, "Label: [%s]\n", vi.Label)
+	fmt.Fprintf(w, "GUID: [%s]\n", vi.Guid)
+	fmt.Fprintf(w, "Serial Number: (0x%08x)\n", vi.SerialNumber)
+	fmt.Fprintf(w, "File-System Revision: [%s]\n", vi.FileSystemRevision)
+	fmt.Fprintf(w, "Size: %s (%s)\n", humanize.Comma(int64(vi.Size)), humanize.IBytes(vi.Size))
+	fmt.Fprintf(w, "Cluster Size: (%d)\n", vi.ClusterSize)
+	fmt.Fprintf(w, "Cluster Count: (%d)\n", vi.ClusterCount)
+	fmt.Fprintf(w, "Is TexFAT: [%v]\n", vi.IsTexFat)
+	fmt.Fprintf(w, "\n")
+
+	fmt.Fprintf(w, "Free Space\n")
+	fmt.Fprintf(w, "==========\n")
+	fmt.Fprintf(w, "\n")
+
+	fmt.Fprintf(w, "Free Clusters: %s of %s (%s)\n", humanize.Comma(int64(vr.FreeClusterCount)), humanize.Comma(int64(vi.ClusterCount)), humanize.IBytes(vr.FreeBytes))
+	fmt.Fprintf(w, "Largest Contiguous File: %s\n", humanize.IBytes(vr.LargestContiguousFileSize))
+	fmt.Fprintf(w, "Percent In Use (Boot-Sector): (%d)\n", vi.PercentInUse)
+	fmt.Fprintf(w, "\n")
+
+	fmt.Fprintf(w, "Health\n")
+	fmt.Fprintf(w, "======\n")
+	fmt.Fprintf(w, "\n")
+
+	fmt.Fprintf(w, "Is Dirty: [%v]\n", vr.Health.IsDirty)
+	fmt.Fprintf(w, "Has Had Media Failures: [%v]\n", vr.Health.HasHadMediaFailures)
+	fmt.Fprintf(w, "Boot Regions Match: [%v]\n", vr.Health.BootRegionsMatch)
+	fmt.Fprintf(w, "Selected Boot Region: [%s]\n", vi.SelectedBootRegion)
+	fmt.Fprintf(w, "FAT Mismatches: (%d)\n", vr.Health.FatMismatchCount)
+	fmt.Fprintf(w, "\n")
+
+	fmt.Fprintf(w, "FATs\n")
+	fmt.Fprintf(w, "====\n")
+	fmt.Fprintf(w, "\n")
+
+	for i, fr := range vr.Fats {
+		fmt.Fprintf(w, "%d: %s\n", i, fr)
+	}
+
+	fmt.Fprintf(w, "\n")
+
+	fmt.Fprintf(w, "Root Metadata Entries\n")
+	fmt.Fprintf(w, "=====================\n")
+	fmt.Fprintf(w, "\n")
+
+	for _, de := range vr.RootMetadataEntries {
+		fmt.Fprintf(w, "%s\n", de)
+	}
+
+	fmt.Fprintf(w, "\n")
+
+	vr.MainBootSectorHeader.DumpTo(w)
+
+	if vr.Health.BootRegionsMatch == false {
+		fmt.Fprintf(w, "(Backup)\n")
+		fmt.Fprintf(w, "\n")
+
+		vr.BackupBootSectorHeader.DumpTo(w)
+	}
+}
@@ -0,0 +1,123 @@
+package exfat
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"encoding/json"
+
+	"github.com/dsoprea/go-logging"
+)
+
+func TestExfatReader_VolumeReport(t *testing.T) {
+	f, er := getTestFileAndParser()
+
+	defer f.Close()
+
+	err := er.Parse()
+	log.PanicIf(err)
+
+	vr, err := er.VolumeReport()
+	log.PanicIf(err)
+
+	if vr.Volume.Label != "testvolumelabel" {
+		t.Fatalf("Label not correct: [%s]", vr.Volume.Label)
+	} else if vr.MainBootSectorHeader != er.ActiveBootSectorHeader() {
+		t.Fatalf("Main boot-sector not correct.")
+	} else if vr.Health.BootRegionsMatch != true {
+		t.Fatalf("Expected the boot regions to match.")
+	} else if vr.Health.IsDirty != false || vr.Health.HasHadMediaFailures != false {
+		t.Fatalf("Expected a healthy volume: %v", vr.Health)
+	} else if vr.Health.FatMismatchCount != 0 {
+		t.Fatalf("Expected no FAT mismatches: (%d)", vr.Health.FatMismatchCount)
+	}
+
+	if len(vr.Fats) != 1 {
+		t.Fatalf("Expected one FAT: (%d)", len(vr.Fats))
+	} else if vr.Fats[0].Offset != 128*512 || vr.Fats[0].EntryCount != 239 || vr.Fats[0].IsActive != true {
+		t.Fatalf("FAT not correct: %s", vr.Fats[0])
+	}
+
+	typeNames := make([]string, len(vr.RootMetadataEntries))
+	for i, de := range vr.RootMetadataEntries {
+		typeNames[i] = de.TypeName()
+	}
+
+	if strings.Join(typeNames, ",") != "VolumeLabel,AllocationBitmap,UpcaseTable" {
+		t.Fatalf("Root metadata entries not correct: %v", typeNames)
+	}
+
+	ab, err := er.ReadAllocationBitmap()
+	log.PanicIf(err)
+
+	if vr.FreeClusterCount != ab.FreeClusterCount() {
+		t.Fatalf("Free-cluster count not correct: (%d)", vr.FreeClusterCount)
+	} else if vr.FreeBytes != uint64(vr.FreeClusterCount)*4096 {
+		t.Fatalf("Free bytes not correct: (%d)", vr.FreeBytes)
+	}
+}
+
+func TestExfatReader_VolumeReport__BootRegionMismatch(t *testing.T) {
+	f, closer := getTestWritableImage()
+	defer closer()
+
+	// Change the serial-number in the backup boot-sector.
+	_, err := f.WriteAt([]byte{0x11, 0x22, 0x33, 0x44}, 12*512+100)
+	log.PanicIf(err)
+
+	er := NewExfatReader(f)
+
+	err = er.Parse()
+	log.PanicIf(err)
+
+	if er.BackupBootSectorHeader().VolumeSerialNumber != 0x44332211 {
+		t.Fatalf("Backup serial-number not correct: (0x%08x)", er.BackupBootSectorHeader().VolumeSerialNumber)
+	} else if er.MainBootSectorHeader().VolumeSerialNumber == 0x44332211 {
+		t.Fatalf("Main boot-sector should not have changed.")
+	}
+
+	vr, err := er.VolumeReport()
+	log.PanicIf(err)
+
+	if vr.Health.BootRegionsMatch != false {
+		t.Fatalf("Expected the boot regions to not match.")
+	}
+
+	b := new(bytes.Buffer)
+	vr.DumpTo(b)
+
+	if strings.Contains(b.String(), "(Backup)") == false {
+		t.Fatalf("Expected the backup boot-sector to be dumped.")
+	}
+}
+
+func TestVolumeReport_MarshalJSON(t *testing.T) {
+	f, er := getTestFileAndParser()
+
+	defer f.Close()
+
+	err := er.Parse()
+	log.PanicIf(err)
+
+	vr, err := er.VolumeReport()
+	log.PanicIf(err)
+
+	encoded, err := json.Marshal(vr)
+	log.PanicIf(err)
+
+	decoded := make(map[string]interface{})
+
+	err = json.Unmarshal(encoded, &decoded)
+	log.PanicIf(err)
+
+	health := decoded["health"].(map[string]interface{})
+	if health["boot_regions_match"] != true {
+		t.Fatalf("Health not correct: %v", health)
+	}
+
+	entries := decoded["root_metadata_entries"].([]interface{})
+	if entries[0].(map[string]interface{})["type"] != "VolumeLabel" {
+		t.Fatalf("First root metadata entry not correct: %v", entries[0])
+	}
+}
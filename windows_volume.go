@@ -0,0 +1,49 @@
+// This package supports reading mounted volumes directly on Windows.
+
+package exfat
+
+import (
+	"errors"
+
+	"github.com/dsoprea/go-logging"
+)
+
+var (
+	// ErrWindowsVolumeNotSupported is returned by OpenWindowsVolume on
+	// platforms other than Windows.
+	ErrWindowsVolumeNotSupported = errors.New("volume handles are only supported on Windows")
+)
+
+// OpenWindowsVolume returns a new ExfatReader for a raw Windows volume or
+// physical drive (e.g. `\\.\E:`), so that a card can be read without first
+// dumping an image. Reads are aligned to the device's sector-size and
+// buffered (see AlignedReader). The volume is opened for reading only and is
+// shared with the OS, so the card should not be written to while it's read.
+// The reader must be parsed, and must be closed with Close() once it's no
+// longer needed. Administrator rights are usually needed. Returns
+// ErrWindowsVolumeNotSupported on other platforms.
+func OpenWindowsVolume(volumePath string) (er *ExfatReader, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	bd, closer, err := openWindowsVolumeDevice(volumePath)
+	if err == ErrWindowsVolumeNotSupported {
+		return nil, err
+	}
+
+	log.PanicIf(err)
+
+	ar, err := NewAlignedReader(bd)
+	if err != nil {
+		closer.Close()
+		log.Panic(err)
+	}
+
+	er = NewExfatReader(ar)
+	er.closer = closer
+
+	return er, nil
+}
@@ -0,0 +1,14 @@
+//go:build !windows
+// +build !windows
+
+// This package stands in for volume handles on platforms other than Windows.
+
+package exfat
+
+import (
+	"io"
+)
+
+func openWindowsVolumeDevice(volumePath string) (bd BlockDevice, closer io.Closer, err error) {
+	return nil, nil, ErrWindowsVolumeNotSupported
+}
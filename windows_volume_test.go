@@ -0,0 +1,17 @@
+package exfat
+
+import (
+	"runtime"
+	"testing"
+)
+
+func TestOpenWindowsVolume__NotSupported(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Volume handles are supported on this platform.")
+	}
+
+	_, err := OpenWindowsVolume(`\\.\E:`)
+	if err != ErrWindowsVolumeNotSupported {
+		t.Fatalf("Expected not-supported error: %v", err)
+	}
+}
@@ -0,0 +1,103 @@
+//go:build windows
+// +build windows
+
+// This package implements volume handles for Windows.
+
+package exfat
+
+import (
+	"io"
+	"os"
+	"syscall"
+
+	"github.com/dsoprea/go-logging"
+)
+
+const (
+	// ioctlDiskGetDriveGeometry returns a DISK_GEOMETRY.
+	ioctlDiskGetDriveGeometry = 0x00070000
+
+	// ioctlDiskGetLengthInfo returns a GET_LENGTH_INFORMATION.
+	ioctlDiskGetLengthInfo = 0x0007405c
+
+	// fileFlagNoBuffering bypasses the cache. Reads must then be aligned,
+	// which AlignedReader takes care of.
+	fileFlagNoBuffering = 0x20000000
+)
+
+// deviceIoControl issues the given control code and returns the output.
+func deviceIoControl(handle syscall.Handle, code uint32, outputSize int) (output []byte, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	output = make([]byte, outputSize)
+
+	var returned uint32
+
+	err = syscall.DeviceIoControl(handle, code, nil, 0, &output[0], uint32(len(output)), &returned, nil)
+	log.PanicIf(err)
+
+	return output[:returned], nil
+}
+
+func openWindowsVolumeDevice(volumePath string) (bd BlockDevice, closer io.Closer, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	pathPointer, err := syscall.UTF16PtrFromString(volumePath)
+	log.PanicIf(err)
+
+	handle, err := syscall.CreateFile(
+		pathPointer,
+		syscall.GENERIC_READ,
+		syscall.FILE_SHARE_READ|syscall.FILE_SHARE_WRITE,
+		nil,
+		syscall.OPEN_EXISTING,
+		fileFlagNoBuffering,
+		0)
+
+	log.PanicIf(err)
+
+	isDone := false
+
+	defer func() {
+		if isDone == false {
+			syscall.CloseHandle(handle)
+		}
+	}()
+
+	// DISK_GEOMETRY: Cylinders (8), MediaType (4), TracksPerCylinder (4),
+	// SectorsPerTrack (4), BytesPerSector (4).
+	geometry, err := deviceIoControl(handle, ioctlDiskGetDriveGeometry, 24)
+	log.PanicIf(err)
+
+	if len(geometry) < 24 {
+		log.Panicf("drive geometry too short: (%d)", len(geometry))
+	}
+
+	blockSize := int(defaultEncoding.Uint32(geometry[20:24]))
+
+	// GET_LENGTH_INFORMATION: Length (8).
+	lengthInfo, err := deviceIoControl(handle, ioctlDiskGetLengthInfo, 8)
+	log.PanicIf(err)
+
+	if len(lengthInfo) < 8 {
+		log.Panicf("length information too short: (%d)", len(lengthInfo))
+	}
+
+	size := int64(defaultEncoding.Uint64(lengthInfo))
+
+	// The file reads at explicit offsets, which is all that the device
+	// needs.
+	f := os.NewFile(uintptr(handle), volumePath)
+
+	isDone = true
+
+	return NewReaderAtBlockDevice(f, size, blockSize), f, nil
+}
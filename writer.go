@@ -0,0 +1,886 @@
+// This package formats new volumes and populates them.
+
+package exfat
+
+import (
+	"io"
+	"math/bits"
+	"strings"
+	"time"
+	"unicode"
+
+	"unicode/utf16"
+
+	"github.com/dsoprea/go-logging"
+)
+
+const (
+	defaultFormatSectorSize  = 512
+	defaultFormatClusterSize = 4096
+
+	// formatFatOffset is the first sector after the main and backup boot
+	// regions, which is as early as the FAT may start (Section 3.1.6).
+	formatFatOffset = 2 * bootRegionSectorCount
+
+	// maxFilenameLength is the most UTF-16 code units in a filename (Section
+	// 7.6.3).
+	maxFilenameLength = 255
+
+	// fileNameEntryUnitCount is the number of UTF-16 code units that each
+	// file-name entry holds (Section 7.7.3).
+	fileNameEntryUnitCount = 15
+
+	fileEntryType             = 0x85
+	streamExtensionEntryType  = 0xc0
+	fileNameEntryType         = 0xc1
+	allocationBitmapEntryType = 0x81
+	upcaseTableEntryType      = 0x82
+
+	// fileAttributeDirectory is the directory flag of FileAttributes.
+	fileAttributeDirectory FileAttributes = 16
+)
+
+// FormatOptions describes the volume that NewExfatWriter() creates. Anything
+// left as zero gets a default.
+type FormatOptions struct {
+	// SectorSize is 512 (the default) through 4096 bytes.
+	SectorSize uint32
+
+	// ClusterSize is a power of two from the sector-size through 32M. It
+	// defaults to 4K.
+	ClusterSize uint32
+
+	// Label is the volume label. The volume doesn't have one if empty.
+	Label string
+
+	// SerialNumber defaults to a value derived from the current time.
+	SerialNumber uint32
+}
+
+// FileMetadata is the attributes and timestamps of a new file or directory.
+// Zero timestamps are set to the current time, and the directory attribute is
+// set or cleared as appropriate.
+type FileMetadata struct {
+	Attributes FileAttributes
+
+	CreatedTime  time.Time
+	ModifiedTime time.Time
+	AccessedTime time.Time
+}
+
+// writerNode is a file or directory that has been added to the volume.
+type writerNode struct {
+	name        string
+	isDirectory bool
+	metadata    FileMetadata
+
+	// firstCluster, dataLength, and validDataLength describe the data.
+	// Directories are only allocated once the writer is closed, since that's
+	// when their size is known.
+	firstCluster    uint32
+	dataLength      uint64
+	validDataLength uint64
+
+	children   []*writerNode
+	childIndex map[string]*writerNode
+}
+
+func newWriterNode(name string, isDirectory bool, metadata FileMetadata) *writerNode {
+	return &writerNode{
+		name:        name,
+		isDirectory: isDirectory,
+		metadata:    metadata,
+		childIndex:  make(map[string]*writerNode),
+	}
+}
+
+// ExfatWriter creates a new volume. The layout (boot regions, FAT, allocation
+// bitmap, and up-case table) is decided when it's created, file data is
+// written as files are added, and the directories, FAT, allocation bitmap, and
+// boot regions are written by Close(). Until then, the image isn't a valid
+// volume. Clusters are allocated sequentially, so every file and directory is
+// contiguous unless it was extended (see OpenFile()) after something else was
+// allocated. It is not safe for concurrent use.
+type ExfatWriter struct {
+	w io.WriterAt
+
+	bsh         BootSectorHeader
+	sectorSize  uint32
+	clusterSize uint32
+
+	// fat has an entry for every cluster, including the two leading entries.
+	fat    []uint32
+	bitmap []byte
+
+	nextCluster uint32
+
+	ut         *UpcaseTable
+	upcaseData []byte
+
+	label string
+
+	bitmapCluster uint32
+	upcaseCluster uint32
+
+	root *writerNode
+
+	isClosed bool
+}
+
+// NewExfatWriter formats a new volume of the given size into the given image.
+func NewExfatWriter(w io.WriterAt, size int64, options FormatOptions) (ew *ExfatWriter, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	sectorSize := options.SectorSize
+	if sectorSize == 0 {
+		sectorSize = defaultFormatSectorSize
+	}
+
+	clusterSize := options.ClusterSize
+	if clusterSize == 0 {
+		clusterSize = defaultFormatClusterSize
+	}
+
+	if sectorSize < 512 || sectorSize > 4096 || sectorSize&(sectorSize-1) != 0 {
+		log.Panicf("sector-size not valid: (%d)", sectorSize)
+	} else if clusterSize < sectorSize || clusterSize > 32*1024*1024 || clusterSize&(clusterSize-1) != 0 {
+		log.Panicf("cluster-size not valid: (%d)", clusterSize)
+	} else if size < 1024*1024 {
+		log.Panicf("volume must be at least 1M: (%d)", size)
+	}
+
+	if options.Label != "" {
+		_, err := encodeVolumeLabelEntry(options.Label)
+		log.PanicIf(err)
+	}
+
+	serialNumber := options.SerialNumber
+	if serialNumber == 0 {
+		serialNumber = uint32(time.Now().UnixNano())
+	}
+
+	// Size the FAT for every cluster that could fit if there were no FAT,
+	// and then fit the cluster heap into what's left.
+
+	volumeLength := uint64(size) / uint64(sectorSize)
+	sectorsPerCluster := uint64(clusterSize / sectorSize)
+
+	maximumClusters := (volumeLength - formatFatOffset) / sectorsPerCluster
+	fatLength := ((maximumClusters+2)*4 + uint64(sectorSize) - 1) / uint64(sectorSize)
+
+	clusterHeapOffset := (formatFatOffset + fatLength + sectorsPerCluster - 1) / sectorsPerCluster * sectorsPerCluster
+	if clusterHeapOffset+sectorsPerCluster > volumeLength {
+		log.Panicf("volume too small for the cluster-size: (%d)", size)
+	}
+
+	clusterCount := (volumeLength - clusterHeapOffset) / sectorsPerCluster
+	if clusterCount > maximumClusterCount {
+		clusterCount = maximumClusterCount
+	}
+
+	bsh := BootSectorHeader{
+		VolumeLength:           volumeLength,
+		FatOffset:              formatFatOffset,
+		FatLength:              uint32(fatLength),
+		ClusterHeapOffset:      uint32(clusterHeapOffset),
+		ClusterCount:           uint32(clusterCount),
+		VolumeSerialNumber:     serialNumber,
+		FileSystemRevision:     [2]uint8{0, 1},
+		BytesPerSectorShift:    uint8(bits.TrailingZeros32(sectorSize)),
+		SectorsPerClusterShift: uint8(bits.TrailingZeros64(sectorsPerCluster)),
+		NumberOfFats:           1,
+		DriveSelect:            0x80,
+		BootSignature:          requiredBootSignature,
+	}
+
+	copy(bsh.JumpBoot[:], requiredJumpBootSignature)
+	copy(bsh.FileSystemName[:], requiredFileSystemName)
+
+	for i := range bsh.BootCode {
+		bsh.BootCode[i] = 0xf4
+	}
+
+	upcaseData := defaultUpcaseTableData()
+
+	ut, err := newUpcaseTable(upcaseData)
+	log.PanicIf(err)
+
+	ew = &ExfatWriter{
+		w:           w,
+		bsh:         bsh,
+		sectorSize:  sectorSize,
+		clusterSize: clusterSize,
+		fat:         make([]uint32, clusterCount+2),
+		bitmap:      make([]byte, (clusterCount+7)/8),
+		nextCluster: 2,
+		ut:          ut,
+		upcaseData:  upcaseData,
+		label:       options.Label,
+		root:        newWriterNode("", true, FileMetadata{}),
+	}
+
+	// FatEntry[0] has the media-type and FatEntry[1] is always FFFFFFFFh
+	// (Sections 4.1.1 and 4.1.2).
+	ew.fat[0] = 0xfffffff8
+	ew.fat[1] = 0xffffffff
+
+	// Make sure that the image is as large as the volume.
+	err = ew.writeAt([]byte{0}, int64(volumeLength)*int64(sectorSize)-1)
+	log.PanicIf(err)
+
+	ew.bitmapCluster, err = ew.allocate(uint64(len(ew.bitmap)))
+	log.PanicIf(err)
+
+	ew.upcaseCluster, err = ew.allocate(uint64(len(upcaseData)))
+	log.PanicIf(err)
+
+	err = ew.writeAt(upcaseData, ew.clusterOffset(ew.upcaseCluster))
+	log.PanicIf(err)
+
+	return ew, nil
+}
+
+// defaultUpcaseTableData returns a compressed up-case table (Section 7.2.5.1)
+// built from Go's simple upper-case mappings. Surrogates, and anything whose
+// upper-case form is outside of the BMP, map to themselves.
+func defaultUpcaseTableData() []byte {
+	units := make([]uint16, 0)
+	identityCount := 0
+
+	flushIdentities := func() {
+		if identityCount > 0 {
+			units = append(units, 0xffff, uint16(identityCount))
+			identityCount = 0
+		}
+	}
+
+	for i := 0; i < 0x10000; i++ {
+		upper := rune(i)
+		if i < 0xd800 || i > 0xdfff {
+			upper = unicode.ToUpper(rune(i))
+		}
+
+		if upper == rune(i) || upper > 0xffff {
+			identityCount++
+
+			if identityCount == 0xffff {
+				flushIdentities()
+			}
+
+			continue
+		}
+
+		flushIdentities()
+		units = append(units, uint16(upper))
+	}
+
+	flushIdentities()
+
+	data := make([]byte, len(units)*2)
+	for i, unit := range units {
+		defaultEncoding.PutUint16(data[i*2:], unit)
+	}
+
+	return data
+}
+
+// writeAt writes to the image.
+func (ew *ExfatWriter) writeAt(data []byte, offset int64) (err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	_, err = ew.w.WriteAt(data, offset)
+	log.PanicIf(err)
+
+	return nil
+}
+
+// clusterOffset returns the absolute offset of the given cluster.
+func (ew *ExfatWriter) clusterOffset(clusterNumber uint32) int64 {
+	return (int64(ew.bsh.ClusterHeapOffset) + int64(clusterNumber-2)<<ew.bsh.SectorsPerClusterShift) * int64(ew.sectorSize)
+}
+
+// allocate allocates enough contiguous clusters for the given number of bytes,
+// marks them in the allocation bitmap, and chains them in the FAT. Zero is
+// returned as the first cluster if no bytes were requested.
+func (ew *ExfatWriter) allocate(byteCount uint64) (firstCluster uint32, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	if byteCount == 0 {
+		return 0, nil
+	}
+
+	clusterCount := (byteCount + uint64(ew.clusterSize) - 1) / uint64(ew.clusterSize)
+	lastCluster := uint64(ew.nextCluster) + clusterCount - 1
+
+	if lastCluster > uint64(ew.bsh.ClusterCount)+1 {
+		log.Panicf("volume is full: need (%d) clusters", clusterCount)
+	}
+
+	firstCluster = ew.nextCluster
+
+	for clusterNumber := firstCluster; uint64(clusterNumber) <= lastCluster; clusterNumber++ {
+		if uint64(clusterNumber) == lastCluster {
+			ew.fat[clusterNumber] = 0xffffffff
+		} else {
+			ew.fat[clusterNumber] = clusterNumber + 1
+		}
+
+		bitIndex := clusterNumber - 2
+		ew.bitmap[bitIndex/8] |= 1 << (bitIndex % 8)
+	}
+
+	ew.nextCluster = uint32(lastCluster + 1)
+
+	return firstCluster, nil
+}
+
+// chain returns the clusters of the chain that starts at the given cluster,
+// from the in-memory FAT.
+func (ew *ExfatWriter) chain(firstCluster uint32) (clusters []uint32) {
+	clusters = make([]uint32, 0)
+
+	for clusterNumber := firstCluster; clusterNumber >= 2 && clusterNumber <= ew.bsh.ClusterCount+1; clusterNumber = ew.fat[clusterNumber] {
+		clusters = append(clusters, clusterNumber)
+	}
+
+	return clusters
+}
+
+// isContiguous indicates whether the chain that starts at the given cluster
+// is a single run of clusters.
+func (ew *ExfatWriter) isContiguous(firstCluster uint32) bool {
+	clusters := ew.chain(firstCluster)
+	for i := 1; i < len(clusters); i++ {
+		if clusters[i] != clusters[i-1]+1 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// free releases the given clusters in the FAT and the allocation bitmap. They
+// aren't reused.
+func (ew *ExfatWriter) free(clusters []uint32) {
+	for _, clusterNumber := range clusters {
+		ew.fat[clusterNumber] = 0
+
+		bitIndex := clusterNumber - 2
+		ew.bitmap[bitIndex/8] &^= 1 << (bitIndex % 8)
+	}
+}
+
+// lookupDirectory returns the directory at the given path.
+func (ew *ExfatWriter) lookupDirectory(pathParts []string) (node *writerNode, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	node = ew.root
+	for i, part := range pathParts {
+		child, found := node.childIndex[ew.ut.ToUpper(part)]
+		if found == false {
+			log.Panicf("directory not found: [%s]", JoinVolumePath(pathParts[:i+1]))
+		} else if child.isDirectory == false {
+			log.Panicf("not a directory: [%s]", JoinVolumePath(pathParts[:i+1]))
+		}
+
+		node = child
+	}
+
+	return node, nil
+}
+
+// addNode adds a new file or directory at the given path, whose parent must
+// already exist.
+func (ew *ExfatWriter) addNode(pathParts []string, isDirectory bool, metadata FileMetadata) (node *writerNode, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	if ew.isClosed == true {
+		log.Panicf("writer is closed")
+	} else if len(pathParts) == 0 {
+		log.Panicf("path is empty")
+	}
+
+	name := pathParts[len(pathParts)-1]
+
+	if name == "" || name == "." || name == ".." {
+		log.Panicf("filename not valid: [%s]", name)
+	} else if len(utf16.Encode([]rune(name))) > maxFilenameLength {
+		log.Panicf("filename is too long: [%s]", name)
+	}
+
+	for _, r := range name {
+		if r < 0x20 || strings.ContainsRune(invalidFilenameCharacters, r) == true {
+			log.Panicf("filename has an invalid character: [%s] (0x%x)", name, r)
+		}
+	}
+
+	parent, err := ew.lookupDirectory(pathParts[:len(pathParts)-1])
+	log.PanicIf(err)
+
+	key := ew.ut.ToUpper(name)
+	if _, found := parent.childIndex[key]; found == true {
+		log.Panicf("file already exists: [%s]", JoinVolumePath(pathParts))
+	}
+
+	now := time.Now()
+
+	if metadata.CreatedTime.IsZero() == true {
+		metadata.CreatedTime = now
+	}
+
+	if metadata.ModifiedTime.IsZero() == true {
+		metadata.ModifiedTime = now
+	}
+
+	if metadata.AccessedTime.IsZero() == true {
+		metadata.AccessedTime = now
+	}
+
+	if isDirectory == true {
+		metadata.Attributes |= fileAttributeDirectory
+	} else {
+		metadata.Attributes &^= fileAttributeDirectory
+	}
+
+	node = newWriterNode(name, isDirectory, metadata)
+
+	parent.children = append(parent.children, node)
+	parent.childIndex[key] = node
+
+	return node, nil
+}
+
+// Mkdir creates a directory. Its parent must already exist.
+func (ew *ExfatWriter) Mkdir(pathParts []string, metadata FileMetadata) (err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	_, err = ew.addNode(pathParts, true, metadata)
+	log.PanicIf(err)
+
+	return nil
+}
+
+// MkdirAll creates a directory along with any of its parents that don't
+// exist yet. `metadata` applies to every directory that's created. It's not
+// an error if the directory already exists, but it is if anything along the
+// path is a file.
+func (ew *ExfatWriter) MkdirAll(pathParts []string, metadata FileMetadata) (err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	if ew.isClosed == true {
+		log.Panicf("writer is closed")
+	}
+
+	node := ew.root
+	for i, part := range pathParts {
+		child, found := node.childIndex[ew.ut.ToUpper(part)]
+		if found == false {
+			child, err = ew.addNode(pathParts[:i+1], true, metadata)
+			log.PanicIf(err)
+		} else if child.isDirectory == false {
+			log.Panicf("not a directory: [%s]", JoinVolumePath(pathParts[:i+1]))
+		}
+
+		node = child
+	}
+
+	return nil
+}
+
+// exfatWriterFile writes the data of a new file sequentially.
+type exfatWriterFile struct {
+	ew      *ExfatWriter
+	offset  int64
+	size    uint64
+	written uint64
+}
+
+// Write writes the next part of the file.
+func (ewf *exfatWriterFile) Write(data []byte) (n int, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	if ewf.written+uint64(len(data)) > ewf.size {
+		log.Panicf("write exceeds the file size: (%d) > (%d)", ewf.written+uint64(len(data)), ewf.size)
+	}
+
+	err = ewf.ew.writeAt(data, ewf.offset+int64(ewf.written))
+	log.PanicIf(err)
+
+	ewf.written += uint64(len(data))
+
+	return len(data), nil
+}
+
+// removeNode removes the node at the given path, which must be the last one
+// that was added to its directory. It undoes addNode() when what follows it
+// fails.
+func (ew *ExfatWriter) removeNode(pathParts []string) (err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	parent, err := ew.lookupDirectory(pathParts[:len(pathParts)-1])
+	log.PanicIf(err)
+
+	key := ew.ut.ToUpper(pathParts[len(pathParts)-1])
+
+	node, found := parent.childIndex[key]
+	if found == false || parent.children[len(parent.children)-1] != node {
+		log.Panicf("not the last node added: [%s]", JoinVolumePath(pathParts))
+	}
+
+	parent.children = parent.children[:len(parent.children)-1]
+	delete(parent.childIndex, key)
+
+	return nil
+}
+
+// createFile adds a file and allocates its clusters. Exactly `size` bytes must
+// be written to the returned writer. The file is added before anything is
+// allocated so that a file that can't be added (e.g. its name is taken)
+// doesn't use up any clusters.
+func (ew *ExfatWriter) createFile(pathParts []string, size uint64, metadata FileMetadata) (ewf *exfatWriterFile, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	node, err := ew.addNode(pathParts, false, metadata)
+	log.PanicIf(err)
+
+	firstCluster, err := ew.allocate(size)
+	if err != nil {
+		removeErr := ew.removeNode(pathParts)
+		log.PanicIf(removeErr)
+
+		log.Panic(err)
+	}
+
+	node.firstCluster = firstCluster
+	node.dataLength = size
+	node.validDataLength = size
+
+	ewf = &exfatWriterFile{
+		ew:   ew,
+		size: size,
+	}
+
+	if firstCluster != 0 {
+		ewf.offset = ew.clusterOffset(firstCluster)
+	}
+
+	return ewf, nil
+}
+
+// CreateFile creates a file with `size` bytes read from `r`. Its parent
+// directory must already exist.
+func (ew *ExfatWriter) CreateFile(pathParts []string, r io.Reader, size uint64, metadata FileMetadata) (err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	ewf, err := ew.createFile(pathParts, size, metadata)
+	log.PanicIf(err)
+
+	_, err = io.CopyN(ewf, r, int64(size))
+	log.PanicIf(err)
+
+	return nil
+}
+
+// entrySetEntryCount returns how many directory-entries the node's entry-set
+// takes.
+func (node *writerNode) entrySetEntryCount() int {
+	unitCount := len(utf16.Encode([]rune(node.name)))
+	return 2 + (unitCount+fileNameEntryUnitCount-1)/fileNameEntryUnitCount
+}
+
+// newEntrySet returns the entry-set (the File, Stream Extension, and File Name
+// entries) for the node.
+func (ew *ExfatWriter) newEntrySet(node *writerNode) (es *EntrySet, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	units := utf16.Encode([]rune(node.name))
+
+	fdf := &ExfatFileDirectoryEntry{
+		EntryType:         fileEntryType,
+		SecondaryCountRaw: uint8(node.entrySetEntryCount() - 1),
+		FileAttributes:    node.metadata.Attributes,
+	}
+
+	fdf.CreateTimestampRaw, fdf.Create10msIncrement, fdf.CreateUtcOffset = NewExfatTimestamp(node.metadata.CreatedTime)
+	fdf.LastModifiedTimestampRaw, fdf.LastModified10msIncrement, fdf.LastModifiedUtcOffset = NewExfatTimestamp(node.metadata.ModifiedTime)
+	fdf.LastAccessedTimestampRaw, _, fdf.LastAccessedUtcOffset = NewExfatTimestamp(node.metadata.AccessedTime)
+
+	sede := &ExfatStreamExtensionDirectoryEntry{
+		EntryType:             streamExtensionEntryType,
+		GeneralSecondaryFlags: 1,
+		NameLength:            uint8(len(units)),
+		NameHash:              ew.ut.NameHash(node.name),
+		ValidDataLength:       node.validDataLength,
+		FirstCluster:          node.firstCluster,
+		DataLength:            node.dataLength,
+	}
+
+	// The FAT is always written, but it only needs to be used for chains that
+	// were fragmented by being extended.
+	if node.firstCluster != 0 && ew.isContiguous(node.firstCluster) == true {
+		sede.GeneralSecondaryFlags |= 2
+	}
+
+	secondaryEntries := []DirectoryEntry{sede}
+
+	for i := 0; i < len(units); i += fileNameEntryUnitCount {
+		fnde := &ExfatFileNameDirectoryEntry{
+			EntryType: fileNameEntryType,
+		}
+
+		for j := 0; j < fileNameEntryUnitCount && i+j < len(units); j++ {
+			defaultEncoding.PutUint16(fnde.FileName[j*2:], units[i+j])
+		}
+
+		secondaryEntries = append(secondaryEntries, fnde)
+	}
+
+	es, err = NewEntrySet(fdf, secondaryEntries)
+	log.PanicIf(err)
+
+	return es, nil
+}
+
+// rootEntries returns the entries that only the root directory has.
+func (ew *ExfatWriter) rootEntries() (raw []byte, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	raw = make([]byte, 0)
+
+	if ew.label != "" {
+		labelRaw, err := encodeVolumeLabelEntry(ew.label)
+		log.PanicIf(err)
+
+		raw = append(raw, labelRaw...)
+	}
+
+	abde := ExfatAllocationBitmapDirectoryEntry{
+		EntryType:    allocationBitmapEntryType,
+		FirstCluster: ew.bitmapCluster,
+		DataLength:   uint64(len(ew.bitmap)),
+	}
+
+	abdeRaw, err := abde.MarshalBinary()
+	log.PanicIf(err)
+
+	raw = append(raw, abdeRaw...)
+
+	utde := ExfatUpcaseTableDirectoryEntry{
+		EntryType:     upcaseTableEntryType,
+		TableChecksum: upcaseTableChecksum(ew.upcaseData),
+		FirstCluster:  ew.upcaseCluster,
+		DataLength:    uint64(len(ew.upcaseData)),
+	}
+
+	utdeRaw, err := utde.MarshalBinary()
+	log.PanicIf(err)
+
+	raw = append(raw, utdeRaw...)
+
+	return raw, nil
+}
+
+// allocateDirectories allocates every directory under (and including) the
+// given one. There is always room left for the end-of-directory entry.
+func (ew *ExfatWriter) allocateDirectories(node *writerNode, extraEntryCount int) (err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	entryCount := extraEntryCount + 1
+	for _, child := range node.children {
+		entryCount += child.entrySetEntryCount()
+	}
+
+	clusterCount := (uint64(entryCount)*directoryEntryBytesCount + uint64(ew.clusterSize) - 1) / uint64(ew.clusterSize)
+	node.dataLength = clusterCount * uint64(ew.clusterSize)
+	node.validDataLength = node.dataLength
+
+	node.firstCluster, err = ew.allocate(node.dataLength)
+	log.PanicIf(err)
+
+	for _, child := range node.children {
+		if child.isDirectory == true {
+			err := ew.allocateDirectories(child, 0)
+			log.PanicIf(err)
+		}
+	}
+
+	return nil
+}
+
+// writeDirectories writes every directory under (and including) the given
+// one.
+func (ew *ExfatWriter) writeDirectories(node *writerNode, leadingEntries []byte) (err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	data := make([]byte, node.dataLength)
+	offset := copy(data, leadingEntries)
+
+	for _, child := range node.children {
+		es, err := ew.newEntrySet(child)
+		log.PanicIf(err)
+
+		offset += copy(data[offset:], es.Raw)
+
+		if child.isDirectory == true {
+			err := ew.writeDirectories(child, nil)
+			log.PanicIf(err)
+		}
+	}
+
+	err = ew.writeAt(data, ew.clusterOffset(node.firstCluster))
+	log.PanicIf(err)
+
+	return nil
+}
+
+// writeBootRegions writes the main and backup boot regions.
+func (ew *ExfatWriter) writeBootRegions() (err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	sectorSize := int(ew.sectorSize)
+	region := make([]byte, bootRegionSectorCount*sectorSize)
+
+	raw, err := ew.bsh.MarshalBinary()
+	log.PanicIf(err)
+
+	copy(region, raw)
+
+	// Each extended boot-sector ends with its signature (Section 3.2.2).
+	for i := 1; i <= mainExtendedBootSectorCount; i++ {
+		defaultEncoding.PutUint32(region[(i+1)*sectorSize-4:], requiredExtendedBootSignature)
+	}
+
+	fillBootChecksum(region, sectorSize)
+
+	err = ew.writeAt(region, 0)
+	log.PanicIf(err)
+
+	err = ew.writeAt(region, int64(len(region)))
+	log.PanicIf(err)
+
+	return nil
+}
+
+// Close writes the directories, the FAT, the allocation bitmap, and the boot
+// regions, after which the volume is complete. The underlying image is not
+// closed. The writer is closed even if this fails, since the directories may
+// already have been allocated, and the volume should be formatted again.
+func (ew *ExfatWriter) Close() (err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	if ew.isClosed == true {
+		log.Panicf("writer is already closed")
+	}
+
+	ew.isClosed = true
+
+	rootEntries, err := ew.rootEntries()
+	log.PanicIf(err)
+
+	err = ew.allocateDirectories(ew.root, len(rootEntries)/directoryEntryBytesCount)
+	log.PanicIf(err)
+
+	err = ew.writeDirectories(ew.root, rootEntries)
+	log.PanicIf(err)
+
+	ew.bsh.FirstClusterOfRootDirectory = ew.root.firstCluster
+
+	// Write the bitmap padded to whole clusters.
+
+	bitmapClusterCount := (uint64(len(ew.bitmap)) + uint64(ew.clusterSize) - 1) / uint64(ew.clusterSize)
+	bitmapData := make([]byte, bitmapClusterCount*uint64(ew.clusterSize))
+	copy(bitmapData, ew.bitmap)
+
+	err = ew.writeAt(bitmapData, ew.clusterOffset(ew.bitmapCluster))
+	log.PanicIf(err)
+
+	fatData := make([]byte, uint64(ew.bsh.FatLength)*uint64(ew.sectorSize))
+	for i, entry := range ew.fat {
+		defaultEncoding.PutUint32(fatData[i*4:], entry)
+	}
+
+	err = ew.writeAt(fatData, int64(ew.bsh.FatOffset)*int64(ew.sectorSize))
+	log.PanicIf(err)
+
+	// Clusters that were freed by truncating a file aren't reused, so count
+	// what's actually allocated.
+	usedClusterCount := uint64(0)
+	for _, b := range ew.bitmap {
+		usedClusterCount += uint64(bits.OnesCount8(b))
+	}
+
+	ew.bsh.PercentInUse = uint8(usedClusterCount * 100 / uint64(ew.bsh.ClusterCount))
+
+	err = ew.writeBootRegions()
+	log.PanicIf(err)
+
+	return nil
+}
@@ -0,0 +1,306 @@
+// This package supports writing files on a new volume at arbitrary offsets.
+
+package exfat
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/dsoprea/go-logging"
+)
+
+// WritableFile is a file on a volume that is being created, opened with
+// ExfatWriter.OpenFile(). Its clusters are allocated as it grows. Like the
+// rest of the volume, its directory-entry and the FAT aren't written until the
+// ExfatWriter is closed. It is not safe for concurrent use.
+type WritableFile struct {
+	ew         *ExfatWriter
+	node       *writerNode
+	pathParts  []string
+	isWritable bool
+	isClosed   bool
+}
+
+// OpenFile opens a file for writing. `flags` are the same as for os.OpenFile():
+// O_CREATE creates the file (with default metadata) if it doesn't exist,
+// O_EXCL (with O_CREATE) requires that it didn't, and O_TRUNC empties it.
+// O_WRONLY or O_RDWR is required to write. O_APPEND isn't supported since the
+// handle only supports positioned writes. The parent directory must already
+// exist.
+func (ew *ExfatWriter) OpenFile(pathParts []string, flags int) (wf *WritableFile, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	if ew.isClosed == true {
+		log.Panicf("writer is closed")
+	} else if len(pathParts) == 0 {
+		log.Panicf("path is empty")
+	} else if flags&os.O_APPEND != 0 {
+		log.Panicf("append mode is not supported: [%s]", JoinVolumePath(pathParts))
+	}
+
+	parent, err := ew.lookupDirectory(pathParts[:len(pathParts)-1])
+	log.PanicIf(err)
+
+	node, found := parent.childIndex[ew.ut.ToUpper(pathParts[len(pathParts)-1])]
+	if found == true {
+		if node.isDirectory == true {
+			log.Panicf("file is a directory: [%s]", JoinVolumePath(pathParts))
+		} else if flags&(os.O_CREATE|os.O_EXCL) == os.O_CREATE|os.O_EXCL {
+			log.Panicf("file already exists: [%s]", JoinVolumePath(pathParts))
+		}
+	} else if flags&os.O_CREATE == 0 {
+		log.Panicf("file not found: [%s]", JoinVolumePath(pathParts))
+	} else {
+		node, err = ew.addNode(pathParts, false, FileMetadata{})
+		log.PanicIf(err)
+	}
+
+	wf = &WritableFile{
+		ew:         ew,
+		node:       node,
+		pathParts:  pathParts,
+		isWritable: flags&(os.O_WRONLY|os.O_RDWR) != 0,
+	}
+
+	if flags&os.O_TRUNC != 0 && node.dataLength > 0 {
+		err := wf.Truncate(0)
+		log.PanicIf(err)
+	}
+
+	return wf, nil
+}
+
+// String returns a descriptive string.
+func (wf *WritableFile) String() string {
+	return fmt.Sprintf("WritableFile<PATH=[%s] VALID-DATA-LENGTH=(%d) DATA-LENGTH=(%d)>", JoinVolumePath(wf.pathParts), wf.node.validDataLength, wf.node.dataLength)
+}
+
+// Size returns the size of the file (DataLength).
+func (wf *WritableFile) Size() int64 {
+	return int64(wf.node.dataLength)
+}
+
+// checkWritable fails if the file can't be changed.
+func (wf *WritableFile) checkWritable() {
+	if wf.isClosed == true {
+		log.Panicf("file is closed: [%s]", JoinVolumePath(wf.pathParts))
+	} else if wf.ew.isClosed == true {
+		log.Panicf("writer is closed")
+	} else if wf.isWritable == false {
+		log.Panicf("file not opened for writing: [%s]", JoinVolumePath(wf.pathParts))
+	}
+}
+
+// touch updates the modified and accessed times.
+func (wf *WritableFile) touch() {
+	now := time.Now()
+
+	wf.node.metadata.ModifiedTime = now
+	wf.node.metadata.AccessedTime = now
+}
+
+// resize allocates or frees clusters so that the chain covers exactly `size`
+// bytes. Clusters are appended to the chain where it ends if they're the next
+// ones available, so a file that's only extended while nothing else is
+// allocated stays contiguous.
+func (wf *WritableFile) resize(size uint64) (err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	ew := wf.ew
+	node := wf.node
+
+	clusterSize := uint64(ew.clusterSize)
+
+	currentCount := (node.dataLength + clusterSize - 1) / clusterSize
+	requiredCount := (size + clusterSize - 1) / clusterSize
+
+	if requiredCount > currentCount {
+		firstCluster, err := ew.allocate((requiredCount - currentCount) * clusterSize)
+		log.PanicIf(err)
+
+		if currentCount == 0 {
+			node.firstCluster = firstCluster
+		} else {
+			clusters := ew.chain(node.firstCluster)
+			ew.fat[clusters[len(clusters)-1]] = firstCluster
+		}
+	} else if requiredCount < currentCount {
+		clusters := ew.chain(node.firstCluster)
+		ew.free(clusters[requiredCount:])
+
+		if requiredCount == 0 {
+			node.firstCluster = 0
+		} else {
+			ew.fat[clusters[requiredCount-1]] = 0xffffffff
+		}
+	}
+
+	node.dataLength = size
+
+	return nil
+}
+
+// writeData writes to the file's clusters at the given offset in the file,
+// which must already be allocated.
+func (wf *WritableFile) writeData(data []byte, offset uint64) (err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	ew := wf.ew
+	clusterSize := uint64(ew.clusterSize)
+
+	clusters := ew.chain(wf.node.firstCluster)
+
+	for len(data) > 0 {
+		clusterIndex := offset / clusterSize
+		clusterOffset := offset % clusterSize
+
+		count := clusterSize - clusterOffset
+		if uint64(len(data)) < count {
+			count = uint64(len(data))
+		}
+
+		err := ew.writeAt(data[:count], ew.clusterOffset(clusters[clusterIndex])+int64(clusterOffset))
+		log.PanicIf(err)
+
+		data = data[count:]
+		offset += count
+	}
+
+	return nil
+}
+
+// WriteAt writes `data` at the given offset, extending the file if it ends
+// past the end. Anything between the end of the valid data and the offset is
+// zeroed, and the valid data is extended to the end of the write.
+func (wf *WritableFile) WriteAt(data []byte, offset int64) (n int, err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	wf.checkWritable()
+
+	if offset < 0 {
+		log.Panic(ErrNegativeOffset)
+	} else if len(data) == 0 {
+		return 0, nil
+	}
+
+	end := uint64(offset) + uint64(len(data))
+
+	if end > wf.node.dataLength {
+		err := wf.resize(end)
+		log.PanicIf(err)
+	}
+
+	// The spec leaves whatever follows the valid data undefined, so it has to
+	// be zeroed before it becomes valid.
+	if uint64(offset) > wf.node.validDataLength {
+		zeros := make([]byte, wf.ew.clusterSize)
+
+		for position := wf.node.validDataLength; position < uint64(offset); {
+			count := uint64(offset) - position
+			if count > uint64(len(zeros)) {
+				count = uint64(len(zeros))
+			}
+
+			err := wf.writeData(zeros[:count], position)
+			log.PanicIf(err)
+
+			position += count
+		}
+	}
+
+	err = wf.writeData(data, uint64(offset))
+	log.PanicIf(err)
+
+	if end > wf.node.validDataLength {
+		wf.node.validDataLength = end
+	}
+
+	wf.touch()
+
+	return len(data), nil
+}
+
+// Truncate changes the size of the file (DataLength). Clusters are allocated
+// or freed as needed. When the file is extended, nothing is written and the
+// valid data stays where it was; the spec has readers treat what follows it
+// as zeros.
+func (wf *WritableFile) Truncate(size int64) (err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	wf.checkWritable()
+
+	if size < 0 {
+		log.Panicf("size can not be negative: (%d)", size)
+	}
+
+	err = wf.resize(uint64(size))
+	log.PanicIf(err)
+
+	if wf.node.validDataLength > uint64(size) {
+		wf.node.validDataLength = uint64(size)
+	}
+
+	wf.touch()
+
+	return nil
+}
+
+// Sync flushes the image if it supports it (e.g. *os.File). Only the data
+// that's been written is flushed; the directory-entry and the FAT are written
+// when the ExfatWriter is closed.
+func (wf *WritableFile) Sync() (err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	if wf.isClosed == true {
+		log.Panicf("file is closed: [%s]", JoinVolumePath(wf.pathParts))
+	}
+
+	if syncer, ok := wf.ew.w.(interface{ Sync() error }); ok == true {
+		err := syncer.Sync()
+		log.PanicIf(err)
+	}
+
+	return nil
+}
+
+// Close releases the handle. Nothing is written.
+func (wf *WritableFile) Close() (err error) {
+	defer func() {
+		if errRaw := recover(); errRaw != nil {
+			err = log.Wrap(errRaw.(error))
+		}
+	}()
+
+	if wf.isClosed == true {
+		log.Panicf("file is already closed: [%s]", JoinVolumePath(wf.pathParts))
+	}
+
+	wf.isClosed = true
+
+	return nil
+}
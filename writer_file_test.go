@@ -0,0 +1,305 @@
+package exfat
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dsoprea/go-logging"
+)
+
+func TestExfatWriter_OpenFile(t *testing.T) {
+	f, closer := getTestNewImage()
+
+	defer closer()
+
+	ew, err := NewExfatWriter(f, 4*1024*1024, FormatOptions{})
+	log.PanicIf(err)
+
+	wf, err := ew.OpenFile([]string{"file"}, os.O_CREATE|os.O_WRONLY)
+	log.PanicIf(err)
+
+	_, err = wf.WriteAt([]byte("hello"), 0)
+	log.PanicIf(err)
+
+	// Allocate something right after the first cluster so that the file has
+	// to be fragmented to grow.
+	other := []byte(strings.Repeat("x", 100))
+
+	err = ew.CreateFile([]string{"other"}, bytes.NewReader(other), uint64(len(other)), FileMetadata{})
+	log.PanicIf(err)
+
+	_, err = wf.WriteAt([]byte("world"), 5000)
+	log.PanicIf(err)
+
+	_, err = wf.WriteAt([]byte("HELLO"), 0)
+	log.PanicIf(err)
+
+	if wf.Size() != 5005 {
+		t.Fatalf("Size not correct: (%d)", wf.Size())
+	}
+
+	err = wf.Sync()
+	log.PanicIf(err)
+
+	err = wf.Close()
+	log.PanicIf(err)
+
+	err = ew.Close()
+	log.PanicIf(err)
+
+	er, image := getTestParsedImage(f)
+
+	tree := NewTree(er)
+
+	node, err := tree.Lookup([]string{"file"})
+	log.PanicIf(err)
+
+	sede := node.StreamDirectoryEntry()
+
+	if sede.ValidDataLength != 5005 || sede.DataLength != 5005 {
+		t.Fatalf("Lengths not correct: %s", sede)
+	} else if sede.GeneralSecondaryFlags.NoFatChain() != false {
+		t.Fatalf("Expected a fragmented file to use the FAT.")
+	}
+
+	expected := make([]byte, 5005)
+	copy(expected, "HELLO")
+	copy(expected[5000:], "world")
+
+	b := new(bytes.Buffer)
+
+	err = node.WriteData(b, false)
+	log.PanicIf(err)
+
+	if bytes.Equal(b.Bytes(), expected) != true {
+		t.Fatalf("File data not correct.")
+	}
+
+	node, err = tree.Lookup([]string{"other"})
+	log.PanicIf(err)
+
+	b = new(bytes.Buffer)
+
+	err = node.WriteData(b, false)
+	log.PanicIf(err)
+
+	if bytes.Equal(b.Bytes(), other) != true {
+		t.Fatalf("Other file data not correct.")
+	}
+
+	report := getTestComplianceReport(image)
+
+	if len(report.Findings) != 0 {
+		t.Fatalf("Expected no compliance findings: %v", report.Findings)
+	}
+}
+
+func TestExfatWriter_OpenFile__Contiguous(t *testing.T) {
+	f, closer := getTestNewImage()
+
+	defer closer()
+
+	ew, err := NewExfatWriter(f, 4*1024*1024, FormatOptions{})
+	log.PanicIf(err)
+
+	wf, err := ew.OpenFile([]string{"file"}, os.O_CREATE|os.O_RDWR)
+	log.PanicIf(err)
+
+	data := []byte(strings.Repeat("0123456789", 1000))
+
+	for i := 0; i < len(data); i += 1000 {
+		_, err := wf.WriteAt(data[i:i+1000], int64(i))
+		log.PanicIf(err)
+	}
+
+	err = ew.Close()
+	log.PanicIf(err)
+
+	er, _ := getTestParsedImage(f)
+
+	tree := NewTree(er)
+
+	node, err := tree.Lookup([]string{"file"})
+	log.PanicIf(err)
+
+	if node.StreamDirectoryEntry().GeneralSecondaryFlags.NoFatChain() != true {
+		t.Fatalf("Expected a contiguous file to not use the FAT.")
+	}
+
+	b := new(bytes.Buffer)
+
+	err = node.WriteData(b, false)
+	log.PanicIf(err)
+
+	if bytes.Equal(b.Bytes(), data) != true {
+		t.Fatalf("File data not correct.")
+	}
+}
+
+func TestWritableFile_Truncate(t *testing.T) {
+	f, closer := getTestNewImage()
+
+	defer closer()
+
+	ew, err := NewExfatWriter(f, 4*1024*1024, FormatOptions{})
+	log.PanicIf(err)
+
+	modifiedTime := time.Date(2021, 5, 6, 7, 8, 10, 0, time.UTC)
+
+	data := []byte(strings.Repeat("0123456789", 1000))
+
+	err = ew.CreateFile([]string{"file"}, bytes.NewReader(data), uint64(len(data)), FileMetadata{ModifiedTime: modifiedTime})
+	log.PanicIf(err)
+
+	wf, err := ew.OpenFile([]string{"FILE"}, os.O_WRONLY)
+	log.PanicIf(err)
+
+	err = wf.Truncate(100)
+	log.PanicIf(err)
+
+	err = wf.Truncate(9000)
+	log.PanicIf(err)
+
+	err = ew.Close()
+	log.PanicIf(err)
+
+	er, image := getTestParsedImage(f)
+
+	tree := NewTree(er)
+
+	node, err := tree.Lookup([]string{"file"})
+	log.PanicIf(err)
+
+	if node.Size() != 100 {
+		t.Fatalf("Valid data-length not correct: (%d)", node.Size())
+	} else if node.AllocatedSize() != 9000 {
+		t.Fatalf("Data-length not correct: (%d)", node.AllocatedSize())
+	} else if node.FileDirectoryEntry().LastModifiedTimestamp().After(modifiedTime) != true {
+		t.Fatalf("Modified time not updated: [%s]", node.FileDirectoryEntry().LastModifiedTimestamp())
+	}
+
+	b := new(bytes.Buffer)
+
+	err = node.WriteData(b, false)
+	log.PanicIf(err)
+
+	if bytes.Equal(b.Bytes(), data[:100]) != true {
+		t.Fatalf("File data not correct.")
+	}
+
+	report := getTestComplianceReport(image)
+
+	if len(report.Findings) != 0 {
+		t.Fatalf("Expected no compliance findings: %v", report.Findings)
+	}
+}
+
+func TestWritableFile_Truncate__Empty(t *testing.T) {
+	f, closer := getTestNewImage()
+
+	defer closer()
+
+	ew, err := NewExfatWriter(f, 4*1024*1024, FormatOptions{})
+	log.PanicIf(err)
+
+	data := []byte(strings.Repeat("0123456789", 1000))
+
+	err = ew.CreateFile([]string{"file"}, bytes.NewReader(data), uint64(len(data)), FileMetadata{})
+	log.PanicIf(err)
+
+	wf, err := ew.OpenFile([]string{"file"}, os.O_WRONLY|os.O_TRUNC)
+	log.PanicIf(err)
+
+	if wf.Size() != 0 {
+		t.Fatalf("Expected file to be truncated: (%d)", wf.Size())
+	}
+
+	err = ew.Close()
+	log.PanicIf(err)
+
+	er, image := getTestParsedImage(f)
+
+	tree := NewTree(er)
+
+	node, err := tree.Lookup([]string{"file"})
+	log.PanicIf(err)
+
+	if node.Size() != 0 || node.StreamDirectoryEntry().FirstCluster != 0 {
+		t.Fatalf("Expected an empty file: %s", node.StreamDirectoryEntry())
+	}
+
+	report := getTestComplianceReport(image)
+
+	if len(report.Findings) != 0 {
+		t.Fatalf("Expected no compliance findings: %v", report.Findings)
+	}
+}
+
+func TestExfatWriter_OpenFile__Invalid(t *testing.T) {
+	f, closer := getTestNewImage()
+
+	defer closer()
+
+	ew, err := NewExfatWriter(f, 2*1024*1024, FormatOptions{})
+	log.PanicIf(err)
+
+	err = ew.Mkdir([]string{"directory"}, FileMetadata{})
+	log.PanicIf(err)
+
+	err = ew.CreateFile([]string{"file"}, bytes.NewReader(nil), 0, FileMetadata{})
+	log.PanicIf(err)
+
+	_, err = ew.OpenFile([]string{"missing"}, os.O_WRONLY)
+	if err == nil {
+		t.Fatalf("Expected error for a missing file.")
+	}
+
+	_, err = ew.OpenFile([]string{"file"}, os.O_WRONLY|os.O_CREATE|os.O_EXCL)
+	if err == nil {
+		t.Fatalf("Expected error for an existing file.")
+	}
+
+	_, err = ew.OpenFile([]string{"directory"}, os.O_WRONLY)
+	if err == nil {
+		t.Fatalf("Expected error for a directory.")
+	}
+
+	_, err = ew.OpenFile([]string{"file"}, os.O_WRONLY|os.O_APPEND)
+	if err == nil {
+		t.Fatalf("Expected error for append mode.")
+	}
+
+	wf, err := ew.OpenFile([]string{"file"}, os.O_RDONLY)
+	log.PanicIf(err)
+
+	_, err = wf.WriteAt([]byte("data"), 0)
+	if err == nil {
+		t.Fatalf("Expected error for a read-only file.")
+	}
+
+	wf, err = ew.OpenFile([]string{"file"}, os.O_WRONLY)
+	log.PanicIf(err)
+
+	_, err = wf.WriteAt([]byte("data"), -1)
+	if err == nil {
+		t.Fatalf("Expected error for a negative offset.")
+	}
+
+	_, err = wf.WriteAt([]byte("data"), 4*1024*1024)
+	if err == nil {
+		t.Fatalf("Expected error for a full volume.")
+	} else if wf.Size() != 0 {
+		t.Fatalf("Expected the size to be unchanged: (%d)", wf.Size())
+	}
+
+	err = ew.Close()
+	log.PanicIf(err)
+
+	_, err = wf.WriteAt([]byte("data"), 0)
+	if err == nil {
+		t.Fatalf("Expected error after the writer was closed.")
+	}
+}
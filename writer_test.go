@@ -0,0 +1,399 @@
+package exfat
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dsoprea/go-logging"
+)
+
+var (
+	errTestWriteFailed = errors.New("write failed")
+)
+
+// getTestNewImage returns an empty temporary file for a new volume.
+func getTestNewImage() (f *os.File, closer func()) {
+	f, err := ioutil.TempFile("", "exfat-writer-")
+	log.PanicIf(err)
+
+	closer = func() {
+		f.Close()
+		os.Remove(f.Name())
+	}
+
+	return f, closer
+}
+
+// getTestParsedImage parses the volume that was written to the given file.
+func getTestParsedImage(f *os.File) (er *ExfatReader, image []byte) {
+	image, err := ioutil.ReadFile(f.Name())
+	log.PanicIf(err)
+
+	er = NewExfatReader(bytes.NewReader(image))
+
+	err = er.Parse()
+	log.PanicIf(err)
+
+	return er, image
+}
+
+func TestNewExfatWriter(t *testing.T) {
+	f, closer := getTestNewImage()
+
+	defer closer()
+
+	options := FormatOptions{
+		Label:        "newvolume",
+		SerialNumber: 0x12345678,
+	}
+
+	ew, err := NewExfatWriter(f, 4*1024*1024, options)
+	log.PanicIf(err)
+
+	modifiedTime := time.Date(2021, 5, 6, 7, 8, 10, 0, time.UTC)
+
+	metadata := FileMetadata{
+		ModifiedTime: modifiedTime,
+	}
+
+	err = ew.Mkdir([]string{"directory1"}, metadata)
+	log.PanicIf(err)
+
+	data := []byte(strings.Repeat("0123456789", 1000))
+
+	metadata = FileMetadata{
+		Attributes:   1,
+		ModifiedTime: modifiedTime,
+	}
+
+	err = ew.CreateFile([]string{"directory1", "a file with a rather long name.txt"}, bytes.NewReader(data), uint64(len(data)), metadata)
+	log.PanicIf(err)
+
+	err = ew.CreateFile([]string{"empty"}, bytes.NewReader(nil), 0, FileMetadata{})
+	log.PanicIf(err)
+
+	err = ew.Close()
+	log.PanicIf(err)
+
+	er, image := getTestParsedImage(f)
+
+	if len(image) != 4*1024*1024 {
+		t.Fatalf("Image not the right size: (%d)", len(image))
+	}
+
+	vi, err := er.VolumeInfo()
+	log.PanicIf(err)
+
+	if vi.Label != "newvolume" {
+		t.Fatalf("Label not correct: [%s]", vi.Label)
+	} else if vi.SerialNumber != 0x12345678 {
+		t.Fatalf("Serial number not correct: (0x%08x)", vi.SerialNumber)
+	} else if vi.ClusterSize != 4096 {
+		t.Fatalf("Cluster-size not correct: (%d)", vi.ClusterSize)
+	}
+
+	tree := NewTree(er)
+
+	node, err := tree.Lookup([]string{"directory1", "a file with a rather long name.txt"})
+	log.PanicIf(err)
+
+	if node == nil {
+		t.Fatalf("File not found.")
+	}
+
+	b := new(bytes.Buffer)
+
+	err = node.WriteData(b, false)
+	log.PanicIf(err)
+
+	if bytes.Equal(b.Bytes(), data) != true {
+		t.Fatalf("File data not correct.")
+	}
+
+	fdf := node.FileDirectoryEntry()
+
+	if fdf.FileAttributes.IsReadOnly() != true || fdf.FileAttributes.IsDirectory() != false {
+		t.Fatalf("Attributes not correct: %s", fdf.FileAttributes)
+	} else if fdf.LastModifiedTimestamp().Equal(modifiedTime) != true {
+		t.Fatalf("Modified time not correct: [%s]", fdf.LastModifiedTimestamp())
+	}
+
+	node, err = tree.Lookup([]string{"DIRECTORY1"})
+	log.PanicIf(err)
+
+	if node != nil {
+		t.Fatalf("Expected lookups to be case-sensitive.")
+	}
+
+	node, err = tree.Lookup([]string{"empty"})
+	log.PanicIf(err)
+
+	if node == nil || node.Size() != 0 {
+		t.Fatalf("Empty file not correct.")
+	}
+
+	ut, err := er.ReadUpcaseTable()
+	log.PanicIf(err)
+
+	if ut.ToUpper("abcäé") != "ABCÄÉ" {
+		t.Fatalf("Up-case table not correct: [%s]", ut.ToUpper("abcäé"))
+	}
+
+	report := getTestComplianceReport(image)
+
+	if len(report.Findings) != 0 {
+		t.Fatalf("Expected no compliance findings: %v", report.Findings)
+	}
+}
+
+func TestNewExfatWriter__ManyEntries(t *testing.T) {
+	f, closer := getTestNewImage()
+
+	defer closer()
+
+	ew, err := NewExfatWriter(f, 2*1024*1024, FormatOptions{ClusterSize: 512})
+	log.PanicIf(err)
+
+	// Enough entries to fill several clusters.
+
+	for i := 0; i < 100; i++ {
+		name := strings.Repeat("x", i%20+1) + string(rune('a'+i%26)) + string(rune('a'+i/26))
+
+		err := ew.CreateFile([]string{name}, bytes.NewReader([]byte(name)), uint64(len(name)), FileMetadata{})
+		log.PanicIf(err)
+	}
+
+	err = ew.Close()
+	log.PanicIf(err)
+
+	er, image := getTestParsedImage(f)
+
+	tree := NewTree(er)
+
+	files, _, err := tree.List()
+	log.PanicIf(err)
+
+	if len(files) != 100 {
+		t.Fatalf("File count not correct: (%d)", len(files))
+	}
+
+	report := getTestComplianceReport(image)
+
+	if len(report.Findings) != 0 {
+		t.Fatalf("Expected no compliance findings: %v", report.Findings)
+	}
+}
+
+func TestExfatWriter_CreateFile__Invalid(t *testing.T) {
+	f, closer := getTestNewImage()
+
+	defer closer()
+
+	ew, err := NewExfatWriter(f, 2*1024*1024, FormatOptions{})
+	log.PanicIf(err)
+
+	err = ew.CreateFile([]string{"a:b"}, bytes.NewReader(nil), 0, FileMetadata{})
+	if err == nil {
+		t.Fatalf("Expected error for an invalid character.")
+	}
+
+	err = ew.CreateFile([]string{"missing", "file"}, bytes.NewReader(nil), 0, FileMetadata{})
+	if err == nil {
+		t.Fatalf("Expected error for a missing directory.")
+	}
+
+	err = ew.CreateFile([]string{"file"}, bytes.NewReader(nil), 0, FileMetadata{})
+	log.PanicIf(err)
+
+	err = ew.CreateFile([]string{"FILE"}, bytes.NewReader(nil), 0, FileMetadata{})
+	if err == nil {
+		t.Fatalf("Expected error for a duplicate name.")
+	}
+
+	err = ew.CreateFile([]string{"big"}, bytes.NewReader(make([]byte, 4*1024*1024)), 4*1024*1024, FileMetadata{})
+	if err == nil {
+		t.Fatalf("Expected error for a full volume.")
+	}
+
+	// The file that didn't fit wasn't left behind.
+	err = ew.CreateFile([]string{"big"}, bytes.NewReader(nil), 0, FileMetadata{})
+	log.PanicIf(err)
+}
+
+func TestExfatWriter_CreateFile__DuplicateDoesNotAllocate(t *testing.T) {
+	data := []byte(strings.Repeat("0123456789", 1000))
+
+	// createVolume returns the free-cluster count of a volume with one file,
+	// after trying to create it a second time if `addDuplicate` is true.
+	createVolume := func(addDuplicate bool) (freeClusterCount uint32, image []byte) {
+		f, closer := getTestNewImage()
+
+		defer closer()
+
+		ew, err := NewExfatWriter(f, 4*1024*1024, FormatOptions{})
+		log.PanicIf(err)
+
+		err = ew.CreateFile([]string{"file"}, bytes.NewReader(data), uint64(len(data)), FileMetadata{})
+		log.PanicIf(err)
+
+		if addDuplicate == true {
+			err = ew.CreateFile([]string{"FILE"}, bytes.NewReader(data), uint64(len(data)), FileMetadata{})
+			if err == nil {
+				t.Fatalf("Expected error for a duplicate name.")
+			}
+		}
+
+		err = ew.Close()
+		log.PanicIf(err)
+
+		er, image := getTestParsedImage(f)
+
+		ab, err := er.ReadAllocationBitmap()
+		log.PanicIf(err)
+
+		return ab.FreeClusterCount(), image
+	}
+
+	expectedFreeClusterCount, _ := createVolume(false)
+	freeClusterCount, image := createVolume(true)
+
+	if freeClusterCount != expectedFreeClusterCount {
+		t.Fatalf("Free-cluster count not correct: (%d) != (%d)", freeClusterCount, expectedFreeClusterCount)
+	}
+
+	report := getTestComplianceReport(image)
+
+	if len(report.Findings) != 0 {
+		t.Fatalf("Expected no compliance findings: %v", report.Findings)
+	}
+}
+
+func TestExfatWriter_MkdirAll(t *testing.T) {
+	f, closer := getTestNewImage()
+
+	defer closer()
+
+	ew, err := NewExfatWriter(f, 2*1024*1024, FormatOptions{})
+	log.PanicIf(err)
+
+	err = ew.Mkdir([]string{"a"}, FileMetadata{})
+	log.PanicIf(err)
+
+	err = ew.MkdirAll([]string{"A", "b", "c"}, FileMetadata{})
+	log.PanicIf(err)
+
+	// Already exists.
+	err = ew.MkdirAll([]string{"a", "B"}, FileMetadata{})
+	log.PanicIf(err)
+
+	err = ew.CreateFile([]string{"a", "b", "c", "file"}, bytes.NewReader([]byte("data")), 4, FileMetadata{})
+	log.PanicIf(err)
+
+	err = ew.MkdirAll([]string{"a", "b", "c", "file", "d"}, FileMetadata{})
+	if err == nil {
+		t.Fatalf("Expected error for a file in the path.")
+	}
+
+	err = ew.Close()
+	log.PanicIf(err)
+
+	er, image := getTestParsedImage(f)
+
+	tree := NewTree(er)
+
+	for _, pathParts := range [][]string{{"a"}, {"a", "b"}, {"a", "b", "c"}} {
+		node, err := tree.Lookup(pathParts)
+		log.PanicIf(err)
+
+		if node == nil || node.IsDirectory() != true {
+			t.Fatalf("Directory not found: %v", pathParts)
+		}
+	}
+
+	node, err := tree.Lookup([]string{"a", "b", "c", "file"})
+	log.PanicIf(err)
+
+	if node == nil || node.Size() != 4 {
+		t.Fatalf("File not found.")
+	}
+
+	files, _, err := tree.List()
+	log.PanicIf(err)
+
+	if len(files) != 4 {
+		t.Fatalf("Unexpected entries: %v", files)
+	}
+
+	report := getTestComplianceReport(image)
+
+	if len(report.Findings) != 0 {
+		t.Fatalf("Expected no compliance findings: %v", report.Findings)
+	}
+}
+
+// testFailingWriterAt fails every write once `isFailing` is set.
+type testFailingWriterAt struct {
+	w         io.WriterAt
+	isFailing bool
+}
+
+func (tfwa *testFailingWriterAt) WriteAt(p []byte, offset int64) (n int, err error) {
+	if tfwa.isFailing == true {
+		return 0, errTestWriteFailed
+	}
+
+	return tfwa.w.WriteAt(p, offset)
+}
+
+func TestExfatWriter_Close__Failed(t *testing.T) {
+	f, closer := getTestNewImage()
+
+	defer closer()
+
+	tfwa := &testFailingWriterAt{
+		w: f,
+	}
+
+	ew, err := NewExfatWriter(tfwa, 4*1024*1024, FormatOptions{})
+	log.PanicIf(err)
+
+	err = ew.Mkdir([]string{"directory1"}, FileMetadata{})
+	log.PanicIf(err)
+
+	tfwa.isFailing = true
+
+	err = ew.Close()
+	if err == nil {
+		t.Fatalf("Expected error for a failed write.")
+	} else if errors.Is(err, errTestWriteFailed) != true {
+		t.Fatalf("Error not correct: [%v]", err)
+	}
+
+	rootFirstCluster := ew.root.firstCluster
+
+	// A second attempt must not allocate the directories again.
+
+	tfwa.isFailing = false
+
+	err = ew.Close()
+	if err == nil {
+		t.Fatalf("Expected error for a writer that's already closed.")
+	} else if err.Error() != "writer is already closed" {
+		t.Fatalf("Error not correct: [%v]", err)
+	}
+
+	if ew.root.firstCluster != rootFirstCluster {
+		t.Fatalf("Root directory was allocated again: (%d) != (%d)", ew.root.firstCluster, rootFirstCluster)
+	}
+
+	err = ew.Mkdir([]string{"directory2"}, FileMetadata{})
+	if err == nil {
+		t.Fatalf("Expected error for a writer that's already closed.")
+	}
+}